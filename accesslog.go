@@ -0,0 +1,89 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ResponseLog records a description of the response written in reply to a request, so that
+// access logging middleware can include the status code, size, duration, error and paging
+// information of the response without wrapping the http.ResponseWriter. A ResponseLog is safe for
+// concurrent use.
+//
+// For example:
+//
+//	func logAccess(next http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			ctx, l := jsonresp.ContextWithResponseLog(r.Context())
+//			next.ServeHTTP(w, r.WithContext(ctx))
+//			if info, ok := l.Info(); ok {
+//				log.Printf("%v %v %v %vB %v", r.Method, r.URL.Path, info.Code, info.Size, info.Duration)
+//			}
+//		})
+//	}
+type ResponseLog struct {
+	mu      sync.Mutex
+	info    ResponseInfo
+	written bool
+}
+
+type responseLogKey struct{}
+
+// ContextWithResponseLog returns a copy of ctx carrying a new ResponseLog, which records the
+// response written by the write functions with the context, either as supplied to the context
+// write functions, such as WriteResponseContext, or as that of the request supplied to
+// WithRequest.
+func ContextWithResponseLog(ctx context.Context) (context.Context, *ResponseLog) {
+	l := &ResponseLog{}
+	return context.WithValue(ctx, responseLogKey{}, l), l
+}
+
+// ResponseLogFromContext returns the ResponseLog carried by ctx, or nil if there is none.
+func ResponseLogFromContext(ctx context.Context) *ResponseLog {
+	l, _ := ctx.Value(responseLogKey{}).(*ResponseLog)
+	return l
+}
+
+// Info returns the description of the response recorded by l, and reports whether a response has
+// been recorded. If more than one response was written with the context of l, the last is
+// returned.
+func (l *ResponseLog) Info() (ResponseInfo, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.info, l.written
+}
+
+// set records info as the description of the response written.
+func (l *ResponseLog) set(info ResponseInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.info, l.written = info, true
+}
+
+// responseLog returns the ResponseLog carried by the context of o, or that of its request, or nil
+// if there is none.
+func (o *options) responseLog() *ResponseLog {
+	if o.ctx != nil {
+		if l := ResponseLogFromContext(o.ctx); l != nil {
+			return l
+		}
+	}
+	if o.request != nil {
+		return ResponseLogFromContext(o.request.Context())
+	}
+	return nil
+}
+
+// startTiming records the time at which the write function was called, if a description of the
+// response is to be observed, and it has not already been recorded.
+func (o *options) startTiming() {
+	if o.start.IsZero() && (len(o.responseObservers) > 0 || o.responseLog() != nil) {
+		o.start = time.Now()
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestContextWithResponseLog(t *testing.T) {
+	pd := &PageDetails{Next: "n"}
+
+	tests := []struct {
+		name  string
+		write func(ctx context.Context, w http.ResponseWriter) error
+		want  ResponseInfo
+	}{
+		{"Request", func(ctx context.Context, w http.ResponseWriter) error {
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			return WriteResponsePage(w, "blah", pd, http.StatusOK, WithRequest(r))
+		}, ResponseInfo{Code: http.StatusOK, Size: len(`{"data":"blah","page":{"next":"n"}}`), Page: pd}},
+		{"Context", func(ctx context.Context, w http.ResponseWriter) error {
+			return WriteErrorContext(ctx, w, "blah", http.StatusNotFound)
+		}, ResponseInfo{Code: http.StatusNotFound, Size: len(`{"error":{"code":404,"message":"blah"}}`), Error: NewError("blah", http.StatusNotFound)}},
+		{"Handler", func(ctx context.Context, w http.ResponseWriter) error {
+			h := Chain{}.Then(func(w http.ResponseWriter, r *http.Request) error {
+				return NewError("blah", http.StatusConflict)
+			})
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+			return nil
+		}, ResponseInfo{Code: http.StatusConflict, Size: len(`{"error":{"code":409,"message":"blah"}}`), Error: NewError("blah", http.StatusConflict)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, l := ContextWithResponseLog(context.Background())
+			if got := ResponseLogFromContext(ctx); got != l {
+				t.Errorf("got log %p, want %p", got, l)
+			}
+			if _, ok := l.Info(); ok {
+				t.Fatal("unexpected response recorded")
+			}
+
+			if err := tt.write(ctx, httptest.NewRecorder()); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			got, ok := l.Info()
+			if !ok {
+				t.Fatal("no response recorded")
+			}
+			if got.Duration < 0 {
+				t.Errorf("got negative duration %v", got.Duration)
+			}
+			got.Duration = 0
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseLogDuration(t *testing.T) {
+	ctx, l := ContextWithResponseLog(context.Background())
+
+	const delay = 10 * time.Millisecond
+	hook := func(*http.Request, *Response) { time.Sleep(delay) }
+	if err := WriteResponseContext(ctx, httptest.NewRecorder(), "blah", http.StatusOK, WithResponseHook(hook)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if info, _ := l.Info(); info.Duration < delay {
+		t.Errorf("got duration %v, want at least %v", info.Duration, delay)
+	}
+}
+
+func TestResponseLogEncodeFailure(t *testing.T) {
+	ctx, l := ContextWithResponseLog(context.Background())
+
+	err := WriteResponseContext(ctx, httptest.NewRecorder(), func() {}, http.StatusOK)
+	if err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	info, ok := l.Info()
+	if !ok {
+		t.Fatal("no response recorded")
+	}
+	if got, want := info.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if !errors.Is(info.Error, NewError(encodeFailureMessage, http.StatusInternalServerError)) {
+		t.Errorf("got error %v", info.Error)
+	}
+}
+
+func TestResponseLogAbsent(t *testing.T) {
+	if l := ResponseLogFromContext(context.Background()); l != nil {
+		t.Errorf("got log %p, want nil", l)
+	}
+
+	o := newOptions([]Option{WithRequest(httptest.NewRequest(http.MethodGet, "/", nil))})
+	if !o.start.IsZero() {
+		t.Errorf("got start %v, want zero", o.start)
+	}
+}
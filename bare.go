@@ -0,0 +1,80 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// WithBare causes successful responses to be written as the encoded data alone, without the
+// response envelope, and causes the read functions to accept responses with or without the
+// envelope. This eases migration from APIs that return bare values.
+//
+// When writing, the page details, warnings, meta and links of the response are not represented,
+// and errors are written within the envelope as usual. WithStream has no effect on bare
+// responses. When reading, a body is treated as an envelope if it is an object whose members are
+// all fields of the envelope, and as bare data otherwise.
+func WithBare() Option {
+	return func(o *options) {
+		o.bare = true
+	}
+}
+
+// isBare reports whether jr is written without the response envelope.
+func (o *options) isBare(jr Response) bool {
+	return o.bare && jr.Error == nil
+}
+
+// body returns the value encoded as the body of the response jr.
+func (o *options) body(jr Response) interface{} {
+	if o.isBare(jr) {
+		return jr.Data
+	}
+	return jr
+}
+
+// envelopeFields are the default object keys of the fields of the response envelope.
+var envelopeFields = []string{"data", "page", "error", "warnings", "meta", "links"}
+
+// isEnvelope reports whether the JSON value b is a response envelope, with the field names
+// established by o.
+func (o *options) isEnvelope(b []byte) bool {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil || len(m) == 0 {
+		return false
+	}
+
+	names := make([]string, 0, len(envelopeFields))
+	for _, f := range envelopeFields {
+		names = append(names, o.fieldNames.name(f))
+	}
+	for k := range m {
+		if !contains(names, k) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeResponse decodes a response envelope from r into u. If o permits bare responses and the
+// response is not an envelope, it is decoded as the data of u. If decoding fails, a DecodeError is
+// returned.
+func (o *options) decodeResponse(r io.Reader, u *rawResponse) error {
+	if !o.bare {
+		return o.decode(r, u)
+	}
+
+	var b json.RawMessage
+	if err := o.decode(r, &b); err != nil {
+		return err
+	}
+	if !o.isEnvelope(b) {
+		u.Data = b
+		return nil
+	}
+	return o.unmarshalData(b, o.renameFields(u))
+}
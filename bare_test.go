@@ -0,0 +1,137 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bareThing struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestWithBareWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		opts []Option
+		want string
+	}{
+		{"Object", bareThing{1, "a"}, nil, `{"id":1,"name":"a"}`},
+		{"Slice", []int{1, 2}, nil, `[1,2]`},
+		{"Nil", nil, nil, `null`},
+		{"Stream", []int{1, 2}, []Option{WithStream()}, `[1,2]`},
+		{"Indent", []int{1}, []Option{WithIndent("", " ")}, "[\n 1\n]"},
+		{"Warning", "blah", []Option{WithWarning("W", "w")}, `"blah"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, tt.data, http.StatusOK, append([]Option{WithBare()}, tt.opts...)...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := rr.Body.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithBareWriteError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithBare()); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"error":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEncodeResponseBare(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeResponse(&buf, Response{Data: "blah", Page: &PageDetails{Next: "n"}}, WithBare()); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if got, want := buf.String(), `"blah"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWithBareRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		opts     []Option
+		want     bareThing
+		wantPage *PageDetails
+		wantErr  error
+	}{
+		{"Bare", `{"id":1,"name":"a"}`, nil, bareThing{1, "a"}, nil, nil},
+		{"Envelope", `{"data":{"id":1,"name":"a"}}`, nil, bareThing{1, "a"}, nil, nil},
+		{"EnvelopePage", `{"data":{"id":1},"page":{"next":"n"}}`, nil, bareThing{ID: 1}, &PageDetails{Next: "n"}, nil},
+		{"EnvelopeError", `{"error":{"code":404}}`, nil, bareThing{}, nil, &Error{Code: http.StatusNotFound}},
+		{"BareWithDataMember", `{"data":1,"id":1}`, nil, bareThing{ID: 1}, nil, nil},
+		{"EmptyObject", `{}`, nil, bareThing{}, nil, nil},
+		{"FieldNames", `{"result":{"id":1}}`, []Option{WithFieldNames(FieldNames{Data: "result"})}, bareThing{ID: 1}, nil, nil},
+		{"FieldNamesBare", `{"data":{"id":1},"id":2}`, []Option{WithFieldNames(FieldNames{Data: "result"})}, bareThing{ID: 2}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bareThing
+			pd, err := ReadResponsePage(strings.NewReader(tt.body), &got, append([]Option{WithBare()}, tt.opts...)...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(pd, tt.wantPage) {
+				t.Errorf("got page %v, want %v", pd, tt.wantPage)
+			}
+		})
+	}
+}
+
+func TestWithBareReadSlice(t *testing.T) {
+	var got []int
+	if err := ReadResponse(strings.NewReader(`[1,2]`), &got, WithBare()); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWithBareReadInvalid(t *testing.T) {
+	var de *DecodeError
+	if err := ReadResponse(strings.NewReader(`{`), nil, WithBare()); !errors.As(err, &de) {
+		t.Errorf("got error %v, want DecodeError", err)
+	}
+}
+
+func TestReadErrorBare(t *testing.T) {
+	if err := ReadError(strings.NewReader(`{"id":1}`), WithBare()); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+
+	want := &Error{Code: http.StatusNotFound}
+	if err := ReadError(strings.NewReader(`{"error":{"code":404}}`), WithBare()); !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// DefaultMaxBatchSize is the maximum number of requests in a batch accepted by BatchHandler,
+// unless overridden by its MaxRequests field.
+const DefaultMaxBatchSize = 100
+
+// BatchItem is a request contained within a batch.
+type BatchItem struct {
+	// ID identifies the request, and is returned with its result.
+	ID string `json:"id"`
+
+	// Method is the HTTP method of the request.
+	Method string `json:"method"`
+
+	// Path is the URL path of the request, including any query string.
+	Path string `json:"path"`
+
+	// Body is the JSON-encoded body of the request, if any.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchRequest is a batch of requests bundled into a single HTTP request.
+type BatchRequest struct {
+	Requests []BatchItem `json:"requests"`
+}
+
+// Add appends a request with the supplied ID, method, path and body to br. If body is not nil, it
+// is encoded as JSON.
+func (br *BatchRequest) Add(id, method, path string, body interface{}) error {
+	bi := BatchItem{
+		ID:     id,
+		Method: method,
+		Path:   path,
+	}
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("jsonresp: failed to encode batch request body: %v", err)
+		}
+		bi.Body = b
+	}
+	br.Requests = append(br.Requests, bi)
+	return nil
+}
+
+// BatchHandler is an http.Handler that serves batch requests. The body of a batch request is a
+// JSON-encoded BatchRequest. Each request it contains is dispatched in turn to Handler, with the
+// headers and context of the batch request, and the results are written as a 207 Multi-Status
+// response, as if by WriteMultiStatus. Handler is expected to write its responses in the format
+// written by the write functions. Clients can read the results with ReadMultiStatus.
+type BatchHandler struct {
+	// Handler serves the requests contained within a batch.
+	Handler http.Handler
+
+	// MaxRequests is the maximum number of requests in a batch. If zero, DefaultMaxBatchSize is
+	// used.
+	MaxRequests int
+}
+
+// ServeHTTP serves the batch request r.
+func (bh *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var br BatchRequest
+	if err := ReadRequest(r, &br); err != nil {
+		_ = WriteRequestError(w, err)
+		return
+	}
+
+	limit := bh.MaxRequests
+	if limit <= 0 {
+		limit = DefaultMaxBatchSize
+	}
+	if len(br.Requests) > limit {
+		_ = WriteError(w, fmt.Sprintf("batch contains %v requests, limit is %v", len(br.Requests), limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var mr MultiResponse
+	for _, bi := range br.Requests {
+		mr.Results = append(mr.Results, bh.dispatch(r, bi))
+	}
+	_ = WriteMultiStatus(w, mr)
+}
+
+// dispatch serves the request bi, contained within the batch request r, and returns its result.
+func (bh *BatchHandler) dispatch(r *http.Request, bi BatchItem) ItemResult {
+	u, err := url.Parse(bi.Path)
+	if err != nil || u.Path == "" || u.Path[0] != '/' || u.Host != "" || u.Scheme != "" {
+		je := NewError(fmt.Sprintf("invalid batch request path %q", bi.Path), http.StatusBadRequest)
+		return ItemResult{ID: bi.ID, Code: je.Code, Error: je}
+	}
+
+	method := bi.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	sub, err := http.NewRequestWithContext(r.Context(), method, u.String(), bytes.NewReader(bi.Body))
+	if err != nil {
+		je := NewError(fmt.Sprintf("invalid batch request: %v", err), http.StatusBadRequest)
+		return ItemResult{ID: bi.ID, Code: je.Code, Error: je}
+	}
+	// Headers describing the body or representation of the batch request do not apply to the
+	// requests it contains.
+	sub.Header = r.Header.Clone()
+	for _, k := range []string{
+		"Accept-Encoding", "Content-Encoding", "Content-Length",
+		"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since",
+	} {
+		sub.Header.Del(k)
+	}
+	if len(bi.Body) > 0 {
+		sub.Header.Set("Content-Type", "application/json")
+		sub.Header.Set("Content-Length", strconv.Itoa(len(bi.Body)))
+	} else {
+		sub.Header.Del("Content-Type")
+		sub.Body = http.NoBody
+	}
+	sub.Host = r.Host
+	sub.RemoteAddr = r.RemoteAddr
+
+	rec := &batchRecorder{header: make(http.Header)}
+	bh.Handler.ServeHTTP(rec, sub)
+	return rec.result(bi.ID)
+}
+
+// batchRecorder is an http.ResponseWriter that records the response to a request contained within
+// a batch.
+type batchRecorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (rec *batchRecorder) Header() http.Header { return rec.header }
+
+func (rec *batchRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+}
+
+func (rec *batchRecorder) Write(p []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(p)
+}
+
+// result returns the recorded response as the result of the request identified by id.
+func (rec *batchRecorder) result(id string) ItemResult {
+	ir := ItemResult{ID: id, Code: rec.code}
+	if ir.Code == 0 {
+		ir.Code = http.StatusOK
+	}
+	if rec.body.Len() == 0 {
+		return ir
+	}
+
+	jr, err := DecodeResponse(bytes.NewReader(rec.body.Bytes()))
+	if err != nil {
+		// Preserve the status of unsuccessful responses that are not in the expected format,
+		// such as those written by http.Error.
+		if ir.Code < http.StatusBadRequest {
+			ir.Code = http.StatusInternalServerError
+			ir.Error = NewError("batch request produced an invalid response", ir.Code)
+			return ir
+		}
+		s := &snippet{max: maxSnippetSize}
+		_, _ = s.Write(rec.body.Bytes())
+		ir.Error = NewError(s.String(), ir.Code)
+		return ir
+	}
+	ir.Data = jr.Data
+	ir.Error = jr.Error
+	return ir
+}
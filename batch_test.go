@@ -0,0 +1,168 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newBatchTestHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/things/1", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_ = WriteResponse(w, map[string]string{"name": "one", "auth": r.Header.Get("Authorization")}, http.StatusOK)
+		case http.MethodPut:
+			var v struct{ Name string }
+			if err := ReadRequest(r, &v); err != nil {
+				_ = WriteRequestError(w, err)
+				return
+			}
+			_ = WriteResponse(w, map[string]string{"name": v.Name}, http.StatusOK, WithCompression(r))
+		case http.MethodDelete:
+			WriteNoContent(w)
+		}
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	})
+	return mux
+}
+
+func TestBatchHandler(t *testing.T) {
+	var br BatchRequest
+	if err := br.Add("get", http.MethodGet, "/things/1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Add("put", http.MethodPut, "/things/1", map[string]string{"name": "uno"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Add("invalid", http.MethodPut, "/things/1", "blah"); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Add("delete", http.MethodDelete, "/things/1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Add("missing", "", "/things/2", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Add("text", "", "/text", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.Add("absolute", "", "http://example.com/things/1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer token")
+	r.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	bh := &BatchHandler{Handler: newBatchTestHandler()}
+	bh.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusMultiStatus; got != want {
+		t.Fatalf("got code %v, want %v", got, want)
+	}
+
+	mr, err := ReadMultiStatus(rr.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+
+	tests := []struct {
+		id       string
+		wantCode int
+		wantData map[string]string
+	}{
+		{"get", http.StatusOK, map[string]string{"name": "one", "auth": "Bearer token"}},
+		{"put", http.StatusOK, map[string]string{"name": "uno"}},
+		{"invalid", http.StatusBadRequest, nil},
+		{"delete", http.StatusNoContent, nil},
+		{"missing", http.StatusNotFound, nil},
+		{"text", http.StatusInternalServerError, nil},
+		{"absolute", http.StatusBadRequest, nil},
+	}
+	if got, want := len(mr.Results), len(tests); got != want {
+		t.Fatalf("got %v results, want %v", got, want)
+	}
+	for i, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			ir := mr.Results[i]
+			if got, want := ir.ID, tt.id; got != want {
+				t.Errorf("got ID %q, want %q", got, want)
+			}
+			if got, want := ir.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+
+			var m map[string]string
+			err := ir.Decode(&m)
+			if tt.wantCode >= http.StatusBadRequest {
+				if !errors.Is(err, &Error{Code: tt.wantCode}) {
+					t.Errorf("got error %v, want code %v", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to decode result: %v", err)
+			}
+			for k, v := range tt.wantData {
+				if got := m[k]; got != v {
+					t.Errorf("got %v %q, want %q", k, got, v)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchHandlerLimit(t *testing.T) {
+	var br BatchRequest
+	for i := 0; i < 3; i++ {
+		if err := br.Add("", http.MethodGet, "/things/1", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := json.Marshal(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewReader(b))
+	r.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	bh := &BatchHandler{Handler: newBatchTestHandler(), MaxRequests: 2}
+	bh.ServeHTTP(rr, r)
+
+	if err := ReadError(rr.Body); !errors.Is(err, &Error{Code: http.StatusRequestEntityTooLarge}) {
+		t.Errorf("got error %v, want code %v", err, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestBatchHandlerInvalid(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewReader([]byte(`{"requests":`)))
+	r.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+
+	bh := &BatchHandler{Handler: newBatchTestHandler()}
+	bh.ServeHTTP(rr, r)
+
+	if got, want := rr.Code, http.StatusBadRequest; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// WithCanonical causes responses to be written as canonical JSON, in the manner of RFC 8785, so
+// that equal responses are byte-for-byte identical. This is useful when responses are signed,
+// hashed or compared against golden files. Object members are sorted by key, insignificant
+// whitespace is omitted, and strings are escaped minimally. Numbers with a fraction or exponent
+// are written in their shortest form, while integers are written exactly, without loss of
+// precision.
+//
+// Canonical responses are buffered even when WithStream is used, and WithIndent has no effect.
+func WithCanonical() Option {
+	return func(o *options) {
+		o.canonical = true
+	}
+}
+
+// canonicalJSON returns the canonical form of the JSON document b.
+func canonicalJSON(b []byte) ([]byte, error) {
+	v, err := parseJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := v.appendCanonical(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendCanonical appends the canonical JSON encoding of v to buf.
+func (v jsonValue) appendCanonical(buf *bytes.Buffer) error {
+	switch v.kind {
+	case 'd':
+		s, err := canonicalNumber(v.s)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case 's':
+		return appendCanonicalString(buf, v.s)
+	case '[':
+		buf.WriteByte('[')
+		for i, e := range v.elems {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := e.appendCanonical(buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case '{':
+		order := make([]int, len(v.keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return lessUTF16(v.keys[order[i]], v.keys[order[j]])
+		})
+
+		buf.WriteByte('{')
+		for n, i := range order {
+			if n > 0 {
+				buf.WriteByte(',')
+			}
+			if err := appendCanonicalString(buf, v.keys[i]); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := v.elems[i].appendCanonical(buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return v.appendJSON(buf)
+	}
+	return nil
+}
+
+// appendCanonicalString appends the JSON encoding of s to buf, without escaping HTML characters.
+func appendCanonicalString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	// Unlike json.Marshal, json.Encoder terminates each value with a newline.
+	buf.Truncate(buf.Len() - 1)
+	return nil
+}
+
+// lessUTF16 reports whether a sorts before b when compared as sequences of UTF-16 code units, as
+// required by RFC 8785.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// canonicalNumber returns the canonical form of the JSON number s. Integers are retained exactly,
+// other than the sign of zero. Other numbers are formatted as by ECMAScript, as required by RFC
+// 8785.
+func canonicalNumber(s string) (string, error) {
+	if !strings.ContainsAny(s, ".eE") {
+		if strings.TrimLeft(s, "-0") == "" {
+			return "0", nil
+		}
+		return s, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", err
+	}
+	if f == 0 {
+		return "0", nil
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// ECMAScript does not pad the exponent with leading zeros.
+	m, e, _ := strings.Cut(strconv.FormatFloat(f, 'e', -1, 64), "e")
+	sign, digits := e[:1], strings.TrimLeft(e[1:], "0")
+	return m + "e" + sign + digits, nil
+}
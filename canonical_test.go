@@ -0,0 +1,77 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"Literals", `[null, true, false]`, `[null,true,false]`},
+		{"SortedKeys", `{"b": 1, "a": {"d": 2, "c": 3}}`, `{"a":{"c":3,"d":2},"b":1}`},
+		{"UTF16Order", `{"\uff5e": 1, "😀": 2, "a": 3}`, `{"a":3,"😀":2,"～":1}`},
+		{"Integer", `[0, -0, 42, -7, 123456789012345678901234567890]`, `[0,0,42,-7,123456789012345678901234567890]`},
+		{"Fraction", `[1.0, 1.50, -0.0, 0.000001, 0.0000001, 1e2, 1E-2]`, `[1,1.5,0,0.000001,1e-7,100,0.01]`},
+		{"Exponent", `[1e21, 1.5e+30, 123e-20]`, `[1e+21,1.5e+30,1.23e-18]`},
+		{"Strings", `"<a & b>é\n"`, `"<a & b>é\n"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := canonicalJSON([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("failed to canonicalize: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCanonical(t *testing.T) {
+	type thing struct {
+		Name  string  `json:"name"`
+		Count float64 `json:"count"`
+		ID    int     `json:"id"`
+	}
+	data := map[string]interface{}{
+		"things": []thing{{"<b>", 2.50, 1}},
+		"a":      json.RawMessage(`{"z":1,"y":2.0}`),
+	}
+	want := `{"data":{"a":{"y":2,"z":1},"things":[{"count":2.5,"id":1,"name":"<b>"}]},"meta":{"k":"v"}}`
+
+	for _, opts := range [][]Option{
+		{WithCanonical()},
+		{WithCanonical(), WithIndent("", "  ")},
+		{WithCanonical(), WithStream()},
+		{WithCanonical(), WithEncoder(json.Marshal)},
+	} {
+		rr := httptest.NewRecorder()
+		if err := WriteResponse(rr, data, http.StatusOK, append(opts, WithMeta("k", "v"))...); err != nil {
+			t.Fatalf("failed to write response: %v", err)
+		}
+		if got := rr.Body.String(); got != want {
+			t.Errorf("got body %v, want %v", got, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeResponse(&buf, Response{Data: data, Meta: map[string]interface{}{"k": "v"}}, WithCanonical()); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got encoded %v, want %v", got, want)
+	}
+}
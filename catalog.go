@@ -0,0 +1,162 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrorDef defines an application error, identified by its application error code, in a Catalog.
+//
+// An ErrorDef may be used as the target of errors.Is, which reports whether an error is an Error
+// with the same application error code, such as one returned by New, or read from a response.
+type ErrorDef struct {
+	// AppCode is the application error code, which identifies the error.
+	AppCode string `json:"appCode"`
+
+	// Code is the HTTP status code with which the error is written.
+	Code int `json:"code"`
+
+	// Message is the message of the error, which may be a template with verbs, in the same way as
+	// fmt.Sprintf, that are replaced by the arguments supplied to New.
+	Message string `json:"message"`
+
+	// Args names the arguments of Message, for documentation.
+	Args []string `json:"args,omitempty"`
+
+	// MessageKey, if not empty, identifies a localizable message template, used by
+	// WriteLocalizedErr in place of Message.
+	MessageKey string `json:"messageKey,omitempty"`
+
+	// Description documents the circumstances in which the error occurs.
+	Description string `json:"description,omitempty"`
+}
+
+// Error returns the application error code and message template of d, so that an ErrorDef may be
+// used as a sentinel error. Unless registered with Register, WriteErr writes it as the Error
+// returned by New.
+func (d *ErrorDef) Error() string {
+	return d.AppCode + ": " + d.Message
+}
+
+// New returns an Error defined by d, with a message formatted from the template of d and args.
+func (d *ErrorDef) New(args ...interface{}) *Error {
+	je := &Error{
+		Code:       d.Code,
+		AppCode:    d.AppCode,
+		Message:    d.Message,
+		MessageKey: d.MessageKey,
+	}
+	if len(args) > 0 {
+		je.Message = fmt.Sprintf(d.Message, args...)
+		je.MessageArgs = args
+	}
+	return je
+}
+
+// NewWithDetails returns an Error defined by d, in the same way as New, with the supplied
+// machine-readable details.
+func (d *ErrorDef) NewWithDetails(details map[string]interface{}, args ...interface{}) *Error {
+	je := d.New(args...)
+	je.Details = details
+	return je
+}
+
+// Catalog declares the application errors of an API in one place, so that they can be documented
+// from the same definitions with which they are written. Catalogs are safe for concurrent use,
+// although errors are typically defined during initialization, as package-level variables:
+//
+//	var (
+//		catalog = jsonresp.NewCatalog()
+//
+//		ErrWidgetNotFound = catalog.Define(jsonresp.ErrorDef{
+//			AppCode: "widget_not_found",
+//			Code:    http.StatusNotFound,
+//			Message: "widget %q not found",
+//			Args:    []string{"name"},
+//		})
+//	)
+//
+// Errors are then written with WriteErr(w, ErrWidgetNotFound.New(name)), and identified using
+// errors.Is(err, ErrWidgetNotFound).
+type Catalog struct {
+	mu     sync.RWMutex
+	defs   []*ErrorDef
+	byCode map[string]*ErrorDef
+}
+
+// NewCatalog returns an empty Catalog.
+func NewCatalog() *Catalog {
+	return &Catalog{byCode: make(map[string]*ErrorDef)}
+}
+
+// Define adds the application error d to c, and returns its definition. If d has no status code,
+// 500 is used. Define panics if d has no application error code, or an error with the same code
+// has already been defined.
+func (c *Catalog) Define(d ErrorDef) *ErrorDef {
+	if d.AppCode == "" {
+		panic("jsonresp: application error code must not be empty")
+	}
+	if d.Code == 0 {
+		d.Code = http.StatusInternalServerError
+	}
+	d.Args = append([]string(nil), d.Args...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.byCode[d.AppCode]; ok {
+		panic(fmt.Sprintf("jsonresp: application error code %q already defined", d.AppCode))
+	}
+	def := &d
+	c.defs = append(c.defs, def)
+	c.byCode[d.AppCode] = def
+	return def
+}
+
+// Lookup returns the definition of the application error with code appCode, if any.
+func (c *Catalog) Lookup(appCode string) (*ErrorDef, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	d, ok := c.byCode[appCode]
+	return d, ok
+}
+
+// Defs returns the definitions of the application errors of c, in the order they were defined.
+func (c *Catalog) Defs() []*ErrorDef {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return append([]*ErrorDef(nil), c.defs...)
+}
+
+// MarshalJSON returns the JSON encoding of the definitions of c, for documentation.
+func (c *Catalog) MarshalJSON() ([]byte, error) {
+	defs := c.Defs()
+	if defs == nil {
+		defs = []*ErrorDef{}
+	}
+	return json.Marshal(struct {
+		Errors []*ErrorDef `json:"errors"`
+	}{defs})
+}
+
+// WriteJSON writes the indented JSON encoding of the definitions of c to w, for documentation.
+func (c *Catalog) WriteJSON(w io.Writer) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to encode catalog: %v", err)
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("jsonresp: failed to write catalog: %w", err)
+	}
+	return nil
+}
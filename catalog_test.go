@@ -0,0 +1,206 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	c := NewCatalog()
+
+	notFound := c.Define(ErrorDef{
+		AppCode:     "not_found",
+		Code:        http.StatusNotFound,
+		Message:     "widget %q not found",
+		Args:        []string{"name"},
+		MessageKey:  "errors.notFound",
+		Description: "The widget does not exist.",
+	})
+	internal := c.Define(ErrorDef{
+		AppCode: "internal",
+		Message: "internal error",
+	})
+
+	tests := []struct {
+		name        string
+		err         *Error
+		wantCode    int
+		wantAppCode string
+		wantMessage string
+		wantArgs    []interface{}
+		wantDetails map[string]interface{}
+	}{
+		{"New", notFound.New("blah"), http.StatusNotFound, "not_found", `widget "blah" not found`, []interface{}{"blah"}, nil},
+		{"NoArgs", internal.New(), http.StatusInternalServerError, "internal", "internal error", nil, nil},
+		{"Details", notFound.NewWithDetails(map[string]interface{}{"a": "b"}, "blah"), http.StatusNotFound, "not_found", `widget "blah" not found`, []interface{}{"blah"}, map[string]interface{}{"a": "b"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := tt.err.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := tt.err.AppCode, tt.wantAppCode; got != want {
+				t.Errorf("got app code %v, want %v", got, want)
+			}
+			if got, want := tt.err.Message, tt.wantMessage; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+			if got, want := tt.err.MessageArgs, tt.wantArgs; !reflect.DeepEqual(got, want) {
+				t.Errorf("got args %v, want %v", got, want)
+			}
+			if got, want := tt.err.Details, tt.wantDetails; !reflect.DeepEqual(got, want) {
+				t.Errorf("got details %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("Lookup", func(t *testing.T) {
+		if d, ok := c.Lookup("not_found"); !ok || d != notFound {
+			t.Errorf("got %v, want %v", d, notFound)
+		}
+		if _, ok := c.Lookup("blah"); ok {
+			t.Errorf("got definition of unknown code")
+		}
+	})
+
+	t.Run("Defs", func(t *testing.T) {
+		if got, want := c.Defs(), []*ErrorDef{notFound, internal}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestErrorDefIs(t *testing.T) {
+	c := NewCatalog()
+	notFound := c.Define(ErrorDef{AppCode: "not_found", Code: http.StatusNotFound, Message: "not found"})
+	conflict := c.Define(ErrorDef{AppCode: "conflict", Code: http.StatusConflict, Message: "conflict"})
+
+	rr := httptest.NewRecorder()
+	if err := WriteErr(rr, fmt.Errorf("wrapped: %w", notFound.New())); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Code, http.StatusNotFound; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+
+	// The error read from the response matches its definition.
+	err := ReadError(rr.Body)
+	if !errors.Is(err, notFound) {
+		t.Errorf("got error %v, want %v", err, notFound)
+	}
+	if errors.Is(err, conflict) {
+		t.Errorf("got error %v, want not %v", err, conflict)
+	}
+}
+
+func TestCatalogDefinePanics(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []ErrorDef
+	}{
+		{"NoAppCode", []ErrorDef{{Message: "blah"}}},
+		{"Duplicate", []ErrorDef{{AppCode: "a"}, {AppCode: "a"}}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("got no panic")
+				}
+			}()
+
+			c := NewCatalog()
+			for _, d := range tt.defs {
+				c.Define(d)
+			}
+		})
+	}
+}
+
+func TestCatalogWriteJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		defs []ErrorDef
+		want string
+	}{
+		{"Empty", nil, "{\n  \"errors\": []\n}\n"},
+		{"Defs", []ErrorDef{
+			{AppCode: "not_found", Code: http.StatusNotFound, Message: "widget %q not found", Args: []string{"name"}, Description: "blah"},
+			{AppCode: "internal"},
+		}, `{
+  "errors": [
+    {
+      "appCode": "not_found",
+      "code": 404,
+      "message": "widget %q not found",
+      "args": [
+        "name"
+      ],
+      "description": "blah"
+    },
+    {
+      "appCode": "internal",
+      "code": 500,
+      "message": ""
+    }
+  ]
+}
+`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCatalog()
+			for _, d := range tt.defs {
+				c.Define(d)
+			}
+
+			var buf bytes.Buffer
+			if err := c.WriteJSON(&buf); err != nil {
+				t.Fatalf("failed to write catalog: %v", err)
+			}
+			if got, want := buf.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteErrErrorDef(t *testing.T) {
+	c := NewCatalog()
+	notFound := c.Define(ErrorDef{AppCode: "not_found", Code: http.StatusNotFound, Message: "not found"})
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"Def", notFound},
+		{"Wrapped", fmt.Errorf("wrapped: %w", notFound)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteErr(rr, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+			if got, want := rr.Body.String(), `{"error":{"code":404,"appCode":"not_found","message":"not found"}}`; got != want {
+				t.Errorf("got body %v, want %v", got, want)
+			}
+		})
+	}
+}
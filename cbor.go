@@ -0,0 +1,331 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// CBOR is the Concise Binary Object Representation wire format (application/cbor), as specified
+// in RFC 8949. Byte strings and tags are not produced when writing. When reading, byte strings are
+// converted to base64-encoded strings, consistent with the JSON encoding of []byte, tags are
+// ignored in favour of the tagged content, and undefined is treated as null.
+var CBOR Format = cborFormat{}
+
+type cborFormat struct{}
+
+func (cborFormat) ContentType() string { return "application/cbor" }
+
+func (cborFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := appendCBOR(&buf, v); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (cborFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := cborDecoder{byteDecoder{b: b}}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	if d.off != len(b) {
+		return nil, errors.New("cbor: unexpected data after top-level value")
+	}
+
+	var buf bytes.Buffer
+	if err := v.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CBOR major types.
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// appendCBORHead appends the head of a data item with major type major and argument n to buf.
+func appendCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	m := major << 5
+	switch {
+	case n < 24:
+		buf.WriteByte(m | byte(n))
+	case n <= math.MaxUint8:
+		buf.Write([]byte{m | 24, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(m | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(m | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(m | 27)
+		_ = binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func appendCBORNumber(buf *bytes.Buffer, s string) error {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if i >= 0 {
+			appendCBORHead(buf, cborUint, uint64(i))
+		} else {
+			appendCBORHead(buf, cborNegInt, uint64(-(i + 1)))
+		}
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		appendCBORHead(buf, cborUint, u)
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	buf.WriteByte(cborSimple<<5 | 27)
+	_ = binary.Write(buf, binary.BigEndian, f)
+	return nil
+}
+
+// appendCBOR appends the CBOR encoding of v to buf.
+func appendCBOR(buf *bytes.Buffer, v jsonValue) error {
+	switch v.kind {
+	case 'n':
+		buf.WriteByte(0xf6)
+	case 'b':
+		if v.b {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case 'd':
+		return appendCBORNumber(buf, v.s)
+	case 's':
+		appendCBORHead(buf, cborText, uint64(len(v.s)))
+		buf.WriteString(v.s)
+	case '[':
+		appendCBORHead(buf, cborArray, uint64(len(v.elems)))
+		for _, e := range v.elems {
+			if err := appendCBOR(buf, e); err != nil {
+				return err
+			}
+		}
+	case '{':
+		appendCBORHead(buf, cborMap, uint64(len(v.elems)))
+		for i, e := range v.elems {
+			appendCBORHead(buf, cborText, uint64(len(v.keys[i])))
+			buf.WriteString(v.keys[i])
+			if err := appendCBOR(buf, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// cborDecoder decodes CBOR data items from a byte slice.
+type cborDecoder struct {
+	byteDecoder
+}
+
+// errBreak is returned by value when a break stop code is encountered.
+var errBreak = errors.New("unexpected break")
+
+// indefinite is the additional information value indicating an indefinite length.
+const indefinite = 31
+
+// head reads the head of a data item, returning its major type, additional information and
+// argument.
+func (d *cborDecoder) head() (major, info byte, arg uint64, err error) {
+	b, err := d.next(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		arg, err = d.uint(1 << (info - 24))
+		return major, info, arg, err
+	case info == indefinite:
+		return major, info, 0, nil
+	}
+	return 0, 0, 0, fmt.Errorf("reserved additional information value %v", info)
+}
+
+// chunks reads the content of a byte or text string of major type major.
+func (d *cborDecoder) chunks(major, info byte, arg uint64) ([]byte, error) {
+	if info != indefinite {
+		n, err := d.checkLength(arg)
+		if err != nil {
+			return nil, err
+		}
+		return d.next(n)
+	}
+
+	var b []byte
+	for {
+		m, i, a, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == cborSimple && i == indefinite {
+			return b, nil
+		}
+		if m != major || i == indefinite {
+			return nil, errors.New("invalid indefinite-length string chunk")
+		}
+		c, err := d.chunks(m, i, a)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, c...)
+	}
+}
+
+// elems reads the elements of an array or, if object is true, the members of a map.
+func (d *cborDecoder) elems(info byte, arg uint64, object bool, depth int) (jsonValue, error) {
+	v := jsonValue{kind: '['}
+	if object {
+		v.kind = '{'
+	}
+
+	for i := uint64(0); info == indefinite || i < arg; i++ {
+		e, err := d.value(depth + 1)
+		if errors.Is(err, errBreak) && info == indefinite {
+			return v, nil
+		}
+		if err != nil {
+			return jsonValue{}, err
+		}
+
+		if object {
+			if e.kind != 's' && e.kind != 'd' {
+				return jsonValue{}, errors.New("unsupported map key type")
+			}
+			v.keys = append(v.keys, e.s)
+			if e, err = d.value(depth + 1); err != nil {
+				return jsonValue{}, err
+			}
+		}
+		v.elems = append(v.elems, e)
+	}
+	return v, nil
+}
+
+// simple decodes a simple value or floating-point number.
+func (d *cborDecoder) simple(info byte, arg uint64) (jsonValue, error) {
+	switch info {
+	case 20, 21:
+		return jsonValue{kind: 'b', b: info == 21}, nil
+	case 22, 23:
+		return jsonValue{kind: 'n'}, nil
+	case 25:
+		return floatValue(halfToFloat(uint16(arg)))
+	case 26:
+		return floatValue(float64(math.Float32frombits(uint32(arg))))
+	case 27:
+		return floatValue(math.Float64frombits(arg))
+	case indefinite:
+		return jsonValue{}, errBreak
+	}
+	return jsonValue{}, fmt.Errorf("unsupported simple value %v", arg)
+}
+
+// value decodes the next data item.
+func (d *cborDecoder) value(depth int) (jsonValue, error) {
+	if depth > maxFormatDepth {
+		return jsonValue{}, errMaxDepth
+	}
+
+	major, info, arg, err := d.head()
+	if err != nil {
+		return jsonValue{}, err
+	}
+	if info == indefinite && (major == cborUint || major == cborNegInt || major == cborTag) {
+		return jsonValue{}, fmt.Errorf("invalid indefinite length for major type %v", major)
+	}
+
+	switch major {
+	case cborUint:
+		return jsonValue{kind: 'd', s: strconv.FormatUint(arg, 10)}, nil
+	case cborNegInt:
+		n := new(big.Int).SetUint64(arg)
+		return jsonValue{kind: 'd', s: n.Neg(n.Add(n, big.NewInt(1))).String()}, nil
+	case cborBytes:
+		b, err := d.chunks(major, info, arg)
+		if err != nil {
+			return jsonValue{}, err
+		}
+		return bytesValue(b), nil
+	case cborText:
+		b, err := d.chunks(major, info, arg)
+		if err != nil {
+			return jsonValue{}, err
+		}
+		return jsonValue{kind: 's', s: string(b)}, nil
+	case cborArray, cborMap:
+		return d.elems(info, arg, major == cborMap, depth)
+	case cborTag:
+		return d.value(depth + 1)
+	}
+	return d.simple(info, arg)
+}
+
+// halfToFloat converts the IEEE 754 half-precision value h to a float64.
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
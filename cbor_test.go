@@ -0,0 +1,147 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test vectors are taken from Appendix A of RFC 8949 where applicable.
+func TestCBOR(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		cbor     string
+		wantJSON string
+	}{
+		{"Null", `null`, "f6", `null`},
+		{"True", `true`, "f5", `true`},
+		{"False", `false`, "f4", `false`},
+		{"Zero", `0`, "00", `0`},
+		{"Uint", `23`, "17", `23`},
+		{"Uint8", `24`, "1818", `24`},
+		{"Uint16", `1000`, "1903e8", `1000`},
+		{"Uint32", `1000000`, "1a000f4240", `1000000`},
+		{"Uint64", `18446744073709551615`, "1bffffffffffffffff", `18446744073709551615`},
+		{"NegInt", `-1`, "20", `-1`},
+		{"NegInt16", `-1000`, "3903e7", `-1000`},
+		{"Float", `1.1`, "fb3ff199999999999a", `1.1`},
+		{"Text", `"IETF"`, "6449455446", `"IETF"`},
+		{"Array", `[1,[2,3],[4,5]]`, "8301820203820405", `[1,[2,3],[4,5]]`},
+		{"Map", `{"a":1,"b":[2,3]}`, "a26161016162820203", `{"a":1,"b":[2,3]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := CBOR.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := hex.EncodeToString(buf.Bytes()), tt.cbor; got != want {
+				t.Errorf("got CBOR %v, want %v", got, want)
+			}
+
+			b, err := CBOR.ToJSON(&buf)
+			if err != nil {
+				t.Fatalf("failed to convert to JSON: %v", err)
+			}
+			if got, want := string(b), tt.wantJSON; got != want {
+				t.Errorf("got JSON %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCBORToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		cbor     string
+		wantJSON string
+		wantErr  bool
+	}{
+		{"NegIntMin", "3bffffffffffffffff", `-18446744073709551616`, false},
+		{"Half", "f93e00", `1.5`, false},
+		{"HalfSubnormal", "f90001", `5.960464477539063e-08`, false},
+		{"HalfNegative", "f9c400", `-4`, false},
+		{"Single", "fa47c35000", `100000`, false},
+		{"Undefined", "f7", `null`, false},
+		{"Bytes", "4401020304", `"AQIDBA=="`, false},
+		{"Tag", "c074323031332d30332d32315432303a30343a30305a", `"2013-03-21T20:04:00Z"`, false},
+		{"IndefiniteText", "7f657374726561646d696e67ff", `"streaming"`, false},
+		{"IndefiniteBytes", "5f42010243030405ff", `"AQIDBAU="`, false},
+		{"IndefiniteArray", "9f018202039f0405ffff", `[1,[2,3],[4,5]]`, false},
+		{"IndefiniteMap", "bf61610161629f0203ffff", `{"a":1,"b":[2,3]}`, false},
+		{"IntegerKey", "a10102", `{"1":2}`, false},
+		{"Empty", "", ``, true},
+		{"Truncated", "64494554", ``, true},
+		{"LengthTooLarge", "9bffffffffffffffff", ``, true},
+		{"Break", "ff", ``, true},
+		{"Reserved", "1c", ``, true},
+		{"IndefiniteUint", "1f", ``, true},
+		{"InvalidChunk", "7f4161ff", ``, true},
+		{"HalfInfinity", "f97c00", ``, true},
+		{"InvalidKey", "a1f601", ``, true},
+		{"TrailingData", "f6f6", ``, true},
+		{"TooDeep", strings.Repeat("81", maxFormatDepth+2) + "f6", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := hex.DecodeString(tt.cbor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := CBOR.ToJSON(bytes.NewReader(in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.wantJSON; got != want {
+				t.Errorf("got JSON %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithFormatCBOR(t *testing.T) {
+	type TestStruct struct {
+		Value string
+		Count int
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, TestStruct{"blah", -42}, &PageDetails{Prev: "p"}, http.StatusOK, WithFormat(CBOR)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/cbor"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	var ts TestStruct
+	pd, err := ReadResponsePage(rr.Body, &ts, WithFormat(CBOR))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if got, want := ts, (TestStruct{"blah", -42}); got != want {
+		t.Errorf("got data %+v, want %+v", got, want)
+	}
+	if got, want := pd, (&PageDetails{Prev: "p"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %+v, want %+v", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusConflict, WithFormat(CBOR)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := ReadError(rr.Body, WithFormat(CBOR)), (&Error{Code: http.StatusConflict, Message: "blah"}); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// NDJSONContentType is the media type of responses written by WriteResponseStream with WithNDJSON.
+const NDJSONContentType = "application/x-ndjson"
+
+// WarningStreamCanceled is the code of the warning added to a response written by
+// WriteResponseStream when its context is done before the items are exhausted.
+const WarningStreamCanceled = "stream_canceled"
+
+// WithNDJSON causes WriteResponseStream to write each item on its own line, as newline-delimited
+// JSON, rather than as elements of the data of a response envelope.
+func WithNDJSON() Option {
+	return func(o *options) {
+		o.ndjson = true
+	}
+}
+
+// WriteResponseStream writes a 200 status code and JSON response to w whose data is an array of the
+// items received from items, encoding each item as it is received, so that the producer need not
+// buffer the entire result set. If WithNDJSON is used, each item is instead written on its own
+// line, with a Content-Type of application/x-ndjson. The response is flushed to the client
+// whenever no item is ready, or after each item if WithFlush is used.
+//
+// The response is complete once items is closed. If ctx is done first, the array is closed, a
+// warning with code WarningStreamCanceled is added to the envelope, and an error wrapping the
+// context error is returned. As the status code is written before the items are received, an
+// error encoding an item, or writing the response, results in a truncated response, and is
+// returned. Either way, the caller should stop sending items once WriteResponseStream returns.
+//
+// Response hooks are called with the envelope before the items are received, so its data is nil.
+// Options that change the format of the response, such as WithFormat, have no effect.
+func WriteResponseStream[T any](ctx context.Context, w http.ResponseWriter, items <-chan T, opts ...Option) error {
+	o := newOptions(joinOptions(opts, []Option{withContext(ctx)}))
+	o.format = nil
+	if err := o.ctxErr(); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %w", err)
+	}
+
+	jr, err := o.prepareResponse(Response{})
+	if err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	var ce string
+	if o.compress {
+		addVary(h, "Accept-Encoding")
+		if ce = acceptedEncoding(o.acceptEncoding); ce != "" {
+			h.Set("Content-Encoding", ce)
+		}
+	}
+	if o.ndjson {
+		o.contentType = NDJSONContentType
+		o.prefix, o.indent = "", ""
+	}
+
+	const code = http.StatusOK
+	writeHeader(w, jr, code, o)
+	cw := &countingWriter{w: w}
+	defer func() { o.observeResponse(code, cw.n, jr) }()
+
+	var canceled bool
+	err = streamBody(cw, ce, func(bw io.Writer) error {
+		sw := &streamWriter{w: bw, o: o}
+		if o.maxResponseSize > 0 {
+			sw.w = &maxBytesWriter{w: bw, n: o.maxResponseSize}
+		}
+		flush := func() error { return flushWriter(bw, w) }
+		if o.flush {
+			sw.flush = flush
+		}
+
+		if !o.ndjson {
+			sw.writeString("{")
+			sw.writeKey("data")
+			sw.writeString("[")
+		}
+
+		n := 0
+		pending := false // whether items have been written since the last flush
+		for sw.err == nil {
+			// Cancellation is checked first, as items may always be ready.
+			if ctx.Err() != nil {
+				canceled = true
+				break
+			}
+
+			var item T
+			var ok bool
+			select {
+			case item, ok = <-items:
+			default:
+				if pending {
+					sw.err, pending = flush(), false
+					continue
+				}
+				select {
+				case item, ok = <-items:
+				case <-ctx.Done():
+					canceled = true
+				}
+			}
+			if canceled || !ok {
+				break
+			}
+
+			ir, err := o.transformData(Response{Data: item})
+			if err != nil {
+				sw.err = err
+				break
+			}
+			if o.ndjson {
+				sw.writeValue(ir.Data, 0)
+				sw.writeString("\n")
+			} else {
+				if n > 0 {
+					sw.writeString(",")
+				}
+				sw.writeNewline(2)
+				sw.writeValue(ir.Data, 2)
+			}
+			n++
+			pending = true
+			sw.flushElement()
+		}
+		if sw.err != nil || o.ndjson {
+			return sw.err
+		}
+
+		if n > 0 {
+			sw.writeNewline(1)
+		}
+		sw.writeString("]")
+		if canceled {
+			warning := Warning{Code: WarningStreamCanceled, Message: "response truncated: " + ctx.Err().Error()}
+			jr.Warnings = append(append([]Warning(nil), jr.Warnings...), warning)
+		}
+		if len(jr.Warnings) > 0 {
+			sw.writeKey("warnings")
+			sw.writeValue(jr.Warnings, 1)
+		}
+		if len(jr.Meta) > 0 {
+			sw.writeKey("meta")
+			sw.writeValue(jr.Meta, 1)
+		}
+		if len(jr.Links) > 0 {
+			sw.writeKey("links")
+			sw.writeValue(jr.Links, 1)
+		}
+		sw.writeNewline(0)
+		sw.writeString("}")
+		return sw.err
+	})
+	if err != nil {
+		return err
+	}
+	if canceled {
+		return fmt.Errorf("jsonresp: failed to stream response: %w", ctx.Err())
+	}
+	return nil
+}
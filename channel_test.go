@@ -0,0 +1,216 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sendAll returns a closed channel from which vs are received.
+func sendAll[T any](vs ...T) <-chan T {
+	c := make(chan T, len(vs))
+	for _, v := range vs {
+		c <- v
+	}
+	close(c)
+	return c
+}
+
+func TestWriteResponseStream(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Token string `json:"token" jsonresp:"secret"`
+	}
+
+	tests := []struct {
+		name     string
+		items    <-chan item
+		opts     []Option
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "Empty",
+			items:    sendAll[item](),
+			wantType: "application/json",
+			wantBody: `{"data":[]}`,
+		},
+		{
+			name:     "Items",
+			items:    sendAll(item{Name: "a"}, item{Name: "b", Token: "abc"}),
+			wantType: "application/json",
+			wantBody: `{"data":[{"name":"a","token":"[REDACTED]"},{"name":"b","token":"[REDACTED]"}]}`,
+		},
+		{
+			name:     "Meta",
+			items:    sendAll(item{Name: "a"}),
+			opts:     []Option{WithMeta("version", 1), WithWarning("partial", "blah")},
+			wantType: "application/json",
+			wantBody: `{"data":[{"name":"a","token":"[REDACTED]"}],"warnings":[{"code":"partial","message":"blah"}],"meta":{"version":1}}`,
+		},
+		{
+			name:     "Indent",
+			items:    sendAll(item{Name: "a"}, item{Name: "b"}),
+			opts:     []Option{WithIndent("", " "), WithFlush()},
+			wantType: "application/json",
+			wantBody: "{\n \"data\": [\n  {\n   \"name\": \"a\",\n   \"token\": \"[REDACTED]\"\n  },\n  {\n   \"name\": \"b\",\n   \"token\": \"[REDACTED]\"\n  }\n ]\n}",
+		},
+		{
+			name:     "NDJSON",
+			items:    sendAll(item{Name: "a"}, item{Name: "b"}),
+			opts:     []Option{WithNDJSON(), WithIndent("", " ")},
+			wantType: "application/x-ndjson",
+			wantBody: "{\"name\":\"a\",\"token\":\"[REDACTED]\"}\n{\"name\":\"b\",\"token\":\"[REDACTED]\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponseStream(context.Background(), rr, tt.items, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, http.StatusOK; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteResponseStreamCanceled(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantBody string
+	}{
+		{"Envelope", nil, `{"data":[1,2],"warnings":[{"code":"stream_canceled","message":"response truncated: context canceled"}]}`},
+		{"NDJSON", []Option{WithNDJSON()}, "1\n2\n"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			items := make(chan int)
+			go func() {
+				items <- 1
+				items <- 2
+				cancel()
+			}()
+
+			rr := httptest.NewRecorder()
+			err := WriteResponseStream(ctx, rr, items, tt.opts...)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("got error %v, want %v", err, context.Canceled)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// flushNotifier is an http.ResponseWriter that signals flushed each time it is flushed.
+type flushNotifier struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func (w *flushNotifier) Flush() {
+	w.ResponseRecorder.Flush()
+	w.flushed <- struct{}{}
+}
+
+func TestWriteResponseStreamFlush(t *testing.T) {
+	w := &flushNotifier{httptest.NewRecorder(), make(chan struct{}, 1)}
+
+	items := make(chan int)
+	go func() {
+		items <- 1
+		<-w.flushed
+		items <- 2
+		<-w.flushed
+		close(items)
+	}()
+
+	if err := WriteResponseStream(context.Background(), w, items); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := w.Body.String(), `{"data":[1,2]}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestWriteResponseStreamDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponseStream(ctx, rr, sendAll(1)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if rr.Body.Len() != 0 {
+		t.Errorf("got body %q, want none", rr.Body.String())
+	}
+}
+
+func TestWriteResponseStreamEncodeError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteResponseStream(context.Background(), rr, sendAll[interface{}](1, make(chan int))); err == nil {
+		t.Error("unexpected success")
+	}
+	if got, want := rr.Body.String(), `{"data":[1,`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestWriteResponseStreamSharedOptions(t *testing.T) {
+	opts := make([]Option, 0, 2)
+	opts = append(opts, WithMeta("a", 1))
+
+	items := make(chan int)
+	close(items)
+	if err := WriteResponseStream(context.Background(), httptest.NewRecorder(), items, opts...); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got := opts[:cap(opts)][1]; got != nil {
+		t.Error("options modified")
+	}
+}
+
+func TestWriteResponseStreamCanceledReady(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Items are always ready, so cancellation must be noticed without waiting for them.
+	items := make(chan int, 100)
+	for i := 0; i < cap(items); i++ {
+		items <- i
+	}
+
+	w := &cancelWriter{ResponseRecorder: httptest.NewRecorder(), cancel: cancel, writes: 1}
+	err := WriteResponseStream(ctx, w, items, WithNDJSON(), WithFlush())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if got, want := w.Body.String(), "0\n"; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package chiresp adapts the jsonresp package to the chi router, so that requests chi cannot
+// route are answered with a jsonresp error rather than a plain text response.
+package chiresp
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// withRequest returns opts, followed by an option supplying r. The options are shared by the
+// requests served by a handler, so opts is not modified.
+func withRequest(r *http.Request, opts []jsonresp.Option) []jsonresp.Option {
+	return append(opts[:len(opts):len(opts)], jsonresp.WithRequest(r))
+}
+
+// NotFound returns a handler that writes a 404 error, for use with chi.Mux.NotFound.
+func NotFound(opts ...jsonresp.Option) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = jsonresp.WriteError(w, "resource not found", http.StatusNotFound, withRequest(r, opts)...)
+	}
+}
+
+// MethodNotAllowed returns a handler that writes a 405 error, for use with
+// chi.Mux.MethodNotAllowed.
+func MethodNotAllowed(opts ...jsonresp.Option) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = jsonresp.WriteError(w, "method not allowed", http.StatusMethodNotAllowed, withRequest(r, opts)...)
+	}
+}
+
+// Register sets the handlers r uses for requests that match no route, and for requests whose
+// method matches no route, to NotFound and MethodNotAllowed, which write with opts. Sub-routers
+// mounted on r inherit these handlers.
+func Register(r chi.Router, opts ...jsonresp.Option) {
+	r.NotFound(NotFound(opts...))
+	r.MethodNotAllowed(MethodNotAllowed(opts...))
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package chiresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+func TestRegister(t *testing.T) {
+	r := chi.NewRouter()
+	Register(r, jsonresp.WithContentType("application/vnd.example+json"))
+	r.Get("/items", func(w http.ResponseWriter, r *http.Request) {
+		_ = jsonresp.WriteResponse(w, "items", http.StatusOK, jsonresp.WithContentType("application/vnd.example+json"))
+	})
+	r.Route("/sub", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {})
+	})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+		wantErr  error
+	}{
+		{"Found", http.MethodGet, "/items", http.StatusOK, nil},
+		{"NotFound", http.MethodGet, "/other", http.StatusNotFound, &jsonresp.Error{Code: http.StatusNotFound, Message: "resource not found"}},
+		{"SubNotFound", http.MethodGet, "/sub/other", http.StatusNotFound, &jsonresp.Error{Code: http.StatusNotFound, Message: "resource not found"}},
+		{"MethodNotAllowed", http.MethodPost, "/items", http.StatusMethodNotAllowed, &jsonresp.Error{Code: http.StatusMethodNotAllowed, Message: "method not allowed"}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), "application/vnd.example+json"; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			if err := jsonresp.ReadError(rr.Body); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotFoundSharedOptions(t *testing.T) {
+	opts := make([]jsonresp.Option, 0, 4)
+	var got []*http.Request
+	opts = append(opts, jsonresp.WithResponseHook(func(r *http.Request, jr *jsonresp.Response) {
+		got = append(got, r)
+	}))
+	h := NotFound(opts...)
+
+	r1 := httptest.NewRequest(http.MethodGet, "/a", nil)
+	r2 := httptest.NewRequest(http.MethodGet, "/b", nil)
+	h(httptest.NewRecorder(), r1)
+	h(httptest.NewRecorder(), r2)
+
+	if len(got) != 2 || got[0] != r1 || got[1] != r2 {
+		t.Errorf("got requests %v, want %v", got, []*http.Request{r1, r2})
+	}
+	if got := opts[:cap(opts)][1]; got != nil {
+		t.Error("options modified")
+	}
+}
@@ -0,0 +1,10 @@
+module github.com/sylabs/json-resp/chiresp
+
+go 1.18
+
+require (
+	github.com/go-chi/chi/v5 v5.0.12
+	github.com/sylabs/json-resp v0.0.0
+)
+
+replace github.com/sylabs/json-resp => ../
@@ -0,0 +1,45 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import "fmt"
+
+var (
+	// ErrClientError matches any Error with a 4xx status code when used as the target of
+	// errors.Is.
+	ErrClientError error = CodeRange(400, 499)
+
+	// ErrServerError matches any Error with a 5xx status code when used as the target of
+	// errors.Is.
+	ErrServerError error = CodeRange(500, 599)
+)
+
+// codeRange is an error that matches Errors with status codes in an inclusive range.
+type codeRange struct {
+	min, max int
+}
+
+// CodeRange returns an error that, when used as the target of errors.Is, matches any Error with a
+// status code between min and max inclusive:
+//
+//	if errors.Is(err, jsonresp.CodeRange(500, 503)) {
+//		// Retry.
+//	}
+func CodeRange(min, max int) error {
+	return codeRange{min, max}
+}
+
+func (cr codeRange) Error() string {
+	if cr.min/100 == cr.max/100 && cr.min%100 == 0 && cr.max%100 == 99 {
+		return fmt.Sprintf("jsonresp: %vxx status", cr.min/100)
+	}
+	return fmt.Sprintf("jsonresp: status %v-%v", cr.min, cr.max)
+}
+
+// contains returns true if code is within cr.
+func (cr codeRange) contains(code int) bool {
+	return cr.min <= code && code <= cr.max
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCodeRange(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"ClientError", NewError("", http.StatusNotFound), ErrClientError, true},
+		{"ClientErrorMin", NewError("", 400), ErrClientError, true},
+		{"ClientErrorMax", NewError("", 499), ErrClientError, true},
+		{"ClientErrorServer", NewError("", http.StatusInternalServerError), ErrClientError, false},
+		{"ServerError", NewError("", http.StatusBadGateway), ErrServerError, true},
+		{"ServerErrorClient", NewError("", http.StatusConflict), ErrServerError, false},
+		{"Range", NewError("", http.StatusServiceUnavailable), CodeRange(502, 504), true},
+		{"RangeMismatch", NewError("", http.StatusInternalServerError), CodeRange(502, 504), false},
+		{"Wrapped", fmt.Errorf("request failed: %w", NewError("", http.StatusForbidden)), ErrClientError, true},
+		{"NotError", errors.New("blah"), ErrClientError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeRangeReadError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusServiceUnavailable); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	err := ReadError(bytes.NewReader(rr.Body.Bytes()))
+	if !errors.Is(err, ErrServerError) {
+		t.Errorf("got error %v, want server error", err)
+	}
+	if errors.Is(err, ErrClientError) {
+		t.Errorf("got error %v, want not client error", err)
+	}
+}
+
+func TestCodeRangeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"ClientError", ErrClientError, "jsonresp: 4xx status"},
+		{"ServerError", ErrServerError, "jsonresp: 5xx status"},
+		{"Range", CodeRange(502, 504), "jsonresp: status 502-504"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
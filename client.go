@@ -0,0 +1,172 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a number of seconds
+// or an HTTP date, relative to now.
+func parseRetryAfter(v string, now time.Time) (int, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		if s < 0 {
+			return 0, false
+		}
+		return s, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return int((d + time.Second - 1) / time.Second), true
+	}
+	return 0, true
+}
+
+// ReadHTTPError attempts to unmarshal JSON-encoded error details from the body of res. If the
+// error does not specify a retry hint, the Retry-After header of res is used to populate it. Like
+// ReadError, it returns nil if an error could not be parsed from the response, unless
+// WithFallbackError is used, in which case an Error with the status code of res is returned. Errors
+// in an envelope version registered by RegisterVersion are converted to the current envelope.
+func ReadHTTPError(res *http.Response, opts ...Option) error {
+	err := readError(res.Body, res.StatusCode, newOptions(withResponseVersion(res, opts)))
+
+	var je *Error
+	if errors.As(err, &je) && je.RetryAfter == 0 {
+		if s, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			je.RetryAfter = s
+		}
+	}
+	return err
+}
+
+// maxSnippetSize is the maximum number of bytes of a response body included in an error
+// synthesized due to WithFallbackError.
+const maxSnippetSize = 256
+
+// WithFallbackError causes ReadError and ReadHTTPError to return an Error rather than nil when an
+// error could not be parsed from the response, such as when a reverse proxy responds with an HTML
+// page. The message of the returned Error is the leading portion of the response body, and when
+// read with ReadHTTPError, its status code is that of the response.
+func WithFallbackError() Option {
+	return func(o *options) {
+		o.fallbackError = true
+	}
+}
+
+// snippet is an io.Writer that retains the first max bytes written to it.
+type snippet struct {
+	max       int
+	b         []byte
+	truncated bool
+}
+
+func (s *snippet) Write(p []byte) (int, error) {
+	if n := s.max - len(s.b); len(p) > n {
+		s.b = append(s.b, p[:n]...)
+		s.truncated = true
+	} else {
+		s.b = append(s.b, p...)
+	}
+	return len(p), nil
+}
+
+// String returns the retained bytes as a string, with surrounding whitespace removed and invalid
+// or truncated UTF-8 sequences replaced.
+func (s *snippet) String() string {
+	b := s.b
+	if s.truncated {
+		// Drop a UTF-8 sequence split at the point of truncation.
+		for i := 1; i < utf8.UTFMax; i++ {
+			if r, size := utf8.DecodeLastRune(b); r != utf8.RuneError || size != 1 {
+				break
+			}
+			b = b[:len(b)-1]
+		}
+	}
+
+	str := strings.TrimSpace(strings.ToValidUTF8(string(b), "\uFFFD"))
+	if s.truncated && str != "" {
+		str += "..."
+	}
+	return str
+}
+
+// maxDrainSize is the number of bytes of an unread response body that are discarded before it is
+// closed, so that the underlying connection can be reused.
+const maxDrainSize = 64 << 10
+
+// ReadHTTPResponse reads the paged response res, and unmarshals the supplied data. The body of res
+// is drained and closed before returning.
+//
+// If the status code of res is not 2xx, the error contained in the body is returned, in the same
+// way as ReadHTTPError. If the body does not contain an error, an Error with the status code of
+// res is returned. A successful response must have the expected Content-Type, and a 204 status
+// code is treated as a response without data. Responses in an envelope version registered by
+// RegisterVersion are converted to the current envelope. The accepted Content-Type header values
+// may be set using WithAllowedContentTypes.
+func ReadHTTPResponse(res *http.Response, v interface{}, opts ...Option) (*PageDetails, error) {
+	defer func() {
+		_, _ = io.CopyN(io.Discard, res.Body, maxDrainSize)
+		res.Body.Close()
+	}()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		if err := ReadHTTPError(res, opts...); err != nil {
+			return nil, err
+		}
+		return nil, NewError("", res.StatusCode)
+	}
+
+	if res.StatusCode == http.StatusNoContent {
+		return nil, nil //nolint:nilnil // a 204 response has no data or page details
+	}
+
+	opts = withResponseVersion(res, opts)
+
+	if o := newOptions(opts); !o.allowsContentType(res.Header.Get("Content-Type")) {
+		return nil, fmt.Errorf("jsonresp: unexpected content type %q, want %v", res.Header.Get("Content-Type"), o.wantContentType())
+	}
+
+	return ReadResponsePage(res.Body, v, opts...)
+}
+
+// sameMediaType reports whether the Content-Type header values a and b have the same media type,
+// ignoring parameters.
+func sameMediaType(a, b string) bool {
+	mta, _, err := mime.ParseMediaType(a)
+	if err != nil {
+		return false
+	}
+	mtb, _, err := mime.ParseMediaType(b)
+	if err != nil {
+		return false
+	}
+	return mta == mtb
+}
+
+// RetryAfter returns the retry hint carried by an Error in the chain of err, if present.
+func RetryAfter(err error) (time.Duration, bool) {
+	var je *Error
+	if !errors.As(err, &je) || je.RetryAfter <= 0 {
+		return 0, false
+	}
+	return time.Duration(je.RetryAfter) * time.Second, true
+}
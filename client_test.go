@@ -0,0 +1,262 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		v      string
+		want   int
+		wantOK bool
+	}{
+		{"Empty", "", 0, false},
+		{"Seconds", "120", 120, true},
+		{"NegativeSeconds", "-1", 0, false},
+		{"Date", now.Add(30 * time.Second).Format(http.TimeFormat), 30, true},
+		{"DatePast", now.Add(-30 * time.Second).Format(http.TimeFormat), 0, true},
+		{"Invalid", "soon", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.v, now)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadHTTPError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            *Error
+		header         string
+		wantHeader     string
+		wantRetryAfter time.Duration
+		wantOK         bool
+	}{
+		{"NoRetry", NewError("blah", http.StatusServiceUnavailable), "", "", 0, false},
+		{"Envelope", &Error{Code: http.StatusTooManyRequests, RetryAfter: 10}, "", "10", 10 * time.Second, true},
+		{"HeaderOnly", NewError("blah", http.StatusServiceUnavailable), "5", "5", 5 * time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if tt.header != "" {
+				rr.Header().Set("Retry-After", tt.header)
+			}
+
+			if err := WriteErr(rr, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			res := rr.Result()
+			defer res.Body.Close()
+
+			if got, want := res.Header.Get("Retry-After"), tt.wantHeader; got != want {
+				t.Errorf("got header %q, want %q", got, want)
+			}
+
+			err := ReadHTTPError(res)
+			if !errors.Is(err, &Error{Code: tt.err.Code}) {
+				t.Fatalf("got error %v, want code %v", err, tt.err.Code)
+			}
+
+			d, ok := RetryAfter(fmt.Errorf("wrapped: %w", err))
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if d != tt.wantRetryAfter {
+				t.Errorf("got retry after %v, want %v", d, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+// trackingBody is a response body that records whether it has been read to EOF and closed.
+type trackingBody struct {
+	io.Reader
+	eof    bool
+	closed bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestReadHTTPResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        int
+		contentType string
+		body        string
+		wantData    string
+		wantPage    *PageDetails
+		wantErr     error
+	}{
+		{"OK", http.StatusOK, "application/json", `{"data":"blah"}`, "blah", nil, nil},
+		{"OKPage", http.StatusOK, "application/json; charset=utf-8", `{"data":"blah","page":{"next":"n"}}`, "blah", &PageDetails{Next: "n"}, nil},
+		{"OKTrailing", http.StatusOK, "application/json", `{"data":"blah"} `, "blah", nil, nil},
+		{"NoContent", http.StatusNoContent, "", ``, "", nil, nil},
+		{"Error", http.StatusNotFound, "application/json", `{"error":{"code":404,"message":"blah"}}`, "", nil, NewError("blah", http.StatusNotFound)},
+		{"ErrorNoBody", http.StatusBadGateway, "text/html", `<html></html>`, "", nil, &Error{Code: http.StatusBadGateway}},
+		{"ContentType", http.StatusOK, "text/html", `{"data":"blah"}`, "", nil, errors.New("")},
+		{"NoContentType", http.StatusOK, "", `{"data":"blah"}`, "", nil, errors.New("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := &trackingBody{Reader: strings.NewReader(tt.body)}
+			res := &http.Response{
+				StatusCode: tt.code,
+				Header:     http.Header{},
+				Body:       body,
+			}
+			if tt.contentType != "" {
+				res.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var s string
+			pd, err := ReadHTTPResponse(res, &s)
+
+			var je *Error
+			switch {
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			case errors.As(tt.wantErr, &je):
+				if !errors.Is(err, je) {
+					t.Fatalf("got error %v, want %v", err, je)
+				}
+			default:
+				if err == nil {
+					t.Fatalf("got nil error, want error")
+				}
+			}
+
+			if got, want := s, tt.wantData; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+			if got, want := pd, tt.wantPage; !reflect.DeepEqual(got, want) {
+				t.Errorf("got page %v, want %v", got, want)
+			}
+			if !body.eof {
+				t.Errorf("body not drained")
+			}
+			if !body.closed {
+				t.Errorf("body not closed")
+			}
+		})
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("a", maxSnippetSize-1)
+
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"Empty", "", ""},
+		{"Short", " <html>Bad Gateway</html>\n", "<html>Bad Gateway</html>"},
+		{"Exact", strings.Repeat("a", maxSnippetSize), strings.Repeat("a", maxSnippetSize)},
+		{"Truncated", strings.Repeat("a", maxSnippetSize+1), strings.Repeat("a", maxSnippetSize) + "..."},
+		{"TruncatedRune", long + "é", long + "..."},
+		{"Invalid", "a\xffb", "a�b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &snippet{max: maxSnippetSize}
+			if _, err := io.Copy(s, iotest.HalfReader(strings.NewReader(tt.s))); err != nil {
+				t.Fatalf("failed to write snippet: %v", err)
+			}
+			if got, want := s.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithFallbackError(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     int
+		body     string
+		opts     []Option
+		wantCode int
+		wantMsg  string
+		wantNil  bool
+	}{
+		{"HTML", http.StatusBadGateway, "<html>Bad Gateway</html>", []Option{WithFallbackError()}, http.StatusBadGateway, "<html>Bad Gateway</html>", false},
+		{"HTMLNoFallback", http.StatusBadGateway, "<html>Bad Gateway</html>", nil, 0, "", true},
+		{"Empty", http.StatusServiceUnavailable, "", []Option{WithFallbackError()}, http.StatusServiceUnavailable, "", false},
+		{"NoError", http.StatusInternalServerError, `{"data":"blah"}`, []Option{WithFallbackError()}, http.StatusInternalServerError, `{"data":"blah"}`, false},
+		{"Envelope", http.StatusInternalServerError, `{"error":{"code":404,"message":"blah"}}`, []Option{WithFallbackError()}, http.StatusNotFound, "blah", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: tt.code,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			err := ReadHTTPError(res, tt.opts...)
+			if tt.wantNil {
+				if err != nil {
+					t.Fatalf("got error %v, want nil", err)
+				}
+				return
+			}
+
+			var je *Error
+			if !errors.As(err, &je) {
+				t.Fatalf("got error %v, want *Error", err)
+			}
+			if got, want := je.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := je.Message, tt.wantMsg; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadErrorFallback(t *testing.T) {
+	err := ReadError(strings.NewReader("not json"), WithFallbackError())
+	if got, want := err, (&Error{Message: "not json"}); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithResponseCache causes the client helpers, such as Do, FetchAll and PollOperation, to retain
+// successful responses to GET requests in s, keyed by URL, so that repeated requests for the same
+// resource are answered without transferring its body again.
+//
+// A retained response is served without sending a request until the max-age of its Cache-Control
+// header elapses, after which the request is sent with an If-None-Match header containing its
+// entity tag. If the server responds with a 304 status code, the retained response is returned in
+// its place, with the headers written with the 304 response applied, so that it may be decoded by
+// ReadHTTPResponse as if it had been sent in full. Responses with neither an ETag header nor a
+// max-age, or whose Cache-Control header contains no-store, are not retained. A Cache-Control
+// header containing no-cache causes the response to be revalidated each time it is used. A
+// successful request with an unsafe method, such as POST or DELETE, discards the response retained
+// for its URL.
+//
+// Requests that already carry an If-None-Match or Range header are sent unchanged, and their
+// responses are not retained. Cached responses belong to the client, so s must not be shared
+// between clients that send different credentials.
+func WithResponseCache(s ResponseStore) Option {
+	return func(o *options) {
+		o.responseCache = s
+	}
+}
+
+// doCached sends req using c, answering it from the cache established by o where possible, and
+// retaining its response.
+func (o *options) doCached(c *http.Client, req *http.Request) (*http.Response, error) {
+	store := o.responseCache
+	key := req.URL.String()
+
+	switch req.Method {
+	case "", http.MethodGet:
+	case http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return o.send(c, req)
+	default:
+		res, err := o.send(c, req)
+		if err == nil && res.StatusCode < http.StatusBadRequest {
+			store.Delete(key)
+		}
+		return res, err
+	}
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("Range") != "" {
+		return o.send(c, req)
+	}
+
+	cr, ok := store.Load(key)
+	if ok && cr.varyMatches(req) {
+		if !cr.Expires.IsZero() && time.Now().Before(cr.Expires) {
+			return cachedHTTPResponse(req, cr), nil
+		}
+		if cr.ETag != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("If-None-Match", cr.ETag)
+		} else {
+			cr = nil
+		}
+	} else {
+		cr = nil
+	}
+
+	res, err := o.send(c, req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cr != nil && res.StatusCode == http.StatusNotModified:
+		_, _ = io.CopyN(io.Discard, res.Body, maxDrainSize)
+		res.Body.Close()
+
+		h := cr.Header.Clone()
+		for _, k := range notModifiedHeaders {
+			if v := res.Header.Values(k); len(v) > 0 {
+				h[http.CanonicalHeaderKey(k)] = v
+			}
+		}
+		if ncr, ok := clientCachedResponse(req, h, cr.Body); ok {
+			store.Store(key, ncr)
+			cr = ncr
+		} else {
+			store.Delete(key)
+			cr = &CachedResponse{Header: h, Body: cr.Body}
+		}
+		return cachedHTTPResponse(req, cr), nil
+
+	case res.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("jsonresp: failed to read response: %w", err)
+		}
+		res.Body = io.NopCloser(bytes.NewReader(body))
+
+		if cr, ok := clientCachedResponse(req, res.Header, body); ok {
+			store.Store(key, cr)
+		} else {
+			store.Delete(key)
+		}
+	}
+	return res, nil
+}
+
+// clientCachedResponse returns the response to r with header h and body, as it is to be retained
+// by a client. If the response must not be retained, ok is false.
+func clientCachedResponse(r *http.Request, h http.Header, body []byte) (cr *CachedResponse, ok bool) {
+	etag := h.Get("ETag")
+	maxAge := freshnessLifetime(h)
+	if etag == "" && maxAge <= 0 {
+		return nil, false
+	}
+	return newCachedResponse(r, h, body, etag, maxAge)
+}
+
+// freshnessLifetime returns the duration for which a response with header h may be used without
+// revalidation, according to its Cache-Control header, or zero if it must be revalidated each
+// time it is used.
+func freshnessLifetime(h http.Header) time.Duration {
+	var maxAge time.Duration
+	for _, v := range h.Values("Cache-Control") {
+		for _, s := range strings.Split(v, ",") {
+			k, v, _ := strings.Cut(strings.TrimSpace(s), "=")
+			switch strings.ToLower(k) {
+			case "no-cache":
+				return 0
+			case "max-age":
+				if n, err := strconv.Atoi(strings.Trim(v, `"`)); err == nil && n > 0 {
+					maxAge = time.Duration(n) * time.Second
+				}
+			}
+		}
+	}
+	return maxAge
+}
+
+// cachedHTTPResponse returns a successful response to req containing the retained response cr.
+func cachedHTTPResponse(req *http.Request, cr *CachedResponse) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cr.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(cr.Body)),
+		ContentLength: int64(len(cr.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// cacheServer is a test server whose resource has a configurable entity tag and Cache-Control
+// header, and which records the conditional requests it receives.
+type cacheServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	data         string
+	etag         string
+	cacheControl string
+	vary         string
+	hits         int
+	inm          []string // If-None-Match header of each request
+}
+
+func newCacheServer(t *testing.T, etag, cacheControl string) *cacheServer {
+	t.Helper()
+
+	cs := &cacheServer{data: "a", etag: etag, cacheControl: cacheControl}
+	cs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+
+		cs.hits++
+		cs.inm = append(cs.inm, r.Header.Get("If-None-Match"))
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if cs.etag != "" {
+			w.Header().Set("ETag", cs.etag)
+		}
+		if cs.cacheControl != "" {
+			w.Header().Set("Cache-Control", cs.cacheControl)
+		}
+		if cs.vary != "" {
+			w.Header().Set("Vary", cs.vary)
+		}
+		if inm := r.Header.Get("If-None-Match"); inm != "" && inm == cs.etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_ = WriteResponse(w, cs.data, http.StatusOK)
+	}))
+	t.Cleanup(cs.Close)
+	return cs
+}
+
+// set changes the resource served by cs.
+func (cs *cacheServer) set(data, etag, cacheControl string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.data, cs.etag, cs.cacheControl = data, etag, cacheControl
+}
+
+// requests returns the number of requests received by cs, and their If-None-Match headers.
+func (cs *cacheServer) requests() (int, []string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.hits, append([]string(nil), cs.inm...)
+}
+
+// get sends a request with method to cs using the cache s, and returns the data of the response.
+func (cs *cacheServer) get(t *testing.T, method string, s ResponseStore, header http.Header) (string, int) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), method, cs.URL+"/things", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	res, err := Do(cs.Client(), req, WithResponseCache(s))
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	code := res.StatusCode
+	if code != http.StatusOK {
+		res.Body.Close()
+		return "", code
+	}
+
+	var data string
+	if _, err := ReadHTTPResponse(res, &data); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	return data, code
+}
+
+func TestWithResponseCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		etag         string
+		cacheControl string
+		wantHits     int
+		wantINM      []string
+	}{
+		{"ETag", `"1"`, "", 3, []string{"", `"1"`, `"1"`}},
+		{"NoCache", `"1"`, "no-cache, max-age=60", 3, []string{"", `"1"`, `"1"`}},
+		{"MaxAge", `"1"`, "max-age=60", 1, []string{""}},
+		{"MaxAgeWithoutETag", "", "private, max-age=60", 1, []string{""}},
+		{"NoStore", `"1"`, "no-store", 3, []string{"", "", ""}},
+		{"NoValidator", "", "", 3, []string{"", "", ""}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newCacheServer(t, tt.etag, tt.cacheControl)
+			s := NewMemoryStore(10)
+
+			for i := 0; i < 3; i++ {
+				if data, code := cs.get(t, http.MethodGet, s, nil); data != "a" || code != http.StatusOK {
+					t.Fatalf("got data %q and code %v, want %q and %v", data, code, "a", http.StatusOK)
+				}
+			}
+
+			hits, inm := cs.requests()
+			if got, want := hits, tt.wantHits; got != want {
+				t.Errorf("got %v requests, want %v", got, want)
+			}
+			if got, want := inm, tt.wantINM; !reflect.DeepEqual(got, want) {
+				t.Errorf("got If-None-Match %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithResponseCacheModified(t *testing.T) {
+	cs := newCacheServer(t, `"1"`, "")
+	s := NewMemoryStore(10)
+
+	if data, _ := cs.get(t, http.MethodGet, s, nil); data != "a" {
+		t.Fatalf("got data %q, want %q", data, "a")
+	}
+	cs.set("b", `"2"`, "")
+	if data, _ := cs.get(t, http.MethodGet, s, nil); data != "b" {
+		t.Fatalf("got data %q, want %q", data, "b")
+	}
+	if data, _ := cs.get(t, http.MethodGet, s, nil); data != "b" {
+		t.Fatalf("got data %q, want %q", data, "b")
+	}
+
+	if _, inm := cs.requests(); !reflect.DeepEqual(inm, []string{"", `"1"`, `"2"`}) {
+		t.Errorf("got If-None-Match %q", inm)
+	}
+}
+
+func TestWithResponseCacheRevalidatedHeaders(t *testing.T) {
+	cs := newCacheServer(t, `"1"`, "")
+	s := NewMemoryStore(10)
+
+	cs.get(t, http.MethodGet, s, nil)
+	cs.set("a", `"1"`, "max-age=60")
+	cs.get(t, http.MethodGet, s, nil)
+	cs.get(t, http.MethodGet, s, nil)
+
+	if hits, _ := cs.requests(); hits != 2 {
+		t.Errorf("got %v requests, want 2", hits)
+	}
+	cr, ok := s.Load(cs.URL + "/things")
+	if !ok {
+		t.Fatal("response not retained")
+	}
+	if got, want := cr.Header.Get("Cache-Control"), "max-age=60"; got != want {
+		t.Errorf("got Cache-Control %q, want %q", got, want)
+	}
+}
+
+func TestWithResponseCacheUnsafe(t *testing.T) {
+	cs := newCacheServer(t, `"1"`, "max-age=60")
+	s := NewMemoryStore(10)
+
+	cs.get(t, http.MethodGet, s, nil)
+	cs.get(t, http.MethodHead, s, nil)
+	cs.get(t, http.MethodGet, s, nil)
+	cs.get(t, http.MethodDelete, s, nil)
+	cs.get(t, http.MethodGet, s, nil)
+
+	if hits, _ := cs.requests(); hits != 4 {
+		t.Errorf("got %v requests, want 4", hits)
+	}
+}
+
+func TestWithResponseCacheConditional(t *testing.T) {
+	cs := newCacheServer(t, `"1"`, "")
+	s := NewMemoryStore(10)
+
+	cs.get(t, http.MethodGet, s, nil)
+	if _, code := cs.get(t, http.MethodGet, s, http.Header{"If-None-Match": {`"1"`}}); code != http.StatusNotModified {
+		t.Errorf("got code %v, want %v", code, http.StatusNotModified)
+	}
+}
+
+func TestWithResponseCacheVary(t *testing.T) {
+	tests := []struct {
+		name     string
+		vary     string
+		wantHits int
+	}{
+		{"None", "", 1},
+		{"AcceptLanguage", "Accept-Language", 3},
+		{"Star", "*", 4},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cs := newCacheServer(t, `"1"`, "max-age=60")
+			cs.vary = tt.vary
+			s := NewMemoryStore(10)
+
+			for _, lang := range []string{"en", "en", "fr", "en"} {
+				cs.get(t, http.MethodGet, s, http.Header{"Accept-Language": {lang}})
+			}
+
+			if got, _ := cs.requests(); got != tt.wantHits {
+				t.Errorf("got %v requests, want %v", got, tt.wantHits)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Codec marshals and unmarshals JSON. It allows an alternative implementation of encoding/json to
+// be used by the write and read functions.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// WithCodec sets the Codec used to encode and decode responses. By default, encoding/json is
+// used.
+func WithCodec(c Codec) Option {
+	return func(o *options) {
+		o.marshal = c.Marshal
+		o.unmarshal = c.Unmarshal
+	}
+}
+
+// WithStrict causes the read functions to return an error if the response envelope, or the data
+// it contains, has an object key that does not match a field of the destination. This is useful
+// for detecting schema drift in tests. WithStrict has no effect when used with WithCodec.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithUseNumber causes the read functions to unmarshal numbers into an interface{} as a
+// json.Number rather than a float64, so that large integers do not lose precision. WithUseNumber
+// has no effect when used with WithCodec.
+func WithUseNumber() Option {
+	return func(o *options) {
+		o.useNumber = true
+	}
+}
+
+// maxDecodeErrorBody is the maximum number of bytes of a payload retained by a DecodeError.
+const maxDecodeErrorBody = 1 << 10
+
+// DecodeError describes a failure to decode a response, retaining the leading portion of the
+// payload so that the cause can be diagnosed.
+type DecodeError struct {
+	// Body is up to the first 1KiB of the payload that failed to decode. This is the response as
+	// read, or the encoded data when the envelope was decoded successfully but the data was not.
+	Body []byte
+
+	// Offset is the byte offset within the payload at which the error was detected, or -1 if it
+	// is not known.
+	Offset int64
+
+	// Err is the underlying error.
+	Err error
+}
+
+// newDecodeError returns a DecodeError describing err, which was encountered decoding the payload
+// beginning with body.
+func newDecodeError(body []byte, err error) *DecodeError {
+	de := &DecodeError{
+		Body:   body,
+		Offset: -1,
+		Err:    err,
+	}
+
+	var se *json.SyntaxError
+	var ute *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &se):
+		de.Offset = se.Offset
+	case errors.As(err, &ute):
+		de.Offset = ute.Offset
+	}
+	return de
+}
+
+func (e *DecodeError) Error() string {
+	if e.Offset >= 0 {
+		return fmt.Sprintf("%v (offset %v)", e.Err, e.Offset)
+	}
+	return e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decode decodes a single value from r into v. If decoding fails, a DecodeError is returned.
+func (o *options) decode(r io.Reader, v interface{}) error {
+	if o.ctx != nil {
+		r = &ctxReader{ctx: o.ctx, r: r}
+	}
+	if o.maxBodySize > 0 {
+		r = &maxBytesReader{r: r, n: o.maxBodySize}
+	}
+
+	s := &snippet{max: maxDecodeErrorBody}
+	if err := o.decodeFrom(io.TeeReader(r, s), v); err != nil {
+		de := newDecodeError(s.b, err)
+		if o.format != nil {
+			// Offsets are relative to the JSON converted from the payload, not the payload itself.
+			de.Offset = -1
+		}
+		observeDecodeError(de)
+		return de
+	}
+	return nil
+}
+
+// decodeFrom decodes a single value from r into v, converting it from the format of o, or applying
+// its field names, if necessary. Formats that decode values directly do so unless the unmarshal
+// function or duplicate key checks of o apply, which are defined in terms of JSON. Documents they
+// decline, including those that are invalid, are converted to JSON, so that errors are reported in
+// the same way.
+func (o *options) decodeFrom(r io.Reader, v interface{}) error {
+	if vf, ok := o.format.(valueFormat); ok && o.unmarshal == nil && o.duplicateKeys == duplicateKeysNone {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if vf.decodeValue(b, v, o) == nil {
+			return nil
+		}
+		r = bytes.NewReader(b)
+	}
+
+	if o.format != nil {
+		b, err := o.format.ToJSON(r)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	if o.maxDepth > 0 {
+		r = &depthReader{r: r, max: o.maxDepth}
+	}
+	if o.unmarshal == nil {
+		r = o.withDuplicateCheck(r, !o.noEnvelope)
+	}
+	v = o.renameFields(v)
+
+	if o.unmarshal == nil {
+		return o.newDecoder(r).Decode(v)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return o.unmarshal(b, v)
+}
+
+// newDecoder returns a json.Decoder reading from r, configured according to o.
+func (o *options) newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if o.strict {
+		dec.DisallowUnknownFields()
+	}
+	if o.useNumber {
+		dec.UseNumber()
+	}
+	return dec
+}
+
+// unmarshalData unmarshals the encoded data b into v. If unmarshalling fails, a DecodeError is
+// returned.
+func (o *options) unmarshalData(b []byte, v interface{}) error {
+	if err := o.unmarshalDataFrom(b, v); err != nil {
+		if len(b) > maxDecodeErrorBody {
+			b = b[:maxDecodeErrorBody]
+		}
+		de := newDecodeError(append([]byte(nil), b...), err)
+		observeDecodeError(de)
+		return de
+	}
+	return nil
+}
+
+// unmarshalDataFrom unmarshals the encoded data b into v, using the unmarshal function of o.
+func (o *options) unmarshalDataFrom(b []byte, v interface{}) error {
+	if o.unmarshal == nil {
+		if !o.strict && !o.useNumber {
+			return json.Unmarshal(b, v)
+		}
+		return o.newDecoder(bytes.NewReader(b)).Decode(v)
+	}
+	return o.unmarshal(b, v)
+}
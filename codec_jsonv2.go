@@ -0,0 +1,37 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build goexperiment.jsonv2
+
+package jsonresp
+
+import (
+	jsonv1 "encoding/json"
+	jsonv2 "encoding/json/v2"
+)
+
+// jsonv2Codec is a Codec backed by encoding/json/v2.
+type jsonv2Codec struct {
+	opts []jsonv2.Options
+}
+
+// JSONv2Codec returns a Codec backed by the experimental encoding/json/v2 package, configured with
+// opts. It is only available when building with GOEXPERIMENT=jsonv2.
+//
+// To preserve the wire format of the response envelope, fields tagged with omitempty are omitted
+// using the legacy encoding/json semantics, unless overridden by opts.
+func JSONv2Codec(opts ...jsonv2.Options) Codec { //nolint:ireturn
+	return jsonv2Codec{
+		opts: append([]jsonv2.Options{jsonv1.OmitEmptyWithLegacySemantics(true)}, opts...),
+	}
+}
+
+func (c jsonv2Codec) Marshal(v interface{}) ([]byte, error) {
+	return jsonv2.Marshal(v, c.opts...)
+}
+
+func (c jsonv2Codec) Unmarshal(data []byte, v interface{}) error {
+	return jsonv2.Unmarshal(data, v, c.opts...)
+}
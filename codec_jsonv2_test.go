@@ -0,0 +1,75 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build goexperiment.jsonv2
+
+package jsonresp
+
+import (
+	jsonv2 "encoding/json/v2"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestJSONv2Codec(t *testing.T) {
+	type TestStruct struct {
+		Value string `json:"value"`
+	}
+
+	tests := []struct {
+		name     string
+		opts     []jsonv2.Options
+		data     interface{}
+		pd       *PageDetails
+		wantBody string
+	}{
+		{"Data", nil, TestStruct{"blah"}, nil, `{"data":{"value":"blah"}}`},
+		{"Page", nil, TestStruct{"blah"}, &PageDetails{Next: "n"}, `{"data":{"value":"blah"},"page":{"next":"n"}}`},
+		{"Deterministic", []jsonv2.Options{jsonv2.Deterministic(true)}, map[string]int{"b": 2, "a": 1}, nil, `{"data":{"a":1,"b":2}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := JSONv2Codec(tt.opts...)
+
+			rr := httptest.NewRecorder()
+			if err := WriteResponsePage(rr, tt.data, tt.pd, http.StatusOK, WithCodec(c)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+
+			v := reflect.New(reflect.TypeOf(tt.data))
+			pd, err := ReadResponsePage(rr.Body, v.Interface(), WithCodec(c))
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := v.Elem().Interface(), tt.data; !reflect.DeepEqual(got, want) {
+				t.Errorf("got data %v, want %v", got, want)
+			}
+			if got, want := pd, tt.pd; !reflect.DeepEqual(got, want) {
+				t.Errorf("got page %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONv2CodecError(t *testing.T) {
+	c := JSONv2Codec()
+
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithCodec(c)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"error":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if got, want := ReadError(rr.Body, WithCodec(c)), (&Error{Code: http.StatusNotFound, Message: "blah"}); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
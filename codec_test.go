@@ -0,0 +1,185 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testCodec wraps encoding/json, counting calls.
+type testCodec struct {
+	marshals   int
+	unmarshals int
+}
+
+func (c *testCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *testCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshals++
+	return json.Unmarshal(data, v)
+}
+
+func TestWithCodec(t *testing.T) {
+	type TestStruct struct {
+		Value string
+	}
+
+	c := &testCodec{}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, TestStruct{"blah"}, &PageDetails{Next: "n"}, http.StatusOK, WithCodec(c)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := c.marshals, 1; got != want {
+		t.Errorf("got %v marshals, want %v", got, want)
+	}
+
+	var ts TestStruct
+	pd, err := ReadResponsePage(rr.Body, &ts, WithCodec(c))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if got, want := ts.Value, "blah"; got != want {
+		t.Errorf("got value %q, want %q", got, want)
+	}
+	if got, want := pd.Next, "n"; got != want {
+		t.Errorf("got next %q, want %q", got, want)
+	}
+	if got, want := c.unmarshals, 2; got != want {
+		t.Errorf("got %v unmarshals, want %v", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithCodec(c)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := ReadError(rr.Body, WithCodec(c)), (&Error{Code: http.StatusNotFound, Message: "blah"}); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+	if got, want := c.unmarshals, 3; got != want {
+		t.Errorf("got %v unmarshals, want %v", got, want)
+	}
+}
+
+func TestWithStrict(t *testing.T) {
+	type TestStruct struct {
+		Value string
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"Known", `{"data":{"Value":"blah"},"page":{"next":"n"}}`, []Option{WithStrict()}, false},
+		{"UnknownData", `{"data":{"Value":"blah","Other":1}}`, nil, false},
+		{"UnknownDataStrict", `{"data":{"Value":"blah","Other":1}}`, []Option{WithStrict()}, true},
+		{"UnknownEnvelope", `{"data":{"Value":"blah"},"other":1}`, nil, false},
+		{"UnknownEnvelopeStrict", `{"data":{"Value":"blah"},"other":1}`, []Option{WithStrict()}, true},
+		{"UnknownPageStrict", `{"data":{"Value":"blah"},"page":{"other":1}}`, []Option{WithStrict()}, true},
+		{"Codec", `{"data":{"Value":"blah","Other":1}}`, []Option{WithStrict(), WithCodec(&testCodec{})}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts TestStruct
+			err := ReadResponse(strings.NewReader(tt.body), &ts, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && ts.Value != "blah" {
+				t.Errorf("got value %q, want %q", ts.Value, "blah")
+			}
+		})
+	}
+}
+
+func TestReadErrorStrict(t *testing.T) {
+	r := strings.NewReader(`{"error":{"code":404,"message":"blah"}}`)
+	if err := ReadError(r, WithStrict()); !errors.Is(err, NewError("blah", http.StatusNotFound)) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestWithUseNumber(t *testing.T) {
+	const body = `{"data":{"id":9007199254740993}}`
+
+	tests := []struct {
+		name string
+		opts []Option
+		want interface{}
+	}{
+		{"Float", nil, float64(9007199254740993)},
+		{"Number", []Option{WithUseNumber()}, json.Number("9007199254740993")},
+		{"NumberStrict", []Option{WithUseNumber(), WithStrict()}, json.Number("9007199254740993")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var m map[string]interface{}
+			if err := ReadResponse(strings.NewReader(body), &m, tt.opts...); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := m["id"], tt.want; got != want {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadErrorUseNumber(t *testing.T) {
+	r := strings.NewReader(`{"error":{"code":400,"details":{"limit":9007199254740993}}}`)
+
+	var je *Error
+	if !errors.As(ReadError(r, WithUseNumber()), &je) {
+		t.Fatalf("failed to read error")
+	}
+	if got, want := je.Details["limit"], json.Number("9007199254740993"); got != want {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	long := `{"data":"` + strings.Repeat("a", maxDecodeErrorBody) + `"`
+
+	tests := []struct {
+		name       string
+		body       string
+		v          interface{}
+		opts       []Option
+		wantBody   string
+		wantOffset int64
+	}{
+		{"Syntax", `{"data":}`, nil, nil, `{"data":}`, 9},
+		{"Truncated", long, nil, nil, long[:maxDecodeErrorBody], -1},
+		{"DataType", `{"data":{"a":"b"}}`, new(int), nil, `{"a":"b"}`, 1},
+		{"DataField", `{"data":{"a":"b"}}`, new(struct{ A int }), nil, `{"a":"b"}`, 8},
+		{"Format", "\x81\xa4data\xc1", nil, []Option{WithFormat(MessagePack)}, "\x81\xa4data\xc1", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ReadResponse(strings.NewReader(tt.body), tt.v, tt.opts...)
+
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("got error %v, want DecodeError", err)
+			}
+			if got, want := string(de.Body), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+			if got, want := de.Offset, tt.wantOffset; got != want {
+				t.Errorf("got offset %v, want %v", got, want)
+			}
+		})
+	}
+}
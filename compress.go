@@ -0,0 +1,134 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the size below which buffered responses are not compressed, as the framing
+// overhead outweighs any saving.
+const minCompressSize = 1 << 10
+
+// WithCompression causes the response to be compressed if the Accept-Encoding header of r permits
+// it. The gzip and deflate content codings are supported. Buffered responses smaller than 1KiB are
+// written uncompressed. The Content-Encoding, Content-Length and Vary headers of the response are
+// set accordingly.
+func WithCompression(r *http.Request) Option {
+	return func(o *options) {
+		o.compress = true
+		o.acceptEncoding = r.Header.Get("Accept-Encoding")
+	}
+}
+
+// acceptedEncoding returns the supported content coding that best satisfies the Accept-Encoding
+// header value v, or an empty string if the response should not be compressed. When gzip and
+// deflate are equally acceptable, gzip is preferred.
+func acceptedEncoding(v string) string {
+	var gzipQ, deflateQ, anyQ float64 = -1, -1, -1
+	for _, s := range strings.Split(v, ",") {
+		coding, params, _ := strings.Cut(s, ";")
+
+		q := 1.0
+		if k, v, ok := strings.Cut(params, "="); ok && strings.TrimSpace(k) == "q" {
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+				continue
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "deflate":
+			deflateQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+
+	if gzipQ < 0 {
+		gzipQ = anyQ
+	}
+	if deflateQ < 0 {
+		deflateQ = anyQ
+	}
+
+	switch {
+	case gzipQ > 0 && gzipQ >= deflateQ:
+		return "gzip"
+	case deflateQ > 0:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+var (
+	gzipWriterPool sync.Pool
+	zlibWriterPool sync.Pool
+)
+
+// compressor is a pooled writer implementing a content coding.
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// newCompressor returns a compressor from the pool that writes data compressed using the content
+// coding ce to w.
+func newCompressor(w io.Writer, ce string) compressor { //nolint:ireturn
+	pool, newFn := &gzipWriterPool, func() compressor { return gzip.NewWriter(nil) }
+	if ce == "deflate" {
+		pool, newFn = &zlibWriterPool, func() compressor { return zlib.NewWriter(nil) }
+	}
+
+	c, ok := pool.Get().(compressor)
+	if !ok {
+		c = newFn()
+	}
+	c.Reset(w)
+	return c
+}
+
+// releaseCompressor returns c, which implements content coding ce, to the pool.
+func releaseCompressor(c compressor, ce string) {
+	c.Reset(nil)
+	if ce == "deflate" {
+		zlibWriterPool.Put(c)
+	} else {
+		gzipWriterPool.Put(c)
+	}
+}
+
+// contentEncoding returns the content coding with which a buffered response of n bytes should be
+// compressed, or an empty string if it should not be compressed.
+func (o *options) contentEncoding(n int) string {
+	if !o.compress || n < minCompressSize {
+		return ""
+	}
+	return acceptedEncoding(o.acceptEncoding)
+}
+
+// compress compresses body into es using the content coding ce, and returns the result.
+func (es *encodeState) compress(body []byte, ce string) ([]byte, error) {
+	c := newCompressor(&es.Buffer, ce)
+	defer releaseCompressor(c, ce)
+
+	if _, err := c.Write(body); err != nil {
+		return nil, err
+	}
+	if err := c.Close(); err != nil {
+		return nil, err
+	}
+	return es.Bytes(), nil
+}
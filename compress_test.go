@@ -0,0 +1,131 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want string
+	}{
+		{"Empty", "", ""},
+		{"Gzip", "gzip", "gzip"},
+		{"XGzip", "x-gzip", "gzip"},
+		{"Deflate", "deflate", "deflate"},
+		{"Both", "deflate, gzip", "gzip"},
+		{"Quality", "gzip;q=0.5, deflate", "deflate"},
+		{"Wildcard", "*", "gzip"},
+		{"WildcardExcluded", "*, gzip;q=0", "deflate"},
+		{"Identity", "identity", ""},
+		{"Unsupported", "br, zstd", ""},
+		{"Zero", "gzip;q=0, deflate;q=0", ""},
+		{"Case", "GZIP", "gzip"},
+		{"InvalidQuality", "gzip;q=x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := acceptedEncoding(tt.v), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// decompress returns the body of rr, decompressed according to its Content-Encoding header.
+func decompress(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var r io.Reader = rr.Body
+	switch ce := rr.Header().Get("Content-Encoding"); ce {
+	case "":
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatalf("failed to create gzip reader: %v", err)
+		}
+		r = zr
+	case "deflate":
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			t.Fatalf("failed to create zlib reader: %v", err)
+		}
+		r = zr
+	default:
+		t.Fatalf("unexpected content encoding %q", ce)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decompress body: %v", err)
+	}
+	return string(b)
+}
+
+func TestWithCompression(t *testing.T) {
+	large := strings.Repeat("a", minCompressSize)
+
+	tests := []struct {
+		name                string
+		acceptEncoding      string
+		data                string
+		opts                []Option
+		wantContentEncoding string
+	}{
+		{"None", "", large, nil, ""},
+		{"Gzip", "gzip", large, nil, "gzip"},
+		{"Deflate", "deflate", large, nil, "deflate"},
+		{"Small", "gzip", "blah", nil, ""},
+		{"Unsupported", "br", large, nil, ""},
+		{"StreamGzip", "gzip", large, []Option{WithStream()}, "gzip"},
+		{"StreamSmall", "gzip", "blah", []Option{WithStream()}, "gzip"},
+		{"StreamNone", "", large, []Option{WithStream()}, ""},
+		{"StreamEncoder", "deflate", large, []Option{WithStream(), WithEncoder(json.Marshal)}, "deflate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rr := httptest.NewRecorder()
+
+			opts := append([]Option{WithCompression(r)}, tt.opts...)
+			if err := WriteResponse(rr, tt.data, http.StatusOK, opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Header().Get("Content-Encoding"), tt.wantContentEncoding; got != want {
+				t.Errorf("got content encoding %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Vary"), "Accept-Encoding"; got != want {
+				t.Errorf("got vary %q, want %q", got, want)
+			}
+			if cl := rr.Header().Get("Content-Length"); cl != "" {
+				if got, want := cl, strconv.Itoa(rr.Body.Len()); got != want {
+					t.Errorf("got content length %v, want %v", got, want)
+				}
+			}
+
+			var s string
+			if err := ReadResponse(strings.NewReader(decompress(t, rr)), &s); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := s, tt.data; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithPreconditions causes the conditional request headers of r to be evaluated against the
+// validators of the response established by WithETag and WithLastModified. If the
+// If-None-Match or If-Modified-Since precondition of a GET or HEAD request is not met, a 304
+// status code is written without a body. If any other precondition is not met, a 412 status code
+// and JSON error is written instead. Only responses with a 2xx status code are subject to
+// preconditions.
+func WithPreconditions(r *http.Request) Option {
+	return func(o *options) {
+		o.method = r.Method
+		o.ifNoneMatch = r.Header.Get("If-None-Match")
+		o.ifModifiedSince = r.Header.Get("If-Modified-Since")
+		o.ifUnmodifiedSince = r.Header.Get("If-Unmodified-Since")
+	}
+}
+
+// WithETag causes a strong entity tag to be computed from the encoded response and written in the
+// ETag header, for responses with a 2xx status code. When used with WithPreconditions, the
+// If-None-Match header of the request is evaluated against it. Because the entity tag is computed
+// from the buffered response, WithETag has no effect when used with WithStream.
+func WithETag() Option {
+	return func(o *options) {
+		o.etag = true
+	}
+}
+
+// WithLastModified causes t to be written in the Last-Modified header, for responses with a 2xx
+// status code. When used with WithPreconditions, the If-Modified-Since and If-Unmodified-Since
+// headers of the request are evaluated against it. Like WithETag, WithLastModified has no effect
+// when used with WithStream.
+func WithLastModified(t time.Time) Option {
+	return func(o *options) {
+		o.lastModified = t
+	}
+}
+
+// WithHead causes the body of the response to be omitted if r is a HEAD request. The response is
+// encoded as usual, so the Content-Length header, and those established by options such as
+// WithETag and WithCompression, are the same as for the equivalent GET request. HEAD responses
+// are buffered even when WithStream is used, so that their Content-Length can be determined.
+func WithHead(r *http.Request) Option {
+	return func(o *options) {
+		o.head = r.Method == http.MethodHead
+	}
+}
+
+// entityTag returns a strong entity tag for body, sent with content coding ce.
+func entityTag(body []byte, ce string) string {
+	sum := sha256.Sum256(body)
+	tag := hex.EncodeToString(sum[:16])
+	if ce != "" {
+		// Representations with different content codings must have different strong entity tags.
+		tag += "-" + ce
+	}
+	return `"` + tag + `"`
+}
+
+// etagMatches reports whether the If-None-Match header value v matches the entity tag tag, using
+// the weak comparison function.
+func etagMatches(v, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		if s == "*" || strings.TrimPrefix(s, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// modifiedSince reports whether the last modification time of the response established by
+// WithLastModified is after the HTTP date v. If either is unknown or invalid, ok is false.
+func (o *options) modifiedSince(v string) (modified, ok bool) {
+	if o.lastModified.IsZero() || v == "" {
+		return false, false
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return false, false
+	}
+	return o.lastModified.Truncate(time.Second).After(t), true
+}
+
+// precondition evaluates the conditional request headers established by WithPreconditions against
+// the entity tag and last modification time of the response, in the order described by RFC 7232.
+// It returns zero if the response should be written, or the status code that should be written
+// instead.
+func (o *options) precondition(etag string) int {
+	if modified, ok := o.modifiedSince(o.ifUnmodifiedSince); ok && modified {
+		return http.StatusPreconditionFailed
+	}
+
+	isGet := o.method == http.MethodGet || o.method == http.MethodHead
+
+	if o.ifNoneMatch != "" {
+		if etag == "" || !etagMatches(o.ifNoneMatch, etag) {
+			return 0
+		}
+		if isGet {
+			return http.StatusNotModified
+		}
+		return http.StatusPreconditionFailed
+	}
+
+	if modified, ok := o.modifiedSince(o.ifModifiedSince); ok && !modified && isGet {
+		return http.StatusNotModified
+	}
+	return 0
+}
+
+// isSuccess reports whether code is a 2xx status code.
+func isSuccess(code int) bool {
+	return code >= 200 && code <= 299
+}
+
+// addVary adds value to the Vary header of h, unless it is already present.
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
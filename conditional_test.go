@@ -0,0 +1,232 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		tag  string
+		want bool
+	}{
+		{"Match", `"abc"`, `"abc"`, true},
+		{"NoMatch", `"abc"`, `"def"`, false},
+		{"List", `"def", "abc"`, `"abc"`, true},
+		{"Wildcard", `*`, `"abc"`, true},
+		{"Weak", `W/"abc"`, `"abc"`, true},
+		{"Unquoted", `abc`, `"abc"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := etagMatches(tt.v, tt.tag), tt.want; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithETag(t *testing.T) {
+	// Determine the entity tag of the response.
+	rr := httptest.NewRecorder()
+	if err := WriteResponse(rr, "blah", http.StatusOK, WithETag()); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("missing ETag header")
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		code        int
+		wantCode    int
+		wantETag    string
+		wantBody    bool
+	}{
+		{"NoCondition", http.MethodGet, "", http.StatusOK, http.StatusOK, etag, true},
+		{"Match", http.MethodGet, etag, http.StatusOK, http.StatusNotModified, etag, false},
+		{"MatchHead", http.MethodHead, etag, http.StatusOK, http.StatusNotModified, etag, false},
+		{"MatchWildcard", http.MethodGet, "*", http.StatusOK, http.StatusNotModified, etag, false},
+		{"NoMatch", http.MethodGet, `"other"`, http.StatusOK, http.StatusOK, etag, true},
+		{"MatchPut", http.MethodPut, etag, http.StatusOK, http.StatusPreconditionFailed, "", true},
+		{"NotSuccess", http.MethodGet, etag, http.StatusNotFound, http.StatusNotFound, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			rr := httptest.NewRecorder()
+
+			if err := WriteResponse(rr, "blah", tt.code, WithPreconditions(r), WithETag()); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("ETag"), tt.wantETag; got != want {
+				t.Errorf("got ETag %q, want %q", got, want)
+			}
+			if got, want := rr.Body.Len() > 0, tt.wantBody; got != want {
+				t.Errorf("got body %q, want body %v", rr.Body.String(), want)
+			}
+		})
+	}
+}
+
+func TestWithETagCompression(t *testing.T) {
+	large := strings.Repeat("a", minCompressSize)
+
+	etags := make(map[string]bool)
+	for _, ae := range []string{"", "gzip", "deflate"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", ae)
+
+		rr := httptest.NewRecorder()
+		if err := WriteResponse(rr, large, http.StatusOK, WithCompression(r), WithETag()); err != nil {
+			t.Fatalf("failed to write response: %v", err)
+		}
+		etags[rr.Header().Get("ETag")] = true
+
+		// A matching conditional request results in a 304, retaining the Vary header.
+		r.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+		rr = httptest.NewRecorder()
+		if err := WriteResponse(rr, large, http.StatusOK, WithCompression(r), WithPreconditions(r), WithETag()); err != nil {
+			t.Fatalf("failed to write response: %v", err)
+		}
+		if got, want := rr.Code, http.StatusNotModified; got != want {
+			t.Errorf("%q: got code %v, want %v", ae, got, want)
+		}
+		if got, want := rr.Header().Get("Vary"), "Accept-Encoding"; got != want {
+			t.Errorf("%q: got vary %q, want %q", ae, got, want)
+		}
+	}
+
+	if got, want := len(etags), 3; got != want {
+		t.Errorf("got %v distinct entity tags, want %v", got, want)
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Vary", "Accept, accept-encoding")
+
+	addVary(h, "Accept-Encoding")
+	addVary(h, "Origin")
+
+	if got, want := h.Values("Vary"), []string{"Accept, accept-encoding", "Origin"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWithLastModified(t *testing.T) {
+	lm := time.Date(2021, 1, 1, 12, 0, 0, 500, time.UTC)
+	before := lm.Add(-time.Hour).Format(http.TimeFormat)
+	at := lm.Format(http.TimeFormat)
+	after := lm.Add(time.Hour).Format(http.TimeFormat)
+
+	tests := []struct {
+		name     string
+		method   string
+		header   http.Header
+		code     int
+		wantCode int
+	}{
+		{"NoCondition", http.MethodGet, http.Header{}, http.StatusOK, http.StatusOK},
+		{"ModifiedSince", http.MethodGet, http.Header{"If-Modified-Since": {before}}, http.StatusOK, http.StatusOK},
+		{"NotModifiedSince", http.MethodGet, http.Header{"If-Modified-Since": {at}}, http.StatusOK, http.StatusNotModified},
+		{"NotModifiedSinceAfter", http.MethodHead, http.Header{"If-Modified-Since": {after}}, http.StatusOK, http.StatusNotModified},
+		{"NotModifiedSincePut", http.MethodPut, http.Header{"If-Modified-Since": {at}}, http.StatusOK, http.StatusOK},
+		{"InvalidDate", http.MethodGet, http.Header{"If-Modified-Since": {"yesterday"}}, http.StatusOK, http.StatusOK},
+		{"Unmodified", http.MethodPut, http.Header{"If-Unmodified-Since": {at}}, http.StatusOK, http.StatusOK},
+		{"UnmodifiedFailed", http.MethodPut, http.Header{"If-Unmodified-Since": {before}}, http.StatusOK, http.StatusPreconditionFailed},
+		{"UnmodifiedFailedGet", http.MethodGet, http.Header{"If-Unmodified-Since": {before}}, http.StatusOK, http.StatusPreconditionFailed},
+		{"NoneMatchTakesPrecedence", http.MethodGet, http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {at}}, http.StatusOK, http.StatusOK},
+		{"NotSuccess", http.MethodGet, http.Header{"If-Modified-Since": {at}}, http.StatusNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.Header = tt.header
+			rr := httptest.NewRecorder()
+
+			if err := WriteResponse(rr, "blah", tt.code, WithPreconditions(r), WithLastModified(lm)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+
+			want := at
+			if tt.wantCode != http.StatusOK && tt.wantCode != http.StatusNotModified {
+				want = ""
+			}
+			if got := rr.Header().Get("Last-Modified"); got != want {
+				t.Errorf("got Last-Modified %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithHead(t *testing.T) {
+	large := strings.Repeat("a", minCompressSize)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Plain", nil},
+		{"Stream", []Option{WithStream()}},
+		{"ETag", []Option{WithETag()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get := httptest.NewRequest(http.MethodGet, "/", nil)
+			get.Header.Set("Accept-Encoding", "gzip")
+			head := httptest.NewRequest(http.MethodHead, "/", nil)
+			head.Header.Set("Accept-Encoding", "gzip")
+
+			getRR := httptest.NewRecorder()
+			if err := WriteResponse(getRR, large, http.StatusOK, append(tt.opts, WithHead(get), WithCompression(get))...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			headRR := httptest.NewRecorder()
+			if err := WriteResponse(headRR, large, http.StatusOK, append(tt.opts, WithHead(head), WithCompression(head))...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := headRR.Code, getRR.Code; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got := headRR.Body.Len(); got != 0 {
+				t.Errorf("got %v byte body, want none", got)
+			}
+			if got, want := headRR.Header().Get("Content-Length"), strconv.Itoa(getRR.Body.Len()); got != want {
+				t.Errorf("got Content-Length %v, want %v", got, want)
+			}
+			for _, k := range []string{"Content-Type", "Content-Encoding", "ETag"} {
+				if got, want := headRR.Header().Get(k), getRR.Header().Get(k); got != want {
+					t.Errorf("got %v %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// WithCharset adds a charset parameter with the value charset to the Content-Type header of the
+// response, unless the header already specifies one.
+func WithCharset(charset string) Option {
+	return func(o *options) {
+		o.charset = charset
+	}
+}
+
+// WithAllowedContentTypes sets the Content-Type header values accepted by ReadHTTPResponse and
+// ReadRequest, such as "application/vnd.example+json; v=2". A value matches a header with the same
+// media type and, if the value has parameters, the same parameter values, compared without regard
+// to case. Parameters of the header that are absent from the value are ignored. By default, only
+// the media type established by WithContentType or WithFormat is accepted.
+func WithAllowedContentTypes(contentTypes ...string) Option {
+	return func(o *options) {
+		o.allowedContentTypes = contentTypes
+	}
+}
+
+// withCharset returns the Content-Type header value ct with the charset established by o added.
+func (o *options) withCharset(ct string) string {
+	if o.charset == "" {
+		return ct
+	}
+	if _, params, err := mime.ParseMediaType(ct); err == nil && params["charset"] != "" {
+		return ct
+	}
+	return ct + "; charset=" + o.charset
+}
+
+// allowsContentType reports whether the Content-Type header value ct is accepted by o.
+func (o *options) allowsContentType(ct string) bool {
+	if len(o.allowedContentTypes) == 0 {
+		return sameMediaType(ct, o.mediaType())
+	}
+	for _, want := range o.allowedContentTypes {
+		if matchContentType(ct, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// wantContentType describes the Content-Type header values accepted by o, for use in errors.
+func (o *options) wantContentType() string {
+	switch len(o.allowedContentTypes) {
+	case 0:
+		return fmt.Sprintf("%q", o.mediaType())
+	case 1:
+		return fmt.Sprintf("%q", o.allowedContentTypes[0])
+	default:
+		return fmt.Sprintf("one of %q", o.allowedContentTypes)
+	}
+}
+
+// matchContentType reports whether the Content-Type header value ct has the media type of want,
+// and the parameters that it specifies.
+func matchContentType(ct, want string) bool {
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	wmt, wparams, err := mime.ParseMediaType(want)
+	if err != nil || mt != wmt {
+		return false
+	}
+	for k, v := range wparams {
+		if !strings.EqualFold(params[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// supportedContentTypes returns the Content-Type header values accepted by o.
+func (o *options) supportedContentTypes() []string {
+	if len(o.allowedContentTypes) == 0 {
+		return []string{o.mediaType()}
+	}
+	return append([]string(nil), o.allowedContentTypes...)
+}
+
+// isUTF8 reports whether charset names the UTF-8 character encoding.
+func isUTF8(charset string) bool {
+	return strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "utf8")
+}
+
+// allowsCharset reports whether the character encoding charset is accepted by o. UTF-8 is always
+// accepted. Other encodings are accepted only if established by WithCharset, or the parameters of
+// WithAllowedContentTypes.
+func (o *options) allowsCharset(charset string) bool {
+	if isUTF8(charset) || strings.EqualFold(charset, o.charset) {
+		return true
+	}
+	for _, ct := range o.allowedContentTypes {
+		if _, params, err := mime.ParseMediaType(ct); err == nil && strings.EqualFold(params["charset"], charset) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequestContentType returns an Error with a 415 status code if the Content-Type header value
+// ct of a request body is not accepted by o, or nil if it is.
+func (o *options) checkRequestContentType(ct string) *Error {
+	if !o.allowsContentType(ct) {
+		return &Error{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("unsupported content type %q, want %v", ct, o.wantContentType()),
+			Details: map[string]interface{}{"supported": o.supportedContentTypes()},
+		}
+	}
+	if _, params, _ := mime.ParseMediaType(ct); params["charset"] != "" && !o.allowsCharset(params["charset"]) {
+		return &Error{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("unsupported charset %q, want \"utf-8\"", params["charset"]),
+			Details: map[string]interface{}{"supported": o.supportedContentTypes()},
+		}
+	}
+	return nil
+}
+
+// RequireContentType returns middleware that rejects requests with a body whose Content-Type
+// header does not match any of types, in the same way as WithAllowedContentTypes, with a 415
+// status code and JSON error listing them, before the handler is invoked. If types is empty,
+// only "application/json" is accepted. As JSON is encoded in UTF-8, a charset parameter naming
+// another encoding is rejected, unless it is specified by one of types. Requests without a body
+// are accepted.
+func RequireContentType(types ...string) func(http.Handler) http.Handler {
+	var o *options
+	if len(types) > 0 {
+		o = newOptions([]Option{WithAllowedContentTypes(append([]string(nil), types...)...)})
+	} else {
+		o = newOptions(nil)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				if je := o.checkRequestContentType(r.Header.Get("Content-Type")); je != nil {
+					_ = writeError(w, je, nil, newOptions([]Option{WithRequest(r), WithRequestID(r)}))
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
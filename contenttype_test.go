@@ -0,0 +1,134 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithAllowedContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		opts        []Option
+		wantOK      bool
+	}{
+		{"Default", "application/json; charset=utf-8", nil, true},
+		{"DefaultMismatch", "application/vnd.test+json", nil, false},
+		{"Allowed", "application/vnd.test+json", []Option{WithAllowedContentTypes("application/vnd.test+json")}, true},
+		{"AllowedCase", "Application/Vnd.Test+JSON", []Option{WithAllowedContentTypes("application/vnd.test+json")}, true},
+		{"AllowedExtraParam", "application/vnd.test+json; charset=utf-8", []Option{WithAllowedContentTypes("application/vnd.test+json")}, true},
+		{"AllowedParam", "application/vnd.test+json; v=2; charset=UTF-8", []Option{WithAllowedContentTypes("application/vnd.test+json; charset=utf-8; v=2")}, true},
+		{"AllowedParamMismatch", "application/vnd.test+json; v=1", []Option{WithAllowedContentTypes("application/vnd.test+json; v=2")}, false},
+		{"AllowedParamMissing", "application/vnd.test+json", []Option{WithAllowedContentTypes("application/vnd.test+json; v=2")}, false},
+		{"AllowedSet", "application/json", []Option{WithAllowedContentTypes("application/vnd.test+json", "application/json")}, true},
+		{"AllowedSetMismatch", "text/plain", []Option{WithAllowedContentTypes("application/vnd.test+json", "application/json")}, false},
+		{"AllowedReplacesDefault", "application/json", []Option{WithAllowedContentTypes("application/vnd.test+json")}, false},
+		{"Invalid", "application/", []Option{WithAllowedContentTypes("application/json")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {tt.contentType}},
+				Body:       io.NopCloser(strings.NewReader(`{"data":"blah"}`)),
+			}
+			var s string
+			if _, err := ReadHTTPResponse(res, &s, tt.opts...); (err == nil) != tt.wantOK {
+				t.Errorf("got response error %v, want ok %v", err, tt.wantOK)
+			}
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`"blah"`))
+			r.Header.Set("Content-Type", tt.contentType)
+			err := ReadRequest(r, &s, tt.opts...)
+			if (err == nil) != tt.wantOK {
+				t.Errorf("got request error %v, want ok %v", err, tt.wantOK)
+			}
+			if code, _ := StatusCode(err); err != nil && code != http.StatusUnsupportedMediaType {
+				t.Errorf("got code %v, want %v", code, http.StatusUnsupportedMediaType)
+			}
+		})
+	}
+}
+
+func TestWantContentType(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"Default", nil, `"application/json"`},
+		{"One", []Option{WithAllowedContentTypes("application/vnd.test+json")}, `"application/vnd.test+json"`},
+		{"Many", []Option{WithAllowedContentTypes("application/vnd.test+json", "application/json")}, `one of ["application/vnd.test+json" "application/json"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newOptions(tt.opts).wantContentType(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireContentType(t *testing.T) {
+	tests := []struct {
+		name          string
+		types         []string
+		contentType   string
+		body          string
+		wantCode      int
+		wantSupported []interface{}
+	}{
+		{"Default", nil, "application/json", "{}", http.StatusOK, nil},
+		{"DefaultCharset", nil, "application/json; charset=utf-8", "{}", http.StatusOK, nil},
+		{"DefaultWrong", nil, "text/plain", "{}", http.StatusUnsupportedMediaType, []interface{}{"application/json"}},
+		{"DefaultMissing", nil, "", "{}", http.StatusUnsupportedMediaType, []interface{}{"application/json"}},
+		{"WrongCharset", nil, "application/json; charset=utf-16", "{}", http.StatusUnsupportedMediaType, []interface{}{"application/json"}},
+		{"NoBody", nil, "text/plain", "", http.StatusOK, nil},
+		{"Types", []string{"application/json", "application/msgpack"}, "application/msgpack", "{}", http.StatusOK, nil},
+		{"TypesWrong", []string{"application/json", "application/msgpack"}, "text/plain", "{}", http.StatusUnsupportedMediaType, []interface{}{"application/json", "application/msgpack"}},
+		{"TypesCharset", []string{"text/csv; charset=iso-8859-1"}, "text/csv; charset=ISO-8859-1", "a,b", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", body)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+
+			h := RequireContentType(tt.types...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			h.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+
+			if tt.wantCode == http.StatusUnsupportedMediaType {
+				var je *Error
+				if !errors.As(ReadError(rr.Body), &je) {
+					t.Fatalf("failed to read error")
+				}
+				if got, want := je.Details["supported"], tt.wantSupported; !reflect.DeepEqual(got, want) {
+					t.Errorf("got supported %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
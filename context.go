@@ -0,0 +1,105 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// withContext causes encoding and decoding to be abandoned once ctx is done.
+func withContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// ctxErr returns the error of the context of o, or nil if there is none.
+func (o *options) ctxErr() error {
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Err()
+}
+
+// ctxReader is an io.Reader that fails once ctx is done.
+type ctxReader struct {
+	ctx context.Context //nolint:containedctx
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ctxResponseWriter is an http.ResponseWriter whose writes fail once ctx is done.
+type ctxResponseWriter struct {
+	http.ResponseWriter
+	ctx context.Context //nolint:containedctx
+}
+
+func (w *ctxResponseWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *ctxResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// WriteResponsePageContext writes a status code and JSON response containing data and pd to w, in
+// the same way as WriteResponsePage. If ctx is done before the response is written, writing is
+// abandoned and the context error is returned. When used with WithStream, a response abandoned
+// part way through is truncated.
+func WriteResponsePageContext(ctx context.Context, w http.ResponseWriter, data interface{}, pd *PageDetails, code int, opts ...Option) error {
+	return WriteResponsePage(w, data, pd, code, joinOptions(opts, []Option{withContext(ctx)})...)
+}
+
+// WriteResponseContext writes a status code and JSON response containing data to w, in the same
+// way as WriteResponse. If ctx is done before the response is written, writing is abandoned and
+// the context error is returned.
+func WriteResponseContext(ctx context.Context, w http.ResponseWriter, data interface{}, code int, opts ...Option) error {
+	return WriteResponsePage(w, data, nil, code, joinOptions(opts, []Option{withContext(ctx)})...)
+}
+
+// WriteErrorContext writes a status code and JSON response containing the supplied error message
+// and status code to w, in the same way as WriteError. The identifiers extracted from ctx by the
+// function established by SetContextExtractor are included in the error. If ctx is done before the
+// response is written, writing is abandoned and the context error is returned.
+func WriteErrorContext(ctx context.Context, w http.ResponseWriter, message string, code int, opts ...Option) error {
+	return writeError(w, NewError(message, code), nil, newOptions(joinOptions(opts, []Option{withContext(ctx)})))
+}
+
+// WriteErrContext writes a status code and JSON response describing err to w, in the same way as
+// WriteErr. The identifiers extracted from ctx by the function established by
+// SetContextExtractor are included in the error. If ctx is done before the response is written,
+// writing is abandoned and the context error is returned.
+func WriteErrContext(ctx context.Context, w http.ResponseWriter, err error, opts ...Option) error {
+	o := newOptions(joinOptions(opts, []Option{withContext(ctx)}))
+	if err == nil {
+		return writeError(w, NewError("", http.StatusInternalServerError), nil, o)
+	}
+	return writeError(w, errorFor(err), err, o)
+}
+
+// ReadResponsePageContext reads a paged JSON response, and unmarshals the supplied data, in the
+// same way as ReadResponsePage. If ctx is done before the response has been read, reading is
+// abandoned and an error wrapping the context error is returned.
+func ReadResponsePageContext(ctx context.Context, r io.Reader, v interface{}, opts ...Option) (*PageDetails, error) {
+	return ReadResponsePage(r, v, joinOptions(opts, []Option{withContext(ctx)})...)
+}
+
+// ReadResponseContext reads a JSON response, and unmarshals the supplied data, in the same way as
+// ReadResponse. If ctx is done before the response has been read, reading is abandoned and an
+// error wrapping the context error is returned.
+func ReadResponseContext(ctx context.Context, r io.Reader, v interface{}, opts ...Option) error {
+	_, err := ReadResponsePage(r, v, joinOptions(opts, []Option{withContext(ctx)})...)
+	return err
+}
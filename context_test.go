@@ -0,0 +1,162 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// cancelWriter is an http.ResponseWriter that cancels a context after a number of writes.
+type cancelWriter struct {
+	*httptest.ResponseRecorder
+	cancel context.CancelFunc
+	writes int
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	if w.writes--; w.writes == 0 {
+		w.cancel()
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestWriteResponseContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context //nolint:containedctx
+		opts     []Option
+		wantErr  error
+		wantBody string
+	}{
+		{"Background", context.Background(), nil, nil, `{"data":"blah"}`},
+		{"BackgroundStream", context.Background(), []Option{WithStream()}, nil, `{"data":"blah"}`},
+		{"Canceled", canceled, nil, context.Canceled, ""},
+		{"CanceledStream", canceled, []Option{WithStream()}, context.Canceled, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			err := WriteResponseContext(tt.ctx, rr, "blah", http.StatusOK, tt.opts...)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteResponsePageContextStreamCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &cancelWriter{ResponseRecorder: httptest.NewRecorder(), cancel: cancel, writes: 3}
+
+	data := []string{"a", "b", "c", "d"}
+	err := WriteResponsePageContext(ctx, w, data, &PageDetails{Next: "n"}, http.StatusOK, WithStream())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+	if got := w.Body.String(); strings.Contains(got, `"d"`) {
+		t.Errorf("got body %q, want truncated", got)
+	}
+}
+
+// cancelReader is an io.Reader that cancels a context after a number of reads.
+type cancelReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if r.reads--; r.reads == 0 {
+		r.cancel()
+	}
+	return r.r.Read(p[:1])
+}
+
+func TestReadResponseContext(t *testing.T) {
+	const body = `{"data":"blah","page":{"next":"n"}}`
+
+	t.Run("Background", func(t *testing.T) {
+		var s string
+		pd, err := ReadResponsePageContext(context.Background(), strings.NewReader(body), &s)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if got, want := s, "blah"; got != want {
+			t.Errorf("got data %q, want %q", got, want)
+		}
+		if got, want := pd.Next, "n"; got != want {
+			t.Errorf("got next %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		r := &cancelReader{r: strings.NewReader(body), cancel: cancel, reads: 5}
+
+		var s string
+		if err := ReadResponseContext(ctx, r, &s); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestContextSharedOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(opts []Option) error
+	}{
+		{"WriteResponseContext", func(opts []Option) error {
+			return WriteResponseContext(context.Background(), httptest.NewRecorder(), "a", http.StatusOK, opts...)
+		}},
+		{"WriteResponsePageContext", func(opts []Option) error {
+			return WriteResponsePageContext(context.Background(), httptest.NewRecorder(), "a", nil, http.StatusOK, opts...)
+		}},
+		{"WriteErrorContext", func(opts []Option) error {
+			return WriteErrorContext(context.Background(), httptest.NewRecorder(), "a", http.StatusBadRequest, opts...)
+		}},
+		{"WriteErrContext", func(opts []Option) error {
+			return WriteErrContext(context.Background(), httptest.NewRecorder(), errors.New("a"), opts...)
+		}},
+		{"ReadResponseContext", func(opts []Option) error {
+			var s string
+			return ReadResponseContext(context.Background(), strings.NewReader(`{"data":"a"}`), &s, opts...)
+		}},
+		{"ReadResponsePageContext", func(opts []Option) error {
+			var s string
+			_, err := ReadResponsePageContext(context.Background(), strings.NewReader(`{"data":"a"}`), &s, opts...)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := make([]Option, 0, 2)
+			opts = append(opts, WithMeta("a", 1))
+			if err := tt.f(opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := opts[:cap(opts)][1]; got != nil {
+				t.Error("options modified")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+var (
+	contextExtractorMu sync.RWMutex
+	contextExtractor   func(ctx context.Context) map[string]string
+	contextHeaders     map[string]string
+)
+
+// SetContextExtractor sets the function used to extract identifiers, such as trace, span and
+// tenant IDs, from the context supplied to the context-aware write functions, such as
+// WriteErrContext and WriteResponseContext. The identifiers are included in the Context of an
+// error written, so that errors seen by clients can be correlated with traces. The identifiers
+// named by the keys of headers are also written in the response headers named by the
+// corresponding values, for both errors and successful responses. If f is nil, no identifiers are
+// extracted.
+//
+// For example, to return OpenTelemetry trace IDs:
+//
+//	jsonresp.SetContextExtractor(func(ctx context.Context) map[string]string {
+//		sc := trace.SpanContextFromContext(ctx)
+//		if !sc.IsValid() {
+//			return nil
+//		}
+//		return map[string]string{"traceId": sc.TraceID().String(), "spanId": sc.SpanID().String()}
+//	}, map[string]string{"traceId": "X-Trace-Id"})
+func SetContextExtractor(f func(ctx context.Context) map[string]string, headers map[string]string) {
+	hs := make(map[string]string, len(headers))
+	for k, v := range headers {
+		hs[k] = http.CanonicalHeaderKey(v)
+	}
+
+	contextExtractorMu.Lock()
+	defer contextExtractorMu.Unlock()
+	contextExtractor = f
+	contextHeaders = hs
+}
+
+// contextIDs returns the identifiers extracted from the context of o, or nil if there are none.
+// The extractor is called at most once for o.
+func (o *options) contextIDs() map[string]string {
+	if o.ctxIDsDone || o.ctx == nil {
+		return o.ctxIDs
+	}
+	o.ctxIDsDone = true
+
+	contextExtractorMu.RLock()
+	f := contextExtractor
+	contextExtractorMu.RUnlock()
+
+	if f != nil {
+		o.ctxIDs = f(o.ctx)
+	}
+	return o.ctxIDs
+}
+
+// withContextIDs adds the identifiers extracted from the context of o to je. Identifiers already
+// present in je are retained.
+func (o *options) withContextIDs(je *Error) *Error {
+	ids := o.contextIDs()
+	if len(ids) == 0 {
+		return je
+	}
+
+	c := *je
+	c.Context = make(map[string]string, len(ids)+len(je.Context))
+	for k, v := range ids {
+		c.Context[k] = v
+	}
+	for k, v := range je.Context {
+		c.Context[k] = v
+	}
+	return &c
+}
+
+// setContextHeaders sets the response headers of h established by SetContextExtractor to the
+// identifiers extracted from the context of o.
+func (o *options) setContextHeaders(h http.Header) {
+	ids := o.contextIDs()
+	if len(ids) == 0 {
+		return
+	}
+
+	contextExtractorMu.RLock()
+	defer contextExtractorMu.RUnlock()
+
+	for k, name := range contextHeaders {
+		if v := ids[k]; v != "" {
+			h.Set(name, v)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type traceIDKey struct{}
+
+func TestSetContextExtractor(t *testing.T) {
+	SetContextExtractor(func(ctx context.Context) map[string]string {
+		id, _ := ctx.Value(traceIDKey{}).(string)
+		if id == "" {
+			return nil
+		}
+		return map[string]string{"traceId": id, "tenantId": "acme"}
+	}, map[string]string{"traceId": "x-trace-id"})
+	defer SetContextExtractor(nil, nil)
+
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "abc123")
+
+	tests := []struct {
+		name       string
+		write      func(w http.ResponseWriter) error
+		wantHeader string
+		wantBody   string
+	}{
+		{
+			name: "ErrContext",
+			write: func(w http.ResponseWriter) error {
+				return WriteErrContext(ctx, w, NewError("blah", http.StatusNotFound))
+			},
+			wantHeader: "abc123",
+			wantBody:   `{"error":{"code":404,"message":"blah","context":{"tenantId":"acme","traceId":"abc123"}}}`,
+		},
+		{
+			name: "ErrContextNil",
+			write: func(w http.ResponseWriter) error {
+				return WriteErrContext(ctx, w, nil)
+			},
+			wantHeader: "abc123",
+			wantBody:   `{"error":{"code":500,"context":{"tenantId":"acme","traceId":"abc123"}}}`,
+		},
+		{
+			name: "ErrorContext",
+			write: func(w http.ResponseWriter) error {
+				return WriteErrorContext(ctx, w, "blah", http.StatusServiceUnavailable)
+			},
+			wantHeader: "abc123",
+			wantBody:   `{"error":{"code":503,"message":"blah","context":{"tenantId":"acme","traceId":"abc123"}}}`,
+		},
+		{
+			name: "Retained",
+			write: func(w http.ResponseWriter) error {
+				je := &Error{Code: http.StatusConflict, Context: map[string]string{"tenantId": "other"}}
+				return WriteErrContext(ctx, w, je)
+			},
+			wantHeader: "abc123",
+			wantBody:   `{"error":{"code":409,"context":{"tenantId":"other","traceId":"abc123"}}}`,
+		},
+		{
+			name: "Response",
+			write: func(w http.ResponseWriter) error {
+				return WriteResponseContext(ctx, w, "blah", http.StatusOK)
+			},
+			wantHeader: "abc123",
+			wantBody:   `{"data":"blah"}`,
+		},
+		{
+			name: "NoIdentifiers",
+			write: func(w http.ResponseWriter) error {
+				return WriteErrContext(context.Background(), w, errors.New("blah"))
+			},
+			wantBody: `{"error":{"code":500,"message":"blah"}}`,
+		},
+		{
+			name: "NoContext",
+			write: func(w http.ResponseWriter) error {
+				return WriteErr(w, errors.New("blah"))
+			},
+			wantBody: `{"error":{"code":500,"message":"blah"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Header().Get("X-Trace-Id"), tt.wantHeader; got != want {
+				t.Errorf("got header %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadErrorContext(t *testing.T) {
+	rr := httptest.NewRecorder()
+	je := &Error{Code: http.StatusNotFound, Context: map[string]string{"traceId": "abc123"}}
+	if err := WriteErr(rr, je); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	var got *Error
+	if err := ReadError(rr.Body); !errors.As(err, &got) {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	if want := je.Context; !reflect.DeepEqual(got.Context, want) {
+		t.Errorf("got context %v, want %v", got.Context, want)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build !go1.20
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// flushResponse flushes buffered data to the client of w, if it, or a writer it wraps, implements
+// http.Flusher.
+func flushResponse(w http.ResponseWriter) error {
+	for {
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+			return nil
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
+
+// errNotSupported is returned by setWriteDeadline, as write deadlines are not supported prior to
+// Go 1.20.
+var errNotSupported = errors.New("feature not supported")
+
+// setWriteDeadline returns errNotSupported, as write deadlines are not supported prior to Go 1.20.
+func setWriteDeadline(http.ResponseWriter, time.Time) error {
+	return errNotSupported
+}
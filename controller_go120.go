@@ -0,0 +1,30 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build go1.20
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// flushResponse flushes buffered data to the client of w, using an http.ResponseController so
+// that wrapped writers implementing Unwrap are supported. If w does not support flushing, nil is
+// returned.
+func flushResponse(w http.ResponseWriter) error {
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// setWriteDeadline sets the write deadline of w to t, using an http.ResponseController. If w does
+// not support write deadlines, an error wrapping http.ErrNotSupported is returned.
+func setWriteDeadline(w http.ResponseWriter, t time.Time) error {
+	return http.NewResponseController(w).SetWriteDeadline(t)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build go1.20
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// unwrapWriter is an http.ResponseWriter that wraps another, without implementing http.Flusher.
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestFlushResponseUnwrap(t *testing.T) {
+	fr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	pw := NewProgressWriter(unwrapWriter{fr})
+	if err := pw.Update(Progress{Percent: 50}); err != nil {
+		t.Fatalf("failed to write progress: %v", err)
+	}
+	if got, want := len(fr.flushes), 1; got != want {
+		t.Errorf("got %v flushes, want %v", got, want)
+	}
+}
+
+// deadlineRecorder is an http.ResponseWriter that records the write deadlines set on it, and
+// whose writes optionally fail as if the deadline had passed.
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+	fail      bool
+}
+
+func (dr *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	dr.deadlines = append(dr.deadlines, t)
+	return nil
+}
+
+func (dr *deadlineRecorder) Write(p []byte) (int, error) {
+	if dr.fail {
+		return 0, &net.OpError{Op: "write", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	}
+	return dr.ResponseRecorder.Write(p)
+}
+
+func TestWithWriteTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		fail          bool
+		wantDeadlines int
+		wantErr       error
+	}{
+		{"None", nil, false, 0, nil},
+		{"Buffered", []Option{WithWriteTimeout(time.Minute)}, false, 1, nil},
+		{"BufferedTimeout", []Option{WithWriteTimeout(time.Minute)}, true, 1, ErrWriteTimeout},
+		{"Stream", []Option{WithWriteTimeout(time.Minute), WithStream()}, false, 1, nil},
+		{"StreamTimeout", []Option{WithWriteTimeout(time.Minute), WithStream()}, true, 1, ErrWriteTimeout},
+		{"StreamContextTimeout", []Option{WithWriteTimeout(time.Minute), WithStream(), withContext(context.Background())}, true, 1, ErrWriteTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dr := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder(), fail: tt.fail}
+
+			start := time.Now()
+			err := WriteResponse(dr, []int{1, 2, 3}, http.StatusOK, tt.opts...)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got, want := len(dr.deadlines), tt.wantDeadlines; got != want {
+				t.Fatalf("got %v deadlines, want %v", got, want)
+			}
+			if len(dr.deadlines) == 0 {
+				return
+			}
+			if d := dr.deadlines[0]; d.Before(start.Add(time.Minute)) || d.After(time.Now().Add(time.Minute)) {
+				t.Errorf("got deadline %v, want a minute from now", d)
+			}
+		})
+	}
+}
+
+func TestWithWriteTimeoutProgress(t *testing.T) {
+	dr := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+
+	pw := NewProgressWriter(dr, WithWriteTimeout(time.Minute))
+	if err := pw.Update(Progress{Percent: 50}); err != nil {
+		t.Fatalf("failed to write progress: %v", err)
+	}
+	if err := pw.WriteResponse("blah"); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	// The deadline is extended for each line, and never cleared, which would remove that of the
+	// server.
+	if got, want := len(dr.deadlines), 2; got != want {
+		t.Fatalf("got %v deadlines, want %v", got, want)
+	}
+	for _, d := range dr.deadlines {
+		if d.IsZero() {
+			t.Error("deadline cleared")
+		}
+	}
+
+	dr.fail = true
+	pw = NewProgressWriter(dr, WithWriteTimeout(time.Minute))
+	if err := pw.Update(Progress{}); !errors.Is(err, ErrWriteTimeout) {
+		t.Errorf("got error %v, want %v", err, ErrWriteTimeout)
+	}
+}
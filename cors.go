@@ -0,0 +1,212 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS describes the cross-origin requests permitted by a server, per the Fetch standard.
+type CORS struct {
+	// AllowedOrigins are the origins, such as "https://example.com", from which requests are
+	// permitted. The origin "*" permits requests from any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods are the methods permitted in addition to GET, HEAD and POST, which are always
+	// permitted.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers permitted. The header "*" permits any header. If
+	// empty, only Content-Type is permitted, so that JSON request bodies may be sent.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers that clients are permitted to read, in addition to
+	// the headers that are always exposed, such as Content-Type.
+	ExposedHeaders []string
+
+	// AllowCredentials permits requests to include credentials, such as cookies. Credentials are
+	// only permitted from the origins listed in AllowedOrigins, and not from those permitted by
+	// "*", as that would allow any site to make credentialed requests.
+	AllowCredentials bool
+
+	// MaxAge is the duration for which the result of a preflight request may be cached. If zero,
+	// the default of the client applies, and if negative, the result is not cached.
+	MaxAge time.Duration
+}
+
+// defaultAllowedHeaders are the request headers permitted if CORS.AllowedHeaders is empty.
+var defaultAllowedHeaders = []string{"Content-Type"}
+
+// safelistedMethods are the methods that are always permitted in cross-origin requests.
+var safelistedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+
+// anyOrigin reports whether c permits requests from any origin.
+func (c *CORS) anyOrigin() bool {
+	return contains(c.AllowedOrigins, "*")
+}
+
+// allowsOrigin reports whether c permits requests from origin.
+func (c *CORS) allowsOrigin(origin string) bool {
+	return origin != "" && (c.anyOrigin() || contains(c.AllowedOrigins, origin))
+}
+
+// allowsMethod reports whether c permits requests with method.
+func (c *CORS) allowsMethod(method string) bool {
+	return contains(safelistedMethods, method) || contains(c.AllowedMethods, method)
+}
+
+// allowsHeaders reports whether c permits requests with the headers named by the
+// Access-Control-Request-Headers value names.
+func (c *CORS) allowsHeaders(names string) bool {
+	allowed := c.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = defaultAllowedHeaders
+	}
+	if contains(allowed, "*") {
+		return true
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		ok := false
+		for _, a := range allowed {
+			if strings.EqualFold(a, name) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// allowsCredentials reports whether c permits requests from origin to include credentials.
+func (c *CORS) allowsCredentials(origin string) bool {
+	return c.AllowCredentials && origin != "*" && contains(c.AllowedOrigins, origin)
+}
+
+// vary reports whether the CORS headers of a response depend on the Origin header of the request.
+func (c *CORS) vary() bool {
+	return !c.anyOrigin() || c.AllowCredentials && len(c.AllowedOrigins) > 1
+}
+
+// responseHeaders returns the headers permitting a request from origin to read the response, or
+// nil if requests from origin are not permitted.
+func (c *CORS) responseHeaders(origin string) http.Header {
+	if !c.allowsOrigin(origin) {
+		return nil
+	}
+
+	h := make(http.Header)
+	credentials := c.allowsCredentials(origin)
+	if c.anyOrigin() && !credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(c.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+	}
+	return h
+}
+
+// WithCORS causes the headers permitting the cross-origin request r to read the response to be
+// written, as permitted by c. Responses written within a CORSHandler have them already, so
+// WithCORS is needed only for responses written outside one, such as by middleware that precedes
+// it. If r is not a cross-origin request permitted by c, no CORS headers are written.
+func WithCORS(r *http.Request, c CORS) Option {
+	return func(o *options) {
+		if c.vary() {
+			o.vary = append(o.vary, "Origin")
+		}
+		for k, v := range c.responseHeaders(r.Header.Get("Origin")) {
+			WithHeader(k, v[0])(o)
+		}
+	}
+}
+
+// CORSHandler is an http.Handler that permits cross-origin requests to Handler, as described by
+// CORS. Preflight requests are answered directly, with a 204 status code if the request is
+// permitted, or a 403 JSON error response otherwise. Other requests are served by Handler, with
+// the CORS headers set beforehand, so that they are present on every response, including error
+// responses written by this package, or by http.Error, that browsers would otherwise hide from
+// the client.
+type CORSHandler struct {
+	// Handler serves requests.
+	Handler http.Handler
+
+	// CORS describes the cross-origin requests permitted.
+	CORS CORS
+
+	// Options are the options with which errors are written, in addition to WithRequest.
+	Options []Option
+}
+
+// ServeHTTP serves the request r.
+func (ch *CORSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if r.Method == http.MethodOptions && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+		ch.preflight(w, r)
+		return
+	}
+
+	h := w.Header()
+	if ch.CORS.vary() {
+		addVary(h, "Origin")
+	}
+	for k, v := range ch.CORS.responseHeaders(origin) {
+		h[k] = v
+	}
+	ch.Handler.ServeHTTP(w, r)
+}
+
+// preflight answers the preflight request r.
+func (ch *CORSHandler) preflight(w http.ResponseWriter, r *http.Request) {
+	c := &ch.CORS
+	h := w.Header()
+	if c.vary() {
+		addVary(h, "Origin")
+	}
+	addVary(h, "Access-Control-Request-Method")
+	addVary(h, "Access-Control-Request-Headers")
+
+	method := r.Header.Get("Access-Control-Request-Method")
+	headers := r.Header.Get("Access-Control-Request-Headers")
+	if !c.allowsOrigin(r.Header.Get("Origin")) || !c.allowsMethod(method) || !c.allowsHeaders(headers) {
+		opts := append([]Option{WithRequest(r)}, ch.Options...)
+		_ = WriteError(w, "cross-origin request not permitted", http.StatusForbidden, opts...)
+		return
+	}
+
+	for k, v := range c.responseHeaders(r.Header.Get("Origin")) {
+		h[k] = v
+	}
+	h.Del("Access-Control-Expose-Headers")
+	if len(c.AllowedMethods) > 0 {
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+	}
+	if headers != "" {
+		// Echoing the requested headers avoids "*", which clients ignore with credentials.
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	if c.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	} else if c.MaxAge < 0 {
+		h.Set("Access-Control-Max-Age", "0")
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,285 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSHandler(t *testing.T) {
+	cors := CORS{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedMethods: []string{http.MethodPut, http.MethodDelete},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{"Retry-After"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := []struct {
+		name        string
+		cors        CORS
+		method      string
+		header      http.Header
+		wantCode    int
+		wantHeader  http.Header
+		wantAbsent  []string
+		wantHandled bool
+	}{
+		{
+			name:        "SameOrigin",
+			cors:        cors,
+			method:      http.MethodGet,
+			wantCode:    http.StatusNotFound,
+			wantHeader:  http.Header{"Vary": {"Origin"}},
+			wantAbsent:  []string{"Access-Control-Allow-Origin"},
+			wantHandled: true,
+		},
+		{
+			name:     "Error",
+			cors:     cors,
+			method:   http.MethodGet,
+			header:   http.Header{"Origin": {"https://example.com"}},
+			wantCode: http.StatusNotFound,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin":   {"https://example.com"},
+				"Access-Control-Expose-Headers": {"Retry-After"},
+				"Vary":                          {"Origin"},
+			},
+			wantAbsent:  []string{"Access-Control-Allow-Credentials"},
+			wantHandled: true,
+		},
+		{
+			name:        "DisallowedOrigin",
+			cors:        cors,
+			method:      http.MethodGet,
+			header:      http.Header{"Origin": {"https://example.org"}},
+			wantCode:    http.StatusNotFound,
+			wantHeader:  http.Header{"Vary": {"Origin"}},
+			wantAbsent:  []string{"Access-Control-Allow-Origin"},
+			wantHandled: true,
+		},
+		{
+			name:        "AnyOrigin",
+			cors:        CORS{AllowedOrigins: []string{"*"}},
+			method:      http.MethodGet,
+			header:      http.Header{"Origin": {"https://example.org"}},
+			wantCode:    http.StatusNotFound,
+			wantHeader:  http.Header{"Access-Control-Allow-Origin": {"*"}},
+			wantAbsent:  []string{"Vary"},
+			wantHandled: true,
+		},
+		{
+			name:        "AnyOriginCredentials",
+			cors:        CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+			method:      http.MethodGet,
+			header:      http.Header{"Origin": {"https://example.org"}},
+			wantCode:    http.StatusNotFound,
+			wantHeader:  http.Header{"Access-Control-Allow-Origin": {"*"}},
+			wantAbsent:  []string{"Access-Control-Allow-Credentials", "Vary"},
+			wantHandled: true,
+		},
+		{
+			name:     "AnyOriginCredentialsListed",
+			cors:     CORS{AllowedOrigins: []string{"*", "https://example.com"}, AllowCredentials: true},
+			method:   http.MethodGet,
+			header:   http.Header{"Origin": {"https://example.com"}},
+			wantCode: http.StatusNotFound,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin":      {"https://example.com"},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Vary":                             {"Origin"},
+			},
+			wantHandled: true,
+		},
+		{
+			name:        "AnyOriginCredentialsUnlisted",
+			cors:        CORS{AllowedOrigins: []string{"*", "https://example.com"}, AllowCredentials: true},
+			method:      http.MethodGet,
+			header:      http.Header{"Origin": {"https://example.org"}},
+			wantCode:    http.StatusNotFound,
+			wantHeader:  http.Header{"Access-Control-Allow-Origin": {"*"}, "Vary": {"Origin"}},
+			wantAbsent:  []string{"Access-Control-Allow-Credentials"},
+			wantHandled: true,
+		},
+		{
+			name:        "OptionsNotPreflight",
+			cors:        cors,
+			method:      http.MethodOptions,
+			header:      http.Header{"Origin": {"https://example.com"}},
+			wantCode:    http.StatusNotFound,
+			wantHeader:  http.Header{"Access-Control-Allow-Origin": {"https://example.com"}},
+			wantHandled: true,
+		},
+		{
+			name:   "Preflight",
+			cors:   cors,
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                         {"https://example.com"},
+				"Access-Control-Request-Method":  {http.MethodPut},
+				"Access-Control-Request-Headers": {"authorization,content-type"},
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin":  {"https://example.com"},
+				"Access-Control-Allow-Methods": {"PUT, DELETE"},
+				"Access-Control-Allow-Headers": {"authorization,content-type"},
+				"Access-Control-Max-Age":       {"600"},
+				"Vary":                         {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+			},
+			wantAbsent: []string{"Access-Control-Expose-Headers"},
+		},
+		{
+			name:   "PreflightDefaults",
+			cors:   CORS{AllowedOrigins: []string{"*"}, MaxAge: -1},
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                         {"https://example.com"},
+				"Access-Control-Request-Method":  {http.MethodPost},
+				"Access-Control-Request-Headers": {"Content-Type"},
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Access-Control-Allow-Origin":  {"*"},
+				"Access-Control-Allow-Headers": {"Content-Type"},
+				"Access-Control-Max-Age":       {"0"},
+			},
+			wantAbsent: []string{"Access-Control-Allow-Methods"},
+		},
+		{
+			name:   "PreflightAnyHeader",
+			cors:   CORS{AllowedOrigins: []string{"*"}, AllowedHeaders: []string{"*"}},
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                         {"https://example.com"},
+				"Access-Control-Request-Method":  {http.MethodGet},
+				"Access-Control-Request-Headers": {"X-Blah"},
+			},
+			wantCode:   http.StatusNoContent,
+			wantHeader: http.Header{"Access-Control-Allow-Headers": {"X-Blah"}},
+			wantAbsent: []string{"Access-Control-Max-Age"},
+		},
+		{
+			name:   "PreflightDisallowedOrigin",
+			cors:   cors,
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                        {"https://example.org"},
+				"Access-Control-Request-Method": {http.MethodPut},
+			},
+			wantCode:   http.StatusForbidden,
+			wantAbsent: []string{"Access-Control-Allow-Origin"},
+		},
+		{
+			name:   "PreflightDisallowedMethod",
+			cors:   cors,
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                        {"https://example.com"},
+				"Access-Control-Request-Method": {http.MethodPatch},
+			},
+			wantCode:   http.StatusForbidden,
+			wantAbsent: []string{"Access-Control-Allow-Origin"},
+		},
+		{
+			name:   "PreflightDisallowedHeader",
+			cors:   cors,
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                         {"https://example.com"},
+				"Access-Control-Request-Method":  {http.MethodPut},
+				"Access-Control-Request-Headers": {"Authorization, X-Blah"},
+			},
+			wantCode:   http.StatusForbidden,
+			wantAbsent: []string{"Access-Control-Allow-Origin"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			handled := false
+			ch := &CORSHandler{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					handled = true
+					_ = WriteError(w, "not found", http.StatusNotFound)
+				}),
+				CORS: tt.cors,
+			}
+
+			r := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			rr := httptest.NewRecorder()
+
+			ch.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := handled, tt.wantHandled; got != want {
+				t.Errorf("got handled %v, want %v", got, want)
+			}
+			for k, want := range tt.wantHeader {
+				if got := rr.Header().Values(k); strings.Join(got, ",") != strings.Join(want, ",") {
+					t.Errorf("got %v header %q, want %q", k, got, want)
+				}
+			}
+			for _, k := range tt.wantAbsent {
+				if got := rr.Header().Values(k); len(got) != 0 {
+					t.Errorf("got %v header %q, want none", k, got)
+				}
+			}
+			if rr.Code == http.StatusForbidden {
+				if got, want := rr.Body.String(), `{"error":{"code":403,"message":"cross-origin request not permitted"}}`; got != want {
+					t.Errorf("got body %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithCORS(t *testing.T) {
+	cors := CORS{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true}
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantOrigin string
+	}{
+		{"NoOrigin", "", ""},
+		{"Allowed", "https://example.com", "https://example.com"},
+		{"Disallowed", "https://example.org", ""},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Vary", "Accept")
+
+			if err := WriteError(rr, "blah", http.StatusUnauthorized, WithCORS(r, cors)); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Header().Get("Access-Control-Allow-Origin"), tt.wantOrigin; got != want {
+				t.Errorf("got origin %q, want %q", got, want)
+			}
+			if got, want := strings.Join(rr.Header().Values("Vary"), ","), "Accept,Origin"; got != want {
+				t.Errorf("got vary %q, want %q", got, want)
+			}
+		})
+	}
+}
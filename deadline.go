@@ -0,0 +1,61 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// ErrWriteTimeout is returned by the write functions when the deadline established by
+// WithWriteTimeout passes before the response is written.
+var ErrWriteTimeout = errors.New("jsonresp: write deadline exceeded")
+
+// WithWriteTimeout sets a deadline of d from the time the response body begins to be written, by
+// which the response must be written to the client. If the deadline passes, writes to the client
+// fail, and the write functions return an error wrapping ErrWriteTimeout, so that a slow client
+// cannot occupy the handler indefinitely. For a ProgressWriter, the deadline is extended before
+// each line is written. The deadline remains in place once the response is written, as the
+// deadline it replaces, such as that of http.Server.WriteTimeout, cannot be restored.
+//
+// The deadline is established using http.ResponseController, so WithWriteTimeout has no effect
+// when built with Go versions prior to 1.20, or when the http.ResponseWriter does not support
+// write deadlines.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = d
+	}
+}
+
+// withWriteDeadline sets the write deadline established by o on w, if any. It returns a writer
+// that reports a write that fails due to the deadline as ErrWriteTimeout.
+func (o *options) withWriteDeadline(w http.ResponseWriter) http.ResponseWriter {
+	if o.writeTimeout <= 0 {
+		return w
+	}
+	if err := setWriteDeadline(w, time.Now().Add(o.writeTimeout)); err != nil {
+		return w
+	}
+	return &deadlineResponseWriter{w}
+}
+
+// deadlineResponseWriter is an http.ResponseWriter whose writes fail with ErrWriteTimeout once
+// the write deadline of the underlying http.ResponseWriter has passed.
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *deadlineResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = ErrWriteTimeout
+	}
+	return n, err
+}
+
+func (w *deadlineResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
@@ -0,0 +1,68 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// DebugInfo contains diagnostic information included in error responses in debug mode.
+type DebugInfo struct {
+	// Stack is the stack trace of the goroutine that wrote the error, starting at the caller of
+	// the write function.
+	Stack []string `json:"stack,omitempty"`
+
+	// Chain describes each error in the wrapped error chain, outermost first.
+	Chain []string `json:"chain,omitempty"`
+}
+
+var debug int32
+
+// SetDebug enables or disables debug mode. In debug mode, error responses written by WriteError,
+// WriteErr and related functions include a stack trace and a description of the wrapped error
+// chain. Debug mode may leak sensitive information to clients, and should not be enabled in
+// production.
+func SetDebug(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&debug, v)
+}
+
+func isDebug() bool {
+	return atomic.LoadInt32(&debug) != 0
+}
+
+// callers returns a description of the stack of the calling goroutine. The argument skip is the
+// number of stack frames to skip, with 0 identifying the caller of callers.
+func callers(skip int) []string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []string
+	for {
+		f, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%v (%v:%v)", f.Function, f.File, f.Line))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// chain returns a description of each error in the chain of err, outermost first.
+func chain(err error) []string {
+	var c []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		c = append(c, fmt.Sprintf("%T: %v", err, err))
+	}
+	return c
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	errBase := errors.New("base")
+
+	tests := []struct {
+		name      string
+		debug     bool
+		write     func(w http.ResponseWriter) error
+		wantDebug bool
+		wantChain []string
+	}{
+		{"Disabled", false, func(w http.ResponseWriter) error {
+			return WriteError(w, "blah", http.StatusInternalServerError)
+		}, false, nil},
+		{"WriteError", true, func(w http.ResponseWriter) error {
+			return WriteError(w, "blah", http.StatusInternalServerError)
+		}, true, nil},
+		{"WriteErr", true, func(w http.ResponseWriter) error {
+			return WriteErr(w, fmt.Errorf("wrapped: %w", errBase))
+		}, true, []string{"*fmt.wrapError: wrapped: base", "*errors.errorString: base"}},
+		{"WriteErrorf", true, func(w http.ResponseWriter) error {
+			return WriteErrorf(w, http.StatusInternalServerError, "wrapped: %w", errBase)
+		}, true, []string{"*fmt.wrapError: wrapped: base", "*errors.errorString: base"}},
+		{"WriteErrorFrom", true, func(w http.ResponseWriter) error {
+			return WriteErrorFrom(w, fmt.Errorf("wrapped: %w", errBase), http.StatusBadGateway)
+		}, true, []string{"*fmt.wrapError: wrapped: base", "*errors.errorString: base"}},
+		{"ProgressWriter", true, func(w http.ResponseWriter) error {
+			return NewProgressWriter(w).WriteErr(fmt.Errorf("wrapped: %w", errBase))
+		}, true, []string{"*fmt.wrapError: wrapped: base", "*errors.errorString: base"}},
+		{"WriteErrorfNoWrap", true, func(w http.ResponseWriter) error {
+			return WriteErrorf(w, http.StatusInternalServerError, "wrapped: %v", errBase)
+		}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetDebug(tt.debug)
+			defer SetDebug(false)
+
+			rr := httptest.NewRecorder()
+
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			var je *Error
+			if !errors.As(ReadError(rr.Body), &je) {
+				t.Fatalf("failed to read error")
+			}
+
+			if got, want := je.Debug != nil, tt.wantDebug; got != want {
+				t.Fatalf("got debug %v, want %v", got, want)
+			}
+			if je.Debug == nil {
+				return
+			}
+
+			if len(je.Debug.Stack) == 0 {
+				t.Fatalf("got empty stack")
+			}
+			if got, want := je.Debug.Stack[0], "TestDebug"; !strings.Contains(got, want) {
+				t.Errorf("got first frame %q, want it to contain %q", got, want)
+			}
+			if got, want := je.Debug.Chain, tt.wantChain; !reflect.DeepEqual(got, want) {
+				t.Errorf("got chain %v, want %v", got, want)
+			}
+		})
+	}
+}
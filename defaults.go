@@ -0,0 +1,56 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+// Defaults configures the behaviour of the write and read functions for an entire program, so
+// that existing calls benefit from options without being rewritten. Options supplied to a call
+// take precedence over the defaults.
+type Defaults struct {
+	// Prefix and Indent establish the indentation of responses, as by SetIndent.
+	Prefix string
+	Indent string
+
+	// Codec, if non-nil, is used to encode and decode responses, as by WithCodec.
+	Codec Codec
+
+	// ContentType, if not empty, is the Content-Type of responses, as by WithContentType.
+	ContentType string
+
+	// Debug enables debug mode, as by SetDebug.
+	Debug bool
+
+	// Hooks are added to the response hooks, as by AddResponseHook.
+	Hooks []ResponseHook
+
+	// Options are applied to every call, before the options supplied to it. Options that accept a
+	// request, such as WithRequest, should not be used.
+	Options []Option
+}
+
+// SetDefaults establishes d as the defaults of the write and read functions, replacing any
+// indentation, options and debug mode established previously. The hooks of d are added to those
+// established previously, so SetDefaults is intended to be called once, during initialization.
+func SetDefaults(d Defaults) {
+	var opts []Option
+	if d.Codec != nil {
+		opts = append(opts, WithCodec(d.Codec))
+	}
+	if d.ContentType != "" {
+		opts = append(opts, WithContentType(d.ContentType))
+	}
+	opts = append(opts, d.Options...)
+
+	defaultsMu.Lock()
+	indentPrefix = d.Prefix
+	indentIndent = d.Indent
+	defaultOpts = opts
+	defaultsMu.Unlock()
+
+	SetDebug(d.Debug)
+	for _, h := range d.Hooks {
+		AddResponseHook(h)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countingCodec is a Codec that counts the values it marshals and unmarshals.
+type countingCodec struct {
+	marshals, unmarshals int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshals++
+	return json.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshals++
+	return json.Unmarshal(data, v)
+}
+
+func TestSetDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults Defaults
+		write    func(w http.ResponseWriter) error
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "None",
+			write:    func(w http.ResponseWriter) error { return WriteResponse(w, "blah", http.StatusOK) },
+			wantType: "application/json",
+			wantBody: `{"data":"blah"}`,
+		},
+		{
+			name:     "Indent",
+			defaults: Defaults{Indent: "\t"},
+			write:    func(w http.ResponseWriter) error { return WriteError(w, "blah", http.StatusNotFound) },
+			wantType: "application/json",
+			wantBody: "{\n\t\"error\": {\n\t\t\"code\": 404,\n\t\t\"message\": \"blah\"\n\t}\n}",
+		},
+		{
+			name:     "IndentOverridden",
+			defaults: Defaults{Indent: "\t"},
+			write: func(w http.ResponseWriter) error {
+				return WriteResponse(w, "blah", http.StatusOK, WithIndent("", ""))
+			},
+			wantType: "application/json",
+			wantBody: `{"data":"blah"}`,
+		},
+		{
+			name:     "ContentType",
+			defaults: Defaults{ContentType: "application/vnd.example+json"},
+			write:    func(w http.ResponseWriter) error { return WriteError(w, "blah", http.StatusNotFound) },
+			wantType: "application/vnd.example+json",
+			wantBody: `{"error":{"code":404,"message":"blah"}}`,
+		},
+		{
+			name: "Hooks",
+			defaults: Defaults{Hooks: []ResponseHook{func(_ *http.Request, jr *Response) {
+				jr.Meta = map[string]interface{}{"version": "1"}
+			}}},
+			write:    func(w http.ResponseWriter) error { return WriteResponse(w, "blah", http.StatusOK) },
+			wantType: "application/json",
+			wantBody: `{"data":"blah","meta":{"version":"1"}}`,
+		},
+		{
+			name:     "Options",
+			defaults: Defaults{Options: []Option{WithMeta("version", "1")}},
+			write: func(w http.ResponseWriter) error {
+				return WriteResponse(w, "blah", http.StatusOK, WithMeta("region", "eu"))
+			},
+			wantType: "application/json",
+			wantBody: `{"data":"blah","meta":{"region":"eu","version":"1"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			withHooks(t)
+			SetDefaults(tt.defaults)
+			defer SetDefaults(Defaults{})
+
+			rr := httptest.NewRecorder()
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Header().Get("Content-Type"), tt.wantType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSetDefaultsCodec(t *testing.T) {
+	c := &countingCodec{}
+	SetDefaults(Defaults{Codec: c})
+	defer SetDefaults(Defaults{})
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponse(rr, map[string]int{"a": 1}, http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	var v map[string]int
+	if err := ReadResponse(rr.Body, &v); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+
+	if c.marshals == 0 {
+		t.Error("codec not used to marshal")
+	}
+	if c.unmarshals == 0 {
+		t.Error("codec not used to unmarshal")
+	}
+	if got, want := v["a"], 1; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSetDefaultsDebug(t *testing.T) {
+	SetDefaults(Defaults{Debug: true})
+	defer SetDefaults(Defaults{})
+
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusInternalServerError); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if !strings.Contains(rr.Body.String(), `"debug":`) {
+		t.Errorf("got body %q, want debug information", rr.Body.String())
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WarningDeprecated is the code of the warning added to responses by WithDeprecation.
+const WarningDeprecated = "DEPRECATED"
+
+// Deprecation describes the deprecation of a resource.
+type Deprecation struct {
+	// Since is the time at which the resource was, or will be, deprecated. If zero, the resource
+	// is deprecated without a known date.
+	Since time.Time
+
+	// Sunset is the time after which the resource is expected to become unavailable. If zero, no
+	// sunset is announced.
+	Sunset time.Time
+
+	// Link is the URL of documentation describing the deprecation, if any.
+	Link string
+
+	// Message is the message of the warning added to the response. If empty, a message describing
+	// the deprecation is generated.
+	Message string
+}
+
+// message returns the message of the warning describing d.
+func (d Deprecation) message() string {
+	if d.Message != "" {
+		return d.Message
+	}
+	if !d.Sunset.IsZero() {
+		return "this resource is deprecated, and will be removed after " + d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	return "this resource is deprecated"
+}
+
+// WithDeprecation marks the response as describing the deprecated resource d. The Deprecation
+// header (RFC 9745) and, if d specifies a sunset, the Sunset header (RFC 8594) are written, along
+// with a Link header referring to the documentation of d, if any, in addition to any Link headers
+// already set. A warning with code WarningDeprecated is added to the response envelope. Clients can
+// detect the deprecation using ParseDeprecation. Typically, the same option is supplied to every
+// response written by the handler of a deprecated route.
+func WithDeprecation(d Deprecation) Option {
+	return func(o *options) {
+		if d.Since.IsZero() {
+			WithHeader("Deprecation", "true")(o)
+		} else {
+			WithHeader("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))(o)
+		}
+		if !d.Sunset.IsZero() {
+			WithHeader("Sunset", d.Sunset.UTC().Format(http.TimeFormat))(o)
+		}
+		if d.Link != "" {
+			o.linkHeader = append(o.linkHeader, "<"+d.Link+`>; rel="deprecation"`)
+		}
+		WithWarning(WarningDeprecated, d.message())(o)
+	}
+}
+
+// ParseDeprecation parses the deprecation described by the headers of a response, and returns
+// false if the response does not describe a deprecated resource. The Message of the returned
+// Deprecation is not populated; it is available as the warning with code WarningDeprecated in the
+// response envelope, as returned by WithEnvelope.
+func ParseDeprecation(h http.Header) (Deprecation, bool) {
+	v := strings.TrimSpace(h.Get("Deprecation"))
+	if v == "" || v == "false" {
+		return Deprecation{}, false
+	}
+
+	var d Deprecation
+	if s := strings.TrimPrefix(v, "@"); s != v {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			d.Since = time.Unix(n, 0).UTC()
+		}
+	} else if t, err := http.ParseTime(v); err == nil {
+		// Earlier drafts of RFC 9745 used an HTTP date.
+		d.Since = t
+	}
+	if t, err := http.ParseTime(strings.TrimSpace(h.Get("Sunset"))); err == nil {
+		d.Sunset = t
+	}
+	d.Link = linkWithRelation(h, "deprecation")
+	return d, true
+}
+
+// linkWithRelation returns the target of the first link in the Link headers of h with relation
+// rel, or an empty string if there is none.
+func linkWithRelation(h http.Header, rel string) string {
+	for _, v := range h.Values("Link") {
+		for _, l := range strings.Split(v, ",") {
+			target, params, _ := strings.Cut(strings.TrimSpace(l), ";")
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(p, "=")
+				if strings.TrimSpace(k) != "rel" {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(strings.TrimSpace(v), `"`)) {
+					if strings.EqualFold(r, rel) {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithDeprecation(t *testing.T) {
+	since := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		d           Deprecation
+		wantHeader  http.Header
+		wantWarning Warning
+	}{
+		{"Unspecified", Deprecation{}, http.Header{
+			"Deprecation": {"true"},
+		}, Warning{WarningDeprecated, "this resource is deprecated"}},
+		{"Since", Deprecation{Since: since}, http.Header{
+			"Deprecation": {"@1609459200"},
+		}, Warning{WarningDeprecated, "this resource is deprecated"}},
+		{"Sunset", Deprecation{Since: since, Sunset: sunset}, http.Header{
+			"Deprecation": {"@1609459200"},
+			"Sunset":      {"Thu, 01 Jul 2021 00:00:00 GMT"},
+		}, Warning{WarningDeprecated, "this resource is deprecated, and will be removed after Thu, 01 Jul 2021 00:00:00 GMT"}},
+		{"All", Deprecation{Since: since, Sunset: sunset, Link: "https://example.com/v2", Message: "use v2"}, http.Header{
+			"Deprecation": {"@1609459200"},
+			"Sunset":      {"Thu, 01 Jul 2021 00:00:00 GMT"},
+			"Link":        {`<https://example.com/v2>; rel="deprecation"`},
+		}, Warning{WarningDeprecated, "use v2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, "blah", http.StatusOK, WithDeprecation(tt.d)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			for k, v := range tt.wantHeader {
+				if got := rr.Header().Values(k); !reflect.DeepEqual(got, v) {
+					t.Errorf("got %v header %q, want %q", k, got, v)
+				}
+			}
+
+			var jr Response
+			if err := ReadResponse(rr.Body, nil, WithEnvelope(&jr)); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := jr.Warnings, []Warning{tt.wantWarning}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got warnings %v, want %v", got, want)
+			}
+
+			d, ok := ParseDeprecation(rr.Header())
+			if !ok {
+				t.Fatalf("failed to parse deprecation")
+			}
+			want := tt.d
+			want.Message = ""
+			if !reflect.DeepEqual(d, want) {
+				t.Errorf("got deprecation %+v, want %+v", d, want)
+			}
+		})
+	}
+}
+
+func TestParseDeprecation(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   Deprecation
+		wantOK bool
+	}{
+		{"None", http.Header{}, Deprecation{}, false},
+		{"False", http.Header{"Deprecation": {"false"}}, Deprecation{}, false},
+		{"True", http.Header{"Deprecation": {"true"}}, Deprecation{}, true},
+		{"Date", http.Header{"Deprecation": {"@1609459200"}}, Deprecation{Since: time.Unix(1609459200, 0).UTC()}, true},
+		{"HTTPDate", http.Header{"Deprecation": {"Fri, 01 Jan 2021 00:00:00 GMT"}}, Deprecation{Since: time.Unix(1609459200, 0).UTC()}, true},
+		{"InvalidDate", http.Header{"Deprecation": {"@blah"}}, Deprecation{}, true},
+		{"Sunset", http.Header{
+			"Deprecation": {"true"},
+			"Sunset":      {"Thu, 01 Jul 2021 00:00:00 GMT"},
+		}, Deprecation{Sunset: time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"Link", http.Header{
+			"Deprecation": {"true"},
+			"Link":        {`<https://example.com/next>; rel="next", <https://example.com/v2>; rel="sunset deprecation"`},
+		}, Deprecation{Link: "https://example.com/v2"}, true},
+		{"LinkOther", http.Header{
+			"Deprecation": {"true"},
+			"Link":        {`<https://example.com/next>; rel="next"`},
+		}, Deprecation{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseDeprecation(tt.header)
+			if ok != tt.wantOK {
+				t.Errorf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got deprecation %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithDeprecationLink(t *testing.T) {
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Link", `</items?page=2>; rel="next"`)
+
+	d := Deprecation{Link: "https://example.com/v2"}
+	if err := WriteResponse(rr, "blah", http.StatusOK, WithDeprecation(d), WithDeprecation(d)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	want := []string{`</items?page=2>; rel="next"`, `<https://example.com/v2>; rel="deprecation"`}
+	if got := rr.Header().Values("Link"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got Link header %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrDuplicateKey is returned by the read functions when a JSON object contains a duplicate key,
+// and WithRejectDuplicateKeys is used.
+var ErrDuplicateKey = errors.New("jsonresp: duplicate object key")
+
+// duplicateKeys identifies the objects checked for duplicate keys.
+type duplicateKeys int
+
+const (
+	duplicateKeysNone     duplicateKeys = iota
+	duplicateKeysEnvelope               // objects of the envelope, excluding its data
+	duplicateKeysAll                    // all objects
+)
+
+// WithRejectDuplicateKeys causes the read functions to return an error wrapping ErrDuplicateKey
+// if an object in the response envelope, such as the envelope itself or its error, contains the
+// same key more than once. If data is true, objects within the data are also checked. Parsers
+// resolve duplicate keys differently, some taking the first value and others the last, so a
+// payload with duplicate keys may be interpreted differently by each service that handles it.
+//
+// Keys are compared after escape sequences are replaced, and without regard to case, as
+// encoding/json matches keys to struct fields without regard to case. ReadRequest and ReadPatch
+// check the entire body, which has no envelope, whether or not data is true.
+// WithRejectDuplicateKeys has no effect when used with WithCodec.
+func WithRejectDuplicateKeys(data bool) Option {
+	return func(o *options) {
+		o.duplicateKeys = duplicateKeysEnvelope
+		if data {
+			o.duplicateKeys = duplicateKeysAll
+		}
+	}
+}
+
+// duplicateFrame describes an array or object being read by a duplicateReader.
+type duplicateFrame struct {
+	object    bool
+	checked   bool
+	expectKey bool                // whether the next string in an object is a key
+	key       string              // the most recent key of an object
+	keys      map[string]struct{} // nil if not checked
+}
+
+// duplicateReader reads JSON from r, returning an error wrapping ErrDuplicateKey if an object
+// checked according to its scope contains a duplicate key.
+type duplicateReader struct {
+	r        io.Reader
+	scope    duplicateKeys
+	envelope bool // whether the value read is a response envelope
+	fn       FieldNames
+	stack    []duplicateFrame
+	inString bool
+	escaped  bool
+	inKey    bool
+	keyBuf   []byte
+	err      error
+}
+
+// withDuplicateCheck returns a reader that checks the JSON read from r for duplicate keys as
+// established by o, or r if none are checked. If envelope is false, the JSON is not a response
+// envelope, and is checked in full.
+func (o *options) withDuplicateCheck(r io.Reader, envelope bool) io.Reader {
+	if o.duplicateKeys == duplicateKeysNone {
+		return r
+	}
+	return &duplicateReader{r: r, scope: o.duplicateKeys, envelope: envelope, fn: o.fieldNames}
+}
+
+// foldKey returns key with its case folded, such that keys matched to the same struct field by
+// encoding/json, including by special cases such as the Kelvin sign, fold to the same string.
+func foldKey(key string) string {
+	return strings.ToLower(strings.ToUpper(key))
+}
+
+// checked reports whether a container nested at the current position is checked.
+func (d *duplicateReader) checked() bool {
+	if len(d.stack) == 0 {
+		return true
+	}
+
+	parent := &d.stack[len(d.stack)-1]
+	if d.envelope && len(d.stack) == 1 && parent.object && foldKey(parent.key) == foldKey(d.fn.name("data")) {
+		return d.scope == duplicateKeysAll
+	}
+	return parent.checked
+}
+
+// push begins an array or object.
+func (d *duplicateReader) push(object bool) {
+	f := duplicateFrame{object: object, checked: d.checked(), expectKey: object}
+	if object && f.checked {
+		f.keys = make(map[string]struct{})
+	}
+	d.stack = append(d.stack, f)
+}
+
+// endKey checks the key collected in keyBuf against the keys of the enclosing object.
+func (d *duplicateReader) endKey() error {
+	f := &d.stack[len(d.stack)-1]
+	f.expectKey = false
+
+	var key string
+	if err := json.Unmarshal(d.keyBuf, &key); err != nil {
+		// The decoder reports the syntax error.
+		return nil
+	}
+	f.key = key
+	if f.keys == nil {
+		return nil
+	}
+
+	k := foldKey(key)
+	if _, ok := f.keys[k]; ok {
+		return fmt.Errorf("%w %q", ErrDuplicateKey, key)
+	}
+	f.keys[k] = struct{}{}
+	return nil
+}
+
+func (d *duplicateReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	n, err := d.r.Read(p)
+	for i, c := range p[:n] {
+		if d.inKey {
+			d.keyBuf = append(d.keyBuf, c)
+		}
+
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			if c == '\\' {
+				d.escaped = true
+			} else if c == '"' {
+				d.inString = false
+				if d.inKey {
+					d.inKey = false
+					if d.err = d.endKey(); d.err != nil {
+						return i, d.err
+					}
+				}
+			}
+		case c == '"':
+			d.inString = true
+			if len(d.stack) > 0 && d.stack[len(d.stack)-1].expectKey {
+				d.inKey = true
+				d.keyBuf = append(d.keyBuf[:0], c)
+			}
+		case c == '{' || c == '[':
+			d.push(c == '{')
+		case c == '}' || c == ']':
+			if len(d.stack) > 0 {
+				d.stack = d.stack[:len(d.stack)-1]
+			}
+		case c == ',':
+			if len(d.stack) > 0 && d.stack[len(d.stack)-1].object {
+				d.stack[len(d.stack)-1].expectKey = true
+			}
+		}
+	}
+	return n, err
+}
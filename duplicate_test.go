@@ -0,0 +1,102 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRejectDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		data    bool
+		wantErr bool
+	}{
+		{"Valid", `{"data":{"a":1,"b":[{"a":2}]},"page":{"next":"x"}}`, true, false},
+		{"Envelope", `{"data":"a","data":"b"}`, false, true},
+		{"EnvelopeCase", `{"data":"a","Data":"b"}`, false, true},
+		{"EnvelopeEscaped", `{"data":"a","\u0064ata":"b"}`, false, true},
+		{"MetaKelvin", `{"meta":{"k":1,"\u212a":2}}`, false, true},
+		{"Error", `{"error":{"code":400,"code":200}}`, false, true},
+		{"ErrorDetails", `{"error":{"code":400,"details":{"a":1,"a":2}}}`, false, true},
+		{"DataUnchecked", `{"data":{"a":1,"a":2}}`, false, false},
+		{"DataUncheckedNested", `{"data":[{"a":1,"a":2}],"meta":{"b":1}}`, false, false},
+		{"Data", `{"data":{"a":1,"a":2}}`, true, true},
+		{"DataNested", `{"data":[{"a":{"b":1,"B":2}}]}`, true, true},
+		{"SameKeyDifferentObjects", `{"data":[{"a":1},{"a":2}]}`, true, false},
+		{"KeyInString", `{"data":"{\"a\":1,\"a\":2}","meta":{"data":1}}`, true, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			err := ReadResponse(strings.NewReader(tt.body), &v, WithRejectDuplicateKeys(tt.data))
+			if got, want := errors.Is(err, ErrDuplicateKey), tt.wantErr; got != want {
+				t.Errorf("got error %v, want duplicate key error %v", err, want)
+			}
+
+			// Without the option, duplicate keys are accepted.
+			if err := ReadResponse(strings.NewReader(tt.body), &v); errors.Is(err, ErrDuplicateKey) {
+				t.Errorf("got error %v without option", err)
+			}
+		})
+	}
+}
+
+func TestReadResponseEachDuplicateKeys(t *testing.T) {
+	body := `{"data":[{"a":1},{"a":2,"a":3}]}`
+	f := func(interface{}) error { return nil }
+
+	if _, err := ReadResponseEach(strings.NewReader(body), f, WithRejectDuplicateKeys(false)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ReadResponseEach(strings.NewReader(body), f, WithRejectDuplicateKeys(true)); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("got error %v, want duplicate key error", err)
+	}
+}
+
+func TestWithRejectDuplicateKeysFieldNames(t *testing.T) {
+	body := `{"result":{"a":1,"a":2}}`
+	opts := []Option{WithFieldNames(FieldNames{Data: "result"})}
+
+	var v interface{}
+	if err := ReadResponse(strings.NewReader(body), &v, append(opts, WithRejectDuplicateKeys(false))...); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ReadResponse(strings.NewReader(body), &v, append(opts, WithRejectDuplicateKeys(true))...); !errors.Is(err, ErrDuplicateKey) {
+		t.Errorf("got error %v, want duplicate key error", err)
+	}
+}
+
+func TestReadRequestDuplicateKeys(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+		Role string `json:"role"`
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"bob","role":"user","Role":"admin"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	var v user
+	err := ReadRequest(r, &v, WithRejectDuplicateKeys(false))
+
+	var je *Error
+	if !errors.As(err, &je) {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	if got, want := je.Code, http.StatusBadRequest; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if !strings.Contains(je.Message, `duplicate object key "Role"`) {
+		t.Errorf("got message %q, want duplicate key", je.Message)
+	}
+}
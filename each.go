@@ -0,0 +1,218 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errDataNotArray is returned by ReadResponseEach when the data of a response is not an array.
+var errDataNotArray = errors.New("data is not an array")
+
+// callbackError wraps an error returned by the function supplied to ReadResponseEach, so that it
+// can be distinguished from a decoding failure.
+type callbackError struct {
+	err error
+}
+
+func (e callbackError) Error() string { return e.err.Error() }
+
+// ReadResponseEach reads a JSON response from r, in the same way as ReadResponsePage, except
+// that the elements of the data, which must be an array, are unmarshalled into values of type T
+// and passed to f one at a time, rather than being held in memory together. If f returns an
+// error, reading stops and the error is returned. Null or absent data results in no calls to f.
+//
+// If the response contains an error, it is returned, even if it follows the data, in which case f
+// may already have been called. When used with WithFormat, the response is converted to JSON in
+// full before its elements are decoded. When used with WithEnvelope, the data of the stored
+// envelope is nil.
+func ReadResponseEach[T any](r io.Reader, f func(T) error, opts ...Option) (*PageDetails, error) {
+	o := newOptions(opts)
+
+	jr, err := o.readEach(r, func(dec *json.Decoder) error {
+		var v T
+		if err := o.decodeElement(dec, &v); err != nil {
+			return err
+		}
+		if err := f(v); err != nil {
+			return callbackError{err}
+		}
+		return nil
+	})
+	if err != nil {
+		var ce callbackError
+		if errors.As(err, &ce) {
+			return nil, ce.err
+		}
+		return nil, fmt.Errorf("jsonresp: failed to read response: %w", err)
+	}
+
+	if o.envelopeTo != nil {
+		*o.envelopeTo = jr
+	}
+	if jr.Error != nil {
+		return nil, jr.Error
+	}
+	return jr.Page, nil
+}
+
+// decodeElement decodes the next value from dec into v, using the unmarshal function of o.
+func (o *options) decodeElement(dec *json.Decoder, v interface{}) error {
+	if o.unmarshal == nil {
+		return dec.Decode(v)
+	}
+
+	var b json.RawMessage
+	if err := dec.Decode(&b); err != nil {
+		return err
+	}
+	return o.unmarshal(b, v)
+}
+
+// readEach reads a response envelope from r, calling elem to decode each element of its data. If
+// decoding fails, a DecodeError is returned.
+func (o *options) readEach(r io.Reader, elem func(*json.Decoder) error) (Response, error) {
+	if o.ctx != nil {
+		r = &ctxReader{ctx: o.ctx, r: r}
+	}
+	if o.maxBodySize > 0 {
+		r = &maxBytesReader{r: r, n: o.maxBodySize}
+	}
+
+	s := &snippet{max: maxDecodeErrorBody}
+	r = io.TeeReader(r, s)
+
+	jr, err := o.readEachFrom(r, elem)
+	if err != nil {
+		var ce callbackError
+		if errors.As(err, &ce) {
+			return Response{}, ce
+		}
+		de := newDecodeError(s.b, err)
+		if o.format != nil {
+			// Offsets are relative to the JSON converted from the payload, not the payload itself.
+			de.Offset = -1
+		}
+		observeDecodeError(de)
+		return Response{}, de
+	}
+	return jr, nil
+}
+
+// readEachFrom reads a response envelope from r, converting it from the format of o if necessary,
+// and calling elem to decode each element of its data.
+func (o *options) readEachFrom(r io.Reader, elem func(*json.Decoder) error) (Response, error) {
+	if o.format != nil {
+		b, err := o.format.ToJSON(r)
+		if err != nil {
+			return Response{}, err
+		}
+		r = bytes.NewReader(b)
+	}
+	if o.maxDepth > 0 {
+		r = &depthReader{r: r, max: o.maxDepth}
+	}
+	r = o.withDuplicateCheck(r, true)
+
+	dec := o.newDecoder(r)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return Response{}, err
+	}
+	switch tok {
+	case json.Delim('{'):
+	case json.Delim('['):
+		if o.bare {
+			// A bare response consists of the data alone.
+			return Response{}, readElements(dec, elem)
+		}
+		fallthrough
+	default:
+		return Response{}, fmt.Errorf("unexpected %v, want response envelope", tok)
+	}
+
+	var jr Response
+	for dec.More() {
+		if err := o.readMember(dec, elem, &jr); err != nil {
+			return Response{}, err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return Response{}, err
+	}
+	return jr, nil
+}
+
+// readMember reads a member of a response envelope from dec into jr, calling elem to decode each
+// element of its data.
+func (o *options) readMember(dec *json.Decoder, elem func(*json.Decoder) error, jr *Response) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	key, _ := tok.(string)
+
+	fn := o.fieldNames
+	switch key {
+	case fn.name("data"):
+		return readData(dec, elem)
+	case fn.name("page"):
+		var wp *wirePage
+		err := dec.Decode(&wp)
+		jr.Page = wp.page()
+		return err
+	case fn.name("error"):
+		var we *wireError
+		err := dec.Decode(&we)
+		jr.Error = we.error()
+		return err
+	case fn.name("warnings"):
+		return dec.Decode(&jr.Warnings)
+	case fn.name("meta"):
+		return dec.Decode(&jr.Meta)
+	case fn.name("links"):
+		return dec.Decode(&jr.Links)
+	}
+
+	if o.strict {
+		return fmt.Errorf("json: unknown field %q", key)
+	}
+	var skip json.RawMessage
+	return dec.Decode(&skip)
+}
+
+// readData reads the data of a response envelope from dec, calling elem to decode each element.
+func readData(dec *json.Decoder, elem func(*json.Decoder) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case nil:
+		return nil
+	case json.Delim('['):
+		return readElements(dec, elem)
+	default:
+		return errDataNotArray
+	}
+}
+
+// readElements reads the elements of an array from dec, the opening delimiter of which has been
+// read, calling elem to decode each.
+func readElements(dec *json.Decoder, elem func(*json.Decoder) error) error {
+	for dec.More() {
+		if err := elem(dec); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadResponseEach(t *testing.T) {
+	type item struct {
+		ID int `json:"id"`
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		opts      []Option
+		wantItems []item
+		wantPage  *PageDetails
+		wantErr   error
+	}{
+		{"Empty", `{}`, nil, nil, nil, nil},
+		{"NullData", `{"data":null}`, nil, nil, nil, nil},
+		{"EmptyData", `{"data":[]}`, nil, nil, nil, nil},
+		{"Data", `{"data":[{"id":1},{"id":2},{"id":3}]}`, nil, []item{{1}, {2}, {3}}, nil, nil},
+		{"Page", `{"page":{"next":"n"},"data":[{"id":1}]}`, nil, []item{{1}}, &PageDetails{Next: "n"}, nil},
+		{"PageZeroTotal", `{"page":{"totalSize":0},"data":[]}`, nil, nil, &PageDetails{ZeroTotal: true}, nil},
+		{"PageAfterData", `{"data":[{"id":1}],"page":{"next":"n"}}`, nil, []item{{1}}, &PageDetails{Next: "n"}, nil},
+		{"Error", `{"error":{"code":404,"message":"blah"}}`, nil, nil, nil, NewError("blah", http.StatusNotFound)},
+		{"TrailingError", `{"data":[{"id":1},{"id":2}],"error":{"code":500,"message":"truncated"}}`, nil, []item{{1}, {2}}, nil, NewError("truncated", http.StatusInternalServerError)},
+		{"Unknown", `{"data":[{"id":1}],"other":{"a":[1,2]}}`, nil, []item{{1}}, nil, nil},
+		{"FieldNames", `{"items":[{"id":1}],"paging":{"next":"n"}}`, []Option{WithFieldNames(FieldNames{Data: "items", Page: "paging"})}, []item{{1}}, &PageDetails{Next: "n"}, nil},
+		{"Bare", `[{"id":1},{"id":2}]`, []Option{WithBare()}, []item{{1}, {2}}, nil, nil},
+		{"BareEnvelope", `{"data":[{"id":1}]}`, []Option{WithBare()}, []item{{1}}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items []item
+			pd, err := ReadResponseEach(strings.NewReader(tt.body), func(v item) error {
+				items = append(items, v)
+				return nil
+			}, tt.opts...)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got, want := items, tt.wantItems; !reflect.DeepEqual(got, want) {
+				t.Errorf("got items %v, want %v", got, want)
+			}
+			if got, want := pd, tt.wantPage; !reflect.DeepEqual(got, want) {
+				t.Errorf("got page %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadResponseEachInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		opts []Option
+	}{
+		{"Empty", ``, nil},
+		{"NotEnvelope", `[1,2]`, nil},
+		{"DataNotArray", `{"data":{"id":1}}`, nil},
+		{"Truncated", `{"data":[{"id":1},`, nil},
+		{"ElementType", `{"data":["a"]}`, nil},
+		{"UnknownStrict", `{"data":[],"other":1}`, []Option{WithStrict()}},
+		{"UnknownElementFieldStrict", `{"data":[{"id":1,"other":1}]}`, []Option{WithStrict()}},
+		{"MaxBodySize", `{"data":[{"id":1},{"id":2}]}`, []Option{WithMaxBodySize(16)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadResponseEach(strings.NewReader(tt.body), func(struct {
+				ID int `json:"id"`
+			}) error {
+				return nil
+			}, tt.opts...)
+
+			var de *DecodeError
+			if !errors.As(err, &de) {
+				t.Errorf("got error %v, want DecodeError", err)
+			}
+		})
+	}
+}
+
+func TestReadResponseEachStop(t *testing.T) {
+	errStop := errors.New("stop")
+
+	var n int
+	_, err := ReadResponseEach(strings.NewReader(`{"data":[1,2,3]}`), func(int) error {
+		if n++; n == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("got error %v, want %v", err, errStop)
+	}
+	if got, want := n, 2; got != want {
+		t.Errorf("got %v calls, want %v", got, want)
+	}
+}
+
+func TestReadResponseEachWritten(t *testing.T) {
+	data := make([]int, 1000)
+	for i := range data {
+		data[i] = i
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, data, &PageDetails{Next: "n", TotalSize: 2000}, http.StatusOK, WithStream(), WithWarning("", "partial")); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	var got []int
+	var jr Response
+	pd, err := ReadResponseEach(rr.Body, func(v int) error {
+		got = append(got, v)
+		return nil
+	}, WithEnvelope(&jr))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if !reflect.DeepEqual(got, data) {
+		t.Errorf("got %v items, want %v", len(got), len(data))
+	}
+	if want := (&PageDetails{Next: "n", TotalSize: 2000}); !reflect.DeepEqual(pd, want) {
+		t.Errorf("got page %+v, want %+v", pd, want)
+	}
+	if want := []Warning{{Message: "partial"}}; !reflect.DeepEqual(jr.Warnings, want) {
+		t.Errorf("got warnings %v, want %v", jr.Warnings, want)
+	}
+}
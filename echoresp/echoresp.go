@@ -0,0 +1,72 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package echoresp adapts the jsonresp package to the echo web framework, so that responses and
+// errors, including echo's binding and routing errors, are written in the jsonresp envelope
+// rather than echo's default format.
+package echoresp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// withRequest returns opts, followed by an option supplying the request of c.
+func withRequest(c echo.Context, opts []jsonresp.Option) []jsonresp.Option {
+	return append(opts[:len(opts):len(opts)], jsonresp.WithRequest(c.Request()))
+}
+
+// Response writes a status code and JSON response containing data, as by jsonresp.WriteResponse.
+func Response(c echo.Context, code int, data interface{}, opts ...jsonresp.Option) error {
+	return jsonresp.WriteResponse(c.Response(), data, code, withRequest(c, opts)...)
+}
+
+// Error writes a status code and JSON response describing err, as by jsonresp.WriteErr. If err is,
+// or wraps, an echo.HTTPError, its code and message are written.
+func Error(c echo.Context, err error, opts ...jsonresp.Option) error {
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		err = httpError(he)
+	}
+	return jsonresp.WriteErr(c.Response(), err, withRequest(c, opts)...)
+}
+
+// httpError returns the Error corresponding to he.
+func httpError(he *echo.HTTPError) *jsonresp.Error {
+	var msg string
+	switch m := he.Message.(type) {
+	case nil:
+	case string:
+		msg = m
+	case error:
+		msg = m.Error()
+	default:
+		msg = fmt.Sprint(m)
+	}
+	return jsonresp.NewError(msg, he.Code)
+}
+
+// HTTPErrorHandler returns an echo.HTTPErrorHandler that writes the errors returned by handlers
+// as by Error, so that the errors echo returns for binding failures, and for requests that match
+// no route, are written in the jsonresp envelope. If a response has already been written, the
+// error is ignored.
+func HTTPErrorHandler(opts ...jsonresp.Option) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+		if err := Error(c, err, opts...); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
+
+// Register sets the error handler of e to HTTPErrorHandler, which writes with opts.
+func Register(e *echo.Echo, opts ...jsonresp.Option) {
+	e.HTTPErrorHandler = HTTPErrorHandler(opts...)
+}
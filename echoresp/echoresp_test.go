@@ -0,0 +1,152 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package echoresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+type item struct {
+	Name string `json:"name"`
+}
+
+func newEcho() *echo.Echo {
+	e := echo.New()
+	Register(e)
+
+	e.GET("/items", func(c echo.Context) error {
+		return Response(c, http.StatusOK, []item{{Name: "a"}})
+	})
+	e.GET("/conflict", func(c echo.Context) error {
+		return jsonresp.NewError("already exists", http.StatusConflict)
+	})
+	e.GET("/wrapped", func(c echo.Context) error {
+		return fmt.Errorf("wrapped: %w", echo.NewHTTPError(http.StatusTeapot, errors.New("short and stout")))
+	})
+	e.GET("/internal", func(c echo.Context) error {
+		return errors.New("blah")
+	})
+	e.GET("/written", func(c echo.Context) error {
+		_ = c.String(http.StatusAccepted, "ok")
+		return errors.New("blah")
+	})
+	e.POST("/bind", func(c echo.Context) error {
+		var v item
+		if err := c.Bind(&v); err != nil {
+			return err
+		}
+		return Response(c, http.StatusCreated, v)
+	})
+	return e
+}
+
+func TestResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Response", http.MethodGet, "/items", "", http.StatusOK, `{"data":[{"name":"a"}]}`},
+		{"Bind", http.MethodPost, "/bind", `{"name":"a"}`, http.StatusCreated, `{"data":{"name":"a"}}`},
+		{"Written", http.MethodGet, "/written", "", http.StatusAccepted, `ok`},
+	}
+
+	e := newEcho()
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantMsg  string
+	}{
+		{"Error", http.MethodGet, "/conflict", "", http.StatusConflict, "already exists"},
+		{"HTTPError", http.MethodGet, "/wrapped", "", http.StatusTeapot, "short and stout"},
+		{"Internal", http.MethodGet, "/internal", "", http.StatusInternalServerError, "blah"},
+		{"BindInvalid", http.MethodPost, "/bind", `{`, http.StatusBadRequest, "unexpected EOF"},
+		{"NotFound", http.MethodGet, "/other", "", http.StatusNotFound, "Not Found"},
+		{"MethodNotAllowed", http.MethodDelete, "/items", "", http.StatusMethodNotAllowed, "Method Not Allowed"},
+	}
+
+	e := newEcho()
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), "application/json"; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+
+			want := &jsonresp.Error{Code: tt.wantCode, Message: tt.wantMsg}
+			if err := jsonresp.ReadError(rr.Body); !errors.Is(err, want) {
+				t.Errorf("got error %v, want %v", err, want)
+			}
+		})
+	}
+}
+
+func TestHTTPErrorMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message interface{}
+		want    string
+	}{
+		{"Nil", nil, ""},
+		{"String", "blah", "blah"},
+		{"Error", errors.New("blah"), "blah"},
+		{"Other", map[string]string{"a": "b"}, "map[a:b]"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			je := httpError(&echo.HTTPError{Code: http.StatusBadRequest, Message: tt.message})
+			if got, want := je.Code, http.StatusBadRequest; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := je.Message, tt.want; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"io"
+	"net/http"
+)
+
+// Encoder writes responses with a fixed set of options, such as a Codec, indentation, limits and
+// response hooks established by WithResponseHook. This allows APIs with different conventions to
+// coexist in one program, without relying on process-wide configuration such as SetDefaults or
+// AddResponseHook, which continue to apply beneath the options of the Encoder. The methods of
+// Encoder behave like the package functions of the same name, with the options of the Encoder
+// applied before those supplied to the call. Encoders are safe for concurrent use.
+type Encoder struct {
+	opts []Option
+}
+
+// NewEncoder returns an Encoder that writes responses with opts.
+func NewEncoder(opts ...Option) *Encoder {
+	return &Encoder{opts: append([]Option(nil), opts...)}
+}
+
+// newOptions returns the options resulting from applying the options of e, then opts, to the
+// defaults.
+func (e *Encoder) newOptions(opts []Option) *options {
+	return newOptions(joinOptions(e.opts, opts))
+}
+
+// joinOptions returns the options of a followed by those of b, without modifying a.
+func joinOptions(a, b []Option) []Option {
+	if len(b) == 0 {
+		return a
+	}
+	return append(a[:len(a):len(a)], b...)
+}
+
+// WriteResponse writes a status code and JSON response containing data to w, as by WriteResponse.
+func (e *Encoder) WriteResponse(w http.ResponseWriter, data interface{}, code int, opts ...Option) error {
+	return WriteResponse(w, data, code, joinOptions(e.opts, opts)...)
+}
+
+// WriteResponsePage writes a status code and JSON response containing data and pd to w, as by
+// WriteResponsePage.
+func (e *Encoder) WriteResponsePage(w http.ResponseWriter, data interface{}, pd *PageDetails, code int, opts ...Option) error {
+	return WriteResponsePage(w, data, pd, code, joinOptions(e.opts, opts)...)
+}
+
+// WriteNegotiated writes a status code and response containing data to w in the format negotiated
+// with r, as by WriteNegotiated.
+func (e *Encoder) WriteNegotiated(w http.ResponseWriter, r *http.Request, data interface{}, code int, opts ...Option) error {
+	return WriteNegotiated(w, r, data, code, joinOptions(e.opts, opts)...)
+}
+
+// WriteError writes a status code and JSON response containing the supplied error message and
+// status code to w, as by WriteError.
+func (e *Encoder) WriteError(w http.ResponseWriter, message string, code int, opts ...Option) error {
+	return writeError(w, NewError(message, code), nil, e.newOptions(opts))
+}
+
+// WriteErrorDetails writes a status code and JSON response containing the supplied error message,
+// status code and machine-readable details to w, as by WriteErrorDetails.
+func (e *Encoder) WriteErrorDetails(w http.ResponseWriter, message string, details map[string]interface{}, code int, opts ...Option) error {
+	je := &Error{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+	return writeError(w, je, nil, e.newOptions(opts))
+}
+
+// WriteErr writes a status code and JSON response describing err to w, as by WriteErr.
+func (e *Encoder) WriteErr(w http.ResponseWriter, err error, opts ...Option) error {
+	if err == nil {
+		return writeError(w, NewError("", http.StatusInternalServerError), nil, e.newOptions(opts))
+	}
+	return writeError(w, errorFor(err), err, e.newOptions(opts))
+}
+
+// EncodeResponse writes the JSON encoding of jr to w, as by EncodeResponse.
+func (e *Encoder) EncodeResponse(w io.Writer, jr Response, opts ...Option) error {
+	return EncodeResponse(w, jr, joinOptions(e.opts, opts)...)
+}
+
+// Decoder reads responses and requests with a fixed set of options, such as a Codec, WithStrict
+// and limits, in the same way as an Encoder writes them. The methods of Decoder behave like the
+// package functions of the same name, with the options of the Decoder applied before those
+// supplied to the call. Decoders are safe for concurrent use.
+type Decoder struct {
+	opts []Option
+}
+
+// NewDecoder returns a Decoder that reads responses and requests with opts.
+func NewDecoder(opts ...Option) *Decoder {
+	return &Decoder{opts: append([]Option(nil), opts...)}
+}
+
+// ReadResponse reads a JSON response from r and unmarshals the data it contains into v, as by
+// ReadResponse.
+func (d *Decoder) ReadResponse(r io.Reader, v interface{}, opts ...Option) error {
+	return ReadResponse(r, v, joinOptions(d.opts, opts)...)
+}
+
+// ReadResponsePage reads a JSON response from r, unmarshals the data it contains into v, and
+// returns its paging information, as by ReadResponsePage.
+func (d *Decoder) ReadResponsePage(r io.Reader, v interface{}, opts ...Option) (*PageDetails, error) {
+	return ReadResponsePage(r, v, joinOptions(d.opts, opts)...)
+}
+
+// ReadHTTPResponse reads the JSON response res, as by ReadHTTPResponse.
+func (d *Decoder) ReadHTTPResponse(res *http.Response, v interface{}, opts ...Option) (*PageDetails, error) {
+	return ReadHTTPResponse(res, v, joinOptions(d.opts, opts)...)
+}
+
+// ReadError reads a JSON response from r and returns the error it contains, as by ReadError.
+func (d *Decoder) ReadError(r io.Reader, opts ...Option) error {
+	return ReadError(r, joinOptions(d.opts, opts)...)
+}
+
+// DecodeResponse reads a JSON response from r, as by DecodeResponse.
+func (d *Decoder) DecodeResponse(r io.Reader, opts ...Option) (Response, error) {
+	return DecodeResponse(r, joinOptions(d.opts, opts)...)
+}
+
+// ReadRequest reads the JSON body of the request r into v, as by ReadRequest.
+func (d *Decoder) ReadRequest(r *http.Request, v interface{}, opts ...Option) error {
+	return ReadRequest(r, v, joinOptions(d.opts, opts)...)
+}
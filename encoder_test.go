@@ -0,0 +1,179 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncoder(t *testing.T) {
+	v1 := NewEncoder(WithResponseHook(func(_ *http.Request, jr *Response) {
+		jr.Meta = map[string]interface{}{"version": 1}
+	}))
+	v2 := NewEncoder(WithIndent("", " "), WithContentType("application/vnd.example.v2+json"))
+
+	tests := []struct {
+		name     string
+		write    func(w http.ResponseWriter) error
+		wantCode int
+		wantType string
+		wantBody string
+	}{
+		{
+			name:     "Response",
+			write:    func(w http.ResponseWriter) error { return v1.WriteResponse(w, "blah", http.StatusOK) },
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"data":"blah","meta":{"version":1}}`,
+		},
+		{
+			name: "ResponsePage",
+			write: func(w http.ResponseWriter) error {
+				return v2.WriteResponsePage(w, "blah", &PageDetails{TotalSize: 1}, http.StatusOK)
+			},
+			wantCode: http.StatusOK,
+			wantType: "application/vnd.example.v2+json",
+			wantBody: "{\n \"data\": \"blah\",\n \"page\": {\n  \"totalSize\": 1\n }\n}",
+		},
+		{
+			name: "CallOptions",
+			write: func(w http.ResponseWriter) error {
+				return v2.WriteResponse(w, "blah", http.StatusOK, WithIndent("", ""))
+			},
+			wantCode: http.StatusOK,
+			wantType: "application/vnd.example.v2+json",
+			wantBody: `{"data":"blah"}`,
+		},
+		{
+			name:     "Error",
+			write:    func(w http.ResponseWriter) error { return v1.WriteError(w, "blah", http.StatusNotFound) },
+			wantCode: http.StatusNotFound,
+			wantType: "application/json",
+			wantBody: `{"error":{"code":404,"message":"blah"},"meta":{"version":1}}`,
+		},
+		{
+			name: "ErrorDetails",
+			write: func(w http.ResponseWriter) error {
+				return v1.WriteErrorDetails(w, "blah", map[string]interface{}{"field": "name"}, http.StatusBadRequest)
+			},
+			wantCode: http.StatusBadRequest,
+			wantType: "application/json",
+			wantBody: `{"error":{"code":400,"message":"blah","details":{"field":"name"}},"meta":{"version":1}}`,
+		},
+		{
+			name:     "Err",
+			write:    func(w http.ResponseWriter) error { return v1.WriteErr(w, errors.New("blah")) },
+			wantCode: http.StatusInternalServerError,
+			wantType: "application/json",
+			wantBody: `{"error":{"code":500,"message":"blah"},"meta":{"version":1}}`,
+		},
+		{
+			name:     "NilErr",
+			write:    func(w http.ResponseWriter) error { return v1.WriteErr(w, nil) },
+			wantCode: http.StatusInternalServerError,
+			wantType: "application/json",
+			wantBody: `{"error":{"code":500},"meta":{"version":1}}`,
+		},
+		{
+			name: "Negotiated",
+			write: func(w http.ResponseWriter) error {
+				r := httptest.NewRequest(http.MethodGet, "/", nil)
+				return v1.WriteNegotiated(w, r, "blah", http.StatusOK)
+			},
+			wantCode: http.StatusOK,
+			wantType: "application/json",
+			wantBody: `{"data":"blah","meta":{"version":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestEncoderEncodeResponse(t *testing.T) {
+	e := NewEncoder(WithMeta("version", 1))
+
+	var sb strings.Builder
+	if err := e.EncodeResponse(&sb, Response{Data: "blah"}); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if got, want := sb.String(), `{"data":"blah","meta":{"version":1}}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	type thing struct {
+		Name string `json:"name"`
+	}
+	const body = `{"data":{"name":"blah","colour":"red"},"page":{"totalSize":1}}`
+
+	lenient := NewDecoder()
+	strict := NewDecoder(WithStrict())
+
+	var v thing
+	if err := lenient.ReadResponse(strings.NewReader(body), &v); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if got, want := v.Name, "blah"; got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+
+	pd, err := strict.ReadResponsePage(strings.NewReader(body), &v)
+	if err == nil {
+		t.Error("unexpected success reading response with unknown field")
+	}
+	if pd != nil {
+		t.Errorf("got page %v, want nil", pd)
+	}
+
+	jr, err := lenient.DecodeResponse(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got, want := jr.Page.TotalSize, int64(1); got != want {
+		t.Errorf("got total size %v, want %v", got, want)
+	}
+
+	err = strict.ReadError(strings.NewReader(`{"error":{"code":404,"message":"blah"}}`))
+	var je *Error
+	if !errors.As(err, &je) || je.Code != http.StatusNotFound {
+		t.Errorf("got error %v, want 404 error", err)
+	}
+}
+
+func TestDecoderReadRequest(t *testing.T) {
+	d := NewDecoder(WithMaxBodySize(8))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"blah"}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	var v map[string]string
+	if err := d.ReadRequest(r, &v); err == nil {
+		t.Error("unexpected success reading request exceeding maximum size")
+	}
+}
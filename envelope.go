@@ -0,0 +1,71 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+// WithWarning adds a warning with the supplied code and message to the response. It may be used
+// more than once to add multiple warnings.
+func WithWarning(code, message string) Option {
+	return func(o *options) {
+		o.warnings = append(o.warnings, Warning{Code: code, Message: message})
+	}
+}
+
+// WithMeta sets the metadata value of the response identified by key to value, replacing any
+// value supplied to WriteResponseMeta. It may be used more than once to set multiple values.
+func WithMeta(key string, value interface{}) Option {
+	return func(o *options) {
+		if o.meta == nil {
+			o.meta = make(map[string]interface{})
+		}
+		o.meta[key] = value
+	}
+}
+
+// WithExplicitNull causes the data and page fields of a successful response to always be
+// written, with a null value if they are empty, rather than being omitted. This allows clients to
+// distinguish a null result from a response that has no data field.
+func WithExplicitNull() Option {
+	return func(o *options) {
+		o.explicitNull = true
+	}
+}
+
+// WithEnvelope causes the read functions to store the response envelope in jr, so that fields
+// such as Warnings, Meta and Links can be retrieved alongside the decoded data. The data of jr, if
+// present, is of type json.RawMessage.
+func WithEnvelope(jr *Response) Option {
+	return func(o *options) {
+		o.envelopeTo = jr
+	}
+}
+
+// envelope returns jr with the envelope fields established by o added.
+func (o *options) envelope(jr Response) Response {
+	if len(o.warnings) > 0 {
+		jr.Warnings = append(append([]Warning(nil), jr.Warnings...), o.warnings...)
+	}
+	if len(o.meta) > 0 {
+		meta := make(map[string]interface{}, len(jr.Meta)+len(o.meta))
+		for k, v := range jr.Meta {
+			meta[k] = v
+		}
+		for k, v := range o.meta {
+			meta[k] = v
+		}
+		jr.Meta = meta
+	}
+	if len(o.links) > 0 {
+		links := make(map[string]Link, len(jr.Links)+len(o.links))
+		for k, v := range jr.Links {
+			links[k] = v
+		}
+		for k, v := range o.links {
+			links[k] = v
+		}
+		jr.Links = links
+	}
+	return jr
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWithWarning(t *testing.T) {
+	opts := []Option{
+		WithWarning("DEPRECATED", "the sort parameter is deprecated"),
+		WithWarning("", "results are partial"),
+	}
+	want := []Warning{
+		{"DEPRECATED", "the sort parameter is deprecated"},
+		{"", "results are partial"},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Buffered", opts},
+		{"Stream", append([]Option{WithStream()}, opts...)},
+		{"StreamIndent", append([]Option{WithStream(), WithIndent("", "\t")}, opts...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, "blah", http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			var jr Response
+			var s string
+			if err := ReadResponse(bytes.NewReader(rr.Body.Bytes()), &s, WithEnvelope(&jr)); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := s, "blah"; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+			if got := jr.Warnings; !reflect.DeepEqual(got, want) {
+				t.Errorf("got warnings %v, want %v", got, want)
+			}
+			if got, want := jr.Data, json.RawMessage(`"blah"`); !reflect.DeepEqual(got, want) {
+				t.Errorf("got envelope data %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestWithWarningStreamIdentical(t *testing.T) {
+	opts := []Option{WithIndent("", "  "), WithWarning("A", "b")}
+
+	buffered := httptest.NewRecorder()
+	if err := WriteResponsePage(buffered, []int{1, 2}, &PageDetails{Next: "n"}, http.StatusOK, opts...); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	streamed := httptest.NewRecorder()
+	if err := WriteResponsePage(streamed, []int{1, 2}, &PageDetails{Next: "n"}, http.StatusOK, append(opts, WithStream())...); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if got, want := streamed.Body.String(), buffered.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestEncodeResponseWarnings(t *testing.T) {
+	var buf bytes.Buffer
+	jr := Response{Data: 1, Warnings: []Warning{{Message: "a"}}}
+	if err := EncodeResponse(&buf, jr, WithWarning("", "b")); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if got, want := buf.String(), `{"data":1,"warnings":[{"message":"a"},{"message":"b"}]}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, err := DecodeResponse(&buf)
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := []Warning{{Message: "a"}, {Message: "b"}}; !reflect.DeepEqual(got.Warnings, want) {
+		t.Errorf("got warnings %v, want %v", got.Warnings, want)
+	}
+}
+
+func TestWriteResponseMeta(t *testing.T) {
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+		opts []Option
+		want map[string]interface{}
+	}{
+		{"None", nil, nil, nil},
+		{"Meta", map[string]interface{}{"requestId": "abc"}, nil, map[string]interface{}{"requestId": "abc"}},
+		{"Option", nil, []Option{WithMeta("version", "v1")}, map[string]interface{}{"version": "v1"}},
+		{"Merged", map[string]interface{}{"requestId": "abc", "version": "v0"}, []Option{WithMeta("version", "v1")}, map[string]interface{}{"requestId": "abc", "version": "v1"}},
+		{"Stream", map[string]interface{}{"requestId": "abc"}, []Option{WithStream(), WithMeta("elapsedMs", 12)}, map[string]interface{}{"requestId": "abc", "elapsedMs": json.Number("12")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponseMeta(rr, "blah", tt.meta, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			var jr Response
+			var s string
+			if err := ReadResponse(rr.Body, &s, WithEnvelope(&jr), WithUseNumber()); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := s, "blah"; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+			if got, want := jr.Meta, tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got meta %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithMetaDoesNotModify(t *testing.T) {
+	meta := map[string]interface{}{"requestId": "abc"}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponseMeta(rr, nil, meta, http.StatusOK, WithMeta("version", "v1")); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := len(meta), 1; got != want {
+		t.Errorf("got %v meta values, want %v", got, want)
+	}
+}
+
+func TestWithExplicitNull(t *testing.T) {
+	tests := []struct {
+		name string
+		jr   Response
+		opts []Option
+		want string
+	}{
+		{"Default", Response{}, nil, `{}`},
+		{"Empty", Response{}, []Option{WithExplicitNull()}, `{"data":null,"page":null}`},
+		{"Data", Response{Data: 1}, []Option{WithExplicitNull()}, `{"data":1,"page":null}`},
+		{"Page", Response{Page: &PageDetails{Next: "n"}}, []Option{WithExplicitNull()}, `{"data":null,"page":{"next":"n"}}`},
+		{"Error", Response{Error: &Error{Code: http.StatusNotFound}}, []Option{WithExplicitNull()}, `{"error":{"code":404}}`},
+		{"FieldNames", Response{}, []Option{WithExplicitNull(), WithFieldNames(FieldNames{Data: "result"})}, `{"result":null,"page":null}`},
+		{"Format", Response{}, []Option{WithExplicitNull(), WithFormat(JSON)}, `{"data":null,"page":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeResponse(&buf, tt.jr, tt.opts...); err != nil {
+				t.Fatalf("failed to encode response: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			if tt.jr.Error != nil {
+				return
+			}
+			for _, stream := range []bool{false, true} {
+				opts := tt.opts
+				if stream {
+					opts = append(opts, WithStream())
+				}
+				rr := httptest.NewRecorder()
+				if err := WriteResponsePage(rr, tt.jr.Data, tt.jr.Page, http.StatusOK, opts...); err != nil {
+					t.Fatalf("failed to write response: %v", err)
+				}
+				if got := rr.Body.String(); got != tt.want {
+					t.Errorf("stream %v: got %v, want %v", stream, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// errorFields has the fields of an Error, without its methods, so that it is encoded according to
+// its fields.
+type errorFields Error
+
+// errorMembers contains the folded names of the members that correspond to the fields of an Error.
+var errorMembers = func() map[string]struct{} {
+	t := reflect.TypeOf(errorFields{})
+	ms := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		ms[foldKey(name)] = struct{}{}
+	}
+	return ms
+}()
+
+// MarshalJSON returns the JSON encoding of e. A RawCode is written as the code if Code is zero,
+// and the members of Extra are written after the fields, so that an Error decoded by UnmarshalJSON
+// is encoded as it was read.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	w := struct {
+		Code interface{} `json:"code,omitempty"`
+		*errorFields
+	}{errorFields: (*errorFields)(e)}
+	if e.Code != 0 {
+		w.Code = e.Code
+	} else if e.RawCode != "" {
+		w.Code = e.RawCode
+	}
+
+	b, err := json.Marshal(w)
+	if err != nil || len(e.Extra) == 0 {
+		return b, err
+	}
+
+	keys := make([]string, 0, len(e.Extra))
+	for k := range e.Extra {
+		if _, ok := errorMembers[foldKey(k)]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer(b[:len(b)-1])
+	for _, k := range keys {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		if m := e.Extra[k]; len(m) > 0 {
+			buf.Write(m)
+		} else {
+			buf.WriteString("null")
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the JSON encoding of an Error into e, replacing its contents. The code may
+// be encoded as a number, or as a string, as it is by the read functions. Members that do not
+// correspond to a field are retained in Extra. As with encoding/json, numbers within the details
+// and message arguments are decoded as float64.
+func (e *Error) UnmarshalJSON(b []byte) error {
+	if string(bytes.TrimSpace(b)) == "null" {
+		return nil
+	}
+
+	var we wireError
+	if err := json.Unmarshal(b, &we); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(b, &members); err != nil {
+		return err
+	}
+
+	*e = *we.error()
+	for k, m := range members {
+		if _, ok := errorMembers[foldKey(k)]; ok {
+			continue
+		}
+		if e.Extra == nil {
+			e.Extra = make(map[string]json.RawMessage)
+		}
+		e.Extra[k] = m
+	}
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder. The Error is encoded as JSON, so that the values of its
+// details and message arguments need not be registered with encoding/gob.
+func (e *Error) GobEncode() ([]byte, error) {
+	return e.MarshalJSON()
+}
+
+// GobDecode implements gob.GobDecoder, decoding an Error encoded by GobEncode into e.
+func (e *Error) GobDecode(b []byte) error {
+	return e.UnmarshalJSON(b)
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestErrorMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		je   *Error
+		want string
+	}{
+		{"Empty", &Error{}, `{}`},
+		{"Fields", NewAppError("QUOTA", "blah", http.StatusTooManyRequests), `{"code":429,"appCode":"QUOTA","message":"blah"}`},
+		{"RawCode", &Error{RawCode: "NOT_FOUND", Message: "blah"}, `{"code":"NOT_FOUND","message":"blah"}`},
+		{"CodeOverridesRawCode", &Error{Code: http.StatusNotFound, RawCode: "NOT_FOUND"}, `{"code":404}`},
+		{"Extra", &Error{
+			Code:  http.StatusNotFound,
+			Extra: map[string]json.RawMessage{"b": json.RawMessage(`[1]`), "a": json.RawMessage(`"x"`)},
+		}, `{"code":404,"a":"x","b":[1]}`},
+		{"ExtraOnly", &Error{Extra: map[string]json.RawMessage{"a": nil}}, `{"a":null}`},
+		{"ExtraShadowed", &Error{
+			Message: "blah",
+			Extra:   map[string]json.RawMessage{"message": json.RawMessage(`"other"`), "Code": json.RawMessage(`1`)},
+		}, `{"message":"blah"}`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.je)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		b       string
+		want    *Error
+		wantErr bool
+	}{
+		{"Empty", `{}`, &Error{}, false},
+		{"Fields", `{"code":429,"appCode":"QUOTA","message":"blah","retryAfter":30}`, &Error{Code: http.StatusTooManyRequests, AppCode: "QUOTA", Message: "blah", RetryAfter: 30}, false},
+		{"StringCode", `{"code":"404"}`, &Error{Code: http.StatusNotFound}, false},
+		{"RawCode", `{"code":"NOT_FOUND"}`, &Error{RawCode: "NOT_FOUND"}, false},
+		{"CaseInsensitive", `{"Message":"blah"}`, &Error{Message: "blah"}, false},
+		{"Extra", `{"code":404,"hint":"retry", "tags":[1, 2]}`, &Error{
+			Code:  http.StatusNotFound,
+			Extra: map[string]json.RawMessage{"hint": json.RawMessage(`"retry"`), "tags": json.RawMessage(`[1, 2]`)},
+		}, false},
+		{"Array", `[]`, nil, true},
+		{"BadCode", `{"code":true}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			je := &Error{Message: "stale", Extra: map[string]json.RawMessage{"stale": nil}}
+			err := json.Unmarshal([]byte(tt.b), je)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(je, tt.want) {
+				t.Errorf("got %#v, want %#v", je, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorUnmarshalJSONNull(t *testing.T) {
+	je := NewError("blah", http.StatusNotFound)
+	if err := je.UnmarshalJSON([]byte("null")); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if want := NewError("blah", http.StatusNotFound); !reflect.DeepEqual(je, want) {
+		t.Errorf("got %#v, want %#v", je, want)
+	}
+}
+
+// roundTripError returns the error that results from encoding and decoding je with gob.
+func roundTripError(t *testing.T, je *Error) *Error {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(je); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	var got *Error
+	if err := gob.NewDecoder(&buf).Decode(&got); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	return got
+}
+
+func TestErrorRoundTrip(t *testing.T) {
+	def := &ErrorDef{AppCode: "QUOTA", Code: http.StatusTooManyRequests, Message: "quota exceeded"}
+
+	tests := []struct {
+		name string
+		je   *Error
+	}{
+		{"Code", NewError("blah", http.StatusNotFound)},
+		{"AppCode", def.New()},
+		{"RawCode", &Error{RawCode: "NOT_FOUND", Message: "blah"}},
+		{"Fields", &Error{
+			Code:        http.StatusServiceUnavailable,
+			Message:     "blah",
+			Details:     map[string]interface{}{"field": "name", "limit": 10.0},
+			MessageKey:  "errors.blah",
+			MessageArgs: []interface{}{"a", 1.5},
+			RetryAfter:  30,
+			RequestID:   "abc",
+			Context:     map[string]string{"traceId": "123"},
+			Debug:       &DebugInfo{Chain: []string{"boom"}},
+		}},
+		{"Extra", &Error{
+			Code:  http.StatusConflict,
+			Extra: map[string]json.RawMessage{"revision": json.RawMessage(`{"n":3}`)},
+		}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Run("JSON", func(t *testing.T) {
+				b, err := json.Marshal(tt.je)
+				if err != nil {
+					t.Fatalf("failed to marshal: %v", err)
+				}
+				var got *Error
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Fatalf("failed to unmarshal: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.je) {
+					t.Errorf("got %#v, want %#v", got, tt.je)
+				}
+			})
+
+			t.Run("Gob", func(t *testing.T) {
+				got := roundTripError(t, tt.je)
+				if !reflect.DeepEqual(got, tt.je) {
+					t.Errorf("got %#v, want %#v", got, tt.je)
+				}
+				if !errors.Is(got, tt.je) {
+					t.Errorf("got %v, which is not %v", got, tt.je)
+				}
+			})
+		})
+	}
+}
+
+func TestErrorRoundTripIs(t *testing.T) {
+	def := &ErrorDef{AppCode: "QUOTA", Code: http.StatusTooManyRequests, Message: "quota exceeded"}
+	got := roundTripError(t, def.New())
+
+	for _, target := range []error{def, ErrClientError, NewError("", http.StatusTooManyRequests)} {
+		if !errors.Is(got, target) {
+			t.Errorf("got %v, which is not %v", got, target)
+		}
+	}
+	if errors.Is(got, ErrServerError) {
+		t.Errorf("got %v, which is %v", got, ErrServerError)
+	}
+}
+
+func TestWriteErrExtra(t *testing.T) {
+	var je *Error
+	if err := json.Unmarshal([]byte(`{"code":409,"message":"blah","revision":3}`), &je); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteErr(rr, je); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"error":{"code":409,"message":"blah","revision":3}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import "net/http"
+
+// ErrorLogFunc is called when a server error response is written in reply to r. The status code
+// of the response is code, and err is the underlying error. If the response was written by a
+// function that accepts an error, such as WriteErr, err is that error. Otherwise, it is the Error
+// written.
+type ErrorLogFunc func(r *http.Request, code int, err error)
+
+// WithErrorLog causes f to be called when WriteError, WriteErr or a related function writes a
+// response with a 5xx status code in reply to r. It is called before the response is written, with
+// the original error, even if production mode is enabled, although secrets are replaced with
+// Redacted in the details of an Error. It is also called with the encoding error when a response
+// fails to encode, and a 500 status code is written in its place.
+func WithErrorLog(r *http.Request, f ErrorLogFunc) Option {
+	return func(o *options) {
+		o.errorLogRequest = r
+		o.errorLog = f
+	}
+}
+
+// logError reports the server error je, caused by cause if non-nil, to the hook established by
+// WithErrorLog. Secrets are redacted from the details of je, and of cause if it is an Error.
+func (o *options) logError(je *Error, cause error) {
+	if o.errorLog == nil || je.Code < http.StatusInternalServerError {
+		return
+	}
+	if cause == nil {
+		cause = je
+	}
+	if c, ok := cause.(*Error); ok {
+		cause = o.redactLoggedError(c)
+	}
+	o.errorLog(o.errorLogRequest, je.Code, cause)
+}
+
+// redactLoggedError returns je with secrets redacted from its details, as by redactError. If the
+// details cannot be redacted, they are omitted.
+func (o *options) redactLoggedError(je *Error) *Error {
+	r, err := o.redactError(je)
+	if err != nil {
+		c := *je
+		c.Details = nil
+		return &c
+	}
+	return r
+}
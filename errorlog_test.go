@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithErrorLog(t *testing.T) {
+	errCause := errors.New("database unavailable")
+
+	tests := []struct {
+		name      string
+		write     func(w http.ResponseWriter, opts ...Option) error
+		wantCalls int
+		wantCode  int
+		wantErr   error
+	}{
+		{"ClientError", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteError(w, "blah", http.StatusNotFound, opts...)
+		}, 0, 0, nil},
+		{"ServerError", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteError(w, "blah", http.StatusServiceUnavailable, opts...)
+		}, 1, http.StatusServiceUnavailable, &Error{Code: http.StatusServiceUnavailable, Message: "blah"}},
+		{"Err", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteErr(w, errCause, opts...)
+		}, 1, http.StatusInternalServerError, errCause},
+		{"ErrorFrom", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteErrorFrom(w, errCause, http.StatusBadGateway, opts...)
+		}, 1, http.StatusBadGateway, errCause},
+		{"ErrorFromClientError", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteErrorFrom(w, errCause, http.StatusBadRequest, opts...)
+		}, 0, 0, nil},
+		{"Response", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteResponse(w, "blah", http.StatusInternalServerError, opts...)
+		}, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/things", nil)
+
+			var calls, gotCode int
+			var gotErr error
+			f := func(lr *http.Request, code int, err error) {
+				if lr != r {
+					t.Errorf("got request %p, want %p", lr, r)
+				}
+				calls++
+				gotCode, gotErr = code, err
+			}
+
+			if err := tt.write(httptest.NewRecorder(), WithErrorLog(r, f)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := calls, tt.wantCalls; got != want {
+				t.Fatalf("got %v calls, want %v", got, want)
+			}
+			if got, want := gotCode, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if tt.wantErr != nil && !errors.Is(gotErr, tt.wantErr) {
+				t.Errorf("got error %v, want %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithErrorLogProduction(t *testing.T) {
+	SetProduction(true, nil)
+	defer SetProduction(false, nil)
+
+	errCause := errors.New("secret")
+
+	var gotErr error
+	f := func(_ *http.Request, _ int, err error) { gotErr = err }
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := WriteErr(httptest.NewRecorder(), errCause, WithErrorLog(r, f)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := gotErr, errCause; got != want {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,469 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// The encoding in this file produces the same output as encoding/json for the response envelopes
+// most commonly written, such as errors and small flat structs, without reflection-driven encoding,
+// and with a single heap allocation, for the header values. Anything it does not handle is left to
+// encoding/json, so its checks err on the side of declining.
+
+// jsonMediaType is the Content-Type header value of JSON responses without a charset.
+const jsonMediaType = "application/json"
+
+// maxSharedContentLength bounds the Content-Length header values held by contentLengths.
+const maxSharedContentLength = 1024
+
+var (
+	contentLengthsOnce sync.Once
+	contentLengths     []string
+)
+
+// contentLengthValue returns the Content-Length header value for a body of n bytes. Values for
+// small bodies are formatted once and shared.
+func contentLengthValue(n int) string {
+	if n >= maxSharedContentLength {
+		return strconv.Itoa(n)
+	}
+	contentLengthsOnce.Do(func() {
+		contentLengths = make([]string, maxSharedContentLength)
+		for i := range contentLengths {
+			contentLengths[i] = strconv.Itoa(i)
+		}
+	})
+	return contentLengths[n]
+}
+
+// contentLength returns the Content-Length header values for a body of n bytes. The slice is
+// owned by the caller, as a handler or middleware may modify the header values of a response.
+func contentLength(n int) []string {
+	return []string{contentLengthValue(n)}
+}
+
+// jsonHeaderValues returns the Content-Type and Content-Length header values of a JSON response
+// with a body of n bytes, using a single allocation. Each slice has a capacity of 1, so that
+// appending to one does not modify the other.
+func jsonHeaderValues(n int) (ct, cl []string) {
+	vs := [2]string{jsonMediaType, contentLengthValue(n)}
+	s := vs[:]
+	return s[0:1:1], s[1:2:2]
+}
+
+// fastEncodable reports whether the envelope of jr, as written with o, may be encoded by
+// encodeFast.
+func (o *options) fastEncodable(jr Response) bool {
+	if o.marshal != nil || o.format != nil || o.canonical || o.bare || o.prefix != "" || o.indent != "" {
+		return false
+	}
+	if o.fieldNames != (FieldNames{}) || (o.explicitNull && jr.Error == nil) {
+		return false
+	}
+	return jr.Page == nil && len(jr.Warnings) == 0 && len(jr.Meta) == 0 && len(jr.Links) == 0
+}
+
+// encodeFast encodes the envelope of jr into es, and reports whether it was able to. If not, es
+// is left unmodified.
+func (es *encodeState) encodeFast(jr Response) bool {
+	n := es.Len()
+
+	var ok bool
+	switch {
+	case jr.Error != nil && jr.Data == nil:
+		es.WriteString(`{"error":`)
+		ok = appendError(&es.Buffer, jr.Error)
+	case jr.Error == nil && jr.Data != nil:
+		es.WriteString(`{"data":`)
+		ok = appendFlat(&es.Buffer, reflect.ValueOf(jr.Data))
+	}
+	if !ok {
+		es.Truncate(n)
+		return false
+	}
+	es.WriteByte('}')
+	return true
+}
+
+// encodeResponse encodes the envelope of jr into es, applying o, using encodeFast where possible.
+func (es *encodeState) encodeResponse(jr Response, o *options) error {
+	if o.fastEncodable(jr) && es.encodeFast(jr) {
+		return nil
+	}
+	return es.encode(o.body(jr), o)
+}
+
+// appendError appends the JSON encoding of je to buf, and reports whether it was able to.
+func appendError(buf *bytes.Buffer, je *Error) bool {
+	if len(je.Details) > 0 || len(je.MessageArgs) > 0 || len(je.Context) > 0 || je.Debug != nil || len(je.Extra) > 0 {
+		return false
+	}
+	if je.Code == 0 && je.RawCode != "" {
+		return false
+	}
+
+	sep := byte('{')
+	field := func(name string) {
+		buf.WriteByte(sep)
+		sep = ','
+		buf.WriteString(name)
+	}
+	ok := true
+	str := func(name, s string) {
+		if s != "" && ok {
+			field(name)
+			ok = appendString(buf, s)
+		}
+	}
+	num := func(name string, n int) {
+		if n != 0 {
+			field(name)
+			var scratch [24]byte
+			buf.Write(strconv.AppendInt(scratch[:0], int64(n), 10))
+		}
+	}
+
+	num(`"code":`, je.Code)
+	str(`"appCode":`, je.AppCode)
+	str(`"message":`, je.Message)
+	str(`"messageKey":`, je.MessageKey)
+	num(`"retryAfter":`, je.RetryAfter)
+	str(`"requestId":`, je.RequestID)
+	if sep == '{' {
+		buf.WriteByte(sep)
+	}
+	buf.WriteByte('}')
+	return ok
+}
+
+// appendString appends the JSON encoding of s to buf, escaping HTML characters as encoding/json
+// does, and reports whether it was able to. Control characters other than newlines, carriage
+// returns and tabs, and invalid UTF-8, whose encodings vary between Go releases, are declined.
+func appendString(buf *bytes.Buffer, s string) bool {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			buf.WriteString(s[start:i])
+			switch c {
+			case '"', '\\':
+				buf.WriteByte('\\')
+				buf.WriteByte(c)
+			case '\n':
+				buf.WriteString(`\n`)
+			case '\r':
+				buf.WriteString(`\r`)
+			case '\t':
+				buf.WriteString(`\t`)
+			case '<', '>', '&':
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			default:
+				return false
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return false
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf.WriteString(s[start:i])
+			buf.WriteString(`\u202`)
+			buf.WriteByte(hexDigits[r&0xf])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf.WriteString(s[start:])
+	buf.WriteByte('"')
+	return true
+}
+
+const hexDigits = "0123456789abcdef"
+
+// fastField describes a field of a flat struct type.
+type fastField struct {
+	index     int
+	key       string // encoded object key, followed by a colon
+	omitEmpty bool
+}
+
+// fastFieldsCache caches the values returned by fastFields.
+var fastFieldsCache sync.Map // map[reflect.Type][]fastField
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// hasMarshaler reports whether t, or a pointer to t, customizes its encoding.
+func hasMarshaler(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t.Implements(marshalerType) || pt.Implements(marshalerType) ||
+		t.Implements(textMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+// isFastKind reports whether values of kind k are encoded by appendScalar.
+func isFastKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+// fastFields returns the fields of the struct type t, in the order they are encoded, and reports
+// whether t is flat: its exported fields are all scalars without custom encodings, and none are
+// embedded or renamed in a way that requires encoding/json to resolve.
+func fastFields(t reflect.Type) ([]fastField, bool) {
+	if v, ok := fastFieldsCache.Load(t); ok {
+		fs := v.([]fastField)
+		return fs, fs != nil
+	}
+
+	fs, ok := computeFastFields(t)
+	if !ok {
+		fs = nil
+	} else if fs == nil {
+		fs = []fastField{}
+	}
+	fastFieldsCache.Store(t, fs)
+	return fs, ok
+}
+
+// computeFastFields computes the value returned by fastFields.
+func computeFastFields(t reflect.Type) ([]fastField, bool) {
+	if hasMarshaler(t) {
+		return nil, false
+	}
+
+	pfs, ok := plainFields(t)
+	if !ok {
+		return nil, false
+	}
+	var fs []fastField
+	for _, pf := range pfs {
+		if ft := t.Field(pf.index).Type; !isFastKind(ft.Kind()) || hasMarshaler(ft) {
+			return nil, false
+		}
+		fs = append(fs, fastField{index: pf.index, key: `"` + pf.name + `":`, omitEmpty: pf.omitEmpty})
+	}
+	return fs, true
+}
+
+// plainField describes an exported field of a struct type, as encoded by encoding/json.
+type plainField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+// plainFields returns the fields of the struct type t encoded by encoding/json, in the order they
+// are encoded, and reports whether t is plain: none of its fields are embedded, have options other
+// than omitempty, or are named in a way that requires encoding/json to resolve.
+func plainFields(t reflect.Type) ([]plainField, bool) {
+	var fs []plainField
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			return nil, false
+		}
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		pf := plainField{index: i}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				pf.omitEmpty = true
+			case "":
+			default:
+				// Options such as string and omitzero are left to encoding/json.
+				return nil, false
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if names[name] || !isPlainKey(name) {
+			return nil, false
+		}
+		names[name] = true
+		pf.name = name
+		fs = append(fs, pf)
+	}
+	return fs, true
+}
+
+// isPlainKey reports whether name is encoded as an object key without escaping.
+func isPlainKey(name string) bool {
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// appendFlat appends the JSON encoding of v, a scalar or flat struct or a non-nil pointer to one,
+// to buf, and reports whether it was able to.
+func appendFlat(buf *bytes.Buffer, v reflect.Value) bool {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() || hasMarshaler(v.Type()) {
+			return false
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		if !isFastKind(v.Kind()) || hasMarshaler(v.Type()) {
+			return false
+		}
+		return appendScalar(buf, v)
+	}
+
+	fs, ok := fastFields(v.Type())
+	if !ok {
+		return false
+	}
+	sep := byte('{')
+	for _, f := range fs {
+		fv := v.Field(f.index)
+		if f.omitEmpty && isEmptyScalar(fv) {
+			continue
+		}
+		buf.WriteByte(sep)
+		sep = ','
+		buf.WriteString(f.key)
+		if !appendScalar(buf, fv) {
+			return false
+		}
+	}
+	if sep == '{' {
+		buf.WriteByte(sep)
+	}
+	buf.WriteByte('}')
+	return true
+}
+
+// isEmptyScalar reports whether the scalar v is omitted by the omitempty option.
+func isEmptyScalar(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		// Unlike reflect.Value.IsZero, negative zero is empty.
+		return v.Float() == 0
+	}
+	return v.IsZero()
+}
+
+// appendScalar appends the JSON encoding of the scalar v to buf, and reports whether it was able
+// to.
+func appendScalar(buf *bytes.Buffer, v reflect.Value) bool {
+	var scratch [32]byte
+	switch v.Kind() {
+	case reflect.Bool:
+		buf.Write(strconv.AppendBool(scratch[:0], v.Bool()))
+	case reflect.String:
+		return appendString(buf, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.Write(strconv.AppendInt(scratch[:0], v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf.Write(strconv.AppendUint(scratch[:0], v.Uint(), 10))
+	case reflect.Float32:
+		return appendFloat(buf, scratch[:0], v.Float(), 32)
+	case reflect.Float64:
+		return appendFloat(buf, scratch[:0], v.Float(), 64)
+	default:
+		return false
+	}
+	return true
+}
+
+// appendFloat appends the JSON encoding of f, of the supplied bit size, to buf, formatted as by
+// encoding/json. NaN and infinite values cannot be encoded, so are declined.
+func appendFloat(buf *bytes.Buffer, scratch []byte, f float64, bits int) bool {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(scratch, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	buf.Write(b)
+	return true
+}
+
+// writeErrorFast writes the response written by WriteError without options, and reports whether
+// it was able to. It declines if response hooks, debug mode, production mode or the defaults
+// established by SetIndent or SetDefaults require the full write path.
+func writeErrorFast(w http.ResponseWriter, message string, code int) (bool, error) {
+	if !bodyAllowed(code) || isDebug() || (code >= http.StatusInternalServerError && isProduction()) {
+		return false, nil
+	}
+	if hasResponseHooks() || hasDefaults() {
+		return false, nil
+	}
+
+	es := newEncodeState()
+	defer es.release()
+
+	je := Error{Code: code, Message: message}
+	if !es.encodeFast(Response{Error: &je}) {
+		return false, nil
+	}
+
+	h := w.Header()
+	h["Content-Type"], h["Content-Length"] = jsonHeaderValues(es.Len())
+	w.WriteHeader(code)
+	n, err := w.Write(es.Bytes())
+	if m := currentMetrics(); m != nil {
+		m.ObserveResponse(code, n)
+	}
+	if err != nil {
+		return true, fmt.Errorf("jsonresp: failed to write response: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// slowEncode returns the encoding of the envelope of jr, without using encodeFast.
+func slowEncode(t *testing.T, jr Response) string {
+	t.Helper()
+
+	o := newOptions(nil)
+	es := newEncodeState()
+	defer es.release()
+	if err := es.encode(o.body(jr), o); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	return es.String()
+}
+
+func TestEncodeFast(t *testing.T) {
+	type flat struct {
+		String   string  `json:"string"`
+		Int      int     `json:"int,omitempty"`
+		Uint8    uint8   `json:"uint8"`
+		Float32  float32 `json:"float32"`
+		Float64  float64 `json:"float64,omitempty"`
+		Bool     bool    `json:"bool"`
+		Skipped  string  `json:"-"`
+		Untagged string
+		private  string
+	}
+	type embedded struct {
+		flat
+		Extra string `json:"extra"`
+	}
+	type stringOption struct {
+		Int int `json:"int,string"`
+	}
+	type nested struct {
+		Time time.Time `json:"time"`
+	}
+	type pointer struct {
+		Int *int `json:"int"`
+	}
+
+	tests := []struct {
+		name   string
+		jr     Response
+		wantOK bool
+	}{
+		{"Error", Response{Error: &Error{Code: http.StatusNotFound, Message: "blah"}}, true},
+		{"ErrorEmptyMessage", Response{Error: &Error{Code: http.StatusNotFound}}, true},
+		{"ErrorHTML", Response{Error: &Error{Code: 400, Message: `<a href="x">&</a>`}}, true},
+		{"ErrorEscapes", Response{Error: &Error{Code: 400, Message: "a\"b\\c\nd\re\tf"}}, true},
+		{"ErrorUnicode", Response{Error: &Error{Code: 400, Message: "héllo, 世界   "}}, true},
+		{"ErrorInvalidUTF8", Response{Error: &Error{Code: 400, Message: "a\xffb"}}, false},
+		{"ErrorControl", Response{Error: &Error{Code: 400, Message: "a\x01b"}}, false},
+		{"ErrorFields", Response{Error: &Error{
+			Code:       http.StatusTooManyRequests,
+			AppCode:    "rate_limited",
+			Message:    "blah",
+			MessageKey: "errors.rate",
+			RetryAfter: 30,
+			RequestID:  "abc",
+		}}, true},
+		{"ErrorDetails", Response{Error: &Error{Code: 400, Details: map[string]interface{}{"a": 1}}}, false},
+		{"Flat", Response{Data: flat{"blah", 1, 2, 0.25, 1.5, true, "x", "y", "z"}}, true},
+		{"FlatPointer", Response{Data: &flat{String: "blah"}}, true},
+		{"FlatEmpty", Response{Data: flat{}}, true},
+		{"FlatNegativeZero", Response{Data: flat{Float64: math.Copysign(0, -1)}}, true},
+		{"FlatLargeFloat", Response{Data: flat{Float32: 1e21, Float64: 1e21}}, true},
+		{"FlatSmallFloat", Response{Data: flat{Float32: 1e-7, Float64: 1e-7}}, true},
+		{"FlatMaxFloat", Response{Data: flat{Float32: math.MaxFloat32, Float64: math.MaxFloat64}}, true},
+		{"FlatNaN", Response{Data: flat{Float64: math.NaN()}}, false},
+		{"Embedded", Response{Data: embedded{}}, false},
+		{"StringOption", Response{Data: stringOption{1}}, false},
+		{"Nested", Response{Data: nested{}}, false},
+		{"Pointer", Response{Data: pointer{}}, false},
+		{"NilPointer", Response{Data: (*flat)(nil)}, false},
+		{"Map", Response{Data: map[string]string{"a": "b"}}, false},
+		{"String", Response{Data: "blah"}, true},
+		{"DataAndError", Response{Data: flat{}, Error: &Error{Code: 400}}, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			es := newEncodeState()
+			defer es.release()
+
+			es.WriteString("prefix")
+			if got, want := es.encodeFast(tt.jr), tt.wantOK; got != want {
+				t.Fatalf("got ok %v, want %v", got, want)
+			}
+			if !tt.wantOK {
+				if got, want := es.String(), "prefix"; got != want {
+					t.Errorf("got %q, want %q", got, want)
+				}
+				return
+			}
+			if got, want := es.String(), "prefix"+slowEncode(t, tt.jr); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFastEncodable(t *testing.T) {
+	jr := Response{Error: &Error{Code: 400}}
+
+	tests := []struct {
+		name   string
+		opts   []Option
+		jr     Response
+		wantOK bool
+	}{
+		{"Default", nil, jr, true},
+		{"Indent", []Option{WithIndent("", "  ")}, jr, false},
+		{"Canonical", []Option{WithCanonical()}, jr, false},
+		{"Meta", []Option{WithMeta("a", "b")}, jr, false},
+		{"ExplicitNullError", []Option{WithExplicitNull()}, jr, true},
+		{"ExplicitNullData", []Option{WithExplicitNull()}, Response{Data: 1}, false},
+		{"Page", nil, Response{Data: 1, Page: &PageDetails{}}, false},
+		{"Warnings", nil, Response{Data: 1, Warnings: []Warning{{Message: "blah"}}}, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOptions(tt.opts)
+			jr := o.envelope(tt.jr)
+			if got, want := o.fastEncodable(jr), tt.wantOK; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestContentLength(t *testing.T) {
+	for _, n := range []int{0, 1, 1023, 1024, 123456} {
+		v := contentLength(n)
+		if got, want := len(v), 1; got != want {
+			t.Fatalf("got length %v, want %v", got, want)
+		}
+		if got, want := cap(v), 1; got != want {
+			t.Errorf("got capacity %v, want %v", got, want)
+		}
+		if got, want := v[0], strconv.Itoa(n); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWriteErrorFast(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   int
+		wantOK bool
+	}{
+		{"NotFound", http.StatusNotFound, true},
+		{"InternalServerError", http.StatusInternalServerError, true},
+		{"NoContent", http.StatusNoContent, false},
+		{"NotModified", http.StatusNotModified, false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			ok, err := writeErrorFast(rr, "blah", tt.code)
+			if err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+			if got, want := ok, tt.wantOK; got != want {
+				t.Fatalf("got ok %v, want %v", got, want)
+			}
+			if !ok {
+				if got, want := rr.Body.Len(), 0; got != want {
+					t.Errorf("got body length %v, want %v", got, want)
+				}
+				return
+			}
+
+			want := httptest.NewRecorder()
+			o := newOptions(nil)
+			if err := encodeResponse(want, Response{Error: &Error{Code: tt.code, Message: "blah"}}, tt.code, o); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+			if got, want := rr.Code, want.Code; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), want.Body.String(); got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+			for _, k := range []string{"Content-Type", "Content-Length"} {
+				if got, want := rr.Header().Get(k), want.Header().Get(k); got != want {
+					t.Errorf("got %v %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteErrorAllocs(t *testing.T) {
+	w := &discardResponseWriter{h: make(http.Header)}
+
+	// The header values are owned by each response, so are allocated.
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = WriteError(w, "blah", http.StatusNotFound)
+	})
+	if allocs != 1 {
+		t.Errorf("got %v allocations, want 1", allocs)
+	}
+}
+
+func TestWriteErrorFastHeaderValues(t *testing.T) {
+	rr1 := httptest.NewRecorder()
+	if err := WriteError(rr1, "blah", http.StatusNotFound); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	rr1.Header()["Content-Type"][0] = "text/plain"
+	rr1.Header()["Content-Length"][0] = "0"
+
+	rr2 := httptest.NewRecorder()
+	if err := WriteError(rr2, "blah", http.StatusNotFound); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr2.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got content type %v, want %v", got, want)
+	}
+	if got, want := rr2.Header().Get("Content-Length"), strconv.Itoa(rr2.Body.Len()); got != want {
+		t.Errorf("got content length %v, want %v", got, want)
+	}
+}
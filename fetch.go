@@ -0,0 +1,103 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrRepeatedPage is returned by FetchAll when the next link of a page refers to a page already
+// retrieved, which would otherwise cause pages to be retrieved indefinitely.
+var ErrRepeatedPage = errors.New("jsonresp: next page link repeats a page already fetched")
+
+// WithMaxPages causes FetchAll to stop after retrieving n pages. A value of zero or less, the
+// default, places no limit on the number of pages.
+func WithMaxPages(n int) Option {
+	return func(o *options) {
+		o.maxPages = n
+	}
+}
+
+// WithMaxItems causes FetchAll to stop once n items have been collected, discarding any excess
+// items of the final page. A value of zero or less, the default, places no limit on the number of
+// items.
+func WithMaxItems(n int) Option {
+	return func(o *options) {
+		o.maxItems = n
+	}
+}
+
+// FetchAll retrieves the paged collection located at rawURL using c, following the next link of
+// each page until the last page is reached, and returns the items of every page in order. If c is
+// nil, http.DefaultClient is used. Relative next links are resolved against the URL of the page
+// in which they appear.
+//
+// The number of pages retrieved, and items collected, may be capped using WithMaxPages and
+// WithMaxItems. Reaching a cap is not an error. If a next link refers to a page already retrieved,
+// an error wrapping ErrRepeatedPage is returned. The supplied options are applied when reading each
+// page, in the same way as ReadHTTPResponse.
+func FetchAll[T any](ctx context.Context, c *http.Client, rawURL string, opts ...Option) ([]T, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	o := newOptions(opts)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("jsonresp: failed to fetch page: %w", err)
+	}
+
+	var all []T
+	seen := make(map[string]bool)
+	for pages := 1; ; pages++ {
+		seen[u.String()] = true
+		items, pd, err := fetchPage[T](ctx, c, u.String(), opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+
+		if o.maxItems > 0 && len(all) >= o.maxItems {
+			return all[:o.maxItems], nil
+		}
+		if pd == nil || pd.Next == "" || (o.maxPages > 0 && pages >= o.maxPages) {
+			return all, nil
+		}
+
+		next, err := u.Parse(pd.Next)
+		if err != nil {
+			return nil, fmt.Errorf("jsonresp: failed to parse next page link: %w", err)
+		}
+		if seen[next.String()] {
+			return nil, fmt.Errorf("%w: %v", ErrRepeatedPage, next)
+		}
+		u = next
+	}
+}
+
+// fetchPage retrieves the page located at url using c, and returns its items and page details.
+func fetchPage[T any](ctx context.Context, c *http.Client, url string, opts []Option) ([]T, *PageDetails, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("jsonresp: failed to fetch page: %w", err)
+	}
+
+	res, err := newOptions(opts).do(c, req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("jsonresp: failed to fetch page: %w", err)
+	}
+
+	var items []T
+	pd, err := ReadHTTPResponse(res, &items, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, pd, nil
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// pagedHandler serves the items 1 to n in pages of the supplied size, linking each page to the
+// next using a relative URL.
+func pagedHandler(n, size int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+
+		var items []int
+		for i := start; i < n && i < start+size; i++ {
+			items = append(items, i+1)
+		}
+
+		var pd *PageDetails
+		if start+size < n {
+			pd = &PageDetails{Next: "?start=" + strconv.Itoa(start+size), TotalSize: int64(n)}
+		}
+		_ = WriteResponsePage(w, items, pd, http.StatusOK)
+	})
+}
+
+func TestFetchAll(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		opts      []Option
+		wantItems []int
+		wantPages int
+	}{
+		{"Empty", 0, nil, nil, 1},
+		{"OnePage", 2, nil, []int{1, 2}, 1},
+		{"FullPage", 3, nil, []int{1, 2, 3}, 1},
+		{"ManyPages", 7, nil, []int{1, 2, 3, 4, 5, 6, 7}, 3},
+		{"MaxPages", 7, []Option{WithMaxPages(2)}, []int{1, 2, 3, 4, 5, 6}, 2},
+		{"MaxPagesUnreached", 2, []Option{WithMaxPages(2)}, []int{1, 2}, 1},
+		{"MaxItems", 7, []Option{WithMaxItems(4)}, []int{1, 2, 3, 4}, 2},
+		{"MaxItemsPageBoundary", 7, []Option{WithMaxItems(3)}, []int{1, 2, 3}, 1},
+		{"MaxItemsUnreached", 2, []Option{WithMaxItems(4)}, []int{1, 2}, 1},
+		{"MaxPagesAndItems", 7, []Option{WithMaxPages(1), WithMaxItems(4)}, []int{1, 2, 3}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages int
+			h := pagedHandler(tt.n, 3)
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				pages++
+				h.ServeHTTP(w, r)
+			}))
+			defer s.Close()
+
+			items, err := FetchAll[int](context.Background(), s.Client(), s.URL+"/items", tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to fetch: %v", err)
+			}
+
+			if got, want := items, tt.wantItems; !reflect.DeepEqual(got, want) {
+				t.Errorf("got items %v, want %v", got, want)
+			}
+			if got, want := pages, tt.wantPages; got != want {
+				t.Errorf("got %v pages, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFetchAllError(t *testing.T) {
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests > 1 {
+			_ = WriteError(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_ = WriteResponsePage(w, []int{1}, &PageDetails{Next: "/items?page=2"}, http.StatusOK)
+	}))
+	defer s.Close()
+
+	items, err := FetchAll[int](context.Background(), s.Client(), s.URL+"/items")
+	if got, want := err, (&Error{Code: http.StatusServiceUnavailable}); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+	if items != nil {
+		t.Errorf("got items %v, want nil", items)
+	}
+}
+
+func TestFetchAllContextCanceled(t *testing.T) {
+	s := httptest.NewServer(pagedHandler(7, 3))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := FetchAll[int](ctx, s.Client(), s.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestFetchAllRepeatedPage(t *testing.T) {
+	tests := []struct {
+		name string
+		next map[string]string
+	}{
+		{"Self", map[string]string{"/items": "/items"}},
+		{"Earlier", map[string]string{"/items": "?page=2", "2": "/items?page=3", "3": "/items?page=2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				key := r.URL.Query().Get("page")
+				if key == "" {
+					key = r.URL.Path
+				}
+				_ = WriteResponsePage(w, []int{requests}, &PageDetails{Next: tt.next[key]}, http.StatusOK)
+			}))
+			defer s.Close()
+
+			items, err := FetchAll[int](context.Background(), s.Client(), s.URL+"/items")
+			if !errors.Is(err, ErrRepeatedPage) {
+				t.Errorf("got error %v, want %v", err, ErrRepeatedPage)
+			}
+			if items != nil {
+				t.Errorf("got items %v, want nil", items)
+			}
+			if got, want := requests, len(tt.next); got != want {
+				t.Errorf("got %v requests, want %v", got, want)
+			}
+		})
+	}
+}
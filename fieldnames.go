@@ -0,0 +1,125 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// FieldNames specifies the object keys of the fields of the response envelope. An empty name
+// leaves the default key of the corresponding field unchanged.
+type FieldNames struct {
+	Data     string // Defaults to "data".
+	Page     string // Defaults to "page".
+	Error    string // Defaults to "error".
+	Warnings string // Defaults to "warnings".
+	Meta     string // Defaults to "meta".
+	Links    string // Defaults to "links".
+}
+
+// WithFieldNames causes responses to be written and read using the object keys specified by fn
+// for the fields of the response envelope, so that APIs using keys such as "result" or "errors"
+// can be served and consumed. WithFieldNames has no effect when used with WithFormat.
+func WithFieldNames(fn FieldNames) Option {
+	return func(o *options) {
+		o.fieldNames = fn
+	}
+}
+
+// name returns the object key of the envelope field with the default key key.
+func (fn FieldNames) name(key string) string {
+	var s string
+	switch key {
+	case "data":
+		s = fn.Data
+	case "page":
+		s = fn.Page
+	case "error":
+		s = fn.Error
+	case "warnings":
+		s = fn.Warnings
+	case "meta":
+		s = fn.Meta
+	case "links":
+		s = fn.Links
+	}
+	if s == "" {
+		return key
+	}
+	return s
+}
+
+type renamedTypeKey struct {
+	t        reflect.Type
+	fn       FieldNames
+	explicit bool
+}
+
+// renamedTypes caches the types returned by renamedType.
+var renamedTypes sync.Map // map[renamedTypeKey]reflect.Type
+
+// renamedType returns a struct type identical to t, other than the JSON object keys of its
+// fields, which are renamed according to fn. If explicit is true, the data and page fields are
+// encoded even when empty.
+func renamedType(t reflect.Type, fn FieldNames, explicit bool) reflect.Type {
+	k := renamedTypeKey{t, fn, explicit}
+	if rt, ok := renamedTypes.Load(k); ok {
+		return rt.(reflect.Type)
+	}
+
+	fs := make([]reflect.StructField, t.NumField())
+	for i := range fs {
+		f := t.Field(i)
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			name, opts, _ := strings.Cut(tag, ",")
+			if explicit && (name == "data" || name == "page") {
+				opts = strings.TrimPrefix(strings.Replace(","+opts, ",omitempty", "", 1), ",")
+			}
+			if opts != "" {
+				opts = "," + opts
+			}
+			f.Tag = reflect.StructTag(`json:"` + fn.name(name) + opts + `"`)
+		}
+		fs[i] = f
+	}
+
+	rt, _ := renamedTypes.LoadOrStore(k, reflect.StructOf(fs))
+	return rt.(reflect.Type)
+}
+
+// renameFields returns v, converted to a type whose envelope fields are encoded using the object
+// keys established by o, if v is a response envelope and o renames any of its fields. Responses
+// without an error are additionally converted to encode empty data and page fields if o requires
+// it, regardless of its format.
+func (o *options) renameFields(v interface{}) interface{} {
+	fn := o.fieldNames
+	if o.format != nil {
+		// Formats are converted from JSON with the default field names.
+		fn = FieldNames{}
+	}
+
+	// The original value is returned where no conversion is required, as returning a Response
+	// held in a new interface value would allocate.
+	switch jr := v.(type) {
+	case Response:
+		explicit := o.explicitNull && jr.Error == nil
+		if fn == (FieldNames{}) && !explicit {
+			return v
+		}
+		rv := reflect.ValueOf(v)
+		return rv.Convert(renamedType(rv.Type(), fn, explicit)).Interface()
+	case *rawResponse:
+		if fn == (FieldNames{}) {
+			return v
+		}
+		// The converted pointer refers to the same value, so decoding into it populates v.
+		rv := reflect.ValueOf(v)
+		return rv.Convert(reflect.PtrTo(renamedType(rv.Type().Elem(), fn, false))).Interface()
+	}
+	return v
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithFieldNames(t *testing.T) {
+	fn := WithFieldNames(FieldNames{Data: "result", Page: "pagination", Error: "errors"})
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"Default", nil, `{"data":[1,2],"page":{"next":"n"}}`},
+		{"Renamed", []Option{fn}, `{"result":[1,2],"pagination":{"next":"n"}}`},
+		{"Partial", []Option{WithFieldNames(FieldNames{Page: "pagination"})}, `{"data":[1,2],"pagination":{"next":"n"}}`},
+		{"Stream", []Option{fn, WithStream()}, `{"result":[1,2],"pagination":{"next":"n"}}`},
+		{"Codec", []Option{fn, WithCodec(&testCodec{})}, `{"result":[1,2],"pagination":{"next":"n"}}`},
+		{"StreamCodec", []Option{fn, WithCodec(&testCodec{}), WithStream()}, `{"result":[1,2],"pagination":{"next":"n"}}`},
+		{"Format", []Option{fn, WithFormat(JSend)}, `{"status":"success","data":[1,2],"page":{"next":"n"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponsePage(rr, []int{1, 2}, &PageDetails{Next: "n"}, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := rr.Body.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+
+			var got []int
+			pd, err := ReadResponsePage(rr.Body, &got, tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got data %v, want %v", got, want)
+			}
+			if got, want := pd, (&PageDetails{Next: "n"}); !reflect.DeepEqual(got, want) {
+				t.Errorf("got page %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithFieldNamesError(t *testing.T) {
+	fn := WithFieldNames(FieldNames{Error: "errors"})
+
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, fn); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"errors":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	want := &Error{Code: http.StatusNotFound, Message: "blah"}
+	if err := ReadError(strings.NewReader(rr.Body.String()), fn); !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+	if err := ReadResponse(strings.NewReader(rr.Body.String()), nil, fn); !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+
+	// Without the field names, the error is not recognized.
+	if err := ReadError(strings.NewReader(rr.Body.String())); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
+
+func TestDecodeResponseFieldNames(t *testing.T) {
+	r := strings.NewReader(`{"result":"blah","info":{"requestId":"r"}}`)
+
+	jr, err := DecodeResponse(r, WithFieldNames(FieldNames{Data: "result", Meta: "info"}))
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got, want := jr.Data, json.RawMessage(`"blah"`); !reflect.DeepEqual(got, want) {
+		t.Errorf("got data %s, want %s", got, want)
+	}
+	if got, want := jr.Meta, map[string]interface{}{"requestId": "r"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got meta %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// FieldSet is a selection of the fields of the data of a response, keyed by object key. A nil
+// FieldSet value selects the entire field, while a non-nil value selects only the fields of the
+// object that it contains.
+type FieldSet map[string]FieldSet
+
+// ParseFields parses the field selection expression expr, a comma-separated list of object keys.
+// Keys of nested objects are selected using dotted paths, such that "id,owner.name" selects the
+// id field and the name field of the owner object. Selecting an object selects all of its fields,
+// regardless of any paths that select its nested fields.
+//
+// If expr is invalid, the returned error is an Error with a 400 status code, suitable for writing
+// with WriteRequestError.
+func ParseFields(expr string) (FieldSet, error) {
+	fs := FieldSet{}
+	for _, s := range strings.Split(expr, ",") {
+		path := strings.Split(strings.TrimSpace(s), ".")
+		for _, k := range path {
+			if k == "" {
+				return nil, invalidParameter("fields", "must not contain empty fields")
+			}
+		}
+		fs.add(path)
+	}
+	return fs, nil
+}
+
+// add adds the field identified by path to fs.
+func (fs FieldSet) add(path []string) {
+	sub, ok := fs[path[0]]
+	switch {
+	case len(path) == 1:
+		fs[path[0]] = nil
+	case ok && sub == nil:
+		// The entire field is already selected.
+	default:
+		if sub == nil {
+			sub = FieldSet{}
+			fs[path[0]] = sub
+		}
+		sub.add(path[1:])
+	}
+}
+
+// BindFieldsQuery parses the fields query parameter of r; see ParseFields. If the parameter is not
+// present, a nil FieldSet is returned, which selects all fields when supplied to WithFields.
+func BindFieldsQuery(r *http.Request) (FieldSet, error) {
+	v := r.URL.Query().Get("fields")
+	if v == "" {
+		return nil, nil //nolint:nilnil // a nil FieldSet selects all fields
+	}
+	return ParseFields(v)
+}
+
+// WithFields causes only the fields of the data of a successful response selected by fs to be
+// written, reducing the size of the response. If the data is an array, the selection applies to
+// each of its elements. Values other than objects are written in full. A nil fs selects all
+// fields.
+func WithFields(fs FieldSet) Option {
+	return func(o *options) {
+		o.fields = fs
+	}
+}
+
+// transformData returns jr with its secrets replaced with Redacted, its data redacted according to
+// WithRedaction, its times and durations formatted according to WithTimeFormat and
+// WithDurationFormat, and reduced to the fields selected by WithFields.
+func (o *options) transformData(jr Response) (Response, error) {
+	jr, err := o.redactEnvelope(jr)
+	if err != nil {
+		return Response{}, err
+	}
+	if jr.Data == nil || jr.Error != nil {
+		return jr, nil
+	}
+
+	rewrite := o.redact || o.timeFormat != TimeDefault || o.durationFormat != DurationDefault || o.nullFormat != NullDefault || hasSecrets(reflect.ValueOf(jr.Data))
+	if o.fields == nil && !rewrite {
+		return jr, nil
+	}
+
+	b, err := o.marshalValue(jr.Data)
+	if err != nil {
+		return Response{}, err
+	}
+	if rewrite {
+		if b, err = o.rewriteData(b, reflect.ValueOf(jr.Data)); err != nil {
+			return Response{}, fmt.Errorf("failed to rewrite data: %w", err)
+		}
+	}
+	if o.fields != nil {
+		if b, err = pruneFields(b, o.fields); err != nil {
+			return Response{}, fmt.Errorf("failed to select fields: %w", err)
+		}
+	}
+	jr.Data = json.RawMessage(b)
+	return jr, nil
+}
+
+// pruneFields returns the JSON value b with the object keys not selected by fs removed.
+func pruneFields(b []byte, fs FieldSet) ([]byte, error) {
+	if fs == nil {
+		return b, nil
+	}
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return b, nil
+	}
+
+	switch b[0] {
+	case '[':
+		return rewriteElements(b, func(_ int, e json.RawMessage) (json.RawMessage, error) {
+			return pruneFields(e, fs)
+		})
+	case '{':
+		return rewriteMembers(b, func(k string, m json.RawMessage) (json.RawMessage, bool, error) {
+			sub, ok := fs[k]
+			if !ok {
+				return nil, false, nil
+			}
+			m, err := pruneFields(m, sub)
+			return m, true, err
+		})
+	}
+	return b, nil
+}
+
+// rewriteMembers returns the JSON object b with each member replaced by the value returned by f,
+// or removed if f returns false. Members retain their order. If b is not an object, it is returned
+// unmodified.
+func rewriteMembers(b []byte, f func(k string, m json.RawMessage) (json.RawMessage, bool, error)) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return b, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for n := 0; dec.More(); {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		k, _ := tok.(string)
+
+		var m json.RawMessage
+		if err := dec.Decode(&m); err != nil {
+			return nil, err
+		}
+
+		m, ok, err := f(k, m)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		if n++; n > 1 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(m)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// rewriteElements returns the JSON array b with the ith element replaced by the value returned by
+// f. If b is not an array, it is returned unmodified.
+func rewriteElements(b []byte, f func(i int, e json.RawMessage) (json.RawMessage, error)) ([]byte, error) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(b, &elems); err != nil || elems == nil {
+		return b, nil //nolint:nilerr // not an array
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, e := range elems {
+		e, err := f(i, e)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(e)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		want    FieldSet
+		wantErr bool
+	}{
+		{"One", "id", FieldSet{"id": nil}, false},
+		{"Many", "id, name", FieldSet{"id": nil, "name": nil}, false},
+		{"Nested", "id,owner.name,owner.email", FieldSet{"id": nil, "owner": {"name": nil, "email": nil}}, false},
+		{"Deep", "a.b.c", FieldSet{"a": {"b": {"c": nil}}}, false},
+		{"ParentFirst", "owner,owner.name", FieldSet{"owner": nil}, false},
+		{"ParentLast", "owner.name,owner", FieldSet{"owner": nil}, false},
+		{"Empty", "", nil, true},
+		{"EmptyField", "id,,name", nil, true},
+		{"EmptyPath", "owner.", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFields(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if code, _ := StatusCode(err); code != http.StatusBadRequest {
+					t.Errorf("got code %v, want %v", code, http.StatusBadRequest)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindFieldsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    FieldSet
+		wantErr bool
+	}{
+		{"None", "/things", nil, false},
+		{"Fields", "/things?fields=id,owner.name", FieldSet{"id": nil, "owner": {"name": nil}}, false},
+		{"Invalid", "/things?fields=id,", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BindFieldsQuery(httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	type owner struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	type thing struct {
+		ID    int    `json:"id"`
+		Name  string `json:"name"`
+		Owner *owner `json:"owner,omitempty"`
+	}
+	v := thing{1, "a", &owner{"o", "o@example.com"}}
+
+	tests := []struct {
+		name string
+		data interface{}
+		expr string
+		want string
+	}{
+		{"All", v, "", `{"data":{"id":1,"name":"a","owner":{"name":"o","email":"o@example.com"}}}`},
+		{"Top", v, "name,id", `{"data":{"id":1,"name":"a"}}`},
+		{"Object", v, "owner", `{"data":{"owner":{"name":"o","email":"o@example.com"}}}`},
+		{"Nested", v, "id,owner.email", `{"data":{"id":1,"owner":{"email":"o@example.com"}}}`},
+		{"Missing", v, "size", `{"data":{}}`},
+		{"Array", []thing{v, {ID: 2, Name: "b"}}, "id,owner.name", `{"data":[{"id":1,"owner":{"name":"o"}},{"id":2}]}`},
+		{"Scalar", 42, "id", `{"data":42}`},
+		{"NestedScalar", v, "name.first", `{"data":{"name":"a"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fs FieldSet
+			if tt.expr != "" {
+				var err error
+				if fs, err = ParseFields(tt.expr); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, stream := range []bool{false, true} {
+				opts := []Option{WithFields(fs)}
+				if stream {
+					opts = append(opts, WithStream())
+				}
+
+				rr := httptest.NewRecorder()
+				if err := WriteResponse(rr, tt.data, http.StatusOK, opts...); err != nil {
+					t.Fatalf("failed to write response: %v", err)
+				}
+				if got := rr.Body.String(); got != tt.want {
+					t.Errorf("stream %v: got body %v, want %v", stream, got, tt.want)
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := EncodeResponse(&buf, Response{Data: tt.data}, WithFields(fs)); err != nil {
+				t.Fatalf("failed to encode response: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got encoded %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFieldsError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "bad", http.StatusBadRequest, WithFields(FieldSet{"id": nil})); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	var je *Error
+	if err := ReadError(rr.Body); !errors.As(err, &je) || je.Message != "bad" {
+		t.Errorf("got error %v, want message %q", err, "bad")
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"io"
+	"net/http"
+)
+
+// WithFlush causes a response written using WithStream to be flushed to the client after each
+// element of its data is written, so that the client receives the elements as they are produced. If
+// the http.ResponseWriter does not support flushing, WithFlush has no effect.
+func WithFlush() Option {
+	return func(o *options) {
+		o.flush = true
+	}
+}
+
+// flushWriter flushes buffered data written to w, which may be a compressor, and then the
+// underlying http.ResponseWriter rw. It reports an error only if flushing is supported and fails.
+func flushWriter(w io.Writer, rw http.ResponseWriter) error {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return flushResponse(rw)
+}
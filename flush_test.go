@@ -0,0 +1,100 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// flushRecorder is an http.ResponseWriter that records the body written at each flush.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (fr *flushRecorder) Flush() {
+	fr.flushes = append(fr.flushes, fr.Body.String())
+	fr.ResponseRecorder.Flush()
+}
+
+// noFlushWriter is an http.ResponseWriter that does not support flushing.
+type noFlushWriter struct {
+	header http.Header
+	bytes.Buffer
+}
+
+func (w *noFlushWriter) Header() http.Header { return w.header }
+func (w *noFlushWriter) WriteHeader(int)     {}
+
+func TestWithFlush(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantFlushes []string
+	}{
+		{"Disabled", []Option{WithStream()}, nil},
+		{"Enabled", []Option{WithStream(), WithFlush()}, []string{
+			`{"data":[1`,
+			`{"data":[1,2`,
+			`{"data":[1,2,3`,
+		}},
+		{"NotStream", []Option{WithFlush()}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+			if err := WriteResponse(fr, []int{1, 2, 3}, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := fr.flushes, tt.wantFlushes; !reflect.DeepEqual(got, want) {
+				t.Errorf("got flushes %q, want %q", got, want)
+			}
+			if got, want := fr.Body.String(), `{"data":[1,2,3]}`; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithFlushCompression(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	fr := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := WriteResponse(fr, []string{"a", "b"}, http.StatusOK, WithStream(), WithFlush(), WithCompression(r)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := len(fr.flushes), 2; got != want {
+		t.Fatalf("got %v flushes, want %v", got, want)
+	}
+
+	// Each flush delivers the compressed elements written so far.
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(fr.flushes[0])))
+	if err != nil {
+		t.Fatalf("failed to create reader: %v", err)
+	}
+	b, _ := io.ReadAll(zr)
+	if got, want := string(b), `{"data":["a"`; got != want {
+		t.Errorf("got flushed body %q, want %q", got, want)
+	}
+}
+
+func TestWithFlushNotSupported(t *testing.T) {
+	w := &noFlushWriter{header: make(http.Header)}
+	if err := WriteResponse(w, []int{1, 2}, http.StatusOK, WithStream(), WithFlush()); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := w.String(), `{"data":[1,2]}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
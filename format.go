@@ -0,0 +1,273 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Format is a wire format in which responses are written and read as an alternative to JSON.
+// Responses are converted between JSON and the format, so the same Response, Error and
+// PageDetails types, and the same struct tags, apply regardless of the format in use.
+type Format interface {
+	// ContentType returns the media type of the format.
+	ContentType() string
+
+	// FromJSON writes the JSON document b to w in the format.
+	FromJSON(w io.Writer, b []byte) error
+
+	// ToJSON reads a document in the format from r, and returns its JSON equivalent.
+	ToJSON(r io.Reader) ([]byte, error)
+}
+
+// valueFormat is implemented by formats that encode and decode values directly, rather than by
+// conversion from and to JSON, with the same results.
+type valueFormat interface {
+	Format
+
+	// encodeValue appends the encoding of v to buf.
+	encodeValue(buf *bytes.Buffer, v interface{}) error
+
+	// decodeValue decodes the document b into v, according to o. If v is a *rawResponse, its data
+	// is retained in the format, so that it may be decoded directly in turn.
+	decodeValue(b []byte, v interface{}, o *options) error
+}
+
+// JSON is the default JSON wire format (application/json).
+var JSON Format = jsonFormat{}
+
+type jsonFormat struct{}
+
+func (jsonFormat) ContentType() string { return "application/json" }
+
+func (jsonFormat) FromJSON(w io.Writer, b []byte) error {
+	_, err := w.Write(b)
+	return err
+}
+
+func (jsonFormat) ToJSON(r io.Reader) ([]byte, error) {
+	return io.ReadAll(r)
+}
+
+// WithFormat causes responses to be written and read in format f rather than JSON. Unless
+// overridden by WithContentType, the Content-Type header of the response is set to the media
+// type of f. Streaming is not supported for alternative formats, so WithStream has no effect.
+func WithFormat(f Format) Option {
+	return func(o *options) {
+		if f == JSON {
+			f = nil
+		}
+		o.format = f
+	}
+}
+
+// errMaxDepth is returned when a document is nested too deeply to be converted.
+var errMaxDepth = errors.New("maximum nesting depth exceeded")
+
+// maxFormatDepth is the maximum nesting depth of documents converted between formats.
+const maxFormatDepth = 1000
+
+// jsonValue is a node in a parsed JSON document. Unlike the result of decoding into an
+// interface{}, the order of object members is preserved.
+type jsonValue struct {
+	kind  byte   // One of 'n' (null), 'b' (bool), 'd' (number), 's' (string), '[' or '{'.
+	b     bool   // Value of a bool.
+	s     string // Value of a string, or text of a number.
+	elems []jsonValue
+	keys  []string // Keys corresponding to elems, for objects.
+}
+
+// parseJSON parses the JSON document b.
+func parseJSON(b []byte) (jsonValue, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return parseJSONValue(dec, 0)
+}
+
+func parseJSONValue(dec *json.Decoder, depth int) (jsonValue, error) {
+	if depth > maxFormatDepth {
+		return jsonValue{}, errMaxDepth
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return jsonValue{}, err
+	}
+
+	switch t := tok.(type) {
+	case nil:
+		return jsonValue{kind: 'n'}, nil
+	case bool:
+		return jsonValue{kind: 'b', b: t}, nil
+	case json.Number:
+		return jsonValue{kind: 'd', s: string(t)}, nil
+	case string:
+		return jsonValue{kind: 's', s: t}, nil
+	case json.Delim:
+		v := jsonValue{kind: byte(t)}
+		for dec.More() {
+			if v.kind == '{' {
+				k, err := dec.Token()
+				if err != nil {
+					return jsonValue{}, err
+				}
+				v.keys = append(v.keys, k.(string))
+			}
+			e, err := parseJSONValue(dec, depth+1)
+			if err != nil {
+				return jsonValue{}, err
+			}
+			v.elems = append(v.elems, e)
+		}
+		// Consume closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return jsonValue{}, err
+		}
+		return v, nil
+	}
+	return jsonValue{}, fmt.Errorf("unexpected token %v", tok)
+}
+
+// member returns the value of the member of object v with the supplied key.
+func (v jsonValue) member(key string) (jsonValue, bool) {
+	for i, k := range v.keys {
+		if k == key {
+			return v.elems[i], true
+		}
+	}
+	return jsonValue{}, false
+}
+
+// set appends a member with the supplied key and value to object v.
+func (v *jsonValue) set(key string, e jsonValue) {
+	v.keys = append(v.keys, key)
+	v.elems = append(v.elems, e)
+}
+
+// appendJSON appends the JSON encoding of v to buf.
+func (v jsonValue) appendJSON(buf *bytes.Buffer) error {
+	switch v.kind {
+	case 'n':
+		buf.WriteString("null")
+	case 'b':
+		if v.b {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case 'd':
+		buf.WriteString(v.s)
+	case 's':
+		b, err := json.Marshal(v.s)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case '[', '{':
+		buf.WriteByte(v.kind)
+		for i, e := range v.elems {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if v.kind == '{' {
+				b, err := json.Marshal(v.keys[i])
+				if err != nil {
+					return err
+				}
+				buf.Write(b)
+				buf.WriteByte(':')
+			}
+			if err := e.appendJSON(buf); err != nil {
+				return err
+			}
+		}
+		if v.kind == '[' {
+			buf.WriteByte(']')
+		} else {
+			buf.WriteByte('}')
+		}
+	default:
+		return fmt.Errorf("unexpected kind %q", v.kind)
+	}
+	return nil
+}
+
+// floatValue returns a number node with value f, which must be representable in JSON.
+func floatValue(f float64) (jsonValue, error) {
+	if err := checkFloat(f); err != nil {
+		return jsonValue{}, err
+	}
+	return jsonValue{kind: 'd', s: strconv.FormatFloat(f, 'g', -1, 64)}, nil
+}
+
+// checkFloat returns an error if f is NaN or infinite, as JSON cannot represent it.
+func checkFloat(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("unsupported float value %v", f)
+	}
+	return nil
+}
+
+// bytesValue returns a string node containing the base64 encoding of b, consistent with the JSON
+// encoding of []byte.
+func bytesValue(b []byte) jsonValue {
+	return jsonValue{kind: 's', s: base64.StdEncoding.EncodeToString(b)}
+}
+
+var errUnexpectedEnd = errors.New("unexpected end of input")
+
+// byteDecoder reads binary-encoded values from a byte slice.
+type byteDecoder struct {
+	b   []byte
+	off int
+}
+
+// next returns the next n bytes of input.
+func (d *byteDecoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.off < n {
+		return nil, errUnexpectedEnd
+	}
+	b := d.b[d.off : d.off+n]
+	d.off += n
+	return b, nil
+}
+
+// uint reads a big-endian unsigned integer of n bytes.
+func (d *byteDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// length reads a length of n bytes, and checks that at least that many bytes of input remain.
+func (d *byteDecoder) length(n int) (int, error) {
+	u, err := d.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	return d.checkLength(u)
+}
+
+// checkLength checks that at least u bytes of input remain.
+func (d *byteDecoder) checkLength(u uint64) (int, error) {
+	if u > uint64(len(d.b)-d.off) {
+		return 0, errUnexpectedEnd
+	}
+	return int(u), nil
+}
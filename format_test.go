@@ -0,0 +1,57 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"Null", `null`, `null`, false},
+		{"Bool", `[true,false]`, `[true,false]`, false},
+		{"Number", `[1,-2,3.5,1e+100,18446744073709551615]`, `[1,-2,3.5,1e+100,18446744073709551615]`, false},
+		{"String", `"a\"b<"`, `"a\"b\u003c"`, false},
+		{"ObjectOrder", `{"z":1,"a":{"y":[],"b":{}}}`, `{"z":1,"a":{"y":[],"b":{}}}`, false},
+		{"Whitespace", " { \"a\" : [ 1 , 2 ] } ", `{"a":[1,2]}`, false},
+		{"Invalid", `{"a":`, ``, true},
+		{"TooDeep", strings.Repeat("[", maxFormatDepth+2) + strings.Repeat("]", maxFormatDepth+2), ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := parseJSON([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var buf bytes.Buffer
+			if err := v.appendJSON(&buf); err != nil {
+				t.Fatalf("failed to append JSON: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONMaxDepth(t *testing.T) {
+	in := strings.Repeat("[", maxFormatDepth+2) + strings.Repeat("]", maxFormatDepth+2)
+	if _, err := parseJSON([]byte(in)); !errors.Is(err, errMaxDepth) {
+		t.Errorf("got error %v, want %v", err, errMaxDepth)
+	}
+}
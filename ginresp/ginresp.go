@@ -0,0 +1,131 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package ginresp adapts the jsonresp package to the gin web framework, so that responses,
+// binding failures and requests gin cannot route are written in the jsonresp envelope rather than
+// gin's default formats.
+package ginresp
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// Render is a gin render.Render that writes a jsonresp response, for use with
+// gin.Context.Render. If Err is not nil, a response describing Err is written as by
+// jsonresp.WriteErr. Otherwise, a response containing Data is written with Code as by
+// jsonresp.WriteResponse.
+type Render struct {
+	Code    int
+	Data    interface{}
+	Err     error
+	Options []jsonresp.Option
+}
+
+var _ render.Render = Render{}
+
+// Render writes the response to w.
+func (r Render) Render(w http.ResponseWriter) error {
+	if r.Err != nil {
+		return jsonresp.WriteErr(w, r.Err, r.Options...)
+	}
+	return jsonresp.WriteResponse(w, r.Data, r.Code, r.Options...)
+}
+
+// WriteContentType sets the Content-Type header of w, for responses without a body.
+func (r Render) WriteContentType(w http.ResponseWriter) {
+	if h := w.Header(); h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "application/json")
+	}
+}
+
+// withRequest returns opts, followed by an option supplying the request of c.
+func withRequest(c *gin.Context, opts []jsonresp.Option) []jsonresp.Option {
+	return append(opts[:len(opts):len(opts)], jsonresp.WithRequest(c.Request))
+}
+
+// Response writes a status code and JSON response containing data, as by jsonresp.WriteResponse.
+func Response(c *gin.Context, code int, data interface{}, opts ...jsonresp.Option) {
+	c.Render(code, Render{Code: code, Data: data, Options: withRequest(c, opts)})
+}
+
+// Error writes a status code and JSON response describing err, as by jsonresp.WriteErr, and
+// aborts the remaining handlers of c.
+func Error(c *gin.Context, err error, opts ...jsonresp.Option) {
+	c.Abort()
+	c.Render(-1, Render{Err: err, Options: withRequest(c, opts)})
+}
+
+// Bind binds the request of c to v, as by gin.Context.ShouldBind. If binding fails, a 400 error
+// describing the failure is written, the remaining handlers of c are aborted, and false is
+// returned. Unlike gin.Context.Bind, which writes the status code before the response body can
+// be chosen, Bind writes a complete jsonresp error.
+func Bind(c *gin.Context, v interface{}, opts ...jsonresp.Option) bool {
+	if err := c.ShouldBind(v); err != nil {
+		Error(c, bindError(err), opts...)
+		return false
+	}
+	return true
+}
+
+// bindError returns the Error describing the binding failure err.
+func bindError(err error) error {
+	var je *jsonresp.Error
+	if errors.As(err, &je) {
+		return err
+	}
+	return jsonresp.NewError("invalid request: "+err.Error(), http.StatusBadRequest)
+}
+
+// ErrorHandler returns a middleware that, once the remaining handlers have run, writes the last
+// error attached to the context by gin.Context.Error, unless a response has already been
+// written. Errors of type gin.ErrorTypeBind are written with a 400 status code, and others as by
+// jsonresp.WriteErr. As gin.Context.Bind writes the status code of a binding failure itself,
+// handlers should bind with Bind, or with gin.Context.ShouldBind and an error of type
+// gin.ErrorTypeBind.
+func ErrorHandler(opts ...jsonresp.Option) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		e := c.Errors.Last()
+		if e == nil || c.Writer.Written() {
+			return
+		}
+		err := e.Err
+		if e.IsType(gin.ErrorTypeBind) {
+			err = bindError(err)
+		}
+		Error(c, err, opts...)
+	}
+}
+
+// NotFound returns a handler that writes a 404 error, for use with gin.Engine.NoRoute.
+func NotFound(opts ...jsonresp.Option) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		Error(c, jsonresp.NewError("resource not found", http.StatusNotFound), opts...)
+	}
+}
+
+// MethodNotAllowed returns a handler that writes a 405 error, for use with gin.Engine.NoMethod.
+func MethodNotAllowed(opts ...jsonresp.Option) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		Error(c, jsonresp.NewError("method not allowed", http.StatusMethodNotAllowed), opts...)
+	}
+}
+
+// Register installs ErrorHandler as a middleware of e, and sets the handlers e uses for requests
+// that match no route, and for requests whose method matches no route, to NotFound and
+// MethodNotAllowed. The handlers write with opts. As gin only applies middleware to routes added
+// after it, Register should be called before routes are added. The MethodNotAllowed handler is
+// only used if e.HandleMethodNotAllowed is set.
+func Register(e *gin.Engine, opts ...jsonresp.Option) {
+	e.Use(ErrorHandler(opts...))
+	e.NoRoute(NotFound(opts...))
+	e.NoMethod(MethodNotAllowed(opts...))
+}
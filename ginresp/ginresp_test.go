@@ -0,0 +1,136 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package ginresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+type item struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func newEngine() *gin.Engine {
+	e := gin.New()
+	e.HandleMethodNotAllowed = true
+	Register(e)
+
+	e.GET("/items", func(c *gin.Context) {
+		Response(c, http.StatusOK, []item{{Name: "a"}})
+	})
+	e.GET("/conflict", func(c *gin.Context) {
+		Error(c, jsonresp.NewError("already exists", http.StatusConflict))
+	})
+	e.GET("/attached", func(c *gin.Context) {
+		_ = c.Error(jsonresp.NewError("gone away", http.StatusGone))
+	})
+	e.GET("/written", func(c *gin.Context) {
+		_ = c.Error(errors.New("blah"))
+		c.String(http.StatusAccepted, "ok")
+	})
+	e.POST("/bind", func(c *gin.Context) {
+		var v item
+		if Bind(c, &v) {
+			Response(c, http.StatusCreated, v)
+		}
+	})
+	e.POST("/should-bind", func(c *gin.Context) {
+		var v item
+		if err := c.ShouldBindJSON(&v); err != nil {
+			_ = c.Error(err).SetType(gin.ErrorTypeBind)
+			return
+		}
+		Response(c, http.StatusCreated, v)
+	})
+	return e
+}
+
+func TestResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{"Response", http.MethodGet, "/items", "", http.StatusOK, `{"data":[{"name":"a"}]}`},
+		{"Bind", http.MethodPost, "/bind", `{"name":"a"}`, http.StatusCreated, `{"data":{"name":"a"}}`},
+		{"ShouldBind", http.MethodPost, "/should-bind", `{"name":"a"}`, http.StatusCreated, `{"data":{"name":"a"}}`},
+		{"Written", http.MethodGet, "/written", "", http.StatusAccepted, `ok`},
+	}
+
+	e := newEngine()
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := strings.TrimSpace(rr.Body.String()), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantMsg  string
+	}{
+		{"Error", http.MethodGet, "/conflict", "", http.StatusConflict, "already exists"},
+		{"Attached", http.MethodGet, "/attached", "", http.StatusGone, "gone away"},
+		{"BindInvalid", http.MethodPost, "/bind", `{`, http.StatusBadRequest, "invalid request: unexpected EOF"},
+		{"BindRequired", http.MethodPost, "/bind", `{}`, http.StatusBadRequest, "invalid request: Key: 'item.Name' Error:Field validation for 'Name' failed on the 'required' tag"},
+		{"ShouldBind", http.MethodPost, "/should-bind", `{`, http.StatusBadRequest, "invalid request: unexpected EOF"},
+		{"NotFound", http.MethodGet, "/other", "", http.StatusNotFound, "resource not found"},
+		{"MethodNotAllowed", http.MethodDelete, "/items", "", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+
+	e := newEngine()
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			e.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), "application/json"; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+
+			want := &jsonresp.Error{Code: tt.wantCode, Message: tt.wantMsg}
+			if err := jsonresp.ReadError(rr.Body); !errors.Is(err, want) {
+				t.Errorf("got error %v, want %v", err, want)
+			}
+		})
+	}
+}
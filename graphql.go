@@ -0,0 +1,194 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// GraphQL is the GraphQL response wire format (application/graphql-response+json), for gateways
+// that front GraphQL services alongside services using the response envelope.
+//
+// When writing, an Error becomes the sole member of "errors", with its message mapped to the
+// "message" member, "path" and "locations" details mapped to the members of the same name, and
+// its code, application code, remaining details and remaining fields mapped to "extensions" as
+// "status", "code" and members of the same name. The page, warnings, meta and links of the
+// envelope become members of the top-level "extensions". When reading, the reverse mapping is
+// applied to the first member of "errors", and the remaining members, if any, are mapped in the
+// same way to the "errors" detail of the Error. Data accompanying errors is retained.
+var GraphQL Format = graphQLFormat{}
+
+type graphQLFormat struct{}
+
+// graphQLErrorMembers are the details of an Error written as members of a GraphQL error, rather
+// than its extensions.
+var graphQLErrorMembers = []string{"path", "locations"}
+
+func (graphQLFormat) ContentType() string { return "application/graphql-response+json" }
+
+func (graphQLFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+	if v.kind != '{' {
+		_, err := w.Write(b)
+		return err
+	}
+
+	d := jsonValue{kind: '{'}
+	if je, ok := v.member("error"); ok && je.kind == '{' {
+		d.set("errors", jsonValue{kind: '[', elems: []jsonValue{graphQLErrorFromJSON(je)}})
+	}
+	if data, ok := v.member("data"); ok {
+		d.set("data", data)
+	} else if _, ok := d.member("errors"); ok {
+		d.set("data", jsonValue{kind: 'n'})
+	}
+
+	ext := jsonValue{kind: '{'}
+	for _, k := range jsendEnvelopeMembers {
+		if e, ok := v.member(k); ok {
+			ext.set(k, e)
+		}
+	}
+	if len(ext.keys) > 0 {
+		d.set("extensions", ext)
+	}
+
+	var buf bytes.Buffer
+	if err := d.appendJSON(&buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// graphQLErrorFromJSON returns the GraphQL error corresponding to the Error je.
+func graphQLErrorFromJSON(je jsonValue) jsonValue {
+	e := jsonValue{kind: '{'}
+	m, ok := je.member("message")
+	if !ok || m.kind != 's' {
+		code := http.StatusInternalServerError
+		if c, ok := je.member("code"); ok && c.kind == 'd' {
+			if n, err := strconv.Atoi(c.s); err == nil {
+				code = n
+			}
+		}
+		m = jsonValue{kind: 's', s: http.StatusText(code)}
+	}
+	e.set("message", m)
+
+	ext := jsonValue{kind: '{'}
+	if c, ok := je.member("appCode"); ok {
+		ext.set("code", c)
+	}
+	if c, ok := je.member("code"); ok {
+		ext.set("status", c)
+	}
+	if ds, ok := je.member("details"); ok && ds.kind == '{' {
+		for i, k := range ds.keys {
+			if contains(graphQLErrorMembers, k) {
+				e.set(k, ds.elems[i])
+			} else {
+				ext.set(k, ds.elems[i])
+			}
+		}
+	}
+	for _, k := range jsonAPIErrorMeta {
+		if m, ok := je.member(k); ok {
+			ext.set(k, m)
+		}
+	}
+	if len(ext.keys) > 0 {
+		e.set("extensions", ext)
+	}
+	return e
+}
+
+func (graphQLFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	if v.kind != '{' {
+		return nil, errors.New("graphql: response is not an object")
+	}
+
+	env := jsonValue{kind: '{'}
+	if data, ok := v.member("data"); ok && data.kind != 'n' {
+		env.set("data", data)
+	}
+	if es, ok := v.member("errors"); ok && es.kind == '[' && len(es.elems) > 0 {
+		env.set("error", graphQLErrorToJSON(es.elems[0], es.elems[1:]))
+	}
+	if ext, ok := v.member("extensions"); ok && ext.kind == '{' {
+		for _, k := range jsendEnvelopeMembers {
+			if e, ok := ext.member(k); ok {
+				env.set(k, e)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := env.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// graphQLErrorToJSON returns the Error corresponding to the GraphQL error e. The Errors
+// corresponding to the GraphQL errors rest, if any, are included as its "errors" detail.
+func graphQLErrorToJSON(e jsonValue, rest []jsonValue) jsonValue {
+	je := jsonValue{kind: '{'}
+	details := jsonValue{kind: '{'}
+	if e.kind != '{' {
+		e = jsonValue{kind: '{'}
+	}
+
+	if ext, ok := e.member("extensions"); ok && ext.kind == '{' {
+		for i, k := range ext.keys {
+			switch x := ext.elems[i]; {
+			case k == "status" && x.kind == 'd':
+				je.set("code", x)
+			case k == "code" && x.kind == 's':
+				je.set("appCode", x)
+			case contains(jsonAPIErrorMeta, k):
+				je.set(k, x)
+			default:
+				details.set(k, x)
+			}
+		}
+	}
+	if m, ok := e.member("message"); ok {
+		je.set("message", m)
+	}
+	for _, k := range graphQLErrorMembers {
+		if m, ok := e.member(k); ok {
+			details.set(k, m)
+		}
+	}
+	if len(rest) > 0 {
+		es := jsonValue{kind: '['}
+		for _, r := range rest {
+			es.elems = append(es.elems, graphQLErrorToJSON(r, nil))
+		}
+		details.set("errors", es)
+	}
+	if len(details.keys) > 0 {
+		je.set("details", details)
+	}
+	return je
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLFromJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Data", `{"data":{"id":1}}`, `{"data":{"id":1}}`},
+		{"NoData", `{}`, `{}`},
+		{"Envelope", `{"data":[1],"page":{"next":"n"},"meta":{"v":1}}`, `{"data":[1],"extensions":{"page":{"next":"n"},"meta":{"v":1}}}`},
+		{"Error", `{"error":{"code":404,"message":"blah"}}`, `{"errors":[{"message":"blah","extensions":{"status":404}}],"data":null}`},
+		{"ErrorAppCode", `{"error":{"code":404,"appCode":"NOT_FOUND","message":"blah","requestId":"r"}}`, `{"errors":[{"message":"blah","extensions":{"code":"NOT_FOUND","status":404,"requestId":"r"}}],"data":null}`},
+		{"ErrorDetails", `{"error":{"code":400,"message":"blah","details":{"path":["user","name"],"locations":[{"line":1,"column":2}],"field":"name"}}}`, `{"errors":[{"message":"blah","path":["user","name"],"locations":[{"line":1,"column":2}],"extensions":{"status":400,"field":"name"}}],"data":null}`},
+		{"ErrorNoMessage", `{"error":{"code":503}}`, `{"errors":[{"message":"Service Unavailable","extensions":{"status":503}}],"data":null}`},
+		{"ErrorData", `{"data":{"id":1},"error":{"code":500,"message":"blah"}}`, `{"errors":[{"message":"blah","extensions":{"status":500}}],"data":{"id":1}}`},
+		{"NotObject", `[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := GraphQL.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := buf.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGraphQLToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		graphql string
+		want    string
+		wantErr bool
+	}{
+		{"Data", `{"data":{"id":1}}`, `{"data":{"id":1}}`, false},
+		{"DataNull", `{"data":null}`, `{}`, false},
+		{"Extensions", `{"data":[1],"extensions":{"page":{"next":"n"},"cost":3}}`, `{"data":[1],"page":{"next":"n"}}`, false},
+		{"Error", `{"errors":[{"message":"blah"}]}`, `{"error":{"message":"blah"}}`, false},
+		{"ErrorExtensions", `{"errors":[{"message":"blah","extensions":{"code":"NOT_FOUND","status":404,"requestId":"r","field":"name"}}]}`, `{"error":{"appCode":"NOT_FOUND","code":404,"requestId":"r","message":"blah","details":{"field":"name"}}}`, false},
+		{"ErrorPath", `{"data":{"user":null},"errors":[{"message":"blah","locations":[{"line":1,"column":2}],"path":["user"]}]}`, `{"data":{"user":null},"error":{"message":"blah","details":{"path":["user"],"locations":[{"line":1,"column":2}]}}}`, false},
+		{"ErrorCodeNotString", `{"errors":[{"message":"blah","extensions":{"code":1,"status":"bad"}}]}`, `{"error":{"message":"blah","details":{"code":1,"status":"bad"}}}`, false},
+		{"ErrorsFirst", `{"errors":[{"message":"a"},{"message":"b"}]}`, `{"error":{"message":"a","details":{"errors":[{"message":"b"}]}}}`, false},
+		{"ErrorsExtensions", `{"errors":[{"message":"a","path":["x"]},{"message":"b","extensions":{"status":404}},1]}`, `{"error":{"message":"a","details":{"path":["x"],"errors":[{"code":404,"message":"b"},{}]}}}`, false},
+		{"ErrorsEmpty", `{"errors":[]}`, `{}`, false},
+		{"NotObject", `[]`, ``, true},
+		{"Invalid", `{`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := GraphQL.ToJSON(strings.NewReader(tt.graphql))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGraphQLRoundTrip(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, []string{"a", "b"}, &PageDetails{Next: "n"}, http.StatusOK, WithFormat(GraphQL)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/graphql-response+json"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	var got []string
+	pd, err := ReadResponsePage(rr.Body, &got, WithFormat(GraphQL))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := pd, (&PageDetails{Next: "n"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %v, want %v", got, want)
+	}
+}
+
+func TestReadErrorGraphQL(t *testing.T) {
+	details := map[string]interface{}{
+		"path":  []interface{}{"user", float64(0), "name"},
+		"field": "name",
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteErrorDetails(rr, "invalid input", details, http.StatusUnprocessableEntity, WithFormat(GraphQL)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	err := ReadError(rr.Body, WithFormat(GraphQL))
+	want := &Error{Code: http.StatusUnprocessableEntity, Message: "invalid input"}
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+	var je *Error
+	if !errors.As(err, &je) {
+		t.Fatalf("got error %T, want *Error", err)
+	}
+	if got, want := je.Details, details; !reflect.DeepEqual(got, want) {
+		t.Errorf("got details %v, want %v", got, want)
+	}
+}
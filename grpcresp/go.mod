@@ -0,0 +1,14 @@
+module github.com/sylabs/json-resp/grpcresp
+
+go 1.19
+
+require (
+	github.com/sylabs/json-resp v0.0.0
+	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1
+	google.golang.org/grpc v1.56.3
+	google.golang.org/protobuf v1.30.0
+)
+
+require github.com/golang/protobuf v1.5.3 // indirect
+
+replace github.com/sylabs/json-resp => ../
@@ -0,0 +1,199 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package grpcresp converts between jsonresp errors and gRPC statuses.
+package grpcresp
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// StatusClientClosedRequest is the non-standard HTTP status code used to represent
+// codes.Canceled.
+const StatusClientClosedRequest = 499
+
+// HTTPStatus returns the HTTP status code corresponding to the gRPC status code c.
+func HTTPStatus(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return StatusClientClosedRequest
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Code returns the gRPC status code corresponding to the HTTP status code httpStatus. Unrecognized
+// client errors map to codes.InvalidArgument, and unrecognized server errors to codes.Internal.
+func Code(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case StatusClientClosedRequest:
+		return codes.Canceled
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+
+	switch {
+	case httpStatus >= 200 && httpStatus < 300:
+		return codes.OK
+	case httpStatus >= 400 && httpStatus < 500:
+		return codes.InvalidArgument
+	case httpStatus >= 500 && httpStatus < 600:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// FromStatus returns an Error equivalent to the gRPC status s, or nil if s represents success.
+// The following status details are recognized:
+//
+//   - errdetails.ErrorInfo, whose reason populates AppCode, and whose metadata populates Details.
+//   - errdetails.RetryInfo, whose retry delay populates RetryAfter.
+//   - errdetails.RequestInfo, whose request ID populates RequestID.
+func FromStatus(s *status.Status) *jsonresp.Error {
+	if s.Code() == codes.OK {
+		return nil
+	}
+
+	je := jsonresp.NewError(s.Message(), HTTPStatus(s.Code()))
+	for _, d := range s.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			je.AppCode = d.GetReason()
+			if md := d.GetMetadata(); len(md) > 0 {
+				je.Details = make(map[string]interface{}, len(md))
+				for k, v := range md {
+					je.Details[k] = v
+				}
+			}
+		case *errdetails.RetryInfo:
+			if rd := d.GetRetryDelay(); rd != nil {
+				je.RetryAfter = int((rd.AsDuration() + time.Second - 1) / time.Second)
+			}
+		case *errdetails.RequestInfo:
+			je.RequestID = d.GetRequestId()
+		}
+	}
+	return je
+}
+
+// FromError returns an Error equivalent to err. If err is nil, nil is returned. If err is or wraps
+// an Error, it is returned. Otherwise, err is converted as if by status.FromError, and the result
+// converted by FromStatus.
+func FromError(err error) *jsonresp.Error {
+	if err == nil {
+		return nil
+	}
+
+	var je *jsonresp.Error
+	if errors.As(err, &je) {
+		return je
+	}
+
+	s, _ := status.FromError(err)
+	return FromStatus(s)
+}
+
+// ToStatus returns the gRPC status equivalent to the Error e. The AppCode, Details, RetryAfter and
+// RequestID fields of e are converted to status details as described by FromStatus. Details values
+// that are not strings are omitted.
+func ToStatus(e *jsonresp.Error) *status.Status {
+	if e == nil {
+		return status.New(codes.OK, "")
+	}
+
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Code)
+	}
+	s := status.New(Code(e.Code), msg)
+
+	var details []protoiface.MessageV1
+	if e.AppCode != "" || len(e.Details) > 0 {
+		ei := &errdetails.ErrorInfo{Reason: e.AppCode}
+		for k, v := range e.Details {
+			if v, ok := v.(string); ok {
+				if ei.Metadata == nil {
+					ei.Metadata = make(map[string]string)
+				}
+				ei.Metadata[k] = v
+			}
+		}
+		details = append(details, ei)
+	}
+	if e.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{
+			RetryDelay: durationpb.New(time.Duration(e.RetryAfter) * time.Second),
+		})
+	}
+	if e.RequestID != "" {
+		details = append(details, &errdetails.RequestInfo{RequestId: e.RequestID})
+	}
+
+	if len(details) > 0 {
+		if sd, err := s.WithDetails(details...); err == nil {
+			s = sd
+		}
+	}
+	return s
+}
+
+// ToError returns an error equivalent to e, suitable for return from a gRPC handler. If e is nil,
+// nil is returned.
+func ToError(e *jsonresp.Error) error {
+	if e == nil {
+		return nil
+	}
+	return ToStatus(e).Err()
+}
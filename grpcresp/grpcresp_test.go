@@ -0,0 +1,215 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package grpcresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		c    codes.Code
+		want int
+	}{
+		{codes.OK, http.StatusOK},
+		{codes.Canceled, StatusClientClosedRequest},
+		{codes.Unknown, http.StatusInternalServerError},
+		{codes.InvalidArgument, http.StatusBadRequest},
+		{codes.DeadlineExceeded, http.StatusGatewayTimeout},
+		{codes.NotFound, http.StatusNotFound},
+		{codes.AlreadyExists, http.StatusConflict},
+		{codes.PermissionDenied, http.StatusForbidden},
+		{codes.ResourceExhausted, http.StatusTooManyRequests},
+		{codes.FailedPrecondition, http.StatusBadRequest},
+		{codes.Aborted, http.StatusConflict},
+		{codes.OutOfRange, http.StatusBadRequest},
+		{codes.Unimplemented, http.StatusNotImplemented},
+		{codes.Internal, http.StatusInternalServerError},
+		{codes.Unavailable, http.StatusServiceUnavailable},
+		{codes.DataLoss, http.StatusInternalServerError},
+		{codes.Unauthenticated, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.c.String(), func(t *testing.T) {
+			if got := HTTPStatus(tt.c); got != tt.want {
+				t.Errorf("got status %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		httpStatus int
+		want       codes.Code
+	}{
+		{http.StatusOK, codes.OK},
+		{http.StatusCreated, codes.OK},
+		{http.StatusBadRequest, codes.InvalidArgument},
+		{http.StatusUnauthorized, codes.Unauthenticated},
+		{http.StatusForbidden, codes.PermissionDenied},
+		{http.StatusNotFound, codes.NotFound},
+		{http.StatusMethodNotAllowed, codes.Unimplemented},
+		{http.StatusRequestTimeout, codes.DeadlineExceeded},
+		{http.StatusConflict, codes.AlreadyExists},
+		{http.StatusPreconditionFailed, codes.FailedPrecondition},
+		{http.StatusRequestedRangeNotSatisfiable, codes.OutOfRange},
+		{http.StatusTooManyRequests, codes.ResourceExhausted},
+		{StatusClientClosedRequest, codes.Canceled},
+		{http.StatusTeapot, codes.InvalidArgument},
+		{http.StatusInternalServerError, codes.Internal},
+		{http.StatusNotImplemented, codes.Unimplemented},
+		{http.StatusBadGateway, codes.Unavailable},
+		{http.StatusServiceUnavailable, codes.Unavailable},
+		{http.StatusGatewayTimeout, codes.DeadlineExceeded},
+		{http.StatusHTTPVersionNotSupported, codes.Internal},
+		{0, codes.Unknown},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.httpStatus), func(t *testing.T) {
+			if got := Code(tt.httpStatus); got != tt.want {
+				t.Errorf("got code %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func mustWithDetails(t *testing.T, s *status.Status, details ...*errdetails.ErrorInfo) *status.Status {
+	t.Helper()
+
+	for _, d := range details {
+		var err error
+		if s, err = s.WithDetails(d); err != nil {
+			t.Fatalf("failed to add details: %v", err)
+		}
+	}
+	return s
+}
+
+func TestFromStatus(t *testing.T) {
+	s, err := status.New(codes.ResourceExhausted, "quota exceeded").WithDetails(
+		&errdetails.ErrorInfo{Reason: "QUOTA_EXCEEDED", Metadata: map[string]string{"limit": "10"}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+		&errdetails.RequestInfo{RequestId: "abc"},
+	)
+	if err != nil {
+		t.Fatalf("failed to add details: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		s    *status.Status
+		want *jsonresp.Error
+	}{
+		{"OK", status.New(codes.OK, ""), nil},
+		{"NotFound", status.New(codes.NotFound, "no such thing"), jsonresp.NewError("no such thing", http.StatusNotFound)},
+		{"ReasonOnly", mustWithDetails(t, status.New(codes.Aborted, "conflict"), &errdetails.ErrorInfo{Reason: "CONFLICT"}), jsonresp.NewAppError("CONFLICT", "conflict", http.StatusConflict)},
+		{"Details", s, &jsonresp.Error{
+			Code:       http.StatusTooManyRequests,
+			AppCode:    "QUOTA_EXCEEDED",
+			Message:    "quota exceeded",
+			Details:    map[string]interface{}{"limit": "10"},
+			RetryAfter: 2,
+			RequestID:  "abc",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromStatus(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got error %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromError(t *testing.T) {
+	je := jsonresp.NewError("blah", http.StatusTeapot)
+
+	tests := []struct {
+		name string
+		err  error
+		want *jsonresp.Error
+	}{
+		{"Nil", nil, nil},
+		{"Error", fmt.Errorf("wrapped: %w", je), je},
+		{"Status", status.Error(codes.NotFound, "no such thing"), jsonresp.NewError("no such thing", http.StatusNotFound)},
+		{"Other", errors.New("blah"), jsonresp.NewError("blah", http.StatusInternalServerError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromError(tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got error %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		e           *jsonresp.Error
+		wantCode    codes.Code
+		wantMessage string
+		wantDetails int
+	}{
+		{"Nil", nil, codes.OK, "", 0},
+		{"NoMessage", &jsonresp.Error{Code: http.StatusNotFound}, codes.NotFound, "Not Found", 0},
+		{"Message", jsonresp.NewError("no such thing", http.StatusNotFound), codes.NotFound, "no such thing", 0},
+		{"AppCode", jsonresp.NewAppError("CONFLICT", "conflict", http.StatusConflict), codes.AlreadyExists, "conflict", 1},
+		{"All", &jsonresp.Error{
+			Code:       http.StatusTooManyRequests,
+			AppCode:    "QUOTA_EXCEEDED",
+			Message:    "quota exceeded",
+			Details:    map[string]interface{}{"limit": "10", "count": 11},
+			RetryAfter: 2,
+			RequestID:  "abc",
+		}, codes.ResourceExhausted, "quota exceeded", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := ToStatus(tt.e)
+
+			if got, want := s.Code(), tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := s.Message(), tt.wantMessage; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+			if got, want := len(s.Details()), tt.wantDetails; got != want {
+				t.Errorf("got %v details, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	e := &jsonresp.Error{
+		Code:       http.StatusTooManyRequests,
+		AppCode:    "QUOTA_EXCEEDED",
+		Message:    "quota exceeded",
+		Details:    map[string]interface{}{"limit": "10"},
+		RetryAfter: 2,
+		RequestID:  "abc",
+	}
+
+	if got := FromError(ToError(e)); !reflect.DeepEqual(got, e) {
+		t.Errorf("got error %#v, want %#v", got, e)
+	}
+	if err := ToError(nil); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// halEmbeddedItems is the relation under which the elements of a collection are embedded.
+const halEmbeddedItems = "items"
+
+// HAL is the Hypertext Application Language wire format (application/hal+json). Like other
+// formats, it may be made available to content negotiation using RegisterFormat.
+//
+// When writing, the members of object data become the members of the resource, and the elements
+// of slice data are embedded under the "items" relation of "_embedded", with the total size and
+// sort of the page, if any, written to the "totalSize" and "sort" members. The previous and next
+// page URLs and the links of the envelope are written to "_links". Warnings and meta are not
+// represented, and error responses are written unchanged. When reading, the reverse mapping is
+// applied.
+var HAL Format = halFormat{}
+
+type halFormat struct{}
+
+func (halFormat) ContentType() string { return "application/hal+json" }
+
+func (halFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+	if _, ok := v.member("error"); ok || v.kind != '{' {
+		_, err := w.Write(b)
+		return err
+	}
+
+	links := jsonValue{kind: '{'}
+	if ls, ok := v.member("links"); ok && ls.kind == '{' {
+		for i, k := range ls.keys {
+			links.set(k, ls.elems[i])
+		}
+	}
+	pd, _ := v.member("page")
+	for _, k := range []string{"prev", "next"} {
+		if e, ok := pd.member(k); ok {
+			links.set(k, jsonValue{kind: '{', keys: []string{"href"}, elems: []jsonValue{e}})
+		}
+	}
+
+	res := jsonValue{kind: '{'}
+	if len(links.keys) > 0 {
+		res.set("_links", links)
+	}
+
+	switch data, _ := v.member("data"); data.kind {
+	case '{':
+		for i, k := range data.keys {
+			res.set(k, data.elems[i])
+		}
+	case '[':
+		res.set("_embedded", jsonValue{kind: '{', keys: []string{halEmbeddedItems}, elems: []jsonValue{data}})
+	case 0, 'n':
+	default:
+		res.set("data", data)
+	}
+	for _, k := range []string{"totalSize", "sort"} {
+		if e, ok := pd.member(k); ok {
+			res.set(k, e)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := res.appendJSON(&buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (halFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	if v.kind != '{' {
+		return nil, errors.New("hal: document is not an object")
+	}
+	if _, ok := v.member("error"); ok {
+		return b, nil
+	}
+
+	page := jsonValue{kind: '{'}
+	links := jsonValue{kind: '{'}
+	if ls, ok := v.member("_links"); ok && ls.kind == '{' {
+		for i, k := range ls.keys {
+			l := ls.elems[i]
+			switch k {
+			case "prev", "next":
+				if h, ok := l.member("href"); ok && h.kind == 's' {
+					page.set(k, h)
+				}
+			default:
+				if l.kind == '[' {
+					// Only a single link with each relation is supported.
+					if len(l.elems) == 0 {
+						continue
+					}
+					l = l.elems[0]
+				}
+				links.set(k, l)
+			}
+		}
+	}
+
+	env := jsonValue{kind: '{'}
+	if items, ok := halCollection(v); ok {
+		env.set("data", items)
+		for _, k := range []string{"totalSize", "sort"} {
+			if e, ok := v.member(k); ok {
+				page.set(k, e)
+			}
+		}
+	} else if d, ok := v.member("data"); ok && hasOnlyMembers(v, "_links", "data") {
+		env.set("data", d)
+	} else {
+		data := jsonValue{kind: '{'}
+		for i, k := range v.keys {
+			if k != "_links" {
+				data.set(k, v.elems[i])
+			}
+		}
+		if len(data.keys) > 0 {
+			env.set("data", data)
+		}
+	}
+
+	if len(page.keys) > 0 {
+		env.set("page", page)
+	}
+	if len(links.keys) > 0 {
+		env.set("links", links)
+	}
+
+	var buf bytes.Buffer
+	if err := env.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// halCollection returns the embedded items of the HAL resource v, if it is a collection. A
+// resource is considered a collection if its only members, other than "_links", "totalSize" and
+// "sort", are items embedded under the "items" relation.
+func halCollection(v jsonValue) (jsonValue, bool) {
+	em, ok := v.member("_embedded")
+	if !ok || em.kind != '{' || len(em.keys) != 1 {
+		return jsonValue{}, false
+	}
+	items, ok := em.member(halEmbeddedItems)
+	if !ok || items.kind != '[' {
+		return jsonValue{}, false
+	}
+	return items, hasOnlyMembers(v, "_links", "_embedded", "totalSize", "sort")
+}
+
+// hasOnlyMembers reports whether object v has no members other than those with the supplied keys.
+func hasOnlyMembers(v jsonValue, keys ...string) bool {
+	for _, k := range v.keys {
+		if !contains(keys, k) {
+			return false
+		}
+	}
+	return true
+}
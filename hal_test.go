@@ -0,0 +1,121 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHALFromJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Object", `{"data":{"id":1,"name":"a"},"links":{"self":{"href":"/a/1"}}}`, `{"_links":{"self":{"href":"/a/1"}},"id":1,"name":"a"}`},
+		{"Collection", `{"data":[{"id":1}],"page":{"next":"/a?cursor=1","totalSize":2},"links":{"self":{"href":"/a"}}}`, `{"_links":{"self":{"href":"/a"},"next":{"href":"/a?cursor=1"}},"_embedded":{"items":[{"id":1}]},"totalSize":2}`},
+		{"CollectionSort", `{"data":[{"id":1}],"page":{"totalSize":2,"sort":"-id"}}`, `{"_embedded":{"items":[{"id":1}]},"totalSize":2,"sort":"-id"}`},
+		{"Scalar", `{"data":"blah"}`, `{"data":"blah"}`},
+		{"NoData", `{"warnings":[{"message":"w"}]}`, `{}`},
+		{"Error", `{"error":{"code":404}}`, `{"error":{"code":404}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := HAL.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := buf.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHALToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		hal     string
+		want    string
+		wantErr bool
+	}{
+		{"Object", `{"_links":{"self":{"href":"/a/1"}},"id":1}`, `{"data":{"id":1},"links":{"self":{"href":"/a/1"}}}`, false},
+		{"ObjectEmbedded", `{"id":1,"_embedded":{"owner":{"id":2}}}`, `{"data":{"id":1,"_embedded":{"owner":{"id":2}}}}`, false},
+		{"Collection", `{"_links":{"prev":{"href":"p"}},"_embedded":{"items":[1,2]},"totalSize":2}`, `{"data":[1,2],"page":{"prev":"p","totalSize":2}}`, false},
+		{"CollectionSort", `{"_embedded":{"items":[1,2]},"sort":"-id"}`, `{"data":[1,2],"page":{"sort":"-id"}}`, false},
+		{"LinkArray", `{"_links":{"item":[{"href":"/a"},{"href":"/b"}]},"id":1}`, `{"data":{"id":1},"links":{"item":{"href":"/a"}}}`, false},
+		{"Scalar", `{"data":"blah"}`, `{"data":"blah"}`, false},
+		{"Empty", `{}`, `{}`, false},
+		{"Error", `{"error":{"code":404}}`, `{"error":{"code":404}}`, false},
+		{"NotObject", `[]`, ``, true},
+		{"Invalid", `{`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := HAL.ToJSON(strings.NewReader(tt.hal))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHALNegotiation(t *testing.T) {
+	withFormats(t, HAL)
+
+	type thing struct {
+		ID int `json:"id"`
+	}
+	in := []thing{{1}, {2}}
+
+	r := httptest.NewRequest(http.MethodGet, "/things", nil)
+	r.Header.Set("Accept", "application/hal+json")
+
+	rr := httptest.NewRecorder()
+	if err := WriteNegotiatedPage(rr, r, in, &PageDetails{Next: "/things?cursor=2"}, http.StatusOK, WithRequestLink(r)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/hal+json"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	var got []thing
+	var jr Response
+	pd, err := ReadResponsePage(rr.Body, &got, WithFormat(HAL), WithEnvelope(&jr))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("got %v, want %v", got, in)
+	}
+	if got, want := pd, (&PageDetails{Next: "/things?cursor=2"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %v, want %v", got, want)
+	}
+	if got, want := jr.Links, map[string]Link{LinkSelf: {Href: "/things"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %v, want %v", got, want)
+	}
+}
+
+func TestReadErrorHAL(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithFormat(HAL)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	want := &Error{Code: http.StatusNotFound, Message: "blah"}
+	if got := ReadError(rr.Body, WithFormat(HAL)); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"sync"
+)
+
+// ResponseHook is called with each response before it is encoded, and may modify it, such as to
+// add warnings or meta, or to remove fields from its data. The request is that supplied with
+// WithRequest or to a function that accepts a request, such as WriteNegotiated, and is nil if no
+// request is available. The maps and slices of the response may be shared with the caller, so
+// hooks must replace rather than modify them. Secrets are replaced with Redacted in the metadata
+// of the response and the details of its error before hooks are called, but not in its data, so
+// hooks that log its data should encode it with Redact.
+type ResponseHook func(r *http.Request, jr *Response)
+
+var (
+	hooksMu sync.RWMutex
+	hooks   []ResponseHook
+)
+
+// AddResponseHook adds h to the hooks called with each response written by the write functions
+// and EncodeResponse. Hooks are called in the order they were added.
+func AddResponseHook(h ResponseHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	hooks = append(hooks, h)
+}
+
+// WithRequest supplies r, the request to which the response is a reply, to response hooks.
+func WithRequest(r *http.Request) Option {
+	return func(o *options) {
+		o.request = r
+	}
+}
+
+// WithResponseHook causes h to be called with the response before it is encoded, after the hooks
+// established by AddResponseHook. It may be used more than once to add multiple hooks, which are
+// called in order.
+func WithResponseHook(h ResponseHook) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, h)
+	}
+}
+
+// applyHooks returns jr, as modified by the hooks established by AddResponseHook and
+// WithResponseHook. The envelope of jr is redacted, as by redactEnvelope, before the hooks are
+// called.
+func (o *options) applyHooks(jr Response) (Response, error) {
+	hooksMu.RLock()
+	hs := hooks
+	hooksMu.RUnlock()
+
+	if len(hs) == 0 && len(o.hooks) == 0 {
+		// Taking the address of jr would cause it to escape to the heap.
+		return jr, nil
+	}
+	jr, err := o.redactEnvelope(jr)
+	if err != nil {
+		return Response{}, err
+	}
+	for _, h := range hs {
+		h(o.request, &jr)
+	}
+	for _, h := range o.hooks {
+		h(o.request, &jr)
+	}
+	return jr, nil
+}
+
+// prepareResponse returns jr, wrapped in the envelope established by o, as modified by the
+// response hooks and transformed by transformData.
+func (o *options) prepareResponse(jr Response) (Response, error) {
+	jr, err := o.applyHooks(o.envelope(jr))
+	if err != nil {
+		return Response{}, err
+	}
+	return o.transformData(jr)
+}
+
+// hasResponseHooks reports whether any hooks have been established by AddResponseHook.
+func hasResponseHooks() bool {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+	return len(hooks) > 0
+}
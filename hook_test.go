@@ -0,0 +1,124 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// withHooks replaces the registered response hooks with hs for the duration of the test.
+func withHooks(t *testing.T, hs ...ResponseHook) {
+	t.Helper()
+
+	hooksMu.Lock()
+	old := hooks
+	hooks = nil
+	hooksMu.Unlock()
+
+	for _, h := range hs {
+		AddResponseHook(h)
+	}
+
+	t.Cleanup(func() {
+		hooksMu.Lock()
+		hooks = old
+		hooksMu.Unlock()
+	})
+}
+
+func TestResponseHook(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/things", nil)
+	r.Header.Set("Accept", "application/json")
+
+	withHooks(t,
+		func(r *http.Request, jr *Response) {
+			path := "none"
+			if r != nil {
+				path = r.URL.Path
+			}
+			jr.Meta = map[string]interface{}{"path": path}
+		},
+		func(_ *http.Request, jr *Response) {
+			if jr.Error != nil {
+				jr.Warnings = append([]Warning(nil), Warning{Message: "retry later"})
+			}
+		},
+	)
+
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter) error
+		want  string
+	}{
+		{"NoRequest", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusOK)
+		}, `{"data":"blah","meta":{"path":"none"}}`},
+		{"WithRequest", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusOK, WithRequest(r))
+		}, `{"data":"blah","meta":{"path":"/things"}}`},
+		{"Stream", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusOK, WithRequest(r), WithStream())
+		}, `{"data":"blah","meta":{"path":"/things"}}`},
+		{"Negotiated", func(w http.ResponseWriter) error {
+			return WriteNegotiated(w, r, "blah", http.StatusOK)
+		}, `{"data":"blah","meta":{"path":"/things"}}`},
+		{"Error", func(w http.ResponseWriter) error {
+			return WriteNegotiatedErr(w, r, NewError("blah", http.StatusServiceUnavailable))
+		}, `{"error":{"code":503,"message":"blah"},"warnings":[{"message":"retry later"}],"meta":{"path":"/things"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := rr.Body.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestResponseHookEncodeResponse(t *testing.T) {
+	withHooks(t, func(_ *http.Request, jr *Response) {
+		jr.Data = "replaced"
+	})
+
+	var buf bytes.Buffer
+	if err := EncodeResponse(&buf, Response{Data: "blah"}); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	if got, want := buf.String(), `{"data":"replaced"}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWithResponseHook(t *testing.T) {
+	withHooks(t, func(_ *http.Request, jr *Response) {
+		jr.Meta = map[string]interface{}{"global": true}
+	})
+
+	meta := func(key string) ResponseHook {
+		return func(_ *http.Request, jr *Response) {
+			m := map[string]interface{}{key: true}
+			for k, v := range jr.Meta {
+				m[k] = v
+			}
+			jr.Meta = m
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponse(rr, "blah", http.StatusOK, WithResponseHook(meta("a")), WithResponseHook(meta("b"))); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"data":"blah","meta":{"a":true,"b":true,"global":true}}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
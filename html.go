@@ -0,0 +1,93 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// HTMLError is the value with which the template of an HTML error format is executed.
+type HTMLError struct {
+	// Error is the error being written.
+	Error *Error
+
+	// Title is the text of the status code of the error, such as "Not Found".
+	Title string
+}
+
+// DefaultErrorTemplate is the template used by the HTML format. It renders a minimal page
+// containing the status code and message of the error.
+var DefaultErrorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Error.Code}} {{.Title}}</title></head>
+<body>
+<h1>{{.Error.Code}} {{.Title}}</h1>
+{{- with .Error.Message}}
+<p>{{.}}</p>
+{{- end}}
+{{- with .Error.RequestID}}
+<p><small>Request ID: {{.}}</small></p>
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML is an HTML wire format (text/html), which renders errors using DefaultErrorTemplate. It is
+// only suitable for error responses, and is never selected by content negotiation for other
+// responses. Once registered with RegisterFormat, WriteNegotiatedErr writes errors as HTML to
+// clients that prefer it, such as web browsers, while other clients continue to receive JSON.
+var HTML = NewHTMLFormat(DefaultErrorTemplate)
+
+// NewHTMLFormat returns an HTML wire format (text/html) that renders errors by executing t with
+// an HTMLError. Like HTML, it is only suitable for error responses. Responses without an error
+// are passed through unchanged, and the format cannot be read.
+func NewHTMLFormat(t *template.Template) Format { //nolint:ireturn
+	return htmlFormat{t: t}
+}
+
+type htmlFormat struct {
+	t *template.Template
+}
+
+func (htmlFormat) errorOnly() {}
+
+func (htmlFormat) ContentType() string { return "text/html; charset=utf-8" }
+
+func (f htmlFormat) FromJSON(w io.Writer, b []byte) error {
+	var u struct {
+		Error *wireError `json:"error"`
+	}
+	if err := json.Unmarshal(b, &u); err != nil {
+		return err
+	}
+	if u.Error == nil {
+		_, err := w.Write(b)
+		return err
+	}
+
+	je := u.Error.error()
+	code := je.Code
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+
+	// The template is executed into a buffer, so that a failure does not produce a partial page.
+	var buf bytes.Buffer
+	if err := f.t.Execute(&buf, HTMLError{Error: je, Title: http.StatusText(code)}); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (htmlFormat) ToJSON(io.Reader) ([]byte, error) {
+	return nil, errors.New("html: reading is not supported")
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteNegotiatedErrHTML(t *testing.T) {
+	withFormats(t, HTML)
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        []string
+	}{
+		{"None", "", "application/json", []string{`"code":404`}},
+		{"JSON", "application/json", "application/json", []string{`"code":404`}},
+		{"Browser", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8", []string{
+			"<title>404 Not Found</title>",
+			"<p>&lt;thing&gt; not found</p>",
+			"Request ID: r",
+		}},
+		{"PreferJSON", "text/html;q=0.5,application/json", "application/json", []string{`"code":404`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			r.Header.Set("X-Request-ID", "r")
+			rr := httptest.NewRecorder()
+
+			if err := WriteNegotiatedErr(rr, r, NewError("<thing> not found", http.StatusNotFound)); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, http.StatusNotFound; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			for _, want := range tt.wantBody {
+				if got := rr.Body.String(); !strings.Contains(got, want) {
+					t.Errorf("got body %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewHTMLFormat(t *testing.T) {
+	tmpl := template.Must(template.New("").Parse(`<p>{{.Title}}: {{.Error.Message}} ({{.Error.AppCode}})</p>`))
+	withFormats(t, NewHTMLFormat(tmpl))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+
+	if err := WriteNegotiatedErr(rr, r, NewAppError("QUOTA", "over quota", http.StatusTooManyRequests)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Body.String(), `<p>Too Many Requests: over quota (QUOTA)</p>`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestHTMLNotNegotiatedForData(t *testing.T) {
+	withFormats(t, HTML)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html,*/*;q=0.8")
+	rr := httptest.NewRecorder()
+
+	if err := WriteNegotiated(rr, r, "blah", http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header in which clients supply an idempotency key, and
+	// in which it is echoed in the response.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is the response header set to "true" by IdempotencyHandler when
+	// a response is replayed.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// MetaIdempotencyKey is the metadata key under which WithIdempotencyKey records the
+	// idempotency key of the request.
+	MetaIdempotencyKey = "idempotencyKey"
+
+	// DefaultIdempotencyTTL is the duration for which the store used by IdempotencyHandler
+	// retains responses, unless its Store field is set.
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	// DefaultMaxIdempotentResponses is the number of responses retained by the store used by
+	// IdempotencyHandler, unless its Store or MaxEntries field is set.
+	DefaultMaxIdempotentResponses = 10000
+)
+
+// IdempotencyKey returns the idempotency key supplied in the Idempotency-Key header of r, or an
+// empty string if there is none. The key may be supplied as a quoted string.
+func IdempotencyKey(r *http.Request) string {
+	k := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if len(k) >= 2 && k[0] == '"' && k[len(k)-1] == '"' {
+		k = k[1 : len(k)-1]
+	}
+	return k
+}
+
+// WithIdempotencyKey causes the idempotency key of r, if any, to be echoed in the Idempotency-Key
+// header of the response, and in the metadata of the response envelope under the
+// MetaIdempotencyKey key.
+func WithIdempotencyKey(r *http.Request) Option {
+	k := IdempotencyKey(r)
+	return func(o *options) {
+		if k != "" {
+			WithHeader(IdempotencyKeyHeader, k)(o)
+			WithMeta(MetaIdempotencyKey, k)(o)
+		}
+	}
+}
+
+// IdempotentResponse is a response retained by an IdempotencyStore, for replay in response to a
+// repeated request.
+type IdempotentResponse struct {
+	// Fingerprint identifies the method, URL and body of the request.
+	Fingerprint string
+
+	// Code is the status code of the response.
+	Code int
+
+	// Header contains the headers of the response.
+	Header http.Header
+
+	// Body is the body of the response.
+	Body []byte
+}
+
+// IdempotencyStore stores responses, keyed by idempotency key. Implementations must be safe for
+// concurrent use, and determine how long responses are retained.
+type IdempotencyStore interface {
+	// Load returns the response stored with key, if any.
+	Load(key string) (*IdempotentResponse, bool)
+
+	// Store stores ir with key.
+	Store(key string, ir *IdempotentResponse)
+}
+
+// MemoryIdempotencyStore is an IdempotencyStore that retains a bounded number of responses in
+// memory for a fixed duration. Once the bound is reached, the oldest responses are discarded, so
+// that requests repeated with their keys are served again.
+type MemoryIdempotencyStore struct {
+	ttl time.Duration
+	max int
+
+	mu    sync.Mutex
+	ll    *list.List // of *idempotencyEntry, oldest first
+	items map[string]*list.Element
+}
+
+// idempotencyEntry is an entry of a MemoryIdempotencyStore.
+type idempotencyEntry struct {
+	key     string
+	ir      *IdempotentResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns a MemoryIdempotencyStore that retains up to max responses for
+// ttl.
+func NewMemoryIdempotencyStore(ttl time.Duration, max int) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:   ttl,
+		max:   max,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// expire removes the entries of s that have expired at t. The caller must hold s.mu.
+func (s *MemoryIdempotencyStore) expire(t time.Time) {
+	for e := s.ll.Front(); e != nil && !t.Before(e.Value.(*idempotencyEntry).expires); e = s.ll.Front() {
+		s.ll.Remove(e)
+		delete(s.items, e.Value.(*idempotencyEntry).key)
+	}
+}
+
+// Load returns the response stored with key, if any.
+func (s *MemoryIdempotencyStore) Load(key string) (*IdempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*idempotencyEntry).ir, true
+}
+
+// Store stores ir with key.
+func (s *MemoryIdempotencyStore) Store(key string, ir *IdempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now()
+	s.expire(t)
+	if e, ok := s.items[key]; ok {
+		s.ll.Remove(e)
+	}
+	s.items[key] = s.ll.PushBack(&idempotencyEntry{key: key, ir: ir, expires: t.Add(s.ttl)})
+	for s.ll.Len() > s.max {
+		e := s.ll.Front()
+		s.ll.Remove(e)
+		delete(s.items, e.Value.(*idempotencyEntry).key)
+	}
+}
+
+// IdempotencyHandler is an http.Handler that replays the response to a request with an unsafe
+// method, such as POST, when it is repeated with the same idempotency key, rather than invoking
+// Handler again. This allows clients to retry requests safely when the outcome of an earlier
+// attempt is unknown. The idempotency key is echoed in the Idempotency-Key header of each
+// response, and replayed responses carry an Idempotent-Replayed header of "true".
+//
+// Responses with a 5xx or 429 status code are not retained, so that requests which fail
+// transiently can be retried. A repeated request whose method, URL or body differs from the
+// original is rejected with a 422 status code, and a request repeated while the original is still
+// being served is rejected with a 409 status code. Requests with a safe method, or without an
+// idempotency key, are passed to Handler.
+type IdempotencyHandler struct {
+	// Handler serves requests that are not replayed.
+	Handler http.Handler
+
+	// Store retains responses. If nil, a MemoryIdempotencyStore retaining up to MaxEntries
+	// responses for DefaultIdempotencyTTL is used.
+	Store IdempotencyStore
+
+	// MaxEntries is the maximum number of responses retained if Store is nil. If zero,
+	// DefaultMaxIdempotentResponses is used.
+	MaxEntries int
+
+	// Scope, if not nil, returns a value identifying the client of r, so that the idempotency
+	// keys of different clients do not collide. If nil, the Authorization header of r is used.
+	Scope func(r *http.Request) string
+
+	// MaxBodySize limits the size of request bodies, which are read in full to determine whether
+	// a repeated request matches the original. Larger requests are rejected with a 413 status
+	// code. A value of zero or less means no limit is applied.
+	MaxBodySize int64
+
+	once  sync.Once
+	store IdempotencyStore
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// init initializes the store of ih.
+func (ih *IdempotencyHandler) init() {
+	ih.once.Do(func() {
+		ih.store = ih.Store
+		if ih.store == nil {
+			n := ih.MaxEntries
+			if n <= 0 {
+				n = DefaultMaxIdempotentResponses
+			}
+			ih.store = NewMemoryIdempotencyStore(DefaultIdempotencyTTL, n)
+		}
+		ih.inFlight = make(map[string]bool)
+	})
+}
+
+// storeKey returns the key with which the response to r, bearing idempotency key k, is stored.
+func (ih *IdempotencyHandler) storeKey(r *http.Request, k string) string {
+	scope := r.Header.Get("Authorization")
+	if ih.Scope != nil {
+		scope = ih.Scope(r)
+	}
+	sum := sha256.Sum256([]byte(scope))
+	return hex.EncodeToString(sum[:]) + ":" + k
+}
+
+// fingerprint returns a value identifying the method, URL and body of r.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ServeHTTP serves the request r.
+func (ih *IdempotencyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	k := IdempotencyKey(r)
+	switch {
+	case k == "":
+		ih.Handler.ServeHTTP(w, r)
+		return
+	case r.Method == http.MethodGet, r.Method == http.MethodHead, r.Method == http.MethodOptions, r.Method == http.MethodTrace:
+		w.Header().Set(IdempotencyKeyHeader, k)
+		ih.Handler.ServeHTTP(w, r)
+		return
+	}
+	ih.init()
+
+	opts := []Option{WithHeader(IdempotencyKeyHeader, k)}
+
+	var body io.Reader = r.Body
+	if ih.MaxBodySize > 0 {
+		body = &maxBytesReader{r: body, n: ih.MaxBodySize}
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrBodyTooLarge) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		_ = WriteError(w, "failed to read request body", code, opts...)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	fp := fingerprint(r, b)
+
+	key := ih.storeKey(r, k)
+
+	ih.mu.Lock()
+	if ih.inFlight[key] {
+		ih.mu.Unlock()
+		_ = WriteError(w, "a request with this idempotency key is in progress", http.StatusConflict, opts...)
+		return
+	}
+	if ir, ok := ih.store.Load(key); ok {
+		ih.mu.Unlock()
+		if ir.Fingerprint != fp {
+			_ = WriteError(w, "idempotency key was used with a different request", http.StatusUnprocessableEntity, opts...)
+			return
+		}
+		h := w.Header()
+		for k, v := range ir.Header {
+			h[k] = append([]string(nil), v...)
+		}
+		h.Set(IdempotentReplayedHeader, "true")
+		w.WriteHeader(ir.Code)
+		_, _ = w.Write(ir.Body)
+		return
+	}
+	ih.inFlight[key] = true
+	ih.mu.Unlock()
+
+	defer func() {
+		ih.mu.Lock()
+		delete(ih.inFlight, key)
+		ih.mu.Unlock()
+	}()
+
+	w.Header().Set(IdempotencyKeyHeader, k)
+	rec := &batchRecorder{header: w.Header()}
+	tw := &teeResponseWriter{ResponseWriter: w, rec: rec}
+	ih.Handler.ServeHTTP(tw, r)
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+
+	if rec.code < http.StatusInternalServerError && rec.code != http.StatusTooManyRequests {
+		ih.store.Store(key, &IdempotentResponse{
+			Fingerprint: fp,
+			Code:        rec.code,
+			Header:      rec.header.Clone(),
+			Body:        append([]byte(nil), rec.body.Bytes()...),
+		})
+	}
+}
+
+// teeResponseWriter is an http.ResponseWriter that records the response written to it, while
+// writing it to the underlying ResponseWriter.
+type teeResponseWriter struct {
+	http.ResponseWriter
+	rec *batchRecorder
+}
+
+func (w *teeResponseWriter) WriteHeader(code int) {
+	if w.rec.code == 0 {
+		w.rec.WriteHeader(code)
+		// Headers set after the status code is written are not sent.
+		w.rec.header = w.ResponseWriter.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *teeResponseWriter) Write(p []byte) (int, error) {
+	if w.rec.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.rec.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *teeResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
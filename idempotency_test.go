@@ -0,0 +1,203 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"None", "", ""},
+		{"Token", "abc", "abc"},
+		{"Quoted", `"abc"`, "abc"},
+		{"Space", " abc ", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(IdempotencyKeyHeader, tt.header)
+			}
+			if got := IdempotencyKey(r); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithIdempotencyKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set(IdempotencyKeyHeader, "abc")
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponse(rr, "a", http.StatusCreated, WithIdempotencyKey(r)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get(IdempotencyKeyHeader), "abc"; got != want {
+		t.Errorf("got header %q, want %q", got, want)
+	}
+	if got, want := rr.Body.String(), `{"data":"a","meta":{"idempotencyKey":"abc"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteResponse(rr, "a", http.StatusCreated, WithIdempotencyKey(httptest.NewRequest(http.MethodPost, "/", nil))); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"data":"a"}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestIdempotencyHandler(t *testing.T) {
+	var calls int
+	ih := &IdempotencyHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			b, _ := io.ReadAll(r.Body)
+			if string(b) == "fail" {
+				_ = WriteError(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			_ = WriteResponse(w, calls, http.StatusCreated, WithHeader("X-Call", "1"))
+		}),
+		MaxBodySize: 8,
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		key          string
+		auth         string
+		body         string
+		wantCode     int
+		wantBody     string
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{"NoKey", http.MethodPost, "", "", "x", http.StatusCreated, `{"data":1}`, false, 1},
+		{"NoKeyRepeated", http.MethodPost, "", "", "x", http.StatusCreated, `{"data":2}`, false, 2},
+		{"First", http.MethodPost, "k1", "", "x", http.StatusCreated, `{"data":3}`, false, 3},
+		{"Replayed", http.MethodPost, "k1", "", "x", http.StatusCreated, `{"data":3}`, true, 3},
+		{"DifferentBody", http.MethodPost, "k1", "", "y", http.StatusUnprocessableEntity, `{"error":{"code":422,"message":"idempotency key was used with a different request"}}`, false, 3},
+		{"DifferentMethod", http.MethodPut, "k1", "", "x", http.StatusUnprocessableEntity, `{"error":{"code":422,"message":"idempotency key was used with a different request"}}`, false, 3},
+		{"DifferentClient", http.MethodPost, "k1", "Bearer other", "x", http.StatusCreated, `{"data":4}`, false, 4},
+		{"SafeMethod", http.MethodGet, "k1", "", "", http.StatusCreated, `{"data":5}`, false, 5},
+		{"Failure", http.MethodPost, "k2", "", "fail", http.StatusServiceUnavailable, `{"error":{"code":503,"message":"unavailable"}}`, false, 6},
+		{"FailureRetried", http.MethodPost, "k2", "", "fail", http.StatusServiceUnavailable, `{"error":{"code":503,"message":"unavailable"}}`, false, 7},
+		{"TooLarge", http.MethodPost, "k3", "", "123456789", http.StatusRequestEntityTooLarge, `{"error":{"code":413,"message":"failed to read request body"}}`, false, 7},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/things", strings.NewReader(tt.body))
+		if tt.key != "" {
+			r.Header.Set(IdempotencyKeyHeader, tt.key)
+		}
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		rr := httptest.NewRecorder()
+		ih.ServeHTTP(rr, r)
+
+		if got, want := rr.Code, tt.wantCode; got != want {
+			t.Errorf("%v: got code %v, want %v", tt.name, got, want)
+		}
+		if got, want := rr.Body.String(), tt.wantBody; got != want {
+			t.Errorf("%v: got body %q, want %q", tt.name, got, want)
+		}
+		if got, want := rr.Header().Get(IdempotentReplayedHeader) == "true", tt.wantReplayed; got != want {
+			t.Errorf("%v: got replayed %v, want %v", tt.name, got, want)
+		}
+		if got, want := rr.Header().Get(IdempotencyKeyHeader), tt.key; got != want {
+			t.Errorf("%v: got key %q, want %q", tt.name, got, want)
+		}
+		if tt.wantReplayed && rr.Header().Get("X-Call") != "1" {
+			t.Errorf("%v: headers not replayed: %v", tt.name, rr.Header())
+		}
+		if got, want := calls, tt.wantCalls; got != want {
+			t.Errorf("%v: got %v calls, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestIdempotencyHandlerInFlight(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	ih := &IdempotencyHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(IdempotencyKeyHeader, "k")
+		return r
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ih.ServeHTTP(httptest.NewRecorder(), newRequest())
+	}()
+	<-started
+
+	rr := httptest.NewRecorder()
+	ih.ServeHTTP(rr, newRequest())
+	if got, want := rr.Code, http.StatusConflict; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	close(release)
+	<-done
+
+	rr = httptest.NewRecorder()
+	ih.ServeHTTP(rr, newRequest())
+	if got, want := rr.Code, http.StatusNoContent; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	s := NewMemoryIdempotencyStore(10*time.Millisecond, 10)
+	ir := &IdempotentResponse{Code: http.StatusCreated}
+
+	s.Store("a", ir)
+	if got, ok := s.Load("a"); !ok || !reflect.DeepEqual(got, ir) {
+		t.Errorf("got %v, want %v", got, ir)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := s.Load("a"); ok {
+		t.Error("expired response loaded")
+	}
+}
+
+func TestMemoryIdempotencyStoreMaxEntries(t *testing.T) {
+	s := NewMemoryIdempotencyStore(time.Hour, 2)
+	for _, k := range []string{"a", "b", "c"} {
+		s.Store(k, &IdempotentResponse{Code: http.StatusCreated})
+	}
+
+	if _, ok := s.Load("a"); ok {
+		t.Error("evicted response loaded")
+	}
+	for _, k := range []string{"b", "c"} {
+		if _, ok := s.Load(k); !ok {
+			t.Errorf("response %v not loaded", k)
+		}
+	}
+}
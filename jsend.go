@@ -0,0 +1,165 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// JSend is the JSend wire format (application/json), for interoperating with services that
+// follow the JSend specification. It is never selected by content negotiation, as it shares a
+// media type with JSON.
+//
+// When writing, a response without an error becomes a "success" response carrying its data. An
+// error with a 4xx status code becomes a "fail" response, and any other error becomes an "error"
+// response. In both cases, the details of the Error become the "data" member, and its message and
+// code become the "message" and "code" members. The remaining fields of the Error, and the page,
+// warnings, meta and links of the envelope, are written as additional members, which JSend
+// clients ignore. When reading, the reverse mapping is applied. Documents without a "status"
+// member are passed through unchanged.
+var JSend Format = jsendFormat{}
+
+type jsendFormat struct{}
+
+// jsendEnvelopeMembers are the members of the response envelope other than data and error, which
+// are passed through unchanged.
+var jsendEnvelopeMembers = []string{"page", "warnings", "meta", "links"}
+
+func (jsendFormat) ContentType() string { return "application/json" }
+
+func (jsendFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+	if v.kind != '{' {
+		_, err := w.Write(b)
+		return err
+	}
+
+	d := jsonValue{kind: '{'}
+	if je, ok := v.member("error"); ok && je.kind == '{' {
+		code := http.StatusInternalServerError
+		if c, ok := je.member("code"); ok && c.kind == 'd' {
+			if n, err := strconv.Atoi(c.s); err == nil {
+				code = n
+			}
+		}
+		status := "error"
+		if code >= 400 && code < 500 {
+			status = "fail"
+		}
+		d.set("status", jsonValue{kind: 's', s: status})
+
+		data := jsonValue{kind: 'n'}
+		if dv, ok := je.member("details"); ok {
+			data = dv
+		}
+		d.set("data", data)
+
+		for i, k := range je.keys {
+			if k != "details" {
+				d.set(k, je.elems[i])
+			}
+		}
+	} else {
+		d.set("status", jsonValue{kind: 's', s: "success"})
+		data := jsonValue{kind: 'n'}
+		if dv, ok := v.member("data"); ok {
+			data = dv
+		}
+		d.set("data", data)
+	}
+
+	for _, k := range jsendEnvelopeMembers {
+		if e, ok := v.member(k); ok {
+			d.set(k, e)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := d.appendJSON(&buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (jsendFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	if v.kind != '{' {
+		return nil, errors.New("jsend: document is not an object")
+	}
+	s, ok := v.member("status")
+	if !ok {
+		return b, nil
+	}
+	if s.kind != 's' {
+		return nil, errors.New("jsend: status is not a string")
+	}
+
+	env := jsonValue{kind: '{'}
+	switch s.s {
+	case "success":
+		if d, ok := v.member("data"); ok && d.kind != 'n' {
+			env.set("data", d)
+		}
+	case "fail", "error":
+		env.set("error", jsendError(v, s.s))
+	default:
+		return nil, fmt.Errorf("jsend: unsupported status %q", s.s)
+	}
+
+	for _, k := range jsendEnvelopeMembers {
+		if e, ok := v.member(k); ok {
+			env.set(k, e)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := env.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsendError returns the Error described by the JSend document v, with the supplied status. If v
+// does not contain a code, one is chosen based on status.
+func jsendError(v jsonValue, status string) jsonValue {
+	je := jsonValue{kind: '{'}
+	if c, ok := v.member("code"); ok && c.kind == 'd' {
+		je.set("code", c)
+	} else if status == "fail" {
+		je.set("code", jsonValue{kind: 'd', s: strconv.Itoa(http.StatusBadRequest)})
+	} else {
+		je.set("code", jsonValue{kind: 'd', s: strconv.Itoa(http.StatusInternalServerError)})
+	}
+	if d, ok := v.member("data"); ok && d.kind == '{' {
+		je.set("details", d)
+	}
+
+	for i, k := range v.keys {
+		switch k {
+		case "status", "data", "code", "page", "warnings", "meta", "links":
+		default:
+			je.set(k, v.elems[i])
+		}
+	}
+	return je
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSendFromJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Data", `{"data":{"id":1}}`, `{"status":"success","data":{"id":1}}`},
+		{"NoData", `{}`, `{"status":"success","data":null}`},
+		{"Envelope", `{"data":[1],"page":{"next":"n"},"meta":{"v":1}}`, `{"status":"success","data":[1],"page":{"next":"n"},"meta":{"v":1}}`},
+		{"Fail", `{"error":{"code":400,"message":"blah","details":{"name":"required"}}}`, `{"status":"fail","data":{"name":"required"},"code":400,"message":"blah"}`},
+		{"FailNoDetails", `{"error":{"code":404,"message":"blah"}}`, `{"status":"fail","data":null,"code":404,"message":"blah"}`},
+		{"Error", `{"error":{"code":503,"appCode":"DOWN","message":"blah"}}`, `{"status":"error","data":null,"code":503,"appCode":"DOWN","message":"blah"}`},
+		{"ErrorNoCode", `{"error":{"message":"blah"}}`, `{"status":"error","data":null,"message":"blah"}`},
+		{"NotObject", `[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := JSend.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := buf.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJSendToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		jsend   string
+		want    string
+		wantErr bool
+	}{
+		{"Success", `{"status":"success","data":{"id":1}}`, `{"data":{"id":1}}`, false},
+		{"SuccessNull", `{"status":"success","data":null}`, `{}`, false},
+		{"SuccessEnvelope", `{"status":"success","data":[1],"page":{"next":"n"}}`, `{"data":[1],"page":{"next":"n"}}`, false},
+		{"Fail", `{"status":"fail","data":{"name":"required"}}`, `{"error":{"code":400,"details":{"name":"required"}}}`, false},
+		{"FailCode", `{"status":"fail","data":null,"code":404,"message":"blah"}`, `{"error":{"code":404,"message":"blah"}}`, false},
+		{"Error", `{"status":"error","message":"blah"}`, `{"error":{"code":500,"message":"blah"}}`, false},
+		{"ErrorExtensions", `{"status":"error","code":503,"message":"blah","appCode":"DOWN"}`, `{"error":{"code":503,"message":"blah","appCode":"DOWN"}}`, false},
+		{"NoStatus", `{"data":1}`, `{"data":1}`, false},
+		{"BadStatus", `{"status":"ok"}`, ``, true},
+		{"StatusNotString", `{"status":1}`, ``, true},
+		{"NotObject", `[]`, ``, true},
+		{"Invalid", `{`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := JSend.ToJSON(strings.NewReader(tt.jsend))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJSendRoundTrip(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, []string{"a", "b"}, &PageDetails{Next: "n"}, http.StatusOK, WithFormat(JSend)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/json"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	var got []string
+	pd, err := ReadResponsePage(rr.Body, &got, WithFormat(JSend))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := pd, (&PageDetails{Next: "n"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %v, want %v", got, want)
+	}
+}
+
+func TestReadErrorJSend(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteErrorDetails(rr, "invalid input", map[string]interface{}{"name": "required"}, http.StatusUnprocessableEntity, WithFormat(JSend)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	err := ReadError(rr.Body, WithFormat(JSend))
+	want := &Error{Code: http.StatusUnprocessableEntity, Message: "invalid input"}
+	if !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+	var je *Error
+	if !errors.As(err, &je) {
+		t.Fatalf("got error %T, want *Error", err)
+	}
+	if got, want := je.Details, map[string]interface{}{"name": "required"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got details %v, want %v", got, want)
+	}
+}
@@ -6,132 +6,734 @@
 package jsonresp
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 // Error describes an error condition.
 type Error struct {
-	Code    int    `json:"code,omitempty"`
-	Message string `json:"message,omitempty"`
+	Code    int                    `json:"code,omitempty"`
+	AppCode string                 `json:"appCode,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+
+	// MessageKey and MessageArgs identify a localizable message template and its arguments. When
+	// written by WriteLocalizedErr, they are used to replace Message with a translation.
+	MessageKey  string        `json:"messageKey,omitempty"`
+	MessageArgs []interface{} `json:"messageArgs,omitempty"`
+
+	// RetryAfter is the number of seconds the client should wait before retrying the request.
+	RetryAfter int `json:"retryAfter,omitempty"`
+
+	// RequestID identifies the request that caused the error. It is populated by WithRequestID.
+	RequestID string `json:"requestId,omitempty"`
+
+	// Context contains identifiers, such as trace and tenant IDs, that correlate the error with
+	// server-side records. It is populated by the extractor established by SetContextExtractor.
+	Context map[string]string `json:"context,omitempty"`
+
+	// Debug contains diagnostic information, and is only populated in debug mode.
+	Debug *DebugInfo `json:"debug,omitempty"`
+
+	// RawCode is the code of an error read from a response that is neither a number nor a string
+	// containing a number, such as the symbolic codes ("NOT_FOUND") emitted by some services. It
+	// is written as the code only if Code is zero.
+	RawCode string `json:"-"`
+
+	// Extra contains the members of an encoded error, decoded by UnmarshalJSON, that do not
+	// correspond to a field, such as those added by a newer version of this package. They are
+	// written after the fields, except where their names match a field.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// NewError returns an Error with the supplied message and status code.
+func NewError(message string, code int) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// NewAppError returns an Error with the supplied application error code, message and status code.
+// The application error code is a stable identifier (ie. "QUOTA_EXCEEDED") that clients can use to
+// distinguish error conditions that share a status code.
+func NewAppError(appCode, message string, code int) *Error {
+	return &Error{
+		Code:    code,
+		AppCode: appCode,
+		Message: message,
+	}
 }
 
 func (e *Error) Error() string {
+	status := fmt.Sprintf("%v %v", e.Code, http.StatusText(e.Code))
+	if e.Code == 0 && e.RawCode != "" {
+		status = e.RawCode
+	}
+
 	if e.Message != "" {
-		return fmt.Sprintf("%v (%v %v)", e.Message, e.Code, http.StatusText(e.Code))
+		return fmt.Sprintf("%v (%v)", e.Message, status)
 	}
-	return fmt.Sprintf("%v %v", e.Code, http.StatusText(e.Code))
+	return status
 }
 
 // Is compares e against target. If target is an Error and matches the non-zero fields of e, true
-// is returned.
+// is returned. If target was returned by CodeRange, or is ErrClientError or ErrServerError, true
+// is returned if the status code of e is within its range. If target is an ErrorDef, true is
+// returned if e has its application error code.
 func (e *Error) Is(target error) bool {
+	if cr, ok := target.(codeRange); ok {
+		return cr.contains(e.Code)
+	}
+	if d, ok := target.(*ErrorDef); ok {
+		return e.AppCode == d.AppCode
+	}
+
 	t, ok := target.(*Error)
 	if !ok {
 		return false
 	}
 	return ((e.Code == t.Code) || t.Code == 0) &&
+		((e.AppCode == t.AppCode) || t.AppCode == "") &&
 		((e.Message == t.Message) || t.Message == "")
 }
 
+// Temporary returns true if the condition that caused e is likely to be temporary, such that the
+// request may succeed if retried. This is the case for the statuses 408 Request Timeout, 429 Too
+// Many Requests, 502 Bad Gateway, 503 Service Unavailable and 504 Gateway Timeout.
+func (e *Error) Temporary() bool {
+	switch e.Code {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Timeout returns true if e indicates that a timeout occurred. This is the case for the statuses
+// 408 Request Timeout and 504 Gateway Timeout.
+func (e *Error) Timeout() bool {
+	return e.Code == http.StatusRequestTimeout || e.Code == http.StatusGatewayTimeout
+}
+
 // PageDetails specifies paging information.
 type PageDetails struct {
-	Prev      string `json:"prev,omitempty"`
-	Next      string `json:"next,omitempty"`
-	TotalSize int    `json:"totalSize,omitempty"`
+	Prev string `json:"prev,omitempty"`
+	Next string `json:"next,omitempty"`
+
+	// TotalSize is the total number of items, if known. A zero TotalSize indicates that the total
+	// is unknown, unless ZeroTotal is set.
+	TotalSize int64 `json:"totalSize,omitempty"`
+
+	// ZeroTotal indicates that the total number of items is known to be zero, as distinct from
+	// unknown. It is set when reading a response that specifies a total of zero.
+	ZeroTotal bool `json:"-"`
+
+	// Sort is the order in which the items were sorted, in the form returned by FormatSort, so
+	// that clients can construct cursors that remain stable across pages. It may be parsed with
+	// ParseSort.
+	Sort string `json:"sort,omitempty"`
+}
+
+// Total returns the total number of items, and whether it is known.
+func (pd *PageDetails) Total() (int64, bool) {
+	return pd.TotalSize, pd.TotalSize != 0 || pd.ZeroTotal
+}
+
+// MarshalJSON encodes pd, including a total of zero if ZeroTotal is set.
+func (pd PageDetails) MarshalJSON() ([]byte, error) {
+	type page PageDetails // Prevent recursion.
+	if pd.TotalSize != 0 || !pd.ZeroTotal {
+		return json.Marshal(page(pd))
+	}
+	return json.Marshal(struct {
+		page
+		TotalSize int64 `json:"totalSize"`
+	}{page: page(pd)})
+}
+
+// Warning describes a non-fatal condition, such as the use of a deprecated parameter.
+type Warning struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
 // Response is the top level container of all of our REST API responses.
 type Response struct {
-	Data  interface{}  `json:"data,omitempty"`
-	Page  *PageDetails `json:"page,omitempty"`
-	Error *Error       `json:"error,omitempty"`
+	Data     interface{}  `json:"data,omitempty"`
+	Page     *PageDetails `json:"page,omitempty"`
+	Error    *Error       `json:"error,omitempty"`
+	Warnings []Warning    `json:"warnings,omitempty"`
+
+	// Meta contains cross-cutting values, such as request identifiers or timing information,
+	// that do not belong in Data.
+	Meta map[string]interface{} `json:"meta,omitempty"`
+
+	// Links describes related resources and available actions, keyed by link relation.
+	Links map[string]Link `json:"links,omitempty"`
+}
+
+// writeHeader writes the response headers and status code to w.
+func writeHeader(w http.ResponseWriter, jr Response, code int, o *options) {
+	h := w.Header()
+	if ct := o.mediaType(); ct == jsonMediaType && !o.preserveHeaders {
+		h["Content-Type"] = []string{jsonMediaType}
+	} else {
+		o.setHeader(h, "Content-Type", ct)
+	}
+	if jr.Error != nil && jr.Error.RetryAfter > 0 {
+		o.setHeader(h, "Retry-After", strconv.Itoa(jr.Error.RetryAfter))
+	}
+	o.copyHeader(h)
+	w.WriteHeader(code)
 }
 
-func encodeResponse(w http.ResponseWriter, jr Response, code int) error {
+// bodyAllowed reports whether a response with status code code may include a body.
+func bodyAllowed(code int) bool {
+	informational := code >= 100 && code < 200
+	return !informational && code != http.StatusNoContent && code != http.StatusNotModified
+}
+
+// writeNoBody writes the headers established by o and status code code to w, without a body.
+func writeNoBody(w http.ResponseWriter, code int, o *options) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	o.copyHeader(h)
+	w.WriteHeader(code)
+	o.observeResponse(code, 0, Response{})
+}
+
+// encodeFailureMessage is the message of the error written in place of a response that failed to
+// encode.
+const encodeFailureMessage = "failed to encode response"
+
+// encodeFailureBody is the body written in place of a response that failed to encode. It is
+// encoded statically, so that it cannot itself fail to encode.
+var encodeFailureBody = []byte(`{"error":{"code":500,"message":"` + encodeFailureMessage + `"}}`)
+
+// writeEncodeFailure writes a 500 status code and encodeFailureBody to w in place of a response
+// that failed to encode with err, so that the client is not left waiting for a response. The
+// failure is reported to the hook established by WithErrorLog, and an error describing it is
+// returned.
+func writeEncodeFailure(w http.ResponseWriter, err error, o *options) error {
+	je := NewError(encodeFailureMessage, http.StatusInternalServerError)
+	o.logError(je, err)
+
+	h := w.Header()
+	h.Del("Content-Encoding")
+	h.Del("ETag")
+	h.Del("Last-Modified")
+	o.copyHeader(h)
+	h["Content-Type"], h["Content-Length"] = jsonHeaderValues(len(encodeFailureBody))
+	w.WriteHeader(je.Code)
+
+	var n int
+	if !o.head {
+		n, _ = w.Write(encodeFailureBody)
+	}
+	o.observeResponse(je.Code, n, Response{Error: je})
+	return fmt.Errorf("jsonresp: %v: %w", encodeFailureMessage, err)
+}
+
+func encodeResponse(w http.ResponseWriter, jr Response, code int, o *options) error {
+	if err := o.ctxErr(); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %w", err)
+	}
+
+	jr, err := o.applyHooks(o.envelope(jr))
+	if err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+	if err := o.validateData(jr); err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+	jr, err = o.transformData(jr)
+	if err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+
+	if !bodyAllowed(code) {
+		writeNoBody(w, code, o)
+		return nil
+	}
+
+	w = o.withWriteDeadline(w)
+
+	if o.stream && o.format == nil && !o.head && !o.canonical && !o.isBare(jr) {
+		if o.ctx != nil {
+			w = &ctxResponseWriter{ResponseWriter: w, ctx: o.ctx}
+		}
+		return streamResponse(w, jr, code, o)
+	}
+
 	// We _could_ encode the JSON directly to the response, but in so doing, the response code is
 	// written out the first time Write() is called under the hood. This makes it difficult to
 	// return an appropriate HTTP code when JSON encoding fails, so we use an intermediate buffer
-	// in order to preserve our ability to set the correct HTTP code.
-	b, err := json.Marshal(jr)
-	if err != nil {
-		return fmt.Errorf("jsonresp: failed to encode response: %v", err)
+	// in order to preserve our ability to set the correct HTTP code. Buffers are pooled to reduce
+	// allocations.
+	es := newEncodeState()
+	defer es.release()
+
+	if err := es.encodeResponse(jr, o); err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+	if err := o.checkResponseSize(es.Len()); err != nil {
+		return writeTooLarge(w, err, o)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	if _, err := w.Write(b); err != nil {
-		return fmt.Errorf("jsonresp: failed to write response: %v", err)
+	return writeBody(w, jr, code, es.Bytes(), o)
+}
+
+// writeBody writes the response headers, status code and encoded response body to w, applying the
+// compression and preconditions established by o.
+func writeBody(w http.ResponseWriter, jr Response, code int, body []byte, o *options) error {
+	h := w.Header()
+
+	var ce string
+	if o.compress {
+		addVary(h, "Accept-Encoding")
+		ce = o.contentEncoding(len(body))
+	}
+
+	if isSuccess(code) {
+		var etag string
+		if o.etag {
+			etag = entityTag(body, ce)
+			h.Set("ETag", etag)
+		}
+		if !o.lastModified.IsZero() {
+			h.Set("Last-Modified", o.lastModified.UTC().Format(http.TimeFormat))
+		}
+
+		switch o.precondition(etag) {
+		case http.StatusNotModified:
+			writeNoBody(w, http.StatusNotModified, o)
+			return nil
+		case http.StatusPreconditionFailed:
+			h.Del("ETag")
+			h.Del("Last-Modified")
+			return writeError(w, NewError("precondition failed", http.StatusPreconditionFailed), nil, o)
+		}
+	}
+
+	if ce != "" {
+		captureBody(w, body)
+
+		cs := newEncodeState()
+		defer cs.release()
+
+		var err error
+		if body, err = cs.compress(body, ce); err != nil {
+			return fmt.Errorf("jsonresp: failed to compress response: %v", err)
+		}
+		h.Set("Content-Encoding", ce)
+	}
+	// The body is buffered in full, so its length is known before the header is written. This
+	// allows clients to report progress and reuse connections.
+	h["Content-Length"] = contentLength(len(body))
+
+	if err := o.ctxErr(); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %w", err)
+	}
+
+	writeHeader(w, jr, code, o)
+	if o.head {
+		o.observeResponse(code, 0, jr)
+		return nil
+	}
+	n, err := w.Write(body)
+	o.observeResponse(code, n, jr)
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %w", err)
 	}
 	return nil
 }
 
+// writeError writes a status code and JSON response containing je to w, prepared as by
+// prepareError. writeError must be called directly by exported functions for the captured stack
+// to be accurate.
+func writeError(w http.ResponseWriter, je *Error, cause error, o *options) error {
+	je = o.prepareError(je, cause, 1)
+
+	jr := Response{
+		Error: je,
+	}
+	return encodeResponse(w, jr, je.Code, o)
+}
+
+// prepareError reports je, caused by cause if non-nil, to the hooks established by o, and returns
+// the Error to be written in its place. If debug mode is enabled, diagnostic information
+// describing the caller of the exported write function and cause is included. If production
+// mode is enabled, server errors are sanitized. The argument skip is the number of stack frames
+// between the exported write function and prepareError.
+func (o *options) prepareError(je *Error, cause error, skip int) *Error {
+	o.logError(je, cause)
+
+	if s := sanitize(je, cause); s != je {
+		je = s
+	} else if isDebug() {
+		c := *je
+		c.Debug = &DebugInfo{
+			Stack: callers(skip + 2),
+			Chain: chain(cause),
+		}
+		je = &c
+	}
+	return o.withContextIDs(o.withRequestID(je))
+}
+
 // WriteError writes a status code and JSON response containing the supplied error message and
 // status code to w.
-func WriteError(w http.ResponseWriter, message string, code int) error {
-	jr := Response{
-		Error: &Error{
-			Code:    code,
-			Message: message,
-		},
+func WriteError(w http.ResponseWriter, message string, code int, opts ...Option) error {
+	if len(opts) == 0 {
+		if ok, err := writeErrorFast(w, message, code); ok {
+			return err
+		}
+	}
+	return writeError(w, NewError(message, code), nil, newOptions(opts))
+}
+
+// WriteErrorDetails writes a status code and JSON response containing the supplied error message,
+// status code and machine-readable details to w.
+func WriteErrorDetails(w http.ResponseWriter, message string, details map[string]interface{}, code int, opts ...Option) error {
+	je := &Error{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+	return writeError(w, je, nil, newOptions(opts))
+}
+
+// WriteErrorf writes a status code and JSON response containing an error message formatted
+// according to format, in the same way as fmt.Errorf, and the status code to w. If format contains
+// the %w verb, the resulting error is supplied to the debug and production mode hooks as the cause
+// of the error written.
+//
+// As args is variadic, options are supplied among args, in any position. They are applied to the
+// response, and do not correspond to verbs in format.
+func WriteErrorf(w http.ResponseWriter, code int, format string, args ...interface{}) error {
+	var opts []Option
+	if hasOptions(args) {
+		fargs := make([]interface{}, 0, len(args))
+		for _, a := range args {
+			if opt, ok := a.(Option); ok {
+				opts = append(opts, opt)
+			} else {
+				fargs = append(fargs, a)
+			}
+		}
+		args = fargs
+	}
+	err := fmt.Errorf(format, args...)
+
+	var cause error
+	switch err.(type) {
+	case interface{ Unwrap() error }, interface{ Unwrap() []error }:
+		cause = err
 	}
-	return encodeResponse(w, jr, code)
+	return writeError(w, NewError(err.Error(), code), cause, newOptions(opts))
+}
+
+// hasOptions reports whether args contains an Option.
+func hasOptions(args []interface{}) bool {
+	for _, a := range args {
+		if _, ok := a.(Option); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteErrorFrom writes a status code and JSON response containing the text of err and the
+// supplied status code to w. Unlike WriteErr, the status code is not derived from err. The
+// original err is supplied to the hooks established by WithErrorLog, SetProduction and SetDebug.
+func WriteErrorFrom(w http.ResponseWriter, err error, code int, opts ...Option) error {
+	var message string
+	if err != nil {
+		message = err.Error()
+	}
+	return writeError(w, NewError(message, code), err, newOptions(opts))
+}
+
+// WriteAppError writes a status code and JSON response containing the supplied application error
+// code, error message and status code to w.
+func WriteAppError(w http.ResponseWriter, appCode, message string, code int, opts ...Option) error {
+	return writeError(w, NewAppError(appCode, message, code), nil, newOptions(opts))
 }
 
 // WriteResponsePage writes a status code and JSON response containing data and pd to w.
-func WriteResponsePage(w http.ResponseWriter, data interface{}, pd *PageDetails, code int) error {
+func WriteResponsePage(w http.ResponseWriter, data interface{}, pd *PageDetails, code int, opts ...Option) error {
 	jr := Response{
 		Data: data,
 		Page: pd,
 	}
-	return encodeResponse(w, jr, code)
+	return encodeResponse(w, jr, code, newOptions(opts))
+}
+
+// WriteRawResponse writes a status code and JSON response containing the pre-encoded JSON raw and
+// pd to w. The raw JSON is embedded in the response as-is, without being decoded and re-encoded,
+// so the order of object keys is preserved. An error is returned if raw is not valid JSON.
+func WriteRawResponse(w http.ResponseWriter, raw json.RawMessage, pd *PageDetails, code int, opts ...Option) error {
+	jr := Response{
+		Page: pd,
+	}
+	if len(raw) > 0 {
+		jr.Data = raw
+	}
+	return encodeResponse(w, jr, code, newOptions(opts))
 }
 
 // WriteResponse writes a status code and JSON response containing data to w.
-func WriteResponse(w http.ResponseWriter, data interface{}, code int) error {
-	return WriteResponsePage(w, data, nil, code)
+func WriteResponse(w http.ResponseWriter, data interface{}, code int, opts ...Option) error {
+	return WriteResponsePage(w, data, nil, code, opts...)
+}
+
+// WriteResponseMeta writes a status code and JSON response containing data and meta to w.
+func WriteResponseMeta(w http.ResponseWriter, data interface{}, meta map[string]interface{}, code int, opts ...Option) error {
+	jr := Response{
+		Data: data,
+		Meta: meta,
+	}
+	return encodeResponse(w, jr, code, newOptions(opts))
+}
+
+// WriteCreated writes a 201 status code and JSON response containing data to w, with the Location
+// header set to location, the URL of the created resource.
+func WriteCreated(w http.ResponseWriter, data interface{}, location string, opts ...Option) error {
+	w.Header().Set("Location", location)
+	return WriteResponsePage(w, data, nil, http.StatusCreated, opts...)
+}
+
+// WriteNoContent writes a 204 status code to w, without a body or Content-Type header. Status
+// codes that do not permit a body, such as 204, are handled in the same way by the other write
+// functions.
+func WriteNoContent(w http.ResponseWriter, opts ...Option) {
+	writeNoBody(w, http.StatusNoContent, newOptions(opts))
+}
+
+// EncodeResponse writes the JSON encoding of jr to w. Unlike the Write functions, it does not
+// require an http.ResponseWriter, so it can be used to write responses to files, message queues
+// and test fixtures. Options that set headers have no effect.
+func EncodeResponse(w io.Writer, jr Response, opts ...Option) error {
+	es := newEncodeState()
+	defer es.release()
+
+	if err := es.encodeEnvelope(jr, newOptions(opts)); err != nil {
+		return err
+	}
+	if _, err := w.Write(es.Bytes()); err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %v", err)
+	}
+	return nil
+}
+
+// encodeEnvelope encodes jr into es, as written by EncodeResponse with o.
+func (es *encodeState) encodeEnvelope(jr Response, o *options) error {
+	jr, err := o.prepareResponse(jr)
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %v", err)
+	}
+	if err := es.encodeResponse(jr, o); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %v", err)
+	}
+	if err := o.checkResponseSize(es.Len()); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %w", err)
+	}
+	return nil
+}
+
+// rawResponse is the wire representation of a Response, with data left encoded.
+type rawResponse struct {
+	Data     json.RawMessage        `json:"data"`
+	Page     *wirePage              `json:"page"`
+	Error    *wireError             `json:"error"`
+	Warnings []Warning              `json:"warnings"`
+	Meta     map[string]interface{} `json:"meta"`
+	Links    map[string]Link        `json:"links"`
+
+	// packed is the data in the format in which the response was read, if it was retained in that
+	// format to be decoded directly, rather than converted to JSON. See valueFormat.
+	packed []byte
+	format Format
+}
+
+// data returns the encoded data of u as JSON. Data retained in another format was checked as it
+// was read, so converts without error.
+func (u *rawResponse) data() json.RawMessage {
+	if u.packed == nil {
+		return u.Data
+	}
+	b, _ := u.format.ToJSON(bytes.NewReader(u.packed))
+	return b
+}
+
+// unmarshalResponseData unmarshals the data of u into v, decoding it directly from the format in
+// which it was retained if possible. If unmarshalling fails, a DecodeError is returned.
+func (o *options) unmarshalResponseData(u *rawResponse, v interface{}) error {
+	if vf, ok := u.format.(valueFormat); ok && u.packed != nil && vf.decodeValue(u.packed, v, o) == nil {
+		return nil
+	}
+	return o.unmarshalData(u.data(), v)
+}
+
+// wirePage is the wire representation of a PageDetails, which distinguishes a total of zero from
+// an unknown total.
+type wirePage struct {
+	Prev      string `json:"prev"`
+	Next      string `json:"next"`
+	TotalSize *int64 `json:"totalSize"`
+	Sort      string `json:"sort"`
+}
+
+// page returns the PageDetails represented by wp, or nil if wp is nil.
+func (wp *wirePage) page() *PageDetails {
+	if wp == nil {
+		return nil
+	}
+	pd := PageDetails{Prev: wp.Prev, Next: wp.Next, Sort: wp.Sort}
+	if wp.TotalSize != nil {
+		pd.TotalSize = *wp.TotalSize
+		pd.ZeroTotal = pd.TotalSize == 0
+	}
+	return &pd
+}
+
+// wireError is the wire representation of an Error. Its code may be encoded as a number, or as a
+// string.
+type wireError struct {
+	errorFields
+	Code errorCode `json:"code"`
+}
+
+// errorCode is the code of an error, decoded tolerantly.
+type errorCode struct {
+	n   int
+	raw string
+}
+
+// UnmarshalJSON decodes a code encoded as a number, a string containing a number, or any other
+// string, which is retained as is.
+func (c *errorCode) UnmarshalJSON(b []byte) error {
+	if len(b) == 0 || b[0] != '"' {
+		return json.Unmarshal(b, &c.n)
+	}
+
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		c.n = n
+	} else {
+		c.raw = s
+	}
+	return nil
+}
+
+// error returns the Error represented by we, or nil if we is nil.
+func (we *wireError) error() *Error {
+	if we == nil {
+		return nil
+	}
+	e := Error(we.errorFields)
+	e.Code = we.Code.n
+	e.RawCode = we.Code.raw
+	return &e
+}
+
+// response returns the Response represented by u.
+func (u rawResponse) response() Response {
+	jr := Response{
+		Page:     u.Page.page(),
+		Error:    u.Error.error(),
+		Warnings: u.Warnings,
+		Meta:     u.Meta,
+		Links:    u.Links,
+	}
+	if data := u.data(); len(data) > 0 {
+		jr.Data = data
+	}
+	return jr
+}
+
+// DecodeResponse reads a JSON response from r. The data of the returned Response, if present, is
+// of type json.RawMessage. Unlike the Read functions, an error contained within the response is
+// returned as the Error field of the Response, rather than as an error.
+func DecodeResponse(r io.Reader, opts ...Option) (Response, error) {
+	var u rawResponse
+	if err := newOptions(opts).decodeResponse(r, &u); err != nil {
+		return Response{}, fmt.Errorf("jsonresp: failed to read response: %w", err)
+	}
+	return u.response(), nil
 }
 
 // ReadResponsePage reads a paged JSON response, and unmarshals the supplied data.
-func ReadResponsePage(r io.Reader, v interface{}) (pd *PageDetails, err error) {
-	var u struct {
-		Data  json.RawMessage `json:"data"`
-		Page  *PageDetails    `json:"page"`
-		Error *Error          `json:"error"`
+func ReadResponsePage(r io.Reader, v interface{}, opts ...Option) (pd *PageDetails, err error) {
+	o := newOptions(opts)
+
+	var u rawResponse
+	if err := o.decodeResponse(r, &u); err != nil {
+		return nil, fmt.Errorf("jsonresp: failed to read response: %w", err)
 	}
-	if err := json.NewDecoder(r).Decode(&u); err != nil {
-		return nil, fmt.Errorf("jsonresp: failed to read response: %v", err)
+	if o.envelopeTo != nil {
+		*o.envelopeTo = u.response()
 	}
 	if u.Error != nil {
-		return nil, u.Error
+		return nil, u.Error.error()
 	}
 	if v != nil {
-		if err := json.Unmarshal(u.Data, v); err != nil {
-			return nil, fmt.Errorf("jsonresp: failed to unmarshal response: %v", err)
+		if err := o.unmarshalResponseData(&u, v); err != nil {
+			return nil, fmt.Errorf("jsonresp: failed to unmarshal response: %w", err)
 		}
 	}
-	return u.Page, nil
+	return u.Page.page(), nil
 }
 
 // ReadResponse reads a JSON response, and unmarshals the supplied data.
-func ReadResponse(r io.Reader, v interface{}) error {
-	_, err := ReadResponsePage(r, v)
+func ReadResponse(r io.Reader, v interface{}, opts ...Option) error {
+	_, err := ReadResponsePage(r, v, opts...)
 	return err
 }
 
 // ReadError attempts to unmarshal JSON-encoded error details from the supplied reader. It returns
-// nil if an error could not be parsed from the response, or if the parsed error was nil.
-func ReadError(r io.Reader) error {
-	var u struct {
-		Error *Error `json:"error"`
+// nil if an error could not be parsed from the response, or if the parsed error was nil, unless
+// WithFallbackError is used. If the response exceeds the limits established by WithMaxBodySize or
+// WithMaxDepth, an error wrapping ErrBodyTooLarge or ErrMaxDepth is returned.
+func ReadError(r io.Reader, opts ...Option) error {
+	return readError(r, 0, newOptions(opts))
+}
+
+// readError reads an error from r, in the same way as ReadError. If an error is synthesized due to
+// WithFallbackError, it has status code code.
+func readError(r io.Reader, code int, o *options) error {
+	var s *snippet
+	if o.fallbackError {
+		s = &snippet{max: maxSnippetSize}
+		r = io.TeeReader(r, s)
 	}
-	if err := json.NewDecoder(r).Decode(&u); err != nil {
-		return nil
+
+	var u rawResponse
+	if err := o.decodeResponse(r, &u); err != nil {
+		if errors.Is(err, ErrBodyTooLarge) || errors.Is(err, ErrMaxDepth) {
+			return fmt.Errorf("jsonresp: failed to read error: %w", err)
+		}
+		u.Error = nil
 	}
 	if u.Error == nil {
+		if s != nil {
+			return NewError(s.String(), code)
+		}
 		return nil
 	}
-	return u.Error
+	return u.Error.error()
 }
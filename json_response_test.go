@@ -14,6 +14,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -59,6 +61,71 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestErrorIs(t *testing.T) {
+	err := NewAppError("QUOTA_EXCEEDED", "blah", http.StatusTooManyRequests)
+
+	tests := []struct {
+		name   string
+		target error
+		want   bool
+	}{
+		{"Empty", &Error{}, true},
+		{"Code", &Error{Code: http.StatusTooManyRequests}, true},
+		{"CodeMismatch", &Error{Code: http.StatusNotFound}, false},
+		{"AppCode", &Error{AppCode: "QUOTA_EXCEEDED"}, true},
+		{"AppCodeMismatch", &Error{AppCode: "NOT_FOUND"}, false},
+		{"Message", &Error{Message: "blah"}, true},
+		{"MessageMismatch", &Error{Message: "other"}, false},
+		{"All", NewAppError("QUOTA_EXCEEDED", "blah", http.StatusTooManyRequests), true},
+		{"NotError", errors.New("blah"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(err, tt.target); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorTemporaryTimeout(t *testing.T) {
+	tests := []struct {
+		code          int
+		wantTemporary bool
+		wantTimeout   bool
+	}{
+		{http.StatusBadRequest, false, false},
+		{http.StatusNotFound, false, false},
+		{http.StatusRequestTimeout, true, true},
+		{http.StatusTooManyRequests, true, false},
+		{http.StatusInternalServerError, false, false},
+		{http.StatusBadGateway, true, false},
+		{http.StatusServiceUnavailable, true, false},
+		{http.StatusGatewayTimeout, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			var err error = NewError("", tt.code)
+
+			var te interface{ Temporary() bool }
+			if !errors.As(err, &te) {
+				t.Fatalf("error does not implement Temporary")
+			}
+			if got, want := te.Temporary(), tt.wantTemporary; got != want {
+				t.Errorf("got temporary %v, want %v", got, want)
+			}
+
+			var to interface{ Timeout() bool }
+			if !errors.As(err, &to) {
+				t.Fatalf("error does not implement Timeout")
+			}
+			if got, want := to.Timeout(), tt.wantTimeout; got != want {
+				t.Errorf("got timeout %v, want %v", got, want)
+			}
+		})
+	}
+}
+
 func TestWriteError(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -92,6 +159,88 @@ func TestWriteError(t *testing.T) {
 	}
 }
 
+func TestWriteErrorf(t *testing.T) {
+	errBase := errors.New("base")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-ID", "r")
+
+	tests := []struct {
+		name       string
+		code       int
+		format     string
+		args       []interface{}
+		wantErr    *Error
+		wantReport error
+	}{
+		{"NoArgs", http.StatusNotFound, "no such thing", nil, &Error{Code: http.StatusNotFound, Message: "no such thing"}, nil},
+		{"Args", http.StatusNotFound, "no such thing %q", []interface{}{"blah"}, &Error{Code: http.StatusNotFound, Message: `no such thing "blah"`}, nil},
+		{"ServerError", http.StatusInternalServerError, "failed: %v", []interface{}{errBase}, &Error{Code: http.StatusInternalServerError, Message: "Internal Server Error"}, &Error{Code: http.StatusInternalServerError, Message: "failed: base"}},
+		{"Wrapped", http.StatusInternalServerError, "failed: %w", []interface{}{errBase}, &Error{Code: http.StatusInternalServerError, Message: "Internal Server Error"}, errBase},
+		{"Options", http.StatusNotFound, "no such thing %q", []interface{}{"blah", WithRequestID(r)}, &Error{Code: http.StatusNotFound, Message: `no such thing "blah"`, RequestID: "r"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported error
+			SetProduction(true, func(err error) { reported = err })
+			defer SetProduction(false, nil)
+
+			rr := httptest.NewRecorder()
+			if err := WriteErrorf(rr, tt.code, tt.format, tt.args...); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, tt.code; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+
+			var je *Error
+			if !errors.As(ReadError(rr.Body), &je) {
+				t.Fatalf("failed to read error")
+			}
+			if got, want := je, tt.wantErr; !reflect.DeepEqual(got, want) {
+				t.Errorf("got error %+v, want %+v", got, want)
+			}
+
+			if got, want := reported, tt.wantReport; !errors.Is(got, want) {
+				t.Errorf("got reported error %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteErrorFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    int
+		wantErr *Error
+	}{
+		{"Nil", nil, http.StatusBadRequest, &Error{Code: http.StatusBadRequest}},
+		{"Error", errors.New("invalid name"), http.StatusBadRequest, &Error{Code: http.StatusBadRequest, Message: "invalid name"}},
+		{"JSONError", NewError("blah", http.StatusNotFound), http.StatusBadGateway, &Error{Code: http.StatusBadGateway, Message: "blah (404 Not Found)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteErrorFrom(rr, tt.err, tt.code); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, tt.code; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+
+			var je *Error
+			if !errors.As(ReadError(rr.Body), &je) {
+				t.Fatalf("failed to read error")
+			}
+			if got, want := je, tt.wantErr; !reflect.DeepEqual(got, want) {
+				t.Errorf("got error %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
 func TestWriteResponsePage(t *testing.T) {
 	type TestStruct struct {
 		Value string
@@ -172,6 +321,10 @@ func TestWriteResponse(t *testing.T) {
 				t.Fatalf("failed to write response: %v", err)
 			}
 
+			if got, want := rr.Header().Get("Content-Length"), strconv.Itoa(rr.Body.Len()); got != want {
+				t.Errorf("got content length %v, want %v", got, want)
+			}
+
 			var ts TestStruct
 			if err := ReadResponse(rr.Body, &ts); err != nil {
 				t.Fatalf("failed to decode response: %v", err)
@@ -186,6 +339,150 @@ func TestWriteResponse(t *testing.T) {
 	}
 }
 
+func TestWriteCreated(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	if err := WriteCreated(rr, "blah", "/things/1"); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if got, want := rr.Code, http.StatusCreated; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Header().Get("Location"), "/things/1"; got != want {
+		t.Errorf("got location %q, want %q", got, want)
+	}
+
+	var s string
+	if err := ReadResponse(rr.Body, &s); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if got, want := s, "blah"; got != want {
+		t.Errorf("got data %q, want %q", got, want)
+	}
+}
+
+func TestWriteNoContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter) error
+		code  int
+	}{
+		{"WriteNoContent", func(w http.ResponseWriter) error {
+			WriteNoContent(w, WithHeader("X-Test", "blah"))
+			return nil
+		}, http.StatusNoContent},
+		{"WriteResponse", func(w http.ResponseWriter) error {
+			return WriteResponse(w, nil, http.StatusNoContent, WithHeader("X-Test", "blah"))
+		}, http.StatusNoContent},
+		{"NotModified", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusNotModified, WithHeader("X-Test", "blah"))
+		}, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Content-Type", "application/json")
+
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.code; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got := rr.Header().Get("Content-Type"); got != "" {
+				t.Errorf("got content type %q, want none", got)
+			}
+			if got, want := rr.Header().Get("X-Test"), "blah"; got != want {
+				t.Errorf("got header %q, want %q", got, want)
+			}
+			if got := rr.Body.Len(); got != 0 {
+				t.Errorf("got %v byte body, want none", got)
+			}
+		})
+	}
+}
+
+func TestWriteRawResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      json.RawMessage
+		pd       *PageDetails
+		wantErr  bool
+		wantBody string
+	}{
+		{"Nil", nil, nil, false, `{}`},
+		{"KeyOrder", json.RawMessage(`{"z": 1, "a": [2, 3]}`), nil, false, `{"data":{"z":1,"a":[2,3]}}`},
+		{"Page", json.RawMessage(`[1,2]`), &PageDetails{Next: "n"}, false, `{"data":[1,2],"page":{"next":"n"}}`},
+		{"Invalid", json.RawMessage(`{"z":`), nil, true, string(encodeFailureBody)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			err := WriteRawResponse(rr, tt.raw, tt.pd, http.StatusOK)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteResponseEncodeFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     interface{}
+		opts     []Option
+		wantBody string
+	}{
+		{"Buffered", make(chan int), nil, string(encodeFailureBody)},
+		{"Header", make(chan int), []Option{WithHeader("X-Blah", "blah")}, string(encodeFailureBody)},
+		{"Format", make(chan int), []Option{WithFormat(XML)}, string(encodeFailureBody)},
+		{"Fields", make(chan int), []Option{WithFields(FieldSet{"a": nil})}, string(encodeFailureBody)},
+		{"Encoder", 1, []Option{WithStream(), WithEncoder(func(interface{}) ([]byte, error) {
+			return nil, errors.New("blah")
+		})}, string(encodeFailureBody)},
+		{"Head", make(chan int), []Option{WithHead(httptest.NewRequest(http.MethodHead, "/", nil))}, ""},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			var logged error
+			opts := append([]Option{WithErrorLog(nil, func(r *http.Request, code int, err error) {
+				logged = err
+			})}, tt.opts...)
+
+			err := WriteResponse(rr, tt.data, http.StatusOK, opts...)
+			if err == nil {
+				t.Fatalf("got no error")
+			}
+			if logged == nil {
+				t.Errorf("got no logged error")
+			}
+
+			if got, want := rr.Code, http.StatusInternalServerError; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), "application/json"; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Length"), strconv.Itoa(len(encodeFailureBody)); got != want {
+				t.Errorf("got content length %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
 func getResponseBodyPage(v interface{}, p *PageDetails) io.Reader {
 	rr := httptest.NewRecorder()
 	if err := WriteResponsePage(rr, v, p, http.StatusOK); err != nil {
@@ -254,6 +551,54 @@ func TestReadResponsePage(t *testing.T) {
 	}
 }
 
+func TestPageDetailsTotal(t *testing.T) {
+	tests := []struct {
+		name      string
+		pd        PageDetails
+		wantJSON  string
+		wantTotal int64
+		wantKnown bool
+	}{
+		{"Unknown", PageDetails{Next: "n"}, `{"next":"n"}`, 0, false},
+		{"Zero", PageDetails{ZeroTotal: true}, `{"totalSize":0}`, 0, true},
+		{"Total", PageDetails{TotalSize: 42}, `{"totalSize":42}`, 42, true},
+		{"TotalZeroTotal", PageDetails{TotalSize: 42, ZeroTotal: true}, `{"totalSize":42}`, 42, true},
+		{"Large", PageDetails{TotalSize: 1 << 40}, `{"totalSize":1099511627776}`, 1 << 40, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.pd)
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if got := string(b); got != tt.wantJSON {
+				t.Errorf("got JSON %v, want %v", got, tt.wantJSON)
+			}
+
+			for _, stream := range []bool{false, true} {
+				opts := []Option{WithStrict()}
+				if stream {
+					opts = append(opts, WithStream())
+				}
+				rr := httptest.NewRecorder()
+				if err := WriteResponsePage(rr, []int{}, &tt.pd, http.StatusOK, opts...); err != nil {
+					t.Fatalf("failed to write response: %v", err)
+				}
+
+				var v []int
+				pd, err := ReadResponsePage(rr.Body, &v, opts...)
+				if err != nil {
+					t.Fatalf("failed to read response: %v", err)
+				}
+				total, known := pd.Total()
+				if total != tt.wantTotal || known != tt.wantKnown {
+					t.Errorf("got total %v (known %v), want %v (known %v)", total, known, tt.wantTotal, tt.wantKnown)
+				}
+			}
+		})
+	}
+}
+
 func TestReadResponse(t *testing.T) {
 	type TestStruct struct {
 		Value string
@@ -334,3 +679,136 @@ func TestReadError(t *testing.T) {
 		})
 	}
 }
+
+func TestReadErrorCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr *Error
+		wantMsg string
+	}{
+		{"Number", `{"error":{"code":404,"message":"blah"}}`, &Error{Code: http.StatusNotFound, Message: "blah"}, "blah (404 Not Found)"},
+		{"NumericString", `{"error":{"code":"404","message":"blah"}}`, &Error{Code: http.StatusNotFound, Message: "blah"}, "blah (404 Not Found)"},
+		{"NumericStringSpace", `{"error":{"code":" 409 ","message":"blah"}}`, &Error{Code: http.StatusConflict, Message: "blah"}, "blah (409 Conflict)"},
+		{"SymbolicString", `{"error":{"code":"NOT_FOUND","message":"blah"}}`, &Error{Message: "blah", RawCode: "NOT_FOUND"}, "blah (NOT_FOUND)"},
+		{"SymbolicStringNoMessage", `{"error":{"code":"NOT_FOUND"}}`, &Error{RawCode: "NOT_FOUND"}, "NOT_FOUND"},
+		{"Null", `{"error":{"code":null,"message":"blah"}}`, &Error{Message: "blah"}, "blah (0 )"},
+		{"Missing", `{"error":{"message":"blah"}}`, &Error{Message: "blah"}, "blah (0 )"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var je *Error
+			if !errors.As(ReadError(strings.NewReader(tt.body)), &je) {
+				t.Fatalf("failed to read error")
+			}
+			if got, want := je, tt.wantErr; !reflect.DeepEqual(got, want) {
+				t.Errorf("got error %#v, want %#v", got, want)
+			}
+			if got, want := je.Error(), tt.wantMsg; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadErrorCodeInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"error":{"code":404.5}}`,
+		`{"error":{"code":true}}`,
+	} {
+		if _, err := DecodeResponse(strings.NewReader(body)); err == nil {
+			t.Errorf("%v: got nil error, want error", body)
+		}
+	}
+}
+
+func TestWriteErrorDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		details     map[string]interface{}
+		code        int
+		wantDetails map[string]interface{}
+	}{
+		{"NoDetails", "blah", nil, http.StatusNotFound, nil},
+		{"Details", "blah", map[string]interface{}{"id": "abc", "limit": 10}, http.StatusForbidden, map[string]interface{}{"id": "abc", "limit": float64(10)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			if err := WriteErrorDetails(rr, tt.message, tt.details, tt.code); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if rr.Code != tt.code {
+				t.Errorf("got code %v, want %v", rr.Code, tt.code)
+			}
+
+			var je *Error
+			if !errors.As(ReadError(rr.Body), &je) {
+				t.Fatalf("failed to read error")
+			}
+			if got, want := je.Details, tt.wantDetails; !reflect.DeepEqual(got, want) {
+				t.Errorf("got details %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteAppError(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	if err := WriteAppError(rr, "QUOTA_EXCEEDED", "blah", http.StatusTooManyRequests); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	if got, want := rr.Code, http.StatusTooManyRequests; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+
+	want := &Error{Code: http.StatusTooManyRequests, AppCode: "QUOTA_EXCEEDED", Message: "blah"}
+	if got := ReadError(rr.Body); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
+
+func TestEncodeDecodeResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		jr       Response
+		wantBody string
+		wantJR   Response
+	}{
+		{"Empty", Response{}, `{}`, Response{}},
+		{"Data", Response{Data: []int{1, 2}}, `{"data":[1,2]}`, Response{Data: json.RawMessage(`[1,2]`)}},
+		{"Page", Response{Data: "a", Page: &PageDetails{Next: "n"}}, `{"data":"a","page":{"next":"n"}}`, Response{Data: json.RawMessage(`"a"`), Page: &PageDetails{Next: "n"}}},
+		{"Error", Response{Error: NewError("blah", http.StatusNotFound)}, `{"error":{"code":404,"message":"blah"}}`, Response{Error: NewError("blah", http.StatusNotFound)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+
+			if err := EncodeResponse(&buf, tt.jr); err != nil {
+				t.Fatalf("failed to encode response: %v", err)
+			}
+			if got, want := buf.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+
+			jr, err := DecodeResponse(&buf)
+			if err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got, want := jr, tt.wantJR; !reflect.DeepEqual(got, want) {
+				t.Errorf("got response %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseInvalid(t *testing.T) {
+	if _, err := DecodeResponse(bytes.NewReader(nil)); err == nil {
+		t.Errorf("got nil error, want error")
+	}
+}
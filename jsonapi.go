@@ -0,0 +1,291 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// JSONAPI is the JSON:API wire format (application/vnd.api+json). Data is written and read as the
+// primary data of the document unchanged, so it should be a resource object, or a slice of
+// resource objects, with "type" and "id" members.
+//
+// When writing, an Error becomes the sole member of "errors", with its code, application code and
+// message mapped to the "status", "code" and "detail" members, and its details and remaining
+// fields to "meta". The previous and next page URLs and the links of the envelope become members
+// of "links", and the total size and sort of the page, and the warnings and meta of the envelope,
+// become members of "meta". When reading, the reverse mapping is applied. Only the first member of
+// "errors" is read.
+var JSONAPI Format = jsonAPIFormat{}
+
+type jsonAPIFormat struct{}
+
+// jsonAPIErrorMeta are the fields of an Error, other than details, that are written to the meta
+// of a JSON:API error object.
+var jsonAPIErrorMeta = []string{"messageKey", "messageArgs", "retryAfter", "requestId", "context", "debug"}
+
+func (jsonAPIFormat) ContentType() string { return "application/vnd.api+json" }
+
+func (jsonAPIFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+	if v.kind != '{' {
+		_, err := w.Write(b)
+		return err
+	}
+
+	d := jsonValue{kind: '{'}
+	if data, ok := v.member("data"); ok {
+		d.set("data", data)
+	}
+	if je, ok := v.member("error"); ok && je.kind == '{' {
+		d.set("errors", jsonValue{kind: '[', elems: []jsonValue{jsonAPIErrorFromJSON(je)}})
+	}
+
+	links := jsonValue{kind: '{'}
+	meta := jsonValue{kind: '{'}
+	if pd, ok := v.member("page"); ok && pd.kind == '{' {
+		for _, k := range []string{"prev", "next"} {
+			if e, ok := pd.member(k); ok {
+				links.set(k, e)
+			}
+		}
+		for _, k := range []string{"totalSize", "sort"} {
+			if e, ok := pd.member(k); ok {
+				meta.set(k, e)
+			}
+		}
+	}
+	if ls, ok := v.member("links"); ok && ls.kind == '{' {
+		for i, k := range ls.keys {
+			links.set(k, jsonAPILinkFromJSON(ls.elems[i]))
+		}
+	}
+	if ws, ok := v.member("warnings"); ok {
+		meta.set("warnings", ws)
+	}
+	if m, ok := v.member("meta"); ok && m.kind == '{' {
+		for i, k := range m.keys {
+			meta.set(k, m.elems[i])
+		}
+	}
+	if len(links.keys) > 0 {
+		d.set("links", links)
+	}
+	if len(meta.keys) > 0 {
+		d.set("meta", meta)
+	}
+
+	var buf bytes.Buffer
+	if err := d.appendJSON(&buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// jsonAPIErrorFromJSON returns the JSON:API error object corresponding to the Error je.
+func jsonAPIErrorFromJSON(je jsonValue) jsonValue {
+	e := jsonValue{kind: '{'}
+	code := http.StatusInternalServerError
+	if c, ok := je.member("code"); ok && c.kind == 'd' {
+		if n, err := strconv.Atoi(c.s); err == nil {
+			code = n
+		}
+	}
+	e.set("status", jsonValue{kind: 's', s: strconv.Itoa(code)})
+	if c, ok := je.member("appCode"); ok {
+		e.set("code", c)
+	}
+	e.set("title", jsonValue{kind: 's', s: http.StatusText(code)})
+	if m, ok := je.member("message"); ok {
+		e.set("detail", m)
+	}
+
+	meta := jsonValue{kind: '{'}
+	if ds, ok := je.member("details"); ok && ds.kind == '{' {
+		for i, k := range ds.keys {
+			meta.set(k, ds.elems[i])
+		}
+	}
+	for _, k := range jsonAPIErrorMeta {
+		if m, ok := je.member(k); ok {
+			meta.set(k, m)
+		}
+	}
+	if len(meta.keys) > 0 {
+		e.set("meta", meta)
+	}
+	return e
+}
+
+// jsonAPILinkFromJSON returns the JSON:API link object corresponding to the Link l.
+func jsonAPILinkFromJSON(l jsonValue) jsonValue {
+	if l.kind != '{' {
+		return l
+	}
+	e := jsonValue{kind: '{'}
+	if h, ok := l.member("href"); ok {
+		e.set("href", h)
+	}
+	if t, ok := l.member("title"); ok {
+		e.set("title", t)
+	}
+	if m, ok := l.member("method"); ok {
+		e.set("meta", jsonValue{kind: '{', keys: []string{"method"}, elems: []jsonValue{m}})
+	}
+	return e
+}
+
+func (jsonAPIFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	if v.kind != '{' {
+		return nil, errors.New("jsonapi: document is not an object")
+	}
+
+	env := jsonValue{kind: '{'}
+	if data, ok := v.member("data"); ok && data.kind != 'n' {
+		env.set("data", data)
+	}
+
+	page := jsonValue{kind: '{'}
+	links := jsonValue{kind: '{'}
+	if ls, ok := v.member("links"); ok && ls.kind == '{' {
+		for i, k := range ls.keys {
+			switch k {
+			case "prev", "next":
+				if h, ok := jsonAPIHref(ls.elems[i]); ok {
+					page.set(k, h)
+				}
+			default:
+				links.set(k, jsonAPILinkToJSON(ls.elems[i]))
+			}
+		}
+	}
+
+	meta := jsonValue{kind: '{'}
+	var warnings jsonValue
+	if m, ok := v.member("meta"); ok && m.kind == '{' {
+		for i, k := range m.keys {
+			switch k {
+			case "totalSize", "sort":
+				page.set(k, m.elems[i])
+			case "warnings":
+				warnings = m.elems[i]
+			default:
+				meta.set(k, m.elems[i])
+			}
+		}
+	}
+
+	if len(page.keys) > 0 {
+		env.set("page", page)
+	}
+	if es, ok := v.member("errors"); ok && es.kind == '[' && len(es.elems) > 0 {
+		env.set("error", jsonAPIErrorToJSON(es.elems[0]))
+	}
+	if warnings.kind != 0 {
+		env.set("warnings", warnings)
+	}
+	if len(meta.keys) > 0 {
+		env.set("meta", meta)
+	}
+	if len(links.keys) > 0 {
+		env.set("links", links)
+	}
+
+	var buf bytes.Buffer
+	if err := env.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonAPIErrorToJSON returns the Error corresponding to the JSON:API error object e.
+func jsonAPIErrorToJSON(e jsonValue) jsonValue {
+	je := jsonValue{kind: '{'}
+	if s, ok := e.member("status"); ok {
+		switch s.kind {
+		case 's':
+			if _, err := strconv.Atoi(s.s); err == nil {
+				je.set("code", jsonValue{kind: 'd', s: s.s})
+			}
+		case 'd':
+			je.set("code", s)
+		}
+	}
+	if c, ok := e.member("code"); ok {
+		je.set("appCode", c)
+	}
+	if d, ok := e.member("detail"); ok {
+		je.set("message", d)
+	} else if t, ok := e.member("title"); ok {
+		je.set("message", t)
+	}
+
+	if m, ok := e.member("meta"); ok && m.kind == '{' {
+		details := jsonValue{kind: '{'}
+		for i, k := range m.keys {
+			if contains(jsonAPIErrorMeta, k) {
+				je.set(k, m.elems[i])
+			} else {
+				details.set(k, m.elems[i])
+			}
+		}
+		if len(details.keys) > 0 {
+			je.set("details", details)
+		}
+	}
+	return je
+}
+
+// jsonAPIHref returns the URL of the JSON:API link l, which is either a string or a link object.
+func jsonAPIHref(l jsonValue) (jsonValue, bool) {
+	switch l.kind {
+	case 's':
+		return l, true
+	case '{':
+		h, ok := l.member("href")
+		return h, ok && h.kind == 's'
+	}
+	return jsonValue{}, false
+}
+
+// jsonAPILinkToJSON returns the Link corresponding to the JSON:API link l.
+func jsonAPILinkToJSON(l jsonValue) jsonValue {
+	e := jsonValue{kind: '{'}
+	if h, ok := jsonAPIHref(l); ok {
+		e.set("href", h)
+	} else {
+		e.set("href", jsonValue{kind: 's'})
+	}
+	if l.kind != '{' {
+		return e
+	}
+	if m, ok := l.member("meta"); ok && m.kind == '{' {
+		if method, ok := m.member("method"); ok {
+			e.set("method", method)
+		}
+	}
+	if t, ok := l.member("title"); ok {
+		e.set("title", t)
+	}
+	return e
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONAPIFromJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Data", `{"data":{"type":"things","id":"1"}}`, `{"data":{"type":"things","id":"1"}}`},
+		{"Page", `{"data":[],"page":{"prev":"p","next":"n","totalSize":3}}`, `{"data":[],"links":{"prev":"p","next":"n"},"meta":{"totalSize":3}}`},
+		{"PageSort", `{"data":[],"page":{"sort":"-id"}}`, `{"data":[],"meta":{"sort":"-id"}}`},
+		{"Links", `{"data":null,"links":{"self":{"href":"/a"},"delete":{"href":"/a","method":"DELETE","title":"Delete"}}}`, `{"data":null,"links":{"self":{"href":"/a"},"delete":{"href":"/a","title":"Delete","meta":{"method":"DELETE"}}}}`},
+		{"Meta", `{"data":1,"warnings":[{"message":"w"}],"meta":{"requestId":"r"}}`, `{"data":1,"meta":{"warnings":[{"message":"w"}],"requestId":"r"}}`},
+		{"Error", `{"error":{"code":404,"message":"blah"}}`, `{"errors":[{"status":"404","title":"Not Found","detail":"blah"}]}`},
+		{"ErrorMeta", `{"error":{"code":429,"appCode":"QUOTA","message":"blah","details":{"limit":5},"retryAfter":5}}`, `{"errors":[{"status":"429","code":"QUOTA","title":"Too Many Requests","detail":"blah","meta":{"limit":5,"retryAfter":5}}]}`},
+		{"ErrorNoCode", `{"error":{"message":"blah"}}`, `{"errors":[{"status":"500","title":"Internal Server Error","detail":"blah"}]}`},
+		{"NotObject", `[1]`, `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := JSONAPI.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := buf.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONAPIToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		want    string
+		wantErr bool
+	}{
+		{"Data", `{"data":{"type":"things","id":"1"},"jsonapi":{"version":"1.1"}}`, `{"data":{"type":"things","id":"1"}}`, false},
+		{"NullData", `{"data":null}`, `{}`, false},
+		{"Page", `{"data":[],"links":{"prev":"p","next":{"href":"n"}},"meta":{"totalSize":3}}`, `{"data":[],"page":{"prev":"p","next":"n","totalSize":3}}`, false},
+		{"PageSort", `{"data":[],"meta":{"sort":"-id","v":1}}`, `{"data":[],"page":{"sort":"-id"},"meta":{"v":1}}`, false},
+		{"Links", `{"data":[],"links":{"self":"/a","delete":{"href":"/a","title":"Delete","meta":{"method":"DELETE"}}}}`, `{"data":[],"links":{"self":{"href":"/a"},"delete":{"href":"/a","method":"DELETE","title":"Delete"}}}`, false},
+		{"Meta", `{"data":1,"meta":{"warnings":[{"message":"w"}],"requestId":"r"}}`, `{"data":1,"warnings":[{"message":"w"}],"meta":{"requestId":"r"}}`, false},
+		{"Error", `{"errors":[{"status":"404","title":"Not Found","detail":"blah"},{"status":"400"}]}`, `{"error":{"code":404,"message":"blah"}}`, false},
+		{"ErrorTitle", `{"errors":[{"status":"404","title":"Not Found"}]}`, `{"error":{"code":404,"message":"Not Found"}}`, false},
+		{"ErrorMeta", `{"errors":[{"status":"429","code":"QUOTA","meta":{"limit":5,"retryAfter":5}}]}`, `{"error":{"code":429,"appCode":"QUOTA","retryAfter":5,"details":{"limit":5}}}`, false},
+		{"ErrorBadStatus", `{"errors":[{"status":"bad","detail":"blah"}]}`, `{"error":{"message":"blah"}}`, false},
+		{"NotObject", `[]`, ``, true},
+		{"Invalid", `{`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := JSONAPI.ToJSON(strings.NewReader(tt.doc))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJSONAPIRoundTrip(t *testing.T) {
+	type thing struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	in := []thing{{"things", "1"}, {"things", "2"}}
+
+	rr := httptest.NewRecorder()
+	opts := []Option{WithFormat(JSONAPI), WithSelfLink("/things"), WithMeta("requestId", "r")}
+	if err := WriteResponsePage(rr, in, &PageDetails{Next: "/things?cursor=2", TotalSize: 4}, http.StatusOK, opts...); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/vnd.api+json"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	var got []thing
+	var jr Response
+	pd, err := ReadResponsePage(rr.Body, &got, WithFormat(JSONAPI), WithEnvelope(&jr))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("got %v, want %v", got, in)
+	}
+	if got, want := pd, (&PageDetails{Next: "/things?cursor=2", TotalSize: 4}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %v, want %v", got, want)
+	}
+	if got, want := jr.Links, map[string]Link{LinkSelf: {Href: "/things"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %v, want %v", got, want)
+	}
+	if got, want := jr.Meta, map[string]interface{}{"requestId": "r"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got meta %v, want %v", got, want)
+	}
+}
+
+func TestReadErrorJSONAPI(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusConflict, WithFormat(JSONAPI)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	want := &Error{Code: http.StatusConflict, Message: "blah"}
+	if got := ReadError(rr.Body, WithFormat(JSONAPI)); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
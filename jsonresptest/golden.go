@@ -0,0 +1,139 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+var update = flag.Bool("jsonresptest.update", false, "update golden files")
+
+// volatilePlaceholder replaces the values of volatile object members.
+const volatilePlaceholder = "<volatile>"
+
+// errorVolatile are the members of the error of a response that are always treated as volatile,
+// as they vary between otherwise identical responses.
+var errorVolatile = []string{"requestId", "debug"}
+
+// Canonicalize returns the JSON document b in a deterministic form, with object members sorted by
+// key and indented. The values of object members named in volatile, at any depth, are replaced by
+// a placeholder, so that values such as timestamps do not cause spurious differences. The
+// requestId and debug members of the error of a response are always treated as volatile.
+func Canonicalize(b []byte, volatile ...string) ([]byte, error) {
+	v, err := decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("jsonresptest: failed to decode document: %v", err)
+	}
+	v = normalize(v, volatile)
+	if m, ok := v.(map[string]interface{}); ok {
+		if je, ok := m["error"].(map[string]interface{}); ok {
+			normalize(je, errorVolatile)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("jsonresptest: failed to encode document: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// normalize replaces the values of object members of v named in volatile with a placeholder.
+func normalize(v interface{}, volatile []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if contains(volatile, k) {
+				v[k] = volatilePlaceholder
+			} else {
+				v[k] = normalize(e, volatile)
+			}
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e, volatile)
+		}
+	}
+	return v
+}
+
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// goldenPath returns the path of the golden file with the supplied name.
+func goldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// AssertGolden reports a test failure if the JSON document got differs from the golden file
+// testdata/<name>.golden, after both are canonicalized as by Canonicalize. If the test binary is
+// run with the -jsonresptest.update flag, the golden file is written instead.
+func AssertGolden(t TB, name string, got []byte, volatile ...string) {
+	t.Helper()
+
+	c, err := Canonicalize(got, volatile...)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	path := goldenPath(name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, c, 0o600); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -jsonresptest.update to create it): %v", err)
+	}
+	if bytes.Equal(c, want) {
+		return
+	}
+
+	diffs, err := Diff(c, want)
+	if err != nil {
+		t.Fatalf("failed to compare with golden file %v: %v", path, err)
+	}
+	if len(diffs) == 0 {
+		// The documents are equivalent, but the golden file is not in canonical form.
+		diffs = []string{"golden file is not canonical"}
+	}
+	t.Errorf("response differs from golden file %v:\n\t%v", path, strings.Join(diffs, "\n\t"))
+}
+
+// AssertGoldenResponse encodes jr as by jsonresp.EncodeResponse, and compares it against the
+// golden file testdata/<name>.golden in the same way as AssertGolden.
+func AssertGoldenResponse(t TB, name string, jr jsonresp.Response, volatile ...string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jsonresp.EncodeResponse(&buf, jr); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+	AssertGolden(t, name, buf.Bytes(), volatile...)
+}
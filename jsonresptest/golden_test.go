@@ -0,0 +1,95 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresptest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		volatile []string
+		want     string
+		wantErr  bool
+	}{
+		{"Sorted", `{"b":1,"a":{"d":[1,2],"c":"<&>"}}`, nil, "{\n  \"a\": {\n    \"c\": \"<&>\",\n    \"d\": [\n      1,\n      2\n    ]\n  },\n  \"b\": 1\n}\n", false},
+		{"Numbers", `{"n":12345678901234567890}`, nil, "{\n  \"n\": 12345678901234567890\n}\n", false},
+		{"Volatile", `{"data":[{"createdAt":"2021-01-01T00:00:00Z"}]}`, []string{"createdAt"}, "{\n  \"data\": [\n    {\n      \"createdAt\": \"<volatile>\"\n    }\n  ]\n}\n", false},
+		{"RequestID", `{"error":{"code":500,"requestId":"abc"}}`, nil, "{\n  \"error\": {\n    \"code\": 500,\n    \"requestId\": \"<volatile>\"\n  }\n}\n", false},
+		{"DataRequestID", `{"data":{"debug":true,"requestId":"abc"}}`, nil, "{\n  \"data\": {\n    \"debug\": true,\n    \"requestId\": \"abc\"\n  }\n}\n", false},
+		{"Invalid", `{`, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Canonicalize([]byte(tt.doc), tt.volatile...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(jsonresp.RequestIDHeader, time.Now().String())
+
+	rr := httptest.NewRecorder()
+	err := jsonresp.WriteErrorDetails(rr, "invalid thing", map[string]interface{}{
+		"field": "name",
+		"at":    time.Now(),
+	}, http.StatusBadRequest, jsonresp.WithRequestID(r))
+	if err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	AssertGolden(t, "error", rr.Body.Bytes(), "at")
+}
+
+func TestAssertGoldenResponse(t *testing.T) {
+	AssertGoldenResponse(t, "page", jsonresp.Response{
+		Data: []string{"a", "b"},
+		Page: &jsonresp.PageDetails{Next: "/things?cursor=2", TotalSize: 4},
+	})
+}
+
+func TestAssertGoldenMismatch(t *testing.T) {
+	if *update {
+		t.Skip("golden files are being updated")
+	}
+
+	tb := run(func(tb TB) {
+		AssertGoldenResponse(tb, "page", jsonresp.Response{Data: []string{"a"}})
+	})
+	if got, want := len(tb.errors), 1; got != want {
+		t.Fatalf("got errors %q, want %v errors", tb.errors, want)
+	}
+
+	tb = run(func(tb TB) { AssertGolden(tb, "missing", []byte(`{}`)) })
+	if !tb.fatal {
+		t.Errorf("got fatal %v, want true", tb.fatal)
+	}
+}
+
+func TestCanonicalizeSharedVolatile(t *testing.T) {
+	volatile := make([]string, 1, 3)
+	volatile[0] = "createdAt"
+	if _, err := Canonicalize([]byte(`{"data":{}}`), volatile...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := volatile[:cap(volatile)][1]; got != "" {
+		t.Errorf("volatile modified: %q", got)
+	}
+}
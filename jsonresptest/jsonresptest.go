@@ -0,0 +1,195 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package jsonresptest provides utilities for testing handlers that write responses using the
+// jsonresp package.
+package jsonresptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// TB is the subset of testing.TB used by this package.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// AssertStatus reports a test failure if the status code of rr is not code.
+func AssertStatus(t TB, rr *httptest.ResponseRecorder, code int) {
+	t.Helper()
+
+	if got := rr.Code; got != code {
+		t.Errorf("got status %v, want %v (body %q)", got, code, rr.Body.String())
+	}
+}
+
+// AssertError reads the error response recorded by rr, and reports a fatal test failure if it does
+// not contain an Error with status code code, or if the message of the Error does not contain
+// msgSubstr. The status code of rr must also be code. The Error is returned.
+func AssertError(t TB, rr *httptest.ResponseRecorder, code int, msgSubstr string) *jsonresp.Error {
+	t.Helper()
+
+	AssertStatus(t, rr, code)
+
+	var je *jsonresp.Error
+	if err := jsonresp.ReadError(bytes.NewReader(rr.Body.Bytes())); !errors.As(err, &je) {
+		t.Fatalf("got error %v, want *jsonresp.Error (body %q)", err, rr.Body.String())
+	}
+	if je.Code != code {
+		t.Errorf("got error code %v, want %v", je.Code, code)
+	}
+	if !strings.Contains(je.Message, msgSubstr) {
+		t.Errorf("got error message %q, want message containing %q", je.Message, msgSubstr)
+	}
+	return je
+}
+
+// DecodeResponse decodes the response recorded by rr, reporting a fatal test failure if it cannot
+// be decoded. The data of the returned Response, if present, is of type json.RawMessage.
+func DecodeResponse(t TB, rr *httptest.ResponseRecorder, opts ...jsonresp.Option) jsonresp.Response {
+	t.Helper()
+
+	jr, err := jsonresp.DecodeResponse(bytes.NewReader(rr.Body.Bytes()), opts...)
+	if err != nil {
+		t.Fatalf("failed to decode response: %v (body %q)", err, rr.Body.String())
+	}
+	return jr
+}
+
+// DecodeData decodes the data of the response recorded by rr into a value of type T, reporting a
+// fatal test failure if the response cannot be decoded or contains an error.
+func DecodeData[T any](t TB, rr *httptest.ResponseRecorder, opts ...jsonresp.Option) T {
+	t.Helper()
+
+	var v T
+	if err := jsonresp.ReadResponse(bytes.NewReader(rr.Body.Bytes()), &v, opts...); err != nil {
+		t.Fatalf("failed to read response: %v (body %q)", err, rr.Body.String())
+	}
+	return v
+}
+
+// AssertResponse reports a test failure describing each difference between the response recorded
+// by rr and want, after both are encoded as JSON.
+func AssertResponse(t TB, rr *httptest.ResponseRecorder, want jsonresp.Response) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jsonresp.EncodeResponse(&buf, want); err != nil {
+		t.Fatalf("failed to encode response: %v", err)
+	}
+
+	diffs, err := Diff(rr.Body.Bytes(), buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to compare responses: %v", err)
+	}
+	if len(diffs) > 0 {
+		t.Errorf("response differs:\n\t%v", strings.Join(diffs, "\n\t"))
+	}
+}
+
+// Diff compares the JSON documents got and want, and returns a description of each difference
+// between them, identified by its JSON Pointer. Object members are compared regardless of order.
+func Diff(got, want []byte) ([]string, error) {
+	g, err := decode(got)
+	if err != nil {
+		return nil, fmt.Errorf("jsonresptest: failed to decode got: %v", err)
+	}
+	w, err := decode(want)
+	if err != nil {
+		return nil, fmt.Errorf("jsonresptest: failed to decode want: %v", err)
+	}
+
+	var diffs []string
+	diff("", g, w, &diffs)
+	return diffs, nil
+}
+
+func decode(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// diff appends a description of each difference between got and want, at the JSON Pointer path,
+// to diffs.
+func diff(path string, got, want interface{}, diffs *[]string) {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, k := range unionKeys(g, w) {
+			p := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+			gv, gok := g[k]
+			wv, wok := w[k]
+			switch {
+			case !gok:
+				*diffs = append(*diffs, fmt.Sprintf("%v: missing, want %v", p, encode(wv)))
+			case !wok:
+				*diffs = append(*diffs, fmt.Sprintf("%v: got %v, want missing", p, encode(gv)))
+			default:
+				diff(p, gv, wv, diffs)
+			}
+		}
+		return
+
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			break
+		}
+		for i := range w {
+			diff(fmt.Sprintf("%v/%v", path, i), g[i], w[i], diffs)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		if path == "" {
+			path = "/"
+		}
+		*diffs = append(*diffs, fmt.Sprintf("%v: got %v, want %v", path, encode(got), encode(want)))
+	}
+}
+
+// unionKeys returns the keys of a and b, in sorted order.
+func unionKeys(a, b map[string]interface{}) []string {
+	ks := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		ks = append(ks, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			ks = append(ks, k)
+		}
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func encode(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
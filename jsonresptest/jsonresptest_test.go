@@ -0,0 +1,194 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresptest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"testing"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// fakeTB records test failures.
+type fakeTB struct {
+	errors []string
+	fatal  bool
+}
+
+func (*fakeTB) Helper() {}
+
+func (tb *fakeTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Fatalf(format string, args ...interface{}) {
+	tb.Errorf(format, args...)
+	tb.fatal = true
+	runtime.Goexit()
+}
+
+// run calls f with a fakeTB, and returns it once f completes or fails fatally.
+func run(f func(tb TB)) *fakeTB {
+	tb := &fakeTB{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f(tb)
+	}()
+	<-done
+	return tb
+}
+
+func TestAssertError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := jsonresp.WriteError(rr, "thing not found", http.StatusNotFound); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		code       int
+		substr     string
+		wantErrors int
+		wantFatal  bool
+	}{
+		{"Match", http.StatusNotFound, "not found", 0, false},
+		{"WrongCode", http.StatusConflict, "not found", 2, false},
+		{"WrongMessage", http.StatusNotFound, "conflict", 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var je *jsonresp.Error
+			tb := run(func(tb TB) { je = AssertError(tb, rr, tt.code, tt.substr) })
+
+			if got, want := len(tb.errors), tt.wantErrors; got != want {
+				t.Errorf("got errors %q, want %v errors", tb.errors, want)
+			}
+			if got, want := tb.fatal, tt.wantFatal; got != want {
+				t.Errorf("got fatal %v, want %v", got, want)
+			}
+			if je == nil || je.Message != "thing not found" {
+				t.Errorf("got error %v", je)
+			}
+		})
+	}
+}
+
+func TestAssertErrorNotError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := jsonresp.WriteResponse(rr, "blah", http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if tb := run(func(tb TB) { AssertError(tb, rr, http.StatusOK, "") }); !tb.fatal {
+		t.Errorf("got fatal %v, want true", tb.fatal)
+	}
+}
+
+func TestDecodeData(t *testing.T) {
+	type thing struct {
+		ID int `json:"id"`
+	}
+
+	rr := httptest.NewRecorder()
+	if err := jsonresp.WriteResponse(rr, []thing{{1}, {2}}, http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if got, want := DecodeData[[]thing](t, rr), []thing{{1}, {2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The body is not consumed.
+	if got, want := DecodeData[[]thing](t, rr), []thing{{1}, {2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := jsonresp.WriteError(rr, "blah", http.StatusNotFound); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if tb := run(func(tb TB) { DecodeData[[]thing](tb, rr) }); !tb.fatal {
+		t.Errorf("got fatal %v, want true", tb.fatal)
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := jsonresp.WriteResponsePage(rr, "blah", &jsonresp.PageDetails{Next: "n"}, http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	jr := DecodeResponse(t, rr)
+	if got, want := jr.Page, (&jsonresp.PageDetails{Next: "n"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %v, want %v", got, want)
+	}
+}
+
+func TestAssertResponse(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := jsonresp.WriteResponsePage(rr, map[string]int{"a": 1, "b": 2}, &jsonresp.PageDetails{Next: "n"}, http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		want       jsonresp.Response
+		wantErrors []string
+	}{
+		{"Equal", jsonresp.Response{
+			Data: map[string]int{"b": 2, "a": 1},
+			Page: &jsonresp.PageDetails{Next: "n"},
+		}, nil},
+		{"Different", jsonresp.Response{
+			Data: map[string]int{"a": 2},
+		}, []string{"response differs:\n\t/data/a: got 1, want 2\n\t/data/b: got 2, want missing\n\t/page: got {\"next\":\"n\"}, want missing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := run(func(tb TB) { AssertResponse(tb, rr, tt.want) })
+			if got, want := tb.errors, tt.wantErrors; !reflect.DeepEqual(got, want) {
+				t.Errorf("got errors %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		got     string
+		want    string
+		diffs   []string
+		wantErr bool
+	}{
+		{"Equal", `{"a":[1,{"b":null}]}`, `{"a":[1,{"b":null}]}`, nil, false},
+		{"Order", `{"a":1,"b":2}`, `{"b":2,"a":1}`, nil, false},
+		{"Numbers", `{"a":1.0}`, `{"a":1}`, []string{`/a: got 1.0, want 1`}, false},
+		{"Missing", `{}`, `{"a":1}`, []string{`/a: missing, want 1`}, false},
+		{"Extra", `{"a/b":1}`, `{}`, []string{`/a~1b: got 1, want missing`}, false},
+		{"Array", `[1,2]`, `[1,3]`, []string{`/1: got 2, want 3`}, false},
+		{"ArrayLength", `{"a":[1]}`, `{"a":[1,2]}`, []string{`/a: got [1], want [1,2]`}, false},
+		{"Type", `1`, `"1"`, []string{`/: got 1, want "1"`}, false},
+		{"InvalidGot", `{`, `{}`, nil, true},
+		{"InvalidWant", `{}`, `{`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := Diff([]byte(tt.got), []byte(tt.want))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := diffs, tt.diffs; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresptest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// Fixture is a canned response served by RoundTripper.
+type Fixture struct {
+	// Code is the status code of the response. If zero, the code of Error is used, or 200 if Error
+	// is nil. If neither specifies a code, an error response has a 500 status code.
+	Code int
+
+	// Data and Page are the data and paging information of a successful response.
+	Data interface{}
+	Page *jsonresp.PageDetails
+
+	// Error is the error described by the response, if any.
+	Error *jsonresp.Error
+
+	// Header contains additional headers of the response.
+	Header http.Header
+}
+
+// RoundTripper is an http.RoundTripper that serves fixtures in the format written by the jsonresp
+// write functions, without making network requests. It is safe for concurrent use. The zero value
+// serves a 404 response to every request.
+type RoundTripper struct {
+	mu       sync.Mutex
+	fixtures map[string]Fixture
+	requests []*http.Request
+}
+
+// Handle causes f to be served in reply to requests with any method for url. The url is either an
+// absolute URL, or a path with optional query string matching any host.
+func (rt *RoundTripper) Handle(url string, f Fixture) {
+	rt.HandleMethod("", url, f)
+}
+
+// HandleMethod causes f to be served in reply to requests with the supplied method for url, in
+// preference to any fixture established by Handle. The url is interpreted as by Handle.
+func (rt *RoundTripper) HandleMethod(method, url string, f Fixture) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if rt.fixtures == nil {
+		rt.fixtures = make(map[string]Fixture)
+	}
+	rt.fixtures[method+" "+url] = f
+}
+
+// lookup returns the fixture for req.
+func (rt *RoundTripper) lookup(req *http.Request) (Fixture, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.requests = append(rt.requests, req)
+
+	for _, method := range []string{req.Method, ""} {
+		for _, url := range []string{req.URL.String(), req.URL.RequestURI()} {
+			if f, ok := rt.fixtures[method+" "+url]; ok {
+				return f, true
+			}
+		}
+	}
+	return Fixture{}, false
+}
+
+// RoundTrip serves the fixture established for req. If there is none, a 404 error response is
+// served.
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	f, ok := rt.lookup(req)
+	if !ok {
+		f.Error = jsonresp.NewError(fmt.Sprintf("no fixture for %v %v", req.Method, req.URL), http.StatusNotFound)
+	}
+
+	rr := httptest.NewRecorder()
+	for k, v := range f.Header {
+		rr.Header()[k] = v
+	}
+
+	var err error
+	switch code := f.Code; {
+	case f.Error != nil:
+		if code == 0 {
+			code = f.Error.Code
+		}
+		if code == 0 {
+			code = http.StatusInternalServerError
+		}
+		je := *f.Error
+		je.Code = code
+		err = jsonresp.WriteErr(rr, &je)
+	default:
+		if code == 0 {
+			code = http.StatusOK
+		}
+		err = jsonresp.WriteResponsePage(rr, f.Data, f.Page, code)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsonresptest: failed to write fixture: %v", err)
+	}
+
+	res := rr.Result()
+	res.Request = req
+	return res, nil
+}
+
+// Requests returns the requests received, in the order they were received.
+func (rt *RoundTripper) Requests() []*http.Request {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]*http.Request(nil), rt.requests...)
+}
+
+// Client returns an http.Client that sends requests to rt.
+func (rt *RoundTripper) Client() *http.Client {
+	return &http.Client{Transport: rt}
+}
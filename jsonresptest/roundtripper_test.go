@@ -0,0 +1,77 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresptest
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+func TestRoundTripper(t *testing.T) {
+	var rt RoundTripper
+	rt.Handle("https://example.com/things", Fixture{
+		Data: []string{"a", "b"},
+		Page: &jsonresp.PageDetails{Next: "/things?cursor=2"},
+	})
+	rt.HandleMethod(http.MethodDelete, "https://example.com/things", Fixture{Code: http.StatusNoContent})
+	rt.Handle("/things/1", Fixture{Data: "one", Header: http.Header{"X-Test": {"1"}}})
+	rt.Handle("/things/2", Fixture{Error: jsonresp.NewError("gone", http.StatusGone)})
+	rt.Handle("/things/3", Fixture{Code: http.StatusServiceUnavailable, Error: &jsonresp.Error{Message: "down"}})
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantData   interface{}
+		wantPage   *jsonresp.PageDetails
+		wantErr    error
+		wantHeader string
+	}{
+		{"Absolute", http.MethodGet, "https://example.com/things", []interface{}{"a", "b"}, &jsonresp.PageDetails{Next: "/things?cursor=2"}, nil, ""},
+		{"Method", http.MethodDelete, "https://example.com/things", nil, nil, nil, ""},
+		{"Path", http.MethodGet, "https://other.example.com/things/1", "one", nil, nil, "1"},
+		{"Error", http.MethodGet, "https://example.com/things/2", nil, nil, &jsonresp.Error{Code: http.StatusGone, Message: "gone"}, ""},
+		{"ErrorCode", http.MethodGet, "https://example.com/things/3", nil, nil, &jsonresp.Error{Code: http.StatusServiceUnavailable, Message: "down"}, ""},
+		{"NotFound", http.MethodGet, "https://example.com/other", nil, nil, &jsonresp.Error{Code: http.StatusNotFound}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := rt.Client().Do(req)
+			if err != nil {
+				t.Fatalf("failed to make request: %v", err)
+			}
+			if got, want := res.Header.Get("X-Test"), tt.wantHeader; got != want {
+				t.Errorf("got header %q, want %q", got, want)
+			}
+
+			var data interface{}
+			pd, err := jsonresp.ReadHTTPResponse(res, &data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(data, tt.wantData) {
+				t.Errorf("got data %v, want %v", data, tt.wantData)
+			}
+			if !reflect.DeepEqual(pd, tt.wantPage) {
+				t.Errorf("got page %v, want %v", pd, tt.wantPage)
+			}
+		})
+	}
+
+	if got, want := len(rt.Requests()), len(tests); got != want {
+		t.Errorf("got %v requests, want %v", got, want)
+	}
+}
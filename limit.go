@@ -0,0 +1,165 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrBodyTooLarge is returned by the read functions when a response exceeds the size established
+// by WithMaxBodySize.
+var ErrBodyTooLarge = errors.New("jsonresp: response body too large")
+
+// ErrMaxDepth is returned by the read functions when a response is nested more deeply than the
+// depth established by WithMaxDepth.
+var ErrMaxDepth = errors.New("jsonresp: response nested too deeply")
+
+// ErrResponseTooLarge is returned by the write functions when the encoded response exceeds the
+// size established by WithMaxResponseSize.
+var ErrResponseTooLarge = errors.New("jsonresp: encoded response too large")
+
+// WithMaxBodySize limits the number of bytes read from a response to n. If the response is
+// larger, the read functions return an error wrapping ErrBodyTooLarge. A value of zero or less
+// means no limit is applied.
+func WithMaxBodySize(n int64) Option {
+	return func(o *options) {
+		o.maxBodySize = n
+	}
+}
+
+// maxBytesReader reads from r, returning ErrBodyTooLarge if more than n bytes are available.
+type maxBytesReader struct {
+	r io.Reader
+	n int64 // bytes remaining, or negative once the limit has been exceeded
+}
+
+func (l *maxBytesReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Read one byte more than remains, so that a response of exactly n bytes is distinguishable
+	// from a larger one.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		n, l.n = int(l.n), -1
+		return n, ErrBodyTooLarge
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// WithMaxDepth limits the nesting depth of arrays and objects in a response, including the
+// response envelope itself, to n. If the response is nested more deeply, the read functions return
+// an error wrapping ErrMaxDepth before decoding the offending value. A value of zero or less means
+// only the limits of the underlying decoder apply.
+func WithMaxDepth(n int) Option {
+	return func(o *options) {
+		o.maxDepth = n
+	}
+}
+
+// depthReader reads JSON from r, returning ErrMaxDepth if arrays and objects are nested more than
+// max deep.
+type depthReader struct {
+	r        io.Reader
+	max      int
+	depth    int
+	inString bool
+	escaped  bool
+	err      error
+}
+
+func (d *depthReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	n, err := d.r.Read(p)
+	for i, c := range p[:n] {
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			if c == '\\' {
+				d.escaped = true
+			} else if c == '"' {
+				d.inString = false
+			}
+		case c == '"':
+			d.inString = true
+		case c == '[' || c == '{':
+			if d.depth++; d.depth > d.max {
+				d.err = ErrMaxDepth
+				return i, d.err
+			}
+		case c == ']' || c == '}':
+			d.depth--
+		}
+	}
+	return n, err
+}
+
+// WithMaxResponseSize limits the size of the encoded response written by the write functions to
+// n bytes, before any compression, as a backstop against inadvertently encoding very large
+// values. If the response is larger, a 500 status code and JSON error is written in its place,
+// and an error wrapping ErrResponseTooLarge is returned. Responses written with WithStream are
+// truncated once the limit is reached, as their status code has already been written, so the
+// client receives an invalid response. A value of zero or less means no limit is applied.
+func WithMaxResponseSize(n int64) Option {
+	return func(o *options) {
+		o.maxResponseSize = n
+	}
+}
+
+// checkResponseSize returns an error wrapping ErrResponseTooLarge if an encoded response of n
+// bytes exceeds the size established by WithMaxResponseSize.
+func (o *options) checkResponseSize(n int) error {
+	if o.maxResponseSize > 0 && int64(n) > o.maxResponseSize {
+		return fmt.Errorf("%w: %v bytes exceeds limit of %v", ErrResponseTooLarge, n, o.maxResponseSize)
+	}
+	return nil
+}
+
+// writeTooLarge writes a 500 status code and JSON error to w in place of a response that failed
+// checkResponseSize with err, and returns err.
+func writeTooLarge(w http.ResponseWriter, err error, o *options) error {
+	eo := *o
+	eo.maxResponseSize = 0
+	if werr := writeError(w, NewError("response too large", http.StatusInternalServerError), err, &eo); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// maxBytesWriter writes to w, returning ErrResponseTooLarge once more than n bytes are written.
+type maxBytesWriter struct {
+	w io.Writer
+	n int64 // bytes remaining
+}
+
+func (l *maxBytesWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		n, err := l.w.Write(p[:l.n])
+		l.n -= int64(n)
+		if err != nil {
+			return n, err
+		}
+		return n, ErrResponseTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestMaxBytesReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		n       int64
+		wantErr error
+	}{
+		{"Under", "blah", 5, nil},
+		{"Exact", "blah", 4, nil},
+		{"Over", "blah", 3, ErrBodyTooLarge},
+		{"Zero", "blah", 0, ErrBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &maxBytesReader{r: iotest.OneByteReader(strings.NewReader(tt.s)), n: tt.n}
+
+			b, err := io.ReadAll(r)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+			want := tt.s
+			if int64(len(want)) > tt.n {
+				want = want[:tt.n]
+			}
+			if got := string(b); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	const body = `{"data":"blah"}`
+
+	tests := []struct {
+		name    string
+		n       int64
+		wantErr bool
+	}{
+		{"NoLimit", 0, false},
+		{"Exact", int64(len(body)), false},
+		{"Under", int64(len(body)) - 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithMaxBodySize(tt.n)}
+
+			var s string
+			err := ReadResponse(strings.NewReader(body), &s, opts...)
+			if got, want := errors.Is(err, ErrBodyTooLarge), tt.wantErr; got != want {
+				t.Errorf("ReadResponse: got error %v, want ErrBodyTooLarge %v", err, want)
+			}
+
+			_, err = DecodeResponse(strings.NewReader(body), opts...)
+			if got, want := errors.Is(err, ErrBodyTooLarge), tt.wantErr; got != want {
+				t.Errorf("DecodeResponse: got error %v, want ErrBodyTooLarge %v", err, want)
+			}
+
+			err = ReadError(strings.NewReader(body), opts...)
+			if got, want := errors.Is(err, ErrBodyTooLarge), tt.wantErr; got != want {
+				t.Errorf("ReadError: got error %v, want ErrBodyTooLarge %v", err, want)
+			}
+		})
+	}
+}
+
+func TestWithMaxBodySizeCodec(t *testing.T) {
+	var s string
+	err := ReadResponse(strings.NewReader(`{"data":"blah"}`), &s, WithCodec(&testCodec{}), WithMaxBodySize(4))
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("got error %v, want ErrBodyTooLarge", err)
+	}
+}
+
+func TestDepthReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		max     int
+		wantErr error
+	}{
+		{"Flat", `{"data":1}`, 1, nil},
+		{"Nested", `{"data":[{}]}`, 3, nil},
+		{"TooDeep", `{"data":[{}]}`, 2, ErrMaxDepth},
+		{"Siblings", `[[],[],[]]`, 2, nil},
+		{"String", `{"data":"[[[{{{"}`, 1, nil},
+		{"EscapedQuote", `{"data":"\"[["}`, 1, nil},
+		{"EscapedBackslash", `{"data":"\\"}`, 1, nil},
+		{"EscapedBackslashThenArray", `{"data":["\\",[]]}`, 2, ErrMaxDepth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &depthReader{r: iotest.HalfReader(strings.NewReader(tt.s)), max: tt.max}
+			if _, err := io.ReadAll(r); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithMaxDepth(t *testing.T) {
+	body := `{"data":` + strings.Repeat("[", 10) + strings.Repeat("]", 10) + `}`
+
+	tests := []struct {
+		name    string
+		n       int
+		wantErr bool
+	}{
+		{"NoLimit", 0, false},
+		{"Exact", 11, false},
+		{"Under", 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Option{WithMaxDepth(tt.n)}
+
+			var v interface{}
+			err := ReadResponse(strings.NewReader(body), &v, opts...)
+			if got, want := errors.Is(err, ErrMaxDepth), tt.wantErr; got != want {
+				t.Errorf("ReadResponse: got error %v, want ErrMaxDepth %v", err, want)
+			}
+
+			err = ReadError(strings.NewReader(body), opts...)
+			if got, want := errors.Is(err, ErrMaxDepth), tt.wantErr; got != want {
+				t.Errorf("ReadError: got error %v, want ErrMaxDepth %v", err, want)
+			}
+		})
+	}
+}
+
+func TestWithMaxResponseSize(t *testing.T) {
+	const tooLarge = `{"error":{"code":500,"message":"response too large"}}`
+
+	tests := []struct {
+		name     string
+		n        int64
+		opts     []Option
+		wantCode int
+		wantBody string
+		wantErr  error
+	}{
+		{"NoLimit", 0, nil, http.StatusOK, `{"data":"blah"}`, nil},
+		{"Exact", 15, nil, http.StatusOK, `{"data":"blah"}`, nil},
+		{"Over", 14, nil, http.StatusInternalServerError, tooLarge, ErrResponseTooLarge},
+		{"OverErrorToo", 1, nil, http.StatusInternalServerError, tooLarge, ErrResponseTooLarge},
+		{"Encoder", 14, []Option{WithEncoder(json.Marshal), WithStream()}, http.StatusInternalServerError, tooLarge, ErrResponseTooLarge},
+		{"Stream", 10, []Option{WithStream()}, http.StatusOK, `{"data":"b`, ErrResponseTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			err := WriteResponse(rr, "blah", http.StatusOK, append([]Option{WithMaxResponseSize(tt.n)}, tt.opts...)...)
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodeResponseMaxResponseSize(t *testing.T) {
+	var buf bytes.Buffer
+	err := EncodeResponse(&buf, Response{Data: "blah"}, WithMaxResponseSize(4))
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("got error %v, want %v", err, ErrResponseTooLarge)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %q written, want none", buf.String())
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import "net/http"
+
+const (
+	// LinkSelf is the relation of a link to the resource itself.
+	LinkSelf = "self"
+
+	// LinkRelated is the relation of a link to a related resource.
+	LinkRelated = "related"
+)
+
+// Link describes a related resource or an action that may be performed on a resource.
+type Link struct {
+	// Href is the URL of the link target.
+	Href string `json:"href"`
+
+	// Method is the HTTP method used to follow the link. If empty, GET is implied.
+	Method string `json:"method,omitempty"`
+
+	// Title is a human-readable description of the link.
+	Title string `json:"title,omitempty"`
+}
+
+// WithLink adds l to the links of the response under the link relation rel, replacing any existing
+// link with the same relation. It may be used more than once to add multiple links.
+func WithLink(rel string, l Link) Option {
+	return func(o *options) {
+		if o.links == nil {
+			o.links = make(map[string]Link)
+		}
+		o.links[rel] = l
+	}
+}
+
+// WithSelfLink adds a link to the resource itself, located at href, to the response.
+func WithSelfLink(href string) Option {
+	return WithLink(LinkSelf, Link{Href: href})
+}
+
+// WithRequestLink adds a link to the resource itself to the response, using the path and query of
+// the request URL of r.
+func WithRequestLink(r *http.Request) Option {
+	return WithSelfLink(r.URL.RequestURI())
+}
+
+// WithActionLink adds a link under the relation rel to the response, describing an action
+// performed by sending a request with the supplied method to href.
+func WithActionLink(rel, method, href string) Option {
+	return WithLink(rel, Link{Href: href, Method: method})
+}
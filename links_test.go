@@ -0,0 +1,83 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWithLink(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "https://example.com/v1/things/1?expand=true", nil)
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]Link
+	}{
+		{"None", nil, nil},
+		{"Self", []Option{WithSelfLink("/v1/things/1")}, map[string]Link{
+			LinkSelf: {Href: "/v1/things/1"},
+		}},
+		{"Request", []Option{WithRequestLink(r)}, map[string]Link{
+			LinkSelf: {Href: "/v1/things/1?expand=true"},
+		}},
+		{"Action", []Option{WithActionLink("delete", http.MethodDelete, "/v1/things/1")}, map[string]Link{
+			"delete": {Href: "/v1/things/1", Method: http.MethodDelete},
+		}},
+		{"Multiple", []Option{
+			WithSelfLink("/v1/things/1"),
+			WithLink(LinkRelated, Link{Href: "/v1/owners/2", Title: "Owner"}),
+		}, map[string]Link{
+			LinkSelf:    {Href: "/v1/things/1"},
+			LinkRelated: {Href: "/v1/owners/2", Title: "Owner"},
+		}},
+		{"Replace", []Option{WithSelfLink("/a"), WithSelfLink("/b")}, map[string]Link{
+			LinkSelf: {Href: "/b"},
+		}},
+		{"Stream", []Option{WithStream(), WithSelfLink("/v1/things/1")}, map[string]Link{
+			LinkSelf: {Href: "/v1/things/1"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, "blah", http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			var jr Response
+			var s string
+			if err := ReadResponse(rr.Body, &s, WithEnvelope(&jr)); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := s, "blah"; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+			if got, want := jr.Links, tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got links %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLinkJSON(t *testing.T) {
+	rr := httptest.NewRecorder()
+	opts := []Option{
+		WithSelfLink("/v1/things/1"),
+		WithActionLink("delete", http.MethodDelete, "/v1/things/1"),
+	}
+	if err := WriteResponse(rr, nil, http.StatusOK, opts...); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	want := `{"links":{"delete":{"href":"/v1/things/1","method":"DELETE"},"self":{"href":"/v1/things/1"}}}`
+	if got := rr.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
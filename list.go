@@ -0,0 +1,94 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"io"
+	"net/http"
+)
+
+// List is a page of items of type T together with its paging information, for the common case of
+// a response whose data is an array. It is written by WriteList, and read by ReadList and
+// ReadHTTPList. A List is encoded by encoding/json as a response envelope, in the same way as by
+// MarshalResponse.
+type List[T any] struct {
+	Items []T
+	PageDetails
+}
+
+// page returns the paging information of l, or nil if it is empty.
+func (l *List[T]) page() *PageDetails {
+	pd := &l.PageDetails
+	if pd.Prev == "" && pd.Next == "" && pd.TotalSize == 0 && !pd.ZeroTotal && pd.Sort == "" {
+		return nil
+	}
+	return pd
+}
+
+// items returns the items of l, which are not nil, so that an empty list is encoded as an empty
+// array.
+func (l *List[T]) items() []T {
+	if l.Items == nil {
+		return []T{}
+	}
+	return l.Items
+}
+
+// set sets the paging information of l to pd, which may be nil.
+func (l *List[T]) set(pd *PageDetails) {
+	l.PageDetails = PageDetails{}
+	if pd != nil {
+		l.PageDetails = *pd
+	}
+}
+
+// MarshalJSON encodes l as a response envelope.
+func (l List[T]) MarshalJSON() ([]byte, error) {
+	return MarshalResponse(l.items(), l.page())
+}
+
+// UnmarshalJSON decodes l from a response envelope.
+func (l *List[T]) UnmarshalJSON(b []byte) error {
+	var items []T
+	pd, err := UnmarshalResponse(b, &items)
+	if err != nil {
+		return err
+	}
+	l.Items = items
+	l.set(pd)
+	return nil
+}
+
+// WriteList writes a status code and JSON response containing the items of l to w, in the same
+// way as WriteResponsePage. The paging information of l is omitted if it is empty, and nil items
+// are written as an empty array.
+func WriteList[T any](w http.ResponseWriter, l List[T], code int, opts ...Option) error {
+	return WriteResponsePage(w, l.items(), l.page(), code, opts...)
+}
+
+// ReadList reads a paged JSON response from r, in the same way as ReadResponsePage, and returns
+// its items and paging information.
+func ReadList[T any](r io.Reader, opts ...Option) (List[T], error) {
+	var l List[T]
+	pd, err := ReadResponsePage(r, &l.Items, opts...)
+	if err != nil {
+		return List[T]{}, err
+	}
+	l.set(pd)
+	return l, nil
+}
+
+// ReadHTTPList reads the paged response res, in the same way as ReadHTTPResponse, and returns its
+// items and paging information.
+func ReadHTTPList[T any](res *http.Response, opts ...Option) (List[T], error) {
+	var l List[T]
+	pd, err := ReadHTTPResponse(res, &l.Items, opts...)
+	if err != nil {
+		return List[T]{}, err
+	}
+	l.set(pd)
+	return l, nil
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type listItem struct {
+	Name string `json:"name"`
+}
+
+func TestWriteList(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     List[listItem]
+		want     string
+		wantList List[listItem]
+	}{
+		{"Empty", List[listItem]{}, `{"data":[]}`, List[listItem]{Items: []listItem{}}},
+		{"Items", List[listItem]{Items: []listItem{{"a"}, {"b"}}}, `{"data":[{"name":"a"},{"name":"b"}]}`, List[listItem]{Items: []listItem{{"a"}, {"b"}}}},
+		{"Page",
+			List[listItem]{Items: []listItem{{"a"}}, PageDetails: PageDetails{Next: "n", TotalSize: 2}},
+			`{"data":[{"name":"a"}],"page":{"next":"n","totalSize":2}}`,
+			List[listItem]{Items: []listItem{{"a"}}, PageDetails: PageDetails{Next: "n", TotalSize: 2}},
+		},
+		{"ZeroTotal",
+			List[listItem]{PageDetails: PageDetails{ZeroTotal: true}},
+			`{"data":[],"page":{"totalSize":0}}`,
+			List[listItem]{Items: []listItem{}, PageDetails: PageDetails{ZeroTotal: true}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteList(rr, tt.list, http.StatusOK); err != nil {
+				t.Fatalf("failed to write list: %v", err)
+			}
+			if got, want := rr.Body.String(), tt.want; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+
+			l, err := ReadList[listItem](rr.Body)
+			if err != nil {
+				t.Fatalf("failed to read list: %v", err)
+			}
+			if got, want := l, tt.wantList; !reflect.DeepEqual(got, want) {
+				t.Errorf("got list %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadListError(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{"Error", `{"error":{"code":404,"message":"blah"}}`, &Error{Code: http.StatusNotFound, Message: "blah"}},
+		{"NotArray", `{"data":{"name":"a"}}`, nil},
+		{"Invalid", `{`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ReadList[listItem](strings.NewReader(tt.body))
+			if err == nil {
+				t.Fatal("unexpected success")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(l, List[listItem]{}) {
+				t.Errorf("got list %+v, want zero", l)
+			}
+		})
+	}
+}
+
+func TestReadHTTPList(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"data":[{"name":"a"}],"page":{"prev":"p"}}`)),
+	}
+
+	l, err := ReadHTTPList[listItem](res)
+	if err != nil {
+		t.Fatalf("failed to read list: %v", err)
+	}
+	if got, want := l, (List[listItem]{Items: []listItem{{"a"}}, PageDetails: PageDetails{Prev: "p"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got list %+v, want %+v", got, want)
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	l := List[listItem]{Items: []listItem{{"a"}}, PageDetails: PageDetails{Next: "n"}}
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if got, want := string(b), `{"data":[{"name":"a"}],"page":{"next":"n"}}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var got List[listItem]
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(got, l) {
+		t.Errorf("got list %+v, want %+v", got, l)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Translator localizes error messages.
+type Translator interface {
+	// Translate returns the message identified by key, formatted with args, in the most preferred
+	// of the supplied languages it supports. Languages are BCP 47 tags ordered by descending
+	// preference. If the message cannot be translated, ok is false.
+	Translate(languages []string, key string, args []interface{}) (message string, ok bool)
+}
+
+var (
+	translatorMu sync.RWMutex
+	translator   Translator
+)
+
+// SetTranslator sets the Translator used by WriteLocalizedErr. A nil Translator disables
+// localization.
+func SetTranslator(t Translator) {
+	translatorMu.Lock()
+	defer translatorMu.Unlock()
+	translator = t
+}
+
+// acceptLanguages returns the language tags in the Accept-Language header value v, ordered by
+// descending quality. Tags with a quality of zero, and the wildcard tag, are omitted.
+func acceptLanguages(v string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+
+	var langs []lang
+	for _, s := range strings.Split(v, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+		if tag = strings.TrimSpace(tag); tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q > 0 {
+			langs = append(langs, lang{tag, q})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+
+	tags := make([]string, 0, len(langs))
+	for _, l := range langs {
+		tags = append(tags, l.tag)
+	}
+	return tags
+}
+
+// localize returns je with its message translated into the language preferred by r, if je has a
+// message key and a translation is available.
+func localize(r *http.Request, je *Error) *Error {
+	if je.MessageKey == "" {
+		return je
+	}
+
+	translatorMu.RLock()
+	t := translator
+	translatorMu.RUnlock()
+
+	if t == nil {
+		return je
+	}
+
+	message, ok := t.Translate(acceptLanguages(r.Header.Get("Accept-Language")), je.MessageKey, je.MessageArgs)
+	if !ok {
+		return je
+	}
+
+	c := *je
+	c.Message = message
+	return &c
+}
+
+// WriteLocalizedErr writes a status code and JSON response describing err to w, in the same way
+// as WriteErr. If the resulting Error has a message key, the Translator established by
+// SetTranslator is used to localize the message according to the Accept-Language header of r.
+// If no translation is available, the message is left unchanged.
+func WriteLocalizedErr(w http.ResponseWriter, r *http.Request, err error, opts ...Option) error {
+	opts = append([]Option{WithRequest(r), WithRequestID(r)}, opts...)
+	if err == nil {
+		return writeError(w, NewError("", http.StatusInternalServerError), nil, newOptions(opts))
+	}
+	return writeError(w, localize(r, errorFor(err)), err, newOptions(opts))
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type testTranslator map[string]map[string]string
+
+func (tt testTranslator) Translate(languages []string, key string, args []interface{}) (string, bool) {
+	for _, l := range languages {
+		if format, ok := tt[l][key]; ok {
+			return fmt.Sprintf(format, args...), true
+		}
+	}
+	return "", false
+}
+
+func TestAcceptLanguages(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want []string
+	}{
+		{"Empty", "", []string{}},
+		{"Single", "fr", []string{"fr"}},
+		{"Ordered", "fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", []string{"fr-CH", "fr", "en", "de"}},
+		{"Unordered", "en;q=0.5, de", []string{"de", "en"}},
+		{"Zero", "en;q=0, de", []string{"de"}},
+		{"InvalidQuality", "en;q=x, de", []string{"de"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := acceptLanguages(tt.v), tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteLocalizedErr(t *testing.T) {
+	SetTranslator(testTranslator{
+		"de": {"quota": "Kontingent von %v überschritten"},
+		"fr": {"quota": "quota de %v dépassé"},
+	})
+	defer SetTranslator(nil)
+
+	quotaErr := &Error{
+		Code:        http.StatusTooManyRequests,
+		Message:     "quota of 10 exceeded",
+		MessageKey:  "quota",
+		MessageArgs: []interface{}{10},
+	}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		err            error
+		wantMessage    string
+	}{
+		{"NoKey", "de", NewError("blah", http.StatusNotFound), "blah"},
+		{"NoLanguage", "", quotaErr, "quota of 10 exceeded"},
+		{"Unsupported", "es", quotaErr, "quota of 10 exceeded"},
+		{"German", "de", quotaErr, "Kontingent von 10 überschritten"},
+		{"Preferred", "es, fr;q=0.8, de;q=0.5", quotaErr, "quota de 10 dépassé"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rr := httptest.NewRecorder()
+
+			if err := WriteLocalizedErr(rr, r, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			var je *Error
+			if !errors.As(ReadError(rr.Body), &je) {
+				t.Fatalf("failed to read error")
+			}
+			if got, want := je.Message, tt.wantMessage; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+		})
+	}
+}
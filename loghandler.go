@@ -0,0 +1,143 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build go1.21
+
+package jsonresp
+
+import (
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultMaxLogBodySize is the maximum number of bytes of a response body logged by LogHandler, if
+// LogHandler.MaxBodySize is zero.
+const defaultMaxLogBodySize = 1024
+
+// LogHandler is an http.Handler that logs each request served by Handler once its response has
+// been written. The method and path of the request, the status code, latency and size of the
+// response, and the first bytes of a JSON response body are included as attributes. Requests are
+// logged at the info level, or the error level if the status code is 5xx. LogHandler requires Go
+// 1.21 or later.
+//
+// The body is captured as it is written, so the response is not buffered again. For a response
+// written by this package, the body logged is the body encoded, so fields removed by WithRedaction
+// and secrets replaced with Redacted are not logged, and a compressed response is logged before
+// compression. Other compressed responses are logged without a body.
+type LogHandler struct {
+	// Handler serves requests.
+	Handler http.Handler
+
+	// Logger is the logger to which requests are logged. If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// MaxBodySize is the maximum number of bytes of the response body logged. If zero, 1024 bytes
+	// are logged, and if negative, the body is not logged.
+	MaxBodySize int
+}
+
+// ServeHTTP serves the request r.
+func (lh *LogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	max := lh.MaxBodySize
+	if max == 0 {
+		max = defaultMaxLogBodySize
+	}
+	lw := &logWriter{ResponseWriter: w, max: max}
+	lh.Handler.ServeHTTP(lw, r)
+
+	code := lw.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	level := slog.LevelInfo
+	if code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", code),
+		slog.Duration("latency", time.Since(start)),
+		slog.Int("size", lw.size),
+	}
+	if lw.body != nil {
+		if body := lw.body.String(); body != "" {
+			attrs = append(attrs, slog.String("body", body))
+		}
+	}
+
+	l := lh.Logger
+	if l == nil {
+		l = slog.Default()
+	}
+	l.LogAttrs(r.Context(), level, "http request", attrs...)
+}
+
+// isJSONType reports whether ct is a JSON media type, such as application/json or
+// application/problem+json.
+func isJSONType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// logWriter is an http.ResponseWriter that records the status code and size of the response
+// written to it, and retains the first bytes of a JSON response body.
+type logWriter struct {
+	http.ResponseWriter
+	code int
+	size int
+	max  int      // negative if not retaining the body
+	body *snippet // nil if not retaining the body
+	raw  bool     // whether the body is retained as it is written
+}
+
+// captureBody retains the first bytes of body, the response body before compression.
+func (lw *logWriter) captureBody(body []byte) {
+	if lw.max < 0 || lw.body != nil {
+		return
+	}
+	lw.body = &snippet{max: lw.max}
+	_, _ = lw.body.Write(body)
+}
+
+func (lw *logWriter) WriteHeader(code int) {
+	if lw.code == 0 && (code < 100 || code >= 200) {
+		lw.code = code
+		h := lw.Header()
+		if lw.max >= 0 && lw.body == nil && h.Get("Content-Encoding") == "" && isJSONType(h.Get("Content-Type")) {
+			lw.body = &snippet{max: lw.max}
+			lw.raw = true
+		}
+	}
+	lw.ResponseWriter.WriteHeader(code)
+}
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	if lw.code == 0 {
+		lw.WriteHeader(http.StatusOK)
+	}
+	n, err := lw.ResponseWriter.Write(p)
+	lw.size += n
+	if lw.raw {
+		_, _ = lw.body.Write(p[:n])
+	}
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter, if it supports flushing.
+func (lw *logWriter) Flush() {
+	if lw.code == 0 {
+		lw.WriteHeader(http.StatusOK)
+	}
+	_ = flushResponse(lw.ResponseWriter)
+}
+
+func (lw *logWriter) Unwrap() http.ResponseWriter { return lw.ResponseWriter }
@@ -0,0 +1,123 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build go1.21
+
+package jsonresp
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogHandler(t *testing.T) {
+	type account struct {
+		Name     string `json:"name"`
+		Password string `json:"password" jsonresp:"secret"`
+	}
+	large := strings.Repeat("a", 2048)
+
+	tests := []struct {
+		name   string
+		max    int
+		h      http.HandlerFunc
+		header http.Header
+		want   string
+	}{
+		{
+			name: "Response",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteResponse(w, "blah", http.StatusOK)
+			},
+			want: `level=INFO msg="http request" method=GET path=/things status=200 size=15 body="{\"data\":\"blah\"}"`,
+		},
+		{
+			name: "Redacted",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteResponse(w, account{"bob", "hunter2"}, http.StatusOK)
+			},
+			want: `level=INFO msg="http request" method=GET path=/things status=200 size=47 body="{\"data\":{\"name\":\"bob\",\"password\":\"[REDACTED]\"}}"`,
+		},
+		{
+			name: "ServerError",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteError(w, "blah", http.StatusInternalServerError)
+			},
+			want: `level=ERROR msg="http request" method=GET path=/things status=500 size=39 body="{\"error\":{\"code\":500,\"message\":\"blah\"}}"`,
+		},
+		{
+			name: "Truncated",
+			max:  10,
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteResponse(w, "blah", http.StatusOK)
+			},
+			want: `level=INFO msg="http request" method=GET path=/things status=200 size=15 body="{\"data\":\"b..."`,
+		},
+		{
+			name: "NoBody",
+			max:  -1,
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteResponse(w, "blah", http.StatusOK)
+			},
+			want: `level=INFO msg="http request" method=GET path=/things status=200 size=15`,
+		},
+		{
+			name: "Compressed",
+			max:  10,
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteResponse(w, large, http.StatusOK, WithCompression(r))
+			},
+			header: http.Header{"Accept-Encoding": {"gzip"}},
+			want:   `body="{\"data\":\"a..."`,
+		},
+		{
+			name: "NotJSON",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "blah", http.StatusNotFound)
+			},
+			want: `level=INFO msg="http request" method=GET path=/things status=404 size=5`,
+		},
+		{
+			name: "Implicit",
+			h:    func(w http.ResponseWriter, r *http.Request) {},
+			want: `level=INFO msg="http request" method=GET path=/things status=200 size=0`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey || a.Key == "latency" {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/things", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			lh := &LogHandler{Handler: tt.h, Logger: l, MaxBodySize: tt.max}
+			lh.ServeHTTP(httptest.NewRecorder(), r)
+
+			got := strings.TrimSpace(buf.String())
+			if tt.header == nil {
+				if got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			} else if !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
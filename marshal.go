@@ -0,0 +1,41 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import "bytes"
+
+// MarshalResponse returns the JSON encoding of a response containing data and pd, in the same way
+// as EncodeResponse. It is a convenience for contexts such as message queues, caches and test
+// fixtures in which an io.Writer is unnecessary.
+func MarshalResponse(data interface{}, pd *PageDetails, opts ...Option) ([]byte, error) {
+	return marshalResponse(Response{Data: data, Page: pd}, newOptions(opts))
+}
+
+// MarshalError returns the JSON encoding of a response containing the supplied error message and
+// status code, as written by WriteError. As with WriteError, the error is reported to the hooks
+// established by WithErrorLog, SetProduction and SetDebug.
+func MarshalError(message string, code int, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	return marshalResponse(Response{Error: o.prepareError(NewError(message, code), nil, 0)}, o)
+}
+
+// marshalResponse returns the JSON encoding of jr, as written by EncodeResponse with o.
+func marshalResponse(jr Response, o *options) ([]byte, error) {
+	es := newEncodeState()
+	defer es.release()
+
+	if err := es.encodeEnvelope(jr, o); err != nil {
+		return nil, err
+	}
+	// The buffer of es is reused once released.
+	return append([]byte(nil), es.Bytes()...), nil
+}
+
+// UnmarshalResponse parses the JSON response b, unmarshals the data it contains into v, and
+// returns its paging information, in the same way as ReadResponsePage.
+func UnmarshalResponse(b []byte, v interface{}, opts ...Option) (*PageDetails, error) {
+	return ReadResponsePage(bytes.NewReader(b), v, opts...)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    interface{}
+		pd      *PageDetails
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"Data", "blah", nil, nil, `{"data":"blah"}`, false},
+		{"Page", []int{1, 2}, &PageDetails{Next: "2", TotalSize: 4}, nil, `{"data":[1,2],"page":{"next":"2","totalSize":4}}`, false},
+		{"Meta", "blah", nil, []Option{WithMeta("version", 1)}, `{"data":"blah","meta":{"version":1}}`, false},
+		{"Indent", "blah", nil, []Option{WithIndent("", " ")}, "{\n \"data\": \"blah\"\n}", false},
+		{"TooLarge", "blah", nil, []Option{WithMaxResponseSize(4)}, "", true},
+		{"Invalid", make(chan int), nil, nil, "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := MarshalResponse(tt.data, tt.pd, tt.opts...)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v, want error %v", err, want)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	b, err := MarshalError("blah", http.StatusNotFound)
+	if err != nil {
+		t.Fatalf("failed to marshal error: %v", err)
+	}
+	if got, want := string(b), `{"error":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var logged error
+	f := func(_ *http.Request, _ int, err error) { logged = err }
+	if _, err := MarshalError("blah", http.StatusInternalServerError, WithErrorLog(nil, f)); err != nil {
+		t.Fatalf("failed to marshal error: %v", err)
+	}
+	if logged == nil {
+		t.Error("server error not logged")
+	}
+}
+
+func TestUnmarshalResponse(t *testing.T) {
+	b, err := MarshalResponse([]string{"a", "b"}, &PageDetails{Next: "2"})
+	if err != nil {
+		t.Fatalf("failed to marshal response: %v", err)
+	}
+
+	var v []string
+	pd, err := UnmarshalResponse(b, &v)
+	if err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if got, want := v, []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got data %v, want %v", got, want)
+	}
+	if got, want := pd, (&PageDetails{Next: "2"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %v, want %v", got, want)
+	}
+
+	b, err = MarshalError("blah", http.StatusNotFound)
+	if err != nil {
+		t.Fatalf("failed to marshal error: %v", err)
+	}
+	_, err = UnmarshalResponse(b, &v)
+	if want := NewError("blah", http.StatusNotFound); !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
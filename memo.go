@@ -0,0 +1,183 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMemoTTL is the duration for which MemoHandler retains a response, unless overridden by
+// its TTL field.
+const DefaultMemoTTL = time.Second
+
+// MemoHandler is an http.Handler that memoizes the successful responses to GET requests served by
+// Handler, so that identical requests received within TTL are served from memory without invoking
+// Handler. Requests are identical if they have the same URL, and the same values of the request
+// headers named by Headers. Concurrent identical requests that cannot be served from memory are
+// de-duplicated: Handler is invoked once, and its response, whether successful or not, is written
+// to each of them.
+//
+// Headers must name each request header that the response depends on, such as Authorization or
+// Accept. Responses with a Vary header naming a request header are only served to requests with the
+// same value of that header. Responses with a Vary header of "*", or a Cache-Control header
+// containing no-store, are not memoized. If the response contains an ETag header, such as set by
+// WithETag, conditional requests that match it are served with a 304 status code. Requests with
+// other methods are passed to Handler.
+type MemoHandler struct {
+	// Handler serves requests that cannot be served from memory.
+	Handler http.Handler
+
+	// TTL is the duration for which a response is memoized. If zero, DefaultMemoTTL is used.
+	TTL time.Duration
+
+	// Headers names the request headers that distinguish identical requests.
+	Headers []string
+
+	// MaxEntries is the maximum number of memoized responses. If zero, DefaultMaxCachedResponses
+	// is used.
+	MaxEntries int
+
+	once  sync.Once
+	store *MemoryStore
+
+	mu    sync.Mutex
+	calls map[string]*memoCall
+}
+
+// memoCall is an invocation of the Handler of a MemoHandler, shared by identical requests.
+type memoCall struct {
+	done chan struct{}
+	rec  *batchRecorder
+	ok   bool // whether Handler returned, rather than panicking
+}
+
+// detachedContext is a context.Context with the values of its parent that is never done, so that
+// a call shared by identical requests is not abandoned when the request that made it is canceled.
+type detachedContext struct {
+	context.Context //nolint:containedctx
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+// memoKey returns the key with which the response to r is memoized.
+func (mh *MemoHandler) memoKey(r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(cacheKey(r))
+	for _, k := range mh.Headers {
+		sb.WriteByte(0)
+		sb.WriteString(http.CanonicalHeaderKey(k))
+		for _, v := range r.Header.Values(k) {
+			sb.WriteByte(0)
+			sb.WriteString(v)
+		}
+	}
+	return sb.String()
+}
+
+// ServeHTTP serves the request r.
+func (mh *MemoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		mh.Handler.ServeHTTP(w, r)
+		return
+	}
+
+	mh.once.Do(func() {
+		n := mh.MaxEntries
+		if n <= 0 {
+			n = DefaultMaxCachedResponses
+		}
+		mh.store = NewMemoryStore(n)
+		mh.calls = make(map[string]*memoCall)
+	})
+
+	key := mh.memoKey(r)
+	if cr, ok := mh.store.Load(key); ok && cr.matches(r, time.Now()) {
+		writeCachedResponse(w, r, http.StatusOK, cr.Header, cr.Body)
+		return
+	}
+
+	rec, ok := mh.do(key, r)
+	if !ok {
+		// The shared call panicked, so r is served by Handler.
+		mh.Handler.ServeHTTP(w, r)
+		return
+	}
+	writeCachedResponse(w, r, rec.code, rec.header, rec.body.Bytes())
+}
+
+// do serves r with Handler, or waits for the response to an identical request already being
+// served, and returns the recorded response. Successful responses are memoized with key. If
+// Handler panics, the panic is propagated to the caller that invoked it, and false is returned to
+// the callers waiting for its response.
+func (mh *MemoHandler) do(key string, r *http.Request) (*batchRecorder, bool) {
+	mh.mu.Lock()
+	if c, ok := mh.calls[key]; ok {
+		mh.mu.Unlock()
+		<-c.done
+		return c.rec, c.ok
+	}
+	c := &memoCall{done: make(chan struct{}), rec: &batchRecorder{header: make(http.Header)}}
+	mh.calls[key] = c
+	mh.mu.Unlock()
+
+	defer func() {
+		mh.mu.Lock()
+		delete(mh.calls, key)
+		mh.mu.Unlock()
+		close(c.done)
+	}()
+
+	// The response is shared by identical requests, which may differ in their preconditions and
+	// may be canceled independently.
+	r = r.Clone(detachedContext{r.Context()})
+	for _, k := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		r.Header.Del(k)
+	}
+
+	mh.Handler.ServeHTTP(c.rec, r)
+	c.ok = true
+	if c.rec.code == 0 {
+		c.rec.code = http.StatusOK
+	}
+
+	if c.rec.code == http.StatusOK {
+		ttl := mh.TTL
+		if ttl <= 0 {
+			ttl = DefaultMemoTTL
+		}
+		if cr, ok := newCachedResponse(r, c.rec.header, c.rec.body.Bytes(), c.rec.header.Get("ETag"), ttl); ok {
+			mh.store.Store(key, cr)
+		}
+	}
+	return c.rec, true
+}
+
+// writeCachedResponse writes the response to r with status code code, header h and body to w. If
+// the response is successful and r is a conditional request matching its ETag header, a 304 status
+// code is written instead.
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, code int, h http.Header, body []byte) {
+	if etag := h.Get("ETag"); code == http.StatusOK && etag != "" {
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+			writeNotModified(w, h)
+			return
+		}
+	}
+
+	wh := w.Header()
+	for k, v := range h {
+		wh[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(code)
+	_, _ = w.Write(body)
+}
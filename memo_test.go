@@ -0,0 +1,229 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoHandler(t *testing.T) {
+	var calls int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/fail" {
+			_ = WriteError(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		_ = WriteResponse(w, map[string]interface{}{"n": n, "user": r.Header.Get("Authorization")}, http.StatusOK, WithETag())
+	})
+	mh := &MemoHandler{Handler: h, TTL: time.Hour, Headers: []string{"Authorization"}}
+
+	serve := func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		mh.ServeHTTP(rr, r)
+		return rr
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		header    http.Header
+		wantCode  int
+		wantBody  string
+		wantCalls int32
+	}{
+		{"First", http.MethodGet, "/a", nil, http.StatusOK, `{"data":{"n":1,"user":""}}`, 1},
+		{"Memoized", http.MethodGet, "/a", nil, http.StatusOK, `{"data":{"n":1,"user":""}}`, 1},
+		{"Query", http.MethodGet, "/a?x=1", nil, http.StatusOK, `{"data":{"n":2,"user":""}}`, 2},
+		{"Header", http.MethodGet, "/a", http.Header{"Authorization": {"u"}}, http.StatusOK, `{"data":{"n":3,"user":"u"}}`, 3},
+		{"HeaderMemoized", http.MethodGet, "/a", http.Header{"Authorization": {"u"}}, http.StatusOK, `{"data":{"n":3,"user":"u"}}`, 3},
+		{"Conditional", http.MethodGet, "/a", http.Header{"If-None-Match": {entityTag([]byte(`{"data":{"n":1,"user":""}}`), "")}}, http.StatusNotModified, ``, 3},
+		{"OtherMethod", http.MethodPost, "/a", nil, http.StatusOK, `{"data":{"n":4,"user":""}}`, 4},
+		{"Failure", http.MethodGet, "/fail", nil, http.StatusInternalServerError, `{"error":{"code":500,"message":"failed"}}`, 5},
+		{"FailureNotMemoized", http.MethodGet, "/fail", nil, http.StatusInternalServerError, `{"error":{"code":500,"message":"failed"}}`, 6},
+	}
+	for _, tt := range tests {
+		rr := serve(tt.method, tt.path, tt.header)
+		if got, want := rr.Code, tt.wantCode; got != want {
+			t.Errorf("%v: got code %v, want %v", tt.name, got, want)
+		}
+		if got, want := rr.Body.String(), tt.wantBody; got != want {
+			t.Errorf("%v: got body %q, want %q", tt.name, got, want)
+		}
+		if got, want := atomic.LoadInt32(&calls), tt.wantCalls; got != want {
+			t.Errorf("%v: got %v calls, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestMemoHandlerExpiry(t *testing.T) {
+	var calls int
+	mh := &MemoHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			_ = WriteResponse(w, calls, http.StatusOK)
+		}),
+		TTL: time.Millisecond,
+	}
+
+	mh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	time.Sleep(5 * time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	mh.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got, want := rr.Body.String(), `{"data":2}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestMemoHandlerSingleFlight(t *testing.T) {
+	const n = 10
+
+	var calls int32
+	release := make(chan struct{})
+	mh := &MemoHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			// Preconditions of the request are not seen by the handler.
+			_ = WriteResponse(w, "a", http.StatusOK, WithETag(), WithPreconditions(r))
+		}),
+	}
+	tag := entityTag([]byte(`{"data":"a"}`), "")
+
+	var wg sync.WaitGroup
+	codes := make([]int, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if i == 0 {
+				r.Header.Set("If-None-Match", tag)
+			}
+			rr := httptest.NewRecorder()
+			mh.ServeHTTP(rr, r)
+			codes[i] = rr.Code
+		}(i)
+	}
+
+	// Wait for the first request to reach the handler, and give the others time to join it.
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("got %v calls, want 1", got)
+	}
+	for i, code := range codes {
+		want := http.StatusOK
+		if i == 0 {
+			want = http.StatusNotModified
+		}
+		if code != want {
+			t.Errorf("request %v: got code %v, want %v", i, code, want)
+		}
+	}
+}
+
+// serveShared serves a request with ctx as the first call to mh, waits for it to reach the handler,
+// then serves an identical request that joins it, and returns the recorded responses. The handler
+// must wait for release, which is closed once the second request has joined the first.
+func serveShared(t *testing.T, ctx context.Context, mh *MemoHandler, calls *int32, release chan struct{}) (first, second *httptest.ResponseRecorder) {
+	t.Helper()
+
+	first, second = httptest.NewRecorder(), httptest.NewRecorder()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer func() { _ = recover() }()
+		mh.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}()
+	for atomic.LoadInt32(calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		defer wg.Done()
+		mh.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	// Give the second request time to join the first.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return first, second
+}
+
+func TestMemoHandlerPanic(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	mh := &MemoHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				<-release
+				panic("boom")
+			}
+			_ = WriteResponse(w, "a", http.StatusOK)
+		}),
+	}
+
+	_, rr := serveShared(t, context.Background(), mh, &calls, release)
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("got %v calls, want 2", got)
+	}
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Body.String(), `{"data":"a"}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestMemoHandlerCanceled(t *testing.T) {
+	type key struct{}
+
+	var calls int32
+	release := make(chan struct{})
+	mh := &MemoHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			if err := r.Context().Err(); err != nil {
+				_ = WriteErr(w, err)
+				return
+			}
+			_ = WriteResponse(w, r.Context().Value(key{}), http.StatusOK)
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "a"))
+	cancel()
+
+	first, second := serveShared(t, ctx, mh, &calls, release)
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("got %v calls, want 1", got)
+	}
+	for _, rr := range []*httptest.ResponseRecorder{first, second} {
+		if got, want := rr.Body.String(), `{"data":"a"}`; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+	}
+}
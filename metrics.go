@@ -0,0 +1,140 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Metrics records observations of the responses written and read by this package. Implementations
+// must be safe for concurrent use. A Prometheus implementation is provided by the promresp
+// package.
+type Metrics interface {
+	// ObserveResponse is called when a response with status code code is written. The size is the
+	// number of body bytes written, after any compression.
+	ObserveResponse(code int, size int)
+
+	// ObserveDecodeError is called when a response or request body cannot be decoded. The error
+	// is a *DecodeError.
+	ObserveDecodeError(err error)
+}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   Metrics
+)
+
+// SetMetrics sets the Metrics to which observations are reported. A nil Metrics disables
+// reporting.
+func SetMetrics(m Metrics) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = m
+}
+
+// currentMetrics returns the Metrics established by SetMetrics, or nil.
+func currentMetrics() Metrics { //nolint:ireturn
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+// ResponseInfo describes a response written by this package.
+type ResponseInfo struct {
+	// Code is the status code of the response.
+	Code int
+
+	// Size is the number of body bytes written, after any compression.
+	Size int
+
+	// Page is the paging information in the response, if any.
+	Page *PageDetails
+
+	// Error is the error described by the response, if any.
+	Error *Error
+
+	// Duration is the time taken to encode and write the response, measured from the call of the
+	// write function. It is zero unless an observer established by WithResponseObserver, or a
+	// ResponseLog established by ContextWithResponseLog, receives the description.
+	Duration time.Duration
+}
+
+// WithResponseObserver causes f to be called after the response is written, with a description
+// of the response. It may be used more than once to add multiple observers.
+func WithResponseObserver(f func(ResponseInfo)) Option {
+	return func(o *options) {
+		o.responseObservers = append(o.responseObservers, f)
+	}
+}
+
+// observeResponse reports that the response jr, with status code code and a body of size bytes,
+// was written to the Metrics established by SetMetrics, the observers established by o, and the
+// ResponseLog carried by its context, if any.
+func (o *options) observeResponse(code, size int, jr Response) {
+	if m := currentMetrics(); m != nil {
+		m.ObserveResponse(code, size)
+	}
+
+	l := o.responseLog()
+	if len(o.responseObservers) == 0 && l == nil {
+		return
+	}
+	info := ResponseInfo{Code: code, Size: size, Page: jr.Page, Error: jr.Error}
+	if !o.start.IsZero() {
+		info.Duration = time.Since(o.start)
+	}
+	for _, f := range o.responseObservers {
+		f(info)
+	}
+	if l != nil {
+		l.set(info)
+	}
+}
+
+// observeDecodeError reports that a body could not be decoded.
+func observeDecodeError(err error) {
+	if m := currentMetrics(); m != nil {
+		m.ObserveDecodeError(err)
+	}
+}
+
+// countingWriter is an io.Writer that counts the bytes written to it.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
+
+// bodyCapturer is implemented by http.ResponseWriters that retain a copy of the response body,
+// such as that of LogHandler.
+type bodyCapturer interface {
+	// captureBody is called with the response body before it is compressed.
+	captureBody(body []byte)
+}
+
+// captureBody passes the response body to w, or the first writer it wraps, that implements
+// bodyCapturer, if any.
+func captureBody(w http.ResponseWriter, body []byte) {
+	for {
+		if bc, ok := w.(bodyCapturer); ok {
+			bc.captureBody(body)
+			return
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
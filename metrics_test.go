@@ -0,0 +1,179 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type observation struct {
+	code int
+	size int
+}
+
+type testMetrics struct {
+	mu           sync.Mutex
+	responses    []observation
+	decodeErrors []error
+}
+
+func (m *testMetrics) ObserveResponse(code, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses = append(m.responses, observation{code, size})
+}
+
+func (m *testMetrics) ObserveDecodeError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decodeErrors = append(m.decodeErrors, err)
+}
+
+// withMetrics reports observations to a testMetrics for the duration of the test.
+func withMetrics(t *testing.T) *testMetrics {
+	t.Helper()
+
+	m := &testMetrics{}
+	SetMetrics(m)
+	t.Cleanup(func() { SetMetrics(nil) })
+	return m
+}
+
+func TestMetricsResponses(t *testing.T) {
+	r := httptest.NewRequest(http.MethodHead, "/", nil)
+
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter) error
+		want  []observation
+	}{
+		{"Response", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusOK)
+		}, []observation{{http.StatusOK, len(`{"data":"blah"}`)}}},
+		{"Error", func(w http.ResponseWriter) error {
+			return WriteError(w, "blah", http.StatusNotFound)
+		}, []observation{{http.StatusNotFound, len(`{"error":{"code":404,"message":"blah"}}`)}}},
+		{"Stream", func(w http.ResponseWriter) error {
+			return WriteResponse(w, []int{1, 2}, http.StatusOK, WithStream())
+		}, []observation{{http.StatusOK, len(`{"data":[1,2]}`)}}},
+		{"StreamCodec", func(w http.ResponseWriter) error {
+			return WriteResponse(w, []int{1, 2}, http.StatusOK, WithStream(), WithCodec(&testCodec{}))
+		}, []observation{{http.StatusOK, len(`{"data":[1,2]}`)}}},
+		{"NoContent", func(w http.ResponseWriter) error {
+			WriteNoContent(w)
+			return nil
+		}, []observation{{http.StatusNoContent, 0}}},
+		{"Head", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusOK, WithHead(r))
+		}, []observation{{http.StatusOK, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := withMetrics(t)
+
+			if err := tt.write(httptest.NewRecorder()); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := m.responses, tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMetricsDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		read func() error
+		want int
+	}{
+		{"Valid", func() error {
+			return ReadResponse(strings.NewReader(`{"data":"blah"}`), nil)
+		}, 0},
+		{"InvalidEnvelope", func() error {
+			return ReadResponse(strings.NewReader(`{`), nil)
+		}, 1},
+		{"InvalidData", func() error {
+			var i int
+			return ReadResponse(strings.NewReader(`{"data":"blah"}`), &i)
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := withMetrics(t)
+
+			err := tt.read()
+			if got, want := len(m.decodeErrors), tt.want; got != want {
+				t.Fatalf("got %v decode errors, want %v", got, want)
+			}
+			for _, de := range m.decodeErrors {
+				if !errors.Is(err, de) {
+					t.Errorf("got error %v, want %v", err, de)
+				}
+			}
+		})
+	}
+}
+
+func TestWithResponseObserver(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter, opts ...Option) error
+		want  ResponseInfo
+	}{
+		{"Response", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteResponse(w, "blah", http.StatusOK, opts...)
+		}, ResponseInfo{Code: http.StatusOK, Size: len(`{"data":"blah"}`)}},
+		{"Error", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteError(w, "blah", http.StatusNotFound, opts...)
+		}, ResponseInfo{Code: http.StatusNotFound, Size: len(`{"error":{"code":404,"message":"blah"}}`), Error: NewError("blah", http.StatusNotFound)}},
+		{"StreamError", func(w http.ResponseWriter, opts ...Option) error {
+			return WriteError(w, "blah", http.StatusNotFound, append(opts, WithStream())...)
+		}, ResponseInfo{Code: http.StatusNotFound, Size: len(`{"error":{"code":404,"message":"blah"}}`), Error: NewError("blah", http.StatusNotFound)}},
+		{"NoContent", func(w http.ResponseWriter, opts ...Option) error {
+			WriteNoContent(w, opts...)
+			return nil
+		}, ResponseInfo{Code: http.StatusNoContent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []ResponseInfo
+			f := func(ri ResponseInfo) {
+				if ri.Duration < 0 {
+					t.Errorf("got negative duration %v", ri.Duration)
+				}
+				ri.Duration = 0
+				got = append(got, ri)
+			}
+
+			if err := tt.write(httptest.NewRecorder(), WithResponseObserver(f), WithResponseObserver(f)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if want := []ResponseInfo{tt.want, tt.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithResponseObserverPage(t *testing.T) {
+	var got ResponseInfo
+	f := func(ri ResponseInfo) { got = ri }
+
+	pd := &PageDetails{Next: "n", TotalSize: 3}
+	if err := WriteResponsePage(httptest.NewRecorder(), []int{1}, pd, http.StatusOK, WithResponseObserver(f)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got.Page != pd {
+		t.Errorf("got page %v, want %v", got.Page, pd)
+	}
+}
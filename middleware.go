@@ -0,0 +1,90 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import "net/http"
+
+// HandlerFunc is an HTTP handler that returns an error, rather than writing an error response
+// itself. This allows handlers and middleware to return early when a request cannot be served,
+// such as when it fails authentication or validation.
+//
+// As an http.Handler, a HandlerFunc writes an error it returns as by WriteErr, so that the status
+// code and message are determined by the mappings established by Register and RegisterType. If
+// the response has already been written, the error cannot be, and is discarded.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls f(w, r), writing the error it returns, if any.
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveFunc(w, r, f, nil)
+}
+
+// Middleware wraps a HandlerFunc, such as to authenticate requests before they are passed to
+// next. Middleware that returns an error causes it to be written in the same way as if returned by
+// the handler.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Adapt returns a Middleware that applies the conventional middleware m, such that errors returned
+// by the handler it wraps are propagated.
+func Adapt(m func(http.Handler) http.Handler) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			var err error
+			m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err = next(w, r)
+			})).ServeHTTP(w, r)
+			return err
+		}
+	}
+}
+
+// Chain is a sequence of Middleware. The first Middleware is outermost, so receives each request
+// first.
+type Chain []Middleware
+
+// Func returns a HandlerFunc that calls h, wrapped by the Middleware of c.
+func (c Chain) Func(h HandlerFunc) HandlerFunc {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+// Then returns an http.Handler that serves requests with h, wrapped by the Middleware of c. An
+// error returned by h or the Middleware is written as by WriteErr with opts, and WithRequest.
+// Options that accept a request, such as WithErrorLog, may be supplied with a nil request, in
+// which case the request being served is used. If the response has already been written, the
+// error is instead reported to the function established by WithErrorLog, if any, with the status
+// code of the response written.
+func (c Chain) Then(h HandlerFunc, opts ...Option) http.Handler {
+	f := c.Func(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveFunc(w, r, f, opts)
+	})
+}
+
+// serveFunc serves r with f, writing the error it returns, if any, with opts.
+func serveFunc(w http.ResponseWriter, r *http.Request, f HandlerFunc, opts []Option) {
+	sw := &statusWriter{ResponseWriter: w}
+	err := f(sw, r)
+	if err == nil {
+		return
+	}
+
+	o := newOptions(opts)
+	o.request = r
+	o.startTiming()
+	if o.errorLogRequest == nil {
+		o.errorLogRequest = r
+	}
+
+	if sw.code != 0 {
+		if o.errorLog != nil {
+			o.errorLog(o.errorLogRequest, sw.code, err)
+		}
+		return
+	}
+	_ = writeError(w, errorFor(err), err, o)
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	errUnauthorized := NewError("unauthorized", http.StatusUnauthorized)
+
+	// trace returns a Middleware that records name in the X-Trace header on entry.
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Add("X-Trace", name)
+				return next(w, r)
+			}
+		}
+	}
+	auth := func(next HandlerFunc) HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			if r.Header.Get("Authorization") == "" {
+				return errUnauthorized
+			}
+			return next(w, r)
+		}
+	}
+	adapted := Adapt(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", "adapted")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	tests := []struct {
+		name      string
+		chain     Chain
+		auth      string
+		h         HandlerFunc
+		wantCode  int
+		wantTrace string
+		wantBody  string
+	}{
+		{
+			name:      "Success",
+			chain:     Chain{trace("a"), trace("b")},
+			h:         func(w http.ResponseWriter, r *http.Request) error { return WriteResponse(w, "blah", http.StatusOK) },
+			wantCode:  http.StatusOK,
+			wantTrace: "a,b",
+			wantBody:  `{"data":"blah"}`,
+		},
+		{
+			name:      "MiddlewareError",
+			chain:     Chain{trace("a"), auth, trace("b")},
+			h:         func(w http.ResponseWriter, r *http.Request) error { return WriteResponse(w, "blah", http.StatusOK) },
+			wantCode:  http.StatusUnauthorized,
+			wantTrace: "a",
+			wantBody:  `{"error":{"code":401,"message":"unauthorized"}}`,
+		},
+		{
+			name:      "MiddlewarePassed",
+			chain:     Chain{trace("a"), auth, trace("b")},
+			auth:      "blah",
+			h:         func(w http.ResponseWriter, r *http.Request) error { return WriteResponse(w, "blah", http.StatusOK) },
+			wantCode:  http.StatusOK,
+			wantTrace: "a,b",
+			wantBody:  `{"data":"blah"}`,
+		},
+		{
+			name:      "HandlerError",
+			chain:     Chain{adapted},
+			h:         func(w http.ResponseWriter, r *http.Request) error { return errors.New("blah") },
+			wantCode:  http.StatusInternalServerError,
+			wantTrace: "adapted",
+			wantBody:  `{"error":{"code":500,"message":"blah"}}`,
+		},
+		{
+			name: "WrittenError",
+			h: func(w http.ResponseWriter, r *http.Request) error {
+				_ = WriteResponse(w, "blah", http.StatusOK)
+				return errors.New("blah")
+			},
+			wantCode: http.StatusOK,
+			wantBody: `{"data":"blah"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			rr := httptest.NewRecorder()
+
+			tt.chain.Then(tt.h).ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := strings.Join(rr.Header().Values("X-Trace"), ","), tt.wantTrace; got != want {
+				t.Errorf("got trace %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestChainErrorLog(t *testing.T) {
+	errHandler := errors.New("blah")
+
+	tests := []struct {
+		name     string
+		h        HandlerFunc
+		wantCode int
+	}{
+		{"Unwritten", func(w http.ResponseWriter, r *http.Request) error { return errHandler }, http.StatusInternalServerError},
+		{"Written", func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusAccepted)
+			return errHandler
+		}, http.StatusAccepted},
+		{"Flushed", func(w http.ResponseWriter, r *http.Request) error {
+			f, ok := w.(http.Flusher)
+			if !ok {
+				t.Fatal("writer does not implement http.Flusher")
+			}
+			f.Flush()
+			return errHandler
+		}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			var gotReq *http.Request
+			var gotCode int
+			var gotErr error
+			f := func(r *http.Request, code int, err error) {
+				gotReq, gotCode, gotErr = r, code, err
+			}
+
+			Chain{}.Then(tt.h, WithErrorLog(nil, f)).ServeHTTP(httptest.NewRecorder(), r)
+
+			if gotReq != r {
+				t.Errorf("got request %p, want %p", gotReq, r)
+			}
+			if got, want := gotCode, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if !errors.Is(gotErr, errHandler) {
+				t.Errorf("got error %v, want %v", gotErr, errHandler)
+			}
+		})
+	}
+}
+
+func TestHandlerFunc(t *testing.T) {
+	var h http.Handler = HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return NewError("blah", http.StatusNotFound)
+	})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got, want := rr.Code, http.StatusNotFound; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Body.String(), `{"error":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// MessagePack is the MessagePack wire format (application/msgpack). Byte strings and extension
+// types are not produced when writing. When reading, byte strings are converted to base64-encoded
+// strings, consistent with the JSON encoding of []byte, and extension types are rejected.
+//
+// Unlike other formats, responses are encoded and decoded directly from and to Go values, with the
+// same results as converting their JSON encoding, so MessagePack reduces both the size of responses
+// and the cost of encoding and decoding them. Values with custom JSON encodings, such as those
+// implementing json.Marshaler, are converted individually. Data rewritten by options such as
+// WithRedaction, WithTimeFormat or WithFields, and entire responses written or read with
+// WithCodec, WithCanonical or WithRejectDuplicateKeys, which are defined in terms of JSON, are
+// converted in full.
+var MessagePack Format = msgpackFormat{}
+
+// WriteResponseMsgpack writes a status code and MessagePack response containing data to w, in the
+// same way as WriteResponse with WithFormat(MessagePack).
+func WriteResponseMsgpack(w http.ResponseWriter, data interface{}, code int, opts ...Option) error {
+	opts = joinOptions(opts, []Option{WithFormat(MessagePack)})
+	return WriteResponsePage(w, data, nil, code, opts...)
+}
+
+type msgpackFormat struct{}
+
+func (msgpackFormat) ContentType() string { return "application/msgpack" }
+
+func (msgpackFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := appendMsgpack(&buf, v); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (msgpackFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d := msgpackDecoder{byteDecoder{b: b}}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	if d.off != len(b) {
+		return nil, errors.New("msgpack: unexpected data after top-level value")
+	}
+
+	var buf bytes.Buffer
+	if err := v.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendMsgpackHeader appends a MessagePack header for a value of length n to buf, using the fixed
+// format fix if n is less than fixMax, or otherwise the 8, 16 or 32-bit format. A zero value for
+// f8 indicates that no 8-bit format exists.
+func appendMsgpackHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, f8, f16, f32 byte) {
+	switch {
+	case n < fixMax:
+		buf.WriteByte(fix | byte(n))
+	case f8 != 0 && n <= math.MaxUint8:
+		buf.Write([]byte{f8, byte(n)})
+	case n <= math.MaxUint16:
+		buf.WriteByte(f16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(f32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+func appendMsgpackString(buf *bytes.Buffer, s string) {
+	appendMsgpackHeader(buf, len(s), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	buf.WriteString(s)
+}
+
+func appendMsgpackNumber(buf *bytes.Buffer, s string) error {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		appendMsgpackInt(buf, i)
+		return nil
+	}
+
+	if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		appendMsgpackUint(buf, u)
+		return nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	buf.WriteByte(0xcb)
+	_ = binary.Write(buf, binary.BigEndian, f)
+	return nil
+}
+
+func appendMsgpackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		appendMsgpackUint(buf, uint64(i))
+	case i >= -32:
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		buf.Write([]byte{0xd0, byte(i)})
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+func appendMsgpackUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= math.MaxInt8:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		_ = binary.Write(buf, binary.BigEndian, uint16(u))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		_ = binary.Write(buf, binary.BigEndian, uint32(u))
+	default:
+		buf.WriteByte(0xcf)
+		_ = binary.Write(buf, binary.BigEndian, u)
+	}
+}
+
+// appendMsgpack appends the MessagePack encoding of v to buf.
+func appendMsgpack(buf *bytes.Buffer, v jsonValue) error {
+	switch v.kind {
+	case 'n':
+		buf.WriteByte(0xc0)
+	case 'b':
+		if v.b {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case 'd':
+		return appendMsgpackNumber(buf, v.s)
+	case 's':
+		appendMsgpackString(buf, v.s)
+	case '[':
+		appendMsgpackHeader(buf, len(v.elems), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, e := range v.elems {
+			if err := appendMsgpack(buf, e); err != nil {
+				return err
+			}
+		}
+	case '{':
+		appendMsgpackHeader(buf, len(v.elems), 0x80, 16, 0, 0xde, 0xdf)
+		for i, e := range v.elems {
+			appendMsgpackString(buf, v.keys[i])
+			if err := appendMsgpack(buf, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// msgpackDecoder decodes MessagePack values from a byte slice.
+type msgpackDecoder struct {
+	byteDecoder
+}
+
+func (d *msgpackDecoder) array(n, depth int) (jsonValue, error) {
+	v := jsonValue{kind: '[', elems: make([]jsonValue, 0, n)}
+	for i := 0; i < n; i++ {
+		e, err := d.value(depth + 1)
+		if err != nil {
+			return jsonValue{}, err
+		}
+		v.elems = append(v.elems, e)
+	}
+	return v, nil
+}
+
+func (d *msgpackDecoder) object(n, depth int) (jsonValue, error) {
+	v := jsonValue{kind: '{', elems: make([]jsonValue, 0, n), keys: make([]string, 0, n)}
+	for i := 0; i < n; i++ {
+		k, err := d.value(depth + 1)
+		if err != nil {
+			return jsonValue{}, err
+		}
+		if k.kind != 's' && k.kind != 'd' {
+			return jsonValue{}, errors.New("unsupported map key type")
+		}
+		e, err := d.value(depth + 1)
+		if err != nil {
+			return jsonValue{}, err
+		}
+		v.keys = append(v.keys, k.s)
+		v.elems = append(v.elems, e)
+	}
+	return v, nil
+}
+
+// value decodes the next value.
+func (d *msgpackDecoder) value(depth int) (jsonValue, error) {
+	if depth > maxFormatDepth {
+		return jsonValue{}, errMaxDepth
+	}
+
+	t, err := d.token()
+	if err != nil {
+		return jsonValue{}, err
+	}
+
+	switch t.kind {
+	case 'n':
+		return jsonValue{kind: 'n'}, nil
+	case 'b':
+		return jsonValue{kind: 'b', b: t.b}, nil
+	case 'i':
+		return jsonValue{kind: 'd', s: strconv.FormatInt(t.i, 10)}, nil
+	case 'u':
+		return jsonValue{kind: 'd', s: strconv.FormatUint(t.u, 10)}, nil
+	case 'f':
+		return floatValue(t.f)
+	case 's':
+		return jsonValue{kind: 's', s: string(t.p)}, nil
+	case 'x':
+		return bytesValue(t.p), nil
+	case '[':
+		return d.array(t.n, depth)
+	}
+	return d.object(t.n, depth)
+}
+
+// msgpackToken is the header of a MessagePack value: a scalar, the contents of a string or byte
+// string, or the length of an array or map.
+type msgpackToken struct {
+	kind byte // One of 'n' (nil), 'b', 'i' (int), 'u' (uint), 'f', 's', 'x' (byte string), '[' or '{'.
+	b    bool
+	i    int64
+	u    uint64
+	f    float64
+	p    []byte // Contents of a string or byte string.
+	n    int    // Length of an array or map.
+}
+
+// token decodes the header of the next value.
+func (d *msgpackDecoder) token() (msgpackToken, error) { //nolint:gocyclo
+	b, err := d.next(1)
+	if err != nil {
+		return msgpackToken{}, err
+	}
+
+	switch c := b[0]; {
+	case c <= 0x7f:
+		return msgpackToken{kind: 'u', u: uint64(c)}, nil
+	case c <= 0x8f:
+		return msgpackToken{kind: '{', n: int(c & 0x0f)}, nil
+	case c <= 0x9f:
+		return msgpackToken{kind: '[', n: int(c & 0x0f)}, nil
+	case c <= 0xbf:
+		return d.bytes('s', int(c&0x1f))
+	case c >= 0xe0:
+		return msgpackToken{kind: 'i', i: int64(int8(c))}, nil
+	}
+
+	switch c := b[0]; c {
+	case 0xc0:
+		return msgpackToken{kind: 'n'}, nil
+	case 0xc2, 0xc3:
+		return msgpackToken{kind: 'b', b: c == 0xc3}, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return d.bytes('x', n)
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return msgpackToken{kind: 'f', f: float64(math.Float32frombits(uint32(u)))}, nil
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return msgpackToken{kind: 'f', f: math.Float64frombits(u)}, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return msgpackToken{kind: 'u', u: u}, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		u, err := d.uint(n)
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		// Sign-extend the value from n bytes.
+		shift := 64 - 8*n
+		return msgpackToken{kind: 'i', i: int64(u<<shift) >> shift}, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return d.bytes('s', n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return msgpackToken{kind: '[', n: n}, nil
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return msgpackToken{}, err
+		}
+		return msgpackToken{kind: '{', n: n}, nil
+	}
+	return msgpackToken{}, fmt.Errorf("unsupported type 0x%02x", b[0])
+}
+
+// bytes decodes the contents of a string or byte string of length n.
+func (d *msgpackDecoder) bytes(kind byte, n int) (msgpackToken, error) {
+	p, err := d.next(n)
+	if err != nil {
+		return msgpackToken{}, err
+	}
+	return msgpackToken{kind: kind, p: p}, nil
+}
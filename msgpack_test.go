@@ -0,0 +1,156 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessagePack(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		msgpack  string
+		wantJSON string
+	}{
+		{"Null", `null`, "c0", `null`},
+		{"True", `true`, "c3", `true`},
+		{"False", `false`, "c2", `false`},
+		{"PositiveFixint", `127`, "7f", `127`},
+		{"NegativeFixint", `-32`, "e0", `-32`},
+		{"Uint8", `200`, "ccc8", `200`},
+		{"Uint16", `65535`, "cdffff", `65535`},
+		{"Uint32", `65536`, "ce00010000", `65536`},
+		{"Uint64", `18446744073709551615`, "cfffffffffffffffff", `18446744073709551615`},
+		{"Int8", `-33`, "d0df", `-33`},
+		{"Int16", `-129`, "d1ff7f", `-129`},
+		{"Int32", `-32769`, "d2ffff7fff", `-32769`},
+		{"Int64", `-2147483649`, "d3ffffffff7fffffff", `-2147483649`},
+		{"Float", `1.5`, "cb3ff8000000000000", `1.5`},
+		{"FixStr", `"abc"`, "a3616263", `"abc"`},
+		{"Str8", `"` + strings.Repeat("a", 32) + `"`, "d920" + strings.Repeat("61", 32), `"` + strings.Repeat("a", 32) + `"`},
+		{"FixArray", `[1,"a"]`, "9201a161", `[1,"a"]`},
+		{"Array16", `[` + strings.Repeat("0,", 15) + `0]`, "dc0010" + strings.Repeat("00", 16), `[` + strings.Repeat("0,", 15) + `0]`},
+		{"FixMap", `{"b":1,"a":2}`, "82a16201a16102", `{"b":1,"a":2}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := MessagePack.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := hex.EncodeToString(buf.Bytes()), tt.msgpack; got != want {
+				t.Errorf("got msgpack %v, want %v", got, want)
+			}
+
+			b, err := MessagePack.ToJSON(&buf)
+			if err != nil {
+				t.Fatalf("failed to convert to JSON: %v", err)
+			}
+			if got, want := string(b), tt.wantJSON; got != want {
+				t.Errorf("got JSON %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMessagePackToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		msgpack  string
+		wantJSON string
+		wantErr  bool
+	}{
+		{"Float32", "ca3fc00000", `1.5`, false},
+		{"Bin8", "c403010203", `"AQID"`, false},
+		{"Map16", "de0001a16101", `{"a":1}`, false},
+		{"IntegerKey", "810102", `{"1":2}`, false},
+		{"Empty", "", ``, true},
+		{"Truncated", "a36162", ``, true},
+		{"LengthTooLarge", "dcffff00", ``, true},
+		{"Ext", "d40100", ``, true},
+		{"NaN", "cb7ff8000000000001", ``, true},
+		{"InvalidKey", "81c001", ``, true},
+		{"TrailingData", "c0c0", ``, true},
+		{"TooDeep", strings.Repeat("91", maxFormatDepth+2) + "c0", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := hex.DecodeString(tt.msgpack)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := MessagePack.ToJSON(bytes.NewReader(in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.wantJSON; got != want {
+				t.Errorf("got JSON %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithFormatMessagePack(t *testing.T) {
+	type TestStruct struct {
+		Value string
+		Count int
+	}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, TestStruct{"blah", 42}, &PageDetails{Next: "n"}, http.StatusOK, WithFormat(MessagePack)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/msgpack"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	var ts TestStruct
+	pd, err := ReadResponsePage(rr.Body, &ts, WithFormat(MessagePack))
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if got, want := ts, (TestStruct{"blah", 42}); got != want {
+		t.Errorf("got data %+v, want %+v", got, want)
+	}
+	if got, want := pd, (&PageDetails{Next: "n"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page %+v, want %+v", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithFormat(MessagePack)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := ReadError(rr.Body, WithFormat(MessagePack)), (&Error{Code: http.StatusNotFound, Message: "blah"}); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
+
+func TestWriteResponseMsgpack(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteResponseMsgpack(rr, "blah", http.StatusOK, WithMeta("a", 1)); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Header().Get("Content-Type"), "application/msgpack"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+
+	b, err := MessagePack.ToJSON(rr.Body)
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if got, want := string(b), `{"data":"blah","meta":{"a":1}}`; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
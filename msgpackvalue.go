@@ -0,0 +1,905 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// The encoding in this file writes and reads MessagePack directly from and to Go values, with the
+// same results as converting their JSON encoding. Values whose JSON encoding it does not reproduce,
+// such as those with custom encodings or embedded fields, are converted from and to JSON
+// individually. When reading, anything else it does not handle, including invalid documents, is
+// left to the conversion of the entire document, so that errors are reported in the same way.
+
+// errMsgpackDeclined is returned when a document is not decoded directly.
+var errMsgpackDeclined = errors.New("msgpack: declined")
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	numberType          = reflect.TypeOf(json.Number(""))
+)
+
+// unmarshalerCache caches the values returned by hasUnmarshaler.
+var unmarshalerCache sync.Map // map[reflect.Type]bool
+
+// hasUnmarshaler reports whether t, or a pointer to t, customizes its decoding.
+func hasUnmarshaler(t reflect.Type) bool {
+	if v, ok := unmarshalerCache.Load(t); ok {
+		return v.(bool)
+	}
+
+	pt := reflect.PtrTo(t)
+	ok := t.Implements(unmarshalerType) || pt.Implements(unmarshalerType) ||
+		t.Implements(textUnmarshalerType) || pt.Implements(textUnmarshalerType)
+	unmarshalerCache.Store(t, ok)
+	return ok
+}
+
+// packField describes a field of a struct type encoded directly.
+type packField struct {
+	index     int
+	name      string
+	key       string // encoded map key
+	omitEmpty bool
+}
+
+// packFieldsCache caches the values returned by packFields.
+var packFieldsCache sync.Map // map[reflect.Type][]packField
+
+// packFields returns the fields of the struct type t, in the order they are encoded, and reports
+// whether t is encoded directly.
+func packFields(t reflect.Type) ([]packField, bool) {
+	if v, ok := packFieldsCache.Load(t); ok {
+		fs := v.([]packField)
+		return fs, fs != nil
+	}
+
+	pfs, ok := plainFields(t)
+	fs := make([]packField, 0, len(pfs))
+	for _, pf := range pfs {
+		var buf bytes.Buffer
+		appendMsgpackString(&buf, pf.name)
+		fs = append(fs, packField{index: pf.index, name: pf.name, key: buf.String(), omitEmpty: pf.omitEmpty})
+	}
+	if !ok {
+		fs = nil
+	}
+	packFieldsCache.Store(t, fs)
+	return fs, ok
+}
+
+// matchField returns the field of fs matching key, preferring an exact match, as encoding/json does.
+func matchField(fs []packField, key []byte) (packField, bool) {
+	for _, f := range fs {
+		if f.name == string(key) {
+			return f, true
+		}
+	}
+	for _, f := range fs {
+		if bytes.EqualFold([]byte(f.name), key) {
+			return f, true
+		}
+	}
+	return packField{}, false
+}
+
+func (msgpackFormat) encodeValue(buf *bytes.Buffer, v interface{}) error {
+	return appendMsgpackValue(buf, reflect.ValueOf(v), 0)
+}
+
+// appendMsgpackValue appends the MessagePack encoding of v, nested within depth containers, to buf.
+func appendMsgpackValue(buf *bytes.Buffer, v reflect.Value, depth int) error {
+	if !v.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+	return packEncoder(v.Type())(buf, v, depth)
+}
+
+// packEncoderFunc appends the MessagePack encoding of v, nested within depth containers, to buf.
+// Values nested too deeply, which may be cyclic, are left to encoding/json.
+type packEncoderFunc func(buf *bytes.Buffer, v reflect.Value, depth int) error
+
+// packEncoderCache caches the values returned by packEncoder.
+var packEncoderCache sync.Map // map[reflect.Type]packEncoderFunc
+
+// packEncoder returns the encoder for values of type t.
+func packEncoder(t reflect.Type) packEncoderFunc {
+	if f, ok := packEncoderCache.Load(t); ok {
+		return f.(packEncoderFunc)
+	}
+
+	// An indirect encoder is stored while the encoder is built, so that recursive types may refer
+	// to it.
+	var (
+		wg sync.WaitGroup
+		f  packEncoderFunc
+	)
+	wg.Add(1)
+	fi, loaded := packEncoderCache.LoadOrStore(t, packEncoderFunc(func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+		wg.Wait()
+		return f(buf, v, depth)
+	}))
+	if loaded {
+		return fi.(packEncoderFunc)
+	}
+
+	f = newPackEncoder(t)
+	wg.Done()
+	packEncoderCache.Store(t, f)
+	return f
+}
+
+// newPackEncoder builds the encoder for values of type t.
+func newPackEncoder(t reflect.Type) packEncoderFunc { //nolint:gocyclo
+	if hasMarshaler(t) {
+		return appendMsgpackJSON
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(buf *bytes.Buffer, v reflect.Value, _ int) error {
+			if v.Bool() {
+				buf.WriteByte(0xc3)
+			} else {
+				buf.WriteByte(0xc2)
+			}
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(buf *bytes.Buffer, v reflect.Value, _ int) error {
+			appendMsgpackInt(buf, v.Int())
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(buf *bytes.Buffer, v reflect.Value, _ int) error {
+			appendMsgpackUint(buf, v.Uint())
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+			if !appendMsgpackFloat(buf, v.Float(), bits) {
+				return appendMsgpackJSON(buf, v, depth)
+			}
+			return nil
+		}
+	case reflect.String:
+		return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+			s := v.String()
+			if !utf8.ValidString(s) {
+				return appendMsgpackJSON(buf, v, depth)
+			}
+			appendMsgpackString(buf, s)
+			return nil
+		}
+	case reflect.Interface:
+		return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+			if v.IsNil() {
+				buf.WriteByte(0xc0)
+				return nil
+			}
+			return appendMsgpackValue(buf, v.Elem(), depth)
+		}
+	case reflect.Ptr:
+		elem := packEncoder(t.Elem())
+		return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+			if v.IsNil() {
+				buf.WriteByte(0xc0)
+				return nil
+			}
+			if depth >= maxFormatDepth {
+				return appendMsgpackJSON(buf, v, depth)
+			}
+			return elem(buf, v.Elem(), depth+1)
+		}
+	case reflect.Slice:
+		if et := t.Elem(); et.Kind() == reflect.Uint8 && !hasMarshaler(et) {
+			return appendMsgpackBytes
+		}
+		elem := packEncoder(t.Elem())
+		return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+			if v.IsNil() {
+				buf.WriteByte(0xc0)
+				return nil
+			}
+			return appendMsgpackArray(buf, v, elem, depth)
+		}
+	case reflect.Array:
+		elem := packEncoder(t.Elem())
+		return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+			return appendMsgpackArray(buf, v, elem, depth)
+		}
+	case reflect.Map:
+		return newPackMapEncoder(t)
+	case reflect.Struct:
+		return newPackStructEncoder(t)
+	}
+	return appendMsgpackJSON
+}
+
+// appendMsgpackFloat appends the MessagePack encoding of f, of the supplied bit size, to buf, as
+// converted from its JSON encoding, and reports whether it was able to. NaN and infinite values
+// are declined.
+func appendMsgpackFloat(buf *bytes.Buffer, f float64, bits int) bool {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+
+	// Integral values are encoded as integers where their JSON encoding is, which is exact only
+	// below 2^53. Other values are converted from their JSON encoding as is.
+	switch {
+	case bits == 64 && f != math.Trunc(f):
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, f)
+	case bits == 64 && math.Abs(f) < 1<<53:
+		appendMsgpackInt(buf, int64(f))
+	default:
+		var scratch [32]byte
+		var js bytes.Buffer
+		appendFloat(&js, scratch[:0], f, bits)
+		_ = appendMsgpackNumber(buf, js.String())
+	}
+	return true
+}
+
+// appendMsgpackBytes appends the MessagePack encoding of the byte slice v to buf, which is a
+// base64-encoded string, as encoded by encoding/json.
+func appendMsgpackBytes(buf *bytes.Buffer, v reflect.Value, _ int) error {
+	if v.IsNil() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+	b := make([]byte, base64.StdEncoding.EncodedLen(v.Len()))
+	base64.StdEncoding.Encode(b, v.Bytes())
+	appendMsgpackHeader(buf, len(b), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	buf.Write(b)
+	return nil
+}
+
+func appendMsgpackArray(buf *bytes.Buffer, v reflect.Value, elem packEncoderFunc, depth int) error {
+	if depth >= maxFormatDepth {
+		return appendMsgpackJSON(buf, v, depth)
+	}
+
+	n := v.Len()
+	appendMsgpackHeader(buf, n, 0x90, 16, 0, 0xdc, 0xdd)
+	for i := 0; i < n; i++ {
+		if err := elem(buf, v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newPackMapEncoder builds the encoder for values of the map type t. Members are named, and sorted
+// by name, as by encoding/json.
+func newPackMapEncoder(t reflect.Type) packEncoderFunc {
+	kt := t.Key()
+	if hasMarshaler(kt) {
+		// Whether encoding/json uses the MarshalText method of a string key varies between Go
+		// releases, so keys with custom encodings are left to it.
+		return appendMsgpackJSON
+	}
+
+	var name func(k reflect.Value) string
+	switch kt.Kind() {
+	case reflect.String:
+		name = reflect.Value.String
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		name = func(k reflect.Value) string { return strconv.FormatInt(k.Int(), 10) }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		name = func(k reflect.Value) string { return strconv.FormatUint(k.Uint(), 10) }
+	default:
+		return appendMsgpackJSON
+	}
+
+	type member struct {
+		name string
+		v    reflect.Value
+	}
+	elem := packEncoder(t.Elem())
+	return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+		if v.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if depth >= maxFormatDepth {
+			return appendMsgpackJSON(buf, v, depth)
+		}
+
+		ms := make([]member, 0, v.Len())
+		for it := v.MapRange(); it.Next(); {
+			n := name(it.Key())
+			if !utf8.ValidString(n) {
+				return appendMsgpackJSON(buf, v, depth)
+			}
+			ms = append(ms, member{n, it.Value()})
+		}
+		if len(ms) > 1 {
+			sort.Slice(ms, func(i, j int) bool { return ms[i].name < ms[j].name })
+		}
+
+		appendMsgpackHeader(buf, len(ms), 0x80, 16, 0, 0xde, 0xdf)
+		for _, m := range ms {
+			appendMsgpackString(buf, m.name)
+			if err := elem(buf, m.v, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// newPackStructEncoder builds the encoder for values of the struct type t.
+func newPackStructEncoder(t reflect.Type) packEncoderFunc {
+	pfs, ok := packFields(t)
+	if !ok {
+		return appendMsgpackJSON
+	}
+
+	type field struct {
+		packField
+		enc packEncoderFunc
+	}
+	fs := make([]field, 0, len(pfs))
+	for _, pf := range pfs {
+		fs = append(fs, field{pf, packEncoder(t.Field(pf.index).Type)})
+	}
+
+	return func(buf *bytes.Buffer, v reflect.Value, depth int) error {
+		if depth >= maxFormatDepth {
+			return appendMsgpackJSON(buf, v, depth)
+		}
+
+		n := 0
+		for _, f := range fs {
+			omit, ok := omitted(v.Field(f.index), f.omitEmpty)
+			if !ok {
+				return appendMsgpackJSON(buf, v, depth)
+			}
+			if !omit {
+				n++
+			}
+		}
+
+		appendMsgpackHeader(buf, n, 0x80, 16, 0, 0xde, 0xdf)
+		for _, f := range fs {
+			fv := v.Field(f.index)
+			if omit, _ := omitted(fv, f.omitEmpty); omit {
+				continue
+			}
+			buf.WriteString(f.key)
+			if err := f.enc(buf, fv, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// omitted reports whether the field v is omitted, according to whether it has the omitempty
+// option, and whether that is known without encoding/json. Whether negative zero is empty varies
+// between Go releases, so is left to encoding/json.
+func omitted(v reflect.Value, omitEmpty bool) (omit, ok bool) {
+	if !omitEmpty {
+		return false, true
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0, true
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); f == 0 {
+			return !math.Signbit(f), !math.Signbit(f)
+		}
+		return false, true
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Interface, reflect.Ptr:
+		return v.IsZero(), true
+	}
+	return false, true
+}
+
+// appendMsgpackJSON appends the MessagePack encoding of v to buf, converted from its JSON
+// encoding. Addressable values are encoded by address, so that encoding/json uses any MarshalJSON
+// method with a pointer receiver, as it would if encoding the enclosing value.
+func appendMsgpackJSON(buf *bytes.Buffer, v reflect.Value, _ int) error {
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	jv, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+	return appendMsgpack(buf, jv)
+}
+
+func (msgpackFormat) decodeValue(b []byte, v interface{}, o *options) error {
+	d := msgpackValueDecoder{msgpackDecoder: msgpackDecoder{byteDecoder{b: b}}, o: o}
+
+	u, isResponse := v.(*rawResponse)
+	if isResponse {
+		d.data = &u.packed
+		u.packed, u.format = nil, MessagePack
+	}
+
+	err := d.decodeAll(v)
+	if err != nil && isResponse {
+		u.packed = nil
+	}
+	return err
+}
+
+// msgpackValueDecoder decodes MessagePack values directly into Go values.
+type msgpackValueDecoder struct {
+	msgpackDecoder
+	o    *options
+	data *[]byte // where the encoded data of a response envelope is retained, if not nil
+}
+
+// decodeAll decodes the entire document into v, which must be a non-nil pointer.
+func (d *msgpackValueDecoder) decodeAll(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errMsgpackDeclined
+	}
+
+	// The document is checked before it is decoded, so that v is not modified if it is invalid, as
+	// it would not be by encoding/json.
+	if err := d.skip(0); err != nil {
+		return err
+	}
+	if d.off != len(d.b) {
+		return errMsgpackDeclined
+	}
+	d.off = 0
+	return d.decode(rv.Elem(), 0)
+}
+
+// enter checks that a container may be nested within depth others, according to the limits of
+// the conversion to JSON and of d.o.
+func (d *msgpackValueDecoder) enter(depth int) error {
+	if depth >= maxFormatDepth || d.o.maxDepth > 0 && depth >= d.o.maxDepth {
+		return errMsgpackDeclined
+	}
+	return nil
+}
+
+// decode decodes the next value, nested within depth containers, into v, as encoding/json decodes
+// the JSON equivalent.
+func (d *msgpackValueDecoder) decode(v reflect.Value, depth int) error { //nolint:gocyclo
+	if hasUnmarshaler(v.Type()) {
+		return d.decodeJSON(v, depth)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if d.off < len(d.b) && d.b[d.off] == 0xc0 {
+			d.off++
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decode(v.Elem(), depth)
+	case reflect.Interface:
+		if v.NumMethod() != 0 || !v.IsNil() {
+			return d.decodeJSON(v, depth)
+		}
+		x, err := d.any(depth)
+		if err != nil {
+			return err
+		}
+		if x != nil {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	case reflect.Struct:
+		if _, ok := packFields(v.Type()); !ok {
+			return d.decodeJSON(v, depth)
+		}
+	case reflect.Map:
+		if kt := v.Type().Key(); kt.Kind() != reflect.String || hasUnmarshaler(kt) {
+			return d.decodeJSON(v, depth)
+		}
+	}
+
+	t, err := d.token()
+	if err != nil {
+		return err
+	}
+	if t.kind == 'n' {
+		// Null sets maps and slices to nil, and leaves other values unmodified.
+		if k := v.Kind(); k == reflect.Map || k == reflect.Slice {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if t.kind != 'b' {
+			return errMsgpackDeclined
+		}
+		v.SetBool(t.b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := t.i
+		if t.kind == 'u' && t.u <= math.MaxInt64 {
+			i = int64(t.u)
+		} else if t.kind != 'i' {
+			return errMsgpackDeclined
+		}
+		if v.OverflowInt(i) {
+			return errMsgpackDeclined
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := t.u
+		if t.kind == 'i' && t.i >= 0 {
+			u = uint64(t.i)
+		} else if t.kind != 'u' {
+			return errMsgpackDeclined
+		}
+		if v.OverflowUint(u) {
+			return errMsgpackDeclined
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := t.float(v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, err := t.string(v.Type() == numberType)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Slice:
+		return d.slice(v, t, depth)
+	case reflect.Array:
+		return d.array(v, t, depth)
+	case reflect.Map:
+		return d.object(v, t, depth)
+	case reflect.Struct:
+		return d.structure(v, t, depth)
+	default:
+		return errMsgpackDeclined
+	}
+	return nil
+}
+
+// float returns the value of the number t as a float of the supplied bit size. A float32 is
+// rounded from the JSON encoding of t, as it is by encoding/json.
+func (t msgpackToken) float(bits int) (float64, error) {
+	if bits == 32 {
+		s, err := t.string(true)
+		if err != nil {
+			return 0, err
+		}
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return 0, errMsgpackDeclined
+		}
+		return f, nil
+	}
+
+	switch t.kind {
+	case 'i':
+		return float64(t.i), nil
+	case 'u':
+		return float64(t.u), nil
+	case 'f':
+		if checkFloat(t.f) != nil {
+			return 0, errMsgpackDeclined
+		}
+		return t.f, nil
+	}
+	return 0, errMsgpackDeclined
+}
+
+// string returns the value of the string t, or the JSON encoding of the number t if number is
+// true. Byte strings are base64-encoded.
+func (t msgpackToken) string(number bool) (string, error) {
+	switch {
+	case number && t.kind == 'i':
+		return strconv.FormatInt(t.i, 10), nil
+	case number && t.kind == 'u':
+		return strconv.FormatUint(t.u, 10), nil
+	case number && t.kind == 'f' && checkFloat(t.f) == nil:
+		return strconv.FormatFloat(t.f, 'g', -1, 64), nil
+	case !number && t.kind == 's' && utf8.Valid(t.p):
+		return string(t.p), nil
+	case !number && t.kind == 'x':
+		return base64.StdEncoding.EncodeToString(t.p), nil
+	}
+	return "", errMsgpackDeclined
+}
+
+// key returns the next map key, which must be a string.
+func (d *msgpackValueDecoder) key() ([]byte, error) {
+	t, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind != 's' || !utf8.Valid(t.p) {
+		return nil, errMsgpackDeclined
+	}
+	return t.p, nil
+}
+
+// any returns the next value as encoding/json decodes the JSON equivalent into an interface{}.
+func (d *msgpackValueDecoder) any(depth int) (interface{}, error) {
+	t, err := d.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t.kind {
+	case 'n':
+		return nil, nil
+	case 'b':
+		return t.b, nil
+	case 'i', 'u', 'f':
+		if d.o.useNumber {
+			s, err := t.string(true)
+			return json.Number(s), err
+		}
+		return t.float(64)
+	case 's', 'x':
+		return t.string(false)
+	case '[':
+		if err := d.enter(depth); err != nil {
+			return nil, err
+		}
+		s := make([]interface{}, 0, t.n)
+		for i := 0; i < t.n; i++ {
+			e, err := d.any(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, e)
+		}
+		return s, nil
+	}
+
+	if err := d.enter(depth); err != nil {
+		return nil, err
+	}
+	m := make(map[string]interface{}, t.n)
+	for i := 0; i < t.n; i++ {
+		k, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		e, err := d.any(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[string(k)] = e
+	}
+	return m, nil
+}
+
+// slice decodes the value beginning with t into the slice v. As with encoding/json, elements are
+// decoded into those of v, which is grown as necessary.
+func (d *msgpackValueDecoder) slice(v reflect.Value, t msgpackToken, depth int) error {
+	if v.Type().Elem().Kind() == reflect.Uint8 && (t.kind == 's' || t.kind == 'x') {
+		b := t.p
+		if t.kind == 's' {
+			var err error
+			if b, err = base64.StdEncoding.DecodeString(string(t.p)); err != nil {
+				return errMsgpackDeclined
+			}
+		} else {
+			b = append([]byte{}, b...)
+		}
+		v.SetBytes(b)
+		return nil
+	}
+	if t.kind != '[' {
+		return errMsgpackDeclined
+	}
+	if err := d.enter(depth); err != nil {
+		return err
+	}
+
+	if t.n == 0 {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		return nil
+	}
+	if t.n > v.Cap() {
+		nv := reflect.MakeSlice(v.Type(), t.n, t.n)
+		reflect.Copy(nv, v.Slice(0, v.Cap()))
+		v.Set(nv)
+	}
+	v.SetLen(t.n)
+	for i := 0; i < t.n; i++ {
+		if err := d.decode(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// array decodes the value beginning with t into the array v. As with encoding/json, excess
+// elements are discarded, and missing elements are set to zero.
+func (d *msgpackValueDecoder) array(v reflect.Value, t msgpackToken, depth int) error {
+	if t.kind != '[' {
+		return errMsgpackDeclined
+	}
+	if err := d.enter(depth); err != nil {
+		return err
+	}
+
+	for i := 0; i < t.n; i++ {
+		var err error
+		if i < v.Len() {
+			err = d.decode(v.Index(i), depth+1)
+		} else {
+			err = d.skip(depth + 1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := t.n; i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
+	}
+	return nil
+}
+
+// object decodes the value beginning with t into the map v, whose keys are strings.
+func (d *msgpackValueDecoder) object(v reflect.Value, t msgpackToken, depth int) error {
+	if t.kind != '{' {
+		return errMsgpackDeclined
+	}
+	if err := d.enter(depth); err != nil {
+		return err
+	}
+
+	mt := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(mt, t.n))
+	}
+	e := reflect.New(mt.Elem()).Elem()
+	for i := 0; i < t.n; i++ {
+		k, err := d.key()
+		if err != nil {
+			return err
+		}
+		e.Set(reflect.Zero(mt.Elem()))
+		if err := d.decode(e, depth+1); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(string(k)).Convert(mt.Key()), e)
+	}
+	return nil
+}
+
+// structure decodes the value beginning with t into the struct v, which is decoded directly. The
+// data of a response envelope is retained in d.data, if not nil.
+func (d *msgpackValueDecoder) structure(v reflect.Value, t msgpackToken, depth int) error {
+	if t.kind != '{' {
+		return errMsgpackDeclined
+	}
+	if err := d.enter(depth); err != nil {
+		return err
+	}
+
+	fs, _ := packFields(v.Type())
+	for i := 0; i < t.n; i++ {
+		k, err := d.key()
+		if err != nil {
+			return err
+		}
+
+		f, ok := matchField(fs, k)
+		switch {
+		case !ok && d.o.strict:
+			return errMsgpackDeclined
+		case !ok:
+			err = d.skip(depth + 1)
+		case depth == 0 && d.data != nil && f.name == "data":
+			start := d.off
+			if err = d.skip(depth + 1); err == nil {
+				*d.data = d.b[start:d.off]
+			}
+		default:
+			err = d.decode(v.Field(f.index), depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip skips the next value, nested within depth containers, checking that it converts to JSON.
+func (d *msgpackValueDecoder) skip(depth int) error {
+	t, err := d.token()
+	if err != nil {
+		return err
+	}
+
+	switch t.kind {
+	case 'f':
+		return checkFloat(t.f)
+	case '[', '{':
+		if err := d.enter(depth); err != nil {
+			return err
+		}
+		for i := 0; i < t.n; i++ {
+			if t.kind == '{' {
+				if err := d.skipKey(); err != nil {
+					return err
+				}
+			}
+			if err := d.skip(depth + 1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// skipKey skips the next map key, checking that it converts to JSON.
+func (d *msgpackValueDecoder) skipKey() error {
+	t, err := d.token()
+	if err != nil {
+		return err
+	}
+
+	switch t.kind {
+	case 'f':
+		return checkFloat(t.f)
+	case 'i', 'u', 's', 'x':
+		return nil
+	}
+	return errMsgpackDeclined
+}
+
+// decodeJSON decodes the next value, nested within depth containers, into v by converting it to
+// JSON, for values not decoded directly.
+func (d *msgpackValueDecoder) decodeJSON(v reflect.Value, depth int) error {
+	start := d.off
+	if err := d.skip(depth); err != nil {
+		return err
+	}
+
+	vd := msgpackDecoder{byteDecoder{b: d.b[start:d.off]}}
+	jv, err := vd.value(0)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := jv.appendJSON(&buf); err != nil {
+		return err
+	}
+	return d.o.newDecoder(&buf).Decode(v.Addr().Interface())
+}
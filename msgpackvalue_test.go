@@ -0,0 +1,389 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type packKey string
+
+func (k packKey) MarshalText() ([]byte, error) { return []byte("k" + k), nil }
+
+type packIntKey int
+
+func (k packIntKey) MarshalText() ([]byte, error) { return []byte("k"), nil }
+
+type packPtrMarshaler struct{ N int }
+
+func (m *packPtrMarshaler) MarshalJSON() ([]byte, error) { return []byte(`"ptr"`), nil }
+
+type packEmbedded struct {
+	A int `json:"a"`
+}
+
+type packStruct struct {
+	Name      string            `json:"name"`
+	Count     int               `json:"count,omitempty"`
+	Ratio     float64           `json:"ratio,omitempty"`
+	Tags      []string          `json:"tags"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Child     *packStruct       `json:"child,omitempty"`
+	Any       interface{}       `json:"any"`
+	Skipped   int               `json:"-"`
+	Untagged  bool
+	unexposed int
+}
+
+func TestMessagePackEncodeValue(t *testing.T) {
+	now := time.Date(2021, 2, 3, 4, 5, 6, 7, time.UTC)
+	raw := json.RawMessage(`{"b":1,"a":[true,null]}`)
+
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"Nil", nil},
+		{"Bool", true},
+		{"Int", -129},
+		{"Uint", uint64(math.MaxUint64)},
+		{"Float", 1.5},
+		{"FloatIntegral", 2.0},
+		{"FloatNegativeZero", math.Copysign(0, -1)},
+		{"FloatLarge", 1e20},
+		{"FloatHuge", 1e21},
+		{"FloatTiny", 1e-7},
+		{"FloatAboveInt64", float64(1 << 63)},
+		{"Float32", float32(0.1)},
+		{"FloatIntegralExact", float64(1<<53 - 1)},
+		{"Float32Integral", float32(16777216)},
+		{"Float32Large", float32(123456789)},
+		{"Float32Huge", float32(3e38)},
+		{"String", "a<b>&c"},
+		{"StringInvalid", "a\xffb"},
+		{"Bytes", []byte{1, 2, 3}},
+		{"BytesNil", []byte(nil)},
+		{"SliceNil", []int(nil)},
+		{"SliceEmpty", []int{}},
+		{"Array", [2]string{"a", "b"}},
+		{"Map", map[string]int{"b": 1, "a": 2, "c": 3}},
+		{"MapNil", map[string]int(nil)},
+		{"MapIntKeys", map[int]string{10: "a", 2: "b", -1: "c"}},
+		{"MapUintKeys", map[uint8]bool{1: true}},
+		{"MapTextKeys", map[packKey]int{"b": 1, "a": 2}},
+		{"MapTextIntKeys", map[packIntKey]int{1: 1}},
+		{"MapInvalidKeys", map[string]int{"a\xff": 1}},
+		{"Struct", packStruct{Name: "n", Tags: []string{"x"}, Skipped: 1, Untagged: true, unexposed: 2}},
+		{"StructOmitted", packStruct{Count: 1, Ratio: 0.5, Labels: map[string]string{"l": "v"}}},
+		{"StructNegativeZero", packStruct{Ratio: math.Copysign(0, -1)}},
+		{"StructNested", &packStruct{Child: &packStruct{Any: []interface{}{1, "a", nil}}}},
+		{"StructEmbedded", struct {
+			packEmbedded
+			B int `json:"b"`
+		}{packEmbedded{1}, 2}},
+		{"StructStringOption", struct {
+			N int `json:"n,string"`
+		}{1}},
+		{"StructDuplicate", struct {
+			A int `json:"X"`
+			X int
+		}{1, 2}},
+		{"Time", now},
+		{"TimePointer", &now},
+		{"RawMessage", raw},
+		{"PointerMarshalerElements", []packPtrMarshaler{{1}}},
+		{"PointerMarshalerValue", packPtrMarshaler{1}},
+		{"Interface", []interface{}{map[string]interface{}{"a": 1.5}, int8(1)}},
+		{"Response", Response{Data: packStruct{Name: "n"}, Page: &PageDetails{Next: "n", TotalSize: 1}, Error: &Error{Code: 400, Details: map[string]interface{}{"a": 1}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want bytes.Buffer
+			if err := MessagePack.FromJSON(&want, b); err != nil {
+				t.Fatal(err)
+			}
+
+			var got bytes.Buffer
+			if err := MessagePack.(valueFormat).encodeValue(&got, tt.v); err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Errorf("got %x, want %x", got.Bytes(), want.Bytes())
+			}
+		})
+	}
+}
+
+func TestMessagePackEncodeValueError(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"NaN", math.NaN()},
+		{"Channel", make(chan int)},
+		{"Nested", []interface{}{1, math.Inf(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := MessagePack.(valueFormat).encodeValue(&buf, tt.v); err == nil {
+				t.Errorf("got no error, want error")
+			}
+		})
+	}
+}
+
+func TestMessagePackDecodeValue(t *testing.T) {
+	type flat struct {
+		Name  string   `json:"name"`
+		Count int      `json:"count"`
+		F32   float32  `json:"f32"`
+		Bytes []byte   `json:"bytes"`
+		Tags  []string `json:"tags"`
+		Child *flat    `json:"child"`
+	}
+
+	tests := []struct {
+		name     string
+		json     string
+		msgpack  string // used in place of json if set
+		newValue func() interface{}
+		opts     []Option
+		wantErr  bool
+	}{
+		{"Interface", `{"a":[1,1.5,"s",true,null,{}],"b":-2}`, "", func() interface{} { return new(interface{}) }, nil, false},
+		{"InterfaceUseNumber", `[1,-1,1.5,18446744073709551615]`, "", func() interface{} { return new(interface{}) }, []Option{WithUseNumber()}, false},
+		{"Int", `-5`, "", func() interface{} { return new(int8) }, nil, false},
+		{"IntFromUnsigned", ``, "d001", func() interface{} { return new(uint) }, nil, false},
+		{"IntOverflow", `300`, "", func() interface{} { return new(int8) }, nil, true},
+		{"UintNegative", `-1`, "", func() interface{} { return new(uint) }, nil, true},
+		{"IntFromFloat", `1.5`, "", func() interface{} { return new(int) }, nil, true},
+		{"Float32", ``, "cb3fb999999999999a", func() interface{} { return new(float32) }, nil, false},
+		{"Float32FromInt", `16777217`, "", func() interface{} { return new(float32) }, nil, false},
+		{"Float32Overflow", `1e300`, "", func() interface{} { return new(float32) }, nil, true},
+		{"Number", `1.5`, "", func() interface{} { return new(json.Number) }, nil, false},
+		{"NumberFromString", `"1"`, "", func() interface{} { return new(json.Number) }, nil, false},
+		{"StringFromBin", ``, "c403010203", func() interface{} { return new(string) }, nil, false},
+		{"StringInvalid", ``, "a2ff61", func() interface{} { return new(string) }, nil, false},
+		{"StringFromNumber", `1`, "", func() interface{} { return new(string) }, nil, true},
+		{"BytesFromBin", ``, "c403010203", func() interface{} { return new([]byte) }, nil, false},
+		{"BytesFromString", `"AQID"`, "", func() interface{} { return new([]byte) }, nil, false},
+		{"BytesFromArray", `[1,2]`, "", func() interface{} { return new([]byte) }, nil, false},
+		{"Array", `[1,2,3]`, "", func() interface{} { return &[2]int{9, 9} }, nil, false},
+		{"ArrayShort", `[1]`, "", func() interface{} { return &[2]int{9, 9} }, nil, false},
+		{"SliceReused", `[{"name":"a"}]`, "", func() interface{} { return &[]flat{{Name: "x", Count: 1}, {Name: "y"}} }, nil, false},
+		{"SliceEmpty", `[]`, "", func() interface{} { return &[]int{1} }, nil, false},
+		{"SliceNull", `null`, "", func() interface{} { return &[]int{1} }, nil, false},
+		{"Map", `{"a":{"name":"x"},"b":null}`, "", func() interface{} { return &map[string]*flat{"c": {}} }, nil, false},
+		{"MapIntKeys", `{"1":2}`, "", func() interface{} { return new(map[int]int) }, nil, false},
+		{"MapNumberKeys", ``, "810102", func() interface{} { return new(map[string]int) }, nil, false},
+		{"Struct", `{"name":"a","COUNT":2,"f32":0.1,"bytes":"AQID","tags":["t"],"child":{"name":"c"},"other":1}`, "", func() interface{} { return new(flat) }, nil, false},
+		{"StructExisting", `{"child":{"count":2}}`, "", func() interface{} { return &flat{Name: "a", Child: &flat{Name: "c"}} }, nil, false},
+		{"StructNullChild", `{"child":null,"name":null}`, "", func() interface{} { return &flat{Name: "a", Child: &flat{}} }, nil, false},
+		{"StructStrict", `{"name":"a","other":1}`, "", func() interface{} { return new(flat) }, []Option{WithStrict()}, true},
+		{"StructWrongType", `{"name":1,"count":2}`, "", func() interface{} { return new(flat) }, nil, true},
+		{"Time", `"2021-02-03T04:05:06Z"`, "", func() interface{} { return new(time.Time) }, nil, false},
+		{"TimeInvalid", `"x"`, "", func() interface{} { return new(time.Time) }, nil, true},
+		{"RawMessage", `{"b":[1,2],"a":null}`, "", func() interface{} { return new(json.RawMessage) }, nil, false},
+		{"Embedded", `{"a":1,"b":2}`, "", func() interface{} {
+			return new(struct {
+				packEmbedded
+				B int `json:"b"`
+			})
+		}, nil, false},
+		{"MaxDepth", `[[[1]]]`, "", func() interface{} { return new(interface{}) }, []Option{WithMaxDepth(2)}, true},
+		{"Truncated", ``, "82a46e616d65a161a5", func() interface{} { return new(flat) }, nil, true},
+		{"TrailingData", ``, "c0c0", func() interface{} { return new(interface{}) }, nil, true},
+		{"NaN", ``, "cb7ff8000000000001", func() interface{} { return new(float64) }, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := []byte(tt.msgpack)
+			if tt.msgpack != "" {
+				b, err := hex.DecodeString(tt.msgpack)
+				if err != nil {
+					t.Fatal(err)
+				}
+				in = b
+			} else {
+				var buf bytes.Buffer
+				if err := MessagePack.FromJSON(&buf, []byte(tt.json)); err != nil {
+					t.Fatal(err)
+				}
+				in = buf.Bytes()
+			}
+
+			// A format that does not implement valueFormat is converted to JSON.
+			converted := struct{ Format }{MessagePack}
+			want := tt.newValue()
+			wantErr := newOptions(append(tt.opts, WithFormat(converted))).decodeFrom(bytes.NewReader(in), want)
+			if (wantErr != nil) != tt.wantErr {
+				t.Fatalf("got conversion error %v, want error %v", wantErr, tt.wantErr)
+			}
+
+			got := tt.newValue()
+			err := newOptions(append(tt.opts, WithFormat(MessagePack))).decodeFrom(bytes.NewReader(in), got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && err.Error() != wantErr.Error() {
+				t.Errorf("got error %v, want %v", err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestMessagePackDecodeValueDirect(t *testing.T) {
+	var buf bytes.Buffer
+	if err := MessagePack.FromJSON(&buf, []byte(`{"data":{"name":"a","tags":["b"]},"page":{"next":"n"},"error":null}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var u rawResponse
+	if err := MessagePack.(valueFormat).decodeValue(buf.Bytes(), &u, newOptions(nil)); err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if got, want := hex.EncodeToString(u.packed), "82a46e616d65a161a47461677391a162"; got != want {
+		t.Errorf("got packed data %v, want %v", got, want)
+	}
+	if got, want := string(u.response().Data.(json.RawMessage)), `{"name":"a","tags":["b"]}`; got != want {
+		t.Errorf("got data %v, want %v", got, want)
+	}
+
+	var v struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+	if err := newOptions(nil).unmarshalResponseData(&u, &v); err != nil {
+		t.Fatalf("failed to unmarshal data: %v", err)
+	}
+	if v.Name != "a" || !reflect.DeepEqual(v.Tags, []string{"b"}) {
+		t.Errorf("got data %+v", v)
+	}
+}
+
+func TestMessagePackRoundTrip(t *testing.T) {
+	in := []packStruct{
+		{Name: "a", Count: 1, Tags: []string{"x"}, Labels: map[string]string{"k": "v"}, Untagged: true},
+		{Name: "b", Child: &packStruct{Name: "c", Any: map[string]interface{}{"s": "x"}}},
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"Default", nil},
+		{"Strict", []Option{WithStrict()}},
+		{"UseNumber", []Option{WithUseNumber()}},
+		{"DuplicateKeys", []Option{WithRejectDuplicateKeys(true)}},
+		{"Canonical", []Option{WithCanonical()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponseMsgpack(rr, in, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			var env Response
+			var out []packStruct
+			opts := append([]Option{WithFormat(MessagePack), WithEnvelope(&env)}, tt.opts...)
+			if err := ReadResponse(rr.Body, &out, opts...); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+
+			if !reflect.DeepEqual(out, in) {
+				t.Errorf("got %+v, want %+v", out, in)
+			}
+			if _, ok := env.Data.(json.RawMessage); !ok {
+				t.Errorf("got envelope data %T, want json.RawMessage", env.Data)
+			}
+		})
+	}
+}
+
+func benchmarkPackData() []packStruct {
+	data := make([]packStruct, 100)
+	for i := range data {
+		data[i] = packStruct{
+			Name:   "name",
+			Count:  i,
+			Ratio:  float64(i) / 3,
+			Tags:   []string{"a", "b", "c"},
+			Labels: map[string]string{"k": "v"},
+		}
+	}
+	return data
+}
+
+func BenchmarkWriteResponseMsgpack(b *testing.B) {
+	data := benchmarkPackData()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"JSON", nil},
+		{"MessagePack", []Option{WithFormat(MessagePack)}},
+		{"MessagePackConverted", []Option{WithFormat(MessagePack), WithCodec(&testCodec{})}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := WriteResponse(httptest.NewRecorder(), data, http.StatusOK, tt.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadResponseMsgpack(b *testing.B) {
+	data := benchmarkPackData()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"JSON", nil},
+		{"MessagePack", []Option{WithFormat(MessagePack)}},
+		{"MessagePackConverted", []Option{WithFormat(MessagePack), WithCodec(&testCodec{})}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, data, http.StatusOK, tt.opts...); err != nil {
+				b.Fatal(err)
+			}
+			body := rr.Body.Bytes()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var out []packStruct
+				if err := ReadResponse(bytes.NewReader(body), &out, tt.opts...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
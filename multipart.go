@@ -0,0 +1,198 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// MultipartMediaType is the media type of responses written by WriteMultipartResponse.
+const MultipartMediaType = "multipart/mixed"
+
+// defaultPartType is the media type of a Part without a ContentType.
+const defaultPartType = "application/octet-stream"
+
+// Part is a binary part of a multipart response, following the JSON response envelope.
+type Part struct {
+	// ID identifies the part, so that it can be referenced from the data of the response, such as
+	// by a "cid:" URL per RFC 2392. It is written as the Content-ID header of the part.
+	ID string
+
+	// ContentType is the media type of the part. If empty, application/octet-stream is used.
+	ContentType string
+
+	// Body is the content of the part. When writing, it is read until EOF, and if nil, the part is
+	// empty. When reading, it is valid until the next part is read.
+	Body io.Reader
+}
+
+// WriteMultipartResponse writes a status code and multipart/mixed response to w, whose first part
+// is the JSON response containing data and pd, followed by parts in order. This allows binary
+// content referenced by the data, such as files, to be returned without a further request. The
+// content of each part is copied as it is read, so the response is not buffered in full. Because
+// the status code is written before the parts are read, an error reading a part results in a
+// truncated response, without a closing boundary, and is returned.
+//
+// Options that apply to the JSON response, such as WithMeta or WithIndent, apply to the first part.
+// Options that apply to the response body as a whole, such as WithETag or WithCompression, have no
+// effect.
+func WriteMultipartResponse(w http.ResponseWriter, data interface{}, pd *PageDetails, parts []Part, code int, opts ...Option) error {
+	o := newOptions(opts)
+	if err := o.ctxErr(); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %w", err)
+	}
+
+	jr, err := o.prepareResponse(Response{Data: data, Page: pd})
+	if err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+
+	if !bodyAllowed(code) {
+		writeNoBody(w, code, o)
+		return nil
+	}
+
+	es := newEncodeState()
+	defer es.release()
+
+	if err := es.encodeResponse(jr, o); err != nil {
+		return writeEncodeFailure(w, err, o)
+	}
+
+	w = o.withWriteDeadline(w)
+
+	cw := &countingWriter{w: w}
+	mw := multipart.NewWriter(cw)
+
+	h := w.Header()
+	h.Del("Content-Length")
+	o.setHeader(h, "Content-Type", mime.FormatMediaType(MultipartMediaType, map[string]string{"boundary": mw.Boundary()}))
+	o.copyHeader(h)
+	w.WriteHeader(code)
+	if o.head {
+		o.observeResponse(code, 0, jr)
+		return nil
+	}
+	defer func() { o.observeResponse(code, cw.n, jr) }()
+
+	pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {o.mediaType()}})
+	if err == nil {
+		_, err = pw.Write(es.Bytes())
+	}
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %w", err)
+	}
+
+	for _, p := range parts {
+		if err := writePart(mw, p); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %w", err)
+	}
+	return nil
+}
+
+// writePart writes p to mw.
+func writePart(mw *multipart.Writer, p Part) error {
+	ct := p.ContentType
+	if ct == "" {
+		ct = defaultPartType
+	}
+	ph := textproto.MIMEHeader{"Content-Type": {ct}}
+	if p.ID != "" {
+		ph.Set("Content-Id", "<"+p.ID+">")
+	}
+
+	pw, err := mw.CreatePart(ph)
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %w", err)
+	}
+	if p.Body == nil {
+		return nil
+	}
+
+	// Errors reading the part are distinguished from those writing the response.
+	er := &errReader{r: p.Body}
+	if _, err := io.Copy(pw, er); er.err != nil {
+		return fmt.Errorf("jsonresp: failed to read part %q: %w", p.ID, er.err)
+	} else if err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %w", err)
+	}
+	return nil
+}
+
+// PartReader reads the binary parts of a multipart response read by ReadMultipartResponse.
+type PartReader struct {
+	mr *multipart.Reader // nil if the response is not multipart
+}
+
+// NextPart returns the next part of the response. When there are no more parts, io.EOF is
+// returned. The Body of the part is valid until NextPart is called again.
+func (pr *PartReader) NextPart() (*Part, error) {
+	if pr.mr == nil {
+		return nil, io.EOF
+	}
+
+	p, err := pr.mr.NextPart()
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("jsonresp: failed to read part: %w", err)
+	}
+
+	ct := p.Header.Get("Content-Type")
+	if ct == "" {
+		ct = defaultPartType
+	}
+	return &Part{
+		ID:          strings.TrimSuffix(strings.TrimPrefix(p.Header.Get("Content-Id"), "<"), ">"),
+		ContentType: ct,
+		Body:        p,
+	}, nil
+}
+
+// ReadMultipartResponse reads a multipart response with media type contentType from r, such as
+// written by WriteMultipartResponse, and unmarshals the data of the JSON response in its first
+// part into v, in the same way as ReadResponsePage. The parts that follow are read from the
+// returned PartReader, which reads from r.
+//
+// If contentType is not a multipart media type, r is read as a JSON response, so that errors
+// written by functions such as WriteError are returned, and the PartReader has no parts.
+func ReadMultipartResponse(r io.Reader, contentType string, v interface{}, opts ...Option) (*PageDetails, *PartReader, error) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mt, "multipart/") {
+		pd, err := ReadResponsePage(r, v, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pd, &PartReader{}, nil
+	}
+
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, nil, errors.New("jsonresp: failed to read response: missing multipart boundary")
+	}
+	mr := multipart.NewReader(r, boundary)
+
+	p, err := mr.NextPart()
+	if err != nil {
+		return nil, nil, fmt.Errorf("jsonresp: failed to read response: %w", err)
+	}
+	pd, err := ReadResponsePage(p, v, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pd, &PartReader{mr: mr}, nil
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMultipartResponse(t *testing.T) {
+	type Artifact struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+
+	tests := []struct {
+		name      string
+		data      interface{}
+		pd        *PageDetails
+		parts     []Part
+		wantParts []Part
+	}{
+		{
+			name: "NoParts",
+			data: []Artifact{},
+		},
+		{
+			name: "Parts",
+			data: []Artifact{{"a", "cid:a"}, {"b", "cid:b"}},
+			pd:   &PageDetails{Next: "n"},
+			parts: []Part{
+				{ID: "a", ContentType: "text/plain", Body: strings.NewReader("blah")},
+				{ID: "b", Body: strings.NewReader("\x00\x01\x02")},
+				{},
+			},
+			wantParts: []Part{
+				{ID: "a", ContentType: "text/plain", Body: strings.NewReader("blah")},
+				{ID: "b", ContentType: "application/octet-stream", Body: strings.NewReader("\x00\x01\x02")},
+				{ContentType: "application/octet-stream", Body: strings.NewReader("")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			if err := WriteMultipartResponse(rr, tt.data, tt.pd, tt.parts, http.StatusOK, WithMeta("a", "b")); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			ct := rr.Header().Get("Content-Type")
+			if mt, _, err := mime.ParseMediaType(ct); err != nil || mt != MultipartMediaType {
+				t.Fatalf("got content type %q, want %v", ct, MultipartMediaType)
+			}
+
+			var jr Response
+			var data []Artifact
+			pd, pr, err := ReadMultipartResponse(rr.Body, ct, &data, WithEnvelope(&jr))
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := len(data), len(tt.data.([]Artifact)); got != want {
+				t.Errorf("got %v items, want %v", got, want)
+			}
+			if got, want := pd != nil, tt.pd != nil; got != want {
+				t.Errorf("got page %v, want %v", pd, tt.pd)
+			}
+			if got, want := jr.Meta["a"], "b"; got != want {
+				t.Errorf("got meta %v, want %v", got, want)
+			}
+
+			for i := 0; ; i++ {
+				p, err := pr.NextPart()
+				if errors.Is(err, io.EOF) {
+					if got, want := i, len(tt.wantParts); got != want {
+						t.Errorf("got %v parts, want %v", got, want)
+					}
+					break
+				}
+				if err != nil {
+					t.Fatalf("failed to read part: %v", err)
+				}
+				if i >= len(tt.wantParts) {
+					t.Fatalf("got unexpected part %v", i)
+				}
+
+				want := tt.wantParts[i]
+				if got, want := p.ID, want.ID; got != want {
+					t.Errorf("got ID %q, want %q", got, want)
+				}
+				if got, want := p.ContentType, want.ContentType; got != want {
+					t.Errorf("got content type %q, want %q", got, want)
+				}
+				b, err := io.ReadAll(p.Body)
+				if err != nil {
+					t.Fatalf("failed to read part: %v", err)
+				}
+				wb, _ := io.ReadAll(want.Body)
+				if got, want := string(b), string(wb); got != want {
+					t.Errorf("got body %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteMultipartResponseReadError(t *testing.T) {
+	errRead := errors.New("read failed")
+
+	rr := httptest.NewRecorder()
+	parts := []Part{{ID: "a", Body: &failingReader{data: "bl", err: errRead}}}
+
+	err := WriteMultipartResponse(rr, "blah", nil, parts, http.StatusOK)
+	if !errors.Is(err, errRead) {
+		t.Fatalf("got error %v, want %v", err, errRead)
+	}
+
+	// The response is truncated, so reading the part fails.
+	_, pr, err := ReadMultipartResponse(rr.Body, rr.Header().Get("Content-Type"), nil)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	p, err := pr.NextPart()
+	if err != nil {
+		t.Fatalf("failed to read part: %v", err)
+	}
+	if _, err := io.ReadAll(p.Body); err == nil {
+		t.Errorf("got no error reading truncated part")
+	}
+}
+
+func TestReadMultipartResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     bool
+		wantCode    int
+	}{
+		{"JSON", "application/json", `{"data":"blah"}`, false, 0},
+		{"Error", "application/json", `{"error":{"code":404,"message":"blah"}}`, true, http.StatusNotFound},
+		{"InvalidContentType", "", `{"data":"blah"}`, false, 0},
+		{"NoBoundary", "multipart/mixed", "", true, 0},
+		{"NoParts", "multipart/mixed; boundary=x", "--x--\r\n", true, 0},
+		{"MultipartError", "multipart/mixed; boundary=x", "--x\r\nContent-Type: application/json\r\n\r\n" +
+			`{"error":{"code":404,"message":"blah"}}` + "\r\n--x--\r\n", true, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var s string
+			_, pr, err := ReadMultipartResponse(strings.NewReader(tt.body), tt.contentType, &s)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v, want %v", err, want)
+			}
+			if err != nil {
+				var je *Error
+				if got, want := errors.As(err, &je), tt.wantCode != 0; got != want {
+					t.Fatalf("got error %v, want Error %v", err, want)
+				}
+				if je != nil && je.Code != tt.wantCode {
+					t.Errorf("got code %v, want %v", je.Code, tt.wantCode)
+				}
+				return
+			}
+
+			if got, want := s, "blah"; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+			if _, err := pr.NextPart(); !errors.Is(err, io.EOF) {
+				t.Errorf("got error %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestWriteMultipartResponseNoBody(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	if err := WriteMultipartResponse(rr, nil, nil, []Part{{ID: "a"}}, http.StatusNoContent); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Code, http.StatusNoContent; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Body.Len(), 0; got != want {
+		t.Errorf("got body length %v, want %v", got, want)
+	}
+}
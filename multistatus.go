@@ -0,0 +1,121 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ItemResult is the result of one item of a bulk operation. A successful result has data, and an
+// unsuccessful result has an error.
+type ItemResult struct {
+	ID    string      `json:"id,omitempty"`
+	Code  int         `json:"code"`
+	Data  interface{} `json:"data,omitempty"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+// Err returns the error of result, or nil if the item succeeded.
+func (ir ItemResult) Err() error {
+	if ir.Error == nil {
+		return nil
+	}
+	return ir.Error
+}
+
+// Decode unmarshals the data of a result read by ReadMultiStatus into v. If
+// the item failed, its error is returned instead.
+func (ir ItemResult) Decode(v interface{}, opts ...Option) error {
+	if err := ir.Err(); err != nil {
+		return err
+	}
+
+	b, ok := ir.Data.(json.RawMessage)
+	if !ok || v == nil {
+		return nil
+	}
+	if err := newOptions(opts).unmarshalData(b, v); err != nil {
+		return fmt.Errorf("jsonresp: failed to unmarshal result: %w", err)
+	}
+	return nil
+}
+
+// MultiResponse describes the per-item results of a bulk operation, some of which may have
+// failed.
+type MultiResponse struct {
+	Results []ItemResult `json:"results"`
+}
+
+// AddResult appends a successful result containing data and status code code for the item
+// identified by id.
+func (mr *MultiResponse) AddResult(id string, data interface{}, code int) {
+	mr.Results = append(mr.Results, ItemResult{
+		ID:   id,
+		Code: code,
+		Data: data,
+	})
+}
+
+// AddError appends an unsuccessful result describing err for the item identified by id. The
+// status code and error are determined in the same way as WriteErr, and are sanitized if
+// production mode is enabled.
+func (mr *MultiResponse) AddError(id string, err error) {
+	je := NewError("", http.StatusInternalServerError)
+	if err != nil {
+		je = sanitize(errorFor(err), err)
+	}
+
+	mr.Results = append(mr.Results, ItemResult{
+		ID:    id,
+		Code:  je.Code,
+		Error: je,
+	})
+}
+
+// WriteMultiStatus writes a 207 status code and JSON response containing mr to w.
+func WriteMultiStatus(w http.ResponseWriter, mr MultiResponse, opts ...Option) error {
+	if mr.Results == nil {
+		mr.Results = []ItemResult{}
+	}
+	return WriteResponsePage(w, mr, nil, http.StatusMultiStatus, opts...)
+}
+
+// ReadMultiStatus reads a JSON response containing per-item results, as written by
+// WriteMultiStatus. The data of each successful result, if present, is of type json.RawMessage,
+// which can be unmarshalled by the caller. If the response contains an error rather than results,
+// the error is returned.
+func ReadMultiStatus(r io.Reader, opts ...Option) (MultiResponse, error) {
+	var u struct {
+		Results []struct {
+			ID    string          `json:"id"`
+			Code  int             `json:"code"`
+			Data  json.RawMessage `json:"data"`
+			Error *Error          `json:"error"`
+		} `json:"results"`
+	}
+	if err := ReadResponse(r, &u, opts...); err != nil {
+		return MultiResponse{}, err
+	}
+
+	mr := MultiResponse{
+		Results: make([]ItemResult, 0, len(u.Results)),
+	}
+	for _, ur := range u.Results {
+		ir := ItemResult{
+			ID:    ur.ID,
+			Code:  ur.Code,
+			Error: ur.Error,
+		}
+		if len(ur.Data) > 0 {
+			ir.Data = ur.Data
+		}
+		mr.Results = append(mr.Results, ir)
+	}
+	return mr, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteMultiStatus(t *testing.T) {
+	var mr MultiResponse
+	mr.AddResult("1", "blah", http.StatusCreated)
+	mr.AddError("2", NewError("conflict", http.StatusConflict))
+	mr.AddError("3", errors.New("boom"))
+	mr.AddError("4", nil)
+
+	rr := httptest.NewRecorder()
+	if err := WriteMultiStatus(rr, mr); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if got, want := rr.Code, http.StatusMultiStatus; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+
+	got, err := ReadMultiStatus(rr.Body)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+
+	tests := []struct {
+		id       string
+		code     int
+		wantData string
+		wantErr  error
+	}{
+		{"1", http.StatusCreated, `"blah"`, nil},
+		{"2", http.StatusConflict, "", NewError("conflict", http.StatusConflict)},
+		{"3", http.StatusInternalServerError, "", &Error{Code: http.StatusInternalServerError}},
+		{"4", http.StatusInternalServerError, "", &Error{Code: http.StatusInternalServerError}},
+	}
+	if len(got.Results) != len(tests) {
+		t.Fatalf("got %v results, want %v", len(got.Results), len(tests))
+	}
+	for i, tt := range tests {
+		ir := got.Results[i]
+		if ir.ID != tt.id {
+			t.Errorf("result %v: got ID %q, want %q", i, ir.ID, tt.id)
+		}
+		if ir.Code != tt.code {
+			t.Errorf("result %v: got code %v, want %v", i, ir.Code, tt.code)
+		}
+		if tt.wantData != "" {
+			if got, ok := ir.Data.(json.RawMessage); !ok || string(got) != tt.wantData {
+				t.Errorf("result %v: got data %v, want %v", i, ir.Data, tt.wantData)
+			}
+		} else if ir.Data != nil {
+			t.Errorf("result %v: got data %v, want none", i, ir.Data)
+		}
+		if tt.wantErr == nil {
+			if err := ir.Err(); err != nil {
+				t.Errorf("result %v: unexpected error %v", i, err)
+			}
+		} else if err := ir.Err(); !errors.Is(err, tt.wantErr) {
+			t.Errorf("result %v: got error %v, want %v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteMultiStatusEmpty(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteMultiStatus(rr, MultiResponse{}); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"data":{"results":[]}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestReadMultiStatusError(t *testing.T) {
+	r := strings.NewReader(`{"error":{"code":400,"message":"blah"}}`)
+	if _, err := ReadMultiStatus(r); !errors.Is(err, NewError("blah", http.StatusBadRequest)) {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	formatsMu sync.RWMutex
+	formats   = []Format{JSON}
+)
+
+// RegisterFormat makes f available to content negotiation. JSON is always available. When a
+// client has no preference between formats, they are preferred in the order they were registered,
+// with JSON first.
+func RegisterFormat(f Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats = append(formats, f)
+}
+
+// errorOnlyFormat is implemented by formats that are only suitable for error responses.
+type errorOnlyFormat interface {
+	errorOnly()
+}
+
+// registeredFormats returns the formats available to content negotiation. If isError is false,
+// formats only suitable for error responses are omitted.
+func registeredFormats(isError bool) []Format {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+
+	fs := make([]Format, 0, len(formats))
+	for _, f := range formats {
+		if _, ok := f.(errorOnlyFormat); ok && !isError {
+			continue
+		}
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// mediaRange is a media range from an Accept header.
+type mediaRange struct {
+	typ     string
+	subtype string
+	q       float64
+	version string // Value of the envelope version parameter, if any.
+}
+
+// parseAccept parses the media ranges in the Accept header value v. Invalid ranges are ignored.
+func parseAccept(v string) []mediaRange {
+	var mrs []mediaRange
+	for _, s := range strings.Split(v, ",") {
+		mt, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mt, "/")
+		if !ok || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		mrs = append(mrs, mediaRange{typ, subtype, q, params[versionParam]})
+	}
+	return mrs
+}
+
+// quality returns the quality assigned to media type mt by the most specific of mrs that matches
+// it, or zero if none match.
+func quality(mrs []mediaRange, mt string) float64 {
+	base, _, err := mime.ParseMediaType(mt)
+	if err != nil {
+		return 0
+	}
+	typ, subtype, _ := strings.Cut(base, "/")
+
+	q, specificity := 0.0, -1
+	for _, mr := range mrs {
+		var s int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// negotiate returns the format from fs that best satisfies the Accept header value accept. If
+// accept is empty, the first format is returned.
+func negotiate(fs []Format, accept string) (Format, bool) { //nolint:ireturn
+	if strings.TrimSpace(accept) == "" {
+		return fs[0], true
+	}
+
+	mrs := parseAccept(accept)
+
+	var best Format
+	bestQ := 0.0
+	for _, f := range fs {
+		if q := quality(mrs, f.ContentType()); q > bestQ {
+			best, bestQ = f, q
+		}
+	}
+	return best, best != nil
+}
+
+// contentTypes returns the media types of fs.
+func contentTypes(fs []Format) []string {
+	cts := make([]string, 0, len(fs))
+	for _, f := range fs {
+		cts = append(cts, f.ContentType())
+	}
+	return cts
+}
+
+// notAcceptable returns an Error with a 406 status code, listing the supported media types cts.
+func notAcceptable(cts []string) *Error {
+	return &Error{
+		Code:    http.StatusNotAcceptable,
+		Message: "none of the supported media types are acceptable: " + strings.Join(cts, ", "),
+		Details: map[string]interface{}{"supported": cts},
+	}
+}
+
+// acceptable reports whether any of the media types cts satisfy the Accept header value accept.
+func acceptable(cts []string, accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+
+	mrs := parseAccept(accept)
+	for _, ct := range cts {
+		if quality(mrs, ct) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAccept returns middleware that rejects requests whose Accept header is not satisfied by
+// any of the media types types, with a 406 status code and JSON error listing them, before the
+// handler is invoked. If types is empty, the media types of the formats available to content
+// negotiation are used, as by WriteNegotiated. Requests without an Accept header are accepted.
+func RequireAccept(types ...string) func(http.Handler) http.Handler {
+	cts := append([]string(nil), types...)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept")
+
+			cts := cts
+			if len(cts) == 0 {
+				cts = contentTypes(registeredFormats(false))
+			}
+			if !acceptable(cts, r.Header.Get("Accept")) {
+				_ = writeError(w, notAcceptable(cts), nil, newOptions([]Option{WithRequest(r), WithRequestID(r)}))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteNegotiated writes a status code and response containing data to w, in the registered
+// format best satisfying the Accept header of r. If none of the registered formats are
+// acceptable, a 406 status code and JSON error listing the supported media types is written
+// instead.
+func WriteNegotiated(w http.ResponseWriter, r *http.Request, data interface{}, code int, opts ...Option) error {
+	return WriteNegotiatedPage(w, r, data, nil, code, opts...)
+}
+
+// WriteNegotiatedPage writes a status code and response containing data and pd to w, in the
+// registered format best satisfying the Accept header of r. If none of the registered formats are
+// acceptable, a 406 status code and JSON error listing the supported media types is written
+// instead.
+func WriteNegotiatedPage(w http.ResponseWriter, r *http.Request, data interface{}, pd *PageDetails, code int, opts ...Option) error {
+	addVary(w.Header(), "Accept")
+	opts = append([]Option{WithRequest(r)}, opts...)
+
+	fs := registeredFormats(false)
+	f, ok := negotiate(fs, r.Header.Get("Accept"))
+	if !ok {
+		return writeError(w, notAcceptable(contentTypes(fs)), nil, newOptions(opts))
+	}
+
+	jr := Response{
+		Data: data,
+		Page: pd,
+	}
+	return encodeResponse(w, jr, code, newOptions(append([]Option{WithFormat(f)}, opts...)))
+}
+
+// WriteNegotiatedErr writes a status code and response describing err to w, in the same way as
+// WriteErr, in the registered format best satisfying the Accept header of r. If none of the
+// registered formats are acceptable, the error is written as JSON.
+func WriteNegotiatedErr(w http.ResponseWriter, r *http.Request, err error, opts ...Option) error {
+	addVary(w.Header(), "Accept")
+	opts = append([]Option{WithRequest(r), WithRequestID(r)}, opts...)
+
+	if f, ok := negotiate(registeredFormats(true), r.Header.Get("Accept")); ok {
+		opts = append([]Option{WithFormat(f)}, opts...)
+	}
+
+	if err == nil {
+		return writeError(w, NewError("", http.StatusInternalServerError), nil, newOptions(opts))
+	}
+	return writeError(w, errorFor(err), err, newOptions(opts))
+}
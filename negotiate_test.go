@@ -0,0 +1,219 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// withFormats registers fs for the duration of a test.
+func withFormats(t *testing.T, fs ...Format) {
+	t.Helper()
+
+	formatsMu.Lock()
+	old := formats
+	formats = append([]Format{JSON}, fs...)
+	formatsMu.Unlock()
+
+	t.Cleanup(func() {
+		formatsMu.Lock()
+		formats = old
+		formatsMu.Unlock()
+	})
+}
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		name string
+		v    string
+		want []mediaRange
+	}{
+		{"Empty", "", nil},
+		{"Single", "application/json", []mediaRange{{"application", "json", 1, ""}}},
+		{"Quality", "application/xml;q=0.5, */*;q=0.1", []mediaRange{{"application", "xml", 0.5, ""}, {"*", "*", 0.1, ""}}},
+		{"Params", "text/html; level=1", []mediaRange{{"text", "html", 1, ""}}},
+		{"Version", "application/json; v=2", []mediaRange{{"application", "json", 1, "2"}}},
+		{"Invalid", "application, */json, text/*;q=x, application/cbor", []mediaRange{{"application", "cbor", 1, ""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := parseAccept(tt.v), tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	fs := []Format{JSON, MessagePack, XML}
+
+	tests := []struct {
+		name   string
+		accept string
+		want   Format
+		wantOK bool
+	}{
+		{"None", "", JSON, true},
+		{"Wildcard", "*/*", JSON, true},
+		{"Exact", "application/msgpack", MessagePack, true},
+		{"TypeWildcard", "application/*", JSON, true},
+		{"Quality", "application/json;q=0.5, application/xml", XML, true},
+		{"Specificity", "*/*, application/json;q=0", MessagePack, true},
+		{"Params", "application/xml; charset=utf-8", XML, true},
+		{"NotAcceptable", "text/html", nil, false},
+		{"Zero", "application/json;q=0", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := negotiate(fs, tt.accept)
+			if ok != tt.wantOK {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if f != tt.want {
+				t.Errorf("got format %v, want %v", f, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteNegotiated(t *testing.T) {
+	withFormats(t, MessagePack, ProblemJSON)
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantCode        int
+		wantContentType string
+	}{
+		{"Default", "", http.StatusOK, "application/json"},
+		{"MessagePack", "application/msgpack", http.StatusOK, "application/msgpack"},
+		{"ProblemNotSelected", "application/problem+json", http.StatusNotAcceptable, "application/json"},
+		{"NotAcceptable", "text/html", http.StatusNotAcceptable, "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			if err := WriteNegotiated(rr, r, "blah", http.StatusOK); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Vary"), "Accept"; got != want {
+				t.Errorf("got vary %q, want %q", got, want)
+			}
+
+			if tt.wantCode == http.StatusNotAcceptable {
+				var je *Error
+				if !errors.As(ReadError(rr.Body), &je) {
+					t.Fatalf("failed to read error")
+				}
+				if got, want := je.Details["supported"], []interface{}{"application/json", "application/msgpack"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("got supported %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteNegotiatedErr(t *testing.T) {
+	withFormats(t, ProblemJSON)
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{"Default", "", "application/json", `{"error":{"code":404,"message":"blah"}}`},
+		{"Problem", "application/problem+json", "application/problem+json", `{"type":"about:blank","title":"Not Found","status":404,"detail":"blah"}`},
+		{"NotAcceptable", "text/html", "application/json", `{"error":{"code":404,"message":"blah"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			if err := WriteNegotiatedErr(rr, r, NewError("blah", http.StatusNotFound)); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, http.StatusNotFound; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRequireAccept(t *testing.T) {
+	withFormats(t, MessagePack)
+
+	tests := []struct {
+		name          string
+		types         []string
+		accept        string
+		wantCode      int
+		wantSupported []interface{}
+	}{
+		{"NoAccept", []string{"application/json"}, "", http.StatusOK, nil},
+		{"Exact", []string{"application/json"}, "application/json", http.StatusOK, nil},
+		{"Wildcard", []string{"application/json"}, "*/*", http.StatusOK, nil},
+		{"SubtypeWildcard", []string{"application/json"}, "application/*", http.StatusOK, nil},
+		{"Parameters", []string{"application/vnd.example+json; v=2"}, "application/vnd.example+json", http.StatusOK, nil},
+		{"ZeroQuality", []string{"application/json"}, "application/json;q=0, text/html", http.StatusNotAcceptable, []interface{}{"application/json"}},
+		{"NotAcceptable", []string{"application/json", "text/csv"}, "text/html", http.StatusNotAcceptable, []interface{}{"application/json", "text/csv"}},
+		{"Registered", nil, "application/msgpack", http.StatusOK, nil},
+		{"RegisteredNotAcceptable", nil, "text/html", http.StatusNotAcceptable, []interface{}{"application/json", "application/msgpack"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			h := RequireAccept(tt.types...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			h.ServeHTTP(rr, r)
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Vary"), "Accept"; got != want {
+				t.Errorf("got vary %q, want %q", got, want)
+			}
+
+			if tt.wantCode == http.StatusNotAcceptable {
+				var je *Error
+				if !errors.As(ReadError(rr.Body), &je) {
+					t.Fatalf("failed to read error")
+				}
+				if got, want := je.Details["supported"], tt.wantSupported; !reflect.DeepEqual(got, want) {
+					t.Errorf("got supported %v, want %v", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import "reflect"
+
+// NullFormat specifies how nil and empty slices and maps within the data of a response are
+// encoded.
+type NullFormat int
+
+const (
+	// NullDefault encodes nil slices and maps as null, and empty slices and maps as [] and {}, as
+	// encoding/json does.
+	NullDefault NullFormat = iota
+
+	// NullAsEmpty encodes nil slices and maps as [] and {}, so that clients expecting a
+	// collection need not check for null.
+	NullAsEmpty
+
+	// EmptyAsNull encodes empty slices and maps, whether nil or not, as null.
+	EmptyAsNull
+)
+
+// WithNullFormat causes nil and empty slices and maps within the data of a successful response,
+// including those nested within structs, slices and maps, to be encoded in the format f. Byte
+// slices, which are encoded as strings, and values encoded by their own MarshalJSON method are not
+// affected, nor are fields omitted by the omitempty option. When used with WithCodec or
+// WithEncoder, the encoding produced by the codec is rewritten, so the codec must produce JSON.
+func WithNullFormat(f NullFormat) Option {
+	return func(o *options) {
+		o.nullFormat = f
+	}
+}
+
+var (
+	nullJSON        = []byte("null")
+	emptyArrayJSON  = []byte("[]")
+	emptyObjectJSON = []byte("{}")
+)
+
+// formatNullValue returns the encoding of v in the format established by o, if v is a slice or
+// map that is not encoded by default.
+func (o *options) formatNullValue(v reflect.Value) ([]byte, bool) {
+	switch o.nullFormat {
+	case NullAsEmpty:
+		if !v.IsNil() {
+			return nil, false
+		}
+		if v.Kind() == reflect.Map {
+			return emptyObjectJSON, true
+		}
+		return emptyArrayJSON, true
+	case EmptyAsNull:
+		return nullJSON, v.Len() == 0
+	}
+	return nil, false
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithNullFormat(t *testing.T) {
+	type child struct {
+		Tags []string `json:"tags"`
+	}
+	type thing struct {
+		Tags     []string          `json:"tags"`
+		Labels   map[string]string `json:"labels"`
+		Counts   map[int]int       `json:"counts"`
+		Children []child           `json:"children"`
+		Optional []string          `json:"optional,omitempty"`
+		Raw      json.RawMessage   `json:"raw"`
+		Bytes    []byte            `json:"bytes"`
+		Array    [0]int            `json:"array"`
+		Ptr      *[]string         `json:"ptr"`
+	}
+
+	nilThing := thing{}
+	emptyThing := thing{
+		Tags:     []string{},
+		Labels:   map[string]string{},
+		Counts:   map[int]int{},
+		Children: []child{{Tags: []string{}}},
+		Raw:      json.RawMessage("[]"),
+		Bytes:    []byte{},
+		Ptr:      &[]string{},
+	}
+
+	tests := []struct {
+		name string
+		data interface{}
+		opts []Option
+		want string
+	}{
+		{"DefaultNil", nilThing, nil,
+			`{"data":{"tags":null,"labels":null,"counts":null,"children":null,"raw":null,"bytes":null,"array":[],"ptr":null}}`},
+		{"DefaultEmpty", emptyThing, nil,
+			`{"data":{"tags":[],"labels":{},"counts":{},"children":[{"tags":[]}],"raw":[],"bytes":"","array":[],"ptr":[]}}`},
+		{"NullAsEmptyNil", nilThing, []Option{WithNullFormat(NullAsEmpty)},
+			`{"data":{"tags":[],"labels":{},"counts":{},"children":[],"raw":null,"bytes":null,"array":[],"ptr":null}}`},
+		{"NullAsEmptyEmpty", emptyThing, []Option{WithNullFormat(NullAsEmpty)},
+			`{"data":{"tags":[],"labels":{},"counts":{},"children":[{"tags":[]}],"raw":[],"bytes":"","array":[],"ptr":[]}}`},
+		{"NullAsEmptyNested", []child{{}}, []Option{WithNullFormat(NullAsEmpty)},
+			`{"data":[{"tags":[]}]}`},
+		{"NullAsEmptyTop", []string(nil), []Option{WithNullFormat(NullAsEmpty)},
+			`{"data":[]}`},
+		{"NullAsEmptyTopMap", map[string]interface{}(nil), []Option{WithNullFormat(NullAsEmpty)},
+			`{"data":{}}`},
+		{"NullAsEmptyInterface", map[string]interface{}{"a": []int(nil)}, []Option{WithNullFormat(NullAsEmpty)},
+			`{"data":{"a":[]}}`},
+		{"EmptyAsNullNil", nilThing, []Option{WithNullFormat(EmptyAsNull)},
+			`{"data":{"tags":null,"labels":null,"counts":null,"children":null,"raw":null,"bytes":null,"array":[],"ptr":null}}`},
+		{"EmptyAsNullEmpty", emptyThing, []Option{WithNullFormat(EmptyAsNull)},
+			`{"data":{"tags":null,"labels":null,"counts":null,"children":[{"tags":null}],"raw":[],"bytes":"","array":[],"ptr":null}}`},
+		{"EmptyAsNullTop", []string{}, []Option{WithNullFormat(EmptyAsNull)},
+			`{"data":null}`},
+		{"Fields", nilThing, []Option{WithNullFormat(NullAsEmpty), WithFields(FieldSet{"tags": nil})},
+			`{"data":{"tags":[]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, tt.data, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("got body %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
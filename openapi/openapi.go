@@ -0,0 +1,238 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package openapi generates OpenAPI 3 schema components describing responses written by the
+// jsonresp package. A program run by go:generate can use a Generator to keep API documentation in
+// sync with the wire format:
+//
+//	g := openapi.NewGenerator()
+//	g.Envelope("ThingResponse", Thing{})
+//	g.Envelope("ThingListResponse", []Thing{})
+//	if err := g.WriteComponents(os.Stdout); err != nil {
+//		log.Fatal(err)
+//	}
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// Schema is an OpenAPI 3 Schema Object. Only the fields required to describe Go types are
+// supported.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// refPrefix is the prefix of references to schema components.
+const refPrefix = "#/components/schemas/"
+
+// Generator generates schema components for Go types. Named struct types are described by
+// components named after the type, and referenced where they are used.
+type Generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// NewGenerator returns a Generator with components describing the Response, Error and
+// PageDetails types of the jsonresp package, and the types they contain.
+func NewGenerator() *Generator {
+	g := &Generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+	g.Schema(jsonresp.Response{})
+	return g
+}
+
+// Envelope adds a component with the supplied name describing a response envelope containing
+// data, and returns a reference to it. The data field of the component is described by the schema
+// of the type of data, and the remaining fields are those of Response.
+func (g *Generator) Envelope(name string, data interface{}) *Schema {
+	env := *g.schemas["Response"]
+	env.Properties = make(map[string]*Schema, len(env.Properties))
+	for k, v := range g.schemas["Response"].Properties {
+		env.Properties[k] = v
+	}
+	env.Properties["data"] = g.Schema(data)
+
+	g.schemas[name] = &env
+	return &Schema{Ref: refPrefix + name}
+}
+
+// Schema returns the schema of the type of v, adding components for any named struct types it
+// contains. A nil v is described by an empty schema, which permits any value.
+func (g *Generator) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return g.schema(reflect.TypeOf(v))
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+	// pageDetailsType and errorType are encoded according to their fields, despite implementing
+	// json.Marshaler.
+	pageDetailsType = reflect.TypeOf(jsonresp.PageDetails{})
+	errorType       = reflect.TypeOf(jsonresp.Error{})
+)
+
+func (g *Generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t == pageDetailsType, t == errorType:
+		return g.ref(t)
+	case t.Implements(jsonMarshalerType), reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// The encoding is not known.
+		return &Schema{}
+	case t.Implements(textMarshalerType), reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// ref returns a reference to the component describing the named struct type t, adding it if
+// necessary.
+func (g *Generator) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.schemas[name]; taken {
+			// Disambiguate types of the same name from different packages.
+			pkg := t.PkgPath()
+			pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		g.names[t] = name
+
+		// Reserve the name before describing the type, to support recursive types.
+		s := &Schema{}
+		g.schemas[name] = s
+		*s = *g.structSchema(t)
+	}
+	return &Schema{Ref: refPrefix + name}
+}
+
+// structSchema returns the schema of the struct type t.
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addFields(s, t)
+	return s
+}
+
+// addFields adds the fields of struct type t to s, following the rules of encoding/json.
+func (g *Generator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := g.schema(f.Type)
+		if strings.Contains(","+opts+",", ",string,") {
+			fs = &Schema{Type: "string"}
+		}
+		s.Properties[name] = fs
+		if !strings.Contains(","+opts+",", ",omitempty,") && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// Components returns the schema components generated, keyed by name.
+func (g *Generator) Components() map[string]*Schema {
+	return g.schemas
+}
+
+// WriteComponents writes an OpenAPI 3 document fragment containing the schema components
+// generated to w, as indented JSON.
+func (g *Generator) WriteComponents(w io.Writer) error {
+	doc := map[string]interface{}{
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("openapi: failed to encode components: %v", err)
+	}
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("openapi: failed to write components: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type thing struct {
+	ID       string            `json:"id"`
+	Count    int               `json:"count,omitempty"`
+	Size     int64             `json:"size,string"`
+	Ratio    float64           `json:"ratio"`
+	OK       bool              `json:"ok"`
+	Created  time.Time         `json:"created"`
+	Blob     []byte            `json:"blob,omitempty"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Parent   *thing            `json:"parent"`
+	Ignored  string            `json:"-"`
+	Untagged string
+	internal string //nolint:unused
+	embedded
+}
+
+type embedded struct {
+	Extra string `json:"extra"`
+}
+
+func TestSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want *Schema
+	}{
+		{"Nil", nil, &Schema{}},
+		{"Bool", true, &Schema{Type: "boolean"}},
+		{"Int32", int32(1), &Schema{Type: "integer", Format: "int32"}},
+		{"Int", 1, &Schema{Type: "integer", Format: "int64"}},
+		{"Float32", float32(1), &Schema{Type: "number", Format: "float"}},
+		{"Float64", 1.0, &Schema{Type: "number", Format: "double"}},
+		{"String", "", &Schema{Type: "string"}},
+		{"Time", time.Time{}, &Schema{Type: "string", Format: "date-time"}},
+		{"Bytes", []byte{}, &Schema{Type: "string", Format: "byte"}},
+		{"RawMessage", json.RawMessage{}, &Schema{}},
+		{"Slice", []int{}, &Schema{Type: "array", Items: &Schema{Type: "integer", Format: "int64"}}},
+		{"Map", map[string]bool{}, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "boolean"}}},
+		{"Interface", []interface{}{}, &Schema{Type: "array", Items: &Schema{}}},
+		{"Pointer", new(string), &Schema{Type: "string"}},
+		{"Struct", thing{}, &Schema{Ref: "#/components/schemas/thing"}},
+		{"StructSlice", []*thing{}, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/thing"}}},
+		{"AnonymousStruct", struct {
+			A string `json:"a"`
+		}{}, &Schema{Type: "object", Properties: map[string]*Schema{"a": {Type: "string"}}, Required: []string{"a"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewGenerator().Schema(tt.v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got schema %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaStruct(t *testing.T) {
+	g := NewGenerator()
+	g.Schema(thing{})
+
+	want := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":       {Type: "string"},
+			"count":    {Type: "integer", Format: "int64"},
+			"size":     {Type: "string"},
+			"ratio":    {Type: "number", Format: "double"},
+			"ok":       {Type: "boolean"},
+			"created":  {Type: "string", Format: "date-time"},
+			"blob":     {Type: "string", Format: "byte"},
+			"tags":     {Type: "array", Items: &Schema{Type: "string"}},
+			"labels":   {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"parent":   {Ref: "#/components/schemas/thing"},
+			"Untagged": {Type: "string"},
+			"extra":    {Type: "string"},
+		},
+		Required: []string{"id", "size", "ratio", "ok", "created", "tags", "Untagged", "extra"},
+	}
+	if got := g.Components()["thing"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got schema %+v, want %+v", got, want)
+	}
+}
+
+func TestNewGenerator(t *testing.T) {
+	c := NewGenerator().Components()
+
+	for _, name := range []string{"Response", "Error", "PageDetails", "Warning", "Link"} {
+		if _, ok := c[name]; !ok {
+			t.Errorf("missing component %v", name)
+		}
+	}
+
+	if got, want := c["Response"].Properties["page"], (&Schema{Ref: "#/components/schemas/PageDetails"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got page schema %+v, want %+v", got, want)
+	}
+	if got, want := c["Response"].Properties["error"], (&Schema{Ref: "#/components/schemas/Error"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got error schema %+v, want %+v", got, want)
+	}
+	if got, want := c["Error"].Properties["code"], (&Schema{Type: "integer", Format: "int64"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got code schema %+v, want %+v", got, want)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	g := NewGenerator()
+
+	if got, want := g.Envelope("ThingListResponse", []thing{}), (&Schema{Ref: "#/components/schemas/ThingListResponse"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got schema %+v, want %+v", got, want)
+	}
+
+	c := g.Components()
+	env := c["ThingListResponse"]
+	if got, want := env.Properties["data"], (&Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/thing"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got data schema %+v, want %+v", got, want)
+	}
+	if got, want := env.Properties["page"], c["Response"].Properties["page"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got page schema %+v, want %+v", got, want)
+	}
+
+	// The Response component is not modified.
+	if got, want := c["Response"].Properties["data"], (&Schema{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got data schema %+v, want %+v", got, want)
+	}
+}
+
+func TestWriteComponents(t *testing.T) {
+	g := NewGenerator()
+	g.Envelope("ThingResponse", thing{})
+
+	var buf bytes.Buffer
+	if err := g.WriteComponents(&buf); err != nil {
+		t.Fatalf("failed to write components: %v", err)
+	}
+
+	var doc struct {
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("failed to decode components: %v", err)
+	}
+	if got, want := doc.Components.Schemas, g.Components(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got components %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package openapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// Validator returns a jsonresp.SchemaValidator that validates the data of a response against s,
+// for use with jsonresp.WithSchema. References within s are resolved to the components of g, so
+// s may be generated by g, such as by Schema, or decoded from a hand-written document.
+//
+// The types, formats, properties, required properties, items and additional properties of a
+// schema are validated. As the schemas generated by g do not describe whether a value may be
+// null, null is accepted in place of any value.
+func (g *Generator) Validator(s *Schema) jsonresp.SchemaValidator {
+	return validator{schemas: g.schemas, s: s}
+}
+
+// validator validates JSON against a schema, resolving references to schemas.
+type validator struct {
+	schemas map[string]*Schema
+	s       *Schema
+}
+
+// ValidateJSON returns an error describing the first value of the JSON b that does not match the
+// schema of v.
+func (v validator) ValidateJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var x interface{}
+	if err := dec.Decode(&x); err != nil {
+		return fmt.Errorf("openapi: failed to decode data: %v", err)
+	}
+	if err := v.validate(v.s, x, "data"); err != nil {
+		return fmt.Errorf("openapi: %v", err)
+	}
+	return nil
+}
+
+// validate validates x, the value at path, against s.
+func (v validator) validate(s *Schema, x interface{}, path string) error {
+	for s != nil && s.Ref != "" {
+		rs, ok := v.schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+		if !ok {
+			return fmt.Errorf("%v: unresolved reference %q", path, s.Ref)
+		}
+		s = rs
+	}
+	if s == nil || s.Type == "" || x == nil {
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("%v: got %v, want %v", path, jsonType(x), s.Type)
+	}
+
+	switch s.Type {
+	case "boolean":
+		if _, ok := x.(bool); !ok {
+			return mismatch()
+		}
+
+	case "integer", "number":
+		n, ok := x.(json.Number)
+		if !ok {
+			return mismatch()
+		}
+		if s.Type == "integer" {
+			if f, err := n.Float64(); err != nil || f != math.Trunc(f) {
+				return fmt.Errorf("%v: got %v, want integer", path, n)
+			}
+		}
+
+	case "string":
+		str, ok := x.(string)
+		if !ok {
+			return mismatch()
+		}
+		return validateFormat(s.Format, str, path)
+
+	case "array":
+		es, ok := x.([]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for i, e := range es {
+			if err := v.validate(s.Items, e, fmt.Sprintf("%v[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+
+	case "object":
+		ms, ok := x.(map[string]interface{})
+		if !ok {
+			return mismatch()
+		}
+		for _, name := range s.Required {
+			if _, ok := ms[name]; !ok {
+				return fmt.Errorf("%v: missing required property %q", path, name)
+			}
+		}
+
+		// Members are validated in a consistent order, so that the same violation is reported.
+		keys := make([]string, 0, len(ms))
+		for k := range ms {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			ps, ok := s.Properties[k]
+			if !ok {
+				ps = s.AdditionalProperties
+			}
+			if err := v.validate(ps, ms[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("%v: unsupported schema type %q", path, s.Type)
+	}
+	return nil
+}
+
+// validateFormat validates the string s, the value at path, against format. Unknown formats are
+// not validated.
+func validateFormat(format, s, path string) error {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, s)
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: invalid %v %q", path, format, s)
+	}
+	return nil
+}
+
+// jsonType returns the JSON type of x, a value decoded with json.Decoder.UseNumber.
+func jsonType(x interface{}) string {
+	switch x.(type) {
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
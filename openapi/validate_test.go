@@ -0,0 +1,104 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+func TestValidator(t *testing.T) {
+	const valid = `{"id":"a","size":"1","ratio":0.5,"ok":true,"created":"2021-01-02T03:04:05Z","tags":[],"Untagged":"","extra":""}`
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		b       string
+		wantErr string
+	}{
+		{"Valid", thing{}, valid, ""},
+		{"Null", thing{}, `null`, ""},
+		{"Nested", []thing{}, `[{"id":"a","size":"1","ratio":1,"ok":false,"created":"2021-01-02T03:04:05.5+01:00","tags":null,"Untagged":"","extra":"","parent":` + valid + `}]`, ""},
+		{"Optional", thing{}, `{"id":"a","count":3,"size":"1","ratio":1,"ok":true,"created":"2021-01-02T03:04:05Z","blob":"AQI=","tags":["x"],"labels":{"k":"v"},"Untagged":"","extra":"","other":1}`, ""},
+		{"Missing", thing{}, `{"id":"a"}`, `openapi: data: missing required property "size"`},
+		{"WrongType", thing{}, `{"id":1,"size":"1","ratio":1,"ok":true,"created":"2021-01-02T03:04:05Z","tags":[],"Untagged":"","extra":""}`, `openapi: data.id: got number, want string`},
+		{"NotInteger", thing{}, `{"id":"a","count":1.5,"size":"1","ratio":1,"ok":true,"created":"2021-01-02T03:04:05Z","tags":[],"Untagged":"","extra":""}`, `openapi: data.count: got 1.5, want integer`},
+		{"Items", []string{}, `["a",2]`, `openapi: data[1]: got number, want string`},
+		{"AdditionalProperties", map[string]int{}, `{"a":1,"b":"2"}`, `openapi: data.b: got string, want integer`},
+		{"DateTime", thing{}, `{"id":"a","size":"1","ratio":1,"ok":true,"created":"yesterday","tags":[],"Untagged":"","extra":""}`, `openapi: data.created: invalid date-time "yesterday"`},
+		{"Reference", []thing{}, `[` + valid + `,{"id":"b","parent":{"id":true}}]`, `openapi: data[1]: missing required property "size"`},
+		{"NotObject", thing{}, `[]`, `openapi: data: got array, want object`},
+		{"Invalid", thing{}, `{`, `openapi: failed to decode data: unexpected EOF`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGenerator()
+			err := g.Validator(g.Schema(tt.v)).ValidateJSON([]byte(tt.b))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatorDecoded(t *testing.T) {
+	var s Schema
+	if err := json.Unmarshal([]byte(`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`), &s); err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+	v := NewGenerator().Validator(&s)
+
+	if err := v.ValidateJSON([]byte(`{"name":"a"}`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := v.ValidateJSON([]byte(`{}`)); err == nil {
+		t.Error("unexpected success")
+	}
+}
+
+func TestValidatorUnresolved(t *testing.T) {
+	v := NewGenerator().Validator(&Schema{Ref: refPrefix + "Missing"})
+
+	want := `openapi: data: unresolved reference "#/components/schemas/Missing"`
+	if err := v.ValidateJSON([]byte(`{}`)); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
+
+func TestValidatorWithSchema(t *testing.T) {
+	g := NewGenerator()
+	e := jsonresp.NewEncoder(jsonresp.WithSchema(g.Validator(g.Schema(thing{}))))
+
+	rr := httptest.NewRecorder()
+	err := e.WriteResponse(rr, struct {
+		ID int `json:"id"`
+	}{}, http.StatusOK)
+	if !errors.Is(err, jsonresp.ErrSchemaViolation) {
+		t.Errorf("got error %v, want %v", err, jsonresp.ErrSchemaViolation)
+	}
+	if got, want := rr.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := e.WriteResponse(rr, thing{Tags: []string{}}, http.StatusOK); err != nil {
+		t.Errorf("failed to write response: %v", err)
+	}
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OperationStatus is the status of an asynchronous operation.
+type OperationStatus string
+
+const (
+	// OperationPending indicates that an operation has not yet started.
+	OperationPending OperationStatus = "pending"
+
+	// OperationRunning indicates that an operation is in progress.
+	OperationRunning OperationStatus = "running"
+
+	// OperationSucceeded indicates that an operation completed successfully.
+	OperationSucceeded OperationStatus = "succeeded"
+
+	// OperationFailed indicates that an operation completed unsuccessfully.
+	OperationFailed OperationStatus = "failed"
+)
+
+// known reports whether s is one of the statuses defined by this package.
+func (s OperationStatus) known() bool {
+	switch s {
+	case OperationPending, OperationRunning, OperationSucceeded, OperationFailed:
+		return true
+	}
+	return false
+}
+
+// Done reports whether s is a terminal status.
+func (s OperationStatus) Done() bool {
+	return s == OperationSucceeded || s == OperationFailed
+}
+
+// Operation describes an asynchronous operation, such as one started by a request answered using
+// WriteAccepted.
+type Operation struct {
+	// ID identifies the operation.
+	ID string `json:"id"`
+
+	// Status is the status of the operation.
+	Status OperationStatus `json:"status"`
+
+	// Progress is the percentage of the operation that has been completed, if known.
+	Progress float64 `json:"progress,omitempty"`
+
+	// Result is the result of an operation that succeeded. When read by PollOperation, it is of
+	// type json.RawMessage.
+	Result interface{} `json:"result,omitempty"`
+
+	// Error describes the failure of an operation that failed.
+	Error *Error `json:"error,omitempty"`
+
+	// PollURL is the URL at which the status of the operation can be retrieved.
+	PollURL string `json:"pollUrl,omitempty"`
+}
+
+// WriteAccepted writes a 202 status code and JSON response containing op to w, indicating that the
+// request has been accepted for asynchronous processing. If op has a poll URL, it is written in
+// the Location header. The handler serving the poll URL should write the current state of the
+// operation using WriteResponse, so that clients can wait for it using PollOperation.
+func WriteAccepted(w http.ResponseWriter, op Operation, opts ...Option) error {
+	if op.PollURL != "" {
+		w.Header().Set("Location", op.PollURL)
+	}
+	return WriteResponse(w, op, http.StatusAccepted, opts...)
+}
+
+// DefaultPollInterval is the interval between requests made by PollOperation, unless the poll
+// response carries a Retry-After header.
+const DefaultPollInterval = time.Second
+
+// minPollInterval is the minimum interval between requests made by PollOperation, so that a
+// Retry-After header of zero, or of a time in the past, does not cause the server to be flooded.
+const minPollInterval = 100 * time.Millisecond
+
+// PollOperation retrieves the state of the operation located at url using c, until the operation
+// is done or ctx is done. Between requests, it waits for the interval specified by the Retry-After
+// header of the previous response, or DefaultPollInterval if there is none, and at least 100ms. If
+// c is nil, http.DefaultClient is used. If a poll response does not contain an operation with a
+// known status, an error is returned.
+//
+// If the operation succeeds, its result is unmarshalled into v, if not nil. If the operation
+// fails, its Error is returned along with the Operation. The supplied options are applied when
+// reading each poll response.
+func PollOperation(ctx context.Context, c *http.Client, url string, v interface{}, opts ...Option) (*Operation, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	for {
+		op, wait, err := pollOperation(ctx, c, url, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Status.Done() {
+			if op.Status == OperationFailed {
+				if op.Error == nil {
+					op.Error = NewError("operation failed", http.StatusInternalServerError)
+				}
+				return op, op.Error
+			}
+			if r, ok := op.Result.(json.RawMessage); ok && v != nil {
+				if err := newOptions(opts).unmarshalData(r, v); err != nil {
+					return op, fmt.Errorf("jsonresp: failed to unmarshal operation result: %w", err)
+				}
+			}
+			return op, nil
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return op, fmt.Errorf("jsonresp: failed to poll operation: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// rawOperation is the wire representation of an Operation, with the result left encoded.
+type rawOperation struct {
+	Operation
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// pollOperation retrieves the state of the operation located at url using c, and returns the
+// interval to wait before the next request.
+func pollOperation(ctx context.Context, c *http.Client, url string, opts []Option) (*Operation, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("jsonresp: failed to poll operation: %w", err)
+	}
+
+	res, err := newOptions(opts).do(c, req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("jsonresp: failed to poll operation: %w", err)
+	}
+
+	var ro rawOperation
+	if _, err := ReadHTTPResponse(res, &ro, opts...); err != nil {
+		return nil, 0, err
+	}
+
+	op := ro.Operation
+	if !op.Status.known() {
+		return nil, 0, fmt.Errorf("jsonresp: failed to poll operation: unknown operation status %q", op.Status)
+	}
+	if len(ro.Result) > 0 {
+		op.Result = ro.Result
+	}
+
+	wait := DefaultPollInterval
+	if s, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+		wait = time.Duration(s) * time.Second
+	}
+	if wait < minPollInterval {
+		wait = minPollInterval
+	}
+	return &op, wait, nil
+}
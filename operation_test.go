@@ -0,0 +1,183 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWriteAccepted(t *testing.T) {
+	op := Operation{ID: "1", Status: OperationPending, PollURL: "/operations/1"}
+
+	rr := httptest.NewRecorder()
+	if err := WriteAccepted(rr, op); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if got, want := rr.Code, http.StatusAccepted; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Header().Get("Location"), "/operations/1"; got != want {
+		t.Errorf("got location %q, want %q", got, want)
+	}
+
+	var got Operation
+	if err := ReadResponse(rr.Body, &got); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if !reflect.DeepEqual(got, op) {
+		t.Errorf("got operation %+v, want %+v", got, op)
+	}
+}
+
+func TestOperationStatusDone(t *testing.T) {
+	tests := []struct {
+		s    OperationStatus
+		want bool
+	}{
+		{OperationPending, false},
+		{OperationRunning, false},
+		{OperationSucceeded, true},
+		{OperationFailed, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.s), func(t *testing.T) {
+			if got := tt.s.Done(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// operationServer returns a server that reports the operation as running for the first n
+// requests, and as final thereafter.
+func operationServer(t *testing.T, n int32, final Operation) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := Operation{ID: final.ID, Status: OperationRunning, Progress: 50}
+		if atomic.AddInt32(&requests, 1) > n {
+			op = final
+		}
+		w.Header().Set("Retry-After", "0")
+		_ = WriteResponse(w, op, http.StatusOK)
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+func TestPollOperation(t *testing.T) {
+	type result struct {
+		Image string `json:"image"`
+	}
+
+	tests := []struct {
+		name       string
+		final      Operation
+		wantResult result
+		wantErr    error
+	}{
+		{"Succeeded", Operation{ID: "1", Status: OperationSucceeded, Progress: 100, Result: result{"sha256:abc"}}, result{"sha256:abc"}, nil},
+		{"SucceededNoResult", Operation{ID: "1", Status: OperationSucceeded}, result{}, nil},
+		{"Failed", Operation{ID: "1", Status: OperationFailed, Error: NewError("build failed", http.StatusUnprocessableEntity)}, result{}, NewError("build failed", http.StatusUnprocessableEntity)},
+		{"FailedNoError", Operation{ID: "1", Status: OperationFailed}, result{}, &Error{Code: http.StatusInternalServerError}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, requests := operationServer(t, 2, tt.final)
+
+			var res result
+			op, err := PollOperation(context.Background(), s.Client(), s.URL, &res)
+			if got, want := err, tt.wantErr; !errors.Is(got, want) || (got == nil) != (want == nil) {
+				t.Fatalf("got error %v, want %v", got, want)
+			}
+			if got, want := op.Status, tt.final.Status; got != want {
+				t.Errorf("got status %v, want %v", got, want)
+			}
+			if got, want := res, tt.wantResult; got != want {
+				t.Errorf("got result %+v, want %+v", got, want)
+			}
+			if got, want := atomic.LoadInt32(requests), int32(3); got != want {
+				t.Errorf("got %v requests, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPollOperationContext(t *testing.T) {
+	s, _ := operationServer(t, 1<<30, Operation{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := PollOperation(ctx, s.Client(), s.URL, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPollOperationError(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = WriteError(w, "no such operation", http.StatusNotFound)
+	}))
+	defer s.Close()
+
+	if _, err := PollOperation(context.Background(), s.Client(), s.URL, nil); !errors.Is(err, NewError("no such operation", http.StatusNotFound)) {
+		t.Errorf("got error %v", err)
+	}
+}
+
+func TestPollOperationNotOperation(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+	}{
+		{"NoContent", func(w http.ResponseWriter) { WriteNoContent(w) }},
+		{"NoStatus", func(w http.ResponseWriter) { _ = WriteResponse(w, Operation{ID: "1"}, http.StatusOK) }},
+		{"UnknownStatus", func(w http.ResponseWriter) { _ = WriteResponse(w, Operation{ID: "1", Status: "queued"}, http.StatusOK) }},
+		{"NotObject", func(w http.ResponseWriter) { _ = WriteResponse(w, nil, http.StatusOK) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				tt.write(w)
+			}))
+			defer s.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			_, err := PollOperation(ctx, s.Client(), s.URL, nil)
+			if err == nil || errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("got error %v, want error describing the response", err)
+			}
+			if got, want := atomic.LoadInt32(&requests), int32(1); got != want {
+				t.Errorf("got %v requests, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPollOperationMinInterval(t *testing.T) {
+	s, requests := operationServer(t, 1<<30, Operation{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	_, _ = PollOperation(ctx, s.Client(), s.URL, nil)
+	if got, max := atomic.LoadInt32(requests), int32(3); got > max {
+		t.Errorf("got %v requests, want at most %v", got, max)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Option configures the behaviour of the write and read functions. Options that do not apply to
+// a function are ignored.
+type Option func(*options)
+
+type options struct {
+	ctx         context.Context //nolint:containedctx // nil if none
+	header      http.Header
+	vary        []string // request headers added to the Vary header
+	linkHeader  []string // values added to the Link header
+	prefix      string
+	indent      string
+	contentType string
+	charset     string
+	marshal     func(v interface{}) ([]byte, error)    // nil for encoding/json
+	unmarshal   func(data []byte, v interface{}) error // nil for encoding/json
+	stream      bool
+	format      Format // nil for JSON
+	canonical   bool
+	keepAlive   time.Duration
+	flush       bool
+	ndjson      bool
+
+	writeTimeout    time.Duration
+	preserveHeaders bool
+
+	compress       bool
+	acceptEncoding string
+
+	maxBodySize     int64
+	maxResponseSize int64
+	maxDepth        int
+	strict          bool
+	duplicateKeys   duplicateKeys
+	noEnvelope      bool // whether the body read is not a response envelope
+	useNumber       bool
+
+	fallbackError bool
+
+	allowedContentTypes []string
+
+	method            string
+	ifNoneMatch       string
+	ifModifiedSince   string
+	ifUnmodifiedSince string
+	etag              bool
+	lastModified      time.Time
+	head              bool
+
+	warnings     []Warning
+	meta         map[string]interface{}
+	links        map[string]Link
+	envelopeTo   *Response
+	fieldNames   FieldNames
+	explicitNull bool
+	fields       FieldSet // nil if selecting all fields
+	redact       bool
+	roles        []string
+
+	timeFormat     TimeFormat
+	durationFormat DurationFormat
+	nullFormat     NullFormat
+	bare           bool
+
+	hooks           []ResponseHook
+	request         *http.Request
+	requestID       string
+	ctxIDs          map[string]string // identifiers extracted from ctx
+	ctxIDsDone      bool
+	errorLogRequest *http.Request
+	errorLog        ErrorLogFunc
+	schema          SchemaValidator // nil if not validating
+
+	responseObservers []func(ResponseInfo)
+	start             time.Time // zero if responses are not timed
+
+	defaultPageLimit int
+	maxPageLimit     int
+	sortFields       []string
+	defaultSort      []SortField
+	forwardedHeaders bool
+
+	maxPages      int
+	maxItems      int
+	retry         *RetryPolicy  // nil if not retrying
+	responseCache ResponseStore // nil if not caching
+}
+
+var (
+	defaultsMu   sync.RWMutex
+	indentPrefix string
+	indentIndent string
+	defaultOpts  []Option
+)
+
+// SetIndent sets the indentation applied to responses that are not written with WithIndent. This
+// allows pretty-printed output to be enabled for an entire server, in the same way as WithIndent.
+// Passing empty strings restores compact output.
+func SetIndent(prefix, indent string) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	indentPrefix = prefix
+	indentIndent = indent
+}
+
+// hasDefaults reports whether an indentation has been established by SetIndent, or options by
+// SetDefaults.
+func hasDefaults() bool {
+	defaultsMu.RLock()
+	defer defaultsMu.RUnlock()
+	return indentPrefix != "" || indentIndent != "" || len(defaultOpts) > 0
+}
+
+// newOptions returns the options resulting from applying opts to the defaults established by
+// SetIndent and SetDefaults.
+func newOptions(opts []Option) *options {
+	defaultsMu.RLock()
+	o := &options{
+		prefix: indentPrefix,
+		indent: indentIndent,
+	}
+	defaults := defaultOpts
+	defaultsMu.RUnlock()
+
+	for _, opt := range defaults {
+		opt(o)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.startTiming()
+	return o
+}
+
+// WithHeader sets the header key to value in the response, replacing any existing values.
+func WithHeader(key, value string) Option {
+	return func(o *options) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Set(key, value)
+	}
+}
+
+// copyHeader copies the headers established by o to h.
+func (o *options) copyHeader(h http.Header) {
+	o.setContextHeaders(h)
+	for k, v := range o.header {
+		h[k] = v
+	}
+	for _, v := range o.vary {
+		addVary(h, v)
+	}
+	for _, v := range o.linkHeader {
+		addLink(h, v)
+	}
+}
+
+// addLink adds the Link header value v to h, unless it is already present. Unlike the headers
+// established by WithHeader, those set by the handler are retained.
+func addLink(h http.Header, v string) {
+	for _, l := range h.Values("Link") {
+		if l == v {
+			return
+		}
+	}
+	h.Add("Link", v)
+}
+
+// WithIndent causes the response to be indented, in the same way as json.Indent. Each element of
+// the response begins on a new line beginning with prefix followed by one or more copies of
+// indent according to the nesting depth. WithIndent("", "") disables indentation established by
+// SetIndent.
+func WithIndent(prefix, indent string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+		o.indent = indent
+	}
+}
+
+// WithContentType sets the Content-Type header of the response. The default is
+// "application/json", or the media type of the Format established by WithFormat.
+func WithContentType(contentType string) Option {
+	return func(o *options) {
+		o.contentType = contentType
+	}
+}
+
+// WithPreservedHeaders causes the Content-Type and Retry-After headers of the response to be
+// written only if they have not already been set on the http.ResponseWriter, such as by a handler
+// that sets a vendor media type. Headers established by WithHeader are written regardless.
+func WithPreservedHeaders() Option {
+	return func(o *options) {
+		o.preserveHeaders = true
+	}
+}
+
+// setHeader sets the header key of h to value, unless o preserves headers and it is already set.
+func (o *options) setHeader(h http.Header, key, value string) {
+	if o.preserveHeaders && h.Get(key) != "" {
+		return
+	}
+	h.Set(key, value)
+}
+
+// WithEncoder sets the function used to encode the response. The default is json.Marshal.
+func WithEncoder(marshal func(v interface{}) ([]byte, error)) Option {
+	return func(o *options) {
+		o.marshal = marshal
+	}
+}
+
+// WithStream causes the response to be written directly to the http.ResponseWriter, rather than
+// via an intermediate buffer. If the data is a slice or array, each element is encoded and written
+// in turn, so memory usage is bounded by the largest element rather than the whole response.
+// Because the status code is written before encoding begins, an encoding failure results in a
+// truncated response rather than an appropriate status code. When used with WithEncoder or
+// WithCodec, the response is marshalled in full before being written.
+func WithStream() Option {
+	return func(o *options) {
+		o.stream = true
+	}
+}
+
+// mediaType returns the value of the Content-Type header of the response.
+func (o *options) mediaType() string {
+	switch {
+	case o.contentType != "":
+		return o.withCharset(o.contentType)
+	case o.format != nil:
+		return o.withCharset(o.format.ContentType())
+	default:
+		return o.withCharset("application/json")
+	}
+}
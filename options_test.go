@@ -0,0 +1,263 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	type TestStruct struct {
+		Value string `json:"value"`
+	}
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		wantErr         bool
+		wantBody        string
+		wantContentType string
+		wantHeader      http.Header
+	}{
+		{
+			name:            "Default",
+			wantBody:        `{"data":{"value":"blah"}}`,
+			wantContentType: "application/json",
+		},
+		{
+			name:            "Header",
+			opts:            []Option{WithHeader("X-Test", "a"), WithHeader("X-Other", "b")},
+			wantBody:        `{"data":{"value":"blah"}}`,
+			wantContentType: "application/json",
+			wantHeader:      http.Header{"X-Test": {"a"}, "X-Other": {"b"}},
+		},
+		{
+			name:            "Indent",
+			opts:            []Option{WithIndent("", "  ")},
+			wantBody:        "{\n  \"data\": {\n    \"value\": \"blah\"\n  }\n}",
+			wantContentType: "application/json",
+		},
+		{
+			name:            "ContentType",
+			opts:            []Option{WithContentType("application/vnd.test+json")},
+			wantBody:        `{"data":{"value":"blah"}}`,
+			wantContentType: "application/vnd.test+json",
+		},
+		{
+			name:            "Charset",
+			opts:            []Option{WithCharset("utf-8")},
+			wantBody:        `{"data":{"value":"blah"}}`,
+			wantContentType: "application/json; charset=utf-8",
+		},
+		{
+			name:            "ContentTypeCharset",
+			opts:            []Option{WithContentType("application/vnd.test+json; v=2"), WithCharset("utf-8")},
+			wantBody:        `{"data":{"value":"blah"}}`,
+			wantContentType: "application/vnd.test+json; v=2; charset=utf-8",
+		},
+		{
+			name:            "ContentTypeWithCharset",
+			opts:            []Option{WithContentType("application/vnd.test+json; charset=utf-8"), WithCharset("iso-8859-1")},
+			wantBody:        `{"data":{"value":"blah"}}`,
+			wantContentType: "application/vnd.test+json; charset=utf-8",
+		},
+		{
+			name: "Encoder",
+			opts: []Option{WithEncoder(func(v interface{}) ([]byte, error) {
+				return json.Marshal(map[string]interface{}{"wrapped": v})
+			})},
+			wantBody:        `{"wrapped":{"data":{"value":"blah"}}}`,
+			wantContentType: "application/json",
+		},
+		{
+			name: "EncoderIndent",
+			opts: []Option{WithIndent("", "\t"), WithEncoder(func(v interface{}) ([]byte, error) {
+				return []byte(`{"a":1}`), nil
+			})},
+			wantBody:        "{\n\t\"a\": 1\n}",
+			wantContentType: "application/json",
+		},
+		{
+			name: "EncoderError",
+			opts: []Option{WithEncoder(func(v interface{}) ([]byte, error) {
+				return nil, errors.New("blah")
+			})},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			err := WriteResponse(rr, TestStruct{"blah"}, http.StatusOK, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			for k := range tt.wantHeader {
+				if got, want := rr.Header().Get(k), tt.wantHeader.Get(k); got != want {
+					t.Errorf("got header %v %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsError(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	err := WriteError(rr, "blah", http.StatusNotFound, WithHeader("X-Test", "a"), WithIndent("", " "))
+	if err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	if got, want := rr.Header().Get("X-Test"), "a"; got != want {
+		t.Errorf("got header %q, want %q", got, want)
+	}
+	if got, want := rr.Body.String(), "{\n \"error\": {\n  \"code\": 404,\n  \"message\": \"blah\"\n }\n}"; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestWithPreservedHeaders(t *testing.T) {
+	tests := []struct {
+		name            string
+		preset          http.Header
+		opts            []Option
+		wantContentType string
+		wantRetryAfter  string
+	}{
+		{"Default", http.Header{"Content-Type": {"application/vnd.test+json"}, "Retry-After": {"5"}}, nil, "application/json", "10"},
+		{"Preserved", http.Header{"Content-Type": {"application/vnd.test+json"}, "Retry-After": {"5"}}, []Option{WithPreservedHeaders()}, "application/vnd.test+json", "5"},
+		{"PreservedUnset", nil, []Option{WithPreservedHeaders()}, "application/json", "10"},
+		{"PreservedHeaderOption", http.Header{"Content-Type": {"application/vnd.test+json"}}, []Option{WithPreservedHeaders(), WithHeader("Content-Type", "text/plain")}, "text/plain", "10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, stream := range []bool{false, true} {
+				rr := httptest.NewRecorder()
+				for k, v := range tt.preset {
+					rr.Header()[k] = v
+				}
+
+				opts := tt.opts
+				if stream {
+					opts = append(opts, WithStream())
+				}
+				je := &Error{Code: http.StatusServiceUnavailable, Message: "unavailable", RetryAfter: 10}
+				if err := WriteErr(rr, je, opts...); err != nil {
+					t.Fatalf("failed to write error: %v", err)
+				}
+
+				if got, want := rr.Header().Get("Content-Type"), tt.wantContentType; got != want {
+					t.Errorf("stream %v: got content type %q, want %q", stream, got, want)
+				}
+				if got, want := rr.Header().Get("Retry-After"), tt.wantRetryAfter; got != want {
+					t.Errorf("stream %v: got retry after %q, want %q", stream, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSetIndent(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		indent   string
+		opts     []Option
+		wantBody string
+	}{
+		{"Compact", "", "", nil, `{"data":"blah"}`},
+		{"Indent", "", "  ", nil, "{\n  \"data\": \"blah\"\n}"},
+		{"Override", "", "  ", []Option{WithIndent("", "\t")}, "{\n\t\"data\": \"blah\"\n}"},
+		{"Disable", "", "  ", []Option{WithIndent("", "")}, `{"data":"blah"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetIndent(tt.prefix, tt.indent)
+			defer SetIndent("", "")
+
+			rr := httptest.NewRecorder()
+
+			if err := WriteResponse(rr, "blah", http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+type errorResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w errorResponseWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWithStream(t *testing.T) {
+	type TestStruct struct {
+		Value string `json:"value"`
+	}
+
+	tests := []struct {
+		name     string
+		data     interface{}
+		opts     []Option
+		wantErr  bool
+		wantCode int
+		wantBody string
+	}{
+		{"Stream", TestStruct{"blah"}, []Option{WithStream()}, false, http.StatusCreated, `{"data":{"value":"blah"}}`},
+		{"StreamIndent", TestStruct{"blah"}, []Option{WithStream(), WithIndent("", " ")}, false, http.StatusCreated, "{\n \"data\": {\n  \"value\": \"blah\"\n }\n}"},
+		{"StreamEncoder", TestStruct{"blah"}, []Option{WithStream(), WithEncoder(json.Marshal)}, false, http.StatusCreated, `{"data":{"value":"blah"}}`},
+		{"StreamEncodeError", []interface{}{1, make(chan int)}, []Option{WithStream()}, true, http.StatusCreated, `{"data":[1,`},
+		{"BufferedEncodeError", make(chan int), nil, true, http.StatusInternalServerError, string(encodeFailureBody)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			err := WriteResponse(rr, tt.data, http.StatusCreated, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+
+			// A buffered response that fails to encode is replaced by a 500 error.
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithStreamWriteError(t *testing.T) {
+	w := errorResponseWriter{httptest.NewRecorder()}
+
+	if err := WriteResponse(w, "blah", http.StatusOK, WithStream()); err == nil {
+		t.Errorf("got nil error, want write error")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package otelresp provides OpenTelemetry tracing for responses written and read by the jsonresp
+// package.
+package otelresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies this package as the source of spans.
+const instrumentationName = "github.com/sylabs/json-resp/otelresp"
+
+// Attribute keys recorded on spans.
+const (
+	StatusCodeKey   = attribute.Key("http.response.status_code")
+	BodySizeKey     = attribute.Key("http.response.body.size")
+	ErrorCodeKey    = attribute.Key("jsonresp.error.code")
+	ErrorAppCodeKey = attribute.Key("jsonresp.error.app_code")
+	ErrorMessageKey = attribute.Key("jsonresp.error.message")
+	PageNextKey     = attribute.Key("jsonresp.page.has_next")
+	PagePrevKey     = attribute.Key("jsonresp.page.has_prev")
+	PageTotalKey    = attribute.Key("jsonresp.page.total_size")
+)
+
+// errorAttributes returns the span attributes describing je.
+func errorAttributes(je *jsonresp.Error) []attribute.KeyValue {
+	kvs := []attribute.KeyValue{
+		ErrorCodeKey.Int(je.Code),
+		ErrorMessageKey.String(je.Message),
+	}
+	if je.AppCode != "" {
+		kvs = append(kvs, ErrorAppCodeKey.String(je.AppCode))
+	}
+	return kvs
+}
+
+// pageAttributes returns the span attributes describing pd.
+func pageAttributes(pd *jsonresp.PageDetails) []attribute.KeyValue {
+	kvs := []attribute.KeyValue{
+		PageNextKey.Bool(pd.Next != ""),
+		PagePrevKey.Bool(pd.Prev != ""),
+	}
+	if total, ok := pd.Total(); ok {
+		kvs = append(kvs, PageTotalKey.Int64(total))
+	}
+	return kvs
+}
+
+// WithSpan returns an option that annotates the span active in the context of r with the status
+// code and body size of the response, and its paging information or error, if any. The span
+// status is set to Error if the status code of the response is 5xx.
+func WithSpan(r *http.Request) jsonresp.Option {
+	span := trace.SpanFromContext(r.Context())
+	return jsonresp.WithResponseObserver(func(ri jsonresp.ResponseInfo) {
+		if !span.IsRecording() {
+			return
+		}
+
+		span.SetAttributes(StatusCodeKey.Int(ri.Code), BodySizeKey.Int(ri.Size))
+		if ri.Page != nil {
+			span.SetAttributes(pageAttributes(ri.Page)...)
+		}
+		if ri.Error != nil {
+			span.SetAttributes(errorAttributes(ri.Error)...)
+		}
+		if ri.Code >= http.StatusInternalServerError {
+			msg := http.StatusText(ri.Code)
+			if ri.Error != nil && ri.Error.Message != "" {
+				msg = ri.Error.Message
+			}
+			span.SetStatus(codes.Error, msg)
+		}
+	})
+}
+
+// ReadHTTPResponse reads the paged response res in the same way as jsonresp.ReadHTTPResponse,
+// recording a client span as a child of the span in ctx. The span is created using the global
+// tracer provider, and describes the status code of res, and its paging information or error, if
+// any.
+func ReadHTTPResponse(ctx context.Context, res *http.Response, v interface{}, opts ...jsonresp.Option) (*jsonresp.PageDetails, error) {
+	_, span := otel.Tracer(instrumentationName).Start(ctx, "jsonresp.ReadHTTPResponse",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(StatusCodeKey.Int(res.StatusCode)),
+	)
+	defer span.End()
+
+	pd, err := jsonresp.ReadHTTPResponse(res, v, opts...)
+	if pd != nil {
+		span.SetAttributes(pageAttributes(pd)...)
+	}
+	if err != nil {
+		var je *jsonresp.Error
+		if errors.As(err, &je) {
+			span.SetAttributes(errorAttributes(je)...)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return pd, err
+}
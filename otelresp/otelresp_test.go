@@ -0,0 +1,160 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package otelresp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	jsonresp "github.com/sylabs/json-resp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecorder returns a tracer provider that records ended spans.
+func newRecorder(t *testing.T) (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return tp, sr
+}
+
+func TestWithSpan(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(w http.ResponseWriter, opt jsonresp.Option) error
+		wantAttrs  []attribute.KeyValue
+		wantStatus codes.Code
+	}{
+		{"Page", func(w http.ResponseWriter, opt jsonresp.Option) error {
+			return jsonresp.WriteResponsePage(w, []int{1}, &jsonresp.PageDetails{Next: "n", TotalSize: 3}, http.StatusOK, opt)
+		}, []attribute.KeyValue{
+			StatusCodeKey.Int(http.StatusOK),
+			BodySizeKey.Int(len(`{"data":[1],"page":{"next":"n","totalSize":3}}`)),
+			PageNextKey.Bool(true),
+			PagePrevKey.Bool(false),
+			PageTotalKey.Int(3),
+		}, codes.Unset},
+		{"ClientError", func(w http.ResponseWriter, opt jsonresp.Option) error {
+			return jsonresp.WriteAppError(w, "QUOTA", "blah", http.StatusTooManyRequests, opt)
+		}, []attribute.KeyValue{
+			StatusCodeKey.Int(http.StatusTooManyRequests),
+			BodySizeKey.Int(len(`{"error":{"code":429,"appCode":"QUOTA","message":"blah"}}`)),
+			ErrorCodeKey.Int(http.StatusTooManyRequests),
+			ErrorMessageKey.String("blah"),
+			ErrorAppCodeKey.String("QUOTA"),
+		}, codes.Unset},
+		{"ServerError", func(w http.ResponseWriter, opt jsonresp.Option) error {
+			return jsonresp.WriteError(w, "blah", http.StatusBadGateway, opt)
+		}, []attribute.KeyValue{
+			StatusCodeKey.Int(http.StatusBadGateway),
+			BodySizeKey.Int(len(`{"error":{"code":502,"message":"blah"}}`)),
+			ErrorCodeKey.Int(http.StatusBadGateway),
+			ErrorMessageKey.String("blah"),
+		}, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, sr := newRecorder(t)
+
+			ctx, span := tp.Tracer("test").Start(context.Background(), "handler")
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			if err := tt.write(httptest.NewRecorder(), WithSpan(r)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			span.End()
+
+			spans := sr.Ended()
+			if got, want := len(spans), 1; got != want {
+				t.Fatalf("got %v spans, want %v", got, want)
+			}
+			if got, want := spans[0].Attributes(), tt.wantAttrs; !reflect.DeepEqual(got, want) {
+				t.Errorf("got attributes %v, want %v", got, want)
+			}
+			if got, want := spans[0].Status().Code, tt.wantStatus; got != want {
+				t.Errorf("got status %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithSpanNotRecording(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if err := jsonresp.WriteError(httptest.NewRecorder(), "blah", http.StatusInternalServerError, WithSpan(r)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+}
+
+func TestReadHTTPResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      func(w http.ResponseWriter) error
+		wantAttrs  []attribute.KeyValue
+		wantStatus codes.Code
+		wantErr    error
+	}{
+		{"Page", func(w http.ResponseWriter) error {
+			return jsonresp.WriteResponsePage(w, []int{1}, &jsonresp.PageDetails{Prev: "p"}, http.StatusOK)
+		}, []attribute.KeyValue{
+			StatusCodeKey.Int(http.StatusOK),
+			PageNextKey.Bool(false),
+			PagePrevKey.Bool(true),
+		}, codes.Unset, nil},
+		{"Error", func(w http.ResponseWriter) error {
+			return jsonresp.WriteError(w, "blah", http.StatusNotFound)
+		}, []attribute.KeyValue{
+			StatusCodeKey.Int(http.StatusNotFound),
+			ErrorCodeKey.Int(http.StatusNotFound),
+			ErrorMessageKey.String("blah"),
+		}, codes.Error, &jsonresp.Error{Code: http.StatusNotFound}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, sr := newRecorder(t)
+			otel.SetTracerProvider(tp)
+			defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+			rr := httptest.NewRecorder()
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			var got []int
+			_, err := ReadHTTPResponse(context.Background(), rr.Result(), &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			spans := sr.Ended()
+			if got, want := len(spans), 1; got != want {
+				t.Fatalf("got %v spans, want %v", got, want)
+			}
+			s := spans[0]
+			if got, want := s.Name(), "jsonresp.ReadHTTPResponse"; got != want {
+				t.Errorf("got name %q, want %q", got, want)
+			}
+			if got, want := s.SpanKind(), trace.SpanKindClient; got != want {
+				t.Errorf("got kind %v, want %v", got, want)
+			}
+			if got, want := s.Attributes(), tt.wantAttrs; !reflect.DeepEqual(got, want) {
+				t.Errorf("got attributes %v, want %v", got, want)
+			}
+			if got, want := s.Status().Code, tt.wantStatus; got != want {
+				t.Errorf("got status %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultPageLimit is the page size used by BindPageQuery when the request does not specify
+	// one, unless overridden by WithPageLimits.
+	DefaultPageLimit = 20
+
+	// DefaultMaxPageLimit is the maximum page size accepted by BindPageQuery, unless overridden by
+	// WithPageLimits.
+	DefaultMaxPageLimit = 100
+)
+
+// SortField is a field by which a page of results is ordered. Its text form, used in the sort
+// parameter and the Sort of PageDetails, is the name of the field, prefixed by "-" if the order
+// is descending.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// String returns the text form of sf, such as "-created".
+func (sf SortField) String() string {
+	if sf.Descending {
+		return "-" + sf.Field
+	}
+	return sf.Field
+}
+
+// MarshalText encodes sf in its text form.
+func (sf SortField) MarshalText() ([]byte, error) {
+	return []byte(sf.String()), nil
+}
+
+// UnmarshalText decodes sf from its text form.
+func (sf *SortField) UnmarshalText(b []byte) error {
+	f := SortField{Field: string(b)}
+	if strings.HasPrefix(f.Field, "-") {
+		f.Field, f.Descending = f.Field[1:], true
+	}
+	if f.Field == "" {
+		return errors.New("jsonresp: empty sort field")
+	}
+	*sf = f
+	return nil
+}
+
+// FormatSort returns the sort parameter value corresponding to sort, such as "-created,name".
+func FormatSort(sort []SortField) string {
+	ss := make([]string, len(sort))
+	for i, sf := range sort {
+		ss[i] = sf.String()
+	}
+	return strings.Join(ss, ",")
+}
+
+// ParseSort parses v, a sort parameter value in the form returned by FormatSort, such as the Sort
+// of PageDetails.
+func ParseSort(v string) ([]SortField, error) {
+	return parseSort(v, nil)
+}
+
+// PageRequest describes the page of results requested by a client.
+type PageRequest struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   []SortField
+}
+
+// WithPageLimits sets the page size used by BindPageQuery when the request does not specify one
+// to defaultLimit, and the maximum page size accepted to maxLimit.
+func WithPageLimits(defaultLimit, maxLimit int) Option {
+	return func(o *options) {
+		o.defaultPageLimit = defaultLimit
+		o.maxPageLimit = maxLimit
+	}
+}
+
+// WithSortFields restricts the fields accepted in the sort parameter by BindPageQuery to fields.
+// By default, any field is accepted.
+func WithSortFields(fields ...string) Option {
+	return func(o *options) {
+		o.sortFields = fields
+	}
+}
+
+// WithDefaultSort sets the sort returned by BindPageQuery when the request does not specify one,
+// so that the sort applied to the results, rather than that requested, is echoed in the
+// PageDetails returned by OffsetPageDetails.
+func WithDefaultSort(sort ...SortField) Option {
+	return func(o *options) {
+		o.defaultSort = sort
+	}
+}
+
+// invalidParameter returns an Error describing an invalid query parameter.
+func invalidParameter(name, reason string) *Error {
+	return &Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("invalid %v parameter: %v", name, reason),
+		Details: map[string]interface{}{"parameter": name},
+	}
+}
+
+// BindPageQuery parses the limit, offset, cursor and sort query parameters of r.
+//
+// The limit parameter must be between 1 and DefaultMaxPageLimit, and defaults to
+// DefaultPageLimit; see WithPageLimits. The offset parameter must not be negative, and may not be
+// combined with cursor. The sort parameter is a comma-separated list of fields, each optionally
+// prefixed by "-" to indicate descending order; see WithSortFields and WithDefaultSort.
+//
+// If a parameter is invalid, the returned error is an Error with a 400 status code, suitable for
+// writing with WriteRequestError. Its details identify the offending parameter.
+func BindPageQuery(r *http.Request, opts ...Option) (PageRequest, error) {
+	o := newOptions(append([]Option{WithPageLimits(DefaultPageLimit, DefaultMaxPageLimit)}, opts...))
+	q := r.URL.Query()
+
+	pr := PageRequest{
+		Limit:  o.defaultPageLimit,
+		Cursor: q.Get("cursor"),
+		Sort:   o.defaultSort,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > o.maxPageLimit {
+			return PageRequest{}, invalidParameter("limit", fmt.Sprintf("must be an integer between 1 and %v", o.maxPageLimit))
+		}
+		pr.Limit = n
+	}
+
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return PageRequest{}, invalidParameter("offset", "must be a non-negative integer")
+		}
+		if pr.Cursor != "" {
+			return PageRequest{}, invalidParameter("offset", "cannot be combined with cursor")
+		}
+		pr.Offset = n
+	}
+
+	if v := q.Get("sort"); v != "" {
+		sort, err := parseSort(v, o.sortFields)
+		if err != nil {
+			return PageRequest{}, err
+		}
+		pr.Sort = sort
+	}
+
+	return pr, nil
+}
+
+// parseSort parses the sort parameter value v. If allowed is not empty, only the fields it
+// contains are accepted.
+func parseSort(v string, allowed []string) ([]SortField, error) {
+	sort := make([]SortField, 0, strings.Count(v, ",")+1)
+	for _, s := range strings.Split(v, ",") {
+		var sf SortField
+		sf.Field = strings.TrimSpace(s)
+		if strings.HasPrefix(sf.Field, "-") {
+			sf.Field, sf.Descending = sf.Field[1:], true
+		}
+
+		if sf.Field == "" {
+			return nil, invalidParameter("sort", "must not contain empty fields")
+		}
+		if len(allowed) > 0 && !contains(allowed, sf.Field) {
+			return nil, invalidParameter("sort", fmt.Sprintf("unsupported field %q", sf.Field))
+		}
+		sort = append(sort, sf)
+	}
+	return sort, nil
+}
+
+// contains reports whether ss contains s.
+func contains(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBindPageQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		opts          []Option
+		want          PageRequest
+		wantParameter string
+	}{
+		{"Defaults", "", nil, PageRequest{Limit: DefaultPageLimit}, ""},
+		{"Limit", "limit=50", nil, PageRequest{Limit: 50}, ""},
+		{"LimitMax", "limit=100", nil, PageRequest{Limit: 100}, ""},
+		{"LimitTooLarge", "limit=101", nil, PageRequest{}, "limit"},
+		{"LimitZero", "limit=0", nil, PageRequest{}, "limit"},
+		{"LimitInvalid", "limit=ten", nil, PageRequest{}, "limit"},
+		{"PageLimits", "", []Option{WithPageLimits(5, 10)}, PageRequest{Limit: 5}, ""},
+		{"PageLimitsTooLarge", "limit=11", []Option{WithPageLimits(5, 10)}, PageRequest{}, "limit"},
+		{"Offset", "offset=40", nil, PageRequest{Limit: DefaultPageLimit, Offset: 40}, ""},
+		{"OffsetNegative", "offset=-1", nil, PageRequest{}, "offset"},
+		{"Cursor", "cursor=abc", nil, PageRequest{Limit: DefaultPageLimit, Cursor: "abc"}, ""},
+		{"OffsetAndCursor", "offset=1&cursor=abc", nil, PageRequest{}, "offset"},
+		{"Sort", "sort=name,-created", nil, PageRequest{Limit: DefaultPageLimit, Sort: []SortField{{"name", false}, {"created", true}}}, ""},
+		{"SortEmptyField", "sort=name,", nil, PageRequest{}, "sort"},
+		{"SortFields", "sort=-name", []Option{WithSortFields("name")}, PageRequest{Limit: DefaultPageLimit, Sort: []SortField{{"name", true}}}, ""},
+		{"SortFieldsUnsupported", "sort=size", []Option{WithSortFields("name")}, PageRequest{}, "sort"},
+		{"DefaultSort", "", []Option{WithDefaultSort(SortField{"created", true})}, PageRequest{Limit: DefaultPageLimit, Sort: []SortField{{"created", true}}}, ""},
+		{"DefaultSortOverridden", "sort=name", []Option{WithDefaultSort(SortField{"created", true})}, PageRequest{Limit: DefaultPageLimit, Sort: []SortField{{"name", false}}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			pr, err := BindPageQuery(r, tt.opts...)
+
+			if tt.wantParameter == "" {
+				if err != nil {
+					t.Fatalf("failed to bind page query: %v", err)
+				}
+			} else {
+				var je *Error
+				if !errors.As(err, &je) {
+					t.Fatalf("got error %v, want *Error", err)
+				}
+				if got, want := je.Code, http.StatusBadRequest; got != want {
+					t.Errorf("got code %v, want %v", got, want)
+				}
+				if got, want := je.Details["parameter"], tt.wantParameter; got != want {
+					t.Errorf("got parameter %v, want %v", got, want)
+				}
+			}
+
+			if got, want := pr, tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestSortFieldText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    SortField
+		wantErr bool
+	}{
+		{"Ascending", "name", SortField{"name", false}, false},
+		{"Descending", "-created", SortField{"created", true}, false},
+		{"Empty", "", SortField{}, true},
+		{"EmptyDescending", "-", SortField{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sf SortField
+			err := sf.UnmarshalText([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := sf, tt.want; got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+			if err != nil {
+				return
+			}
+
+			b, err := sf.MarshalText()
+			if err != nil {
+				t.Fatalf("failed to marshal: %v", err)
+			}
+			if got, want := string(b), tt.text; got != want {
+				t.Errorf("got text %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFormatSort(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []SortField
+		want string
+	}{
+		{"Nil", nil, ""},
+		{"One", []SortField{{"name", false}}, "name"},
+		{"Many", []SortField{{"created", true}, {"name", false}}, "-created,name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := FormatSort(tt.sort), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPageDetailsSort(t *testing.T) {
+	pd := &PageDetails{Next: "n", Sort: FormatSort([]SortField{{"created", true}, {"name", false}})}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponsePage(rr, []int{1}, pd, http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"data":[1],"page":{"next":"n","sort":"-created,name"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+
+	var data []int
+	got, err := ReadResponsePage(rr.Body, &data)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if !reflect.DeepEqual(got, pd) {
+		t.Errorf("got page %+v, want %+v", got, pd)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       string
+		want    []SortField
+		wantErr bool
+	}{
+		{"One", "name", []SortField{{"name", false}}, false},
+		{"Many", "-created,name", []SortField{{"created", true}, {"name", false}}, false},
+		{"Empty", "", nil, true},
+		{"EmptyField", "-", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseSort(tt.v)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageDetailsComparable(t *testing.T) {
+	if a, b := (PageDetails{Sort: "-id"}), (PageDetails{Sort: "-id"}); a != b {
+		t.Errorf("got %+v != %+v", a, b)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// UnknownTotal may be passed to OffsetPageDetails when the total number of items is not known.
+const UnknownTotal int64 = -1
+
+// WithForwardedHeaders causes PageURL and the functions that use it to determine the scheme, host
+// and path prefix by which clients reach the server from the Forwarded header of the request, or
+// in its absence, the X-Forwarded-Proto, X-Forwarded-Host and X-Forwarded-Prefix headers. These
+// headers can be set by any client, so this option must only be used when the server is reached
+// exclusively through a proxy that sets or removes them.
+func WithForwardedHeaders() Option {
+	return func(o *options) {
+		o.forwardedHeaders = true
+	}
+}
+
+// PageURL returns the absolute URL of the resource requested by r, with the query parameters in q
+// replacing those of the request. A parameter in q without a non-empty value is removed. Other
+// query parameters of the request are preserved.
+func PageURL(r *http.Request, q url.Values, opts ...Option) string {
+	o := newOptions(opts)
+
+	u := url.URL{
+		Scheme:  "http",
+		Host:    r.Host,
+		Path:    r.URL.Path,
+		RawPath: r.URL.RawPath,
+	}
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if u.Host == "" {
+		u.Host = r.URL.Host
+	}
+	if o.forwardedHeaders {
+		applyForwarded(&u, r.Header)
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	vs := r.URL.Query()
+	for k, v := range q {
+		if len(v) == 0 || v[0] == "" {
+			vs.Del(k)
+		} else {
+			vs[k] = v
+		}
+	}
+	u.RawQuery = vs.Encode()
+	return u.String()
+}
+
+// applyForwarded updates the scheme, host and path of u according to the forwarding headers h.
+func applyForwarded(u *url.URL, h http.Header) {
+	if v := h.Get("Forwarded"); v != "" {
+		// The first element describes the request as received by the proxy nearest the client.
+		first, _, _ := strings.Cut(v, ",")
+		for _, pair := range strings.Split(first, ";") {
+			k, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			v = strings.Trim(v, `"`)
+			switch strings.ToLower(k) {
+			case "proto":
+				if v == "http" || v == "https" {
+					u.Scheme = v
+				}
+			case "host":
+				if v != "" {
+					u.Host = v
+				}
+			}
+		}
+	} else {
+		if v := firstValue(h.Get("X-Forwarded-Proto")); v == "http" || v == "https" {
+			u.Scheme = v
+		}
+		if v := firstValue(h.Get("X-Forwarded-Host")); v != "" {
+			u.Host = v
+		}
+	}
+
+	if p := strings.TrimRight(firstValue(h.Get("X-Forwarded-Prefix")), "/"); p != "" {
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		u.Path = p + u.Path
+		if u.RawPath != "" {
+			u.RawPath = p + u.RawPath
+		}
+	}
+}
+
+// firstValue returns the first element of the comma-separated header value v.
+func firstValue(v string) string {
+	first, _, _ := strings.Cut(v, ",")
+	return strings.TrimSpace(first)
+}
+
+// OffsetPageDetails returns the paging information for a page of n items, retrieved according to
+// the offset and limit of pr, in reply to r. The previous and next page URLs are those of r with
+// the offset parameter replaced, and the cursor parameter removed; see PageURL.
+//
+// If total is not UnknownTotal, it is the total number of items, and a next page URL is included
+// if items remain beyond this page. Otherwise, a next page URL is included if the page is full.
+// The sort of pr is echoed as the sort of the page.
+func OffsetPageDetails(r *http.Request, pr PageRequest, n int, total int64, opts ...Option) *PageDetails {
+	pd := &PageDetails{Sort: FormatSort(pr.Sort)}
+	if total >= 0 {
+		pd.TotalSize = total
+		pd.ZeroTotal = total == 0
+	}
+
+	if pr.Offset > 0 {
+		prev := pr.Offset - pr.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		pd.Prev = PageURL(r, offsetQuery(prev), opts...)
+	}
+
+	next := pr.Offset + n
+	if (total >= 0 && int64(next) < total) || (total < 0 && n > 0 && n >= pr.Limit) {
+		pd.Next = PageURL(r, offsetQuery(next), opts...)
+	}
+	return pd
+}
+
+// offsetQuery returns query parameters selecting the page at offset. The offset parameter of the
+// first page is omitted.
+func offsetQuery(offset int) url.Values {
+	q := url.Values{"offset": nil, "cursor": nil}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(offset))
+	}
+	return q
+}
+
+// CursorPageDetails returns the paging information for a page retrieved using cursors, in reply
+// to r. The previous and next page URLs are those of r with the cursor parameter set to prev and
+// next respectively, and the offset parameter removed; see PageURL. A URL is omitted if its cursor
+// is empty. The sort order is not known, so the caller should set the Sort of the result to that
+// returned by FormatSort for the sort applied.
+func CursorPageDetails(r *http.Request, prev, next string, opts ...Option) *PageDetails {
+	pd := &PageDetails{}
+	if prev != "" {
+		pd.Prev = PageURL(r, url.Values{"cursor": {prev}, "offset": nil}, opts...)
+	}
+	if next != "" {
+		pd.Next = PageURL(r, url.Values{"cursor": {next}, "offset": nil}, opts...)
+	}
+	return pd
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestPageURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		tls    bool
+		header http.Header
+		q      url.Values
+		opts   []Option
+		want   string
+	}{
+		{"Plain", "/things", false, nil, nil, nil, "http://example.com/things"},
+		{"TLS", "/things", true, nil, nil, nil, "https://example.com/things"},
+		{"Root", "", false, nil, nil, nil, "http://example.com/"},
+		{"Preserve", "/things?q=a+b&limit=5", false, nil, url.Values{"offset": {"10"}}, nil, "http://example.com/things?limit=5&offset=10&q=a+b"},
+		{"Replace", "/things?offset=5", false, nil, url.Values{"offset": {"10"}}, nil, "http://example.com/things?offset=10"},
+		{"Remove", "/things?offset=5&cursor=c", false, nil, url.Values{"offset": nil, "cursor": {""}}, nil, "http://example.com/things"},
+		{"EscapedPath", "/things/a%2Fb", false, nil, nil, nil, "http://example.com/things/a%2Fb"},
+		{"ForwardedIgnored", "/things", false, http.Header{"Forwarded": {"proto=https;host=api.example.org"}}, nil, nil, "http://example.com/things"},
+		{"Forwarded", "/things", false, http.Header{"Forwarded": {`for=1.2.3.4;proto=https;host="api.example.org", for=5.6.7.8`}}, nil, []Option{WithForwardedHeaders()}, "https://api.example.org/things"},
+		{"ForwardedPrecedence", "/things", false, http.Header{"Forwarded": {"host=a.example.org"}, "X-Forwarded-Host": {"b.example.org"}}, nil, []Option{WithForwardedHeaders()}, "http://a.example.org/things"},
+		{"XForwarded", "/things", false, http.Header{"X-Forwarded-Proto": {"https, http"}, "X-Forwarded-Host": {"api.example.org, proxy"}}, nil, []Option{WithForwardedHeaders()}, "https://api.example.org/things"},
+		{"XForwardedInvalidProto", "/things", true, http.Header{"X-Forwarded-Proto": {"ftp"}}, nil, []Option{WithForwardedHeaders()}, "https://example.com/things"},
+		{"XForwardedPrefix", "/things", false, http.Header{"X-Forwarded-Prefix": {"/api/"}}, nil, []Option{WithForwardedHeaders()}, "http://example.com/api/things"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.url, nil)
+			r.TLS = nil
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+
+			if got := PageURL(r, tt.q, tt.opts...); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOffsetPageDetails(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   string
+		pr    PageRequest
+		n     int
+		total int64
+		want  *PageDetails
+	}{
+		{"First", "/things?limit=10", PageRequest{Limit: 10}, 10, 25, &PageDetails{
+			Next:      "http://example.com/things?limit=10&offset=10",
+			TotalSize: 25,
+		}},
+		{"Middle", "/things?limit=10&offset=10", PageRequest{Limit: 10, Offset: 10}, 10, 25, &PageDetails{
+			Prev:      "http://example.com/things?limit=10",
+			Next:      "http://example.com/things?limit=10&offset=20",
+			TotalSize: 25,
+		}},
+		{"Last", "/things?limit=10&offset=20", PageRequest{Limit: 10, Offset: 20}, 5, 25, &PageDetails{
+			Prev:      "http://example.com/things?limit=10&offset=10",
+			TotalSize: 25,
+		}},
+		{"PartialPrev", "/things?offset=5", PageRequest{Limit: 10, Offset: 5}, 10, 15, &PageDetails{
+			Prev:      "http://example.com/things",
+			TotalSize: 15,
+		}},
+		{"Empty", "/things", PageRequest{Limit: 10}, 0, 0, &PageDetails{ZeroTotal: true}},
+		{"UnknownFull", "/things", PageRequest{Limit: 10}, 10, UnknownTotal, &PageDetails{
+			Next: "http://example.com/things?offset=10",
+		}},
+		{"UnknownPartial", "/things", PageRequest{Limit: 10}, 3, UnknownTotal, &PageDetails{}},
+		{"Sort", "/things?sort=-created", PageRequest{Limit: 10, Sort: []SortField{{"created", true}}}, 10, 20, &PageDetails{
+			Next:      "http://example.com/things?offset=10&sort=-created",
+			TotalSize: 20,
+			Sort:      "-created",
+		}},
+		{"Cursor", "/things?cursor=c", PageRequest{Limit: 10}, 10, 20, &PageDetails{
+			Next:      "http://example.com/things?offset=10",
+			TotalSize: 20,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.url, nil)
+			if got := OffsetPageDetails(r, tt.pr, tt.n, tt.total); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCursorPageDetails(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/things?limit=5&offset=5&cursor=b", nil)
+
+	tests := []struct {
+		name       string
+		prev, next string
+		want       *PageDetails
+	}{
+		{"None", "", "", &PageDetails{}},
+		{"Next", "", "c", &PageDetails{Next: "http://example.com/things?cursor=c&limit=5"}},
+		{"Both", "a", "c", &PageDetails{
+			Prev: "http://example.com/things?cursor=a&limit=5",
+			Next: "http://example.com/things?cursor=c&limit=5",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CursorPageDetails(r, tt.prev, tt.next); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
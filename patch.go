@@ -0,0 +1,533 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// JSONPatchType is the media type of a JSON Patch document (RFC 6902).
+	JSONPatchType = "application/json-patch+json"
+
+	// MergePatchType is the media type of a JSON Merge Patch document (RFC 7386).
+	MergePatchType = "application/merge-patch+json"
+)
+
+// ReadPatch reads the patch document in the body of r, and applies it to target, which must be a
+// non-nil pointer. The request must have a Content-Type of JSONPatchType or MergePatchType. The
+// body is read in the same way as ReadRequest, and the patched document is unmarshalled into
+// target as if by WithStrict, replacing its previous contents. Struct fields that are not encoded
+// as JSON, such as those tagged `json:"-"`, are retained. If the patch cannot be applied, target
+// is unmodified.
+//
+// If the patch cannot be applied, the returned error is an Error suitable for writing with
+// WriteRequestError. A 400 status code indicates an invalid patch document or patched result, and
+// a 409 status code indicates a patch that conflicts with the current state of target.
+func ReadPatch(r *http.Request, target interface{}, opts ...Option) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("jsonresp: patch target must be a non-nil pointer")
+	}
+
+	var apply func(doc, patch []byte) ([]byte, error)
+
+	ct := r.Header.Get("Content-Type")
+	switch mt, _, _ := mime.ParseMediaType(ct); mt {
+	case JSONPatchType:
+		apply = ApplyJSONPatch
+	case MergePatchType:
+		apply = ApplyMergePatch
+	default:
+		return &Error{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("unsupported content type %q, want %q or %q", ct, JSONPatchType, MergePatchType),
+			Details: map[string]interface{}{"supported": []string{JSONPatchType, MergePatchType}},
+		}
+	}
+
+	var patch json.RawMessage
+	if err := ReadRequest(r, &patch, joinOptions(opts, []Option{WithFormat(JSON), WithContentType(ct)})...); err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to marshal patch target: %v", err)
+	}
+
+	b, err := apply(doc, patch)
+	if err != nil {
+		return err
+	}
+
+	// The result is decoded into a copy of target, so that target is unmodified if it is invalid.
+	nv := reflect.New(rv.Elem().Type())
+	nv.Elem().Set(rv.Elem())
+	clearJSONFields(nv.Elem())
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(nv.Interface()); err != nil {
+		return NewError("invalid patch result: "+err.Error(), http.StatusBadRequest)
+	}
+	rv.Elem().Set(nv.Elem())
+	return nil
+}
+
+// clearJSONFields sets the fields of the struct v that are encoded by encoding/json to their zero
+// values, so that members removed by a patch are not retained when the result is decoded into v.
+// Fields that are not encoded, such as unexported fields and those tagged `json:"-"`, are
+// retained. If v is not a struct, it is set to its zero value.
+func clearJSONFields(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f, fv := v.Type().Field(i), v.Field(i)
+		switch {
+		case f.Tag.Get("json") == "-":
+		case f.Anonymous && f.Type.Kind() == reflect.Struct:
+			clearJSONFields(fv)
+		case fv.CanSet():
+			fv.Set(reflect.Zero(f.Type))
+		}
+	}
+}
+
+// ApplyMergePatch applies the JSON Merge Patch document patch to the JSON document doc, and
+// returns the result. If either document is not valid JSON, an Error with a 400 status code is
+// returned.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	d, err := decodePatchValue(doc)
+	if err != nil {
+		return nil, NewError("invalid document: "+err.Error(), http.StatusBadRequest)
+	}
+	p, err := decodePatchValue(patch)
+	if err != nil {
+		return nil, NewError("invalid merge patch: "+err.Error(), http.StatusBadRequest)
+	}
+	return json.Marshal(mergePatch(d, p))
+}
+
+// mergePatch returns target with patch merged into it, as described by RFC 7386.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// patchOperation is an operation of a JSON Patch document.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"` // empty if not present
+}
+
+// patchConflict returns an Error describing a JSON Patch operation that conflicts with the
+// document it is applied to.
+func patchConflict(i int, format string, a ...interface{}) *Error {
+	return &Error{
+		Code:    http.StatusConflict,
+		Message: fmt.Sprintf("patch operation %v: %v", i, fmt.Sprintf(format, a...)),
+		Details: map[string]interface{}{"operation": i},
+	}
+}
+
+// invalidPatch returns an Error describing an invalid JSON Patch operation.
+func invalidPatch(i int, format string, a ...interface{}) *Error {
+	return &Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("invalid patch operation %v: %v", i, fmt.Sprintf(format, a...)),
+		Details: map[string]interface{}{"operation": i},
+	}
+}
+
+// ApplyJSONPatch applies the JSON Patch document patch to the JSON document doc, and returns the
+// result. Operations are applied in turn, and if any fails, the document is left unchanged. If
+// either document is invalid, an Error with a 400 status code is returned. If an operation
+// refers to a location that does not exist, or a test operation fails, an Error with a 409 status
+// code is returned.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	d, err := decodePatchValue(doc)
+	if err != nil {
+		return nil, NewError("invalid document: "+err.Error(), http.StatusBadRequest)
+	}
+
+	var ops []patchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, NewError("invalid patch: "+err.Error(), http.StatusBadRequest)
+	}
+
+	for i, op := range ops {
+		if d, err = applyPatchOperation(d, i, op); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(d)
+}
+
+// parsedOperation is a JSON Patch operation with its pointers and value decoded.
+type parsedOperation struct {
+	op    string
+	path  []string
+	from  []string
+	value interface{}
+}
+
+// parsePatchOperation parses op, the operation at index i of a JSON Patch document.
+func parsePatchOperation(i int, op patchOperation) (parsedOperation, error) {
+	po := parsedOperation{op: op.Op}
+
+	if op.Path == nil {
+		return po, invalidPatch(i, "missing path")
+	}
+	var err error
+	if po.path, err = parsePointer(*op.Path); err != nil {
+		return po, invalidPatch(i, "%v", err)
+	}
+
+	switch op.Op {
+	case "move", "copy":
+		if op.From == nil {
+			return po, invalidPatch(i, "missing from")
+		}
+		if po.from, err = parsePointer(*op.From); err != nil {
+			return po, invalidPatch(i, "%v", err)
+		}
+		if op.Op == "move" && len(po.from) < len(po.path) && isPrefix(po.from, po.path) {
+			return po, invalidPatch(i, "cannot move %q into itself", *op.From)
+		}
+
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return po, invalidPatch(i, "missing value")
+		}
+		if po.value, err = decodePatchValue(op.Value); err != nil {
+			return po, invalidPatch(i, "%v", err)
+		}
+
+	case "remove":
+
+	default:
+		return po, invalidPatch(i, "unknown op %q", op.Op)
+	}
+	return po, nil
+}
+
+// applyPatchOperation applies op, the operation at index i of a JSON Patch document, to doc.
+func applyPatchOperation(doc interface{}, i int, op patchOperation) (interface{}, error) {
+	po, err := parsePatchOperation(i, op)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	switch po.op {
+	case "add":
+		doc, err = addValue(doc, po.path, po.value)
+
+	case "remove":
+		doc, _, err = removeValue(doc, po.path)
+
+	case "replace":
+		if len(po.path) == 0 {
+			return po.value, nil
+		}
+		if doc, _, err = removeValue(doc, po.path); err == nil {
+			doc, err = addValue(doc, po.path, po.value)
+		}
+
+	case "move":
+		if doc, v, err = removeValue(doc, po.from); err == nil {
+			doc, err = addValue(doc, po.path, v)
+		}
+
+	case "copy":
+		if v, err = getValue(doc, po.from); err == nil {
+			doc, err = addValue(doc, po.path, deepCopy(v))
+		}
+
+	case "test":
+		if v, err = getValue(doc, po.path); err == nil && !jsonEqual(v, po.value) {
+			return nil, patchConflict(i, "test failed at %q", *op.Path)
+		}
+	}
+
+	if err != nil {
+		return nil, patchConflict(i, "%v", err)
+	}
+	return doc, nil
+}
+
+// decodePatchValue decodes the JSON value b, retaining the precision of numbers.
+func decodePatchValue(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after value")
+	}
+	return v, nil
+}
+
+// parsePointer parses the JSON Pointer (RFC 6901) p into its reference tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q", p)
+	}
+
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// isPrefix reports whether the pointer tokens a are a prefix of b.
+func isPrefix(a, b []string) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// arrayIndex parses the array index token t, which must be less than n.
+func arrayIndex(t string, n int) (int, error) {
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || strconv.Itoa(i) != t {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	if i >= n {
+		return 0, fmt.Errorf("array index %v out of range", i)
+	}
+	return i, nil
+}
+
+// getValue returns the value at the location in doc identified by tokens.
+func getValue(doc interface{}, tokens []string) (interface{}, error) {
+	for _, t := range tokens {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(c))
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", doc, t)
+		}
+	}
+	return doc, nil
+}
+
+// modifyParent calls fn with the container in doc that holds the location identified by tokens,
+// and the final token, and returns doc with the container replaced by the one fn returns.
+func modifyParent(doc interface{}, tokens []string, fn func(c interface{}, t string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		v, ok := c[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", tokens[0])
+		}
+		v, err := modifyParent(v, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[tokens[0]] = v
+		return c, nil
+
+	case []interface{}:
+		i, err := arrayIndex(tokens[0], len(c))
+		if err != nil {
+			return nil, err
+		}
+		v, err := modifyParent(c[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = v
+		return c, nil
+
+	default:
+		return nil, fmt.Errorf("cannot index %T with %q", doc, tokens[0])
+	}
+}
+
+// addValue returns doc with v added at the location identified by tokens.
+func addValue(doc interface{}, tokens []string, v interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return v, nil
+	}
+
+	return modifyParent(doc, tokens, func(c interface{}, t string) (interface{}, error) {
+		switch c := c.(type) {
+		case map[string]interface{}:
+			c[t] = v
+			return c, nil
+
+		case []interface{}:
+			i := len(c)
+			if t != "-" {
+				var err error
+				if i, err = arrayIndex(t, len(c)+1); err != nil {
+					return nil, err
+				}
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = v
+			return c, nil
+
+		default:
+			return nil, fmt.Errorf("cannot add %q to %T", t, c)
+		}
+	})
+}
+
+// removeValue returns doc with the value at the location identified by tokens removed, along
+// with the removed value.
+func removeValue(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+
+	var removed interface{}
+	doc, err := modifyParent(doc, tokens, func(c interface{}, t string) (interface{}, error) {
+		switch c := c.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			removed = v
+			delete(c, t)
+			return c, nil
+
+		case []interface{}:
+			i, err := arrayIndex(t, len(c))
+			if err != nil {
+				return nil, err
+			}
+			removed = c[i]
+			return append(c[:i:i], c[i+1:]...), nil
+
+		default:
+			return nil, fmt.Errorf("cannot remove %q from %T", t, c)
+		}
+	})
+	return doc, removed, err
+}
+
+// deepCopy returns a copy of the decoded JSON value v that shares no containers with it.
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = deepCopy(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = deepCopy(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// jsonEqual reports whether the decoded JSON values a and b are equal, as defined by the test
+// operation of RFC 6902. Numbers are equal if their values are numerically equal.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okx := new(big.Rat).SetString(a.String())
+		y, oky := new(big.Rat).SetString(b.String())
+		return okx && oky && x.Cmp(y) == 0
+
+	default:
+		return a == b
+	}
+}
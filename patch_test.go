@@ -0,0 +1,206 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		want     string
+		wantCode int
+	}{
+		// Examples from RFC 6902, Appendix A.
+		{"AddMember", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, 0},
+		{"AddElement", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, 0},
+		{"RemoveMember", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, 0},
+		{"RemoveElement", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, 0},
+		{"Replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, 0},
+		{"Move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, 0},
+		{"MoveElement", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`, 0},
+		{"Test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`, 0},
+		{"TestFailed", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, http.StatusConflict},
+		{"AddNested", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"child":{"grandchild":{}},"foo":"bar"}`, 0},
+		{"UnknownOp", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"baz":"qux","foo":"bar"}`, 0},
+		{"AddToMissing", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, http.StatusConflict},
+		{"Escaped", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10}]`, `{"/":9,"~1":10}`, 0},
+		{"TestNumberNotString", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":"10"}]`, ``, http.StatusConflict},
+		{"AppendElement", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`, 0},
+
+		// Further cases.
+		{"AddRoot", `{"foo":"bar"}`, `[{"op":"add","path":"","value":[1]}]`, `[1]`, 0},
+		{"ReplaceRoot", `{"foo":"bar"}`, `[{"op":"replace","path":"","value":null}]`, `null`, 0},
+		{"ReplaceMissing", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, ``, http.StatusConflict},
+		{"RemoveRoot", `{"foo":"bar"}`, `[{"op":"remove","path":""}]`, ``, http.StatusConflict},
+		{"RemoveOutOfRange", `{"foo":[1]}`, `[{"op":"remove","path":"/foo/1"}]`, ``, http.StatusConflict},
+		{"AddOutOfRange", `{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":2}]`, ``, http.StatusConflict},
+		{"LeadingZero", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/01"}]`, ``, http.StatusConflict},
+		{"Copy", `{"foo":{"a":1}}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"add","path":"/bar/b","value":2}]`, `{"bar":{"a":1,"b":2},"foo":{"a":1}}`, 0},
+		{"MoveIntoChild", `{"foo":{"a":1}}`, `[{"op":"move","from":"/foo","path":"/foo/b"}]`, ``, http.StatusBadRequest},
+		{"TestNumeric", `{"foo":1}`, `[{"op":"test","path":"/foo","value":1.0}]`, `{"foo":1}`, 0},
+		{"TestObject", `{"foo":{"a":[1,{"b":null}]}}`, `[{"op":"test","path":"/foo","value":{"a":[1,{"b":null}]}}]`, `{"foo":{"a":[1,{"b":null}]}}`, 0},
+		{"Precision", `{"foo":9007199254740993}`, `[{"op":"add","path":"/bar","value":1}]`, `{"bar":1,"foo":9007199254740993}`, 0},
+		{"AddNull", `{}`, `[{"op":"add","path":"/foo","value":null}]`, `{"foo":null}`, 0},
+		{"MissingValue", `{}`, `[{"op":"add","path":"/foo"}]`, ``, http.StatusBadRequest},
+		{"MissingPath", `{}`, `[{"op":"remove"}]`, ``, http.StatusBadRequest},
+		{"MissingFrom", `{}`, `[{"op":"copy","path":"/foo"}]`, ``, http.StatusBadRequest},
+		{"InvalidPointer", `{}`, `[{"op":"remove","path":"foo"}]`, ``, http.StatusBadRequest},
+		{"InvalidOp", `{}`, `[{"op":"frobnicate","path":"/foo"}]`, ``, http.StatusBadRequest},
+		{"InvalidPatch", `{}`, `{"op":"add"}`, ``, http.StatusBadRequest},
+		{"InvalidDocument", `{`, `[]`, ``, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ApplyJSONPatch([]byte(tt.doc), []byte(tt.patch))
+
+			if tt.wantCode != 0 {
+				if !errors.Is(err, &Error{Code: tt.wantCode}) {
+					t.Fatalf("got error %v, want code %v", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to apply patch: %v", err)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr bool
+	}{
+		// Examples from RFC 7386, Appendix A.
+		{"Replace", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`, false},
+		{"Add", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`, false},
+		{"Remove", `{"a":"b"}`, `{"a":null}`, `{}`, false},
+		{"RemoveOne", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`, false},
+		{"ReplaceArray", `{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`, false},
+		{"ReplaceWithArray", `{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`, false},
+		{"Nested", `{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`, false},
+		{"ArrayOfObjects", `{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`, false},
+		{"ArrayDocument", `["a","b"]`, `["c","d"]`, `["c","d"]`, false},
+		{"ObjectOverArray", `{"a":"b"}`, `["c"]`, `["c"]`, false},
+		{"NullPatch", `{"a":"foo"}`, `null`, `null`, false},
+		{"StringPatch", `{"a":"foo"}`, `"bar"`, `"bar"`, false},
+		{"NullMember", `{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`, false},
+		{"ArrayToObject", `[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`, false},
+		{"DeepNull", `{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`, false},
+
+		{"InvalidDocument", `{`, `{}`, ``, true},
+		{"InvalidPatch", `{}`, `{`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ApplyMergePatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.wantErr {
+				if !errors.Is(err, &Error{Code: http.StatusBadRequest}) {
+					t.Fatalf("got error %v, want code %v", err, http.StatusBadRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to apply patch: %v", err)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadPatch(t *testing.T) {
+	type TestStruct struct {
+		ID   int      `json:"-"`
+		Name string   `json:"name,omitempty"`
+		Tags []string `json:"tags,omitempty"`
+		Size int      `json:"size,omitempty"`
+		rev  int
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        TestStruct
+		wantCode    int
+	}{
+		{"JSONPatch", JSONPatchType, `[{"op":"add","path":"/tags/-","value":"c"},{"op":"remove","path":"/name"}]`, TestStruct{ID: 7, Tags: []string{"a", "b", "c"}, Size: 1, rev: 3}, 0},
+		{"MergePatch", MergePatchType, `{"name":null,"size":2}`, TestStruct{ID: 7, Tags: []string{"a", "b"}, Size: 2, rev: 3}, 0},
+		{"MergePatchCharset", MergePatchType + "; charset=utf-8", `{"size":2}`, TestStruct{ID: 7, Name: "blah", Tags: []string{"a", "b"}, Size: 2, rev: 3}, 0},
+		{"Conflict", JSONPatchType, `[{"op":"test","path":"/size","value":2}]`, TestStruct{}, http.StatusConflict},
+		{"UnknownField", MergePatchType, `{"other":1}`, TestStruct{}, http.StatusBadRequest},
+		{"WrongType", MergePatchType, `{"size":"big"}`, TestStruct{}, http.StatusBadRequest},
+		{"WrongTypeLater", MergePatchType, `{"name":"new","size":"big"}`, TestStruct{}, http.StatusBadRequest},
+		{"UnsupportedType", "application/json", `{}`, TestStruct{}, http.StatusUnsupportedMediaType},
+		{"InvalidBody", JSONPatchType, `[`, TestStruct{}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			orig := TestStruct{ID: 7, Name: "blah", Tags: []string{"a", "b"}, Size: 1, rev: 3}
+			ts := orig
+			err := ReadPatch(r, &ts)
+
+			if tt.wantCode != 0 {
+				if !errors.Is(err, &Error{Code: tt.wantCode}) {
+					t.Fatalf("got error %v, want code %v", err, tt.wantCode)
+				}
+				if !reflect.DeepEqual(ts, orig) {
+					t.Errorf("got %+v, want unmodified %+v", ts, orig)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+			if got, want := ts, tt.want; !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadPatchTarget(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{}`))
+	r.Header.Set("Content-Type", MergePatchType)
+
+	var je *Error
+	if err := ReadPatch(r, struct{}{}); err == nil || errors.As(err, &je) {
+		t.Errorf("got error %v, want non-Error error", err)
+	}
+}
+
+func TestReadPatchMap(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"a":null,"c":3}`))
+	r.Header.Set("Content-Type", MergePatchType)
+
+	m := map[string]int{"a": 1, "b": 2}
+	if err := ReadPatch(r, &m); err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+	if got, want := m, map[string]int{"b": 2, "c": 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"mime"
+	"net/http"
+)
+
+// maxPlainErrorSize is the maximum number of bytes of a plain text error body used as the message
+// of the JSON error response that replaces it.
+const maxPlainErrorSize = 1024
+
+// PlainErrorHandler is an http.Handler that rewrites plain text error responses written by
+// Handler, such as by http.Error, http.NotFound or third-party middleware, as JSON error
+// responses, so that clients can parse every error response in the same way. A response is
+// rewritten if it has a 4xx or 5xx status code, and a Content-Type header that is absent or
+// text/plain. The text of the response, with surrounding whitespace removed, is used as the
+// message of the error, or if empty, the text of the status code. Other responses are passed
+// through unchanged.
+type PlainErrorHandler struct {
+	// Handler serves requests.
+	Handler http.Handler
+
+	// Options are the options with which errors are written, in addition to WithRequest.
+	Options []Option
+}
+
+// ServeHTTP serves the request r.
+func (ph *PlainErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pw := &plainErrorWriter{ResponseWriter: w}
+	ph.Handler.ServeHTTP(pw, r)
+	if pw.code == 0 || pw.body == nil {
+		return
+	}
+
+	message := pw.body.String()
+	if message == "" {
+		message = http.StatusText(pw.code)
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Del("Content-Type")
+	opts := append([]Option{WithRequest(r)}, ph.Options...)
+	_ = WriteError(w, message, pw.code, opts...)
+}
+
+// plainErrorWriter is an http.ResponseWriter that retains the body of a plain text error
+// response, rather than writing it to the underlying ResponseWriter.
+type plainErrorWriter struct {
+	http.ResponseWriter
+	code int
+	body *snippet // nil if not rewriting
+}
+
+// isPlainText reports whether a response with Content-Type header ct is plain text.
+func isPlainText(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && mt == "text/plain"
+}
+
+func (pw *plainErrorWriter) WriteHeader(code int) {
+	if pw.code != 0 {
+		return
+	}
+	if code >= 100 && code < 200 {
+		// Informational responses precede the final response.
+		pw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	pw.code = code
+	if code >= http.StatusBadRequest && isPlainText(pw.Header().Get("Content-Type")) {
+		pw.body = &snippet{max: maxPlainErrorSize}
+		return
+	}
+	pw.ResponseWriter.WriteHeader(code)
+}
+
+func (pw *plainErrorWriter) Write(p []byte) (int, error) {
+	if pw.code == 0 {
+		pw.WriteHeader(http.StatusOK)
+	}
+	if pw.body != nil {
+		return pw.body.Write(p)
+	}
+	return pw.ResponseWriter.Write(p)
+}
+
+// Flush flushes the underlying ResponseWriter, unless the response is being rewritten.
+func (pw *plainErrorWriter) Flush() {
+	if pw.code == 0 {
+		pw.WriteHeader(http.StatusOK)
+	}
+	if pw.body != nil {
+		return
+	}
+	if f, ok := pw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (pw *plainErrorWriter) Unwrap() http.ResponseWriter { return pw.ResponseWriter }
@@ -0,0 +1,140 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPlainErrorHandler(t *testing.T) {
+	tests := []struct {
+		name            string
+		h               http.HandlerFunc
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Error",
+			h:               func(w http.ResponseWriter, r *http.Request) { http.Error(w, "blah", http.StatusForbidden) },
+			wantCode:        http.StatusForbidden,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":403,"message":"blah"}}`,
+		},
+		{
+			name:            "NotFound",
+			h:               http.NotFound,
+			wantCode:        http.StatusNotFound,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":404,"message":"404 page not found"}}`,
+		},
+		{
+			name: "NoContentType",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte("bad "))
+				_, _ = w.Write([]byte("gateway\n"))
+			},
+			wantCode:        http.StatusBadGateway,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":502,"message":"bad gateway"}}`,
+		},
+		{
+			name:            "Empty",
+			h:               func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+			wantCode:        http.StatusUnauthorized,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":401,"message":"Unauthorized"}}`,
+		},
+		{
+			name: "Long",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, strings.Repeat("a", maxPlainErrorSize+1), http.StatusBadRequest)
+			},
+			wantCode:        http.StatusBadRequest,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":400,"message":"` + strings.Repeat("a", maxPlainErrorSize) + `..."}}`,
+		},
+		{
+			name: "JSONError",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				_ = WriteError(w, "blah", http.StatusConflict)
+			},
+			wantCode:        http.StatusConflict,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":409,"message":"blah"}}`,
+		},
+		{
+			name: "HTMLError",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html")
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte("<p>blah</p>"))
+			},
+			wantCode:        http.StatusNotFound,
+			wantContentType: "text/html",
+			wantBody:        "<p>blah</p>",
+		},
+		{
+			name: "PlainSuccess",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				_, _ = w.Write([]byte("blah"))
+			},
+			wantCode:        http.StatusOK,
+			wantContentType: "text/plain",
+			wantBody:        "blah",
+		},
+		{
+			name: "Flush",
+			h: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.(http.Flusher).Flush()
+				_, _ = w.Write([]byte("blah"))
+			},
+			wantCode:        http.StatusServiceUnavailable,
+			wantContentType: "application/json",
+			wantBody:        `{"error":{"code":503,"message":"blah"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			h := &PlainErrorHandler{Handler: tt.h}
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPlainErrorHandlerOptions(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	h := &PlainErrorHandler{
+		Handler: http.NotFoundHandler(),
+		Options: []Option{WithMeta("a", "b")},
+	}
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got, want := rr.Body.String(), `{"error":{"code":404,"message":"404 page not found"},"meta":{"a":"b"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
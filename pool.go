@@ -0,0 +1,108 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledSize is the capacity above which buffers are not returned to the pool, so that an
+// occasional large response does not pin a large allocation indefinitely.
+const maxPooledSize = 64 << 10
+
+// encodeState is a reusable buffer, along with an encoder that writes to it.
+type encodeState struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+var encodeStatePool = sync.Pool{
+	New: func() interface{} {
+		es := &encodeState{}
+		es.enc = json.NewEncoder(&es.Buffer)
+		return es
+	},
+}
+
+// newEncodeState returns an empty encodeState from the pool.
+func newEncodeState() *encodeState {
+	return encodeStatePool.Get().(*encodeState)
+}
+
+// release returns es to the pool.
+func (es *encodeState) release() {
+	if es.Cap() > maxPooledSize {
+		return
+	}
+	es.Reset()
+	encodeStatePool.Put(es)
+}
+
+// encode encodes v into es, applying the field names, marshal function, indentation and format of
+// o. Formats that encode values directly do so unless the marshal function or canonical form of o
+// apply, which are defined in terms of JSON.
+func (es *encodeState) encode(v interface{}, o *options) error {
+	if o.format == nil {
+		return es.encodeJSON(o.renameFields(v), o)
+	}
+	if vf, ok := o.format.(valueFormat); ok && o.marshal == nil && !o.canonical {
+		return vf.encodeValue(&es.Buffer, o.renameFields(v))
+	}
+
+	js := newEncodeState()
+	defer js.release()
+
+	if err := js.encodeJSON(o.renameFields(v), o); err != nil {
+		return err
+	}
+	return o.format.FromJSON(&es.Buffer, js.Bytes())
+}
+
+// encodeJSON encodes v into es as JSON, applying the marshal function and indentation of o, or
+// its canonical form if o is canonical.
+func (es *encodeState) encodeJSON(v interface{}, o *options) error {
+	if o.canonical {
+		b, err := o.marshalValue(v)
+		if err != nil {
+			return err
+		}
+		if b, err = canonicalJSON(b); err != nil {
+			return err
+		}
+		_, err = es.Write(b)
+		return err
+	}
+
+	if o.marshal == nil {
+		es.enc.SetIndent(o.prefix, o.indent)
+		if err := es.enc.Encode(v); err != nil {
+			return err
+		}
+		// Unlike json.Marshal, json.Encoder terminates each value with a newline.
+		es.Truncate(es.Len() - 1)
+		return nil
+	}
+
+	b, err := o.marshal(v)
+	if err != nil {
+		return err
+	}
+	if o.prefix != "" || o.indent != "" {
+		return json.Indent(&es.Buffer, b, o.prefix, o.indent)
+	}
+	_, err = es.Write(b)
+	return err
+}
+
+// marshalValue returns the JSON encoding of v, using the marshal function of o.
+func (o *options) marshalValue(v interface{}) ([]byte, error) {
+	if o.marshal == nil {
+		return json.Marshal(v)
+	}
+	return o.marshal(v)
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// discardResponseWriter is a http.ResponseWriter that discards everything written to it, so that
+// benchmarks measure only the allocations of this package.
+type discardResponseWriter struct {
+	h http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.h }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+func TestEncodeStateRelease(t *testing.T) {
+	es := newEncodeState()
+	es.WriteString("blah")
+	es.release()
+
+	if got, want := newEncodeState().Len(), 0; got != want {
+		t.Errorf("got length %v, want %v", got, want)
+	}
+
+	es = newEncodeState()
+	es.WriteString(strings.Repeat("x", maxPooledSize+1))
+	es.release()
+	if got, want := es.Len(), maxPooledSize+1; got != want {
+		t.Errorf("got length %v, want %v", got, want)
+	}
+}
+
+func benchmarkWriteResponse(b *testing.B, opts ...Option) {
+	type TestStruct struct {
+		Name  string   `json:"name"`
+		Size  int      `json:"size"`
+		Tags  []string `json:"tags"`
+		Valid bool     `json:"valid"`
+	}
+
+	data := make([]TestStruct, 16)
+	for i := range data {
+		data[i] = TestStruct{"blah", i, []string{"a", "b", "c"}, true}
+	}
+
+	w := &discardResponseWriter{h: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteResponse(w, data, http.StatusOK, opts...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteResponse(b *testing.B) {
+	b.Run("Pooled", func(b *testing.B) {
+		benchmarkWriteResponse(b)
+	})
+	b.Run("Marshal", func(b *testing.B) {
+		benchmarkWriteResponse(b, WithEncoder(json.Marshal))
+	})
+}
+
+func BenchmarkWriteError(b *testing.B) {
+	w := &discardResponseWriter{h: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteError(w, "blah", http.StatusNotFound); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteErrorOptions(b *testing.B) {
+	w := &discardResponseWriter{h: make(http.Header)}
+	opts := []Option{WithHeader("X-Blah", "blah")}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteError(w, "blah", http.StatusNotFound, opts...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteResponseFlat(b *testing.B) {
+	type TestStruct struct {
+		Name  string  `json:"name"`
+		Size  int     `json:"size"`
+		Ratio float64 `json:"ratio,omitempty"`
+		Valid bool    `json:"valid"`
+	}
+	data := &TestStruct{"blah", 42, 0.5, true}
+
+	w := &discardResponseWriter{h: make(http.Header)}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteResponse(w, data, http.StatusOK); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
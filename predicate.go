@@ -0,0 +1,56 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+)
+
+// StatusCode returns the status code of the first Error in the chain of err, as found by
+// errors.As. If the chain does not contain an Error, false is returned.
+func StatusCode(err error) (int, bool) {
+	var je *Error
+	if !errors.As(err, &je) {
+		return 0, false
+	}
+	return je.Code, true
+}
+
+// HasStatus returns true if the chain of err contains an Error with the supplied status code.
+func HasStatus(err error, code int) bool {
+	c, ok := StatusCode(err)
+	return ok && c == code
+}
+
+// IsBadRequest returns true if the chain of err contains an Error with status 400 Bad Request.
+func IsBadRequest(err error) bool { return HasStatus(err, http.StatusBadRequest) }
+
+// IsUnauthorized returns true if the chain of err contains an Error with status 401 Unauthorized.
+func IsUnauthorized(err error) bool { return HasStatus(err, http.StatusUnauthorized) }
+
+// IsForbidden returns true if the chain of err contains an Error with status 403 Forbidden.
+func IsForbidden(err error) bool { return HasStatus(err, http.StatusForbidden) }
+
+// IsNotFound returns true if the chain of err contains an Error with status 404 Not Found.
+func IsNotFound(err error) bool { return HasStatus(err, http.StatusNotFound) }
+
+// IsConflict returns true if the chain of err contains an Error with status 409 Conflict.
+func IsConflict(err error) bool { return HasStatus(err, http.StatusConflict) }
+
+// IsPreconditionFailed returns true if the chain of err contains an Error with status 412
+// Precondition Failed.
+func IsPreconditionFailed(err error) bool { return HasStatus(err, http.StatusPreconditionFailed) }
+
+// IsTooManyRequests returns true if the chain of err contains an Error with status 429 Too Many
+// Requests.
+func IsTooManyRequests(err error) bool { return HasStatus(err, http.StatusTooManyRequests) }
+
+// IsClientError returns true if the chain of err contains an Error with a 4xx status code.
+func IsClientError(err error) bool { return errors.Is(err, ErrClientError) }
+
+// IsServerError returns true if the chain of err contains an Error with a 5xx status code.
+func IsServerError(err error) bool { return errors.Is(err, ErrServerError) }
@@ -0,0 +1,85 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantOK   bool
+	}{
+		{"Nil", nil, 0, false},
+		{"Other", errors.New("blah"), 0, false},
+		{"Error", NewError("blah", http.StatusNotFound), http.StatusNotFound, true},
+		{"Wrapped", fmt.Errorf("a: %w", fmt.Errorf("b: %w", NewError("blah", http.StatusConflict))), http.StatusConflict, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ok := StatusCode(tt.err)
+			if got, want := code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := ok, tt.wantOK; got != want {
+				t.Errorf("got ok %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	predicates := map[string]func(error) bool{
+		"IsBadRequest":         IsBadRequest,
+		"IsUnauthorized":       IsUnauthorized,
+		"IsForbidden":          IsForbidden,
+		"IsNotFound":           IsNotFound,
+		"IsConflict":           IsConflict,
+		"IsPreconditionFailed": IsPreconditionFailed,
+		"IsTooManyRequests":    IsTooManyRequests,
+		"IsClientError":        IsClientError,
+		"IsServerError":        IsServerError,
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want []string
+	}{
+		{"Nil", nil, nil},
+		{"Other", errors.New("blah"), nil},
+		{"BadRequest", NewError("", http.StatusBadRequest), []string{"IsBadRequest", "IsClientError"}},
+		{"Unauthorized", NewError("", http.StatusUnauthorized), []string{"IsUnauthorized", "IsClientError"}},
+		{"Forbidden", NewError("", http.StatusForbidden), []string{"IsForbidden", "IsClientError"}},
+		{"NotFound", NewError("", http.StatusNotFound), []string{"IsNotFound", "IsClientError"}},
+		{"Conflict", NewError("", http.StatusConflict), []string{"IsConflict", "IsClientError"}},
+		{"PreconditionFailed", NewError("", http.StatusPreconditionFailed), []string{"IsPreconditionFailed", "IsClientError"}},
+		{"TooManyRequests", NewError("", http.StatusTooManyRequests), []string{"IsTooManyRequests", "IsClientError"}},
+		{"Teapot", NewError("", http.StatusTeapot), []string{"IsClientError"}},
+		{"InternalServerError", NewError("", http.StatusInternalServerError), []string{"IsServerError"}},
+		{"WrappedNotFound", fmt.Errorf("failed to get thing: %w", NewError("", http.StatusNotFound)), []string{"IsNotFound", "IsClientError"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := make(map[string]bool)
+			for _, name := range tt.want {
+				want[name] = true
+			}
+
+			for name, f := range predicates {
+				if got := f(tt.err); got != want[name] {
+					t.Errorf("%v: got %v, want %v", name, got, want[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// ProblemJSON is the Problem Details wire format (application/problem+json), as specified in
+// RFC 7807. It is only suitable for error responses, and is never selected by content
+// negotiation for other responses. When writing, the code and message of the Error are mapped to
+// the "status" and "detail" members of the problem, the "title" member is set to the text of the
+// status code, and the remaining fields of the Error become extension members. When reading, the
+// reverse mapping is applied, with the "title" member used as the message in the absence of a
+// "detail" member. Response envelopes are passed through unchanged in both directions.
+var ProblemJSON Format = problemFormat{}
+
+type problemFormat struct{}
+
+func (problemFormat) errorOnly() {}
+
+func (problemFormat) ContentType() string { return "application/problem+json" }
+
+func (problemFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+
+	je, ok := v.member("error")
+	if !ok || je.kind != '{' {
+		_, err := w.Write(b)
+		return err
+	}
+
+	p := jsonValue{kind: '{'}
+	p.set("type", jsonValue{kind: 's', s: "about:blank"})
+
+	code := http.StatusInternalServerError
+	if c, ok := je.member("code"); ok && c.kind == 'd' {
+		if n, err := strconv.Atoi(c.s); err == nil {
+			code = n
+		}
+	}
+	p.set("title", jsonValue{kind: 's', s: http.StatusText(code)})
+	p.set("status", jsonValue{kind: 'd', s: strconv.Itoa(code)})
+
+	for i, k := range je.keys {
+		switch k {
+		case "code":
+		case "message":
+			p.set("detail", je.elems[i])
+		default:
+			p.set(k, je.elems[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := p.appendJSON(&buf); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (problemFormat) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := parseJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	if v.kind != '{' {
+		return nil, errors.New("problem: document is not an object")
+	}
+	if _, ok := v.member("error"); ok {
+		return b, nil
+	}
+	if _, ok := v.member("data"); ok {
+		return b, nil
+	}
+
+	je := jsonValue{kind: '{'}
+	if s, ok := v.member("status"); ok {
+		je.set("code", s)
+	}
+	if d, ok := v.member("detail"); ok {
+		je.set("message", d)
+	} else if t, ok := v.member("title"); ok {
+		je.set("message", t)
+	}
+	for i, k := range v.keys {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+		default:
+			je.set(k, v.elems[i])
+		}
+	}
+
+	env := jsonValue{kind: '{'}
+	env.set("error", je)
+
+	var buf bytes.Buffer
+	if err := env.appendJSON(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProblemJSONFromJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Error", `{"error":{"code":404,"message":"blah"}}`, `{"type":"about:blank","title":"Not Found","status":404,"detail":"blah"}`},
+		{"ErrorExtensions", `{"error":{"code":429,"appCode":"QUOTA","message":"blah","retryAfter":5}}`, `{"type":"about:blank","title":"Too Many Requests","status":429,"appCode":"QUOTA","detail":"blah","retryAfter":5}`},
+		{"ErrorNoCode", `{"error":{"message":"blah"}}`, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"blah"}`},
+		{"Data", `{"data":"blah"}`, `{"data":"blah"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ProblemJSON.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+			if got, want := buf.String(), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestProblemJSONToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem string
+		want    string
+		wantErr bool
+	}{
+		{"Detail", `{"type":"about:blank","title":"Not Found","status":404,"detail":"blah","instance":"/x"}`, `{"error":{"code":404,"message":"blah"}}`, false},
+		{"Title", `{"title":"Not Found","status":404}`, `{"error":{"code":404,"message":"Not Found"}}`, false},
+		{"Extensions", `{"status":429,"detail":"blah","appCode":"QUOTA"}`, `{"error":{"code":429,"message":"blah","appCode":"QUOTA"}}`, false},
+		{"Envelope", `{"error":{"code":404}}`, `{"error":{"code":404}}`, false},
+		{"EnvelopeData", `{"data":1}`, `{"data":1}`, false},
+		{"NotObject", `[]`, ``, true},
+		{"Invalid", `{`, ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := ProblemJSON.ToJSON(strings.NewReader(tt.problem))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got, want := string(b), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadErrorProblemJSON(t *testing.T) {
+	r := strings.NewReader(`{"type":"about:blank","title":"Forbidden","status":403,"detail":"blah"}`)
+
+	want := &Error{Code: http.StatusForbidden, Message: "blah"}
+	if got := ReadError(r, WithFormat(ProblemJSON)); !errors.Is(got, want) {
+		t.Errorf("got error %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ProgressContentType is the media type of responses written by a ProgressWriter.
+const ProgressContentType = "application/x-ndjson"
+
+// errProgressDone is returned by a ProgressWriter once the final response has been written.
+var errProgressDone = errors.New("jsonresp: final response already written")
+
+// Progress describes the progress of a long-running operation.
+type Progress struct {
+	// Percent is the percentage of the operation that has been completed, if known.
+	Percent float64 `json:"percent,omitempty"`
+
+	// Message describes the current stage of the operation.
+	Message string `json:"message,omitempty"`
+
+	// Bytes is the number of bytes transferred so far, if applicable.
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// TotalBytes is the total number of bytes to be transferred, if known.
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+}
+
+// progressFrame is the wire representation of a progress update.
+type progressFrame struct {
+	Progress *Progress `json:"progress"`
+}
+
+// ProgressWriter writes a response consisting of a sequence of progress updates followed by a
+// final response, as newline-delimited JSON. Each progress update is written as an object with a
+// single "progress" member, and the final response is written as a response envelope. Each line
+// is flushed as it is written, if w supports it. Clients can read the response with ReadProgress.
+//
+// The status code of the response is written with the first line. If the final response is
+// written before any progress updates, its status code is used. Otherwise, the status code is
+// 200, and the outcome is conveyed by the final response alone.
+//
+// The methods of ProgressWriter may be called concurrently.
+type ProgressWriter struct {
+	w http.ResponseWriter
+	o *options
+
+	mu      sync.Mutex
+	started bool
+	done    bool
+	wrote   bool // whether a line has been written since the last keep-alive tick
+
+	stopOnce sync.Once
+	stop     chan struct{} // nil if keep-alives are disabled
+	stopped  chan struct{}
+}
+
+// NewProgressWriter returns a ProgressWriter that writes to w, configured according to opts.
+// Indentation and options established by WithFormat do not apply, as each line must be a single
+// JSON value.
+func NewProgressWriter(w http.ResponseWriter, opts ...Option) *ProgressWriter {
+	o := newOptions(opts)
+	o.prefix, o.indent, o.format = "", "", nil
+
+	pw := &ProgressWriter{w: w, o: o}
+	if o.keepAlive > 0 {
+		pw.stop = make(chan struct{})
+		pw.stopped = make(chan struct{})
+		go pw.keepAlive(o.keepAlive)
+	}
+	return pw
+}
+
+// WithKeepAlive causes a ProgressWriter to write an empty line whenever an interval of d elapses
+// without a line being written, so that intermediaries do not consider the connection idle while
+// the handler is computing. Empty lines are ignored by ReadProgress. Keep-alives stop when the
+// final response is written, or the ProgressWriter is closed. The first keep-alive writes the
+// status code of the response, so subsequent errors are conveyed by the final response alone.
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *options) {
+		o.keepAlive = d
+	}
+}
+
+// keepAlive writes keep-alives at the interval d, until pw.stop is closed.
+func (pw *ProgressWriter) keepAlive(d time.Duration) {
+	defer close(pw.stopped)
+
+	t := time.NewTicker(d)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-pw.stop:
+			return
+		case <-t.C:
+		}
+
+		pw.mu.Lock()
+		if !pw.done && !pw.wrote {
+			pw.start(http.StatusOK)
+			// A failure will be reported when the next line is written.
+			_ = pw.writeLine(nil)
+		}
+		pw.wrote = false
+		pw.mu.Unlock()
+	}
+}
+
+// stopKeepAlive stops keep-alives, and waits for any in progress to complete. The caller must not
+// hold pw.mu.
+func (pw *ProgressWriter) stopKeepAlive() {
+	if pw.stop == nil {
+		return
+	}
+	pw.stopOnce.Do(func() { close(pw.stop) })
+	<-pw.stopped
+}
+
+// Close stops keep-alives established by WithKeepAlive, without writing a final response. It
+// must be called before the handler returns if a final response may not have been written, and
+// is typically deferred.
+func (pw *ProgressWriter) Close() error {
+	pw.stopKeepAlive()
+	return nil
+}
+
+// start writes the headers of the response, with status code code, if they have not already been
+// written. The caller must hold pw.mu.
+func (pw *ProgressWriter) start(code int) {
+	if pw.started {
+		return
+	}
+	pw.started = true
+
+	h := pw.w.Header()
+	h.Set("Content-Type", ProgressContentType)
+	h.Del("Content-Length")
+	pw.o.copyHeader(h)
+	pw.w.WriteHeader(code)
+}
+
+// writeLine writes b, followed by a newline, and flushes the response. The caller must hold pw.mu.
+func (pw *ProgressWriter) writeLine(b []byte) error {
+	w := pw.o.withWriteDeadline(pw.w)
+
+	if _, err := w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	pw.wrote = true
+	return flushResponse(pw.w)
+}
+
+// Update writes the progress update p.
+func (pw *ProgressWriter) Update(p Progress) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.done {
+		return errProgressDone
+	}
+	pw.start(http.StatusOK)
+
+	b, err := json.Marshal(progressFrame{&p})
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to encode progress: %v", err)
+	}
+	if err := pw.writeLine(b); err != nil {
+		return fmt.Errorf("jsonresp: failed to write progress: %w", err)
+	}
+	return nil
+}
+
+// finish writes jr as the final response, with status code code if no progress updates have been
+// written.
+func (pw *ProgressWriter) finish(jr Response, code int) error {
+	pw.stopKeepAlive()
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	if pw.done {
+		return errProgressDone
+	}
+	pw.done = true
+	pw.start(code)
+
+	es := newEncodeState()
+	defer es.release()
+
+	jr, err := pw.o.prepareResponse(jr)
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %v", err)
+	}
+	if err := es.encode(jr, pw.o); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %v", err)
+	}
+
+	sizeErr := pw.o.checkResponseSize(es.Len())
+	if sizeErr != nil {
+		// The status code has already been written, so the error is conveyed by the final
+		// response alone.
+		es.Reset()
+		je := pw.o.prepareError(NewError("response too large", http.StatusInternalServerError), sizeErr, 0)
+		if err := es.encode(Response{Error: je}, pw.o); err != nil {
+			return fmt.Errorf("jsonresp: failed to encode response: %v", err)
+		}
+	}
+
+	if err := pw.writeLine(es.Bytes()); err != nil {
+		return fmt.Errorf("jsonresp: failed to write response: %w", err)
+	}
+	if sizeErr != nil {
+		return fmt.Errorf("jsonresp: failed to encode response: %w", sizeErr)
+	}
+	return nil
+}
+
+// WriteResponse writes a final response containing data.
+func (pw *ProgressWriter) WriteResponse(data interface{}) error {
+	return pw.finish(Response{Data: data}, http.StatusOK)
+}
+
+// WriteError writes a final response containing the supplied error message and status code, in
+// the same way as WriteError.
+func (pw *ProgressWriter) WriteError(message string, code int) error {
+	je := pw.o.prepareError(NewError(message, code), nil, 0)
+	return pw.finish(Response{Error: je}, je.Code)
+}
+
+// WriteErr writes a final response describing err, in the same way as WriteErr.
+func (pw *ProgressWriter) WriteErr(err error) error {
+	je := NewError("", http.StatusInternalServerError)
+	if err != nil {
+		je = errorFor(err)
+	}
+	je = pw.o.prepareError(je, err, 0)
+	return pw.finish(Response{Error: je}, je.Code)
+}
+
+// ReadProgress reads a response written by a ProgressWriter from r. The function f, if not nil,
+// is called with each progress update in turn. The final response is read in the same way as
+// ReadResponse: its data is unmarshalled into v, or the error it contains is returned. If r ends
+// before the final response, an error wrapping io.ErrUnexpectedEOF is returned.
+func ReadProgress(r io.Reader, v interface{}, f func(Progress), opts ...Option) error {
+	dec := json.NewDecoder(r)
+	for {
+		var b json.RawMessage
+		if err := dec.Decode(&b); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("jsonresp: failed to read progress: %w", err)
+		}
+
+		var pf progressFrame
+		if err := json.Unmarshal(b, &pf); err == nil && pf.Progress != nil {
+			if f != nil {
+				f(*pf.Progress)
+			}
+			continue
+		}
+
+		return ReadResponse(bytes.NewReader(b), v, opts...)
+	}
+}
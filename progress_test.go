@@ -0,0 +1,206 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressWriter(t *testing.T) {
+	updates := []Progress{
+		{Percent: 10, Message: "pulling base image"},
+		{Percent: 50, Message: "building", Bytes: 1024, TotalBytes: 4096},
+		{Percent: 100},
+	}
+
+	tests := []struct {
+		name     string
+		updates  []Progress
+		finish   func(pw *ProgressWriter) error
+		wantCode int
+		wantData string
+		wantErr  error
+	}{
+		{"Response", updates, func(pw *ProgressWriter) error {
+			return pw.WriteResponse("sha256:abc")
+		}, http.StatusOK, "sha256:abc", nil},
+		{"ResponseNoUpdates", nil, func(pw *ProgressWriter) error {
+			return pw.WriteResponse("sha256:abc")
+		}, http.StatusOK, "sha256:abc", nil},
+		{"Error", updates, func(pw *ProgressWriter) error {
+			return pw.WriteError("build failed", http.StatusUnprocessableEntity)
+		}, http.StatusOK, "", NewError("build failed", http.StatusUnprocessableEntity)},
+		{"ErrorNoUpdates", nil, func(pw *ProgressWriter) error {
+			return pw.WriteError("build failed", http.StatusUnprocessableEntity)
+		}, http.StatusUnprocessableEntity, "", NewError("build failed", http.StatusUnprocessableEntity)},
+		{"Err", updates, func(pw *ProgressWriter) error {
+			return pw.WriteErr(errors.New("disk full"))
+		}, http.StatusOK, "", NewError("disk full", http.StatusInternalServerError)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			pw := NewProgressWriter(rr, WithIndent("", "\t"))
+			for _, p := range tt.updates {
+				if err := pw.Update(p); err != nil {
+					t.Fatalf("failed to write progress: %v", err)
+				}
+			}
+			if err := tt.finish(pw); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Type"), ProgressContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := strings.Count(rr.Body.String(), "\n"), len(tt.updates)+1; got != want {
+				t.Errorf("got %v lines, want %v", got, want)
+			}
+			if !rr.Flushed {
+				t.Errorf("response not flushed")
+			}
+
+			var got []Progress
+			var data string
+			err := ReadProgress(rr.Body, &data, func(p Progress) { got = append(got, p) })
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.updates) {
+				t.Errorf("got updates %+v, want %+v", got, tt.updates)
+			}
+			if data != tt.wantData {
+				t.Errorf("got data %q, want %q", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestProgressWriterDone(t *testing.T) {
+	pw := NewProgressWriter(httptest.NewRecorder())
+	if err := pw.WriteResponse(nil); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	if err := pw.Update(Progress{}); !errors.Is(err, errProgressDone) {
+		t.Errorf("got error %v, want %v", err, errProgressDone)
+	}
+	if err := pw.WriteResponse(nil); !errors.Is(err, errProgressDone) {
+		t.Errorf("got error %v, want %v", err, errProgressDone)
+	}
+}
+
+func TestReadProgressUnexpectedEOF(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"Empty", ""},
+		{"NoResponse", `{"progress":{"percent":10}}` + "\n"},
+		{"Truncated", `{"progress":{"percent":10}}` + "\n" + `{"data":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ReadProgress(strings.NewReader(tt.body), nil, nil); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+			}
+		})
+	}
+}
+
+func TestProgressWriterKeepAlive(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	pw := NewProgressWriter(rr, WithKeepAlive(time.Millisecond))
+	defer pw.Close()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := pw.Update(Progress{Percent: 50}); err != nil {
+		t.Fatalf("failed to write progress: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := pw.WriteError("build failed", http.StatusUnprocessableEntity); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+
+	// The status code is written by the first keep-alive.
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+
+	body := rr.Body.String()
+	if !strings.HasPrefix(body, "\n") {
+		t.Errorf("got body %q, want keep-alive before progress", body)
+	}
+	if !strings.Contains(body, "\n\n") || !strings.HasSuffix(body, "}\n") {
+		t.Errorf("got body %q, want keep-alives before final response only", body)
+	}
+
+	var got []Progress
+	err := ReadProgress(strings.NewReader(body), nil, func(p Progress) { got = append(got, p) })
+	if want := NewError("build failed", http.StatusUnprocessableEntity); !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+	if want := []Progress{{Percent: 50}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got updates %+v, want %+v", got, want)
+	}
+
+	// No keep-alives are written once the final response has been written.
+	time.Sleep(10 * time.Millisecond)
+	if got := rr.Body.String(); got != body {
+		t.Errorf("got body %q after final response, want %q", got, body)
+	}
+}
+
+func TestProgressWriterClose(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	pw := NewProgressWriter(rr, WithKeepAlive(time.Millisecond))
+	if err := pw.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	n := rr.Body.Len()
+
+	time.Sleep(10 * time.Millisecond)
+	if got := rr.Body.Len(); got != n {
+		t.Errorf("got %v bytes after close, want %v", got, n)
+	}
+
+	// Close is idempotent, and the final response may still be written.
+	if err := pw.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+	if err := pw.WriteResponse("blah"); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+}
+
+func TestProgressWriterMaxResponseSize(t *testing.T) {
+	rr := httptest.NewRecorder()
+	pw := NewProgressWriter(rr, WithMaxResponseSize(16))
+	if err := pw.Update(Progress{Percent: 50}); err != nil {
+		t.Fatalf("failed to write progress: %v", err)
+	}
+	if err := pw.WriteResponse(strings.Repeat("a", 16)); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("got error %v, want %v", err, ErrResponseTooLarge)
+	}
+
+	err := ReadProgress(rr.Body, nil, nil)
+	if want := NewError("response too large", http.StatusInternalServerError); !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+// Package promresp provides a Prometheus implementation of jsonresp.Metrics.
+package promresp
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+// Metrics is a jsonresp.Metrics that records observations as Prometheus metrics. It is a
+// prometheus.Collector, so it must be registered before its metrics are exported:
+//
+//	m := promresp.New()
+//	prometheus.MustRegister(m)
+//	jsonresp.SetMetrics(m)
+type Metrics struct {
+	responses    *prometheus.CounterVec
+	responseSize *prometheus.HistogramVec
+	decodeErrors prometheus.Counter
+}
+
+var _ jsonresp.Metrics = (*Metrics)(nil)
+
+// New returns a Metrics recording the following metrics:
+//
+//   - jsonresp_responses_total, the number of responses written, by status class (ie. "2xx").
+//   - jsonresp_response_size_bytes, the size of response bodies written, by status class.
+//   - jsonresp_decode_errors_total, the number of bodies that could not be decoded.
+func New() *Metrics {
+	return &Metrics{
+		responses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "jsonresp",
+			Name:      "responses_total",
+			Help:      "Number of responses written, by status class.",
+		}, []string{"class"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "jsonresp",
+			Name:      "response_size_bytes",
+			Help:      "Size of response bodies written, by status class.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"class"}),
+		decodeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "jsonresp",
+			Name:      "decode_errors_total",
+			Help:      "Number of bodies that could not be decoded.",
+		}),
+	}
+}
+
+// statusClass returns the class of status code code, such as "2xx".
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// ObserveResponse records a response with status code code and a body of size bytes.
+func (m *Metrics) ObserveResponse(code, size int) {
+	class := statusClass(code)
+	m.responses.WithLabelValues(class).Inc()
+	m.responseSize.WithLabelValues(class).Observe(float64(size))
+}
+
+// ObserveDecodeError records a body that could not be decoded.
+func (m *Metrics) ObserveDecodeError(error) {
+	m.decodeErrors.Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.responses.Describe(ch)
+	m.responseSize.Describe(ch)
+	m.decodeErrors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.responses.Collect(ch)
+	m.responseSize.Collect(ch)
+	m.decodeErrors.Collect(ch)
+}
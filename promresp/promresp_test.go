@@ -0,0 +1,77 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package promresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	jsonresp "github.com/sylabs/json-resp"
+)
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{http.StatusOK, "2xx"},
+		{http.StatusNotModified, "3xx"},
+		{http.StatusNotFound, "4xx"},
+		{http.StatusServiceUnavailable, "5xx"},
+		{0, "unknown"},
+		{600, "unknown"},
+	}
+	for _, tt := range tests {
+		if got := statusClass(tt.code); got != tt.want {
+			t.Errorf("statusClass(%v) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	m := New()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(m); err != nil {
+		t.Fatalf("failed to register metrics: %v", err)
+	}
+
+	jsonresp.SetMetrics(m)
+	defer jsonresp.SetMetrics(nil)
+
+	if err := jsonresp.WriteResponse(httptest.NewRecorder(), "blah", http.StatusOK); err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if err := jsonresp.WriteError(httptest.NewRecorder(), "blah", http.StatusNotFound); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if err := jsonresp.WriteError(httptest.NewRecorder(), "blah", http.StatusNotFound); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if err := jsonresp.ReadResponse(strings.NewReader("{"), nil); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	want := `
+# HELP jsonresp_decode_errors_total Number of bodies that could not be decoded.
+# TYPE jsonresp_decode_errors_total counter
+jsonresp_decode_errors_total 1
+# HELP jsonresp_responses_total Number of responses written, by status class.
+# TYPE jsonresp_responses_total counter
+jsonresp_responses_total{class="2xx"} 1
+jsonresp_responses_total{class="4xx"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "jsonresp_responses_total", "jsonresp_decode_errors_total"); err != nil {
+		t.Error(err)
+	}
+
+	if got, want := testutil.CollectAndCount(m, "jsonresp_response_size_bytes"), 2; got != want {
+		t.Errorf("got %v size series, want %v", got, want)
+	}
+}
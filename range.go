@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RangeUnit is the range unit by which a collection is requested with the Range header.
+const RangeUnit = "items"
+
+// invalidRange returns an Error describing an unsatisfiable Range header.
+func invalidRange(reason string) *Error {
+	return &Error{
+		Code:    http.StatusRequestedRangeNotSatisfiable,
+		Message: fmt.Sprintf("invalid range: %v", reason),
+	}
+}
+
+// BindPageRange parses a Range header of r in the items unit, such as "items=0-49", which requests
+// the first 50 items of a collection. The last position may be omitted, such as "items=50-", in
+// which case the page size defaults to DefaultPageLimit. Ranges exceeding DefaultMaxPageLimit
+// items are truncated, and the response indicates the items actually returned. The page limits
+// may be overridden by WithPageLimits. The sort query parameter is parsed as by BindPageQuery.
+//
+// If r has no Range header, or it specifies a unit other than items, false is returned, and the
+// page should be bound from the query parameters of r instead. If the Range header is invalid,
+// the returned error is an Error with a 416 status code.
+func BindPageRange(r *http.Request, opts ...Option) (PageRequest, bool, error) {
+	o := newOptions(append([]Option{WithPageLimits(DefaultPageLimit, DefaultMaxPageLimit)}, opts...))
+
+	v := r.Header.Get("Range")
+	unit, spec, ok := strings.Cut(v, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), RangeUnit) {
+		return PageRequest{}, false, nil
+	}
+
+	if strings.Contains(spec, ",") {
+		return PageRequest{}, true, invalidRange("multiple ranges are not supported")
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return PageRequest{}, true, invalidRange("must be of the form items=first-last")
+	}
+
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 {
+		return PageRequest{}, true, invalidRange("first position must be a non-negative integer")
+	}
+
+	pr := PageRequest{
+		Offset: start,
+		Limit:  o.defaultPageLimit,
+	}
+	if last != "" {
+		end, err := strconv.Atoi(last)
+		if err != nil || end < start {
+			return PageRequest{}, true, invalidRange("last position must be an integer no less than the first")
+		}
+		// The difference is compared before adding one, so that it cannot overflow.
+		if end-start >= o.maxPageLimit {
+			pr.Limit = o.maxPageLimit
+		} else {
+			pr.Limit = end - start + 1
+		}
+	}
+	if pr.Limit > o.maxPageLimit {
+		pr.Limit = o.maxPageLimit
+	}
+	if pr.Limit <= 0 {
+		return PageRequest{}, true, invalidRange("range must contain at least one item")
+	}
+
+	if v := r.URL.Query().Get("sort"); v != "" {
+		sort, err := parseSort(v, o.sortFields)
+		if err != nil {
+			return PageRequest{}, true, err
+		}
+		pr.Sort = sort
+	}
+
+	return pr, true, nil
+}
+
+// contentRange returns the value of the Content-Range header describing n items at offset of a
+// collection of total items, which may be UnknownTotal.
+func contentRange(offset, n int, total int64) string {
+	size := "*"
+	if total >= 0 {
+		size = strconv.FormatInt(total, 10)
+	}
+	if n == 0 {
+		return fmt.Sprintf("%v */%v", RangeUnit, size)
+	}
+	return fmt.Sprintf("%v %v-%v/%v", RangeUnit, offset, offset+n-1, size)
+}
+
+// WriteResponseRange writes a response containing data, the n items found at the offset requested
+// by pr, as the response to a request bound by BindPageRange. The response has a 206 status code,
+// and its Content-Range header describes the items returned. The total number of items is
+// reported if it is not UnknownTotal, in both the Content-Range header and the page details of the
+// response.
+//
+// If no items are found, a 200 status code is written if pr requests the start of the collection,
+// since the collection is empty. Otherwise, an error with a 416 status code is written, and its
+// Content-Range header reports the total number of items, if known.
+func WriteResponseRange(w http.ResponseWriter, data interface{}, pr PageRequest, n int, total int64, opts ...Option) error {
+	h := w.Header()
+	h.Set("Accept-Ranges", RangeUnit)
+	h.Set("Content-Range", contentRange(pr.Offset, n, total))
+
+	if n == 0 && pr.Offset > 0 {
+		return WriteErr(w, invalidRange("first position exceeds the size of the collection"), opts...)
+	}
+
+	var pd *PageDetails
+	if total >= 0 {
+		pd = &PageDetails{TotalSize: total, ZeroTotal: total == 0}
+	}
+
+	code := http.StatusPartialContent
+	if n == 0 {
+		code = http.StatusOK
+	}
+	return WriteResponsePage(w, data, pd, code, opts...)
+}
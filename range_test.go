@@ -0,0 +1,108 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBindPageRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		rng      string
+		opts     []Option
+		wantPR   PageRequest
+		wantOK   bool
+		wantCode int
+	}{
+		{"None", "/", "", nil, PageRequest{}, false, 0},
+		{"OtherUnit", "/", "bytes=0-99", nil, PageRequest{}, false, 0},
+		{"FirstLast", "/", "items=0-49", nil, PageRequest{Limit: 50}, true, 0},
+		{"Offset", "/", "items=50-59", nil, PageRequest{Offset: 50, Limit: 10}, true, 0},
+		{"CaseInsensitiveUnit", "/", "Items=0-9", nil, PageRequest{Limit: 10}, true, 0},
+		{"OpenEnded", "/", "items=10-", nil, PageRequest{Offset: 10, Limit: DefaultPageLimit}, true, 0},
+		{"Truncated", "/", "items=0-999", nil, PageRequest{Limit: DefaultMaxPageLimit}, true, 0},
+		{"PageLimits", "/", "items=0-", []Option{WithPageLimits(5, 8)}, PageRequest{Limit: 5}, true, 0},
+		{"Overflow", "/", "items=0-9223372036854775807", nil, PageRequest{Limit: DefaultMaxPageLimit}, true, 0},
+		{"OverflowOffset", "/", "items=1-9223372036854775807", nil, PageRequest{Offset: 1, Limit: DefaultMaxPageLimit}, true, 0},
+		{"ZeroLimit", "/", "items=0-", []Option{WithPageLimits(0, 8)}, PageRequest{}, true, http.StatusRequestedRangeNotSatisfiable},
+		{"Sort", "/?sort=-name", "items=0-9", nil, PageRequest{Limit: 10, Sort: []SortField{{Field: "name", Descending: true}}}, true, 0},
+		{"SortInvalid", "/?sort=name", "items=0-9", []Option{WithSortFields("id")}, PageRequest{}, true, http.StatusBadRequest},
+		{"Suffix", "/", "items=-10", nil, PageRequest{}, true, http.StatusRequestedRangeNotSatisfiable},
+		{"Multiple", "/", "items=0-9,20-29", nil, PageRequest{}, true, http.StatusRequestedRangeNotSatisfiable},
+		{"NoDash", "/", "items=10", nil, PageRequest{}, true, http.StatusRequestedRangeNotSatisfiable},
+		{"Reversed", "/", "items=10-9", nil, PageRequest{}, true, http.StatusRequestedRangeNotSatisfiable},
+		{"NotInteger", "/", "items=a-b", nil, PageRequest{}, true, http.StatusRequestedRangeNotSatisfiable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.rng != "" {
+				r.Header.Set("Range", tt.rng)
+			}
+
+			pr, ok, err := BindPageRange(r, tt.opts...)
+			if tt.wantCode != 0 {
+				var je *Error
+				if !errors.As(err, &je) || je.Code != tt.wantCode {
+					t.Fatalf("got error %v, want code %v", err, tt.wantCode)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(pr, tt.wantPR) {
+				t.Errorf("got %+v, want %+v", pr, tt.wantPR)
+			}
+		})
+	}
+}
+
+func TestWriteResponseRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []int
+		pr        PageRequest
+		total     int64
+		wantCode  int
+		wantRange string
+		wantBody  string
+	}{
+		{"First", []int{0, 1}, PageRequest{Limit: 2}, 5, http.StatusPartialContent, "items 0-1/5", `{"data":[0,1],"page":{"totalSize":5}}`},
+		{"Last", []int{4}, PageRequest{Offset: 4, Limit: 2}, 5, http.StatusPartialContent, "items 4-4/5", `{"data":[4],"page":{"totalSize":5}}`},
+		{"UnknownTotal", []int{2, 3}, PageRequest{Offset: 2, Limit: 2}, UnknownTotal, http.StatusPartialContent, "items 2-3/*", `{"data":[2,3]}`},
+		{"Empty", []int{}, PageRequest{Limit: 2}, 0, http.StatusOK, "items */0", `{"data":[],"page":{"totalSize":0}}`},
+		{"Unsatisfiable", []int{}, PageRequest{Offset: 10, Limit: 2}, 5, http.StatusRequestedRangeNotSatisfiable, "items */5", `{"error":{"code":416,"message":"invalid range: first position exceeds the size of the collection"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponseRange(rr, tt.data, tt.pr, len(tt.data), tt.total); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Range"), tt.wantRange; got != want {
+				t.Errorf("got Content-Range %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Accept-Ranges"), RangeUnit; got != want {
+				t.Errorf("got Accept-Ranges %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MetaRateLimit is the metadata key under which WithRateLimitMeta records the rate limit.
+const MetaRateLimit = "rateLimit"
+
+// RateLimit describes the rate limit applied to the client of a request.
+type RateLimit struct {
+	// Limit is the number of requests permitted within the current window.
+	Limit int `json:"limit"`
+
+	// Remaining is the number of requests remaining within the current window.
+	Remaining int `json:"remaining"`
+
+	// Reset is the number of seconds until the current window ends.
+	Reset int `json:"reset"`
+}
+
+// rateLimitHeaders are the names of the headers used to convey a rate limit, in order of
+// preference.
+var rateLimitHeaders = []struct {
+	limit, remaining, reset string
+}{
+	{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+	{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+}
+
+// WithRateLimit causes rl to be written in the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the response, and in the equivalent X-RateLimit headers for the
+// benefit of older clients. In both cases, the reset is expressed in seconds.
+func WithRateLimit(rl RateLimit) Option {
+	return func(o *options) {
+		for _, h := range rateLimitHeaders {
+			WithHeader(h.limit, strconv.Itoa(rl.Limit))(o)
+			WithHeader(h.remaining, strconv.Itoa(rl.Remaining))(o)
+			WithHeader(h.reset, strconv.Itoa(rl.Reset))(o)
+		}
+	}
+}
+
+// WithRateLimitMeta causes rl to be written in the headers of the response, as by WithRateLimit,
+// and in the metadata of the response envelope under the MetaRateLimit key.
+func WithRateLimitMeta(rl RateLimit) Option {
+	return func(o *options) {
+		WithRateLimit(rl)(o)
+		WithMeta(MetaRateLimit, rl)(o)
+	}
+}
+
+// ParseRateLimit parses the rate limit conveyed by the headers of a response. The RateLimit
+// headers are preferred over the X-RateLimit headers. If neither set of headers is present and
+// valid, false is returned.
+func ParseRateLimit(h http.Header) (RateLimit, bool) {
+	for _, names := range rateLimitHeaders {
+		limit, ok := parseRateLimitValue(h.Get(names.limit))
+		if !ok {
+			continue
+		}
+		remaining, ok := parseRateLimitValue(h.Get(names.remaining))
+		if !ok {
+			continue
+		}
+		reset, ok := parseRateLimitValue(h.Get(names.reset))
+		if !ok {
+			continue
+		}
+		return RateLimit{Limit: limit, Remaining: remaining, Reset: reset}, true
+	}
+	return RateLimit{}, false
+}
+
+// parseRateLimitValue parses the non-negative integer value of a rate limit header.
+func parseRateLimitValue(v string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// ResponseRateLimit returns the rate limit recorded in the metadata of jr by WithRateLimitMeta. If
+// jr does not contain a rate limit, false is returned.
+func ResponseRateLimit(jr Response) (RateLimit, bool) {
+	v, ok := jr.Meta[MetaRateLimit]
+	if !ok {
+		return RateLimit{}, false
+	}
+	if rl, ok := v.(RateLimit); ok {
+		return rl, true
+	}
+
+	// The metadata of a response that has been read contains decoded JSON values.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return RateLimit{}, false
+	}
+	var rl RateLimit
+	if err := json.Unmarshal(b, &rl); err != nil {
+		return RateLimit{}, false
+	}
+	return rl, true
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	rl := RateLimit{Limit: 100, Remaining: 42, Reset: 30}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantMeta bool
+	}{
+		{"Headers", []Option{WithRateLimit(rl)}, false},
+		{"Meta", []Option{WithRateLimitMeta(rl)}, true},
+		{"MetaStream", []Option{WithRateLimitMeta(rl), WithStream()}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, "blah", http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+
+			want := map[string]string{
+				"RateLimit-Limit":       "100",
+				"RateLimit-Remaining":   "42",
+				"RateLimit-Reset":       "30",
+				"X-RateLimit-Limit":     "100",
+				"X-RateLimit-Remaining": "42",
+				"X-RateLimit-Reset":     "30",
+			}
+			for k, v := range want {
+				if got := rr.Header().Get(k); got != v {
+					t.Errorf("got %v header %q, want %q", k, got, v)
+				}
+			}
+
+			if got, ok := ParseRateLimit(rr.Header()); !ok {
+				t.Errorf("failed to parse rate limit")
+			} else if got != rl {
+				t.Errorf("got rate limit %+v, want %+v", got, rl)
+			}
+
+			var jr Response
+			if err := ReadResponse(rr.Body, nil, WithEnvelope(&jr)); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			got, ok := ResponseRateLimit(jr)
+			if ok != tt.wantMeta {
+				t.Fatalf("got rate limit in meta %v, want %v", ok, tt.wantMeta)
+			}
+			if ok && got != rl {
+				t.Errorf("got rate limit %+v, want %+v", got, rl)
+			}
+		})
+	}
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   RateLimit
+		wantOK bool
+	}{
+		{"None", http.Header{}, RateLimit{}, false},
+		{"Standard", http.Header{
+			"Ratelimit-Limit":     {"10"},
+			"Ratelimit-Remaining": {"5"},
+			"Ratelimit-Reset":     {"60"},
+		}, RateLimit{10, 5, 60}, true},
+		{"Legacy", http.Header{
+			"X-Ratelimit-Limit":     {"10"},
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {" 60 "},
+		}, RateLimit{10, 0, 60}, true},
+		{"PreferStandard", http.Header{
+			"Ratelimit-Limit":       {"10"},
+			"Ratelimit-Remaining":   {"5"},
+			"Ratelimit-Reset":       {"60"},
+			"X-Ratelimit-Limit":     {"20"},
+			"X-Ratelimit-Remaining": {"15"},
+			"X-Ratelimit-Reset":     {"30"},
+		}, RateLimit{10, 5, 60}, true},
+		{"InvalidStandard", http.Header{
+			"Ratelimit-Limit":       {"ten"},
+			"Ratelimit-Remaining":   {"5"},
+			"Ratelimit-Reset":       {"60"},
+			"X-Ratelimit-Limit":     {"20"},
+			"X-Ratelimit-Remaining": {"15"},
+			"X-Ratelimit-Reset":     {"30"},
+		}, RateLimit{20, 15, 30}, true},
+		{"Incomplete", http.Header{
+			"Ratelimit-Limit":     {"10"},
+			"Ratelimit-Remaining": {"5"},
+		}, RateLimit{}, false},
+		{"Negative", http.Header{
+			"Ratelimit-Limit":     {"10"},
+			"Ratelimit-Remaining": {"-1"},
+			"Ratelimit-Reset":     {"60"},
+		}, RateLimit{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRateLimit(tt.header)
+			if ok != tt.wantOK {
+				t.Errorf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("got rate limit %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		jr     Response
+		want   RateLimit
+		wantOK bool
+	}{
+		{"None", Response{}, RateLimit{}, false},
+		{"Value", Response{Meta: map[string]interface{}{MetaRateLimit: RateLimit{1, 2, 3}}}, RateLimit{1, 2, 3}, true},
+		{"Decoded", Response{Meta: map[string]interface{}{MetaRateLimit: map[string]interface{}{"limit": 1.0, "remaining": 2.0, "reset": 3.0}}}, RateLimit{1, 2, 3}, true},
+		{"Invalid", Response{Meta: map[string]interface{}{MetaRateLimit: "blah"}}, RateLimit{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ResponseRateLimit(tt.jr)
+			if ok != tt.wantOK {
+				t.Errorf("got ok %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("got rate limit %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithRedaction causes struct fields of the data of a successful response to be removed according
+// to their jsonresp struct tag, so that a single type can serve views with differing access. A
+// field tagged `jsonresp:"redact"` is always removed. A field tagged `jsonresp:"role=admin"` is
+// removed unless admin is among the supplied roles. A field may be tagged with more than one role,
+// such as `jsonresp:"role=admin,role=auditor"`, in which case it is retained if any of them is
+// supplied.
+//
+// Redaction applies to the fields of nested structs, and of structs within slices, arrays, maps
+// and interfaces. The encoding of values that implement json.Marshaler is not modified. To replace
+// the values of fields rather than remove them, regardless of the client, see RegisterSecretType.
+func WithRedaction(roles ...string) Option {
+	return func(o *options) {
+		o.redact = true
+		o.roles = roles
+	}
+}
+
+// redactField describes the encoding of a struct field subject to redaction.
+type redactField struct {
+	index  []int    // index sequence of the field, for reflect.Value.FieldByIndex
+	redact bool     // whether the field is always redacted
+	secret bool     // whether the value of the field is replaced with Redacted
+	roles  []string // roles permitted to view the field, or nil if unrestricted
+}
+
+// visible reports whether the field is retained for a client with the supplied roles.
+func (f redactField) visible(roles []string) bool {
+	if f.redact {
+		return false
+	}
+	if f.roles == nil {
+		return true
+	}
+	for _, r := range f.roles {
+		if contains(roles, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactFieldsCache caches the values returned by redactFields.
+var redactFieldsCache sync.Map // map[reflect.Type]map[string]redactField
+
+// redactFields returns the fields of the struct type t, keyed by JSON object key. Fields of
+// embedded structs are included unless their key is already in use by a shallower field.
+func redactFields(t reflect.Type) map[string]redactField {
+	if fs, ok := redactFieldsCache.Load(t); ok {
+		return fs.(map[string]redactField)
+	}
+
+	fs := make(map[string]redactField)
+	addRedactFields(fs, t, nil)
+
+	v, _ := redactFieldsCache.LoadOrStore(t, fs)
+	return v.(map[string]redactField)
+}
+
+// addRedactFields adds the fields of the struct type t, whose index sequence begins with index, to
+// fs.
+func addRedactFields(fs map[string]redactField, t reflect.Type, index []int) {
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, f)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if !hasTag || name == "" {
+			name = f.Name
+		}
+		if _, ok := fs[name]; ok {
+			continue
+		}
+
+		rf := redactField{index: append(append([]int(nil), index...), i)}
+		for _, opt := range strings.Split(f.Tag.Get("jsonresp"), ",") {
+			switch opt = strings.TrimSpace(opt); {
+			case opt == "redact":
+				rf.redact = true
+			case opt == "secret":
+				rf.secret = true
+			case strings.HasPrefix(opt, "role="):
+				rf.roles = append(rf.roles, strings.TrimPrefix(opt, "role="))
+			}
+		}
+		fs[name] = rf
+	}
+
+	// Fields of embedded structs are added once those of t, which take precedence, are known.
+	for _, f := range embedded {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		addRedactFields(fs, ft, append(append([]int(nil), index...), f.Index...))
+	}
+}
+
+// fieldByIndex returns the field of the struct v with the supplied index sequence, or the zero
+// Value if it is reached through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// rewriteData returns b, the JSON encoding of v, with the struct fields that are not visible to
+// the roles established by WithRedaction removed, its secrets replaced with Redacted, and the
+// times, durations, slices and maps it contains formatted as established by WithTimeFormat,
+// WithDurationFormat and WithNullFormat.
+func (o *options) rewriteData(b []byte, v reflect.Value) ([]byte, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return b, nil
+		}
+		if isSecretType(v.Type()) {
+			return redactedJSON, nil
+		}
+		if v.Kind() == reflect.Ptr && v.Type().Implements(marshalerType) && !v.Type().Elem().Implements(marshalerType) {
+			// The value is encoded by a method with a pointer receiver.
+			return b, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return b, nil
+	}
+	if isSecretType(v.Type()) {
+		return redactedJSON, nil
+	}
+	if tb, ok := o.formatTimeValue(v); ok {
+		return tb, nil
+	}
+	if v.Type().Implements(marshalerType) || reflect.PtrTo(v.Type()).Implements(marshalerType) && v.CanAddr() {
+		return b, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		fs := redactFields(v.Type())
+		return rewriteMembers(b, func(k string, m json.RawMessage) (json.RawMessage, bool, error) {
+			f, ok := fs[k]
+			if !ok {
+				return m, true, nil
+			}
+			if o.redact && !f.visible(o.roles) {
+				return nil, false, nil
+			}
+			if f.secret && string(m) != "null" {
+				return redactedJSON, true, nil
+			}
+			m, err := o.rewriteData(m, fieldByIndex(v, f.index))
+			return m, true, err
+		})
+
+	case reflect.Map:
+		if nb, ok := o.formatNullValue(v); ok {
+			return nb, nil
+		}
+		if v.Type().Key().Kind() == reflect.String {
+			return rewriteMembers(b, func(k string, m json.RawMessage) (json.RawMessage, bool, error) {
+				m, err := o.rewriteData(m, v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key())))
+				return m, true, err
+			})
+		}
+		keys, err := mapKeys(v)
+		if err != nil {
+			return nil, err
+		}
+		return rewriteMembers(b, func(k string, m json.RawMessage) (json.RawMessage, bool, error) {
+			key, ok := keys[k]
+			if !ok {
+				// Failing to rewrite the value would leave any secrets it contains in place.
+				return nil, false, fmt.Errorf("failed to resolve map key %q", k)
+			}
+			m, err := o.rewriteData(m, v.MapIndex(key))
+			return m, true, err
+		})
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return b, nil
+		}
+		if v.Kind() == reflect.Slice {
+			if nb, ok := o.formatNullValue(v); ok {
+				return nb, nil
+			}
+		}
+		return rewriteElements(b, func(i int, e json.RawMessage) (json.RawMessage, error) {
+			if i >= v.Len() {
+				return e, nil
+			}
+			return o.rewriteData(e, v.Index(i))
+		})
+	}
+	return b, nil
+}
+
+// mapKeys returns the keys of the map v, whose keys are not strings, indexed by their JSON object
+// keys. Keys are resolved in the same way as by encoding/json, which encodes keys that implement
+// encoding.TextMarshaler as their text, and integer keys in decimal. An error is returned if a
+// key cannot be resolved, or two keys share a JSON object key, so that the values of the map are
+// not written without being rewritten.
+func mapKeys(v reflect.Value) (map[string]reflect.Value, error) {
+	keys := make(map[string]reflect.Value, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		k := it.Key()
+
+		var name string
+		switch {
+		case k.Type().Implements(textMarshalerType):
+			if k.Kind() == reflect.Ptr && k.IsNil() {
+				break
+			}
+			b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve map key: %w", err)
+			}
+			name = string(b)
+		case k.Kind() >= reflect.Int && k.Kind() <= reflect.Int64:
+			name = strconv.FormatInt(k.Int(), 10)
+		case k.Kind() >= reflect.Uint && k.Kind() <= reflect.Uintptr:
+			name = strconv.FormatUint(k.Uint(), 10)
+		default:
+			return nil, fmt.Errorf("unsupported map key type %v", k.Type())
+		}
+		if _, ok := keys[name]; ok {
+			return nil, fmt.Errorf("duplicate map key %q", name)
+		}
+		keys[name] = k
+	}
+	return keys, nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type redactAudit struct {
+	CreatedBy string `json:"createdBy" jsonresp:"role=admin,role=auditor"`
+	Internal  string `json:"internal" jsonresp:"redact"`
+}
+
+type redactUser struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email,omitempty" jsonresp:"role=admin"`
+	Password string `json:"password" jsonresp:"redact"`
+	Untagged string
+	redactAudit
+	Manager *redactUser `json:"manager,omitempty"`
+}
+
+// redactText is encoded using a custom method, so its fields are not redacted.
+type redactText struct {
+	Secret string `jsonresp:"redact"`
+}
+
+func (t redactText) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"Secret": t.Secret})
+}
+
+// redactKey is a map key encoded as text.
+type redactKey struct {
+	name string
+}
+
+func (k redactKey) MarshalText() ([]byte, error) {
+	return []byte("key-" + k.name), nil
+}
+
+func TestWithRedaction(t *testing.T) {
+	u := redactUser{
+		ID:          1,
+		Name:        "a",
+		Email:       "a@example.com",
+		Password:    "p",
+		Untagged:    "u",
+		redactAudit: redactAudit{CreatedBy: "c", Internal: "i"},
+		Manager:     &redactUser{ID: 2, Email: "m@example.com"},
+	}
+
+	tests := []struct {
+		name string
+		data interface{}
+		opts []Option
+		want string
+	}{
+		{"None", redactText{"s"}, nil, `{"data":{"Secret":"s"}}`},
+		{"Public", u, []Option{WithRedaction()},
+			`{"data":{"id":1,"name":"a","Untagged":"u","manager":{"id":2,"name":"","Untagged":""}}}`},
+		{"Admin", u, []Option{WithRedaction("admin")},
+			`{"data":{"id":1,"name":"a","email":"a@example.com","Untagged":"u","createdBy":"c","manager":{"id":2,"name":"","email":"m@example.com","Untagged":"","createdBy":""}}}`},
+		{"Auditor", &u, []Option{WithRedaction("auditor")},
+			`{"data":{"id":1,"name":"a","Untagged":"u","createdBy":"c","manager":{"id":2,"name":"","Untagged":"","createdBy":""}}}`},
+		{"Slice", []redactUser{{ID: 1, Password: "p"}, {ID: 2, Email: "b@example.com"}}, []Option{WithRedaction()},
+			`{"data":[{"id":1,"name":"","Untagged":""},{"id":2,"name":"","Untagged":""}]}`},
+		{"Map", map[string]interface{}{"user": redactUser{ID: 1, Password: "p"}, "n": 1}, []Option{WithRedaction()},
+			`{"data":{"n":1,"user":{"id":1,"name":"","Untagged":""}}}`},
+		{"MapIntKeys", map[int]redactUser{1: {ID: 1, Email: "a@example.com", Password: "p"}}, []Option{WithRedaction()},
+			`{"data":{"1":{"id":1,"name":"","Untagged":""}}}`},
+		{"MapUintKeys", map[uint8]redactUser{2: {ID: 2, Email: "b@example.com"}}, []Option{WithRedaction("admin")},
+			`{"data":{"2":{"id":2,"name":"","email":"b@example.com","Untagged":"","createdBy":""}}}`},
+		{"MapTextKeys", map[redactKey]redactUser{{"a"}: {ID: 1, Email: "a@example.com"}}, []Option{WithRedaction()},
+			`{"data":{"key-a":{"id":1,"name":"","Untagged":""}}}`},
+		{"Marshaler", redactText{"s"}, []Option{WithRedaction()}, `{"data":{"Secret":"s"}}`},
+		{"Scalar", 42, []Option{WithRedaction()}, `{"data":42}`},
+		{"Fields", u, []Option{WithRedaction(), WithFields(FieldSet{"id": nil, "password": nil})}, `{"data":{"id":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, tt.data, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("got body %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactFieldsEmbeddedPointer(t *testing.T) {
+	type inner struct {
+		Secret string `json:"secret" jsonresp:"redact"`
+		Public string `json:"public"`
+	}
+	type outer struct {
+		*inner
+		Public string `json:"public"`
+	}
+
+	tests := []struct {
+		name string
+		v    outer
+		want string
+	}{
+		{"Nil", outer{Public: "o"}, `{"data":{"public":"o"}}`},
+		{"Shadowed", outer{inner: &inner{"s", "i"}, Public: "o"}, `{"data":{"public":"o"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, tt.v, http.StatusOK, WithRedaction()); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("got body %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// mapping translates errors into Errors, reporting whether it applies to err.
+type mapping func(err error) (*Error, bool)
+
+var (
+	registryMu sync.RWMutex
+	registry   []mapping
+)
+
+// mapTo returns a mapping that translates errors for which match returns true into an Error with
+// the supplied status code and message. If message is empty, the text of the error is used.
+func mapTo(match func(error) bool, code int, message string) mapping {
+	return func(err error) (*Error, bool) {
+		if !match(err) {
+			return nil, false
+		}
+		if message == "" {
+			return NewError(err.Error(), code), true
+		}
+		return NewError(message, code), true
+	}
+}
+
+// Register maps errors matching target (as reported by errors.Is) to the supplied status code and
+// message when written by WriteErr. If message is empty, the text of the error is used. Mappings
+// are consulted in the order they were registered.
+func Register(target error, code int, message string) {
+	register(mapTo(func(err error) bool { return errors.Is(err, target) }, code, message))
+}
+
+// RegisterType maps errors whose chain contains a value of the same type as target (as reported
+// by errors.As) to the supplied status code and message when written by WriteErr. Typically,
+// target is a nil pointer of the relevant type (ie. (*os.PathError)(nil)). If message is empty,
+// the text of the error is used. Mappings are consulted in the order they were registered.
+func RegisterType(target error, code int, message string) {
+	t := reflect.TypeOf(target)
+	if t == nil {
+		panic("jsonresp: target must be a non-nil error type")
+	}
+	register(mapTo(func(err error) bool { return errors.As(err, reflect.New(t).Interface()) }, code, message))
+}
+
+// RegisterFunc adds f to the mappings consulted by WriteErr, such that errors for which f returns
+// true are written as the Error it returns. This allows the errors of a database driver or
+// validation library, for example, to be translated in one place. If the Error returned has no
+// status code, 500 is used. Functions are consulted along with the mappings established by
+// Register and RegisterType, in the order they were registered.
+func RegisterFunc(f func(err error) (*Error, bool)) {
+	if f == nil {
+		panic("jsonresp: f must not be nil")
+	}
+	register(func(err error) (*Error, bool) {
+		je, ok := f(err)
+		if !ok || je == nil {
+			return nil, false
+		}
+		if je.Code == 0 {
+			c := *je
+			c.Code = http.StatusInternalServerError
+			je = &c
+		}
+		return je, true
+	})
+}
+
+func register(m mapping) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// lookup returns the Error described by the first registered mapping that applies to err.
+func lookup(err error) (*Error, bool) {
+	registryMu.RLock()
+	ms := registry
+	registryMu.RUnlock()
+
+	// The lock is not held while consulting mappings, so that functions registered with
+	// RegisterFunc may themselves register mappings.
+	for _, m := range ms {
+		if je, ok := m(err); ok {
+			return je, true
+		}
+	}
+	return nil, false
+}
+
+// StatusCoder is implemented by errors that report the HTTP status code they should be written
+// with.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// HTTPStatuser is implemented by errors that report the HTTP status code they should be written
+// with.
+type HTTPStatuser interface {
+	HTTPStatus() int
+}
+
+// statusFor returns the non-zero status code reported by an error in the chain of err that
+// implements StatusCoder or HTTPStatuser.
+func statusFor(err error) (int, bool) {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		if code := sc.StatusCode(); code != 0 {
+			return code, true
+		}
+	}
+	var hs HTTPStatuser
+	if errors.As(err, &hs) {
+		if code := hs.HTTPStatus(); code != 0 {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// errorFor returns an Error describing err. If err is, or wraps, an Error, it is used directly.
+// Otherwise, the registered mappings are consulted, followed by the Error returned by the New
+// method of an ErrorDef, and the StatusCoder and HTTPStatuser interfaces. If none of these apply,
+// an Error with status code 500 and the text of err is returned.
+func errorFor(err error) *Error {
+	var je *Error
+	if errors.As(err, &je) {
+		if je.Code == 0 {
+			c := *je
+			c.Code = http.StatusInternalServerError
+			je = &c
+		}
+		return je
+	}
+
+	if je, ok := lookup(err); ok {
+		return je
+	}
+
+	var d *ErrorDef
+	if errors.As(err, &d) {
+		return errorFor(d.New())
+	}
+
+	if code, ok := statusFor(err); ok {
+		return NewError(err.Error(), code)
+	}
+
+	return NewError(err.Error(), http.StatusInternalServerError)
+}
+
+// WriteErr writes a status code and JSON response describing err to w. If err is, or wraps, an
+// Error, its fields are written directly. Otherwise, the mappings established by Register,
+// RegisterType and RegisterFunc are consulted to determine the status code and message. If no
+// mapping matches, and an error in the chain is an ErrorDef, the Error returned by its New method
+// is written. Failing that, if an error in the chain implements StatusCoder or HTTPStatuser, the
+// reported status code is written along with the text of err. Failing that, a 500 status code is
+// written along with the text of err.
+func WriteErr(w http.ResponseWriter, err error, opts ...Option) error {
+	if err == nil {
+		return writeError(w, NewError("", http.StatusInternalServerError), nil, newOptions(opts))
+	}
+	return writeError(w, errorFor(err), err, newOptions(opts))
+}
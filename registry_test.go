@@ -0,0 +1,151 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+type testRegistryError struct{}
+
+func (testRegistryError) Error() string { return "registry error" }
+
+type testStatusCoderError struct{ code int }
+
+func (e testStatusCoderError) Error() string   { return "status coder error" }
+func (e testStatusCoderError) StatusCode() int { return e.code }
+
+type testHTTPStatuserError struct{ code int }
+
+func (e testHTTPStatuserError) Error() string   { return "http statuser error" }
+func (e testHTTPStatuserError) HTTPStatus() int { return e.code }
+
+func TestWriteErr(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+	errSentinelNoMessage := errors.New("sentinel no message")
+
+	Register(errSentinel, http.StatusNotFound, "not found")
+	Register(errSentinelNoMessage, http.StatusConflict, "")
+	RegisterType((*fs.PathError)(nil), http.StatusBadRequest, "bad path")
+	RegisterType(testRegistryError{}, http.StatusTeapot, "")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantErr  error
+	}{
+		{"Nil", nil, http.StatusInternalServerError, &Error{Code: http.StatusInternalServerError}},
+		{"Error", NewError("blah", http.StatusForbidden), http.StatusForbidden, &Error{Code: http.StatusForbidden, Message: "blah"}},
+		{"ErrorWrapped", fmt.Errorf("wrapped: %w", NewError("blah", http.StatusForbidden)), http.StatusForbidden, &Error{Code: http.StatusForbidden, Message: "blah"}},
+		{"ErrorNoCode", &Error{Message: "blah"}, http.StatusInternalServerError, &Error{Code: http.StatusInternalServerError, Message: "blah"}},
+		{"Sentinel", errSentinel, http.StatusNotFound, &Error{Code: http.StatusNotFound, Message: "not found"}},
+		{"SentinelWrapped", fmt.Errorf("wrapped: %w", errSentinel), http.StatusNotFound, &Error{Code: http.StatusNotFound, Message: "not found"}},
+		{"SentinelNoMessage", errSentinelNoMessage, http.StatusConflict, &Error{Code: http.StatusConflict, Message: "sentinel no message"}},
+		{"Type", &fs.PathError{Op: "open", Path: "x", Err: os.ErrNotExist}, http.StatusBadRequest, &Error{Code: http.StatusBadRequest, Message: "bad path"}},
+		{"TypeValue", fmt.Errorf("wrapped: %w", testRegistryError{}), http.StatusTeapot, &Error{Code: http.StatusTeapot, Message: "wrapped: registry error"}},
+		{"StatusCoder", testStatusCoderError{http.StatusGone}, http.StatusGone, &Error{Code: http.StatusGone, Message: "status coder error"}},
+		{"StatusCoderWrapped", fmt.Errorf("wrapped: %w", testStatusCoderError{http.StatusGone}), http.StatusGone, &Error{Code: http.StatusGone, Message: "wrapped: status coder error"}},
+		{"StatusCoderZero", testStatusCoderError{}, http.StatusInternalServerError, &Error{Code: http.StatusInternalServerError, Message: "status coder error"}},
+		{"HTTPStatuser", testHTTPStatuserError{http.StatusUnauthorized}, http.StatusUnauthorized, &Error{Code: http.StatusUnauthorized, Message: "http statuser error"}},
+		{"Unmapped", errors.New("blah"), http.StatusInternalServerError, &Error{Code: http.StatusInternalServerError, Message: "blah"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			if err := WriteErr(rr, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := ReadError(rr.Body), tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+type testTranslatedError struct {
+	field string
+}
+
+func (e testTranslatedError) Error() string { return "invalid " + e.field }
+
+func TestRegisterFunc(t *testing.T) {
+	errShadowed := errors.New("shadowed")
+	errNoCode := errors.New("no code")
+	errDeclined := errors.New("declined")
+
+	RegisterFunc(func(err error) (*Error, bool) {
+		var te testTranslatedError
+		if !errors.As(err, &te) {
+			return nil, false
+		}
+		return &Error{
+			Code:    http.StatusUnprocessableEntity,
+			Message: "validation failed",
+			Details: map[string]interface{}{"field": te.field},
+		}, true
+	})
+	RegisterFunc(func(err error) (*Error, bool) {
+		switch {
+		case errors.Is(err, errShadowed):
+			return NewError("translated", http.StatusBadRequest), true
+		case errors.Is(err, errNoCode):
+			return &Error{Message: "no code"}, true
+		case errors.Is(err, errDeclined):
+			return nil, true
+		}
+		return nil, false
+	})
+	// Mappings are consulted in order, so this mapping is shadowed by the function above.
+	Register(errShadowed, http.StatusTeapot, "")
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantErr  error
+	}{
+		{"Translated", fmt.Errorf("wrapped: %w", testTranslatedError{"name"}), http.StatusUnprocessableEntity, &Error{Code: http.StatusUnprocessableEntity, Message: "validation failed"}},
+		{"Order", errShadowed, http.StatusBadRequest, &Error{Code: http.StatusBadRequest, Message: "translated"}},
+		{"NoCode", errNoCode, http.StatusInternalServerError, &Error{Code: http.StatusInternalServerError, Message: "no code"}},
+		{"NilError", errDeclined, http.StatusInternalServerError, &Error{Code: http.StatusInternalServerError, Message: "declined"}},
+		{"Error", NewError("blah", http.StatusForbidden), http.StatusForbidden, &Error{Code: http.StatusForbidden, Message: "blah"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			if err := WriteErr(rr, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := ReadError(rr.Body), tt.wantErr; !errors.Is(got, want) {
+				t.Errorf("got error %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("Details", func(t *testing.T) {
+		je := errorFor(testTranslatedError{"name"})
+		if got, want := je.Details["field"], "name"; got != want {
+			t.Errorf("got field %v, want %v", got, want)
+		}
+	})
+}
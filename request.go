@@ -0,0 +1,83 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxRequestSize is the maximum size of a request body read by ReadRequest, unless
+// overridden by WithMaxBodySize.
+const DefaultMaxRequestSize = 1 << 20
+
+// ReadRequest reads the JSON-encoded body of r, and unmarshals it into v. Unlike the response
+// read functions, the body is not expected to be wrapped in a response envelope.
+//
+// The request must have a Content-Type of "application/json", or the media type established by
+// WithContentType or WithFormat, unless WithAllowedContentTypes is used, and a charset parameter,
+// if any, must name UTF-8, unless established by WithCharset or WithAllowedContentTypes. The body
+// is limited to DefaultMaxRequestSize bytes, and is decoded as if by WithStrict. Reading is
+// abandoned if the context of r is done.
+//
+// If the request is unacceptable, the returned error is an Error with a 400, 413 or 415 status
+// code describing the problem, suitable for writing with WriteRequestError.
+func ReadRequest(r *http.Request, v interface{}, opts ...Option) error {
+	o := newOptions(append([]Option{WithMaxBodySize(DefaultMaxRequestSize)}, opts...))
+	o.strict = true
+	o.noEnvelope = true
+	o.ctx = r.Context()
+
+	if je := o.checkRequestContentType(r.Header.Get("Content-Type")); je != nil {
+		return je
+	}
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return NewError("request body is empty", http.StatusBadRequest)
+	}
+
+	err := o.decode(r.Body, v)
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, ErrBodyTooLarge) {
+		return &Error{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("request body exceeds %v bytes", o.maxBodySize),
+		}
+	}
+	if ctxErr := o.ctxErr(); ctxErr != nil {
+		return fmt.Errorf("jsonresp: failed to read request: %w", ctxErr)
+	}
+	if errors.Is(err, io.EOF) {
+		return NewError("request body is empty", http.StatusBadRequest)
+	}
+
+	je := NewError("invalid request body", http.StatusBadRequest)
+
+	var de *DecodeError
+	if errors.As(err, &de) {
+		je.Message = "invalid request body: " + de.Err.Error()
+		if de.Offset >= 0 {
+			je.Details = map[string]interface{}{"offset": de.Offset}
+		}
+	}
+	return je
+}
+
+// WriteRequestError writes a status code and JSON response describing err, as returned by
+// ReadRequest, to w. Errors that do not describe a status code are written with a 400 status
+// code.
+func WriteRequestError(w http.ResponseWriter, err error, opts ...Option) error {
+	var je *Error
+	if !errors.As(err, &je) {
+		je = NewError("invalid request", http.StatusBadRequest)
+	}
+	return writeError(w, je, err, newOptions(opts))
+}
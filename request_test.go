@@ -0,0 +1,107 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadRequest(t *testing.T) {
+	type TestStruct struct {
+		Value string
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		opts        []Option
+		wantCode    int
+		wantValue   string
+	}{
+		{"OK", "application/json", `{"Value":"blah"}`, nil, 0, "blah"},
+		{"OKCharset", "application/json; charset=utf-8", `{"Value":"blah"}`, nil, 0, "blah"},
+		{"ContentTypeOption", "application/vnd.test+json", `{"Value":"blah"}`, []Option{WithContentType("application/vnd.test+json")}, 0, "blah"},
+		{"NoContentType", "", `{"Value":"blah"}`, nil, http.StatusUnsupportedMediaType, ""},
+		{"WrongContentType", "text/plain", `{"Value":"blah"}`, nil, http.StatusUnsupportedMediaType, ""},
+		{"OKCharsetUTF8", "application/json; charset=UTF8", `{"Value":"blah"}`, nil, 0, "blah"},
+		{"WrongCharset", "application/json; charset=iso-8859-1", `{"Value":"blah"}`, nil, http.StatusUnsupportedMediaType, ""},
+		{"CharsetOption", "application/json; charset=iso-8859-1", `{"Value":"blah"}`, []Option{WithCharset("ISO-8859-1")}, 0, "blah"},
+		{"CharsetAllowed", "text/plain; charset=iso-8859-1", `{"Value":"blah"}`, []Option{WithAllowedContentTypes("text/plain; charset=iso-8859-1")}, 0, "blah"},
+		{"Empty", "application/json", ``, nil, http.StatusBadRequest, ""},
+		{"Invalid", "application/json", `{"Value":}`, nil, http.StatusBadRequest, ""},
+		{"UnknownField", "application/json", `{"Value":"blah","Other":1}`, nil, http.StatusBadRequest, "blah"},
+		{"TooLarge", "application/json", `{"Value":"blah"}`, []Option{WithMaxBodySize(4)}, http.StatusRequestEntityTooLarge, ""},
+		{"TooLargeDefault", "application/json", `{"Value":"` + strings.Repeat("a", DefaultMaxRequestSize) + `"}`, nil, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var ts TestStruct
+			err := ReadRequest(r, &ts, tt.opts...)
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("failed to read request: %v", err)
+				}
+			} else if !errors.Is(err, &Error{Code: tt.wantCode}) {
+				t.Fatalf("got error %v, want code %v", err, tt.wantCode)
+			}
+
+			if got, want := ts.Value, tt.wantValue; got != want {
+				t.Errorf("got value %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadRequestOffset(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"Value":}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	var je *Error
+	if !errors.As(ReadRequest(r, &struct{ Value string }{}), &je) {
+		t.Fatalf("failed to read error")
+	}
+	if got, want := je.Details["offset"], int64(10); got != want {
+		t.Errorf("got offset %v, want %v", got, want)
+	}
+}
+
+func TestWriteRequestError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{"UnsupportedMediaType", NewError("blah", http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType},
+		{"RequestEntityTooLarge", NewError("blah", http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge},
+		{"Other", errors.New("blah"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteRequestError(rr, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if err := ReadError(rr.Body); !errors.Is(err, &Error{Code: tt.wantCode}) {
+				t.Errorf("got error %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
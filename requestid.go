@@ -0,0 +1,83 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// RequestIDHeader is the header from which request IDs are extracted by default, and in which
+// they are returned to the client.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID id.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or an empty string if there is
+// none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+var (
+	requestIDMu   sync.RWMutex
+	requestIDFunc func(*http.Request) string
+)
+
+// SetRequestIDFunc sets the function used to extract the request ID of a request. If f is nil,
+// the ID carried by the request context, as established by ContextWithRequestID, is used, falling
+// back to the value of the X-Request-Id header.
+func SetRequestIDFunc(f func(r *http.Request) string) {
+	requestIDMu.Lock()
+	defer requestIDMu.Unlock()
+	requestIDFunc = f
+}
+
+// requestID returns the request ID of r, or an empty string if it has none.
+func requestID(r *http.Request) string {
+	requestIDMu.RLock()
+	f := requestIDFunc
+	requestIDMu.RUnlock()
+
+	if f != nil {
+		return f(r)
+	}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
+}
+
+// WithRequestID causes the request ID of r, as extracted by the function established by
+// SetRequestIDFunc, to be returned in the X-Request-Id header of the response. If the response
+// describes an error, the ID is also included in the error, so that it can be quoted when
+// reporting problems. WriteNegotiatedErr and WriteLocalizedErr apply WithRequestID implicitly.
+func WithRequestID(r *http.Request) Option {
+	id := requestID(r)
+	return func(o *options) {
+		o.requestID = id
+		if id != "" {
+			WithHeader(RequestIDHeader, id)(o)
+		}
+	}
+}
+
+// withRequestID adds the request ID established by o to je, if it does not already have one.
+func (o *options) withRequestID(je *Error) *Error {
+	if o.requestID == "" || je.RequestID != "" {
+		return je
+	}
+	c := *je
+	c.RequestID = o.requestID
+	return &c
+}
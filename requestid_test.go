@@ -0,0 +1,124 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		ctxID  string
+		f      func(*http.Request) string
+		want   string
+	}{
+		{"None", "", "", nil, ""},
+		{"Header", "abc", "", nil, "abc"},
+		{"Context", "abc", "def", nil, "def"},
+		{"Func", "abc", "def", func(r *http.Request) string { return "ghi" }, "ghi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRequestIDFunc(tt.f)
+			defer SetRequestIDFunc(nil)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(RequestIDHeader, tt.header)
+			}
+			if tt.ctxID != "" {
+				r = r.WithContext(ContextWithRequestID(r.Context(), tt.ctxID))
+			}
+
+			if got, want := requestID(r), tt.want; got != want {
+				t.Errorf("got request ID %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithRequestID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "abc")
+
+	tests := []struct {
+		name     string
+		write    func(w http.ResponseWriter) error
+		wantBody string
+	}{
+		{"Response", func(w http.ResponseWriter) error {
+			return WriteResponse(w, "blah", http.StatusOK, WithRequestID(r))
+		}, `{"data":"blah"}`},
+		{"Error", func(w http.ResponseWriter) error {
+			return WriteError(w, "blah", http.StatusNotFound, WithRequestID(r))
+		}, `{"error":{"code":404,"message":"blah","requestId":"abc"}}`},
+		{"ErrorExisting", func(w http.ResponseWriter) error {
+			je := &Error{Code: http.StatusNotFound, RequestID: "def"}
+			return WriteErr(w, je, WithRequestID(r))
+		}, `{"error":{"code":404,"requestId":"def"}}`},
+		{"Negotiated", func(w http.ResponseWriter) error {
+			return WriteNegotiatedErr(w, r, NewError("blah", http.StatusConflict))
+		}, `{"error":{"code":409,"message":"blah","requestId":"abc"}}`},
+		{"Localized", func(w http.ResponseWriter) error {
+			return WriteLocalizedErr(w, r, NewError("blah", http.StatusConflict))
+		}, `{"error":{"code":409,"message":"blah","requestId":"abc"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := tt.write(rr); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, want := rr.Header().Get(RequestIDHeader), "abc"; got != want {
+				t.Errorf("got header %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithRequestIDProduction(t *testing.T) {
+	SetProduction(true, nil)
+	defer SetProduction(false, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "abc")
+
+	rr := httptest.NewRecorder()
+	if err := WriteErr(rr, errors.New("secret"), WithRequestID(r)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	var je *Error
+	if err := ReadError(rr.Body); !errors.As(err, &je) {
+		t.Fatalf("got error %v, want *Error", err)
+	}
+	if got, want := je.RequestID, "abc"; got != want {
+		t.Errorf("got request ID %q, want %q", got, want)
+	}
+}
+
+func TestWithRequestIDNone(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithRequestID(r)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if _, ok := rr.Header()[RequestIDHeader]; ok {
+		t.Errorf("unexpected %v header", RequestIDHeader)
+	}
+	if got, want := rr.Body.String(), `{"error":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy specifies how requests sent by the client helpers are retried. Idempotent requests
+// are retried when sending fails due to a network error, or the response has the status 429 Too
+// Many Requests, 502 Bad Gateway, 503 Service Unavailable or 504 Gateway Timeout.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, including the first. If zero,
+	// DefaultRetryPolicy.MaxAttempts is used.
+	MaxAttempts int
+
+	// MinBackoff is the interval to wait before the first retry, which doubles with each
+	// subsequent retry. If zero, DefaultRetryPolicy.MinBackoff is used.
+	MinBackoff time.Duration
+
+	// MaxBackoff is the maximum interval to wait between attempts. If zero,
+	// DefaultRetryPolicy.MaxBackoff is used. A Retry-After header in the response takes precedence
+	// over the backoff, and is not limited by MaxBackoff.
+	MaxBackoff time.Duration
+
+	// OnRetry, if not nil, is called before waiting to retry a request, with a description of the
+	// failed attempt.
+	OnRetry func(RetryInfo)
+}
+
+// DefaultRetryPolicy is the policy used by WithRetry in place of the zero fields of a RetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+}
+
+// RetryInfo describes a failed attempt to send a request that is to be retried.
+type RetryInfo struct {
+	// Request is the request that was sent.
+	Request *http.Request
+
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+
+	// Response is the response to the failed attempt, or nil if Err is not nil. Its body has been
+	// closed.
+	Response *http.Response
+
+	// Err is the error encountered sending the request, if any.
+	Err error
+
+	// Wait is the interval to wait before the next attempt.
+	Wait time.Duration
+}
+
+// WithRetry causes the client helpers, such as Do, FetchAll and PollOperation, to retry requests
+// according to p. By default, requests are not retried.
+func WithRetry(p RetryPolicy) Option {
+	return func(o *options) {
+		if p.MaxAttempts == 0 {
+			p.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+		}
+		if p.MinBackoff == 0 {
+			p.MinBackoff = DefaultRetryPolicy.MinBackoff
+		}
+		if p.MaxBackoff == 0 {
+			p.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+		}
+		o.retry = &p
+	}
+}
+
+// Do sends req using c, retrying according to the policy established by WithRetry, and using the
+// cache established by WithResponseCache. If c is nil, http.DefaultClient is used. The response to
+// the final attempt is returned, whether or not it was successful, so that it may be read with
+// ReadHTTPResponse.
+//
+// A request is retried only if it is idempotent, meaning its method is GET, HEAD, OPTIONS, TRACE,
+// PUT or DELETE, or it has an Idempotency-Key header, and its body is empty or can be obtained
+// again using its GetBody function.
+func Do(c *http.Client, req *http.Request, opts ...Option) (*http.Response, error) {
+	return newOptions(opts).do(c, req)
+}
+
+// do sends req using c, retrying according to the policy established by o, and answering it from
+// the cache established by WithResponseCache if any.
+func (o *options) do(c *http.Client, req *http.Request) (*http.Response, error) {
+	if o.responseCache != nil {
+		return o.doCached(c, req)
+	}
+	return o.send(c, req)
+}
+
+// send sends req using c, retrying according to the policy established by o.
+func (o *options) send(c *http.Client, req *http.Request) (*http.Response, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	p := o.retry
+	if p == nil || !canRetry(req) {
+		return c.Do(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := c.Do(req)
+		if attempt >= p.MaxAttempts || !shouldRetry(req.Context(), res, err) {
+			return res, err
+		}
+
+		wait := p.backoff(attempt)
+		if res != nil {
+			if s, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				wait = time.Duration(s) * time.Second
+			}
+			_, _ = io.CopyN(io.Discard, res.Body, maxDrainSize)
+			res.Body.Close()
+		}
+
+		if p.OnRetry != nil {
+			p.OnRetry(RetryInfo{Request: req, Attempt: attempt, Response: res, Err: err, Wait: wait})
+		}
+
+		if err := retrySleep(req.Context(), wait); err != nil {
+			return nil, fmt.Errorf("jsonresp: failed to retry request: %w", err)
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("jsonresp: failed to retry request: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// canRetry reports whether req is idempotent, and can be sent again.
+func canRetry(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetry reports whether the outcome of sending a request, res or err, is transient.
+func shouldRetry(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the interval to wait after the supplied failed attempt. The interval doubles
+// with each attempt, up to the maximum, and is randomized to between half and all of its value so
+// that clients retrying at the same time spread their requests.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2))) //nolint:gosec // jitter need not be secure
+}
+
+// retrySleep waits for d to elapse, or ctx to be done. It is a variable so that tests may avoid
+// waiting.
+var retrySleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordSleeps replaces retrySleep for the duration of the test, recording the intervals waited
+// rather than waiting.
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+
+	var waits []time.Duration
+	prev := retrySleep
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { retrySleep = prev })
+	return &waits
+}
+
+// failingHandler responds with code to the first n requests, and thereafter with a successful
+// response containing the request body.
+func failingHandler(n, code int, header http.Header) (http.Handler, *int) {
+	var requests int
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests <= n {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			_ = WriteError(w, "failed", code)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		_ = WriteResponse(w, []string{string(b)}, http.StatusOK)
+	}), &requests
+}
+
+func TestDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		header       http.Header
+		failures     int
+		code         int
+		opts         []Option
+		wantRequests int
+		wantCode     int
+	}{
+		{"NoRetry", http.MethodGet, nil, 1, http.StatusServiceUnavailable, nil, 1, http.StatusServiceUnavailable},
+		{"TooManyRequests", http.MethodGet, nil, 1, http.StatusTooManyRequests, []Option{WithRetry(RetryPolicy{})}, 2, http.StatusOK},
+		{"BadGateway", http.MethodGet, nil, 1, http.StatusBadGateway, []Option{WithRetry(RetryPolicy{})}, 2, http.StatusOK},
+		{"ServiceUnavailable", http.MethodGet, nil, 2, http.StatusServiceUnavailable, []Option{WithRetry(RetryPolicy{})}, 3, http.StatusOK},
+		{"GatewayTimeout", http.MethodGet, nil, 1, http.StatusGatewayTimeout, []Option{WithRetry(RetryPolicy{})}, 2, http.StatusOK},
+		{"InternalServerError", http.MethodGet, nil, 1, http.StatusInternalServerError, []Option{WithRetry(RetryPolicy{})}, 1, http.StatusInternalServerError},
+		{"MaxAttempts", http.MethodGet, nil, 5, http.StatusServiceUnavailable, []Option{WithRetry(RetryPolicy{MaxAttempts: 3})}, 3, http.StatusServiceUnavailable},
+		{"Put", http.MethodPut, nil, 1, http.StatusServiceUnavailable, []Option{WithRetry(RetryPolicy{})}, 2, http.StatusOK},
+		{"Post", http.MethodPost, nil, 1, http.StatusServiceUnavailable, []Option{WithRetry(RetryPolicy{})}, 1, http.StatusServiceUnavailable},
+		{"PostIdempotencyKey", http.MethodPost, http.Header{"Idempotency-Key": {"k"}}, 1, http.StatusServiceUnavailable, []Option{WithRetry(RetryPolicy{})}, 2, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordSleeps(t)
+
+			h, requests := failingHandler(tt.failures, tt.code, nil)
+			s := httptest.NewServer(h)
+			defer s.Close()
+
+			req, err := http.NewRequestWithContext(context.Background(), tt.method, s.URL, strings.NewReader("body"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+
+			res, err := Do(s.Client(), req, tt.opts...)
+			if err != nil {
+				t.Fatalf("failed to send request: %v", err)
+			}
+
+			var got []string
+			_, err = ReadHTTPResponse(res, &got)
+			if code, _ := StatusCode(err); err == nil && res.StatusCode != tt.wantCode || err != nil && code != tt.wantCode {
+				t.Errorf("got code %v, error %v, want code %v", res.StatusCode, err, tt.wantCode)
+			}
+			if err == nil && (len(got) != 1 || got[0] != "body") {
+				t.Errorf("got body %q, want %q", got, "body")
+			}
+			if got, want := *requests, tt.wantRequests; got != want {
+				t.Errorf("got %v requests, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDoRetryAfter(t *testing.T) {
+	waits := recordSleeps(t)
+
+	h, _ := failingHandler(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"7"}})
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	var infos []RetryInfo
+	p := RetryPolicy{OnRetry: func(ri RetryInfo) { infos = append(infos, ri) }}
+
+	if _, err := FetchAll[string](context.Background(), s.Client(), s.URL, WithRetry(p)); err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+
+	if got, want := *waits, []time.Duration{7 * time.Second}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("got waits %v, want %v", got, want)
+	}
+	if len(infos) != 1 {
+		t.Fatalf("got %v retries, want 1", len(infos))
+	}
+	if got, want := infos[0].Attempt, 1; got != want {
+		t.Errorf("got attempt %v, want %v", got, want)
+	}
+	if got, want := infos[0].Response.StatusCode, http.StatusTooManyRequests; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if got, want := infos[0].Wait, 7*time.Second; got != want {
+		t.Errorf("got wait %v, want %v", got, want)
+	}
+}
+
+func TestDoNetworkError(t *testing.T) {
+	waits := recordSleeps(t)
+
+	s := httptest.NewServer(http.NotFoundHandler())
+	url := s.URL
+	s.Close()
+
+	var errs []error
+	p := RetryPolicy{MaxAttempts: 3, OnRetry: func(ri RetryInfo) { errs = append(errs, ri.Err) }}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Do(nil, req, WithRetry(p)); err == nil {
+		t.Fatal("unexpected success")
+	}
+
+	if got, want := len(*waits), 2; got != want {
+		t.Errorf("got %v waits, want %v", got, want)
+	}
+	for _, err := range errs {
+		if err == nil {
+			t.Error("got nil retry error")
+		}
+	}
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	recordSleeps(t)
+
+	h, requests := failingHandler(5, http.StatusServiceUnavailable, nil)
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := RetryPolicy{OnRetry: func(RetryInfo) { cancel() }}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Do(s.Client(), req, WithRetry(p)); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if got, want := *requests, 1; got != want {
+		t.Errorf("got %v requests, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.backoff(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Errorf("attempt %v: got backoff %v, want between %v and %v", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"container/list"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxCachedResponses is the number of responses retained by the ResponseStore used by
+// RevalidationHandler, unless its Store field is set.
+const DefaultMaxCachedResponses = 1000
+
+// CachedResponse is a successful response to a GET request retained by a ResponseStore.
+type CachedResponse struct {
+	// ETag is the entity tag of the response.
+	ETag string
+
+	// Header contains the headers of the response.
+	Header http.Header
+
+	// Body is the body of the response. It is nil for responses retained by RevalidationHandler,
+	// which only answers requests that revalidate them.
+	Body []byte
+
+	// Vary contains the values of the request headers named by the Vary header of the response,
+	// which a request must match to be served from the cache.
+	Vary http.Header
+
+	// Expires is the time after which the response must not be served from the cache, or the zero
+	// time if it does not expire.
+	Expires time.Time
+}
+
+// matches reports whether the cached response may be used to respond to r at time t.
+func (cr *CachedResponse) matches(r *http.Request, t time.Time) bool {
+	if !cr.Expires.IsZero() && !t.Before(cr.Expires) {
+		return false
+	}
+	return cr.varyMatches(r)
+}
+
+// varyMatches reports whether the headers of r match those named by the Vary header of the cached
+// response.
+func (cr *CachedResponse) varyMatches(r *http.Request) bool {
+	for k, v := range cr.Vary {
+		if strings.Join(r.Header.Values(k), ",") != strings.Join(v, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// ResponseStore stores cached responses, keyed by request URL. Implementations must be safe for
+// concurrent use, and may discard responses at any time.
+type ResponseStore interface {
+	// Load returns the response stored with key, if any.
+	Load(key string) (*CachedResponse, bool)
+
+	// Store stores cr with key, replacing any response already stored with it.
+	Store(key string, cr *CachedResponse)
+
+	// Delete removes the response stored with key, if any.
+	Delete(key string)
+}
+
+// MemoryStore is a ResponseStore that retains a bounded number of responses in memory, discarding
+// the least recently used when full.
+type MemoryStore struct {
+	max int
+
+	mu    sync.Mutex
+	ll    *list.List // of *memoryEntry, most recently used first
+	items map[string]*list.Element
+}
+
+// memoryEntry is an entry of a MemoryStore.
+type memoryEntry struct {
+	key string
+	cr  *CachedResponse
+}
+
+// NewMemoryStore returns a MemoryStore that retains up to max responses.
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{
+		max:   max,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Load returns the response stored with key, if any.
+func (s *MemoryStore) Load(key string) (*CachedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	s.ll.MoveToFront(e)
+	return e.Value.(*memoryEntry).cr, true
+}
+
+// Store stores cr with key, replacing any response already stored with it.
+func (s *MemoryStore) Store(key string, cr *CachedResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		e.Value.(*memoryEntry).cr = cr
+		s.ll.MoveToFront(e)
+		return
+	}
+	s.items[key] = s.ll.PushFront(&memoryEntry{key: key, cr: cr})
+	for s.ll.Len() > s.max {
+		e := s.ll.Back()
+		s.ll.Remove(e)
+		delete(s.items, e.Value.(*memoryEntry).key)
+	}
+}
+
+// Delete removes the response stored with key, if any.
+func (s *MemoryStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.items[key]; ok {
+		s.ll.Remove(e)
+		delete(s.items, key)
+	}
+}
+
+// RevalidationHandler is an http.Handler that retains the entity tags of successful responses to
+// GET requests served by Handler, and responds to conditional GET and HEAD requests whose
+// If-None-Match header matches a retained entity tag with a 304 status code, without invoking
+// Handler. Responses are retained per URL, and are discarded when a request with an unsafe method
+// such as POST or DELETE succeeds for the same URL, or MaxAge elapses. Responses are only
+// consistent with the resource they represent if it is modified exclusively through requests
+// served by the same RevalidationHandler, or MaxAge bounds the staleness that can be tolerated.
+//
+// The entity tag of a response is taken from its ETag header, such as set by WithETag, or if not
+// set, computed from the encoded response in the same way, and added to the response. Responses
+// to GET requests are buffered in full, but their bodies are not retained. Responses with a Vary
+// header of "*", or a Cache-Control header containing no-store, are not retained.
+//
+// Requests with credentials, in the form of an Authorization or Cookie header, are always passed to
+// Handler, so that it can authorize them, and their responses are not retained.
+type RevalidationHandler struct {
+	// Handler serves requests that cannot be answered from the store.
+	Handler http.Handler
+
+	// Store retains responses. If nil, a MemoryStore retaining DefaultMaxCachedResponses
+	// responses is used.
+	Store ResponseStore
+
+	// MaxAge is the duration for which a response is retained. If zero, responses are retained
+	// until they are invalidated, or discarded by Store.
+	MaxAge time.Duration
+
+	once  sync.Once
+	store ResponseStore
+}
+
+// notModifiedHeaders are the headers written with a 304 response, per RFC 7232 section 4.1.
+var notModifiedHeaders = []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Vary"}
+
+// responseStore returns the store of rh.
+func (rh *RevalidationHandler) responseStore() ResponseStore { //nolint:ireturn
+	rh.once.Do(func() {
+		rh.store = rh.Store
+		if rh.store == nil {
+			rh.store = NewMemoryStore(DefaultMaxCachedResponses)
+		}
+	})
+	return rh.store
+}
+
+// hasCredentials reports whether r carries credentials, in the form of an Authorization or Cookie
+// header.
+func hasCredentials(r *http.Request) bool {
+	return r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != ""
+}
+
+// cacheKey returns the key with which the response to r is stored.
+func cacheKey(r *http.Request) string {
+	return r.Host + r.URL.RequestURI()
+}
+
+// ServeHTTP serves the request r.
+func (rh *RevalidationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	store := rh.responseStore()
+	key := cacheKey(r)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if hasCredentials(r) {
+			rh.Handler.ServeHTTP(w, r)
+			return
+		}
+		if inm := r.Header.Get("If-None-Match"); inm != "" {
+			if cr, ok := store.Load(key); ok && cr.matches(r, time.Now()) && etagMatches(inm, cr.ETag) {
+				writeNotModified(w, cr.Header)
+				return
+			}
+		}
+		if r.Method == http.MethodGet {
+			rh.serveGet(w, r, store, key)
+			return
+		}
+		rh.Handler.ServeHTTP(w, r)
+
+	case http.MethodOptions, http.MethodTrace:
+		rh.Handler.ServeHTTP(w, r)
+
+	default:
+		sw := &statusWriter{ResponseWriter: w}
+		rh.Handler.ServeHTTP(sw, r)
+		if sw.code < http.StatusBadRequest {
+			store.Delete(key)
+		}
+	}
+}
+
+// serveGet serves the GET request r, retaining the response in store with key if successful.
+func (rh *RevalidationHandler) serveGet(w http.ResponseWriter, r *http.Request, store ResponseStore, key string) {
+	rec := &batchRecorder{header: make(http.Header)}
+	rh.Handler.ServeHTTP(rec, r)
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+
+	if rec.code == http.StatusOK {
+		body := rec.body.Bytes()
+		etag := rec.header.Get("ETag")
+		if etag == "" {
+			etag = entityTag(body, rec.header.Get("Content-Encoding"))
+			rec.header.Set("ETag", etag)
+		}
+
+		if cr, ok := newCachedResponse(r, rec.header, nil, etag, rh.MaxAge); ok {
+			store.Store(key, cr)
+		} else {
+			store.Delete(key)
+		}
+	}
+	writeCachedResponse(w, r, rec.code, rec.header, rec.body.Bytes())
+}
+
+// newCachedResponse returns the response to r with header h, body and entity tag etag, as it is to
+// be retained for maxAge, or indefinitely if maxAge is zero. If the response must not be retained,
+// ok is false.
+func newCachedResponse(r *http.Request, h http.Header, body []byte, etag string, maxAge time.Duration) (cr *CachedResponse, ok bool) {
+	for _, v := range h.Values("Cache-Control") {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), "no-store") {
+				return nil, false
+			}
+		}
+	}
+
+	cr = &CachedResponse{
+		ETag:   etag,
+		Header: h.Clone(),
+		Body:   append([]byte(nil), body...),
+		Vary:   make(http.Header),
+	}
+	for _, v := range h.Values("Vary") {
+		for _, s := range strings.Split(v, ",") {
+			k := http.CanonicalHeaderKey(strings.TrimSpace(s))
+			if k == "*" {
+				return nil, false
+			}
+			if k != "" {
+				cr.Vary[k] = r.Header.Values(k)
+			}
+		}
+	}
+	if maxAge > 0 {
+		cr.Expires = time.Now().Add(maxAge)
+	}
+	return cr, true
+}
+
+// writeNotModified writes a 304 status code to w, with the headers of h that describe the
+// response it revalidates.
+func writeNotModified(w http.ResponseWriter, h http.Header) {
+	wh := w.Header()
+	for _, k := range notModifiedHeaders {
+		if v := h.Values(k); len(v) > 0 {
+			wh[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// statusWriter is an http.ResponseWriter that records the status code written to it.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	return sw.ResponseWriter.Write(p)
+}
+
+func (sw *statusWriter) Flush() {
+	if sw.code == 0 {
+		sw.code = http.StatusOK
+	}
+	_ = flushResponse(sw.ResponseWriter)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter { return sw.ResponseWriter }
@@ -0,0 +1,226 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// countingHandler returns a handler that writes a response containing the value of *v, and the
+// number of times it has been invoked.
+func countingHandler(v *string, h http.Header) (http.Handler, *int) {
+	var n int
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		for k, vv := range h {
+			w.Header()[k] = vv
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = WriteResponse(w, *v, http.StatusOK)
+	}), &n
+}
+
+func TestRevalidationHandler(t *testing.T) {
+	v := "a"
+	h, calls := countingHandler(&v, nil)
+	rh := &RevalidationHandler{Handler: h}
+
+	serve := func(method, inm string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/things?x=1", nil)
+		if inm != "" {
+			r.Header.Set("If-None-Match", inm)
+		}
+		for k, vv := range header {
+			r.Header[k] = vv
+		}
+		rr := httptest.NewRecorder()
+		rh.ServeHTTP(rr, r)
+		return rr
+	}
+
+	rr := serve(http.MethodGet, "", nil)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Body.String(), `{"data":"a"}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	etag := rr.Header().Get("ETag")
+	if want := entityTag([]byte(`{"data":"a"}`), ""); etag != want {
+		t.Fatalf("got ETag %q, want %q", etag, want)
+	}
+
+	// A matching conditional request is answered without invoking the handler.
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		rr = serve(method, etag, nil)
+		if got, want := rr.Code, http.StatusNotModified; got != want {
+			t.Errorf("%v: got code %v, want %v", method, got, want)
+		}
+		if got := rr.Header().Get("ETag"); got != etag {
+			t.Errorf("%v: got ETag %q, want %q", method, got, etag)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("%v: got body %q, want none", method, rr.Body)
+		}
+	}
+	if got, want := *calls, 1; got != want {
+		t.Errorf("got %v calls, want %v", got, want)
+	}
+
+	// A mismatched conditional request invokes the handler.
+	if rr = serve(http.MethodGet, `"other"`, nil); rr.Code != http.StatusOK {
+		t.Errorf("got code %v, want %v", rr.Code, http.StatusOK)
+	}
+	if got, want := *calls, 2; got != want {
+		t.Errorf("got %v calls, want %v", got, want)
+	}
+
+	// A successful unsafe request invalidates the retained response.
+	v = "b"
+	serve(http.MethodPut, "", nil)
+	rr = serve(http.MethodGet, etag, nil)
+	if got, want := rr.Code, http.StatusOK; got != want {
+		t.Fatalf("got code %v, want %v", got, want)
+	}
+	if got, want := rr.Body.String(), `{"data":"b"}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+	if got, want := *calls, 4; got != want {
+		t.Errorf("got %v calls, want %v", got, want)
+	}
+
+	// The handler's own conditional response is preserved.
+	etag = rr.Header().Get("ETag")
+	if rr = serve(http.MethodGet, etag, nil); rr.Code != http.StatusNotModified {
+		t.Errorf("got code %v, want %v", rr.Code, http.StatusNotModified)
+	}
+}
+
+func TestRevalidationHandlerNotRetained(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		req    http.Header
+		maxAge time.Duration
+		wait   time.Duration
+	}{
+		{"NoStore", http.Header{"Cache-Control": {"private, no-store"}}, nil, 0, 0},
+		{"VaryStar", http.Header{"Vary": {"*"}}, nil, 0, 0},
+		{"VaryMismatch", http.Header{"Vary": {"Accept-Language"}}, http.Header{"Accept-Language": {"fr"}}, 0, 0},
+		{"Expired", nil, nil, time.Millisecond, 5 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := "a"
+			h, calls := countingHandler(&v, tt.header)
+			rh := &RevalidationHandler{Handler: h, MaxAge: tt.maxAge}
+
+			rr := httptest.NewRecorder()
+			rh.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			time.Sleep(tt.wait)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+			for k, vv := range tt.req {
+				r.Header[k] = vv
+			}
+			rh.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got, want := *calls, 2; got != want {
+				t.Errorf("got %v calls, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore(2)
+	a, b, c := &CachedResponse{ETag: "a"}, &CachedResponse{ETag: "b"}, &CachedResponse{ETag: "c"}
+
+	s.Store("a", a)
+	s.Store("b", b)
+	if _, ok := s.Load("a"); !ok {
+		t.Fatal("a not found")
+	}
+	s.Store("c", c) // evicts b, the least recently used
+
+	for _, tt := range []struct {
+		key  string
+		want *CachedResponse
+	}{{"a", a}, {"b", nil}, {"c", c}} {
+		if got, _ := s.Load(tt.key); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.key, got, tt.want)
+		}
+	}
+
+	s.Delete("a")
+	if _, ok := s.Load("a"); ok {
+		t.Error("a found after delete")
+	}
+}
+
+func TestRevalidationHandlerCredentials(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  http.Header
+		second http.Header
+	}{
+		{"Authorization", nil, http.Header{"Authorization": {"Bearer x"}}},
+		{"Cookie", nil, http.Header{"Cookie": {"session=x"}}},
+		{"RetainedAuthorization", http.Header{"Authorization": {"Bearer x"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := "a"
+			h, calls := countingHandler(&v, nil)
+			rh := &RevalidationHandler{Handler: h}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, vv := range tt.first {
+				r.Header[k] = vv
+			}
+			rr := httptest.NewRecorder()
+			rh.ServeHTTP(rr, r)
+
+			r = httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+			for k, vv := range tt.second {
+				r.Header[k] = vv
+			}
+			rh.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got, want := *calls, 2; got != want {
+				t.Errorf("got %v calls, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRevalidationHandlerBody(t *testing.T) {
+	v := "a"
+	h, _ := countingHandler(&v, nil)
+	store := NewMemoryStore(1)
+	rh := &RevalidationHandler{Handler: h, Store: store}
+
+	rh.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	cr, ok := store.Load(cacheKey(httptest.NewRequest(http.MethodGet, "/", nil)))
+	if !ok {
+		t.Fatal("response not retained")
+	}
+	if cr.Body != nil {
+		t.Errorf("got body %q, want none", cr.Body)
+	}
+	if cr.ETag == "" {
+		t.Error("no entity tag retained")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	productionMu     sync.RWMutex
+	production       bool
+	productionReport func(error)
+)
+
+// SetProduction enables or disables production mode. In production mode, the message of any
+// error response with a 5xx status code written by WriteError, WriteErr and related functions is
+// replaced with the text of the status code (ie. "Internal Server Error"), and any details are
+// omitted. This prevents internal error text from leaking to clients. If report is non-nil, it is
+// called with the original error before the sanitized response is written. Production mode takes
+// precedence over debug mode for these responses.
+func SetProduction(enabled bool, report func(err error)) {
+	productionMu.Lock()
+	defer productionMu.Unlock()
+	production = enabled
+	productionReport = report
+}
+
+// sanitize returns je with internal information removed if production mode is enabled and je
+// describes a server error. The original error, cause if non-nil or je otherwise, is reported
+// via the hook established by SetProduction.
+func sanitize(je *Error, cause error) *Error {
+	productionMu.RLock()
+	enabled, report := production, productionReport
+	productionMu.RUnlock()
+
+	if !enabled || je.Code < http.StatusInternalServerError {
+		return je
+	}
+
+	if report != nil {
+		if cause == nil {
+			cause = je
+		}
+		report(cause)
+	}
+
+	return &Error{
+		Code:       je.Code,
+		AppCode:    je.AppCode,
+		Message:    http.StatusText(je.Code),
+		RetryAfter: je.RetryAfter,
+	}
+}
+
+// isProduction reports whether production mode is enabled.
+func isProduction() bool {
+	productionMu.RLock()
+	defer productionMu.RUnlock()
+	return production
+}
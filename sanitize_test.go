@@ -0,0 +1,64 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestProduction(t *testing.T) {
+	errSecret := errors.New("pq: password authentication failed")
+
+	tests := []struct {
+		name       string
+		production bool
+		debug      bool
+		err        error
+		wantErr    *Error
+		wantReport error
+	}{
+		{"Disabled", false, false, errSecret, &Error{Code: http.StatusInternalServerError, Message: errSecret.Error()}, nil},
+		{"ServerError", true, false, errSecret, &Error{Code: http.StatusInternalServerError, Message: "Internal Server Error"}, errSecret},
+		{"ServerErrorDebug", true, true, errSecret, &Error{Code: http.StatusInternalServerError, Message: "Internal Server Error"}, errSecret},
+		{"ServerErrorDetails", true, false, &Error{Code: http.StatusBadGateway, AppCode: "UPSTREAM", Message: "dial tcp 10.0.0.1:5432", Details: map[string]interface{}{"host": "10.0.0.1"}}, &Error{Code: http.StatusBadGateway, AppCode: "UPSTREAM", Message: "Bad Gateway"}, &Error{Code: http.StatusBadGateway}},
+		{"ClientError", true, false, NewError("blah", http.StatusNotFound), &Error{Code: http.StatusNotFound, Message: "blah"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported error
+			SetProduction(tt.production, func(err error) { reported = err })
+			defer SetProduction(false, nil)
+			SetDebug(tt.debug)
+			defer SetDebug(false)
+
+			rr := httptest.NewRecorder()
+
+			if err := WriteErr(rr, tt.err); err != nil {
+				t.Fatalf("failed to write error: %v", err)
+			}
+
+			var je *Error
+			if !errors.As(ReadError(rr.Body), &je) {
+				t.Fatalf("failed to read error")
+			}
+			if got, want := je, tt.wantErr; !reflect.DeepEqual(got, want) {
+				t.Errorf("got error %+v, want %+v", got, want)
+			}
+
+			if tt.wantReport == nil {
+				if reported != nil {
+					t.Errorf("got reported error %v, want none", reported)
+				}
+			} else if !errors.Is(reported, tt.wantReport) {
+				t.Errorf("got reported error %v, want %v", reported, tt.wantReport)
+			}
+		})
+	}
+}
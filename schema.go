@@ -0,0 +1,67 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSchemaViolation is returned by the write functions when the data of a response does not match
+// the schema established by WithSchema.
+var ErrSchemaViolation = errors.New("jsonresp: response data does not match schema")
+
+// SchemaValidator validates the JSON encoding of the data of a response against a schema, such as
+// a JSON Schema. The openapi package provides a SchemaValidator for the schemas it generates.
+type SchemaValidator interface {
+	ValidateJSON(data []byte) error
+}
+
+// WithSchema causes the data written by WriteResponse and related functions to be validated by v
+// before the response is written, so that drift between a handler and the documented schema of
+// its route is detected at the source. It is typically included in the options of an Encoder
+// used by the handlers of a route. The data is validated as supplied, before fields are selected
+// or redacted, and error responses are not validated.
+//
+// If the data does not match the schema, a 500 status code is written in place of the response,
+// as for an encoding failure, and an error wrapping ErrSchemaViolation is returned. If production
+// mode is enabled, the violation is instead reported to the hook established by SetProduction,
+// and the response is written. As validation requires the data to be encoded an additional time,
+// WithSchema is best suited to development and tests.
+func WithSchema(v SchemaValidator) Option {
+	return func(o *options) {
+		o.schema = v
+	}
+}
+
+// validateData validates the data of jr with the validator established by WithSchema. If
+// production mode is enabled, a violation is reported via the hook established by SetProduction,
+// rather than returned.
+func (o *options) validateData(jr Response) error {
+	if o.schema == nil || jr.Data == nil || jr.Error != nil {
+		return nil
+	}
+
+	b, err := o.marshalValue(jr.Data)
+	if err != nil {
+		return err
+	}
+	if err := o.schema.ValidateJSON(b); err != nil {
+		err = fmt.Errorf("%w: %v", ErrSchemaViolation, err)
+
+		productionMu.RLock()
+		enabled, report := production, productionReport
+		productionMu.RUnlock()
+
+		if !enabled {
+			return err
+		}
+		if report != nil {
+			report(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// validatorFunc is a SchemaValidator implemented by a function.
+type validatorFunc func(data []byte) error
+
+func (f validatorFunc) ValidateJSON(data []byte) error { return f(data) }
+
+var errNoName = errors.New(`missing "name"`)
+
+// nameValidator requires data to contain a name member.
+var nameValidator = validatorFunc(func(data []byte) error {
+	if !bytes.Contains(data, []byte(`"name"`)) {
+		return errNoName
+	}
+	return nil
+})
+
+func TestWithSchema(t *testing.T) {
+	type thing struct {
+		Name  string `json:"name"`
+		Value int    `json:"value"`
+	}
+
+	tests := []struct {
+		name       string
+		data       interface{}
+		opts       []Option
+		production bool
+		wantCode   int
+		wantBody   string
+		wantErr    bool
+		wantReport bool
+	}{
+		{"Valid", thing{Name: "a"}, nil, false, http.StatusOK, `{"data":{"name":"a","value":0}}`, false, false},
+		{"Invalid", map[string]int{"value": 1}, nil, false, http.StatusInternalServerError, string(encodeFailureBody), true, false},
+		{"InvalidProduction", map[string]int{"value": 1}, nil, true, http.StatusOK, `{"data":{"value":1}}`, false, true},
+		{"BeforeFields", thing{Name: "a"}, []Option{WithFields(FieldSet{"value": nil})}, false, http.StatusOK, `{"data":{"value":0}}`, false, false},
+		{"Raw", json.RawMessage(`{"name":"a"}`), nil, false, http.StatusOK, `{"data":{"name":"a"}}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reported error
+			SetProduction(tt.production, func(err error) { reported = err })
+			defer SetProduction(false, nil)
+
+			rr := httptest.NewRecorder()
+			err := WriteResponse(rr, tt.data, http.StatusOK, append(tt.opts, WithSchema(nameValidator))...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrSchemaViolation) {
+					t.Errorf("got error %v, want %v", err, ErrSchemaViolation)
+				}
+				if got, want := err.Error(), `jsonresp: failed to encode response: jsonresp: response data does not match schema: missing "name"`; got != want {
+					t.Errorf("got error %q, want %q", got, want)
+				}
+			}
+
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+			if got, want := errors.Is(reported, ErrSchemaViolation), tt.wantReport; got != want {
+				t.Errorf("got report %v, want report %v", reported, want)
+			}
+		})
+	}
+}
+
+func TestWithSchemaError(t *testing.T) {
+	called := false
+	v := validatorFunc(func([]byte) error {
+		called = true
+		return errNoName
+	})
+
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithSchema(v)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if called {
+		t.Error("error response validated")
+	}
+	if got, want := rr.Code, http.StatusNotFound; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+}
+
+func TestWithSchemaReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid", `{"name":"a"}`, http.StatusOK, `{"data":{"name":"a"}}`},
+		{"Invalid", `{"a":1}`, http.StatusInternalServerError, string(encodeFailureBody)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			err := WriteResponseFrom(rr, strings.NewReader(tt.data), nil, http.StatusOK, WithSchema(nameValidator))
+			if got, want := err != nil, tt.wantCode != http.StatusOK; got != want {
+				t.Fatalf("got error %v, want error %v", err, want)
+			}
+			if got, want := rr.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+		})
+	}
+}
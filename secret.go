@@ -0,0 +1,225 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Redacted is the value with which secrets are replaced in responses.
+const Redacted = "[REDACTED]"
+
+// maxSecretDepth limits the depth to which values are searched for secrets, so that cyclic values
+// do not cause unbounded recursion. Such values cannot be encoded, so are reported as containing
+// secrets, leaving the encoder to fail.
+const maxSecretDepth = 1000
+
+var (
+	secretsMu    sync.RWMutex
+	secretTypes  = map[reflect.Type]bool{}
+	secretsCache = map[reflect.Type]bool{}
+)
+
+// RegisterSecretType causes values of the type of v, such as a token or password type, to be
+// replaced with Redacted wherever they appear in responses written by this package. Pointers to
+// the type are redacted in the same way, unless nil. Struct fields of other types may be redacted
+// with the `jsonresp:"secret"` struct tag.
+//
+// Secrets are redacted from the data and metadata of responses, and the details of errors. The
+// encoding of a value that implements json.Marshaler is not inspected, unless the value itself is
+// a secret.
+func RegisterSecretType(v interface{}) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		panic("jsonresp: v must not be nil")
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secretTypes[t] = true
+	secretsCache = map[reflect.Type]bool{}
+}
+
+// isSecretType reports whether t was registered with RegisterSecretType.
+func isSecretType(t reflect.Type) bool {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	return secretTypes[t]
+}
+
+// mayHaveSecrets reports whether the values of t may contain secrets.
+func mayHaveSecrets(t reflect.Type) bool {
+	secretsMu.RLock()
+	ok, cached := secretsCache[t]
+	secretsMu.RUnlock()
+	if cached {
+		return ok
+	}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	return computeMayHaveSecrets(t, map[reflect.Type]bool{})
+}
+
+// computeMayHaveSecrets reports whether the values of t may contain secrets. The types being
+// computed by callers are recorded in seen. The caller must hold secretsMu.
+func computeMayHaveSecrets(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if ok, cached := secretsCache[t]; cached {
+		return ok
+	}
+	if seen[t] {
+		// The type is recursive, so is conservatively assumed to contain secrets.
+		return true
+	}
+	seen[t] = true
+
+	var ok bool
+	switch {
+	case secretTypes[t]:
+		ok = true
+	case t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType):
+	default:
+		switch t.Kind() {
+		case reflect.Interface:
+			ok = true
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			ok = computeMayHaveSecrets(t.Elem(), seen)
+		case reflect.Struct:
+			for _, f := range redactFields(t) {
+				if f.secret || computeMayHaveSecrets(t.FieldByIndex(f.index).Type, seen) {
+					ok = true
+					break
+				}
+			}
+		}
+	}
+
+	secretsCache[t] = ok
+	return ok
+}
+
+// hasSecrets reports whether v contains secrets.
+func hasSecrets(v reflect.Value) bool {
+	return valueHasSecrets(v, 0)
+}
+
+// valueHasSecrets reports whether v, nested depth levels within the value being inspected,
+// contains secrets.
+func valueHasSecrets(v reflect.Value, depth int) bool {
+	if !v.IsValid() || !mayHaveSecrets(v.Type()) {
+		return false
+	}
+	if depth > maxSecretDepth || isSecretType(v.Type()) {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil() && valueHasSecrets(v.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if valueHasSecrets(v.Index(i), depth+1) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for it := v.MapRange(); it.Next(); {
+			if valueHasSecrets(it.Value(), depth+1) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		for _, f := range redactFields(v.Type()) {
+			if f.secret || valueHasSecrets(fieldByIndex(v, f.index), depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redactedJSON is the JSON encoding of Redacted.
+var redactedJSON = json.RawMessage(`"` + Redacted + `"`)
+
+// redactSecrets returns v, or if it contains secrets, its JSON encoding with the secrets replaced
+// by Redacted.
+func (o *options) redactSecrets(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if !hasSecrets(rv) {
+		return v, nil
+	}
+	b, err := o.marshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	b, err = o.rewriteData(b, rv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redact secrets: %w", err)
+	}
+	return json.RawMessage(b), nil
+}
+
+// redactEnvelope returns jr with secrets redacted from its metadata and the details of its error.
+// The data of jr is redacted by transformData.
+func (o *options) redactEnvelope(jr Response) (Response, error) {
+	if jr.Meta != nil && hasSecrets(reflect.ValueOf(jr.Meta)) {
+		meta := make(map[string]interface{}, len(jr.Meta))
+		for k, v := range jr.Meta {
+			v, err := o.redactSecrets(v)
+			if err != nil {
+				return Response{}, err
+			}
+			meta[k] = v
+		}
+		jr.Meta = meta
+	}
+	if jr.Error != nil {
+		je, err := o.redactError(jr.Error)
+		if err != nil {
+			return Response{}, err
+		}
+		jr.Error = je
+	}
+	return jr, nil
+}
+
+// redactError returns je, or if its details contain secrets, a copy of je with the secrets
+// replaced by Redacted.
+func (o *options) redactError(je *Error) (*Error, error) {
+	if je.Details == nil || !hasSecrets(reflect.ValueOf(je.Details)) {
+		return je, nil
+	}
+	c := *je
+	c.Details = make(map[string]interface{}, len(je.Details))
+	for k, v := range je.Details {
+		v, err := o.redactSecrets(v)
+		if err != nil {
+			return nil, err
+		}
+		c.Details[k] = v
+	}
+	return &c, nil
+}
+
+// Redact returns the JSON encoding of v, with secrets replaced by Redacted, as they would be in a
+// response. It is intended for use by hooks that log values, such as a ResponseHook.
+func Redact(v interface{}) (json.RawMessage, error) {
+	o := newOptions(nil)
+	rv := reflect.ValueOf(v)
+	b, err := o.marshalValue(v)
+	if err != nil {
+		return nil, fmt.Errorf("jsonresp: failed to encode value: %v", err)
+	}
+	if hasSecrets(rv) {
+		if b, err = o.rewriteData(b, rv); err != nil {
+			return nil, fmt.Errorf("jsonresp: failed to redact secrets: %v", err)
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testToken string
+
+type testPassword struct {
+	Value string `json:"value"`
+}
+
+func (testPassword) MarshalJSON() ([]byte, error) { return []byte(`"hunter2"`), nil }
+
+func init() {
+	RegisterSecretType(testToken(""))
+	RegisterSecretType(testPassword{})
+}
+
+func TestSecrets(t *testing.T) {
+	type credentials struct {
+		User     string `json:"user"`
+		Password string `json:"password" jsonresp:"secret"`
+		Hint     *int   `json:"hint,omitempty" jsonresp:"secret"`
+		Key      *int   `json:"key" jsonresp:"secret"`
+	}
+	type node struct {
+		Name  string `json:"name"`
+		Token string `json:"token,omitempty" jsonresp:"secret"`
+		Next  *node  `json:"next,omitempty"`
+	}
+	type wrapper struct {
+		Token    testToken     `json:"token"`
+		TokenPtr *testToken    `json:"tokenPtr"`
+		Password testPassword  `json:"password"`
+		Any      interface{}   `json:"any"`
+		List     []interface{} `json:"list"`
+	}
+	tok := testToken("t")
+
+	tests := []struct {
+		name string
+		data interface{}
+		opts []Option
+		want string
+	}{
+		{"None", map[string]int{"a": 1}, nil, `{"data":{"a":1}}`},
+		{"Tag", credentials{User: "u", Password: "p"}, nil, `{"data":{"user":"u","password":"[REDACTED]","key":null}}`},
+		{"Slice", []credentials{{User: "u", Password: "p"}}, nil, `{"data":[{"user":"u","password":"[REDACTED]","key":null}]}`},
+		{"Recursive", node{Name: "a", Next: &node{Name: "b", Token: "t"}}, nil, `{"data":{"name":"a","next":{"name":"b","token":"[REDACTED]"}}}`},
+		{"RecursiveNone", node{Name: "a", Next: &node{Name: "b"}}, nil, `{"data":{"name":"a","next":{"name":"b"}}}`},
+		{"Type", wrapper{Token: "t", TokenPtr: &tok, Any: tok, List: []interface{}{1, tok}}, nil, `{"data":{"token":"[REDACTED]","tokenPtr":"[REDACTED]","password":"[REDACTED]","any":"[REDACTED]","list":[1,"[REDACTED]"]}}`},
+		{"NilPointer", wrapper{Token: "t"}, nil, `{"data":{"token":"[REDACTED]","tokenPtr":null,"password":"[REDACTED]","any":null,"list":null}}`},
+		{"TopLevel", tok, nil, `{"data":"[REDACTED]"}`},
+		{"Interface", map[string]interface{}{"a": credentials{User: "u", Password: "p"}}, nil, `{"data":{"a":{"user":"u","password":"[REDACTED]","key":null}}}`},
+		{"Fields", credentials{User: "u", Password: "p"}, []Option{WithFields(FieldSet{"password": nil})}, `{"data":{"password":"[REDACTED]"}}`},
+		{"MapIntKeys", map[int]credentials{1: {User: "u", Password: "p"}}, nil, `{"data":{"1":{"user":"u","password":"[REDACTED]","key":null}}}`},
+		{"MapIntKeysType", map[int]testToken{1: "t"}, nil, `{"data":{"1":"[REDACTED]"}}`},
+		{"Meta", "a", []Option{WithMeta("auth", credentials{User: "u", Password: "p"}), WithMeta("other", 1)}, `{"data":"a","meta":{"auth":{"user":"u","password":"[REDACTED]","key":null},"other":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, tt.data, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretsErrorDetails(t *testing.T) {
+	rr := httptest.NewRecorder()
+	err := WriteErrorDetails(rr, "bad token", map[string]interface{}{"token": testToken("t"), "field": "a"}, http.StatusBadRequest)
+	if err != nil {
+		t.Fatalf("failed to write response: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"error":{"code":400,"message":"bad token","details":{"field":"a","token":"[REDACTED]"}}}`; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"StringKeys", map[string]interface{}{"token": testToken("t"), "n": 1}, `{"n":1,"token":"[REDACTED]"}`},
+		{"IntKeys", map[int]interface{}{1: testToken("t"), 2: 1}, `{"1":"[REDACTED]","2":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Redact(tt.v)
+			if err != nil {
+				t.Fatalf("failed to redact: %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// collidingKey is a map key whose values all share the same text.
+type collidingKey int
+
+func (collidingKey) MarshalText() ([]byte, error) { return []byte("k"), nil }
+
+func TestSecretsMapKeysUnresolved(t *testing.T) {
+	data := map[collidingKey]testToken{1: "t", 2: "u"}
+
+	rr := httptest.NewRecorder()
+	if err := WriteResponse(rr, data, http.StatusOK); err == nil {
+		t.Error("unexpected success")
+	}
+	if got, want := rr.Code, http.StatusInternalServerError; got != want {
+		t.Errorf("got code %v, want %v", got, want)
+	}
+	if _, err := Redact(data); err == nil {
+		t.Error("unexpected success redacting")
+	}
+}
+
+func TestSecretsHooks(t *testing.T) {
+	details := map[string]interface{}{"token": testToken("t")}
+
+	var logged, hooked interface{}
+	opts := []Option{
+		WithErrorLog(nil, func(_ *http.Request, _ int, err error) {
+			if je, ok := err.(*Error); ok {
+				logged = je.Details["token"]
+			}
+		}),
+		WithResponseHook(func(_ *http.Request, jr *Response) {
+			if jr.Error != nil {
+				hooked = jr.Error.Details["token"]
+			}
+		}),
+	}
+
+	tests := []struct {
+		name  string
+		write func(http.ResponseWriter) error
+	}{
+		{"WriteErrorDetails", func(w http.ResponseWriter) error {
+			return WriteErrorDetails(w, "failed", details, http.StatusInternalServerError, opts...)
+		}},
+		{"WriteErr", func(w http.ResponseWriter) error {
+			return WriteErr(w, &Error{Code: http.StatusInternalServerError, Details: details}, opts...)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged, hooked = nil, nil
+			if err := tt.write(httptest.NewRecorder()); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got, _ := logged.(json.RawMessage); string(got) != string(redactedJSON) {
+				t.Errorf("got logged %v, want %s", logged, redactedJSON)
+			}
+			if got, _ := hooked.(json.RawMessage); string(got) != string(redactedJSON) {
+				t.Errorf("got hooked %v, want %s", hooked, redactedJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build go1.21
+
+package jsonresp
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// WithLogger causes server errors written in reply to r to be logged to l at the error level, in
+// the same way as WithErrorLog. The method and path of r, the status code of the response and the
+// underlying error are included as attributes. WithLogger requires Go 1.21 or later.
+func WithLogger(r *http.Request, l *slog.Logger) Option {
+	return WithErrorLog(r, func(r *http.Request, code int, err error) {
+		l.LogAttrs(r.Context(), slog.LevelError, "server error response",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", code),
+			slog.String("error", err.Error()),
+		)
+	})
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+//go:build go1.21
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/things", nil)
+	if err := WriteErr(httptest.NewRecorder(), errors.New("database unavailable"), WithLogger(r, l)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	want := `level=ERROR msg="server error response" method=POST path=/things status=500 error="database unavailable"`
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := WriteError(httptest.NewRecorder(), "blah", http.StatusBadRequest, WithLogger(r, l)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log output %q", buf.String())
+	}
+}
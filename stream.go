@@ -0,0 +1,354 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// streamResponse encodes jr directly to w, without buffering the response in full. If jr.Data is
+// a slice or array, each element is encoded and written in turn, so memory usage is proportional
+// to the largest element rather than the whole response. The status code is written before
+// encoding begins, so it cannot reflect an encoding failure.
+func streamResponse(w http.ResponseWriter, jr Response, code int, o *options) error {
+	var ce string
+	if o.compress {
+		h := w.Header()
+		addVary(h, "Accept-Encoding")
+		if ce = acceptedEncoding(o.acceptEncoding); ce != "" {
+			h.Set("Content-Encoding", ce)
+			h.Del("Content-Length")
+		}
+	}
+
+	if o.marshal != nil {
+		b, err := o.marshal(o.renameFields(jr))
+		if err != nil {
+			return writeEncodeFailure(w, err, o)
+		}
+		if err := o.checkResponseSize(len(b)); err != nil {
+			return writeTooLarge(w, err, o)
+		}
+		writeHeader(w, jr, code, o)
+		cw := &countingWriter{w: w}
+		defer func() { o.observeResponse(code, cw.n, jr) }()
+
+		return streamBody(cw, ce, func(w io.Writer) error {
+			_, err := w.Write(b)
+			return err
+		})
+	}
+
+	writeHeader(w, jr, code, o)
+	cw := &countingWriter{w: w}
+	defer func() { o.observeResponse(code, cw.n, jr) }()
+
+	return streamBody(cw, ce, func(bw io.Writer) error {
+		sw := &streamWriter{w: bw, o: o}
+		if o.maxResponseSize > 0 {
+			sw.w = &maxBytesWriter{w: bw, n: o.maxResponseSize}
+		}
+		if o.flush {
+			sw.flush = func() error { return flushWriter(bw, w) }
+		}
+		explicit := o.explicitNull && jr.Error == nil
+
+		sw.writeString("{")
+		if jr.Data != nil || explicit {
+			sw.writeKey("data")
+			sw.writeData(jr.Data)
+		}
+		if jr.Page != nil || explicit {
+			sw.writeKey("page")
+			sw.writeValue(jr.Page, 1)
+		}
+		if jr.Error != nil {
+			sw.writeKey("error")
+			sw.writeValue(jr.Error, 1)
+		}
+		if len(jr.Warnings) > 0 {
+			sw.writeKey("warnings")
+			sw.writeValue(jr.Warnings, 1)
+		}
+		if len(jr.Meta) > 0 {
+			sw.writeKey("meta")
+			sw.writeValue(jr.Meta, 1)
+		}
+		if len(jr.Links) > 0 {
+			sw.writeKey("links")
+			sw.writeValue(jr.Links, 1)
+		}
+		if sw.fields > 0 {
+			sw.writeNewline(0)
+		}
+		sw.writeString("}")
+		return sw.err
+	})
+}
+
+// streamBody calls write with a writer that writes to w, compressed using the content coding ce
+// if it is not empty.
+func streamBody(w io.Writer, ce string, write func(io.Writer) error) error {
+	if ce == "" {
+		if err := write(w); err != nil {
+			return fmt.Errorf("jsonresp: failed to stream response: %w", err)
+		}
+		return nil
+	}
+
+	c := newCompressor(w, ce)
+	defer releaseCompressor(c, ce)
+
+	if err := write(c); err != nil {
+		return fmt.Errorf("jsonresp: failed to stream response: %w", err)
+	}
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("jsonresp: failed to stream response: %w", err)
+	}
+	return nil
+}
+
+// streamWriter writes the fields of a response envelope to an io.Writer, retaining the first
+// error encountered.
+type streamWriter struct {
+	w      io.Writer
+	o      *options
+	flush  func() error // nil if not flushing
+	fields int
+	err    error
+}
+
+func (sw *streamWriter) indented() bool {
+	return sw.o.prefix != "" || sw.o.indent != ""
+}
+
+func (sw *streamWriter) write(b []byte) {
+	if sw.err == nil {
+		_, sw.err = sw.w.Write(b)
+	}
+}
+
+func (sw *streamWriter) writeString(s string) {
+	if sw.err == nil {
+		_, sw.err = io.WriteString(sw.w, s)
+	}
+}
+
+// writeNewline begins a new line at the supplied nesting depth, if indentation is enabled.
+func (sw *streamWriter) writeNewline(depth int) {
+	if sw.indented() {
+		sw.writeString("\n" + sw.o.prefix + strings.Repeat(sw.o.indent, depth))
+	}
+}
+
+// writeKey writes the name of an envelope field, preceded by a separator if required.
+func (sw *streamWriter) writeKey(name string) {
+	name = sw.o.fieldNames.name(name)
+	if sw.fields > 0 {
+		sw.writeString(",")
+	}
+	sw.fields++
+	sw.writeNewline(1)
+	if sw.indented() {
+		sw.writeString(`"` + name + `": `)
+	} else {
+		sw.writeString(`"` + name + `":`)
+	}
+}
+
+// writeValue encodes v in full at the supplied nesting depth.
+func (sw *streamWriter) writeValue(v interface{}, depth int) {
+	if sw.err != nil {
+		return
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		sw.err = err
+		return
+	}
+	if sw.indented() {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, b, sw.o.prefix+strings.Repeat(sw.o.indent, depth), sw.o.indent); err != nil {
+			sw.err = err
+			return
+		}
+		b = buf.Bytes()
+	}
+	sw.write(b)
+}
+
+// flushElement flushes the response after an element of the data has been written, if flushing
+// is enabled.
+func (sw *streamWriter) flushElement() {
+	if sw.err == nil && sw.flush != nil {
+		sw.err = sw.flush()
+	}
+}
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// writeData writes data, encoding the elements of slices and arrays individually.
+func (sw *streamWriter) writeData(data interface{}) {
+	if data == nil {
+		sw.writeString("null")
+		return
+	}
+
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Ptr && !v.IsNil() && !v.Type().Implements(marshalerType) {
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == readerDataType:
+		sw.copyData(data.(*readerData))
+		return
+	case v.Type().Implements(marshalerType):
+	case v.Kind() == reflect.Slice && v.IsNil():
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+	case v.Kind() == reflect.Slice, v.Kind() == reflect.Array:
+		sw.writeString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				sw.writeString(",")
+			}
+			sw.writeNewline(2)
+			sw.writeValue(v.Index(i).Interface(), 2)
+			sw.flushElement()
+		}
+		if v.Len() > 0 {
+			sw.writeNewline(1)
+		}
+		sw.writeString("]")
+		return
+	}
+
+	sw.writeValue(data, 1)
+}
+
+// copyData copies the pre-encoded JSON of rd, as it is read, writing null if it is empty or
+// contains only whitespace. If rd has already been read in full, such as to validate it, the JSON
+// read is written.
+func (sw *streamWriter) copyData(rd *readerData) {
+	if sw.err != nil {
+		return
+	}
+	if rd.read {
+		if b, err := rd.MarshalJSON(); err != nil {
+			sw.err = err
+		} else {
+			sw.write(b)
+		}
+		return
+	}
+
+	// Errors reading the data are distinguished from those writing the response.
+	er := &errReader{r: rd.r}
+	br := bufio.NewReader(er)
+
+	// Leading whitespace is discarded to determine whether the data is empty.
+	empty := true
+	for {
+		c, err := br.ReadByte()
+		if err != nil {
+			break
+		}
+		if !isSpace(c) {
+			_ = br.UnreadByte()
+			empty = false
+			break
+		}
+	}
+
+	var err error
+	if !empty {
+		_, err = io.Copy(sw.w, br)
+	}
+	switch {
+	case er.err != nil:
+		sw.err = fmt.Errorf("failed to read data: %w", er.err)
+	case err != nil:
+		sw.err = err
+	case empty:
+		sw.writeString("null")
+	}
+}
+
+// isSpace reports whether c is JSON whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// errReader is an io.Reader that retains the first error, other than io.EOF, returned by the
+// underlying io.Reader.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (er *errReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) && er.err == nil {
+		er.err = err
+	}
+	return n, err
+}
+
+// readerData is the data of a response written by WriteResponseFrom.
+type readerData struct {
+	r io.Reader
+
+	read bool
+	b    []byte
+	err  error
+}
+
+var readerDataType = reflect.TypeOf((*readerData)(nil))
+
+// MarshalJSON reads the pre-encoded JSON of rd in full. It is used when the response cannot be
+// streamed.
+func (rd *readerData) MarshalJSON() ([]byte, error) {
+	if !rd.read {
+		rd.read = true
+		if rd.b, rd.err = io.ReadAll(rd.r); rd.err != nil {
+			rd.err = fmt.Errorf("failed to read data: %w", rd.err)
+		} else if len(bytes.TrimSpace(rd.b)) == 0 {
+			rd.b = []byte("null")
+		}
+	}
+	return rd.b, rd.err
+}
+
+// WriteResponseFrom writes a status code and JSON response containing the pre-encoded JSON read
+// from data and pd to w. The JSON is copied into the response as it is read, without being
+// buffered in full or re-encoded, so memory usage does not depend on its size. This is intended
+// for proxying large documents, such as those held in object storage. If data is empty, the data
+// of the response is null. The caller remains responsible for closing data.
+//
+// Because the status code is written before data is read, an error reading data results in a
+// truncated response, and is returned. The JSON is not validated, so data must contain a single
+// valid JSON value. Options that depend on the content of the response, such as WithFormat,
+// WithFields, WithETag or WithHead, cause data to be read in full, validated and written in the
+// same way as by WriteRawResponse.
+func WriteResponseFrom(w http.ResponseWriter, data io.Reader, pd *PageDetails, code int, opts ...Option) error {
+	o := newOptions(opts)
+	o.stream = !o.etag && o.lastModified.IsZero()
+
+	jr := Response{
+		Data: &readerData{r: data},
+		Page: pd,
+	}
+	return encodeResponse(w, jr, code, o)
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testMarshaler []int
+
+func (testMarshaler) MarshalJSON() ([]byte, error) { return []byte(`"custom"`), nil }
+
+func TestStreamResponse(t *testing.T) {
+	type TestStruct struct {
+		Value string `json:"value"`
+	}
+
+	items := []TestStruct{{"a"}, {"b"}}
+	var nilSlice []TestStruct
+
+	tests := []struct {
+		name string
+		jr   Response
+	}{
+		{"Empty", Response{}},
+		{"Struct", Response{Data: TestStruct{"blah"}}},
+		{"Slice", Response{Data: items}},
+		{"SlicePointer", Response{Data: &items}},
+		{"SliceEmpty", Response{Data: []TestStruct{}}},
+		{"SliceNil", Response{Data: nilSlice}},
+		{"Array", Response{Data: [2]int{1, 2}}},
+		{"Bytes", Response{Data: []byte("blah")}},
+		{"Marshaler", Response{Data: testMarshaler{1, 2}}},
+		{"Page", Response{Data: items, Page: &PageDetails{Next: "n", TotalSize: 2}}},
+		{"Error", Response{Error: &Error{Code: http.StatusNotFound, Message: "blah"}}},
+	}
+	for _, tt := range tests {
+		for _, indent := range []string{"", "  "} {
+			t.Run(tt.name, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+
+				o := newOptions([]Option{WithIndent("", indent)})
+				if err := streamResponse(rr, tt.jr, http.StatusOK, o); err != nil {
+					t.Fatalf("failed to stream response: %v", err)
+				}
+
+				// The streamed response should be byte-for-byte identical to the buffered response.
+				want, err := json.Marshal(tt.jr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if indent != "" {
+					var buf bytes.Buffer
+					if err := json.Indent(&buf, want, "", indent); err != nil {
+						t.Fatal(err)
+					}
+					want = buf.Bytes()
+				}
+				if got := rr.Body.String(); got != string(want) {
+					t.Errorf("got body %q, want %q", got, want)
+				}
+				if cl := rr.Header().Get("Content-Length"); cl != "" {
+					t.Errorf("got content length %v, want none", cl)
+				}
+			})
+		}
+	}
+}
+
+// failingReader is an io.Reader that returns data, followed by err.
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestWriteResponseFrom(t *testing.T) {
+	errRead := errors.New("read failed")
+
+	tests := []struct {
+		name              string
+		data              io.Reader
+		pd                *PageDetails
+		opts              []Option
+		wantErr           bool
+		wantBody          string
+		wantContentLength bool
+	}{
+		{
+			name:     "Object",
+			data:     strings.NewReader(`{"value":"blåh"}`),
+			wantBody: `{"data":{"value":"blåh"}}`,
+		},
+		{
+			name:     "Whitespace",
+			data:     strings.NewReader("[1, 2]\n"),
+			wantBody: "{\"data\":[1, 2]\n}",
+		},
+		{
+			name:     "Empty",
+			data:     strings.NewReader(""),
+			wantBody: `{"data":null}`,
+		},
+		{
+			name:     "OnlyWhitespace",
+			data:     strings.NewReader(" \n\t"),
+			wantBody: `{"data":null}`,
+		},
+		{
+			name:     "LeadingWhitespace",
+			data:     strings.NewReader(" \n[1]"),
+			wantBody: `{"data":[1]}`,
+		},
+		{
+			name:     "Page",
+			data:     strings.NewReader(`[1,2]`),
+			pd:       &PageDetails{Next: "n"},
+			wantBody: `{"data":[1,2],"page":{"next":"n"}}`,
+		},
+		{
+			name:     "Meta",
+			data:     strings.NewReader(`[1,2]`),
+			opts:     []Option{WithMeta("a", "b")},
+			wantBody: `{"data":[1,2],"meta":{"a":"b"}}`,
+		},
+		{
+			name:     "Indent",
+			data:     strings.NewReader(`[1,2]`),
+			opts:     []Option{WithIndent("", "  ")},
+			wantBody: "{\n  \"data\": [1,2]\n}",
+		},
+		{
+			name:     "ReadError",
+			data:     &failingReader{data: `[1,`, err: errRead},
+			wantErr:  true,
+			wantBody: `{"data":[1,`,
+		},
+		{
+			name:              "Buffered",
+			data:              strings.NewReader(`[1, 2]`),
+			opts:              []Option{WithETag()},
+			wantBody:          `{"data":[1,2]}`,
+			wantContentLength: true,
+		},
+		{
+			name:              "BufferedEmpty",
+			data:              strings.NewReader(" "),
+			opts:              []Option{WithETag()},
+			wantBody:          `{"data":null}`,
+			wantContentLength: true,
+		},
+		{
+			name:              "BufferedReadError",
+			data:              &failingReader{data: `[1,`, err: errRead},
+			opts:              []Option{WithETag()},
+			wantErr:           true,
+			wantBody:          string(encodeFailureBody),
+			wantContentLength: true,
+		},
+		{
+			name:     "Fields",
+			data:     strings.NewReader(`{"a":1,"b":2}`),
+			opts:     []Option{WithFields(FieldSet{"a": nil})},
+			wantBody: `{"data":{"a":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+
+			err := WriteResponseFrom(rr, tt.data, tt.pd, http.StatusOK, tt.opts...)
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v, want %v", err, want)
+			}
+			if err != nil && !strings.Contains(err.Error(), errRead.Error()) {
+				t.Errorf("got error %v, want %v", err, errRead)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+			if got, want := rr.Header().Get("Content-Length") != "", tt.wantContentLength; got != want {
+				t.Errorf("got content length %v, want %v", got, want)
+			}
+		})
+	}
+}
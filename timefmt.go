@@ -0,0 +1,142 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat specifies how time.Time values within the data of a response are encoded.
+type TimeFormat int
+
+const (
+	// TimeDefault encodes times as by their MarshalJSON method, in RFC 3339 format with their
+	// original time zone offset.
+	TimeDefault TimeFormat = iota
+
+	// TimeRFC3339UTC encodes times as strings in RFC 3339 format, converted to UTC, with
+	// fractional seconds if non-zero.
+	TimeRFC3339UTC
+
+	// TimeUnix encodes times as the number of seconds elapsed since the Unix epoch.
+	TimeUnix
+
+	// TimeUnixMilli encodes times as the number of milliseconds elapsed since the Unix epoch.
+	TimeUnixMilli
+)
+
+// DurationFormat specifies how time.Duration values within the data of a response are encoded.
+type DurationFormat int
+
+const (
+	// DurationDefault encodes durations as an integer number of nanoseconds.
+	DurationDefault DurationFormat = iota
+
+	// DurationISO8601 encodes durations as strings in ISO 8601 format, such as "PT1H30M", using
+	// hours, minutes and seconds only.
+	DurationISO8601
+
+	// DurationString encodes durations as strings in the format of time.Duration.String, such as
+	// "1h30m0s".
+	DurationString
+
+	// DurationMillis encodes durations as an integer number of milliseconds, truncated.
+	DurationMillis
+)
+
+// WithTimeFormat causes time.Time values within the data of a successful response, including those
+// nested within structs, slices and maps, to be encoded in the format f. When used with WithCodec
+// or WithEncoder, the encoding produced by the codec is rewritten, so the codec must produce JSON.
+func WithTimeFormat(f TimeFormat) Option {
+	return func(o *options) {
+		o.timeFormat = f
+	}
+}
+
+// WithDurationFormat causes time.Duration values within the data of a successful response,
+// including those nested within structs, slices and maps, to be encoded in the format f. When used
+// with WithCodec or WithEncoder, the encoding produced by the codec is rewritten, so the codec
+// must produce JSON.
+func WithDurationFormat(f DurationFormat) Option {
+	return func(o *options) {
+		o.durationFormat = f
+	}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// formatTimeValue returns the encoding of v in the formats established by o, if v is a time.Time
+// or time.Duration that is not encoded by default.
+func (o *options) formatTimeValue(v reflect.Value) ([]byte, bool) {
+	switch {
+	case v.Type() == timeType && o.timeFormat != TimeDefault:
+		return formatTime(v.Interface().(time.Time), o.timeFormat), true
+	case v.Type() == durationType && o.durationFormat != DurationDefault:
+		return formatDuration(time.Duration(v.Int()), o.durationFormat), true
+	}
+	return nil, false
+}
+
+// formatTime returns the JSON encoding of t in the format f.
+func formatTime(t time.Time, f TimeFormat) []byte {
+	switch f {
+	case TimeUnix:
+		return strconv.AppendInt(nil, t.Unix(), 10)
+	case TimeUnixMilli:
+		return strconv.AppendInt(nil, t.UnixMilli(), 10)
+	default:
+		return strconv.AppendQuote(nil, t.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+// formatDuration returns the JSON encoding of d in the format f.
+func formatDuration(d time.Duration, f DurationFormat) []byte {
+	switch f {
+	case DurationString:
+		return strconv.AppendQuote(nil, d.String())
+	case DurationMillis:
+		return strconv.AppendInt(nil, d.Milliseconds(), 10)
+	default:
+		return strconv.AppendQuote(nil, isoDuration(d))
+	}
+}
+
+// isoDuration returns d in ISO 8601 format, using hours, minutes and seconds.
+func isoDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var sb strings.Builder
+	// The magnitude of the minimum duration cannot be represented as a positive duration.
+	u := uint64(d)
+	if d < 0 {
+		sb.WriteByte('-')
+		u = -u
+	}
+	sb.WriteString("PT")
+
+	if h := u / uint64(time.Hour); h > 0 {
+		sb.WriteString(strconv.FormatUint(h, 10) + "H")
+	}
+	if m := u / uint64(time.Minute) % 60; m > 0 {
+		sb.WriteString(strconv.FormatUint(m, 10) + "M")
+	}
+	if ns := u % uint64(time.Minute); ns > 0 {
+		s := strconv.FormatUint(ns/uint64(time.Second), 10)
+		if frac := ns % uint64(time.Second); frac > 0 {
+			s += strings.TrimRight("."+strconv.FormatUint(frac+uint64(time.Second), 10)[1:], "0")
+		}
+		sb.WriteString(s + "S")
+	}
+	return sb.String()
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestISODuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{time.Second, "PT1S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{time.Nanosecond, "PT0.000000001S"},
+		{90 * time.Minute, "PT1H30M"},
+		{36*time.Hour + 5*time.Second, "PT36H5S"},
+		{-2 * time.Minute, "-PT2M"},
+		{math.MinInt64, "-PT2562047H47M16.854775808S"},
+	}
+	for _, tt := range tests {
+		if got := isoDuration(tt.d); got != tt.want {
+			t.Errorf("%v: got %v, want %v", int64(tt.d), got, tt.want)
+		}
+	}
+}
+
+func TestWithTimeFormat(t *testing.T) {
+	type event struct {
+		At      time.Time     `json:"at"`
+		Ended   *time.Time    `json:"ended,omitempty"`
+		Took    time.Duration `json:"took"`
+		Timeout time.Duration `json:"timeout,omitempty"`
+	}
+
+	loc := time.FixedZone("EST", -5*60*60)
+	at := time.Date(2021, 3, 4, 5, 6, 7, 500000000, loc)
+	ended := at.Add(time.Hour)
+	e := event{At: at, Ended: &ended, Took: 1500 * time.Millisecond}
+
+	tests := []struct {
+		name string
+		data interface{}
+		opts []Option
+		want string
+	}{
+		{"Default", e, nil,
+			`{"data":{"at":"2021-03-04T05:06:07.5-05:00","ended":"2021-03-04T06:06:07.5-05:00","took":1500000000}}`},
+		{"RFC3339UTC", e, []Option{WithTimeFormat(TimeRFC3339UTC)},
+			`{"data":{"at":"2021-03-04T10:06:07.5Z","ended":"2021-03-04T11:06:07.5Z","took":1500000000}}`},
+		{"Unix", e, []Option{WithTimeFormat(TimeUnix)},
+			`{"data":{"at":1614852367,"ended":1614855967,"took":1500000000}}`},
+		{"UnixMilli", e, []Option{WithTimeFormat(TimeUnixMilli)},
+			`{"data":{"at":1614852367500,"ended":1614855967500,"took":1500000000}}`},
+		{"ISO8601", e, []Option{WithDurationFormat(DurationISO8601)},
+			`{"data":{"at":"2021-03-04T05:06:07.5-05:00","ended":"2021-03-04T06:06:07.5-05:00","took":"PT1.5S"}}`},
+		{"DurationString", e, []Option{WithDurationFormat(DurationString)},
+			`{"data":{"at":"2021-03-04T05:06:07.5-05:00","ended":"2021-03-04T06:06:07.5-05:00","took":"1.5s"}}`},
+		{"DurationMillis", e, []Option{WithTimeFormat(TimeUnix), WithDurationFormat(DurationMillis)},
+			`{"data":{"at":1614852367,"ended":1614855967,"took":1500}}`},
+		{"Top", at, []Option{WithTimeFormat(TimeRFC3339UTC)}, `{"data":"2021-03-04T10:06:07.5Z"}`},
+		{"Slice", []time.Duration{time.Second, time.Minute}, []Option{WithDurationFormat(DurationISO8601)}, `{"data":["PT1S","PT1M"]}`},
+		{"Map", map[string]interface{}{"at": at, "n": 1}, []Option{WithTimeFormat(TimeUnix)}, `{"data":{"at":1614852367,"n":1}}`},
+		{"MapIntKeys", map[int]time.Time{1: at}, []Option{WithTimeFormat(TimeUnix)}, `{"data":{"1":1614852367}}`},
+		{"Fields", e, []Option{WithTimeFormat(TimeUnix), WithFields(FieldSet{"at": nil})}, `{"data":{"at":1614852367}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, tt.data, http.StatusOK, tt.opts...); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			if got := rr.Body.String(); got != tt.want {
+				t.Errorf("got body %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// versionParam is the media type parameter identifying the envelope version of a response.
+const versionParam = "v"
+
+var (
+	versionsMu sync.RWMutex
+	versions   = map[string]Format{}
+)
+
+// RegisterVersion makes envelope version v available to WithVersion, NegotiateVersion and the
+// HTTP read functions. When written in version v, responses are converted from the current
+// envelope by the FromJSON method of f, and when read, they are converted back by its ToJSON
+// method. The ContentType method of f is not used. If f is nil, version v is the current envelope.
+func RegisterVersion(v string, f Format) {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+	versions[v] = f
+}
+
+// registeredVersion returns the renderer of envelope version v, and whether v is registered.
+func registeredVersion(v string) (Format, bool) { //nolint:ireturn
+	versionsMu.RLock()
+	defer versionsMu.RUnlock()
+	f, ok := versions[v]
+	return f, ok
+}
+
+// versionFormat is the JSON format of a particular envelope version.
+type versionFormat struct {
+	v string
+	f Format
+}
+
+func (vf versionFormat) ContentType() string {
+	return mime.FormatMediaType("application/json", map[string]string{versionParam: vf.v})
+}
+
+func (vf versionFormat) FromJSON(w io.Writer, b []byte) error {
+	if vf.f == nil {
+		return JSON.FromJSON(w, b)
+	}
+	return vf.f.FromJSON(w, b)
+}
+
+func (vf versionFormat) ToJSON(r io.Reader) ([]byte, error) {
+	if vf.f == nil {
+		return JSON.ToJSON(r)
+	}
+	return vf.f.ToJSON(r)
+}
+
+// WithVersion causes responses to be written and read in envelope version v, using the renderer
+// registered by RegisterVersion. When writing, the media type of the response is JSON, with a "v"
+// parameter identifying the version. If v is empty or has no registered renderer, the current
+// envelope is used. WithVersion replaces any format established by WithFormat.
+func WithVersion(v string) Option {
+	return func(o *options) {
+		if v == "" {
+			o.format = nil
+			return
+		}
+		f, _ := registeredVersion(v)
+		o.format = versionFormat{v: v, f: f}
+	}
+}
+
+// NegotiateVersion returns the registered envelope version best satisfying the Accept header of
+// r, as identified by the "v" parameter of its JSON media ranges, such as "application/json; v=2".
+// If r does not request a registered version, an empty string is returned, indicating the current
+// envelope.
+func NegotiateVersion(r *http.Request) string {
+	var best string
+	bestQ := 0.0
+	for _, mr := range parseAccept(r.Header.Get("Accept")) {
+		if mr.typ != "application" || mr.subtype != "json" || mr.version == "" {
+			continue
+		}
+		if _, ok := registeredVersion(mr.version); ok && mr.q > bestQ {
+			best, bestQ = mr.version, mr.q
+		}
+	}
+	return best
+}
+
+// ResponseVersion returns the envelope version of res, as identified by the "v" parameter of its
+// Content-Type header, or an empty string if it is not specified.
+func ResponseVersion(res *http.Response) string {
+	_, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return params[versionParam]
+}
+
+// withResponseVersion prepends to opts an option that reads responses in the envelope version of
+// res, if it is registered.
+func withResponseVersion(res *http.Response, opts []Option) []Option {
+	v := ResponseVersion(res)
+	if v == "" {
+		return opts
+	}
+	if _, ok := registeredVersion(v); !ok {
+		return opts
+	}
+	return append([]Option{WithVersion(v)}, opts...)
+}
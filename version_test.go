@@ -0,0 +1,161 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// v1Format renders the envelope as version 1, in which data was named "result".
+type v1Format struct{}
+
+func (v1Format) ContentType() string { return "application/json" }
+
+func (v1Format) FromJSON(w io.Writer, b []byte) error {
+	_, err := w.Write(bytes.Replace(b, []byte(`{"data":`), []byte(`{"result":`), 1))
+	return err
+}
+
+func (v1Format) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Replace(b, []byte(`{"result":`), []byte(`{"data":`), 1), nil
+}
+
+// withVersions registers the supplied versions for the duration of the test.
+func withVersions(t *testing.T, vs map[string]Format) {
+	t.Helper()
+
+	versionsMu.Lock()
+	old := versions
+	versions = vs
+	versionsMu.Unlock()
+
+	t.Cleanup(func() {
+		versionsMu.Lock()
+		versions = old
+		versionsMu.Unlock()
+	})
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	withVersions(t, map[string]Format{"1": v1Format{}, "2": nil})
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"None", "", ""},
+		{"Unversioned", "application/json", ""},
+		{"Version", "application/json; v=1", "1"},
+		{"Current", "application/json; v=2", "2"},
+		{"Unregistered", "application/json; v=3", ""},
+		{"Quality", "application/json; v=1; q=0.5, application/json; v=2", "2"},
+		{"OtherType", "application/xml; v=1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept", tt.accept)
+
+			if got, want := NegotiateVersion(r), tt.want; got != want {
+				t.Errorf("got version %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithVersion(t *testing.T) {
+	withVersions(t, map[string]Format{"1": v1Format{}, "2": nil})
+
+	tests := []struct {
+		name            string
+		version         string
+		wantContentType string
+		wantBody        string
+	}{
+		{"Current", "", "application/json", `{"data":"blah"}`},
+		{"V1", "1", "application/json; v=1", `{"result":"blah"}`},
+		{"V2", "2", "application/json; v=2", `{"data":"blah"}`},
+		{"Unregistered", "3", "application/json; v=3", `{"data":"blah"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if err := WriteResponse(rr, "blah", http.StatusOK, WithVersion(tt.version)); err != nil {
+				t.Fatalf("failed to write response: %v", err)
+			}
+			res := rr.Result()
+
+			if got, want := res.Header.Get("Content-Type"), tt.wantContentType; got != want {
+				t.Errorf("got content type %q, want %q", got, want)
+			}
+			if got, want := rr.Body.String(), tt.wantBody; got != want {
+				t.Errorf("got body %q, want %q", got, want)
+			}
+			if got, want := ResponseVersion(res), tt.version; got != want {
+				t.Errorf("got version %q, want %q", got, want)
+			}
+
+			var s string
+			if _, err := ReadHTTPResponse(res, &s); err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if got, want := s, "blah"; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestReadHTTPErrorVersion(t *testing.T) {
+	withVersions(t, map[string]Format{"1": errorV1Format{}})
+
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithVersion("1")); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+	if got, want := rr.Body.String(), `{"err":{"code":404,"message":"blah"}}`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+
+	want := &Error{Code: http.StatusNotFound, Message: "blah"}
+	_, err := ReadHTTPResponse(rr.Result(), nil)
+	if !errors.Is(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+	var je *Error
+	if errors.As(err, &je) && !reflect.DeepEqual(je, want) {
+		t.Errorf("got error %#v, want %#v", je, want)
+	}
+}
+
+// errorV1Format renders the envelope as version 1, in which error was named "err".
+type errorV1Format struct{}
+
+func (errorV1Format) ContentType() string { return "application/json" }
+
+func (errorV1Format) FromJSON(w io.Writer, b []byte) error {
+	_, err := w.Write(bytes.Replace(b, []byte(`{"error":`), []byte(`{"err":`), 1))
+	return err
+}
+
+func (errorV1Format) ToJSON(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Replace(b, []byte(`{"err":`), []byte(`{"error":`), 1), nil
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// TextMessage is the message type of a WebSocket text message, per RFC 6455 section 11.8, in which
+// WriteMessage and WriteErrorMessage send responses.
+const TextMessage = 1
+
+// MetaCorrelationID is the metadata key under which WriteMessage and WriteErrorMessage record the
+// correlation ID of a message, which associates a response with the request to which it replies.
+const MetaCorrelationID = "correlationId"
+
+// MessageConn is a connection over which messages are exchanged, such as a WebSocket connection.
+// It is satisfied by the *Conn type of github.com/gorilla/websocket.
+type MessageConn interface {
+	// ReadMessage reads the next message, returning its type and content.
+	ReadMessage() (messageType int, p []byte, err error)
+
+	// WriteMessage writes a message of type messageType with content data.
+	WriteMessage(messageType int, data []byte) error
+}
+
+// WriteMessage sends a text message containing a JSON response containing data to c. If id is not
+// empty, it is recorded in the metadata of the response under the MetaCorrelationID key, so that
+// the receiver can associate the response with the request to which it replies. Options that set
+// headers, or apply to the HTTP response as a whole, have no effect.
+func WriteMessage(c MessageConn, id string, data interface{}, opts ...Option) error {
+	return writeMessage(c, id, Response{Data: data}, newOptions(opts))
+}
+
+// WriteErrorMessage sends a text message containing a JSON response describing err to c, in the
+// same way as WriteErr. If id is not empty, it is recorded in the metadata of the response under
+// the MetaCorrelationID key.
+func WriteErrorMessage(c MessageConn, id string, err error, opts ...Option) error {
+	o := newOptions(opts)
+
+	je := NewError("", http.StatusInternalServerError)
+	if err != nil {
+		je = errorFor(err)
+	}
+	return writeMessage(c, id, Response{Error: o.prepareError(je, err, 0)}, o)
+}
+
+// writeMessage sends a text message containing jr, with correlation ID id, to c.
+func writeMessage(c MessageConn, id string, jr Response, o *options) error {
+	if id != "" {
+		WithMeta(MetaCorrelationID, id)(o)
+	}
+	// Messages are always JSON envelopes, so that they can carry a correlation ID.
+	o.format = nil
+	o.bare = false
+
+	jr, err := o.prepareResponse(jr)
+	if err != nil {
+		return fmt.Errorf("jsonresp: failed to encode message: %v", err)
+	}
+
+	es := newEncodeState()
+	defer es.release()
+
+	if err := es.encodeResponse(jr, o); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode message: %v", err)
+	}
+	if err := o.checkResponseSize(es.Len()); err != nil {
+		return fmt.Errorf("jsonresp: failed to encode message: %w", err)
+	}
+	if err := c.WriteMessage(TextMessage, es.Bytes()); err != nil {
+		return fmt.Errorf("jsonresp: failed to write message: %w", err)
+	}
+	return nil
+}
+
+// ReadMessage reads the next message from c, which must contain a JSON response, such as sent by
+// WriteMessage, and unmarshals its data into v, in the same way as ReadResponse. The correlation ID
+// of the message, if any, is returned. If the response contains an error, it is returned along
+// with the correlation ID. Errors reading from c, such as when it is closed, are returned wrapped.
+func ReadMessage(c MessageConn, v interface{}, opts ...Option) (id string, err error) {
+	_, p, err := c.ReadMessage()
+	if err != nil {
+		return "", fmt.Errorf("jsonresp: failed to read message: %w", err)
+	}
+
+	o := newOptions(opts)
+
+	var u rawResponse
+	if err := o.decodeResponse(bytes.NewReader(p), &u); err != nil {
+		return "", fmt.Errorf("jsonresp: failed to read message: %w", err)
+	}
+	if o.envelopeTo != nil {
+		*o.envelopeTo = u.response()
+	}
+	id, _ = u.Meta[MetaCorrelationID].(string)
+
+	if u.Error != nil {
+		return id, u.Error.error()
+	}
+	if v != nil {
+		if err := o.unmarshalResponseData(&u, v); err != nil {
+			return id, fmt.Errorf("jsonresp: failed to unmarshal message: %w", err)
+		}
+	}
+	return id, nil
+}
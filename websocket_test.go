@@ -0,0 +1,165 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// testConn is a MessageConn that records the messages written to it, and returns them in turn
+// when read.
+type testConn struct {
+	types    []int
+	messages [][]byte
+	err      error
+}
+
+func (c *testConn) ReadMessage() (int, []byte, error) {
+	if len(c.messages) == 0 {
+		return 0, nil, io.EOF
+	}
+	t, p := c.types[0], c.messages[0]
+	c.types, c.messages = c.types[1:], c.messages[1:]
+	return t, p, nil
+}
+
+func (c *testConn) WriteMessage(messageType int, data []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.types = append(c.types, messageType)
+	c.messages = append(c.messages, append([]byte(nil), data...))
+	return nil
+}
+
+func TestWriteMessage(t *testing.T) {
+	type TestStruct struct {
+		Value string `json:"value"`
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		data    interface{}
+		opts    []Option
+		wantMsg string
+	}{
+		{"NoID", "", TestStruct{"blah"}, nil, `{"data":{"value":"blah"}}`},
+		{"ID", "1", TestStruct{"blah"}, nil, `{"data":{"value":"blah"},"meta":{"correlationId":"1"}}`},
+		{"Meta", "1", nil, []Option{WithMeta("a", "b")}, `{"meta":{"a":"b","correlationId":"1"}}`},
+		{"Bare", "1", "blah", []Option{WithBare()}, `{"data":"blah","meta":{"correlationId":"1"}}`},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &testConn{}
+
+			if err := WriteMessage(c, tt.id, tt.data, tt.opts...); err != nil {
+				t.Fatalf("failed to write message: %v", err)
+			}
+			if got, want := len(c.messages), 1; got != want {
+				t.Fatalf("got %v messages, want %v", got, want)
+			}
+			if got, want := c.types[0], TextMessage; got != want {
+				t.Errorf("got message type %v, want %v", got, want)
+			}
+			if got, want := string(c.messages[0]), tt.wantMsg; got != want {
+				t.Errorf("got message %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteMessageError(t *testing.T) {
+	errWrite := errors.New("write failed")
+
+	if err := WriteMessage(&testConn{err: errWrite}, "1", "blah"); !errors.Is(err, errWrite) {
+		t.Errorf("got error %v, want %v", err, errWrite)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{"Nil", nil, http.StatusInternalServerError},
+		{"Error", NewError("blah", http.StatusNotFound), http.StatusNotFound},
+		{"Wrapped", errors.New("blah"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &testConn{}
+
+			if err := WriteErrorMessage(c, "1", tt.err); err != nil {
+				t.Fatalf("failed to write message: %v", err)
+			}
+
+			id, err := ReadMessage(c, nil)
+			if got, want := id, "1"; got != want {
+				t.Errorf("got ID %q, want %q", got, want)
+			}
+			var je *Error
+			if !errors.As(err, &je) {
+				t.Fatalf("got error %v, want Error", err)
+			}
+			if got, want := je.Code, tt.wantCode; got != want {
+				t.Errorf("got code %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		wantID   string
+		wantData string
+		wantErr  bool
+	}{
+		{"NoID", `{"data":"blah"}`, "", "blah", false},
+		{"ID", `{"data":"blah","meta":{"correlationId":"1"}}`, "1", "blah", false},
+		{"InvalidID", `{"data":"blah","meta":{"correlationId":1}}`, "", "blah", false},
+		{"Invalid", `{"data":`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &testConn{types: []int{TextMessage}, messages: [][]byte{[]byte(tt.message)}}
+
+			var jr Response
+			var s string
+			id, err := ReadMessage(c, &s, WithEnvelope(&jr))
+			if got, want := err != nil, tt.wantErr; got != want {
+				t.Fatalf("got error %v, want %v", err, want)
+			}
+			if got, want := id, tt.wantID; got != want {
+				t.Errorf("got ID %q, want %q", got, want)
+			}
+			if got, want := s, tt.wantData; got != want {
+				t.Errorf("got data %q, want %q", got, want)
+			}
+			if !tt.wantErr && jr.Data == nil {
+				t.Errorf("got no envelope")
+			}
+		})
+	}
+
+	t.Run("Closed", func(t *testing.T) {
+		if _, err := ReadMessage(&testConn{}, nil); !errors.Is(err, io.EOF) {
+			t.Errorf("got error %v, want %v", err, io.EOF)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// XML is an XML rendering of the response envelope (application/xml), intended for legacy
+// consumers that cannot parse JSON. The JSON encoding of the response is mapped onto XML as
+// follows:
+//
+//   - The response is enclosed in a <response> element.
+//   - Each member of an object becomes a child element named for the member. Members whose names
+//     are not valid XML element names become <entry> elements with a "key" attribute.
+//   - Each element of an array becomes an <item> child element.
+//   - Strings, numbers and booleans become character data, and null becomes an empty element.
+//
+// For example, {"data":[1,2],"page":{"next":"n"}} is rendered as:
+//
+//	<response><data><item>1</item><item>2</item></data><page><next>n</next></page></response>
+//
+// The mapping does not retain type information, so XML is a write-only format, and ToJSON always
+// returns an error.
+var XML Format = xmlFormat{}
+
+type xmlFormat struct{}
+
+func (xmlFormat) ContentType() string { return "application/xml" }
+
+func (xmlFormat) FromJSON(w io.Writer, b []byte) error {
+	v, err := parseJSON(b)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := appendXML(&buf, "response", v); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (xmlFormat) ToJSON(r io.Reader) ([]byte, error) {
+	return nil, errors.New("xml: reading responses is not supported")
+}
+
+// isXMLName reports whether s is valid as an XML element name. Names beginning with "xml" are
+// reserved, and names containing colons are avoided as they denote namespaces.
+func isXMLName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r), r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// appendXML appends v to buf as an element with the supplied name.
+func appendXML(buf *bytes.Buffer, name string, v jsonValue) error {
+	return appendXMLElement(buf, name, nil, v)
+}
+
+// appendXMLElement appends v to buf as an element with the supplied name. If key is non-nil, it
+// is added as the value of the "key" attribute.
+func appendXMLElement(buf *bytes.Buffer, name string, key *string, v jsonValue) error {
+	buf.WriteString("<" + name)
+	if key != nil {
+		buf.WriteString(` key="`)
+		if err := xml.EscapeText(buf, []byte(*key)); err != nil {
+			return err
+		}
+		buf.WriteString(`"`)
+	}
+	if v.kind == 'n' {
+		buf.WriteString("/>")
+		return nil
+	}
+	buf.WriteString(">")
+
+	switch v.kind {
+	case 'b':
+		if v.b {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case 'd':
+		buf.WriteString(v.s)
+	case 's':
+		if err := xml.EscapeText(buf, []byte(v.s)); err != nil {
+			return err
+		}
+	case '[':
+		for _, e := range v.elems {
+			if err := appendXMLElement(buf, "item", nil, e); err != nil {
+				return err
+			}
+		}
+	case '{':
+		for i, e := range v.elems {
+			name, key := v.keys[i], (*string)(nil)
+			if !isXMLName(name) {
+				name, key = "entry", &v.keys[i]
+			}
+			if err := appendXMLElement(buf, name, key, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	buf.WriteString("</" + name + ">")
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018-2021, Sylabs Inc. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the LICENSE.md file
+// distributed with the sources of this project regarding your rights to use or distribute this
+// software.
+
+package jsonresp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestXML(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"Null", `null`, `<response/>`},
+		{"Scalars", `{"a":true,"b":false,"c":1.5,"d":"x<y&z"}`, `<response><a>true</a><b>false</b><c>1.5</c><d>x&lt;y&amp;z</d></response>`},
+		{"Array", `{"data":[1,null,[2]]}`, `<response><data><item>1</item><item/><item><item>2</item></item></data></response>`},
+		{"InvalidNames", `{"1a":1,"a b":2,"xmlns":3,"":4,"a\"":5}`, `<response><entry key="1a">1</entry><entry key="a b">2</entry><entry key="xmlns">3</entry><entry key="">4</entry><entry key="a&#34;">5</entry></response>`},
+		{"ValidNames", `{"_a":1,"b-c.d9":2,"été":3}`, `<response><_a>1</_a><b-c.d9>2</b-c.d9><été>3</été></response>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := XML.FromJSON(&buf, []byte(tt.json)); err != nil {
+				t.Fatalf("failed to convert from JSON: %v", err)
+			}
+
+			got := buf.String()
+			if !strings.HasPrefix(got, xml.Header) {
+				t.Fatalf("got %q, want XML header", got)
+			}
+			if got, want := strings.TrimPrefix(got, xml.Header), tt.want; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+
+			// The output must be well-formed.
+			dec := xml.NewDecoder(&buf)
+			for {
+				if _, err := dec.Token(); err != nil {
+					if !errors.Is(err, io.EOF) {
+						t.Errorf("got malformed XML: %v", err)
+					}
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWithFormatXML(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := WriteError(rr, "blah", http.StatusNotFound, WithFormat(XML)); err != nil {
+		t.Fatalf("failed to write error: %v", err)
+	}
+
+	if got, want := rr.Header().Get("Content-Type"), "application/xml"; got != want {
+		t.Errorf("got content type %q, want %q", got, want)
+	}
+	if got, want := rr.Body.String(), xml.Header+`<response><error><code>404</code><message>blah</message></error></response>`; got != want {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+
+	if err := ReadError(rr.Body, WithFormat(XML)); err != nil {
+		t.Errorf("got error %v, want nil", err)
+	}
+}